@@ -0,0 +1,64 @@
+package patch
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestAppendJSON(t *testing.T) {
+	type custom struct {
+		N int `json:"n"`
+	}
+	for _, v := range []interface{}{
+		nil, true, false, "", "plain", "<b>&amp;</b>", "quote \" backslash \\ \n\r\t\x00\x1f\x7f",
+		"invalid \xff utf-8", "separators    ", "émoji 🎉",
+		0.0, -0.0, 1.0, -1.5, 1e20, 1e21, 1e-6, 1e-7, 123456789.125, math.MaxFloat64, math.SmallestNonzeroFloat64,
+		float32(0.1), float32(1e-7), 42, int64(-7), json.Number("12345678901234567890"), json.Number("1.50"),
+		map[string]interface{}{"b": []interface{}{1.0, "x", nil}, "a": map[string]interface{}{}, "": false},
+		[]interface{}{}, custom{3}, []interface{}{custom{4}},
+	} {
+		expected, err := marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := AppendJSON([]byte("prefix "), v)
+		if err != nil {
+			t.Errorf("%#v: %v", v, err)
+			continue
+		}
+		if string(got) != "prefix "+string(expected) {
+			t.Errorf("%#v: expected %s, got %s", v, expected, got)
+		}
+	}
+
+	for _, v := range []interface{}{math.NaN(), math.Inf(-1), json.Number("1x"), json.Number(`"s"`), []interface{}{math.Inf(1)}} {
+		if _, err := AppendJSON(nil, v); err == nil {
+			t.Errorf("%#v: expected an error", v)
+		}
+	}
+
+	// the buffer is reused once large enough
+	doc := decode(`{"a": [1, 2, 3], "b": "text"}`)
+	buf := make([]byte, 0, 64)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ = AppendJSON(buf[:0], doc)
+	})
+	if allocs > 1 {
+		t.Errorf("expected at most one allocation, for sorting keys, got %v", allocs)
+	}
+}
+
+func TestAppendApplyBytes(t *testing.T) {
+	buf := []byte("[")
+	buf, err := AppendApplyBytes(buf, []byte(`{"id": 12345678901234567890}`), []byte(`[{"op": "add", "path": "/html", "value": "<b>"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `[{"html":"<b>","id":12345678901234567890}`; string(buf) != expected {
+		t.Errorf("expected %s, got %s", expected, buf)
+	}
+	if _, err := AppendApplyBytes(nil, []byte(`{}`), []byte(`[{"op": "remove", "path": "/x"}]`)); err == nil {
+		t.Error("expected an error")
+	}
+}
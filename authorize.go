@@ -0,0 +1,65 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrForbidden matches errors for operations refused by Options.Authorize
+// or Options.Policy.
+var ErrForbidden = errors.New("operation forbidden")
+
+// AuthorizeFunc decides whether op may be applied. It is called with the
+// context given to WithAuthorize, the operation and the location its path
+// resolves to in the document, before the operation is applied. A non-nil
+// error refuses the operation and fails the patch.
+type AuthorizeFunc func(ctx context.Context, op Operation, target Target) error
+
+// ForbiddenError reports an operation refused by Options.Authorize or
+// Options.Policy.
+type ForbiddenError struct {
+	Index int
+	Op    string
+	Path  string
+	Err   error // returned by the AuthorizeFunc, or a *PolicyError
+}
+
+func (e *ForbiddenError) Error() string {
+	return fmt.Sprintf("operation %d (%s %s): forbidden: %v", e.Index, e.Op, e.Path, e.Err)
+}
+
+func (e *ForbiddenError) Unwrap() error { return e.Err }
+
+// Is makes ForbiddenError match ErrForbidden.
+func (e *ForbiddenError) Is(target error) bool { return target == ErrForbidden }
+
+// Code returns "forbidden".
+func (e *ForbiddenError) Code() string { return "forbidden" }
+
+// WithAuthorize calls fn with ctx before each operation is applied. See
+// Options.Authorize.
+func WithAuthorize(ctx context.Context, fn AuthorizeFunc) Option {
+	return func(o *Options) { o.Context, o.Authorize = ctx, fn }
+}
+
+// authorize asks Options.Policy and Options.Authorize whether the i-th
+// operation may be applied to the location c resolves to.
+func (a *applier) authorize(i int, op *Operation, c *command) error {
+	if a.opts.Policy != nil {
+		if err := a.opts.Policy.check(op.Op, c.path, c.from); err != nil {
+			return &ForbiddenError{Index: i, Op: op.Op, Path: op.Path, Err: err}
+		}
+	}
+	if a.opts.Authorize == nil {
+		return nil
+	}
+	ctx := a.opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := a.opts.Authorize(ctx, *op, c.target()); err != nil {
+		return &ForbiddenError{Index: i, Op: op.Op, Path: op.Path, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type subjectKey struct{}
+
+// specOnly lets the "dev" subject change anything but /spec/replicas, and
+// everyone else only read.
+func specOnly(ctx context.Context, op Operation, target Target) error {
+	if op.Op == "test" {
+		return nil
+	}
+	if ctx.Value(subjectKey{}) != "dev" {
+		return errors.New("read only")
+	}
+	if strings.HasPrefix(target.Pointer.String(), "/spec/replicas") {
+		return errors.New("replicas are managed by the autoscaler")
+	}
+	return nil
+}
+
+func TestAuthorize(t *testing.T) {
+	doc := decode(`{"spec": {"replicas": 3, "image": "v1"}}`)
+	dev := context.WithValue(context.Background(), subjectKey{}, "dev")
+
+	result, err := Apply(doc, parseStr(`[
+		{"op": "test", "path": "/spec/image", "value": "v1"},
+		{"op": "replace", "path": "/spec/image", "value": "v2"}
+	]`), WithAuthorize(dev, specOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, decode(`{"spec": {"replicas": 3, "image": "v2"}}`)) {
+		t.Errorf("unexpected result %v", result)
+	}
+
+	_, err = Apply(doc, parseStr(`[
+		{"op": "replace", "path": "/spec/image", "value": "v2"},
+		{"op": "replace", "path": "/spec/replicas", "value": 5}
+	]`), WithAuthorize(dev, specOnly))
+	var fe *ForbiddenError
+	if !errors.Is(err, ErrForbidden) || !errors.As(err, &fe) || fe.Index != 1 || fe.Path != "/spec/replicas" {
+		t.Fatalf("expected operation 1 to be forbidden, got %v", err)
+	}
+	if fe.Code() != "forbidden" || !strings.Contains(err.Error(), "autoscaler") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	_, err = Apply(doc, parseStr(`[{"op": "add", "path": "/x", "value": 1}]`), WithAuthorize(context.Background(), specOnly))
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected anonymous write to be forbidden, got %v", err)
+	}
+}
+
+func TestAuthorizeTarget(t *testing.T) {
+	var targets []Target
+	record := func(ctx context.Context, op Operation, target Target) error {
+		targets = append(targets, target)
+		return nil
+	}
+	_, err := Apply(decode(`{"a": [1, 2]}`), parseStr(`[
+		{"op": "add", "path": "/a/-", "value": 3},
+		{"op": "replace", "path": "/a/0", "value": 0},
+		{"op": "move", "from": "/a/1", "path": "/b"}
+	]`), WithOptions(Options{Authorize: record}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Target{
+		{Pointer: []string{"a", "-"}},
+		{Pointer: []string{"a", "0"}, Value: 1.0, Exists: true},
+		{Pointer: []string{"b"}},
+	}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("expected targets %v, got %v", expected, targets)
+	}
+}
+
+func TestAuthorizeContinueOnError(t *testing.T) {
+	deny := func(ctx context.Context, op Operation, target Target) error {
+		if op.Path == "/b" {
+			return errors.New("no")
+		}
+		return nil
+	}
+	result, err := Apply(decode(`{}`), parseStr(`[
+		{"op": "add", "path": "/a", "value": 1},
+		{"op": "add", "path": "/b", "value": 2}
+	]`), WithAuthorize(context.Background(), deny), WithContinueOnError())
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected a forbidden error, got %v", err)
+	}
+	if !reflect.DeepEqual(result, decode(`{"a": 1}`)) {
+		t.Errorf("unexpected result %v", result)
+	}
+}
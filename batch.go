@@ -0,0 +1,158 @@
+package patch
+
+import (
+	"iter"
+	"runtime"
+	"sync"
+)
+
+// Result is the outcome of a patch applied to one document of a batch.
+type Result struct {
+	Doc interface{} // the patched document, nil when Err fails the patch
+	Err error
+}
+
+// ApplyAll applies operations to every document of docs, as Apply does
+// with opts, and returns the result for each document in the order of
+// docs. The patch is compiled once and applied to up to parallelism
+// documents at a time, or runtime.GOMAXPROCS(0) when parallelism is not
+// positive. Hooks and other functions given in opts are called
+// concurrently and must be safe for concurrent use.
+//
+// A patch that does not compile, and so would fail on every document, is
+// reported as the error of ApplyAll without applying it to any document,
+// unless ContinueOnError is set.
+func ApplyAll(docs []interface{}, operations []Operation, parallelism int, opts ...Option) ([]Result, error) {
+	b, err := newBatch(operations, opts)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(docs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers(parallelism, len(docs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = b.apply(docs[i], operations)
+			}
+		}()
+	}
+	for i := range docs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results, nil
+}
+
+// ApplyAllSeq is ApplyAll for a sequence of documents too large to hold in
+// memory, such as the records of a migration read from a database. The
+// returned sequence yields the position of each document in docs and its
+// result, in order, reading ahead of the consumer by at most twice
+// parallelism documents. Stopping the iteration stops reading docs.
+func ApplyAllSeq(docs iter.Seq[interface{}], operations []Operation, parallelism int, opts ...Option) (iter.Seq2[int, Result], error) {
+	b, err := newBatch(operations, opts)
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(int, Result) bool) {
+		n := workers(parallelism, -1)
+		// every document gets a channel for its result, queued in order, so
+		// that the results are yielded in order as workers complete them
+		type job struct {
+			doc    interface{}
+			result chan Result
+		}
+		jobs := make(chan job)
+		queue := make(chan chan Result, n)
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(n + 1)
+		for range n {
+			go func() {
+				defer wg.Done()
+				for j := range jobs {
+					j.result <- b.apply(j.doc, operations)
+				}
+			}()
+		}
+		go func() {
+			defer wg.Done()
+			defer close(queue)
+			defer close(jobs)
+			for doc := range docs {
+				j := job{doc: doc, result: make(chan Result, 1)}
+				select {
+				case queue <- j.result:
+				case <-done:
+					return
+				}
+				select {
+				case jobs <- j:
+				case <-done:
+					return
+				}
+			}
+		}()
+		defer wg.Wait()
+		defer close(done)
+		i := 0
+		for result := range queue {
+			if !yield(i, <-result) {
+				return
+			}
+			i++
+		}
+	}, nil
+}
+
+// workers returns the number of workers to apply a patch to n documents
+// with, or to an unknown number of them when n is negative.
+func workers(parallelism, n int) int {
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	if n >= 0 {
+		parallelism = min(parallelism, n)
+	}
+	return parallelism
+}
+
+// batch is a patch compiled once for the documents of ApplyAll.
+type batch struct {
+	opts     *Options
+	compiled []*instruction
+	scope    []string
+}
+
+func newBatch(operations []Operation, opts []Option) (*batch, error) {
+	options := newOptions(opts)
+	a := &applier{opts: options}
+	b := &batch{opts: options, compiled: make([]*instruction, len(operations))}
+	for i, op := range operations {
+		ins, err := a.compile(i, op)
+		if err != nil {
+			if options.ContinueOnError {
+				// each document reports the error when skipping it
+				continue
+			}
+			return nil, err
+		}
+		ins.shared = true
+		b.compiled[i] = ins
+	}
+	b.scope = a.scope
+	return b, nil
+}
+
+// apply applies the patch to doc.
+func (b *batch) apply(doc interface{}, operations []Operation) Result {
+	if !b.opts.InPlace {
+		doc = deepCopy(doc)
+	}
+	a := &applier{opts: b.opts, compiled: b.compiled, scope: b.scope}
+	doc, err := a.apply(doc, operations)
+	return Result{Doc: doc, Err: err}
+}
@@ -0,0 +1,71 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestApplyAll(t *testing.T) {
+	var docs []interface{}
+	for i := range 100 {
+		docs = append(docs, decode(fmt.Sprintf(`{"id": %d, "tags": []}`, i)))
+	}
+	docs[7] = decode(`{"id": 7}`)
+	ops := parseStr(`[{"op": "add", "path": "/tags/-", "value": "migrated"}, {"op": "remove", "path": "/id"}]`)
+	results, err := ApplyAll(docs, ops, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range results {
+		if i == 7 {
+			if r.Err == nil || r.Doc != nil {
+				t.Errorf("expected document 7 to fail, got %v", r)
+			}
+			continue
+		}
+		if r.Err != nil || !jsonEqual(r.Doc, decode(`{"tags": ["migrated"]}`)) {
+			t.Errorf("document %d: unexpected result %v, %v", i, r.Doc, r.Err)
+		}
+	}
+	if !jsonEqual(docs[0], decode(`{"id": 0, "tags": []}`)) {
+		t.Error("expected the documents to be left unchanged")
+	}
+
+	if _, err := ApplyAll(docs, []Operation{{Op: "add", Path: "x", Value: json.RawMessage("1")}}, 0); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("expected the patch to be refused, got %v", err)
+	}
+	results, err = ApplyAll(docs[:2], []Operation{{Op: "bogus"}, {Op: "add", Path: "/n", Value: json.RawMessage("1")}}, 0, WithContinueOnError())
+	if err != nil || len(results) != 2 || !errors.Is(results[1].Err, ErrInvalidPatch) || !jsonEqual(results[1].Doc, decode(`{"id": 1, "n": 1, "tags": []}`)) {
+		t.Errorf("expected the invalid operation to be skipped, got %v, %v", results, err)
+	}
+}
+
+func TestApplyAllSeq(t *testing.T) {
+	docs := func(yield func(interface{}) bool) {
+		for i := range 50 {
+			if !yield(map[string]interface{}{"n": float64(i)}) {
+				return
+			}
+		}
+	}
+	seq, err := ApplyAllSeq(docs, parseStr(`[{"op": "add", "path": "/ok", "value": true}]`), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []float64
+	for i, r := range seq {
+		if r.Err != nil || r.Doc.(map[string]interface{})["ok"] != true || r.Doc.(map[string]interface{})["n"] != float64(i) {
+			t.Fatalf("document %d: unexpected result %v, %v", i, r.Doc, r.Err)
+		}
+		got = append(got, float64(i))
+		if i == 29 {
+			break
+		}
+	}
+	if len(got) != 30 || !slices.IsSorted(got) {
+		t.Errorf("expected the first 30 results in order, got %v", got)
+	}
+}
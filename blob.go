@@ -0,0 +1,139 @@
+package patch
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// MaxBlobSize is the largest blob, in bytes, that a bspatch operation may
+// produce.
+const MaxBlobSize = 256 << 20
+
+var errCorruptDelta = errors.New("corrupt bsdiff delta")
+
+// BSPatchOperator returns an operator that updates a base64 encoded blob in
+// place by applying a binary delta to its decoded bytes, so that large
+// blobs embedded in a document can be updated without sending them whole.
+// The operation's value is the base64 encoding of a delta in the BSDIFF40
+// format produced by the bsdiff tool:
+//
+//	{"op": "bspatch", "path": "/firmware/image", "value": "QlNESUZGNDA..."}
+//
+// The operator only accepts paths matching one of patterns, whose tokens
+// may be "*" to match any token. It is not registered by default:
+//
+//	patch.Apply(doc, ops, patch.WithOperator("bspatch", patch.BSPatchOperator("/firmware/*")))
+func BSPatchOperator(patterns ...string) OperatorFunc {
+	parsed := make([]pointer.Pointer, len(patterns))
+	for i, p := range patterns {
+		var err error
+		if parsed[i], err = pointer.Parse(p); err != nil {
+			panic("patch: invalid bspatch pattern " + p + ": " + err.Error())
+		}
+	}
+	return func(doc interface{}, op Operation, target Target, value interface{}) (interface{}, error) {
+		allowed := false
+		for _, p := range parsed {
+			if len(p) == len(target.Pointer) && matchPrefix(p, target.Pointer) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, fmt.Errorf("%s is not a designated blob", op.Path)
+		}
+		if !target.Exists {
+			return nil, ErrNotFound
+		}
+		encoded, ok := target.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("blob is a %T, not a base64 string", target.Value)
+		}
+		old, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("blob: %v", err)
+		}
+		delta, ok := value.(string)
+		if !ok {
+			return nil, &InvalidPatchError{Op: op.Op, Err: fmt.Errorf("value must be a base64 string, not %T", value)}
+		}
+		d, err := base64.StdEncoding.DecodeString(delta)
+		if err != nil {
+			return nil, &InvalidPatchError{Op: op.Op, Err: fmt.Errorf("value: %v", err)}
+		}
+		updated, err := bspatch(old, d)
+		if err != nil {
+			return nil, err
+		}
+		return target.Pointer.Set(doc, base64.StdEncoding.EncodeToString(updated))
+	}
+}
+
+// bspatch applies a BSDIFF40 delta to old.
+func bspatch(old, delta []byte) ([]byte, error) {
+	if len(delta) < 32 || string(delta[:8]) != "BSDIFF40" {
+		return nil, errCorruptDelta
+	}
+	ctrlLen, diffLen, newSize := offtin(delta[8:]), offtin(delta[16:]), offtin(delta[24:])
+	if ctrlLen < 0 || diffLen < 0 || newSize < 0 || ctrlLen > int64(len(delta)-32) || diffLen > int64(len(delta)-32)-ctrlLen {
+		return nil, errCorruptDelta
+	}
+	if newSize > MaxBlobSize {
+		return nil, fmt.Errorf("patched blob would be %d bytes, more than %d", newSize, MaxBlobSize)
+	}
+	body := delta[32:]
+	ctrl := bzip2.NewReader(bytes.NewReader(body[:ctrlLen]))
+	diff := bzip2.NewReader(bytes.NewReader(body[ctrlLen : ctrlLen+diffLen]))
+	extra := bzip2.NewReader(bytes.NewReader(body[ctrlLen+diffLen:]))
+
+	out := make([]byte, newSize)
+	var buf [24]byte
+	var oldPos, newPos int64
+	for newPos < newSize {
+		if _, err := io.ReadFull(ctrl, buf[:]); err != nil {
+			return nil, errCorruptDelta
+		}
+		add, copyLen, seek := offtin(buf[:]), offtin(buf[8:]), offtin(buf[16:])
+		if add < 0 || copyLen < 0 || add > newSize-newPos || copyLen > newSize-newPos-add {
+			return nil, errCorruptDelta
+		}
+		if _, err := io.ReadFull(diff, out[newPos:newPos+add]); err != nil {
+			return nil, errCorruptDelta
+		}
+		for i := int64(0); i < add; i++ {
+			if j := oldPos + i; j >= 0 && j < int64(len(old)) {
+				out[newPos+i] += old[j]
+			}
+		}
+		newPos += add
+		oldPos += add
+		if _, err := io.ReadFull(extra, out[newPos:newPos+copyLen]); err != nil {
+			return nil, errCorruptDelta
+		}
+		newPos += copyLen
+		oldPos += seek
+	}
+	return out, nil
+}
+
+// offtin decodes the sign and magnitude integers used by bsdiff.
+func offtin(b []byte) int64 {
+	var y int64
+	for i := 7; i >= 0; i-- {
+		c := b[i]
+		if i == 7 {
+			c &= 0x7f
+		}
+		y = y<<8 | int64(c)
+	}
+	if b[7]&0x80 != 0 {
+		y = -y
+	}
+	return y
+}
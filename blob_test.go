@@ -0,0 +1,42 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+)
+
+const (
+	blobV1    = "aGVsbG8gd29ybGQsIHRoaXMgaXMgdGhlIGZpcm13YXJlIHYx"
+	blobV2    = "aGVsbG8gd29ybGQsIHRoaXMgaXMgdGhlIGZpcm13YXJlIHYyISE="
+	blobDelta = "QlNESUZGNDArAAAAAAAAACkAAAAAAAAAJgAAAAAAAABCWmg5MUFZJlNZ/zr2WAAABdAAWAgEACAAMM0AkBpBVm4u5IpwoSH+deywQlpoOTFBWSZTWa+McOEAAABgAGAAAACgADDMDPUFzi7kinChIV8Y4cJCWmg5MUFZJlNZkRDHLwAAAJAAIAAgACEYRsLuSKcKEhIiGOXg"
+)
+
+func TestBSPatch(t *testing.T) {
+	bspatch := WithOperator("bspatch", BSPatchOperator("/firmware/*"))
+	doc := map[string]interface{}{
+		"firmware": map[string]interface{}{"main": blobV1},
+		"other":    blobV1,
+	}
+	result, err := Apply(doc, []Operation{{Op: "bspatch", Path: "/firmware/main", Value: []byte(`"` + blobDelta + `"`)}}, bspatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.(map[string]interface{})["firmware"].(map[string]interface{})["main"]; got != blobV2 {
+		t.Errorf("expected %s, got %s", blobV2, got)
+	}
+
+	for _, op := range []Operation{
+		{Op: "bspatch", Path: "/other", Value: []byte(`"` + blobDelta + `"`)},
+		{Op: "bspatch", Path: "/firmware/missing", Value: []byte(`"` + blobDelta + `"`)},
+		{Op: "bspatch", Path: "/firmware/main", Value: []byte(`"QlNESUZGNDA="`)},
+		{Op: "bspatch", Path: "/firmware/main", Value: []byte(`"` + blobDelta[:100] + `"`)},
+	} {
+		if _, err := Apply(doc, []Operation{op}, bspatch); err == nil {
+			t.Errorf("%s %s: expected an error", op.Path, op.Value)
+		}
+	}
+	_, err = Apply(doc, []Operation{{Op: "bspatch", Path: "/firmware/main", Value: []byte(`42`)}}, bspatch)
+	if !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("expected an invalid patch error, got %v", err)
+	}
+}
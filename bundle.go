@@ -0,0 +1,134 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Bundle stores many similar documents as one base document and, for each
+// document, the patch turning the base into it. When the documents share
+// most of their content, this is much smaller than the documents
+// themselves:
+//
+//	{
+//	  "base": {"plan": "free", "limits": {...}},
+//	  "docs": {
+//	    "acme": [{"op": "replace", "path": "/plan", "value": "pro"}],
+//	    "initech": []
+//	  }
+//	}
+type Bundle struct {
+	Base interface{}            `json:"base"`
+	Docs map[string][]Operation `json:"docs"`
+}
+
+// Export returns a Bundle holding docs, keyed by name. The base document is
+// built from the content most of the documents agree on: an object member
+// present in more than half of the objects at the same location is kept,
+// with the value most of them have, and other values are taken whole from
+// the most frequent one.
+func Export(docs map[string]interface{}) (*Bundle, error) {
+	names := make([]string, 0, len(docs))
+	for name := range docs {
+		names = append(names, name)
+	}
+	// sorted, so that ties always resolve to the same base
+	sort.Strings(names)
+	values := make([]interface{}, len(names))
+	for i, name := range names {
+		values[i] = docs[name]
+	}
+	base, err := consensus(values)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{Base: base, Docs: make(map[string][]Operation, len(docs))}
+	for _, name := range names {
+		if b.Docs[name], err = CreatePatch(base, docs[name]); err != nil {
+			return nil, fmt.Errorf("document %q: %v", name, err)
+		}
+	}
+	return b, nil
+}
+
+// Import applies the patch of every document of b to the base and returns
+// the documents, keyed by name. opts are passed to Apply.
+func (b *Bundle) Import(opts ...Option) (map[string]interface{}, error) {
+	docs := make(map[string]interface{}, len(b.Docs))
+	for name, ops := range b.Docs {
+		doc, err := Apply(b.Base, ops, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("document %q: %w", name, err)
+		}
+		docs[name] = doc
+	}
+	return docs, nil
+}
+
+// MarshalBundle encodes docs as a Bundle.
+func MarshalBundle(docs map[string]interface{}) ([]byte, error) {
+	b, err := Export(docs)
+	if err != nil {
+		return nil, err
+	}
+	return marshal(b)
+}
+
+// ParseBundle decodes documents encoded as a Bundle. Numbers are decoded as
+// json.Number, so they are restored exactly.
+func ParseBundle(data []byte) (map[string]interface{}, error) {
+	var b Bundle
+	if err := unmarshalNumber(data, &b); err != nil {
+		return nil, err
+	}
+	return b.Import(WithUseNumber())
+}
+
+// consensus returns the value most of values agree on. When more than half
+// of them are objects, it is the object made of the members present in more
+// than half of those, each with the consensus of its values. Otherwise it is
+// a copy of the most frequent value.
+func consensus(values []interface{}) (interface{}, error) {
+	var objects []map[string]interface{}
+	for _, v := range values {
+		if m, ok := v.(map[string]interface{}); ok {
+			objects = append(objects, m)
+		}
+	}
+	if 2*len(objects) > len(values) {
+		members := make(map[string][]interface{})
+		for _, m := range objects {
+			for k, v := range m {
+				members[k] = append(members[k], v)
+			}
+		}
+		base := make(map[string]interface{})
+		for k, vs := range members {
+			if 2*len(vs) <= len(objects) {
+				continue
+			}
+			v, err := consensus(vs)
+			if err != nil {
+				return nil, err
+			}
+			base[k] = v
+		}
+		return base, nil
+	}
+
+	var best interface{}
+	bestCount := 0
+	counts := make(map[string]int)
+	for _, v := range values {
+		key, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		counts[string(key)]++
+		if n := counts[string(key)]; n > bestCount {
+			best, bestCount = v, n
+		}
+	}
+	return deepCopy(best), nil
+}
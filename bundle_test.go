@@ -0,0 +1,73 @@
+package patch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	docs := map[string]interface{}{
+		"acme":    decode(`{"plan": "pro", "limits": {"users": 100, "storage": 50}, "features": ["sso", "audit"]}`),
+		"initech": decode(`{"plan": "free", "limits": {"users": 10, "storage": 50}, "features": ["sso"]}`),
+		"hooli":   decode(`{"plan": "free", "limits": {"users": 10, "storage": 50}, "features": ["sso"], "beta": true}`),
+		"globex":  decode(`{"plan": "free", "limits": {"users": 10, "storage": 5}, "features": ["sso"]}`),
+		"broken":  decode(`[1, 2]`),
+	}
+	b, err := Export(docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := decode(`{"plan": "free", "limits": {"users": 10, "storage": 50}, "features": ["sso"]}`)
+	if !reflect.DeepEqual(b.Base, expected) {
+		t.Errorf("expected base %v, got %v", expected, b.Base)
+	}
+	if len(b.Docs["initech"]) != 0 || len(b.Docs["hooli"]) != 1 {
+		t.Errorf("expected patches against the shared content only, got %v", b.Docs)
+	}
+
+	imported, err := b.Import()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(imported, docs) {
+		t.Errorf("expected %v, got %v", docs, imported)
+	}
+}
+
+func TestBundleBytes(t *testing.T) {
+	docs := map[string]interface{}{
+		"a": decode(`{"id": 1, "config": {"retries": 3, "endpoints": ["x", "y"]}}`),
+		"b": decode(`{"id": 2, "config": {"retries": 3, "endpoints": ["x", "y"]}}`),
+		"c": decode(`{"id": 3, "config": {"retries": 3, "endpoints": ["x", "y"]}}`),
+	}
+	data, err := MarshalBundle(docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), `"endpoints"`); n != 1 {
+		t.Errorf("expected the shared content to be stored once, found %d copies in %s", n, data)
+	}
+	parsed, err := ParseBundle(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, doc := range parsed {
+		if !jsonEqual(doc, docs[name]) {
+			t.Errorf("%s: expected %v, got %v", name, docs[name], doc)
+		}
+	}
+
+	// numbers are restored exactly
+	parsed, err = ParseBundle([]byte(`{"base": {"n": 12345678901234567890}, "docs": {"a": [], "b": [{"op": "replace", "path": "/n", "value": 0.1000000000000000055511151231257827}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = marshal(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"a":{"n":12345678901234567890},"b":{"n":0.1000000000000000055511151231257827}}` {
+		t.Errorf("unexpected documents %s", data)
+	}
+}
@@ -0,0 +1,45 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ApplyBytes applies a JSON encoded patch to a JSON encoded document and
+// returns the encoded result. Numbers in the document and in the patch are
+// decoded as json.Number, so large integers and precise decimals are written
+// out exactly as they were read instead of passing through float64.
+func ApplyBytes(doc []byte, patch []byte) ([]byte, error) {
+	ops, err := Parse(patch)
+	if err != nil {
+		return nil, err
+	}
+	return applyFormat(jsonFormat{}, doc, ops, nil)
+}
+
+// unmarshalNumber is json.Unmarshal with numbers decoded as json.Number.
+func unmarshalNumber(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return fmt.Errorf("invalid character after top-level value")
+	}
+	return nil
+}
+
+// marshal encodes v without escaping HTML characters, which json.Marshal
+// does by default.
+func marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,45 @@
+package patch
+
+import "testing"
+
+func TestApplyBytes(t *testing.T) {
+	cases := []struct{ doc, patch, expected string }{
+		{
+			`{"id": 12345678901234567890, "price": 0.1000000000000000055511151231257827}`,
+			`[{"op": "add", "path": "/big", "value": 98765432109876543210}]`,
+			`{"big":98765432109876543210,"id":12345678901234567890,"price":0.1000000000000000055511151231257827}`,
+		},
+		{
+			`{"a": {"id": 12345678901234567890}, "b": []}`,
+			`[{"op": "copy", "from": "/a/id", "path": "/b/-"}, {"op": "move", "from": "/a", "path": "/c"}]`,
+			`{"b":[12345678901234567890],"c":{"id":12345678901234567890}}`,
+		},
+		{
+			`{"html": "<b>"}`,
+			`[]`,
+			`{"html":"<b>"}`,
+		},
+	}
+	for _, c := range cases {
+		out, err := ApplyBytes([]byte(c.doc), []byte(c.patch))
+		if err != nil {
+			t.Errorf("%s: %v", c.patch, err)
+			continue
+		}
+		if string(out) != c.expected {
+			t.Errorf("expected %s, got %s", c.expected, out)
+		}
+	}
+}
+
+func TestApplyBytesErrors(t *testing.T) {
+	if _, err := ApplyBytes([]byte(`{} {}`), []byte(`[]`)); err == nil {
+		t.Error("expected trailing data in the document to be rejected")
+	}
+	if _, err := ApplyBytes([]byte(`{}`), []byte(`{}`)); err == nil {
+		t.Error("expected a non-array patch to be rejected")
+	}
+	if _, err := ApplyBytes([]byte(`{}`), []byte(`[{"op": "remove", "path": "/x/y"}]`)); err == nil {
+		t.Error("expected a failing patch to return an error")
+	}
+}
@@ -0,0 +1,390 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ApplyCBOR applies operations to a CBOR (RFC 8949) encoded document and
+// returns the CBOR encoded result. The document must hold the JSON data
+// model: maps with text string keys, arrays, text strings, numbers, booleans
+// and null. Byte strings and tags other than bignums (2 and 3) and the
+// self-described CBOR tag (55799) are rejected. Integers, bignums included,
+// keep their exact value; floating point numbers stay floating point.
+//
+// The result uses the deterministic encoding of RFC 8949 section 4.2:
+// shortest lengths and integers, floats in the shortest of 32 or 64 bits
+// that keeps their value, and map keys sorted.
+func ApplyCBOR(doc []byte, operations []Operation, opts ...Option) ([]byte, error) {
+	return applyFormat(cborFormat{}, doc, operations, opts)
+}
+
+// maxDepth bounds the nesting of decoded binary documents, like
+// encoding/json does for JSON.
+const maxDepth = 10000
+
+var errTruncated = errors.New("unexpected end of data")
+
+type cborFormat struct{}
+
+func (cborFormat) decode(data []byte) (interface{}, error) {
+	d := &cborDecoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, fmt.Errorf("cbor: %w at offset %d", err, d.pos)
+	}
+	if d.pos != len(data) {
+		return nil, fmt.Errorf("cbor: extra data at offset %d", d.pos)
+	}
+	return v, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// head reads the initial byte of a data item and its argument. indefinite
+// is set for the indefinite length marker.
+func (d *cborDecoder) head() (major byte, arg uint64, indefinite bool, err error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, false, errTruncated
+	}
+	b := d.data[d.pos]
+	d.pos++
+	major, info := b>>5, b&0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), false, nil
+	case info <= 27:
+		n := 1 << (info - 24)
+		if len(d.data)-d.pos < n {
+			return 0, 0, false, errTruncated
+		}
+		for _, c := range d.data[d.pos : d.pos+n] {
+			arg = arg<<8 | uint64(c)
+		}
+		d.pos += n
+		return major, arg, false, nil
+	case info == 31 && major >= 2 && major <= 5:
+		return major, 0, true, nil
+	case info == 31 && major == 7:
+		return 0, 0, false, errors.New("unexpected break")
+	}
+	return 0, 0, false, fmt.Errorf("invalid additional information %d", info)
+}
+
+// length checks that n items of at least one byte each can follow.
+func (d *cborDecoder) length(n uint64) (int, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return 0, errTruncated
+	}
+	return int(n), nil
+}
+
+// isBreak consumes the break ending an indefinite length item, if it is
+// next.
+func (d *cborDecoder) isBreak() (bool, error) {
+	if d.pos >= len(d.data) {
+		return false, errTruncated
+	}
+	if d.data[d.pos] == 0xff {
+		d.pos++
+		return true, nil
+	}
+	return false, nil
+}
+
+func (d *cborDecoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New("exceeded max depth")
+	}
+	start := d.pos
+	major, arg, indefinite, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case 0:
+		return json.Number(strconv.FormatUint(arg, 10)), nil
+	case 1:
+		n := new(big.Int).SetUint64(arg)
+		return json.Number(n.Not(n).String()), nil
+	case 2:
+		d.pos = start
+		return nil, errors.New("byte strings have no JSON equivalent")
+	case 3:
+		return d.text(arg, indefinite)
+	case 4:
+		s := []interface{}{}
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite {
+				if end, err := d.isBreak(); end || err != nil {
+					return s, err
+				}
+			} else if _, err := d.length(arg - i); err != nil {
+				return nil, err
+			}
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+		}
+		return s, nil
+	case 5:
+		m := make(map[string]interface{})
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite {
+				if end, err := d.isBreak(); end || err != nil {
+					return m, err
+				}
+			} else if _, err := d.length(arg - i); err != nil {
+				return nil, err
+			}
+			keyAt := d.pos
+			k, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				d.pos = keyAt
+				return nil, errors.New("map keys must be text strings")
+			}
+			if m[key], err = d.value(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case 6:
+		return d.tagged(arg, depth)
+	}
+	// major type 7
+	switch {
+	case arg == 20:
+		return false, nil
+	case arg == 21:
+		return true, nil
+	case arg == 22, arg == 23: // null and undefined
+		return nil, nil
+	case d.pos-start == 3:
+		return floatNumber(halfToFloat(uint16(arg))), nil
+	case d.pos-start == 5:
+		return floatNumber(float64(math.Float32frombits(uint32(arg)))), nil
+	case d.pos-start == 9:
+		return floatNumber(math.Float64frombits(arg)), nil
+	}
+	d.pos = start
+	return nil, fmt.Errorf("unsupported simple value %d", arg)
+}
+
+func (d *cborDecoder) text(n uint64, indefinite bool) (string, error) {
+	if indefinite {
+		var b strings.Builder
+		for {
+			if end, err := d.isBreak(); end || err != nil {
+				return b.String(), err
+			}
+			major, n, indefinite, err := d.head()
+			if err != nil {
+				return "", err
+			}
+			if major != 3 || indefinite {
+				return "", errors.New("invalid chunk in indefinite length text string")
+			}
+			s, err := d.text(n, false)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(s)
+		}
+	}
+	l, err := d.length(n)
+	if err != nil {
+		return "", err
+	}
+	b := d.data[d.pos : d.pos+l]
+	if !utf8.Valid(b) {
+		return "", errors.New("invalid UTF-8 in text string")
+	}
+	d.pos += l
+	return string(b), nil
+}
+
+// tagged decodes the content of a tag: bignums become numbers and the
+// self-described CBOR tag is dropped.
+func (d *cborDecoder) tagged(tag uint64, depth int) (interface{}, error) {
+	switch tag {
+	case 55799:
+		return d.value(depth)
+	case 2, 3:
+		major, n, indefinite, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if major != 2 || indefinite {
+			return nil, errors.New("bignum content must be a definite length byte string")
+		}
+		l, err := d.length(n)
+		if err != nil {
+			return nil, err
+		}
+		b := new(big.Int).SetBytes(d.data[d.pos : d.pos+l])
+		d.pos += l
+		if tag == 3 {
+			b.Not(b)
+		}
+		return json.Number(b.String()), nil
+	}
+	return nil, fmt.Errorf("unsupported tag %d", tag)
+}
+
+func halfToFloat(h uint16) float64 {
+	exp, mant := int(h>>10&0x1f), float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		f = math.Inf(1)
+		if mant != 0 {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+func (cborFormat) encode(v interface{}) ([]byte, error) {
+	var e cborEncoder
+	if err := e.value(v); err != nil {
+		return nil, fmt.Errorf("cbor: %w", err)
+	}
+	return e.Bytes(), nil
+}
+
+type cborEncoder struct{ bytes.Buffer }
+
+func (e *cborEncoder) head(major byte, arg uint64) {
+	major <<= 5
+	switch {
+	case arg < 24:
+		e.WriteByte(major | byte(arg))
+	case arg <= math.MaxUint8:
+		e.Write([]byte{major | 24, byte(arg)})
+	case arg <= math.MaxUint16:
+		e.WriteByte(major | 25)
+		e.Write(binary.BigEndian.AppendUint16(nil, uint16(arg)))
+	case arg <= math.MaxUint32:
+		e.WriteByte(major | 26)
+		e.Write(binary.BigEndian.AppendUint32(nil, uint32(arg)))
+	default:
+		e.WriteByte(major | 27)
+		e.Write(binary.BigEndian.AppendUint64(nil, arg))
+	}
+}
+
+func (e *cborEncoder) value(v interface{}) error {
+	v, err := normalize(v)
+	if err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case nil:
+		e.WriteByte(0xf6)
+	case bool:
+		if v {
+			e.WriteByte(0xf5)
+		} else {
+			e.WriteByte(0xf4)
+		}
+	case string:
+		e.head(3, uint64(len(v)))
+		e.WriteString(v)
+	case float64:
+		e.float(v)
+	case json.Number:
+		return e.number(v)
+	case []interface{}:
+		e.head(4, uint64(len(v)))
+		for _, x := range v {
+			if err := e.value(x); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		// encoded text string keys sort bytewise by length first
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.SortFunc(keys, func(a, b string) int {
+			if len(a) != len(b) {
+				return len(a) - len(b)
+			}
+			return strings.Compare(a, b)
+		})
+		e.head(5, uint64(len(v)))
+		for _, k := range keys {
+			e.head(3, uint64(len(k)))
+			e.WriteString(k)
+			if err := e.value(v[k]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *cborEncoder) number(n json.Number) error {
+	s := n.String()
+	if strings.ContainsAny(s, ".eE") {
+		f, err := n.Float64()
+		if err != nil {
+			return err
+		}
+		e.float(f)
+		return nil
+	}
+	b, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return fmt.Errorf("invalid number %q", s)
+	}
+	major, tag := byte(0), uint64(2)
+	if b.Sign() < 0 {
+		major, tag = 1, 3
+		b.Not(b)
+	}
+	if b.IsUint64() {
+		e.head(major, b.Uint64())
+		return nil
+	}
+	e.head(6, tag)
+	e.head(2, uint64(len(b.Bytes())))
+	e.Write(b.Bytes())
+	return nil
+}
+
+func (e *cborEncoder) float(f float64) {
+	if f32 := float32(f); float64(f32) == f {
+		e.WriteByte(0xfa)
+		e.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(f32)))
+		return
+	}
+	e.WriteByte(0xfb)
+	e.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+}
@@ -0,0 +1,148 @@
+package patch
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCBORDecode(t *testing.T) {
+	// examples from RFC 8949 appendix A
+	cases := []struct{ cbor, json string }{
+		{"00", `0`},
+		{"17", `23`},
+		{"1818", `24`},
+		{"1b ffffffffffffffff", `18446744073709551615`},
+		{"c2 49 010000000000000000", `18446744073709551616`},
+		{"3b ffffffffffffffff", `-18446744073709551616`},
+		{"c3 49 010000000000000000", `-18446744073709551617`},
+		{"29", `-10`},
+		{"f9 3c00", `1.0`},
+		{"f9 3e00", `1.5`},
+		{"f9 0001", `5.960464477539063e-08`},
+		{"fa 47c35000", `100000.0`},
+		{"fb 3ff199999999999a", `1.1`},
+		{"fb 7e37e43c8800759c", `1e+300`},
+		{"f4", `false`},
+		{"f6", `null`},
+		{"62 225c", `"\"\\"`},
+		{"63 e6b0b4", `"水"`},
+		{"83 01 82 02 03 82 04 05", `[1, [2, 3], [4, 5]]`},
+		{"a2 61 61 01 61 62 82 02 03", `{"a": 1, "b": [2, 3]}`},
+		{"7f 65 7374726561 64 6d696e67 ff", `"streaming"`},
+		{"9f 01 82 02 03 9f 04 05 ff ff", `[1, [2, 3], [4, 5]]`},
+		{"bf 61 61 01 61 62 9f 02 03 ff ff", `{"a": 1, "b": [2, 3]}`},
+		{"d9d9f7 80", `[]`},
+	}
+	for _, c := range cases {
+		v, err := cborFormat{}.decode(unhex(t, c.cbor))
+		if err != nil {
+			t.Errorf("%s: %v", c.cbor, err)
+			continue
+		}
+		var expected interface{}
+		if err := unmarshalNumber([]byte(c.json), &expected); err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := marshal(v); string(b) != string(mustMarshal(t, expected)) {
+			t.Errorf("%s: expected %s, got %s", c.cbor, mustMarshal(t, expected), b)
+		}
+	}
+
+	for _, bad := range []string{
+		"", "18", "62 61", "42 0102", "c1 00", "a1 01 02", "f7 00", "ff", "9f 01", "62 ff fe", "00 00",
+	} {
+		if _, err := (cborFormat{}).decode(unhex(t, bad)); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	b, err := marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCBOREncode(t *testing.T) {
+	cases := []struct{ json, cbor string }{
+		{`0`, "00"},
+		{`-1`, "20"},
+		{`1000000`, "1a 000f4240"},
+		{`18446744073709551616`, "c2 49 010000000000000000"},
+		{`-18446744073709551617`, "c3 49 010000000000000000"},
+		{`1.5`, "fa 3fc00000"},
+		{`1.1`, "fb 3ff199999999999a"},
+		{`1e2`, "fa 42c80000"},
+		{`"a"`, "61 61"},
+		{`[true, null]`, "82 f5 f6"},
+		{`{"bb": 1, "c": 2, "a": 3}`, "a3 61 61 03 61 63 02 62 6262 01"},
+	}
+	for _, c := range cases {
+		var v interface{}
+		if err := unmarshalNumber([]byte(c.json), &v); err != nil {
+			t.Fatal(err)
+		}
+		b, err := cborFormat{}.encode(v)
+		if err != nil {
+			t.Errorf("%s: %v", c.json, err)
+			continue
+		}
+		if expected := unhex(t, c.cbor); string(b) != string(expected) {
+			t.Errorf("%s: expected %x, got %x", c.json, expected, b)
+		}
+	}
+	// values of other types go through encoding/json
+	b, err := cborFormat{}.encode(struct {
+		A int `json:"a"`
+	}{1})
+	if err != nil || string(b) != string(unhex(t, "a1 61 61 01")) {
+		t.Errorf("struct: %x %v", b, err)
+	}
+}
+
+func TestApplyCBOR(t *testing.T) {
+	// {"n": 18446744073709551616, "f": 1.0, "list": [1, 2]}
+	doc := unhex(t, "a3 61 6e c2 49 010000000000000000 61 66 f9 3c00 64 6c697374 82 01 02")
+	ops := parseStr(`[
+		{"op": "test", "path": "/n", "value": 18446744073709551616},
+		{"op": "add", "path": "/list/-", "value": 3},
+		{"op": "copy", "from": "/f", "path": "/g"}
+	]`)
+	result, err := ApplyCBOR(doc, ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := cborFormat{}.decode(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"f":1.0,"g":1.0,"list":[1,2,3],"n":18446744073709551616}`
+	if b, _ := marshal(v); string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+	// the float stays a float
+	if v.(map[string]interface{})["g"] != json.Number("1.0") {
+		t.Errorf("expected a float, got %#v", v.(map[string]interface{})["g"])
+	}
+
+	if _, err := ApplyCBOR(doc, parseStr(`[{"op": "test", "path": "/f", "value": 2}]`)); err == nil {
+		t.Error("expected the test to fail")
+	}
+	if _, err := ApplyCBOR(unhex(t, "41 00"), nil); err == nil {
+		t.Error("expected byte strings to be rejected")
+	}
+}
@@ -0,0 +1,174 @@
+package patch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// AnnotatedPatch is a patch together with information about when and why
+// it was applied.
+type AnnotatedPatch struct {
+	Patch   []Operation
+	Author  string
+	Time    time.Time
+	Message string
+}
+
+// ChangelogRule declares a kind of item found in a document: every member
+// (or element) directly under Prefix is one item. Prefix tokens may be "*"
+// to match any token, so "/services/*/endpoints" groups the endpoints of
+// every service.
+type ChangelogRule struct {
+	Prefix string
+	Noun   string // the item kind, such as "feature flag"
+	Plural string // defaults to Noun followed by "s"
+}
+
+// ChangelogEntry summarizes what happened to the items of one rule.
+type ChangelogEntry struct {
+	Rule  ChangelogRule
+	Verb  string   // "Added", "Changed" or "Removed"
+	Items []string // pointers to the items, in the order first modified
+}
+
+// String returns the entry as in "Changed 12 feature flags".
+func (e ChangelogEntry) String() string {
+	noun := e.Rule.Noun
+	if len(e.Items) != 1 {
+		noun = e.Rule.Plural
+		if noun == "" {
+			noun = e.Rule.Noun + "s"
+		}
+	}
+	return e.Verb + " " + strconv.Itoa(len(e.Items)) + " " + noun
+}
+
+// FormatChangelog joins entries into a single line such as
+// "Changed 12 feature flags; Added 2 endpoints".
+func FormatChangelog(entries []ChangelogEntry) string {
+	parts := make([]string, len(entries))
+	for i, e := range entries {
+		parts[i] = e.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Changelog summarizes the net effect of a history of patches, oldest first,
+// on the items declared by rules. Each modified pointer is attributed to the
+// rule with the longest matching prefix; pointers matching no rule are left
+// out, so a rule with an empty prefix collects everything else. An item that
+// is added and later removed does not appear at all, an item that is added
+// and then changed is only reported as added, and an item modified many
+// times is counted once. Entries are ordered by rule, then as added, changed
+// and removed.
+func Changelog(history []AnnotatedPatch, rules []ChangelogRule) ([]ChangelogEntry, error) {
+	patterns := make([]pointer.Pointer, len(rules))
+	for i, r := range rules {
+		p, err := pointer.Parse(r.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Prefix, err)
+		}
+		patterns[i] = p
+	}
+
+	type item struct {
+		rule    int
+		ptr     string
+		added   bool // the first change to the item created it
+		removed bool // the last change to the item removed it
+	}
+	var items []*item
+	byKey := make(map[string]*item)
+	appends := 0
+	touch := func(ptr string, kind ChangeKind) error {
+		p, err := pointer.Parse(ptr)
+		if err != nil {
+			return err
+		}
+		rule, depth := -1, -1
+		for i, pattern := range patterns {
+			if len(pattern) > depth && matchPrefix(pattern, p) {
+				rule, depth = i, len(pattern)
+			}
+		}
+		if rule < 0 {
+			return nil
+		}
+		whole := len(p) <= depth+1
+		if !whole {
+			p = p[:depth+1]
+		}
+		key := p.String()
+		if len(p) > depth && p[depth] == "-" {
+			// every append creates a distinct item
+			appends++
+			key += "#" + strconv.Itoa(appends)
+		}
+		it, ok := byKey[key]
+		if !ok {
+			it = &item{rule: rule, ptr: p.String(), added: whole && kind == ChangeAdded}
+			byKey[key] = it
+			items = append(items, it)
+		}
+		if whole {
+			switch kind {
+			case ChangeAdded:
+				it.removed = false
+			case ChangeRemoved:
+				it.removed = true
+			}
+		}
+		return nil
+	}
+
+	for _, h := range history {
+		for _, op := range h.Patch {
+			var err error
+			switch op.Op {
+			case "test":
+			case "add", "copy":
+				err = touch(op.Path, ChangeAdded)
+			case "remove":
+				err = touch(op.Path, ChangeRemoved)
+			case "move":
+				if err = touch(op.From, ChangeRemoved); err == nil {
+					err = touch(op.Path, ChangeAdded)
+				}
+			default:
+				err = touch(op.Path, ChangeReplaced)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", op.Op, op.Path, err)
+			}
+		}
+	}
+
+	var entries []ChangelogEntry
+	for r, rule := range rules {
+		for _, verb := range []string{"Added", "Changed", "Removed"} {
+			e := ChangelogEntry{Rule: rule, Verb: verb}
+			for _, it := range items {
+				if it.rule != r || it.added && it.removed {
+					continue
+				}
+				v := "Changed"
+				if it.added {
+					v = "Added"
+				} else if it.removed {
+					v = "Removed"
+				}
+				if v == verb {
+					e.Items = append(e.Items, it.ptr)
+				}
+			}
+			if len(e.Items) > 0 {
+				entries = append(entries, e)
+			}
+		}
+	}
+	return entries, nil
+}
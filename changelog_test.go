@@ -0,0 +1,63 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangelog(t *testing.T) {
+	history := []AnnotatedPatch{
+		{Author: "ann", Patch: parseStr(`[
+			{"op": "replace", "path": "/flags/dark-mode", "value": true},
+			{"op": "replace", "path": "/flags/beta/enabled", "value": false},
+			{"op": "add", "path": "/services/api/endpoints/-", "value": "/v2"},
+			{"op": "add", "path": "/flags/tmp", "value": true},
+			{"op": "test", "path": "/flags/old", "value": true}
+		]`)},
+		{Author: "bob", Patch: parseStr(`[
+			{"op": "replace", "path": "/flags/dark-mode", "value": false},
+			{"op": "remove", "path": "/flags/tmp"},
+			{"op": "remove", "path": "/flags/old"},
+			{"op": "add", "path": "/flags/new", "value": true},
+			{"op": "replace", "path": "/flags/new", "value": false},
+			{"op": "add", "path": "/services/web/endpoints/-", "value": "/"},
+			{"op": "replace", "path": "/title", "value": "x"}
+		]`)},
+	}
+	rules := []ChangelogRule{
+		{Prefix: "/flags", Noun: "feature flag"},
+		{Prefix: "/services/*/endpoints", Noun: "endpoint"},
+	}
+	entries, err := Changelog(history, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ChangelogEntry{
+		{Rule: rules[0], Verb: "Added", Items: []string{"/flags/new"}},
+		{Rule: rules[0], Verb: "Changed", Items: []string{"/flags/dark-mode", "/flags/beta"}},
+		{Rule: rules[0], Verb: "Removed", Items: []string{"/flags/old"}},
+		{Rule: rules[1], Verb: "Added", Items: []string{"/services/api/endpoints/-", "/services/web/endpoints/-"}},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected\n%v\ngot\n%v", expected, entries)
+	}
+	summary := "Added 1 feature flag; Changed 2 feature flags; Removed 1 feature flag; Added 2 endpoints"
+	if s := FormatChangelog(entries); s != summary {
+		t.Errorf("expected %q, got %q", summary, s)
+	}
+}
+
+func TestChangelogCatchAll(t *testing.T) {
+	history := []AnnotatedPatch{{Patch: parseStr(`[
+		{"op": "move", "from": "/a", "path": "/b"},
+		{"op": "replace", "path": "/flags/x", "value": 1}
+	]`)}}
+	rules := []ChangelogRule{{Prefix: "/flags", Noun: "flag"}, {Prefix: "", Noun: "setting"}}
+	entries, err := Changelog(history, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := FormatChangelog(entries); s != "Changed 1 flag; Added 1 setting; Removed 1 setting" {
+		t.Errorf("unexpected changelog %q", s)
+	}
+}
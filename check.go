@@ -0,0 +1,149 @@
+package patch
+
+import (
+	"errors"
+	"slices"
+)
+
+// Check reports whether operations would apply to doc, returning the error
+// Apply would return, without modifying doc or building a result document.
+// Only the objects and arrays along the paths written by each operation are
+// copied, so checking a small patch against a large document is cheap. It
+// suits admission control, where a patch is vetted before it is committed.
+func Check(doc interface{}, operations []Operation, opts ...Option) error {
+	options := newOptions(opts)
+	options.ContinueOnError = false
+	a := &applier{opts: options, dry: true, shared: true}
+	_, err := a.apply(doc, operations)
+	return err
+}
+
+// CheckAll is like Check but keeps going after an operation fails, as if
+// that operation had been left out of the patch, and returns the errors of
+// every failing operation joined with errors.Join.
+func CheckAll(doc interface{}, operations []Operation, opts ...Option) error {
+	options := newOptions(opts)
+	options.ContinueOnError = true
+	a := &applier{opts: options, dry: true, shared: true}
+	_, err := a.apply(doc, operations)
+	return err
+}
+
+// applyEach applies operations one at a time, copying the containers each
+// one writes to first, so that a failing operation leaves the document as
+// it was before it. With Options.ContinueOnError the failing operations are
+// skipped and their errors joined; otherwise the first error is returned.
+func (a *applier) applyEach(o interface{}, operations []Operation) (interface{}, error) {
+	var errs []error
+	for i, op := range operations {
+		if err := a.interrupted(i); err != nil {
+			return nil, err
+		}
+		changes := 0
+		if a.report != nil {
+			changes = len(a.report.Changes)
+		}
+		ins, err := a.instruction(i, op)
+		if err == nil {
+			next := copyPath(o, ins.path)
+			if op.Op == "move" {
+				next = copyPath(next, ins.from)
+			}
+			if next, err = a.exec(next, i, ins); err == nil {
+				o = next
+				continue
+			}
+			if a.opts.OnErrorHints {
+				if next, err = a.recoverOp(o, i, op, err, changes); err == nil {
+					o = next
+					continue
+				}
+				if op.OnError == "abort" {
+					return nil, err
+				}
+			}
+		}
+		if !a.opts.ContinueOnError {
+			return nil, err
+		}
+		if a.report != nil {
+			a.report.Changes = a.report.Changes[:changes]
+		}
+		errs = append(errs, err)
+	}
+	return o, errors.Join(errs...)
+}
+
+// isolate returns o with the containers along the paths of ins copied,
+// like applyEach does before executing an instruction, when the document
+// must not be modified in place. It is needed when the paths of ins were
+// resolved after applyEach made its copies.
+func (a *applier) isolate(o interface{}, ins *instruction) interface{} {
+	if !a.copies() {
+		return o
+	}
+	o = copyPath(o, ins.path)
+	if ins.op.Op == "move" {
+		o = copyPath(o, ins.from)
+	}
+	return o
+}
+
+// copies reports whether operations copy the containers they write to
+// rather than modifying the document in place.
+func (a *applier) copies() bool {
+	return a.shared || a.opts.ContinueOnError || a.opts.OnErrorHints || a.groups != nil
+}
+
+// copyPath returns root with the containers holding the value at path
+// replaced by shallow copies, so that the value can be added, replaced or
+// removed without modifying root. It stops at the first token that does not
+// resolve.
+func copyPath(root interface{}, path []string) interface{} {
+	root = shallowCopy(root)
+	current := root
+	for i := 0; i < len(path)-1; i++ {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			child, ok := v[path[i]]
+			if !ok {
+				return root
+			}
+			current = shallowCopy(child)
+			v[path[i]] = current
+		case *SortedObject:
+			child, ok := v.Get(path[i])
+			if !ok {
+				return root
+			}
+			current = shallowCopy(child)
+			v.Set(path[i], current)
+		case []interface{}:
+			j, err := elementIndex(v, path[i], false)
+			if err != nil {
+				return root
+			}
+			current = shallowCopy(v[j])
+			v[j] = current
+		default:
+			return root
+		}
+	}
+	return root
+}
+
+func shallowCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, x := range v {
+			out[k] = x
+		}
+		return out
+	case *SortedObject:
+		return v.clone()
+	case []interface{}:
+		return slices.Clone(v)
+	}
+	return v
+}
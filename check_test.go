@@ -0,0 +1,121 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	original := `{"a": {"b": [1, 2, 3]}, "c": {"d": "x"}, "e": [{"f": 1}]}`
+	doc := decode(original)
+	ok := parseStr(`[
+		{"op": "add", "path": "/a/b/-", "value": 4},
+		{"op": "remove", "path": "/a/b/0"},
+		{"op": "move", "from": "/c/d", "path": "/a/d"},
+		{"op": "test", "path": "/a/d", "value": "x"},
+		{"op": "replace", "path": "/e/0/f", "value": 2},
+		{"op": "copy", "from": "/e", "path": "/c/e"},
+		{"op": "add", "path": "/c/e/0/g", "value": 3},
+		{"op": "test", "path": "/e/0", "value": {"f": 2}},
+		{"op": "test", "path": "/a/b", "value": [2, 3, 4]}
+	]`)
+	if err := Check(doc, ok); err != nil {
+		t.Errorf("expected the patch to apply, got %v", err)
+	}
+	if !reflect.DeepEqual(doc, decode(original)) {
+		t.Errorf("Check modified the document: %v", doc)
+	}
+
+	bad := parseStr(`[
+		{"op": "remove", "path": "/c/d"},
+		{"op": "test", "path": "/c/d", "value": "x"},
+		{"op": "bogus", "path": "/x"},
+		{"op": "replace", "path": "/a/b/9", "value": 1},
+		{"op": "move", "from": "/a/b", "path": "/a/b/x"}
+	]`)
+	err := Check(doc, bad)
+	var pe *PathError
+	if !errors.As(err, &pe) || pe.Index != 1 {
+		t.Errorf("expected operation 1 to fail, got %v", err)
+	}
+
+	err = CheckAll(doc, bad)
+	joined, isJoined := err.(interface{ Unwrap() []error })
+	if !isJoined || len(joined.Unwrap()) != 4 {
+		t.Fatalf("expected 4 errors, got %v", err)
+	}
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("unexpected errors %v", err)
+	}
+	if !reflect.DeepEqual(doc, decode(original)) {
+		t.Errorf("CheckAll modified the document: %v", doc)
+	}
+}
+
+func TestCheckArrayMoves(t *testing.T) {
+	// removing the source shifts the destination into containers that
+	// were not copied before the move
+	for _, tc := range []struct{ doc, ops string }{
+		{`["d", {}, {}]`, `[{"op": "move", "from": "/0", "path": "/1/a"}]`},
+		{`["d", [[0]], [[1]]]`, `[{"op": "move", "from": "/0", "path": "/1/0/-"}]`},
+		{`["d", {}, {}, {}]`, `[{"op": "move", "from": "/0", "path": "/1/a"}, {"op": "move", "from": "/0", "path": "/1/b"}]`},
+	} {
+		doc := decode(tc.doc)
+		if err := Check(doc, parseStr(tc.ops)); err != nil {
+			t.Errorf("%s: expected the patch to apply, got %v", tc.ops, err)
+		}
+		if err := CheckAll(doc, parseStr(tc.ops)); err != nil {
+			t.Errorf("%s: expected the patch to apply, got %v", tc.ops, err)
+		}
+		if !reflect.DeepEqual(doc, decode(tc.doc)) {
+			t.Errorf("%s: Check modified the document: %v", tc.ops, doc)
+		}
+	}
+}
+
+func TestContinueOnError(t *testing.T) {
+	doc := decode(`{"a": 1, "list": [1, 2]}`)
+	ops := parseStr(`[
+		{"op": "add", "path": "/b", "value": 2},
+		{"op": "remove", "path": "/missing"},
+		{"op": "move", "from": "/list/0", "path": "/nowhere/x"},
+		{"op": "replace", "path": "/a", "value": 3},
+		{"op": "test", "path": "/a", "value": 1}
+	]`)
+	result, err := Apply(doc, ops, WithContinueOnError())
+	expected := decode(`{"a": 3, "b": 2, "list": [1, 2]}`)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("expected joined errors, got %v", err)
+	}
+	var indexes []int
+	for _, e := range joined.Unwrap() {
+		var pe *PathError
+		var te *TestFailedError
+		switch {
+		case errors.As(e, &pe):
+			indexes = append(indexes, pe.Index)
+		case errors.As(e, &te):
+			indexes = append(indexes, te.Index)
+		}
+	}
+	if !reflect.DeepEqual(indexes, []int{1, 2, 4}) {
+		t.Errorf("expected operations 1, 2 and 4 to fail, got %v (%v)", indexes, err)
+	}
+
+	result, report, err := ApplyWithReport(doc, ops, WithContinueOnError())
+	if err == nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("ApplyWithReport: %v %v", result, err)
+	}
+	if touched := report.Touched(); !reflect.DeepEqual(touched, []string{"/b", "/a"}) {
+		t.Errorf("expected failed operations to be left out of the report, got %v", touched)
+	}
+
+	if result, err := Apply(doc, ops[:1], WithContinueOnError()); err != nil || result == nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
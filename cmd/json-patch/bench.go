@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"time"
+
+	patch "github.com/grncdr/json-patch"
+)
+
+// benchResult is the outcome of the bench command, as printed with
+// -output json. Durations are in nanoseconds.
+type benchResult struct {
+	Iterations  int   `json:"iterations"`
+	Min         int64 `json:"minNs"`
+	Mean        int64 `json:"meanNs"`
+	P50         int64 `json:"p50Ns"`
+	P90         int64 `json:"p90Ns"`
+	P99         int64 `json:"p99Ns"`
+	Max         int64 `json:"maxNs"`
+	AllocsPerOp int64 `json:"allocsPerOp"`
+	BytesPerOp  int64 `json:"bytesPerOp"`
+}
+
+// bench applies a patch to a document repeatedly and reports the latency
+// percentiles and allocations of a single application.
+func (c *cli) bench(args []string) int {
+	fs := c.flags("bench")
+	docFile := fs.String("doc", "", "benchmark against the document in `FILE`")
+	patchFile := fs.String("patch", "", "benchmark the patch in `FILE`")
+	iterations := fs.Int("iterations", 1000, "apply the patch `N` times")
+	output := fs.String("output", "text", "print the results as `text` or json")
+	cpuProfile := fs.String("cpuprofile", "", "write a CPU profile to `FILE`")
+	memProfile := fs.String("memprofile", "", "write an allocation profile to `FILE`")
+	if fs.Parse(args) != nil || fs.NArg() > 0 || *patchFile == "" || *iterations < 1 ||
+		*output != "text" && *output != "json" {
+		fs.Usage()
+		return 2
+	}
+	data, err := c.read(*patchFile)
+	if err != nil {
+		return c.fail(err)
+	}
+	ops, err := patch.Parse(data)
+	if err != nil {
+		return c.fail(err)
+	}
+	if data, err = c.read(*docFile); err != nil {
+		return c.fail(err)
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var doc interface{}
+	if err := d.Decode(&doc); err != nil {
+		return c.fail(err)
+	}
+	opts := []patch.Option{patch.WithUseNumber()}
+	// a patch that does not apply would only measure how fast it fails
+	if _, err := patch.Apply(doc, ops, opts...); err != nil {
+		return c.fail(err)
+	}
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return c.fail(err)
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return c.fail(err)
+		}
+	}
+	if *memProfile != "" {
+		runtime.MemProfileRate = 1
+	}
+	times := make([]time.Duration, *iterations)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for i := range times {
+		start := time.Now()
+		patch.Apply(doc, ops, opts...)
+		times[i] = time.Since(start)
+	}
+	runtime.ReadMemStats(&after)
+	if *cpuProfile != "" {
+		pprof.StopCPUProfile()
+	}
+	if *memProfile != "" {
+		f, err := os.Create(*memProfile)
+		if err != nil {
+			return c.fail(err)
+		}
+		defer f.Close()
+		if err := pprof.Lookup("allocs").WriteTo(f, 0); err != nil {
+			return c.fail(err)
+		}
+	}
+
+	n := int64(len(times))
+	var total time.Duration
+	for _, t := range times {
+		total += t
+	}
+	slices.Sort(times)
+	percentile := func(p int) int64 {
+		return int64(times[(len(times)-1)*p/100])
+	}
+	r := benchResult{
+		Iterations:  len(times),
+		Min:         int64(times[0]),
+		Mean:        int64(total) / n,
+		P50:         percentile(50),
+		P90:         percentile(90),
+		P99:         percentile(99),
+		Max:         int64(times[len(times)-1]),
+		AllocsPerOp: int64(after.Mallocs-before.Mallocs) / n,
+		BytesPerOp:  int64(after.TotalAlloc-before.TotalAlloc) / n,
+	}
+	if *output == "json" {
+		return c.write("-", encode(r))
+	}
+	fmt.Fprintf(c.stdout, "iterations %d\n", r.Iterations)
+	for _, l := range []struct {
+		name string
+		ns   int64
+	}{{"min", r.Min}, {"mean", r.Mean}, {"p50", r.P50}, {"p90", r.P90}, {"p99", r.P99}, {"max", r.Max}} {
+		fmt.Fprintf(c.stdout, "%-10s %v\n", l.name, time.Duration(l.ns))
+	}
+	fmt.Fprintf(c.stdout, "allocs/op  %d\nbytes/op   %d\n", r.AllocsPerOp, r.BytesPerOp)
+	return 0
+}
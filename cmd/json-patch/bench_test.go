@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBench(t *testing.T) {
+	dir := t.TempDir()
+	p := writeFile(t, dir, "patch.json", `[{"op": "add", "path": "/b", "value": [1, 2]}, {"op": "remove", "path": "/a"}]`)
+	doc := writeFile(t, dir, "doc.json", `{"a": 1}`)
+
+	code, out, errOut := runCLI("", "bench", "--doc", doc, "--patch", p, "--iterations", "50")
+	if code != 0 || !strings.HasPrefix(out, "iterations 50\nmin") || !strings.Contains(out, "\np99 ") || !strings.Contains(out, "allocs/op") {
+		t.Errorf("unexpected output %d %q %q", code, out, errOut)
+	}
+
+	cpu, mem := filepath.Join(dir, "cpu.pprof"), filepath.Join(dir, "mem.pprof")
+	code, out, errOut = runCLI(`{"a": 1}`, "bench", "-patch", p, "-iterations", "20", "-output", "json", "-cpuprofile", cpu, "-memprofile", mem)
+	var r benchResult
+	if code != 0 || json.Unmarshal([]byte(out), &r) != nil || r.Iterations != 20 || r.Min > r.P50 || r.P50 > r.Max || r.AllocsPerOp == 0 {
+		t.Errorf("unexpected output %d %q %q", code, out, errOut)
+	}
+	for _, f := range []string{cpu, mem} {
+		if info, err := os.Stat(f); err != nil || info.Size() == 0 {
+			t.Errorf("expected a profile in %s, got %v", f, err)
+		}
+	}
+
+	if code, _, _ := runCLI("", "bench", "-doc", doc); code != 2 {
+		t.Errorf("expected a missing patch to be a usage error, got %d", code)
+	}
+	failing := writeFile(t, dir, "failing.json", `[{"op": "remove", "path": "/missing"}]`)
+	if code, _, errOut := runCLI("", "bench", "-doc", doc, "-patch", failing); code != 1 || !strings.Contains(errOut, "missing") {
+		t.Errorf("expected a patch that does not apply to fail, got %d %q", code, errOut)
+	}
+}
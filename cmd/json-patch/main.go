@@ -0,0 +1,338 @@
+// Command json-patch applies and creates JSON patches (RFC 6902).
+//
+// Usage:
+//
+//	json-patch apply [-o FILE] [-lines] PATCH [DOC]
+//	json-patch apply -dry-run [-output text|json] PATCH [DOC]
+//	json-patch diff [-o FILE] ORIGINAL MODIFIED
+//	json-patch test PATCH [DOC]
+//	json-patch repl [DOC]
+//	json-patch bench [-iterations N] [-output text|json] [-cpuprofile FILE] [-memprofile FILE] -patch PATCH [-doc DOC]
+//
+// A file name of "-", or a missing DOC, reads standard input. The test
+// command prints nothing and exits with status 1 when the patch does not
+// apply cleanly. With -lines, apply reads DOC as JSON Lines and patches every
+// record, writing the results as they are produced. With -dry-run, apply
+// writes nothing but prints the plan: every pointer whose value the patch
+// would change, with its value before and after, one per line or, with
+// -output json, as a JSON object for scripts to check. The repl command
+// starts an interactive session on DOC, or on a null document, reading
+// commands from standard input; type help for a list. The bench command
+// applies PATCH to DOC N times, 1000 by default, and prints the latency
+// percentiles and the allocations of one application, optionally writing
+// CPU and allocation profiles for go tool pprof. Usage errors exit with
+// status 2.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	patch "github.com/grncdr/json-patch"
+	"github.com/grncdr/json-patch/pointer"
+)
+
+const usage = `usage:
+  json-patch apply [-o FILE] [-lines] PATCH [DOC]
+  json-patch apply -dry-run [-output text|json] PATCH [DOC]
+  json-patch diff [-o FILE] ORIGINAL MODIFIED
+  json-patch test PATCH [DOC]
+  json-patch repl [DOC]
+  json-patch bench [-iterations N] [-output text|json] [-cpuprofile FILE] [-memprofile FILE] -patch PATCH [-doc DOC]
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// cli holds the standard streams a command reads and writes.
+type cli struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	var cmd func([]string) int
+	switch args[0] {
+	case "apply":
+		cmd = c.apply
+	case "diff":
+		cmd = c.diff
+	case "test":
+		cmd = c.test
+	case "repl":
+		cmd = c.repl
+	case "bench":
+		cmd = c.bench
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "json-patch: unknown command %q\n%s", args[0], usage)
+		return 2
+	}
+	return cmd(args[1:])
+}
+
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() { fmt.Fprint(c.stderr, usage) }
+	return fs
+}
+
+func (c *cli) apply(args []string) int {
+	fs := c.flags("apply")
+	out := fs.String("o", "-", "write the result to `FILE`")
+	lines := fs.Bool("lines", false, "patch every record of a JSON Lines document")
+	dryRun := fs.Bool("dry-run", false, "print the changes instead of applying them")
+	output := fs.String("output", "text", "print the changes as `text` or json")
+	if fs.Parse(args) != nil || fs.NArg() < 1 || fs.NArg() > 2 {
+		return 2
+	}
+	if *dryRun {
+		if *lines || *output != "text" && *output != "json" {
+			fs.Usage()
+			return 2
+		}
+		return c.plan(*output, fs.Arg(0), fs.Arg(1))
+	}
+	if *lines {
+		return c.applyLines(*out, fs.Arg(0), fs.Arg(1))
+	}
+	result, err := c.patch(fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.write(*out, result)
+}
+
+func (c *cli) diff(args []string) int {
+	fs := c.flags("diff")
+	out := fs.String("o", "-", "write the patch to `FILE`")
+	if fs.Parse(args) != nil || fs.NArg() != 2 {
+		return 2
+	}
+	original, err := c.read(fs.Arg(0))
+	if err != nil {
+		return c.fail(err)
+	}
+	modified, err := c.read(fs.Arg(1))
+	if err != nil {
+		return c.fail(err)
+	}
+	p, err := patch.CreatePatchBytes(original, modified)
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.write(*out, p)
+}
+
+func (c *cli) test(args []string) int {
+	fs := c.flags("test")
+	if fs.Parse(args) != nil || fs.NArg() < 1 || fs.NArg() > 2 {
+		return 2
+	}
+	if _, err := c.patch(fs.Arg(0), fs.Arg(1)); err != nil {
+		return c.fail(err)
+	}
+	return 0
+}
+
+func (c *cli) repl(args []string) int {
+	fs := c.flags("repl")
+	if fs.Parse(args) != nil || fs.NArg() > 1 {
+		return 2
+	}
+	var doc interface{}
+	if fs.NArg() == 1 {
+		data, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			return c.fail(err)
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return c.fail(err)
+		}
+	}
+	if err := patch.NewRepl(doc).Run(c.stdin, c.stdout); err != nil {
+		return c.fail(err)
+	}
+	return 0
+}
+
+// applyLines applies the patch in file p to every record of the JSON Lines
+// document in file doc, writing the results to the file out.
+func (c *cli) applyLines(out, p, doc string) int {
+	data, err := c.read(p)
+	if err != nil {
+		return c.fail(err)
+	}
+	ops, err := patch.Parse(data)
+	if err != nil {
+		return c.fail(err)
+	}
+	r := c.stdin
+	if doc != "" && doc != "-" {
+		f, err := os.Open(doc)
+		if err != nil {
+			return c.fail(err)
+		}
+		defer f.Close()
+		r = f
+	}
+	w := c.stdout
+	if out != "-" {
+		f, err := os.Create(out)
+		if err != nil {
+			return c.fail(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := patch.ApplyNDJSON(r, w, func(interface{}) []patch.Operation { return ops }); err != nil {
+		return c.fail(err)
+	}
+	return 0
+}
+
+// change is a pointer whose value a patch changes, as printed by
+// apply -dry-run. Before or After is missing when the value is added or
+// removed.
+type change struct {
+	Kind   patch.ChangeKind `json:"kind"`
+	Path   string           `json:"path"`
+	Before json.RawMessage  `json:"before,omitempty"`
+	After  json.RawMessage  `json:"after,omitempty"`
+}
+
+// plan prints the changes the patch in file p makes to the document in file
+// doc, in the given output format.
+func (c *cli) plan(output, p, doc string) int {
+	data, err := c.read(p)
+	if err != nil {
+		return c.fail(err)
+	}
+	ops, err := patch.Parse(data)
+	if err != nil {
+		return c.fail(err)
+	}
+	if data, err = c.read(doc); err != nil {
+		return c.fail(err)
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var original interface{}
+	if err := d.Decode(&original); err != nil {
+		return c.fail(err)
+	}
+	result, report, err := patch.ApplyWithReport(original, ops, patch.WithUseNumber())
+	if err != nil {
+		return c.fail(err)
+	}
+	// compare the document before and after the whole patch at each
+	// pointer an operation modified
+	changes := []change{}
+	for _, path := range report.Touched() {
+		ptr, err := pointer.Parse(path)
+		if err != nil {
+			return c.fail(err)
+		}
+		ch := change{Path: path}
+		before, errBefore := ptr.Get(original)
+		after, errAfter := ptr.Get(result)
+		switch {
+		case errBefore != nil && errAfter != nil:
+			continue
+		case errBefore != nil:
+			ch.Kind = patch.ChangeAdded
+		case errAfter != nil:
+			ch.Kind = patch.ChangeRemoved
+		default:
+			ch.Kind = patch.ChangeReplaced
+		}
+		if errBefore == nil {
+			ch.Before = encode(before)
+		}
+		if errAfter == nil {
+			ch.After = encode(after)
+		}
+		if bytes.Equal(ch.Before, ch.After) {
+			continue
+		}
+		changes = append(changes, ch)
+	}
+	if output == "json" {
+		return c.write("-", encode(map[string][]change{"changes": changes}))
+	}
+	for _, ch := range changes {
+		switch {
+		case ch.Before == nil:
+			fmt.Fprintf(c.stdout, "%s %s: %s\n", ch.Kind, ch.Path, ch.After)
+		case ch.After == nil:
+			fmt.Fprintf(c.stdout, "%s %s: %s\n", ch.Kind, ch.Path, ch.Before)
+		default:
+			fmt.Fprintf(c.stdout, "%s %s: %s -> %s\n", ch.Kind, ch.Path, ch.Before, ch.After)
+		}
+	}
+	return 0
+}
+
+// encode returns v as JSON, without escaping HTML characters. Object
+// members are sorted, so equal values have equal encodings.
+func encode(v interface{}) json.RawMessage {
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	e.Encode(v)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// patch applies the patch in file p to the document in file doc.
+func (c *cli) patch(p, doc string) ([]byte, error) {
+	ops, err := c.read(p)
+	if err != nil {
+		return nil, err
+	}
+	d, err := c.read(doc)
+	if err != nil {
+		return nil, err
+	}
+	return patch.ApplyBytes(d, ops)
+}
+
+// read returns the contents of the named file, or of standard input when
+// name is "" or "-".
+func (c *cli) read(name string) ([]byte, error) {
+	if name == "" || name == "-" {
+		return io.ReadAll(c.stdin)
+	}
+	return os.ReadFile(name)
+}
+
+func (c *cli) write(name string, data []byte) int {
+	data = append(data, '\n')
+	var err error
+	if name == "-" {
+		_, err = c.stdout.Write(data)
+	} else {
+		err = os.WriteFile(name, data, 0o666)
+	}
+	if err != nil {
+		return c.fail(err)
+	}
+	return 0
+}
+
+func (c *cli) fail(err error) int {
+	fmt.Fprintf(c.stderr, "json-patch: %v\n", err)
+	return 1
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func runCLI(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	p := writeFile(t, dir, "patch.json", `[{"op": "add", "path": "/b", "value": 2}]`)
+	doc := writeFile(t, dir, "doc.json", `{"a": 1}`)
+
+	code, out, errOut := runCLI("", "apply", p, doc)
+	if code != 0 || out != `{"a":1,"b":2}`+"\n" {
+		t.Errorf("apply from file: %d %q %q", code, out, errOut)
+	}
+
+	code, out, _ = runCLI(`{"a": 12345678901234567890}`, "apply", p)
+	if code != 0 || out != `{"a":12345678901234567890,"b":2}`+"\n" {
+		t.Errorf("apply from stdin: %d %q", code, out)
+	}
+
+	result := filepath.Join(dir, "result.json")
+	if code, _, _ = runCLI(`{}`, "apply", "-o", result, p, "-"); code != 0 {
+		t.Fatalf("apply -o: %d", code)
+	}
+	if b, _ := os.ReadFile(result); string(b) != `{"b":2}`+"\n" {
+		t.Errorf("apply -o wrote %q", b)
+	}
+}
+
+func TestApplyLines(t *testing.T) {
+	dir := t.TempDir()
+	p := writeFile(t, dir, "patch.json", `[{"op": "add", "path": "/seen", "value": true}]`)
+	code, out, errOut := runCLI("{\"id\": 1}\n{\"id\": 2}\n", "apply", "-lines", p)
+	if code != 0 || out != `{"id":1,"seen":true}`+"\n"+`{"id":2,"seen":true}`+"\n" {
+		t.Errorf("apply -lines: %d %q %q", code, out, errOut)
+	}
+	code, _, errOut = runCLI("{}\n[]\n", "apply", "-lines", p)
+	if code != 1 || !strings.Contains(errOut, "line 2") {
+		t.Errorf("apply -lines with a failing record: %d %q", code, errOut)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.json", `{"a": 1}`)
+	code, out, errOut := runCLI(`{"a": 2}`, "diff", a, "-")
+	if code != 0 || out != `[{"op":"replace","path":"/a","value":2}]`+"\n" {
+		t.Errorf("diff: %d %q %q", code, out, errOut)
+	}
+}
+
+func TestTest(t *testing.T) {
+	dir := t.TempDir()
+	p := writeFile(t, dir, "patch.json", `[{"op": "test", "path": "/a", "value": 1}]`)
+	if code, out, _ := runCLI(`{"a": 1}`, "test", p); code != 0 || out != "" {
+		t.Errorf("expected the patch to apply, got %d %q", code, out)
+	}
+	code, _, errOut := runCLI(`{"a": 2}`, "test", p)
+	if code != 1 || !strings.HasPrefix(errOut, "json-patch: ") {
+		t.Errorf("expected the patch not to apply, got %d %q", code, errOut)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"frobnicate"},
+		{"apply"},
+		{"diff", "a.json"},
+		{"test", "-x", "p.json"},
+	} {
+		if code, _, _ := runCLI("", args...); code != 2 {
+			t.Errorf("%v: expected status 2, got %d", args, code)
+		}
+	}
+}
+
+func TestRepl(t *testing.T) {
+	dir := t.TempDir()
+	doc := writeFile(t, dir, "doc.json", `{"a": 1}`)
+	code, out, _ := runCLI("add /b 2\nget /b\npatch\nquit\n", "repl", doc)
+	expected := "> > 2\n> [\n  {\n    \"op\": \"add\",\n    \"path\": \"/b\",\n    \"value\": 2\n  }\n]\n> "
+	if code != 0 || out != expected {
+		t.Errorf("expected %q, got %d %q", expected, code, out)
+	}
+}
+
+func TestApplyDryRun(t *testing.T) {
+	dir := t.TempDir()
+	p := writeFile(t, dir, "patch.json", `[
+		{"op": "replace", "path": "/a", "value": 2},
+		{"op": "add", "path": "/b", "value": {"c": "<d>"}},
+		{"op": "remove", "path": "/e"},
+		{"op": "replace", "path": "/f", "value": 12345678901234567890},
+		{"op": "add", "path": "/g", "value": 1},
+		{"op": "remove", "path": "/g"}
+	]`)
+	doc := writeFile(t, dir, "doc.json", `{"a": 1, "e": [true], "f": 12345678901234567890}`)
+	code, out, errOut := runCLI("", "apply", "-dry-run", p, doc)
+	expected := "replaced /a: 1 -> 2\nadded /b: {\"c\":\"<d>\"}\nremoved /e: [true]\n"
+	if code != 0 || out != expected {
+		t.Errorf("expected %q, got %d %q %q", expected, code, out, errOut)
+	}
+	code, out, errOut = runCLI("", "apply", "--dry-run", "--output=json", p, doc)
+	expected = `{"changes":[{"kind":"replaced","path":"/a","before":1,"after":2},` +
+		`{"kind":"added","path":"/b","after":{"c":"<d>"}},{"kind":"removed","path":"/e","before":[true]}]}` + "\n"
+	if code != 0 || out != expected {
+		t.Errorf("expected %q, got %d %q %q", expected, code, out, errOut)
+	}
+	if b, _ := os.ReadFile(doc); string(b) != `{"a": 1, "e": [true], "f": 12345678901234567890}` {
+		t.Errorf("dry run modified the document: %s", b)
+	}
+	if code, out, _ = runCLI(`{}`, "apply", "-dry-run", "-output", "json", p); code != 1 || out != "" {
+		t.Errorf("expected the plan to fail, got %d %q", code, out)
+	}
+	for _, args := range [][]string{
+		{"apply", "-dry-run", "-output", "yaml", p, doc},
+		{"apply", "-dry-run", "-lines", p, doc},
+	} {
+		if code, _, _ := runCLI("", args...); code != 2 {
+			t.Errorf("%v: expected status 2, got %d", args, code)
+		}
+	}
+}
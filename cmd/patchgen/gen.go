@@ -0,0 +1,657 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	patch "github.com/grncdr/json-patch"
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// basics maps the predeclared types a patch can set to the bit size of
+// their values, 0 for those without one.
+var basics = map[string]int{
+	"bool": 0, "string": 0,
+	"int": 64, "int8": 8, "int16": 16, "int32": 32, "int64": 64,
+	"uint": 64, "uint8": 8, "uint16": 16, "uint32": 32, "uint64": 64, "uintptr": 64,
+	"float32": 32, "float64": 64,
+	"byte": 8, "rune": 32,
+}
+
+// generator writes the Go code applying a patch to a struct type declared
+// in a package.
+type generator struct {
+	pkg     string
+	types   map[string]ast.Expr // the named types of the package
+	imports map[string]bool
+	body    bytes.Buffer
+}
+
+// loadPackage parses the Go files of the package in dir, leaving out tests.
+func loadPackage(dir string) (*generator, error) {
+	fset := token.NewFileSet()
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	g := &generator{types: make(map[string]ast.Expr), imports: make(map[string]bool)}
+	for _, name := range names {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, name, src, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		if g.pkg != "" && f.Name.Name != g.pkg {
+			continue
+		}
+		g.pkg = f.Name.Name
+		for _, decl := range f.Decls {
+			d, ok := decl.(*ast.GenDecl)
+			if !ok || d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if ts.TypeParams == nil {
+					g.types[ts.Name.Name] = ts.Type
+				}
+			}
+		}
+	}
+	if g.pkg == "" {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return g, nil
+}
+
+// generate returns the source of a file declaring the function name, which
+// applies ops to a *typeName.
+func (g *generator) generate(source, typeName, name string, ops []patch.Operation) ([]byte, error) {
+	if _, ok := g.types[typeName]; !ok {
+		return nil, fmt.Errorf("type %s not found in package %s", typeName, g.pkg)
+	}
+	for i, op := range ops {
+		fmt.Fprintf(&g.body, "\t// %s %s\n\t{\n", op.Op, op.Path)
+		if err := g.operation(i, op, ast.NewIdent(typeName)); err != nil {
+			return nil, fmt.Errorf("operation %d: %v", i, err)
+		}
+		g.body.WriteString("\t}\n")
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by patchgen from %s; DO NOT EDIT.\n\npackage %s\n\n", source, g.pkg)
+	if len(g.imports) > 0 {
+		imports := make([]string, 0, len(g.imports))
+		for imp := range g.imports {
+			imports = append(imports, strconv.Quote(imp))
+		}
+		sort.Strings(imports)
+		fmt.Fprintf(&out, "import (\n%s\n)\n\n", strings.Join(imports, "\n"))
+	}
+	fmt.Fprintf(&out, "// %s applies the patch in %s to v.\n", name, source)
+	fmt.Fprintf(&out, "// It stops at the first operation that fails, leaving the operations\n// before it applied.\n")
+	fmt.Fprintf(&out, "func %s(v *%s) error {\n", name, typeName)
+	out.Write(g.body.Bytes())
+	out.WriteString("\treturn nil\n}\n")
+	return format.Source(out.Bytes())
+}
+
+// location is a Go expression for the value at a JSON pointer.
+type location struct {
+	expr string
+	typ  ast.Expr
+	ptr  string
+}
+
+// maxIndex bounds the array indexes of paths.
+const maxIndex = math.MaxInt32
+
+func (loc location) child(expr string, typ ast.Expr, token string) location {
+	return location{expr: expr, typ: typ, ptr: loc.ptr + "/" + pointer.Escape(token)}
+}
+
+// operation writes the statements applying op.
+func (g *generator) operation(i int, op patch.Operation, root ast.Expr) error {
+	path, err := pointer.Parse(op.Path)
+	if err != nil {
+		return err
+	}
+	if len(path) == 0 {
+		return fmt.Errorf("operations on the whole document are not supported")
+	}
+	start := location{expr: "(*v)", typ: root}
+	if _, ok := g.underlying(root).(*ast.StructType); ok {
+		start.expr = "v"
+	}
+	fail := func(msg string) string {
+		g.imports["errors"] = true
+		return fmt.Sprintf("return errors.New(%q)", fmt.Sprintf("operation %d: %s", i, msg))
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		var value interface{}
+		d := json.NewDecoder(bytes.NewReader(op.Value))
+		d.UseNumber()
+		if op.Value == nil || d.Decode(&value) != nil {
+			return fmt.Errorf("missing or invalid 'value' parameter")
+		}
+		parent, err := g.walk(start, path[:len(path)-1], fail)
+		if err != nil {
+			return err
+		}
+		if op.Op == "test" {
+			loc, err := g.read(parent, path[len(path)-1], fail)
+			if err != nil {
+				return err
+			}
+			cond, err := g.differs(loc, value)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&g.body, "if %s {\n%s\n}\n", cond, fail("test "+op.Path+" failed"))
+			return nil
+		}
+		return g.write(parent, path[len(path)-1], op.Op == "add", func(typ ast.Expr) (string, error) {
+			return g.literal(typ, value)
+		}, fail)
+	case "remove":
+		parent, err := g.walk(start, path[:len(path)-1], fail)
+		if err != nil {
+			return err
+		}
+		return g.remove(parent, path[len(path)-1], fail)
+	case "copy", "move":
+		from, err := pointer.Parse(op.From)
+		if err != nil {
+			return err
+		}
+		if len(from) == 0 {
+			return fmt.Errorf("operations on the whole document are not supported")
+		}
+		parent, err := g.walk(start, from[:len(from)-1], fail)
+		if err != nil {
+			return err
+		}
+		src, err := g.read(parent, from[len(from)-1], fail)
+		if err != nil {
+			return err
+		}
+		if op.Op == "copy" && !g.flat(src.typ) {
+			return fmt.Errorf("copies of %s values are not supported, only of values without pointers, slices or maps", types.ExprString(src.typ))
+		}
+		fmt.Fprintf(&g.body, "value := %s\n", src.expr)
+		if op.Op == "move" {
+			if err := g.remove(parent, from[len(from)-1], fail); err != nil {
+				return err
+			}
+		}
+		if parent, err = g.walk(start, path[:len(path)-1], fail); err != nil {
+			return err
+		}
+		return g.write(parent, path[len(path)-1], true, func(typ ast.Expr) (string, error) {
+			if types.ExprString(typ) != types.ExprString(src.typ) {
+				return "", fmt.Errorf("cannot store a %s in a %s", types.ExprString(src.typ), types.ExprString(typ))
+			}
+			return "value", nil
+		}, fail)
+	}
+	return fmt.Errorf("%s operations are not supported", op.Op)
+}
+
+// underlying returns the type expression a named type of the package is
+// defined with, following definitions.
+func (g *generator) underlying(typ ast.Expr) ast.Expr {
+	for i := 0; i < 100; i++ {
+		id, ok := typ.(*ast.Ident)
+		if !ok {
+			return typ
+		}
+		if _, ok := basics[id.Name]; ok {
+			return id
+		}
+		def, ok := g.types[id.Name]
+		if !ok {
+			return typ
+		}
+		typ = def
+	}
+	return typ
+}
+
+// basic returns the predeclared type of typ, if it is one.
+func (g *generator) basic(typ ast.Expr) (string, bool) {
+	id, ok := g.underlying(typ).(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	_, ok = basics[id.Name]
+	return id.Name, ok
+}
+
+// deref checks that the pointer loc is not nil and returns what it points
+// to; other locations are returned as they are.
+func (g *generator) deref(loc location, fail func(string) string) location {
+	star, ok := g.underlying(loc.typ).(*ast.StarExpr)
+	if !ok {
+		return loc
+	}
+	fmt.Fprintf(&g.body, "if %s == nil {\n%s\n}\n", loc.expr, fail(loc.ptr+" is null"))
+	if _, ok := g.underlying(star.X).(*ast.StructType); ok {
+		// selectors go through pointers to structs
+		return location{expr: loc.expr, typ: star.X, ptr: loc.ptr}
+	}
+	return location{expr: "(*" + loc.expr + ")", typ: star.X, ptr: loc.ptr}
+}
+
+// walk writes the checks needed to reach the value at path from loc and
+// returns its location.
+func (g *generator) walk(loc location, path pointer.Pointer, fail func(string) string) (location, error) {
+	for _, token := range path {
+		next, err := g.read(loc, token, fail)
+		if err != nil {
+			return location{}, err
+		}
+		if _, ok := g.underlying(loc.typ).(*ast.MapType); ok {
+			if _, ok := g.underlying(next.typ).(*ast.StructType); ok {
+				return location{}, fmt.Errorf("%s: struct values of maps cannot be modified in place", token)
+			}
+		}
+		loc = next
+	}
+	return g.deref(loc, fail), nil
+}
+
+// read writes the checks needed for the member or element token of the
+// container loc to exist and returns its location.
+func (g *generator) read(loc location, token string, fail func(string) string) (location, error) {
+	loc = g.deref(loc, fail)
+	switch t := g.underlying(loc.typ).(type) {
+	case *ast.StructType:
+		field, typ, err := g.field(t, token)
+		if err != nil {
+			return location{}, err
+		}
+		return loc.child(loc.expr+"."+field, typ, token), nil
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return location{}, fmt.Errorf("arrays are not supported, only slices")
+		}
+		i, err := pointer.ParseIndex(token, maxIndex, false)
+		if err != nil {
+			return location{}, err
+		}
+		next := loc.child(fmt.Sprintf("%s[%d]", loc.expr, i), t.Elt, token)
+		fmt.Fprintf(&g.body, "if len(%s) <= %d {\n%s\n}\n", loc.expr, i, fail(next.ptr+" not found"))
+		return next, nil
+	case *ast.MapType:
+		if err := g.stringKeys(t); err != nil {
+			return location{}, err
+		}
+		key := strconv.Quote(token)
+		next := loc.child(loc.expr+"["+key+"]", t.Value, token)
+		fmt.Fprintf(&g.body, "if _, ok := %s[%s]; !ok {\n%s\n}\n", loc.expr, key, fail(next.ptr+" not found"))
+		return next, nil
+	}
+	return location{}, fmt.Errorf("%s: cannot index a %s", token, types.ExprString(loc.typ))
+}
+
+func (g *generator) stringKeys(t *ast.MapType) error {
+	if name, ok := g.basic(t.Key); !ok || name != "string" {
+		return fmt.Errorf("only maps with string keys are supported")
+	}
+	return nil
+}
+
+// field returns the name and type of the field of t encoding/json uses for
+// the member name: the one whose name or json tag matches exactly, or else
+// case-insensitively. Embedded fields are not supported.
+func (g *generator) field(t *ast.StructType, name string) (string, ast.Expr, error) {
+	var folded []*ast.Field
+	var foldedName string
+	for _, f := range t.Fields.List {
+		key := ""
+		if f.Tag != nil {
+			tag, _ := strconv.Unquote(f.Tag.Value)
+			key, _, _ = strings.Cut(reflect.StructTag(tag).Get("json"), ",")
+			if key == "-" && !strings.HasPrefix(reflect.StructTag(tag).Get("json"), "-,") {
+				continue
+			}
+		}
+		for _, id := range f.Names {
+			if !id.IsExported() {
+				continue
+			}
+			k := key
+			if k == "" {
+				k = id.Name
+			}
+			if k == name {
+				return id.Name, f.Type, nil
+			}
+			if strings.EqualFold(k, name) {
+				folded, foldedName = append(folded, f), id.Name
+			}
+		}
+	}
+	if len(folded) == 1 {
+		return foldedName, folded[0].Type, nil
+	}
+	return "", nil, fmt.Errorf("no field for member %q", name)
+}
+
+// write writes the statements storing a value at the member or element
+// token of the container loc: value returns the expression of the value
+// for the type stored there. Adding to a slice inserts an element.
+func (g *generator) write(loc location, token string, add bool, value func(ast.Expr) (string, error), fail func(string) string) error {
+	switch t := g.underlying(loc.typ).(type) {
+	case *ast.StructType:
+		field, typ, err := g.field(t, token)
+		if err != nil {
+			return err
+		}
+		v, err := value(typ)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&g.body, "%s.%s = %s\n", loc.expr, field, v)
+		return nil
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return fmt.Errorf("arrays are not supported, only slices")
+		}
+		v, err := value(t.Elt)
+		if err != nil {
+			return err
+		}
+		if add && token == "-" {
+			fmt.Fprintf(&g.body, "%s = append(%s, %s)\n", loc.expr, loc.expr, v)
+			return nil
+		}
+		i, err := pointer.ParseIndex(token, maxIndex, false)
+		if err != nil {
+			return err
+		}
+		ptr := loc.child("", nil, token).ptr
+		if !add {
+			fmt.Fprintf(&g.body, "if len(%s) <= %d {\n%s\n}\n%s[%d] = %s\n", loc.expr, i, fail(ptr+" not found"), loc.expr, i, v)
+			return nil
+		}
+		g.imports["slices"] = true
+		if i > 0 {
+			fmt.Fprintf(&g.body, "if len(%s) < %d {\n%s\n}\n", loc.expr, i, fail(ptr+" is out of range"))
+		}
+		fmt.Fprintf(&g.body, "%s = slices.Insert(%s, %d, %s)\n", loc.expr, loc.expr, i, v)
+		return nil
+	case *ast.MapType:
+		if err := g.stringKeys(t); err != nil {
+			return err
+		}
+		v, err := value(t.Value)
+		if err != nil {
+			return err
+		}
+		key := strconv.Quote(token)
+		if add {
+			fmt.Fprintf(&g.body, "if %s == nil {\n%s = %s{}\n}\n", loc.expr, loc.expr, types.ExprString(loc.typ))
+		} else {
+			fmt.Fprintf(&g.body, "if _, ok := %s[%s]; !ok {\n%s\n}\n", loc.expr, key, fail(loc.child("", nil, token).ptr+" not found"))
+		}
+		fmt.Fprintf(&g.body, "%s[%s] = %s\n", loc.expr, key, v)
+		return nil
+	}
+	return fmt.Errorf("%s: cannot index a %s", token, types.ExprString(loc.typ))
+}
+
+// remove writes the statements removing the member or element token of the
+// container loc. Struct fields are set to their zero value.
+func (g *generator) remove(loc location, token string, fail func(string) string) error {
+	switch t := g.underlying(loc.typ).(type) {
+	case *ast.StructType:
+		field, typ, err := g.field(t, token)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&g.body, "%s.%s = %s\n", loc.expr, field, g.zero(typ))
+		return nil
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return fmt.Errorf("arrays are not supported, only slices")
+		}
+		i, err := pointer.ParseIndex(token, maxIndex, false)
+		if err != nil {
+			return err
+		}
+		g.imports["slices"] = true
+		fmt.Fprintf(&g.body, "if len(%s) <= %d {\n%s\n}\n%s = slices.Delete(%s, %d, %d)\n", loc.expr, i, fail(loc.child("", nil, token).ptr+" not found"), loc.expr, loc.expr, i, i+1)
+		return nil
+	case *ast.MapType:
+		if err := g.stringKeys(t); err != nil {
+			return err
+		}
+		key := strconv.Quote(token)
+		fmt.Fprintf(&g.body, "if _, ok := %s[%s]; !ok {\n%s\n}\ndelete(%s, %s)\n", loc.expr, key, fail(loc.child("", nil, token).ptr+" not found"), loc.expr, key)
+		return nil
+	}
+	return fmt.Errorf("%s: cannot index a %s", token, types.ExprString(loc.typ))
+}
+
+func (g *generator) zero(typ ast.Expr) string {
+	switch g.underlying(typ).(type) {
+	case *ast.StarExpr, *ast.ArrayType, *ast.MapType:
+		return "nil"
+	case *ast.StructType:
+		return types.ExprString(typ) + "{}"
+	}
+	switch name, _ := g.basic(typ); name {
+	case "string":
+		return `""`
+	case "bool":
+		return "false"
+	}
+	return "0"
+}
+
+// flat reports whether values of typ can be copied by assignment, having no
+// pointers, slices or maps.
+func (g *generator) flat(typ ast.Expr) bool {
+	if _, ok := g.basic(typ); ok {
+		return true
+	}
+	st, ok := g.underlying(typ).(*ast.StructType)
+	if !ok {
+		return false
+	}
+	for _, f := range st.Fields.List {
+		if !g.flat(f.Type) {
+			return false
+		}
+	}
+	return true
+}
+
+// literal returns a Go expression of type typ for the JSON value v.
+func (g *generator) literal(typ ast.Expr, v interface{}) (string, error) {
+	name := types.ExprString(typ)
+	switch t := g.underlying(typ).(type) {
+	case *ast.StarExpr:
+		if v == nil {
+			return "nil", nil
+		}
+		elem, err := g.literal(t.X, v)
+		if err != nil {
+			return "", err
+		}
+		if _, ok := g.underlying(t.X).(*ast.StructType); ok {
+			return "&" + elem, nil
+		}
+		return fmt.Sprintf("func() %s { x := %s(%s); return &x }()", name, types.ExprString(t.X), elem), nil
+	case *ast.ArrayType:
+		if v == nil {
+			return "nil", nil
+		}
+		s, ok := v.([]interface{})
+		if !ok || t.Len != nil {
+			return "", fmt.Errorf("cannot store %s in a %s", jsonText(v), name)
+		}
+		elems := make([]string, len(s))
+		for i, x := range s {
+			var err error
+			if elems[i], err = g.literal(t.Elt, x); err != nil {
+				return "", err
+			}
+		}
+		return name + "{" + strings.Join(elems, ", ") + "}", nil
+	case *ast.MapType:
+		if v == nil {
+			return "nil", nil
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("cannot store %s in a %s", jsonText(v), name)
+		}
+		if err := g.stringKeys(t); err != nil {
+			return "", err
+		}
+		return g.members(name, m, func(k string) (string, ast.Expr, error) {
+			return strconv.Quote(k), t.Value, nil
+		})
+	case *ast.StructType:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("cannot store %s in a %s", jsonText(v), name)
+		}
+		return g.members(name, m, func(k string) (string, ast.Expr, error) {
+			return g.field(t, k)
+		})
+	}
+	basic, ok := g.basic(typ)
+	if !ok {
+		return "", fmt.Errorf("%s values are not supported", name)
+	}
+	bits := basics[basic]
+	switch v := v.(type) {
+	case string:
+		if basic == "string" {
+			return strconv.Quote(v), nil
+		}
+	case bool:
+		if basic == "bool" {
+			return strconv.FormatBool(v), nil
+		}
+	case json.Number:
+		var err error
+		switch {
+		case strings.HasPrefix(basic, "float"):
+			_, err = strconv.ParseFloat(v.String(), bits)
+		case strings.HasPrefix(basic, "int"), basic == "rune":
+			_, err = strconv.ParseInt(v.String(), 10, bits)
+		case bits > 0:
+			_, err = strconv.ParseUint(v.String(), 10, bits)
+		default:
+			err = fmt.Errorf("not a number type")
+		}
+		if err == nil {
+			return v.String(), nil
+		}
+	}
+	return "", fmt.Errorf("cannot store %s in a %s", jsonText(v), name)
+}
+
+// members returns a composite literal of type name for the members of m,
+// sorted by name; key returns the key of a member in the literal and the
+// type of its value.
+func (g *generator) members(name string, m map[string]interface{}, key func(string) (string, ast.Expr, error)) (string, error) {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	elems := make([]string, len(names))
+	for i, k := range names {
+		lit, typ, err := key(k)
+		if err != nil {
+			return "", err
+		}
+		v, err := g.literal(typ, m[k])
+		if err != nil {
+			return "", err
+		}
+		elems[i] = lit + ": " + v
+	}
+	return name + "{" + strings.Join(elems, ", ") + "}", nil
+}
+
+// differs returns a condition true when the value at loc is not the JSON
+// value v. Only values that Go compares like JSON are supported: those
+// without pointers, slices or maps, pointers to them, and slices and maps
+// of basic types, besides comparisons with null.
+func (g *generator) differs(loc location, v interface{}) (string, error) {
+	u := g.underlying(loc.typ)
+	if v == nil {
+		switch u.(type) {
+		case *ast.StarExpr, *ast.ArrayType, *ast.MapType:
+			return loc.expr + " != nil", nil
+		}
+		return "", fmt.Errorf("a %s is never null", types.ExprString(loc.typ))
+	}
+	lit, err := g.literal(loc.typ, v)
+	if err != nil {
+		return "", err
+	}
+	switch t := u.(type) {
+	case *ast.StarExpr:
+		if !g.flat(t.X) {
+			break
+		}
+		elem, err := g.literal(t.X, v)
+		if err != nil {
+			return "", err
+		}
+		if _, ok := g.underlying(t.X).(*ast.StructType); ok {
+			elem = "(" + elem + ")"
+		}
+		return fmt.Sprintf("%s == nil || *%s != %s", loc.expr, loc.expr, elem), nil
+	case *ast.ArrayType:
+		if _, ok := g.basic(t.Elt); ok {
+			g.imports["slices"] = true
+			return fmt.Sprintf("!slices.Equal(%s, %s)", loc.expr, lit), nil
+		}
+	case *ast.MapType:
+		if _, ok := g.basic(t.Value); ok {
+			g.imports["maps"] = true
+			return fmt.Sprintf("!maps.Equal(%s, %s)", loc.expr, lit), nil
+		}
+	case *ast.StructType:
+		if g.flat(loc.typ) {
+			return fmt.Sprintf("%s != (%s)", loc.expr, lit), nil
+		}
+	default:
+		return fmt.Sprintf("%s != %s", loc.expr, lit), nil
+	}
+	return "", fmt.Errorf("tests of %s values are not supported", types.ExprString(loc.typ))
+}
+
+func jsonText(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testTypes = `package config
+
+type Level string
+
+type Config struct {
+	Name     string            ` + "`json:\"name\"`" + `
+	Port     int               ` + "`json:\"port,omitempty\"`" + `
+	Ratio    float64           ` + "`json:\"ratio\"`" + `
+	Debug    bool
+	Level    Level             ` + "`json:\"level\"`" + `
+	Tags     []string          ` + "`json:\"tags\"`" + `
+	Labels   map[string]string ` + "`json:\"labels\"`" + `
+	DB       *Database         ` + "`json:\"db\"`" + `
+	Backends []Backend         ` + "`json:\"backends\"`" + `
+	Limit    *int              ` + "`json:\"limit\"`" + `
+	Skipped  string            ` + "`json:\"-\"`" + `
+	secret   string
+}
+
+type Database struct {
+	Host string ` + "`json:\"host\"`" + `
+	Pool Pool   ` + "`json:\"pool\"`" + `
+}
+
+type Pool struct {
+	Min, Max int
+}
+
+type Backend struct {
+	URL    string ` + "`json:\"url\"`" + `
+	Weight uint8  ` + "`json:\"weight\"`" + `
+}
+`
+
+const testPatch = `[
+	{"op": "test", "path": "/name", "value": "old"},
+	{"op": "replace", "path": "/name", "value": "new \"quoted\""},
+	{"op": "add", "path": "/port", "value": 8080},
+	{"op": "replace", "path": "/ratio", "value": 0.5},
+	{"op": "add", "path": "/debug", "value": true},
+	{"op": "add", "path": "/level", "value": "info"},
+	{"op": "add", "path": "/tags/-", "value": "c"},
+	{"op": "add", "path": "/tags/0", "value": "z"},
+	{"op": "remove", "path": "/tags/1"},
+	{"op": "test", "path": "/tags", "value": ["z", "b", "c"]},
+	{"op": "add", "path": "/labels/env", "value": "prod"},
+	{"op": "remove", "path": "/labels/old"},
+	{"op": "replace", "path": "/db/host", "value": "db.internal"},
+	{"op": "test", "path": "/db/pool", "value": {"Min": 1, "Max": 2}},
+	{"op": "add", "path": "/db/pool/max", "value": 10},
+	{"op": "add", "path": "/backends/-", "value": {"url": "http://b", "weight": 3}},
+	{"op": "copy", "from": "/backends/0/weight", "path": "/backends/1/weight"},
+	{"op": "move", "from": "/db/host", "path": "/name"},
+	{"op": "add", "path": "/limit", "value": 5},
+	{"op": "test", "path": "/limit", "value": 5},
+	{"op": "remove", "path": "/ratio"}
+]`
+
+// testMain applies the generated function to a Config and prints it.
+const testMain = `package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+func main() {
+	c := Config{
+		Name:     "old",
+		Ratio:    2,
+		Tags:     []string{"a", "b"},
+		Labels:   map[string]string{"old": "x"},
+		DB:       &Database{Host: "h", Pool: Pool{Min: 1, Max: 2}},
+		Backends: []Backend{{URL: "http://a", Weight: 7}},
+	}
+	if err := applyConfig(&c); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	b, _ := json.Marshal(c)
+	fmt.Println(string(b))
+	var empty Config
+	fmt.Println(applyConfig(&empty))
+}
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// typeCheck type-checks the Go files in dir.
+func typeCheck(t *testing.T, dir string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	names, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	var files []*ast.File
+	for _, name := range names {
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	conf := types.Config{Importer: importer.Default()}
+	if _, err := conf.Check("config", fset, files, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.go", testTypes)
+	p := writeFile(t, dir, "patch.json", testPatch)
+	src, err := generate(p, dir, "Config", "applyConfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(src), "// Code generated by patchgen from patch.json; DO NOT EDIT.") {
+		t.Errorf("missing header:\n%s", src)
+	}
+	for _, unwanted := range []string{"reflect", "interface{}", "any"} {
+		if strings.Contains(string(src), unwanted) {
+			t.Errorf("the generated code uses %s:\n%s", unwanted, src)
+		}
+	}
+	writeFile(t, dir, "config_gen.go", string(src))
+	typeCheck(t, dir)
+
+	// run the generated code
+	goTool, err := exec.LookPath("go")
+	if err != nil || testing.Short() {
+		t.Skip("go tool not available")
+	}
+	writeFile(t, dir, "go.mod", "module config\n\ngo 1.22\n")
+	writeFile(t, dir, "main.go", strings.Replace(testMain, "package config", "package main", 1))
+	for _, name := range []string{"config.go", "config_gen.go"} {
+		b, _ := os.ReadFile(filepath.Join(dir, name))
+		os.WriteFile(filepath.Join(dir, name), []byte(strings.Replace(string(b), "package config", "package main", 1)), 0o666)
+	}
+	cmd := exec.Command(goTool, "run", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s\n%s", err, out, src)
+	}
+	expected := `{"name":"db.internal","port":8080,"ratio":0,"Debug":true,"level":"info","tags":["z","b","c"],` +
+		`"labels":{"env":"prod"},"db":{"host":"","pool":{"Min":1,"Max":10}},` +
+		`"backends":[{"url":"http://a","weight":7},{"url":"http://b","weight":7}],"limit":5}` + "\n" +
+		`operation 0: test /name failed` + "\n"
+	if string(out) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.go", testTypes)
+	for _, p := range []string{
+		`[{"op": "add", "path": "/port", "value": "x"}]`,
+		`[{"op": "add", "path": "/backends/0/weight", "value": 256}]`,
+		`[{"op": "add", "path": "/missing", "value": 1}]`,
+		`[{"op": "add", "path": "/skipped", "value": "x"}]`,
+		`[{"op": "add", "path": "/secret", "value": "x"}]`,
+		`[{"op": "add", "path": "/name/x", "value": 1}]`,
+		`[{"op": "replace", "path": "", "value": {}}]`,
+		`[{"op": "copy", "from": "/tags", "path": "/labels"}]`,
+		`[{"op": "move", "from": "/port", "path": "/name"}]`,
+		`[{"op": "test", "path": "/backends", "value": []}]`,
+		`[{"op": "add", "path": "/name", "value": null}]`,
+		`[{"op": "frobnicate", "path": "/name"}]`,
+	} {
+		g, err := loadPackage(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := g.generate("patch.json", "Config", "f", parseOps(t, p)); err == nil {
+			t.Errorf("%s: expected an error", p)
+		}
+	}
+	g, _ := loadPackage(dir)
+	if _, err := g.generate("patch.json", "Missing", "f", nil); err == nil {
+		t.Error("expected an unknown type to be rejected")
+	}
+}
@@ -0,0 +1,93 @@
+// Command patchgen generates Go code applying a fixed JSON patch (RFC 6902)
+// to a struct type, with plain assignments instead of reflection or
+// interface{} values, for hot paths where the patch is known at build time.
+//
+// Usage:
+//
+//	patchgen -type TYPE [-func NAME] [-dir DIR] [-o FILE] PATCH
+//
+// patchgen reads the patch in the file PATCH and the declaration of TYPE in
+// the package in DIR, the current directory by default, and writes a file
+// of that package declaring
+//
+//	func NAME(v *TYPE) error
+//
+// to FILE, or to standard output. NAME defaults to "apply" followed by TYPE.
+// It fits go:generate directives:
+//
+//	//go:generate patchgen -type Config -o defaults_gen.go defaults.json
+//
+// Paths follow the JSON names of struct fields, from their json tags, and
+// may go through pointers, slices and maps with string keys of types
+// declared in the same package. Removing a struct field sets it to its zero
+// value. Values are checked against the types they are stored in when the
+// code is generated; only the existence of the locations a path goes
+// through is checked when it runs, so the generated function returns an
+// error where the patch would not apply. Copies are limited to values
+// without pointers, slices or maps, and tests to values Go compares like
+// JSON.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	patch "github.com/grncdr/json-patch"
+)
+
+const usage = `usage: patchgen -type TYPE [-func NAME] [-dir DIR] [-o FILE] PATCH
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("patchgen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, usage) }
+	typeName := fs.String("type", "", "apply the patch to a *`TYPE`")
+	name := fs.String("func", "", "name the generated function `NAME`")
+	dir := fs.String("dir", ".", "find TYPE in the package in `DIR`")
+	out := fs.String("o", "-", "write the code to `FILE`")
+	if fs.Parse(args) != nil || fs.NArg() != 1 || *typeName == "" {
+		return 2
+	}
+	if *name == "" {
+		*name = "apply" + *typeName
+	}
+	src, err := generate(fs.Arg(0), *dir, *typeName, *name)
+	if err != nil {
+		fmt.Fprintf(stderr, "patchgen: %v\n", err)
+		return 1
+	}
+	if *out == "-" {
+		_, err = stdout.Write(src)
+	} else {
+		err = os.WriteFile(*out, src, 0o666)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "patchgen: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func generate(patchFile, dir, typeName, name string) ([]byte, error) {
+	data, err := os.ReadFile(patchFile)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := patch.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	g, err := loadPackage(dir)
+	if err != nil {
+		return nil, err
+	}
+	return g.generate(filepath.Base(patchFile), typeName, name, ops)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	patch "github.com/grncdr/json-patch"
+)
+
+func parseOps(t *testing.T, s string) []patch.Operation {
+	t.Helper()
+	ops, err := patch.Parse([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ops
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "config.go", testTypes)
+	p := writeFile(t, dir, "patch.json", `[{"op": "add", "path": "/port", "value": 1}]`)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-type", "Config", "-dir", dir, p}, &stdout, &stderr); code != 0 {
+		t.Fatalf("status %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "func applyConfig(v *Config) error {") {
+		t.Errorf("unexpected output:\n%s", stdout.String())
+	}
+
+	out := filepath.Join(dir, "gen.go")
+	if code := run([]string{"-type", "Config", "-func", "SetPort", "-dir", dir, "-o", out, p}, &stdout, &stderr); code != 0 {
+		t.Fatalf("status %d: %s", code, stderr.String())
+	}
+	if b, _ := os.ReadFile(out); !strings.Contains(string(b), "func SetPort(v *Config) error {") {
+		t.Errorf("unexpected file:\n%s", b)
+	}
+
+	for _, args := range [][]string{nil, {p}, {"-type", "Config"}} {
+		if code := run(args, &stdout, &stderr); code != 2 {
+			t.Errorf("%v: expected status 2, got %d", args, code)
+		}
+	}
+	if code := run([]string{"-type", "Nope", "-dir", dir, p}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected status 1, got %d", code)
+	}
+}
@@ -0,0 +1,61 @@
+package patch
+
+import (
+	"encoding/json"
+	"sync/atomic"
+)
+
+// Codec encodes and decodes JSON. The package uses encoding/json through
+// StdCodec by default; SetCodec and WithCodec plug in another
+// implementation, such as jsoniter or encoding/json/v2, for faster decoding
+// of operation values and documents. A Codec must follow the semantics of
+// encoding/json for the values the package works on: maps, slices,
+// strings, float64 or json.Number, booleans and nil, and the Operation type.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	// UnmarshalNumber is Unmarshal with numbers decoded into interface{}
+	// values as json.Number instead of float64.
+	UnmarshalNumber(data []byte, v interface{}) error
+}
+
+// StdCodec is the Codec using encoding/json. Its Marshal does not escape
+// HTML characters.
+type StdCodec struct{}
+
+// Marshal encodes v like json.Marshal, without escaping HTML characters.
+func (StdCodec) Marshal(v interface{}) ([]byte, error) { return marshal(v) }
+
+// Unmarshal is json.Unmarshal.
+func (StdCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// UnmarshalNumber decodes data like json.Unmarshal, with
+// json.Decoder.UseNumber.
+func (StdCodec) UnmarshalNumber(data []byte, v interface{}) error { return unmarshalNumber(data, v) }
+
+var defaultCodec atomic.Pointer[Codec]
+
+// SetCodec sets the Codec used by functions without options, such as Parse
+// and ApplyBytes, and by patches whose options set none. A nil c restores
+// StdCodec. SetCodec is meant to be called once, during initialization.
+func SetCodec(c Codec) {
+	if c == nil {
+		c = StdCodec{}
+	}
+	defaultCodec.Store(&c)
+}
+
+func currentCodec() Codec {
+	if c := defaultCodec.Load(); c != nil {
+		return *c
+	}
+	return StdCodec{}
+}
+
+// codec returns the Codec of the options, or the one set with SetCodec.
+func (o *Options) codec() Codec {
+	if o.Codec != nil {
+		return o.Codec
+	}
+	return currentCodec()
+}
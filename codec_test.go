@@ -0,0 +1,80 @@
+package patch
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+// countingCodec counts the calls to StdCodec.
+type countingCodec struct {
+	StdCodec
+	marshal, unmarshal atomic.Int32
+}
+
+func (c *countingCodec) Marshal(v interface{}) ([]byte, error) {
+	c.marshal.Add(1)
+	return c.StdCodec.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v interface{}) error {
+	c.unmarshal.Add(1)
+	return c.StdCodec.Unmarshal(data, v)
+}
+
+func (c *countingCodec) UnmarshalNumber(data []byte, v interface{}) error {
+	c.unmarshal.Add(1)
+	return c.StdCodec.UnmarshalNumber(data, v)
+}
+
+func TestWithCodec(t *testing.T) {
+	c := &countingCodec{}
+	ops := parseStr(`[
+		{"op": "add", "path": "/a", "value": 1},
+		{"op": "copy", "from": "/a", "path": "/b"}
+	]`)
+	doc, err := Apply(decode(`{}`), ops, WithCodec(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !jsonEqual(doc, decode(`{"a": 1, "b": 1}`)) {
+		t.Errorf("unexpected result %v", doc)
+	}
+	// the value of the add; the copy reuses the decoded value
+	if c.unmarshal.Load() != 1 {
+		t.Errorf("the codec was not used: %d unmarshals", c.unmarshal.Load())
+	}
+}
+
+func TestSetCodec(t *testing.T) {
+	c := &countingCodec{}
+	SetCodec(c)
+	t.Cleanup(func() { SetCodec(nil) })
+
+	out, err := ApplyBytes([]byte(`{"a": "<b>"}`), []byte(`[{"op": "move", "from": "/a", "path": "/c"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `{"c":"<b>"}` {
+		t.Errorf("unexpected result %s", out)
+	}
+	// Parse and the document, then the result
+	if c.unmarshal.Load() != 2 || c.marshal.Load() != 1 {
+		t.Errorf("the codec was not used: %d marshals, %d unmarshals", c.marshal.Load(), c.unmarshal.Load())
+	}
+
+	// options take precedence
+	other := &countingCodec{}
+	ops := parseStr(`[{"op": "add", "path": "/a", "value": 1}]`)
+	before := c.unmarshal.Load()
+	if _, err := Apply(decode(`{}`), ops, WithCodec(other)); err != nil {
+		t.Fatal(err)
+	}
+	if other.unmarshal.Load() != 1 || c.unmarshal.Load() != before {
+		t.Errorf("expected the codec of the options to be used")
+	}
+
+	SetCodec(nil)
+	if _, ok := currentCodec().(StdCodec); !ok {
+		t.Errorf("expected StdCodec, got %T", currentCodec())
+	}
+}
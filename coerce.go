@@ -0,0 +1,122 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// CoerceType is a type values of replace operations are coerced to. See
+// Options.Coerce.
+type CoerceType string
+
+const (
+	// CoerceNumber parses strings as JSON numbers.
+	CoerceNumber CoerceType = "number"
+	// CoerceBoolean parses strings with strconv.ParseBool, and also accepts
+	// "on" and "off", as sent by HTML checkboxes.
+	CoerceBoolean CoerceType = "boolean"
+	// CoerceString leaves strings as they are and turns numbers and
+	// booleans into their JSON text.
+	CoerceString CoerceType = "string"
+)
+
+// coerce converts the value of the replace command c according to the
+// options.
+func (a *applier) coerce(c *command) error {
+	to, err := a.coerceType(c)
+	if err != nil || to == "" {
+		return err
+	}
+	switch v := c.value.(type) {
+	case string:
+		c.value, err = a.coerceString(v, to)
+		return err
+	case bool, float64, json.Number:
+		if to == CoerceString {
+			b, _ := marshal(v)
+			c.value = string(b)
+		}
+	}
+	return nil
+}
+
+// coerceType returns the type the value of the replace command c is to be
+// coerced to: the one Options.CoerceTypes gives for its path, or else the
+// type of the number or boolean it replaces when Options.Coerce is set.
+func (a *applier) coerceType(c *command) (CoerceType, error) {
+	if len(a.opts.CoerceTypes) > 0 {
+		path := pointer.Pointer(c.path)
+		if to, ok := a.opts.CoerceTypes[path.String()]; ok {
+			return to, nil
+		}
+		// the most specific pattern, with the fewest "*", wins
+		patterns := make([]string, 0, len(a.opts.CoerceTypes))
+		for p := range a.opts.CoerceTypes {
+			if strings.Contains(p, "*") {
+				patterns = append(patterns, p)
+			}
+		}
+		sort.Slice(patterns, func(i, j int) bool {
+			wi, wj := strings.Count(patterns[i], "*"), strings.Count(patterns[j], "*")
+			if wi != wj {
+				return wi < wj
+			}
+			return patterns[i] < patterns[j]
+		})
+		for _, p := range patterns {
+			pattern, err := pointer.Parse(p)
+			if err != nil {
+				return "", err
+			}
+			if len(pattern) == len(path) && matchPrefix(pattern, path) {
+				return a.opts.CoerceTypes[p], nil
+			}
+		}
+	}
+	if !a.opts.Coerce {
+		return "", nil
+	}
+	prior, _ := c.prior(false)
+	switch prior.(type) {
+	case float64, json.Number:
+		return CoerceNumber, nil
+	case bool:
+		return CoerceBoolean, nil
+	}
+	return "", nil
+}
+
+func (a *applier) coerceString(s string, to CoerceType) (interface{}, error) {
+	switch to {
+	case CoerceString:
+		return s, nil
+	case CoerceNumber:
+		var n interface{}
+		if err := unmarshalNumber([]byte(strings.TrimSpace(s)), &n); err == nil {
+			if n, ok := n.(json.Number); ok {
+				if a.useNumber || a.opts.UseNumber {
+					return n, nil
+				}
+				return n.Float64()
+			}
+		}
+	case CoerceBoolean:
+		switch s = strings.TrimSpace(s); s {
+		case "on":
+			return true, nil
+		case "off":
+			return false, nil
+		}
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown coercion type %q", to)
+	}
+	return nil, fmt.Errorf("cannot coerce %q to a %s", s, to)
+}
@@ -0,0 +1,65 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCoerce(t *testing.T) {
+	doc := `{"n": 1, "b": false, "s": "x", "null": null, "list": [1, true], "form": {"age": 30, "zip": "01234", "tags": "a"}}`
+	ops := parseStr(`[
+		{"op": "replace", "path": "/n", "value": " 3.5 "},
+		{"op": "replace", "path": "/b", "value": "on"},
+		{"op": "replace", "path": "/s", "value": "4"},
+		{"op": "replace", "path": "/null", "value": "5"},
+		{"op": "replace", "path": "/list/0", "value": "-2"},
+		{"op": "replace", "path": "/list/1", "value": "0"},
+		{"op": "add", "path": "/added", "value": "6"},
+		{"op": "replace", "path": "/form/age", "value": "31"},
+		{"op": "replace", "path": "/form/zip", "value": 98765},
+		{"op": "replace", "path": "/form/tags", "value": "7"}
+	]`)
+	result, err := Apply(decode(doc), ops, WithCoerce(map[string]CoerceType{
+		"/form/zip": CoerceString,
+		"/*/tags":   CoerceNumber,
+		"/form/*":   CoerceString,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"n": 3.5, "b": true, "s": "4", "null": "5", "list": []interface{}{-2.0, false}, "added": "6",
+		"form": map[string]interface{}{"age": "31", "zip": "98765", "tags": 7.0},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+
+	// numbers keep their precision with UseNumber
+	result, err = Apply(decode(`{"n": 1}`), parseStr(`[{"op": "replace", "path": "/n", "value": "12345678901234567890"}]`),
+		WithCoerce(nil), WithUseNumber())
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"n": json.Number("12345678901234567890")}) {
+		t.Errorf("unexpected result %v %v", result, err)
+	}
+
+	// without the option, or without a rule, strings stay strings
+	result, _ = Apply(decode(`{"n": 1}`), parseStr(`[{"op": "replace", "path": "/n", "value": "2"}]`),
+		WithOptions(Options{CoerceTypes: map[string]CoerceType{"/m": CoerceNumber}}))
+	if !reflect.DeepEqual(result, map[string]interface{}{"n": "2"}) {
+		t.Errorf("unexpected result %v", result)
+	}
+
+	for _, p := range []string{
+		`[{"op": "replace", "path": "/n", "value": "three"}]`,
+		`[{"op": "replace", "path": "/n", "value": "0x10"}]`,
+		`[{"op": "replace", "path": "/b", "value": "yes"}]`,
+	} {
+		_, err := Apply(decode(doc), parseStr(p), WithCoerce(nil))
+		var pathErr *PathError
+		if !errors.As(err, &pathErr) {
+			t.Errorf("%s: expected a PathError, got %v", p, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// CompactPatch is a wire encoding of a patch in which values repeated across
+// operations are stored once in a table and referenced by index:
+//
+//	{
+//	  "values": [{"enabled": true, "limits": {...}}],
+//	  "patch": [
+//	    {"op": "add", "path": "/a/config", "valueRef": 0},
+//	    {"op": "add", "path": "/b/config", "valueRef": 0}
+//	  ]
+//	}
+type CompactPatch struct {
+	Values []json.RawMessage  `json:"values"`
+	Patch  []CompactOperation `json:"patch"`
+}
+
+// CompactOperation is an Operation whose value may be a reference into the
+// value table of a CompactPatch.
+type CompactOperation struct {
+	Op       string          `json:"op"`
+	Path     string          `json:"path"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	ValueRef *int            `json:"valueRef,omitempty"`
+	From     string          `json:"from,omitempty"`
+}
+
+// Compact moves every value of at least minSize bytes that occurs more than
+// once in operations into a shared table. Values are considered identical
+// when their compacted JSON text is.
+func Compact(operations []Operation, minSize int) (*CompactPatch, error) {
+	keys := make([]string, len(operations))
+	counts := make(map[string]int)
+	for i, op := range operations {
+		if len(op.Value) < minSize {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, op.Value); err != nil {
+			return nil, fmt.Errorf("operation %d: %v", i, err)
+		}
+		if buf.Len() < minSize {
+			continue
+		}
+		keys[i] = buf.String()
+		counts[keys[i]]++
+	}
+
+	p := &CompactPatch{
+		Values: make([]json.RawMessage, 0),
+		Patch:  make([]CompactOperation, len(operations)),
+	}
+	refs := make(map[string]int)
+	for i, op := range operations {
+		c := CompactOperation{Op: op.Op, Path: op.Path, From: op.From, Value: op.Value}
+		if k := keys[i]; k != "" && counts[k] > 1 {
+			ref, ok := refs[k]
+			if !ok {
+				ref = len(p.Values)
+				refs[k] = ref
+				p.Values = append(p.Values, json.RawMessage(k))
+			}
+			c.Value = nil
+			c.ValueRef = &ref
+		}
+		p.Patch[i] = c
+	}
+	return p, nil
+}
+
+// Expand resolves the value references of p and returns the plain patch.
+func (p *CompactPatch) Expand() ([]Operation, error) {
+	ops := make([]Operation, len(p.Patch))
+	for i, c := range p.Patch {
+		ops[i] = Operation{Op: c.Op, Path: c.Path, From: c.From, Value: c.Value}
+		if c.ValueRef == nil {
+			continue
+		}
+		if c.Value != nil {
+			return nil, fmt.Errorf("operation %d has both a value and a valueRef", i)
+		}
+		if *c.ValueRef < 0 || *c.ValueRef >= len(p.Values) {
+			return nil, fmt.Errorf("operation %d references unknown value %d", i, *c.ValueRef)
+		}
+		ops[i].Value = p.Values[*c.ValueRef]
+	}
+	return ops, nil
+}
+
+// MarshalCompact encodes operations as a CompactPatch.
+func MarshalCompact(operations []Operation, minSize int) ([]byte, error) {
+	p, err := Compact(operations, minSize)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(p)
+}
+
+// ParseCompact decodes a patch encoded as a CompactPatch.
+func ParseCompact(data []byte) ([]Operation, error) {
+	var p CompactPatch
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return p.Expand()
+}
@@ -0,0 +1,56 @@
+package patch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompactRoundTrip(t *testing.T) {
+	ops := parseStr(`[
+		{"op": "add", "path": "/a", "value": {"enabled": true, "limit": 10}},
+		{"op": "add", "path": "/b", "value": {"enabled":true,"limit":10}},
+		{"op": "add", "path": "/c", "value": 1},
+		{"op": "add", "path": "/d", "value": 1},
+		{"op": "replace", "path": "/c", "value": {"enabled": true, "limit": 10}},
+		{"op": "add", "path": "/f", "value": {"unique": "value"}},
+		{"op": "move", "from": "/a", "path": "/g"}
+	]`)
+	data, err := MarshalCompact(ops, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), `"limit"`); n != 1 {
+		t.Errorf("expected the repeated value to be stored once, found %d copies in %s", n, data)
+	}
+	if strings.Count(string(data), `"valueRef"`) != 3 {
+		t.Errorf("expected three references in %s", data)
+	}
+	expanded, err := ParseCompact(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := map[string]interface{}{}
+	want, err := Apply(doc, ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Apply(doc, expanded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestParseCompactErrors(t *testing.T) {
+	for _, s := range []string{
+		`{"values": [], "patch": [{"op": "add", "path": "/a", "valueRef": 0}]}`,
+		`{"values": [1], "patch": [{"op": "add", "path": "/a", "value": 1, "valueRef": 0}]}`,
+	} {
+		if _, err := ParseCompact([]byte(s)); err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package patch
+
+// CompiledPatch is a patch whose operators, pointers and values have been
+// validated and decoded once, so that applying it to many documents only
+// costs the traversal of each document. A CompiledPatch is safe for
+// concurrent use.
+type CompiledPatch struct {
+	ins []*instruction
+}
+
+// Compile prepares operations for repeated application, failing if any of
+// them uses an unknown operator, lacks a required value or has a malformed
+// pointer.
+func Compile(operations []Operation) (*CompiledPatch, error) {
+	a := &applier{opts: &Options{}}
+	p := &CompiledPatch{ins: make([]*instruction, len(operations))}
+	for i, op := range operations {
+		ins, err := a.compile(i, op)
+		if err != nil {
+			return nil, err
+		}
+		ins.shared = true
+		p.ins[i] = ins
+	}
+	return p, nil
+}
+
+// Apply applies the patch to a deep copy of doc and returns the result.
+func (p *CompiledPatch) Apply(doc interface{}) (interface{}, error) {
+	return p.ApplyUnsafe(deepCopy(doc))
+}
+
+// ApplyUnsafe applies the patch directly to doc, with the same caveats as
+// the package level ApplyUnsafe.
+func (p *CompiledPatch) ApplyUnsafe(doc interface{}) (interface{}, error) {
+	a := &applier{opts: &Options{}}
+	var err error
+	for i, ins := range p.ins {
+		if doc, err = a.exec(doc, i, ins); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
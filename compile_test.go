@@ -0,0 +1,60 @@
+package patch
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCompiledPatch(t *testing.T) {
+	p, err := Compile(parseStr(`[
+		{"op": "add", "path": "/defaults", "value": {"enabled": true}},
+		{"op": "copy", "from": "/defaults", "path": "/copy"},
+		{"op": "add", "path": "/list/-", "value": {"n": 1}}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	results := make([]interface{}, 8)
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			doc := map[string]interface{}{"list": []interface{}{}}
+			results[i], errs[i] = p.Apply(doc)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// values must not be shared between results
+	results[0].(map[string]interface{})["defaults"].(map[string]interface{})["enabled"] = false
+	expected := map[string]interface{}{
+		"defaults": map[string]interface{}{"enabled": true},
+		"copy":     map[string]interface{}{"enabled": true},
+		"list":     []interface{}{map[string]interface{}{"n": 1.0}},
+	}
+	for _, r := range results[1:] {
+		if !reflect.DeepEqual(r, expected) {
+			t.Errorf("expected %v, got %v", expected, r)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, ops := range [][]Operation{
+		parseStr(`[{"op": "frobnicate", "path": "/a"}]`),
+		{{Op: "add", Path: "/a"}},
+		parseStr(`[{"op": "add", "path": "a", "value": 1}]`),
+		parseStr(`[{"op": "move", "from": "a", "path": "/a"}]`),
+	} {
+		if _, err := Compile(ops); err == nil {
+			t.Errorf("%v: expected an error", ops)
+		}
+	}
+}
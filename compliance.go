@@ -0,0 +1,174 @@
+package patch
+
+import (
+	"slices"
+	"sort"
+)
+
+// Compliance reports how Apply behaves under a set of options: which
+// requirements of RFC 6902 and RFC 7386 it meets, and which extensions and
+// restrictions are in effect. It is meant for tests asserting that a
+// deployed configuration keeps the promises made about it:
+//
+//	c := patch.ComplianceReport(deployedOptions...)
+//	if !c.Compliant() {
+//		t.Errorf("not RFC 6902 compliant: %v", c.Violations())
+//	}
+type Compliance struct {
+	Requirements []Requirement `json:"requirements"`
+	// Extensions lists the enabled features changing the meaning of
+	// patches RFC 6902 defines, or giving one to patches it refuses, such
+	// as "wildcards" or "create-missing-parents", in sorted order.
+	Extensions []string `json:"extensions"`
+	// Restrictions lists the enabled features refusing patches RFC 6902
+	// accepts, such as "limits" or "authorize", in sorted order.
+	Restrictions []string `json:"restrictions"`
+}
+
+// Requirement is a requirement of an RFC and whether Apply meets it.
+type Requirement struct {
+	ID      string `json:"id"`      // such as "rfc6902/4.1-parent-exists"
+	Section string `json:"section"` // such as "RFC 6902 section 4.1"
+	Text    string `json:"text"`
+	Met     bool   `json:"met"`
+	// Reason names the options breaking the requirement when it is not met.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ComplianceReport reports the compliance of Apply with opts, given as they
+// would be to Apply. Operators registered with RegisterOperator count as an
+// extension.
+func ComplianceReport(opts ...Option) *Compliance {
+	o := newOptions(opts)
+	c := &Compliance{Extensions: []string{}, Restrictions: []string{}}
+	requirement := func(id, section, text string, broken ...string) {
+		r := Requirement{ID: id, Section: section, Text: text, Met: true}
+		for _, b := range broken {
+			if b != "" {
+				r.Met = false
+				if r.Reason != "" {
+					r.Reason += ", "
+				}
+				r.Reason += b
+			}
+		}
+		c.Requirements = append(c.Requirements, r)
+	}
+	when := func(cond bool, reason string) string {
+		if cond {
+			return reason
+		}
+		return ""
+	}
+
+	registryMu.RLock()
+	registered := len(registry) > 0
+	registryMu.RUnlock()
+
+	requirement("rfc6902/3-sequential", "RFC 6902 section 3",
+		"operations are applied sequentially in the order they appear")
+	requirement("rfc6902/4-pointers", "RFC 6902 section 4",
+		"path and from are JSON pointers (RFC 6901)",
+		when(o.Wildcards, "Wildcards"),
+		when(o.JSONPath, "JSONPath"),
+		when(o.FollowRefs, "FollowRefs"),
+		when(o.NegativeIndices, "NegativeIndices"),
+		when(o.IndexResolver != nil, "IndexResolver"),
+		when(o.Anchor != "", "Anchor"))
+	requirement("rfc6902/4-unknown-op", "RFC 6902 section 4",
+		"an operation with an unknown op is an error",
+		when(o.UnknownOps != UnknownOpReject, "UnknownOps"),
+		when(len(o.Operators) > 0, "Operators"),
+		when(registered, "RegisterOperator"))
+	requirement("rfc6902/4.1-parent-exists", "RFC 6902 section 4.1",
+		"the target location of add must have an existing parent",
+		when(o.CreateMissingParents, "CreateMissingParents"),
+		when(len(o.Defaulters) > 0, "Defaulters"))
+	requirement("rfc6902/4.3-replace-value", "RFC 6902 section 4.3",
+		"replace sets the target to the given value",
+		when(o.Coerce || len(o.CoerceTypes) > 0, "Coerce"))
+	requirement("rfc6902/4.4-move-index", "RFC 6902 section 4.4",
+		"the destination of a move is located after its source is removed",
+		when(o.MoveIndex == MoveBeforeRemove, "MoveIndex"))
+	requirement("rfc6902/4.5-copy-from", "RFC 6902 section 4.5",
+		"copy copies the value at from in the document",
+		when(o.OpRefs, "OpRefs"))
+	requirement("rfc6902/4.6-test-equality", "RFC 6902 section 4.6",
+		"test compares values by JSON equality",
+		when(o.Equaler != nil, "Equaler"))
+	requirement("rfc6902/5-atomic", "RFC 6902 section 5",
+		"a patch with a failing operation is not applied",
+		when(o.ContinueOnError, "ContinueOnError"),
+		when(o.OnErrorHints, "OnErrorHints"))
+	requirement("rfc7386/2-null-removes", "RFC 7386 section 2",
+		"a null member of a merge patch removes the member (MergePatch)")
+	requirement("rfc7386/2-objects-merge", "RFC 7386 section 2",
+		"objects of a merge patch are merged recursively and other values replace the target (MergePatch)")
+
+	extension := func(cond bool, name string) {
+		if cond {
+			c.Extensions = append(c.Extensions, name)
+		}
+	}
+	extension(o.Wildcards, "wildcards")
+	extension(o.JSONPath, "json-path")
+	extension(o.FollowRefs, "follow-refs")
+	extension(o.NegativeIndices, "negative-indices")
+	extension(o.IndexResolver != nil, "index-resolver")
+	extension(o.Anchor != "", "relative-pointers")
+	extension(o.UnknownOps == UnknownOpSkip, "skip-unknown-ops")
+	extension(o.UnknownOps == UnknownOpDispatch, "unknown-operator")
+	extension(len(o.Operators) > 0 || registered, "custom-operators")
+	extension(o.CreateMissingParents, "create-missing-parents")
+	extension(len(o.Defaulters) > 0, "defaulters")
+	extension(o.Coerce || len(o.CoerceTypes) > 0, "coerce")
+	extension(o.MoveIndex == MoveBeforeRemove, "move-before-remove")
+	extension(o.OpRefs, "op-refs")
+	extension(o.Equaler != nil, "custom-equality")
+	extension(o.ContinueOnError, "continue-on-error")
+	extension(o.OnErrorHints, "on-error-hints")
+	extension(o.UTF8 == UTF8Replace, "utf8-replace")
+
+	restriction := func(cond bool, name string) {
+		if cond {
+			c.Restrictions = append(c.Restrictions, name)
+		}
+	}
+	restriction(o.UTF8 == UTF8Reject, "utf8-reject")
+	restriction(o.MoveIndex == MoveRejectAmbiguous, "move-reject-ambiguous")
+	restriction(o.Limits != Limits{}, "limits")
+	restriction(o.Policy != nil, "policy")
+	restriction(o.Scope != "", "scope")
+	restriction(o.Authorize != nil, "authorize")
+	restriction(o.BeforeOp != nil, "before-op")
+	restriction(o.Quota != nil, "quota")
+	restriction(o.StructValidator != nil, "struct-validator")
+	restriction(o.Schema != nil, "schema")
+
+	sort.Strings(c.Extensions)
+	sort.Strings(c.Restrictions)
+	return c
+}
+
+// Compliant reports whether every requirement is met. Restrictions do not
+// count against compliance, since RFC 6902 lets an implementation refuse
+// patches it is not willing to apply.
+func (c *Compliance) Compliant() bool {
+	return len(c.Violations()) == 0
+}
+
+// Violations returns the requirements that are not met.
+func (c *Compliance) Violations() []Requirement {
+	var out []Requirement
+	for _, r := range c.Requirements {
+		if !r.Met {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Enabled reports whether the extension or restriction name is in effect.
+func (c *Compliance) Enabled(name string) bool {
+	return slices.Contains(c.Extensions, name) || slices.Contains(c.Restrictions, name)
+}
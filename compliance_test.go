@@ -0,0 +1,43 @@
+package patch
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestComplianceReport(t *testing.T) {
+	c := ComplianceReport(Hardened(), WithPolicy(&Policy{}))
+	for _, r := range c.Violations() {
+		if r.ID != "rfc6902/4-unknown-op" || r.Reason != "RegisterOperator" {
+			t.Errorf("unexpected violation %+v", r)
+		}
+	}
+	if !c.Enabled("limits") || !c.Enabled("policy") || !c.Enabled("utf8-reject") || c.Enabled("wildcards") {
+		t.Errorf("unexpected restrictions %v and extensions %v", c.Restrictions, c.Extensions)
+	}
+
+	c = ComplianceReport(Lenient(), WithWildcards(), WithMoveIndex(MoveBeforeRemove))
+	if c.Compliant() {
+		t.Error("expected the lenient options not to be compliant")
+	}
+	violated := map[string]string{}
+	for _, r := range c.Violations() {
+		violated[r.ID] = r.Reason
+	}
+	for id, reason := range map[string]string{
+		"rfc6902/4-pointers":        "Wildcards",
+		"rfc6902/4.1-parent-exists": "CreateMissingParents",
+		"rfc6902/4.4-move-index":    "MoveIndex",
+		"rfc6902/5-atomic":          "ContinueOnError",
+	} {
+		if violated[id] != reason {
+			t.Errorf("expected %s to be broken by %s, got %q", id, reason, violated[id])
+		}
+	}
+	if !c.Enabled("continue-on-error") || !c.Enabled("skip-unknown-ops") || !c.Enabled("utf8-replace") {
+		t.Errorf("unexpected extensions %v", c.Extensions)
+	}
+	if _, err := json.Marshal(c); err != nil {
+		t.Error(err)
+	}
+}
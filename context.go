@@ -0,0 +1,60 @@
+package patch
+
+import (
+	"context"
+	"fmt"
+)
+
+// ApplyContext is Apply for servers that bound the time spent on a patch:
+// ctx is checked before each operation, and before each location matched
+// by a wildcard or JSONPath, and once it is done the patch is abandoned
+// with an error matching ctx.Err(). o is left unchanged, unless WithInPlace
+// is given, in which case it is left as ApplyUnsafe leaves it after a
+// failure.
+func ApplyContext(ctx context.Context, o interface{}, operations []Operation, opts ...Option) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	options := newOptions(opts)
+	if !options.InPlace {
+		o = deepCopy(o)
+	}
+	a := &applier{opts: options, ctx: ctx}
+	return a.apply(o, operations)
+}
+
+// interrupted returns an error for the i-th operation once the context of
+// ApplyContext is done. Operations skipped by ContinueOnError do not
+// include it: the patch stops.
+func (a *applier) interrupted(i int) error {
+	if a.ctx == nil {
+		return nil
+	}
+	if err := a.ctx.Err(); err != nil {
+		return fmt.Errorf("operation %d: %w", i, err)
+	}
+	return nil
+}
+
+// CreatePatchContext is CreatePatchWithOptions checking ctx for
+// cancellation periodically while diffing, so that large documents can be
+// abandoned with an error matching ctx.Err().
+func CreatePatchContext(ctx context.Context, original, modified interface{}, opts DiffOptions) ([]Operation, error) {
+	d := &differ{ops: make([]Operation, 0), opts: opts, ctx: ctx}
+	if err := d.interrupted(); err != nil {
+		return nil, err
+	}
+	if err := d.run(original, modified); err != nil {
+		return nil, err
+	}
+	return d.ops, nil
+}
+
+// interrupted returns the error of the context of CreatePatchContext once
+// it is done.
+func (d *differ) interrupted() error {
+	if d.ctx == nil {
+		return nil
+	}
+	return d.ctx.Err()
+}
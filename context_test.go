@@ -0,0 +1,83 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestApplyContext(t *testing.T) {
+	doc := decode(`{"a": 1}`)
+	result, err := ApplyContext(context.Background(), doc, parseStr(`[{"op": "add", "path": "/b", "value": 2}]`))
+	if err != nil || !reflect.DeepEqual(result, decode(`{"a": 1, "b": 2}`)) {
+		t.Fatalf("unexpected result %v, %v", result, err)
+	}
+
+	var cancel context.CancelFunc
+	ops := parseStr(`[
+		{"op": "add", "path": "/b", "value": 2},
+		{"op": "add", "path": "/c", "value": 3},
+		{"op": "add", "path": "/d", "value": 4}
+	]`)
+	applied := 0
+	cancelAfterFirst := WithAfterOp(func(Operation, interface{}, interface{}) {
+		if applied++; applied == 1 {
+			cancel()
+		}
+	})
+	for _, opts := range [][]Option{
+		{cancelAfterFirst},
+		{cancelAfterFirst, WithContinueOnError()},
+	} {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		applied = 0
+		_, err := ApplyContext(ctx, doc, ops, opts...)
+		if !errors.Is(err, context.Canceled) || applied != 1 {
+			t.Errorf("expected the patch to stop after one operation, got %d applied, %v", applied, err)
+		}
+		if err == nil || err.Error() != "operation 1: context canceled" {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+	if !reflect.DeepEqual(doc, decode(`{"a": 1}`)) {
+		t.Errorf("the document was modified: %v", doc)
+	}
+
+	applied = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = ApplyContext(ctx, decode(`{"list": [1, 2, 3]}`), parseStr(`[{"op": "replace", "path": "/list/*", "value": 0}]`), WithWildcards(), cancelAfterFirst)
+	if !errors.Is(err, context.Canceled) || applied != 1 {
+		t.Errorf("expected the wildcard to stop after one location, got %d applied, %v", applied, err)
+	}
+
+	if _, err := ApplyContext(ctx, doc, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a canceled context to be reported, got %v", err)
+	}
+}
+
+func TestCreatePatchContext(t *testing.T) {
+	a := make([]interface{}, 2000)
+	b := make([]interface{}, 2000)
+	for i := range a {
+		a[i] = map[string]interface{}{"n": float64(i)}
+		b[i] = map[string]interface{}{"n": float64(i + 1)}
+	}
+	ops, err := CreatePatchContext(context.Background(), a, b, DiffOptions{})
+	if err != nil || len(ops) == 0 {
+		t.Fatalf("unexpected patch %v, %v", len(ops), err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := CreatePatchContext(ctx, a, b, DiffOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the diff to be canceled, got %v", err)
+	}
+
+	// the context is also checked while aligning large arrays
+	d := &differ{ctx: ctx}
+	if _, err := alignArrays(d, a, b, a, b, jsonEqual); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the alignment to be canceled, got %v", err)
+	}
+}
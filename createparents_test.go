@@ -0,0 +1,53 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCreateMissingParents(t *testing.T) {
+	opts := WithCreateMissingParents()
+	for _, tc := range []struct {
+		doc, patch, expected string
+	}{
+		{`{}`, `[{"op": "add", "path": "/a/b/c", "value": 1}]`, `{"a": {"b": {"c": 1}}}`},
+		{`{"a": {"x": 1}}`, `[{"op": "add", "path": "/a/b/c", "value": 1}]`, `{"a": {"x": 1, "b": {"c": 1}}}`},
+		{`{}`, `[{"op": "add", "path": "/a/0/b", "value": 1}]`, `{"a": {"0": {"b": 1}}}`},
+		{`{"a": [{}]}`, `[{"op": "add", "path": "/a/0/b/c", "value": 1}]`, `{"a": [{"b": {"c": 1}}]}`},
+		{`{"a": []}`, `[{"op": "add", "path": "/a/-", "value": 1}]`, `{"a": [1]}`},
+	} {
+		result, _, err := ApplyWithReport(decode(tc.doc), parseStr(tc.patch), opts)
+		if err != nil {
+			t.Errorf("%s: %v", tc.patch, err)
+			continue
+		}
+		if expected := decode(tc.expected); !reflect.DeepEqual(result, expected) {
+			t.Errorf("%s: expected %v, got %v", tc.patch, expected, result)
+		}
+	}
+
+	for _, tc := range []struct{ doc, patch string }{
+		{`{"a": []}`, `[{"op": "add", "path": "/a/0/b", "value": 1}]`},
+		{`{"a": []}`, `[{"op": "add", "path": "/a/-/b", "value": 1}]`},
+		{`{"a": null}`, `[{"op": "add", "path": "/a/b", "value": 1}]`},
+		{`{"a": 1}`, `[{"op": "add", "path": "/a/b", "value": 1}]`},
+	} {
+		if _, _, err := ApplyWithReport(decode(tc.doc), parseStr(tc.patch), opts); err == nil {
+			t.Errorf("%s on %s: expected an error", tc.patch, tc.doc)
+		}
+	}
+
+	if _, err := Apply(decode(`{}`), parseStr(`[{"op": "add", "path": "/a/b", "value": 1}]`)); err == nil {
+		t.Error("expected parents not to be created by default")
+	}
+
+	a := &applier{opts: newOptions([]Option{opts}), undo: &undoLog{}}
+	doc := decode(`{"a": {}}`)
+	result, err := a.apply(deepCopy(doc), parseStr(`[{"op": "add", "path": "/a/b/c/d", "value": 1}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored, err := Apply(result, a.undo.ops()); err != nil || !reflect.DeepEqual(restored, doc) {
+		t.Errorf("inverse did not remove the created parents: %v %v", restored, err)
+	}
+}
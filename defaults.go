@@ -0,0 +1,78 @@
+package patch
+
+import (
+	"sort"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// DefaulterFunc fills in defaults for an object or array that an add
+// operation is about to create at ptr, and returns the value to store
+// instead. It may modify value in place.
+type DefaulterFunc func(ptr string, value interface{}) (interface{}, error)
+
+type defaulter struct {
+	pattern pointer.Pointer
+	fn      DefaulterFunc
+}
+
+// defaulters returns the configured defaulters, shortest pattern first.
+func (a *applier) defaulters() ([]defaulter, error) {
+	if a.defaults != nil || len(a.opts.Defaulters) == 0 {
+		return a.defaults, nil
+	}
+	for pattern, fn := range a.opts.Defaulters {
+		p, err := pointer.Parse(pattern)
+		if err != nil {
+			return nil, err
+		}
+		a.defaults = append(a.defaults, defaulter{p, fn})
+	}
+	sort.Slice(a.defaults, func(i, j int) bool {
+		pi, pj := a.defaults[i].pattern, a.defaults[j].pattern
+		if len(pi) != len(pj) {
+			return len(pi) < len(pj)
+		}
+		return pi.String() < pj.String()
+	})
+	return a.defaults, nil
+}
+
+// applyDefaults runs the defaulters whose pattern matches the location where
+// the add operation c creates an object or array.
+func (a *applier) applyDefaults(c *command) error {
+	switch c.value.(type) {
+	case map[string]interface{}, []interface{}:
+	default:
+		return nil
+	}
+	defaults, err := a.defaulters()
+	if err != nil || len(defaults) == 0 {
+		return err
+	}
+	ptr := concreteIndex(c)
+	target, _ := pointer.Parse(ptr)
+	for _, d := range defaults {
+		if !matchPrefix(d.pattern, target) {
+			continue
+		}
+		if c.value, err = d.fn(ptr, c.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchPrefix reports whether pattern is a prefix of p, where the pattern
+// token "*" matches any token.
+func matchPrefix(pattern, p pointer.Pointer) bool {
+	if len(pattern) > len(p) {
+		return false
+	}
+	for i, t := range pattern {
+		if t != "*" && t != p[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDefaulters(t *testing.T) {
+	var calls []string
+	opts := WithOptions(Options{Defaulters: map[string]DefaulterFunc{
+		"/spec/containers/*": func(ptr string, v interface{}) (interface{}, error) {
+			calls = append(calls, ptr)
+			m := v.(map[string]interface{})
+			if _, ok := m["pullPolicy"]; !ok {
+				m["pullPolicy"] = "IfNotPresent"
+			}
+			return m, nil
+		},
+		"/spec": func(ptr string, v interface{}) (interface{}, error) {
+			calls = append(calls, "spec "+ptr)
+			return v, nil
+		},
+	}})
+	doc := decode(`{"spec": {"containers": [{"name": "a"}]}}`)
+	ops := parseStr(`[
+		{"op": "add", "path": "/spec/containers/-", "value": {"name": "b"}},
+		{"op": "add", "path": "/spec/containers/0", "value": {"name": "c", "pullPolicy": "Always"}},
+		{"op": "add", "path": "/spec/containers/0/name", "value": "scalar values are not defaulted"},
+		{"op": "add", "path": "/status", "value": {}}
+	]`)
+	result, _, err := ApplyWithReport(doc, ops, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := decode(`{"spec": {"containers": [
+		{"name": "scalar values are not defaulted", "pullPolicy": "Always"},
+		{"name": "a"},
+		{"name": "b", "pullPolicy": "IfNotPresent"}
+	]}, "status": {}}`)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	expectedCalls := []string{
+		"spec /spec/containers/1", "/spec/containers/1",
+		"spec /spec/containers/0", "/spec/containers/0",
+	}
+	if !reflect.DeepEqual(calls, expectedCalls) {
+		t.Errorf("expected calls %v, got %v", expectedCalls, calls)
+	}
+}
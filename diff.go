@@ -0,0 +1,381 @@
+package patch
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// maxLCSCells bounds the size of the table used to align array elements.
+// Arrays whose differing middle sections are larger than this are diffed
+// position by position instead.
+const maxLCSCells = 1 << 22
+
+// CreatePatch returns a list of operations that, applied in order to
+// original, produce modified. Both documents must be made of the values
+// produced by encoding/json (maps, slices, strings, numbers, booleans, nil).
+func CreatePatch(original, modified interface{}) ([]Operation, error) {
+	return CreatePatchWithOptions(original, modified, DiffOptions{})
+}
+
+// DiffOptions controls how differences are expressed by
+// CreatePatchWithOptions. By default, a member missing from the modified
+// document is removed, and a member whose value became null is replaced with
+// null, keeping the two cases distinct.
+type DiffOptions struct {
+	// RemovedAsNull expresses a member missing from the modified document
+	// as a replace with null, for consumers that treat null as absence.
+	RemovedAsNull bool
+	// NullAsRemoved expresses a member whose value is null in the modified
+	// document as a remove (or nothing, if it was absent), for consumers
+	// that do not store nulls.
+	NullAsRemoved bool
+	// DetectMoves expresses values relocated within the document as move
+	// and copy operations, found by hashing the values: array elements
+	// moved within their array, members removed from an object and added
+	// elsewhere, and objects and arrays duplicated from a location left
+	// unchanged. Members are only moved and values copied from locations
+	// reached through object members alone, whose pointers no operation
+	// shifts; the removals of the members that could be moved come last.
+	DetectMoves bool
+}
+
+// CreatePatchWithOptions is CreatePatch with control over how absent and
+// null object members are expressed.
+func CreatePatchWithOptions(original, modified interface{}, opts DiffOptions) ([]Operation, error) {
+	d := &differ{ops: make([]Operation, 0), opts: opts}
+	if err := d.run(original, modified); err != nil {
+		return nil, err
+	}
+	return d.ops, nil
+}
+
+// CreatePatchBytes is like CreatePatch for JSON encoded documents, and
+// returns the patch encoded as JSON. Numbers are decoded as json.Number, so
+// they are compared and written out exactly.
+func CreatePatchBytes(original, modified []byte) ([]byte, error) {
+	var a, b interface{}
+	if err := unmarshalNumber(original, &a); err != nil {
+		return nil, err
+	}
+	if err := unmarshalNumber(modified, &b); err != nil {
+		return nil, err
+	}
+	ops, err := CreatePatch(a, b)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(ops)
+}
+
+type differ struct {
+	ops  []Operation
+	opts DiffOptions
+	// moves holds the values the patch can move or copy with DetectMoves
+	moves *relocations
+	// ctx is checked for cancellation every few values diffed, and for
+	// every row of the tables aligning arrays, by CreatePatchContext
+	ctx    context.Context
+	visits int
+}
+
+// run diffs original and modified.
+func (d *differ) run(original, modified interface{}) error {
+	if d.opts.DetectMoves {
+		d.moves = newRelocations(original, modified, d.opts)
+	}
+	if err := d.diff("", original, modified); err != nil {
+		return err
+	}
+	if d.moves != nil {
+		return d.removeUnmoved()
+	}
+	return nil
+}
+
+func (d *differ) emit(op, path string, value interface{}) error {
+	if (op == "add" || op == "replace") && d.relocate(path, value) {
+		return nil
+	}
+	o := Operation{Op: op, Path: path}
+	if op != "remove" {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		o.Value = raw
+	}
+	d.ops = append(d.ops, o)
+	return nil
+}
+
+func (d *differ) diff(path string, a, b interface{}) error {
+	if d.visits++; d.visits%1024 == 0 {
+		if err := d.interrupted(); err != nil {
+			return err
+		}
+	}
+	if o, ok := a.(*SortedObject); ok {
+		a = o.Map()
+	}
+	if o, ok := b.(*SortedObject); ok {
+		b = o.Map()
+	}
+	switch av := a.(type) {
+	case map[string]interface{}:
+		if bv, ok := b.(map[string]interface{}); ok {
+			return d.diffObject(path, av, bv)
+		}
+	case []interface{}:
+		if bv, ok := b.([]interface{}); ok {
+			return d.diffArray(path, av, bv)
+		}
+	}
+	if jsonEqual(a, b) {
+		return nil
+	}
+	return d.emit("replace", path, b)
+}
+
+func (d *differ) diffObject(path string, a, b map[string]interface{}) error {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := path + "/" + pointer.Escape(k)
+		av, inA := a[k]
+		bv, inB := b[k]
+		var err error
+		switch {
+		case !inB && d.opts.RemovedAsNull:
+			if av != nil {
+				err = d.emit("replace", p, nil)
+			}
+		case !inB:
+			if d.moves == nil || d.moves.removed[p] == nil {
+				err = d.emit("remove", p, nil)
+			}
+		case bv == nil && d.opts.NullAsRemoved:
+			if inA {
+				err = d.emit("remove", p, nil)
+			}
+		case !inA:
+			err = d.emit("add", p, bv)
+		default:
+			err = d.diff(p, av, bv)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffArray aligns the elements of a and b using their longest common
+// subsequence and emits the inserts and removals needed between the aligned
+// elements. A removal immediately followed by an insert becomes a replace,
+// or a nested diff when both elements are containers of the same kind.
+// Arrays made only of strings, or only of float64, are compared unboxed.
+func (d *differ) diffArray(path string, a, b []interface{}) error {
+	sa, oka := unboxStrings(a)
+	sb, okb := unboxStrings(b)
+	if oka && okb {
+		return diffArrayOf(d, path, a, b, sa, sb, func(x, y string) bool { return x == y })
+	}
+	na, oka := unboxNumbers(a)
+	nb, okb := unboxNumbers(b)
+	if oka && okb {
+		return diffArrayOf(d, path, a, b, na, nb, func(x, y float64) bool { return x == y })
+	}
+	return diffArrayOf(d, path, a, b, a, b, jsonEqual)
+}
+
+// diffArrayOf is diffArray comparing the elements with eq, where ta and tb
+// hold the elements of a and b as a T.
+func diffArrayOf[T any](d *differ, path string, a, b []interface{}, ta, tb []T, eq func(x, y T) bool) error {
+	start := 0
+	for start < len(ta) && start < len(tb) && eq(ta[start], tb[start]) {
+		start++
+	}
+	endA, endB := len(ta), len(tb)
+	for endA > start && endB > start && eq(ta[endA-1], tb[endB-1]) {
+		endA--
+		endB--
+	}
+
+	edits, err := alignArrays(d, a[start:endA], b[start:endB], ta[start:endA], tb[start:endB], eq)
+	if err != nil {
+		return err
+	}
+	if d.moves != nil {
+		if moved, err := d.moveElements(path, start, edits); moved || err != nil {
+			return err
+		}
+	}
+
+	pos := start
+	for i := 0; i < len(edits); {
+		if edits[i].kind == editKeep {
+			pos++
+			i++
+			continue
+		}
+		// collect the run of removals and inserts between two kept elements
+		var removed, inserted []interface{}
+		for ; i < len(edits) && edits[i].kind != editKeep; i++ {
+			if edits[i].kind == editRemove {
+				removed = append(removed, edits[i].value)
+			} else {
+				inserted = append(inserted, edits[i].value)
+			}
+		}
+		paired := len(removed)
+		if len(inserted) < paired {
+			paired = len(inserted)
+		}
+		for j := 0; j < paired; j++ {
+			if err := d.diffElement(path+"/"+strconv.Itoa(pos), removed[j], inserted[j]); err != nil {
+				return err
+			}
+			pos++
+		}
+		for j := paired; j < len(removed); j++ {
+			if err := d.emit("remove", path+"/"+strconv.Itoa(pos), nil); err != nil {
+				return err
+			}
+		}
+		for j := paired; j < len(inserted); j++ {
+			if err := d.emit("add", path+"/"+strconv.Itoa(pos), inserted[j]); err != nil {
+				return err
+			}
+			pos++
+		}
+	}
+	return nil
+}
+
+const (
+	editKeep = iota
+	editRemove
+	editInsert
+)
+
+type edit struct {
+	kind  int
+	value interface{}
+}
+
+// alignArrays returns an edit script turning a into b, comparing their
+// elements as held in ta and tb with eq.
+func alignArrays[T any](d *differ, a, b []interface{}, ta, tb []T, eq func(x, y T) bool) ([]edit, error) {
+	n, m := len(a), len(b)
+	edits := make([]edit, 0, n+m)
+	if n == 0 || m == 0 || n*m > maxLCSCells {
+		for i := 0; i < n || i < m; i++ {
+			if i < n && i < m && eq(ta[i], tb[i]) {
+				edits = append(edits, edit{editKeep, a[i]})
+				continue
+			}
+			if i < n {
+				edits = append(edits, edit{editRemove, a[i]})
+			}
+			if i < m {
+				edits = append(edits, edit{editInsert, b[i]})
+			}
+		}
+		return edits, nil
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		if err := d.interrupted(); err != nil {
+			return nil, err
+		}
+		for j := m - 1; j >= 0; j-- {
+			if eq(ta[i], tb[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case eq(ta[i], tb[j]):
+			edits = append(edits, edit{editKeep, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, edit{editRemove, a[i]})
+			i++
+		default:
+			edits = append(edits, edit{editInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		edits = append(edits, edit{editRemove, a[i]})
+	}
+	for ; j < m; j++ {
+		edits = append(edits, edit{editInsert, b[j]})
+	}
+	return edits, nil
+}
+
+// unboxStrings returns the elements of s as strings, when they all are.
+func unboxStrings(s []interface{}) ([]string, bool) {
+	if len(s) > 0 {
+		if _, ok := s[0].(string); !ok {
+			return nil, false
+		}
+	}
+	out := make([]string, len(s))
+	for i, v := range s {
+		str, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		out[i] = str
+	}
+	return out, true
+}
+
+// unboxNumbers returns the elements of s as float64, when they all are and
+// are finite, as decoded by encoding/json.
+func unboxNumbers(s []interface{}) ([]float64, bool) {
+	if len(s) > 0 {
+		if _, ok := s[0].(float64); !ok {
+			return nil, false
+		}
+	}
+	out := make([]float64, len(s))
+	for i, v := range s {
+		f, ok := v.(float64)
+		if !ok || math.IsInf(f, 0) {
+			return nil, false
+		}
+		out[i] = f
+	}
+	return out, true
+}
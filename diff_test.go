@@ -0,0 +1,142 @@
+package patch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decode(s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func TestCreatePatchRoundTrip(t *testing.T) {
+	cases := []struct{ a, b string }{
+		{`{}`, `{}`},
+		{`{"a": 1}`, `{"a": 2}`},
+		{`{"a": 1, "b": 2}`, `{"b": 2, "c": 3}`},
+		{`{"a/b": 1, "c~d": 2}`, `{"a/b": 3}`},
+		{`{"a": {"b": [1, 2, 3]}}`, `{"a": {"b": [1, 3, 4]}}`},
+		{`[1, 2, 3, 4, 5]`, `[0, 1, 3, 5, 6]`},
+		{`[1, 2, 3]`, `[]`},
+		{`[]`, `[1, 2, 3]`},
+		{`[{"id": 1, "v": "a"}, {"id": 2}]`, `[{"id": 1, "v": "b"}, {"id": 2}, {"id": 3}]`},
+		{`[1, 2, 3]`, `{"a": 1}`},
+		{`"x"`, `null`},
+	}
+	for _, c := range cases {
+		a, b := decode(c.a), decode(c.b)
+		ops, err := CreatePatch(a, b)
+		if err != nil {
+			t.Errorf("%s -> %s: %v", c.a, c.b, err)
+			continue
+		}
+		result, err := Apply(a, ops)
+		if err != nil {
+			t.Errorf("%s -> %s: applying %+v: %v", c.a, c.b, ops, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, b) {
+			t.Errorf("%s -> %s: got %v", c.a, c.b, result)
+		}
+	}
+}
+
+func TestCreatePatchArraysAreNotReplacedWholesale(t *testing.T) {
+	ops, err := CreatePatch(decode(`[1, 2, 3, 4]`), decode(`[1, 2, 9, 3, 4]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := parseStr(`[{"op": "add", "path": "/2", "value": 9}]`)
+	if !reflect.DeepEqual(ops, expected) {
+		t.Errorf("expected %s, got %s", expected, ops)
+	}
+}
+
+func TestCreatePatchBytes(t *testing.T) {
+	out, err := CreatePatchBytes([]byte(`{"a": 1}`), []byte(`{"a": 1, "b": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `[{"op":"add","path":"/b","value":true}]` {
+		t.Errorf("unexpected patch %s", out)
+	}
+}
+
+func TestCreatePatchNullAndAbsent(t *testing.T) {
+	a := decode(`{"gone": 1, "nulled": 2, "wasNull": null, "goneNull": null}`)
+	b := decode(`{"nulled": null, "wasNull": null, "newNull": null}`)
+	cases := []struct {
+		opts     DiffOptions
+		expected string
+	}{
+		{DiffOptions{}, `[
+			{"op": "remove", "path": "/gone"},
+			{"op": "remove", "path": "/goneNull"},
+			{"op": "add", "path": "/newNull", "value": null},
+			{"op": "replace", "path": "/nulled", "value": null}
+		]`},
+		{DiffOptions{RemovedAsNull: true}, `[
+			{"op": "replace", "path": "/gone", "value": null},
+			{"op": "add", "path": "/newNull", "value": null},
+			{"op": "replace", "path": "/nulled", "value": null}
+		]`},
+		{DiffOptions{NullAsRemoved: true}, `[
+			{"op": "remove", "path": "/gone"},
+			{"op": "remove", "path": "/goneNull"},
+			{"op": "remove", "path": "/nulled"},
+			{"op": "remove", "path": "/wasNull"}
+		]`},
+	}
+	for _, c := range cases {
+		ops, err := CreatePatchWithOptions(a, b, c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := json.Marshal(ops)
+		want, _ := json.Marshal(parseStr(c.expected))
+		if string(got) != string(want) {
+			t.Errorf("%+v: expected %s, got %s", c.opts, want, got)
+		}
+	}
+}
+
+func TestCreatePatchPrimitiveArrays(t *testing.T) {
+	series := func(n int, changed map[int]interface{}) []interface{} {
+		s := make([]interface{}, n)
+		for i := range s {
+			s[i] = float64(i)
+			if v, ok := changed[i]; ok {
+				s[i] = v
+			}
+		}
+		return s
+	}
+	// too large to align, so compared position by position
+	n := 3000
+	for _, tc := range []struct {
+		a, b     interface{}
+		expected string
+	}{
+		{series(n, nil), series(n, map[int]interface{}{5: -1.0, 2000: "x"}),
+			`[{"op":"replace","path":"/5","value":-1},{"op":"replace","path":"/2000","value":"x"}]`},
+		{series(n, nil), series(n, map[int]interface{}{7: 0.5}), `[{"op":"replace","path":"/7","value":0.5}]`},
+		{[]interface{}{"a", "b", "c"}, []interface{}{"a", "x", "b", "c"}, `[{"op":"add","path":"/1","value":"x"}]`},
+		{[]interface{}{1.0, 2.0, 3.0}, []interface{}{json.Number("1"), 3.0}, `[{"op":"remove","path":"/1"}]`},
+	} {
+		ops, err := CreatePatch(tc.a, tc.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(mustMarshal(t, ops)); got != tc.expected {
+			t.Errorf("expected %s, got %s", tc.expected, got)
+		}
+		if result, err := Apply(tc.a, ops); err != nil || !jsonEqual(result, tc.b) {
+			t.Errorf("the patch does not produce the modified array: %v", err)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package patch
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// DiffWithMoves is CreatePatch with DiffOptions.DetectMoves set: values
+// relocated within the document are moved or copied instead of being
+// removed and added again.
+func DiffWithMoves(original, modified interface{}) ([]Operation, error) {
+	return CreatePatchWithOptions(original, modified, DiffOptions{DetectMoves: true})
+}
+
+// relocations holds the values of the original document that the operations
+// of a patch can take their value from instead of writing it out again, by
+// hash: members removed from objects, which can be moved, and objects and
+// arrays left unchanged, which can be copied. Both are found along paths
+// made of object members present in both documents, which no operation
+// shifts, so that they stay valid throughout the patch.
+type relocations struct {
+	sources map[uint64][]*relocation
+	// removed holds the members to be moved, by path, whose removal is
+	// deferred to the end of the patch in case they are moved instead
+	removed map[string]*relocation
+	order   []*relocation
+}
+
+type relocation struct {
+	path  string
+	value interface{}
+	move  bool
+	used  bool
+}
+
+// newRelocations finds the values of original that the patch turning it
+// into modified can move or copy. Members removed as null with
+// RemovedAsNull are not moved, and values are not copied with
+// NullAsRemoved, which modifies the unchanged values holding nulls.
+func newRelocations(original, modified interface{}, opts DiffOptions) *relocations {
+	r := &relocations{sources: make(map[uint64][]*relocation), removed: make(map[string]*relocation)}
+	a, oka := asObject(original)
+	b, okb := asObject(modified)
+	if oka && okb {
+		r.collect("", a, b, opts)
+	}
+	return r
+}
+
+func (r *relocations) collect(path string, a, b map[string]interface{}, opts DiffOptions) {
+	for _, k := range slices.Sorted(maps.Keys(a)) {
+		p := path + "/" + pointer.Escape(k)
+		av := a[k]
+		bv, inB := b[k]
+		switch {
+		case !inB:
+			if !opts.RemovedAsNull {
+				s := &relocation{path: p, value: av, move: true}
+				h := hashTree(av, "", nil)
+				r.sources[h] = append(r.sources[h], s)
+				r.removed[p] = s
+				r.order = append(r.order, s)
+			}
+		case jsonEqual(av, bv):
+			if !opts.NullAsRemoved {
+				hashTree(av, p, func(path string, v interface{}, h uint64) {
+					r.sources[h] = append(r.sources[h], &relocation{path: path, value: v})
+				})
+			}
+		default:
+			am, oka := asObject(av)
+			bm, okb := asObject(bv)
+			if oka && okb {
+				r.collect(p, am, bm, opts)
+			}
+		}
+	}
+}
+
+// take returns the relocation to write v with, a move rather than a copy
+// when there is one, or nil.
+func (r *relocations) take(v interface{}) *relocation {
+	if len(r.sources) == 0 {
+		return nil
+	}
+	var found *relocation
+	for _, s := range r.sources[hashTree(v, "", nil)] {
+		if s.used || !jsonEqual(s.value, v) {
+			continue
+		}
+		if s.move {
+			s.used = true
+			return s
+		}
+		if found == nil {
+			found = s
+		}
+	}
+	return found
+}
+
+// relocate emits the move or copy writing value at path, when the original
+// document holds it, and reports whether it did.
+func (d *differ) relocate(path string, value interface{}) bool {
+	if d.moves == nil || path == "" {
+		return false
+	}
+	s := d.moves.take(value)
+	if s == nil {
+		return false
+	}
+	op := "copy"
+	if s.move {
+		op = "move"
+	}
+	d.ops = append(d.ops, Operation{Op: op, From: s.path, Path: path})
+	return true
+}
+
+// diffElement is diff for the element of an array at path. A move or copy
+// onto an element inserts before it, so an element replaced by a value the
+// original document holds is removed before the value is relocated there.
+func (d *differ) diffElement(path string, a, b interface{}) error {
+	if d.moves != nil && !sameKind(a, b) && !jsonEqual(a, b) {
+		n := len(d.ops)
+		if d.relocate(path, b) {
+			d.ops = slices.Insert(d.ops, n, Operation{Op: "remove", Path: path})
+			return nil
+		}
+	}
+	return d.diff(path, a, b)
+}
+
+// sameKind reports whether a and b are both objects or both arrays, which
+// diff compares member by member or element by element.
+func sameKind(a, b interface{}) bool {
+	_, oka := asObject(a)
+	_, okb := asObject(b)
+	if oka || okb {
+		return oka && okb
+	}
+	_, oka = a.([]interface{})
+	_, okb = b.([]interface{})
+	return oka && okb
+}
+
+// removeUnmoved emits the removal of the members that were deferred and
+// not moved after all.
+func (d *differ) removeUnmoved() error {
+	for _, s := range d.moves.order {
+		if !s.used {
+			if err := d.emit("remove", s.path, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// moveElements emits the operations turning the elements of an array at
+// path into those of another, from start on, given the edit script
+// aligning them, moving the removed elements that are inserted again
+// elsewhere. The other removals and inserts are paired and diffed as
+// diffArrayOf does. It reports false, having emitted nothing, when no
+// element is moved.
+func (d *differ) moveElements(path string, start int, edits []edit) (bool, error) {
+	removed := make(map[uint64][]int)
+	for i, e := range edits {
+		if e.kind == editRemove {
+			h := hashTree(e.value, "", nil)
+			removed[h] = append(removed[h], i)
+		}
+	}
+	// source maps the inserted elements, by edit, to the removed element
+	// they are moved or diffed from
+	source := make(map[int]int)
+	moved := make(map[int]bool)
+	for i, e := range edits {
+		if e.kind != editInsert {
+			continue
+		}
+		h := hashTree(e.value, "", nil)
+		for k, r := range removed[h] {
+			if jsonEqual(edits[r].value, e.value) {
+				source[i], moved[r] = r, true
+				removed[h] = slices.Delete(removed[h], k, k+1)
+				break
+			}
+		}
+	}
+	if len(source) == 0 {
+		return false, nil
+	}
+	for i := 0; i < len(edits); {
+		var dels, ins []int
+		for ; i < len(edits) && edits[i].kind != editKeep; i++ {
+			switch {
+			case edits[i].kind == editRemove && !moved[i]:
+				dels = append(dels, i)
+			case edits[i].kind == editInsert:
+				if _, ok := source[i]; !ok {
+					ins = append(ins, i)
+				}
+			}
+		}
+		for j := 0; j < len(dels) && j < len(ins); j++ {
+			source[ins[j]] = dels[j]
+		}
+		i++
+	}
+	used := make(map[int]bool)
+	for _, r := range source {
+		used[r] = true
+	}
+
+	// cur holds the elements of the array as the operations are emitted,
+	// by the edit of their original element, or -1 for inserted ones
+	var cur []int
+	for i, e := range edits {
+		if e.kind != editInsert {
+			cur = append(cur, i)
+		}
+	}
+	at := func(i int) string {
+		return path + "/" + strconv.Itoa(start+i)
+	}
+	for i := 0; i < len(cur); {
+		if edits[cur[i]].kind == editRemove && !used[cur[i]] {
+			if err := d.emit("remove", at(i), nil); err != nil {
+				return false, err
+			}
+			cur = slices.Delete(cur, i, i+1)
+			continue
+		}
+		i++
+	}
+	// the elements up to prev are those of the modified array
+	prev := -1
+	for i, e := range edits {
+		if e.kind == editRemove {
+			continue
+		}
+		r, ok := source[i]
+		switch {
+		case e.kind == editKeep:
+			prev = slices.Index(cur, i)
+		case ok && moved[r]:
+			from := slices.Index(cur, r)
+			cur = slices.Delete(cur, from, from+1)
+			if from < prev {
+				prev--
+			}
+			prev++
+			if from != prev {
+				d.ops = append(d.ops, Operation{Op: "move", From: at(from), Path: at(prev)})
+			}
+			cur = slices.Insert(cur, prev, r)
+		case ok:
+			prev = slices.Index(cur, r)
+			if err := d.diffElement(at(prev), edits[r].value, e.value); err != nil {
+				return false, err
+			}
+		default:
+			prev++
+			if err := d.emit("add", at(prev), e.value); err != nil {
+				return false, err
+			}
+			cur = slices.Insert(cur, prev, -1)
+		}
+	}
+	return true, nil
+}
+
+// asObject returns v as a map, when it is an object.
+func asObject(v interface{}) (map[string]interface{}, bool) {
+	if o, ok := v.(*SortedObject); ok {
+		return o.Map(), true
+	}
+	m, ok := v.(map[string]interface{})
+	return m, ok
+}
+
+// hashTree returns a hash of v, equal for values equal by jsonEqual. When
+// visit is not nil, it is called with the path, the value and the hash of v
+// and of every non-empty object and array within it, v being at path.
+func hashTree(v interface{}, path string, visit func(path string, v interface{}, h uint64)) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	put := func(n uint64) {
+		binary.LittleEndian.PutUint64(buf[:], n)
+		h.Write(buf[:])
+	}
+	// the paths of the values within v are only needed to visit them
+	child := func(token string) string {
+		if visit == nil {
+			return ""
+		}
+		return path + "/" + token
+	}
+	container := false
+	switch v := v.(type) {
+	case *SortedObject:
+		return hashTree(v.Map(), path, visit)
+	case map[string]interface{}:
+		// members are summed so that their order does not matter
+		var sum uint64
+		for k, e := range v {
+			m := fnv.New64a()
+			m.Write([]byte(k))
+			binary.LittleEndian.PutUint64(buf[:], hashTree(e, child(pointer.Escape(k)), visit))
+			m.Write(buf[:])
+			sum += m.Sum64()
+		}
+		h.Write([]byte{'o'})
+		put(sum)
+		container = len(v) > 0
+	case []interface{}:
+		h.Write([]byte{'a'})
+		for i, e := range v {
+			put(hashTree(e, child(strconv.Itoa(i)), visit))
+		}
+		container = len(v) > 0
+	case string:
+		h.Write([]byte{'s'})
+		h.Write([]byte(v))
+	case bool:
+		if v {
+			h.Write([]byte{'t'})
+		} else {
+			h.Write([]byte{'f'})
+		}
+	case nil:
+		h.Write([]byte{'n'})
+	default:
+		// numbers equal by value hash the same whatever their type
+		h.Write([]byte{'#'})
+		if r, ok := toRat(v); ok {
+			h.Write([]byte(r.RatString()))
+		}
+	}
+	sum := h.Sum64()
+	if container && visit != nil {
+		visit(path, v, sum)
+	}
+	return sum
+}
@@ -0,0 +1,159 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	randv2 "math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+func TestDiffWithMoves(t *testing.T) {
+	for _, tc := range []struct{ a, b, expected string }{
+		{`[{"id": 1}, {"id": 2}, {"id": 3}, {"id": 4}]`, `[{"id": 4}, {"id": 1}, {"id": 2}, {"id": 3}]`,
+			`[{"op":"move","path":"/0","from":"/3"}]`},
+		{`[{"id": 1}, {"id": 2}, {"id": 3}]`, `[{"id": 2}, {"id": 3}, {"id": 1}]`,
+			`[{"op":"move","path":"/2","from":"/0"}]`},
+		{`{"list": ["a", "b", "c", "d"]}`, `{"list": ["d", "c", "b", "a"]}`,
+			`[{"op":"move","path":"/list/3","from":"/list/2"},{"op":"move","path":"/list/3","from":"/list/1"},{"op":"move","path":"/list/3","from":"/list/0"}]`},
+		{`[{"id": 1}, {"id": 2}, {"id": 3}, {"id": 4}]`, `[{"id": 4}, {"id": 1}, {"id": 2, "v": true}, {"id": 5}]`,
+			`[{"op":"remove","path":"/1"},{"op":"remove","path":"/1"},{"op":"move","path":"/1","from":"/0"},{"op":"add","path":"/2","value":{"id":2,"v":true}},{"op":"add","path":"/3","value":{"id":5}}]`},
+		{`{"old": {"a": [1, 2]}, "z": 1}`, `{"new": {"a": [1, 2]}, "z": 1}`,
+			`[{"op":"move","path":"/new","from":"/old"}]`},
+		{`{"a": 1, "b": 2}`, `{"a": 2}`,
+			`[{"op":"move","path":"/a","from":"/b"}]`},
+		{`{"a": {"x": [1, 2]}}`, `{"a": {"x": [1, 2]}, "b": {"x": [1, 2]}, "c": [[1, 2]]}`,
+			`[{"op":"copy","path":"/b","from":"/a"},{"op":"add","path":"/c","value":[[1,2]]}]`},
+		{`{"a": {"x": [1, 2]}}`, `{"a": {"x": [1, 2]}, "c": [[1, 2]]}`,
+			`[{"op":"add","path":"/c","value":[[1,2]]}]`},
+		{`{"a": 1, "b": {"c": 2}}`, `{"d": 3}`,
+			`[{"op":"add","path":"/d","value":3},{"op":"remove","path":"/a"},{"op":"remove","path":"/b"}]`},
+		{`{"a": [{"id": 1}], "b": {"id": 2}}`, `{"a": [{"id": 2}, {"id": 1}]}`,
+			`[{"op":"move","path":"/a/0","from":"/b"}]`},
+		{`{"keep": {"k": 1}, "c": [1, 2]}`, `{"keep": {"k": 1}, "c": [{"k": 1}, 2]}`,
+			`[{"op":"remove","path":"/c/0"},{"op":"copy","path":"/c/0","from":"/keep"}]`},
+		{`{"a": {"id": 1}, "c": [1, 2]}`, `{"c": [1, {"id": 1}]}`,
+			`[{"op":"remove","path":"/c/1"},{"op":"move","path":"/c/1","from":"/a"}]`},
+	} {
+		a, b := decode(tc.a), decode(tc.b)
+		ops, err := DiffWithMoves(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(mustMarshal(t, ops)); got != tc.expected {
+			t.Errorf("%s -> %s: expected %s, got %s", tc.a, tc.b, tc.expected, got)
+		}
+		if result, err := Apply(a, ops); err != nil || !reflect.DeepEqual(result, b) {
+			t.Errorf("%s -> %s: the patch produces %v, %v", tc.a, tc.b, result, err)
+		}
+	}
+}
+
+func TestDiffWithMovesOptions(t *testing.T) {
+	a, b := decode(`{"a": {"x": 1}, "b": null}`), decode(`{"c": {"x": 1}, "d": {"x": 1}, "b": null}`)
+	for _, tc := range []struct {
+		opts     DiffOptions
+		expected string
+	}{
+		{DiffOptions{DetectMoves: true},
+			`[{"op":"move","path":"/c","from":"/a"},{"op":"add","path":"/d","value":{"x":1}}]`},
+		{DiffOptions{DetectMoves: true, RemovedAsNull: true},
+			`[{"op":"replace","path":"/a","value":null},{"op":"add","path":"/c","value":{"x":1}},{"op":"add","path":"/d","value":{"x":1}}]`},
+		{DiffOptions{DetectMoves: true, NullAsRemoved: true},
+			`[{"op":"remove","path":"/b"},{"op":"move","path":"/c","from":"/a"},{"op":"add","path":"/d","value":{"x":1}}]`},
+	} {
+		ops, err := CreatePatchWithOptions(a, b, tc.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(mustMarshal(t, ops)); got != tc.expected {
+			t.Errorf("%+v: expected %s, got %s", tc.opts, tc.expected, got)
+		}
+	}
+}
+
+func TestDiffWithMovesRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	item := func(id int) interface{} {
+		return map[string]interface{}{"id": float64(id), "body": fmt.Sprintf("item %d", id%7)}
+	}
+	for n := 0; n < 200; n++ {
+		var a, b []interface{}
+		for i := range rng.Intn(12) {
+			a = append(a, item(i))
+		}
+		for _, i := range rng.Perm(len(a)) {
+			switch rng.Intn(6) {
+			case 0: // removed
+			case 1:
+				b = append(b, item(100+i))
+			case 2:
+				b = append(b, item(i%3), item(i))
+			default:
+				b = append(b, item(i))
+			}
+		}
+		doc := map[string]interface{}{"items": a, "kept": item(1)}
+		modified := map[string]interface{}{"items": b, "kept": item(1)}
+		if rng.Intn(2) == 0 {
+			modified["more"] = []interface{}{item(1)}
+		}
+		ops, err := DiffWithMoves(doc, modified)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := Apply(doc, ops)
+		if err != nil || !jsonEqual(result, modified) {
+			t.Fatalf("%v -> %v: %s produces %v, %v", doc, modified, mustMarshal(t, ops), result, err)
+		}
+		plain, err := CreatePatch(doc, modified)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ops) > len(plain) {
+			t.Errorf("%v -> %v: %d operations with moves, %d without", a, b, len(ops), len(plain))
+		}
+	}
+}
+
+// TestDiffWithMovesRoundTrip checks that the patches between documents and
+// randomly modified copies of them turn the former into the latter.
+func TestDiffWithMovesRoundTrip(t *testing.T) {
+	r := randv2.New(randv2.NewPCG(1, 2))
+	doc := decode(`{"keep": {"k": 1}, "c": [1, 2, {"x": 1}], "o": {"a": {"x": 1}, "b": [[2], "p"]}}`)
+	for n := 0; n < 5000; n++ {
+		modified, err := Apply(doc, randomPatch(r, doc, 1+r.IntN(6)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ops, err := DiffWithMoves(doc, modified)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result, err := Apply(doc, ops); err != nil || !jsonEqual(result, modified) {
+			t.Fatalf("%v -> %v: %s produces %v, %v", doc, modified, mustMarshal(t, ops), result, err)
+		}
+	}
+}
+
+func TestHashTree(t *testing.T) {
+	for _, tc := range []struct{ a, b interface{} }{
+		{decode(`{"a": 1, "b": [true, null, "x"]}`), decode(`{"b": [true, null, "x"], "a": 1.0}`)},
+		{1.0, json.Number("1.00")},
+	} {
+		if hashTree(tc.a, "", nil) != hashTree(tc.b, "", nil) {
+			t.Errorf("expected %v and %v to hash the same", tc.a, tc.b)
+		}
+	}
+	if hashTree(decode(`["a", "b"]`), "", nil) == hashTree(decode(`["b", "a"]`), "", nil) {
+		t.Error("expected the order of elements to matter")
+	}
+	var visited []string
+	hashTree(decode(`{"a": {"b": [[], [1]]}, "c": 2}`), "/x", func(path string, v interface{}, h uint64) {
+		visited = append(visited, path)
+	})
+	if len(visited) != 4 {
+		t.Errorf("expected the non-empty containers to be visited, got %v", visited)
+	}
+}
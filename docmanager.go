@@ -0,0 +1,220 @@
+package patch
+
+import (
+	"container/list"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrDocumentNotOpen is returned by DocManager.Apply for a URI that is not
+// open, or was closed or evicted.
+var ErrDocumentNotOpen = errors.New("document not open")
+
+// DocChange notifies the subscribers of a DocManager of a change to an open
+// document.
+type DocChange struct {
+	URI     string
+	Version int64 // version of the document after the change
+	// Patch is the patch applied to the document, or nil when the document
+	// was opened again with new contents.
+	Patch []Operation
+	// Doc is the document after the change. It is shared with readers and
+	// must not be modified.
+	Doc interface{}
+	// Closed is true when the document was closed or evicted; Version and
+	// Doc are then those it was closed with.
+	Closed bool
+}
+
+// DocManager holds the documents a language server or a collaborative
+// editing server has open, keyed by URI. Patches are applied with version
+// checks, as by Document.Apply, and every change is delivered to the
+// subscribers of its document. When more documents are open than the
+// manager's capacity, the least recently used ones are evicted. A
+// DocManager is safe for concurrent use.
+type DocManager struct {
+	mu       sync.Mutex
+	capacity int
+	opts     []Option
+	docs     map[string]*managedDoc
+	lru      *list.List // of *managedDoc, most recently used first
+	subs     map[string][]*subscriber
+}
+
+type managedDoc struct {
+	uri    string
+	doc    *Document
+	mu     sync.Mutex // orders patches and their notifications
+	closed atomic.Bool
+	elem   *list.Element
+}
+
+type subscriber struct {
+	fn func(DocChange)
+}
+
+// NewDocManager returns a DocManager keeping at most capacity documents
+// open, or any number when capacity is 0. opts are used for every patch
+// applied to its documents.
+func NewDocManager(capacity int, opts ...Option) *DocManager {
+	return &DocManager{
+		capacity: capacity,
+		opts:     opts,
+		docs:     make(map[string]*managedDoc),
+		lru:      list.New(),
+		subs:     make(map[string][]*subscriber),
+	}
+}
+
+// Open opens the document uri with the given contents and version,
+// replacing the document already open under uri if any; its subscribers are
+// then notified with a DocChange without Patch. Opening a document may
+// evict the least recently used one.
+func (m *DocManager) Open(uri string, doc interface{}, version int64) {
+	md := &managedDoc{uri: uri, doc: NewDocument(doc, version, m.opts...)}
+	m.mu.Lock()
+	old := m.docs[uri]
+	if old != nil {
+		old.closed.Store(true)
+		m.lru.Remove(old.elem)
+	}
+	md.elem = m.lru.PushFront(md)
+	m.docs[uri] = md
+	var evicted []*managedDoc
+	for m.capacity > 0 && m.lru.Len() > m.capacity {
+		e := m.lru.Back().Value.(*managedDoc)
+		m.remove(e)
+		evicted = append(evicted, e)
+	}
+	m.mu.Unlock()
+
+	if old != nil {
+		m.notify(DocChange{URI: uri, Version: version, Doc: doc})
+	}
+	for _, e := range evicted {
+		m.notifyClosed(e)
+	}
+}
+
+// Close closes the document uri and notifies its subscribers. It reports
+// whether the document was open.
+func (m *DocManager) Close(uri string) bool {
+	m.mu.Lock()
+	md := m.docs[uri]
+	if md != nil {
+		m.remove(md)
+	}
+	m.mu.Unlock()
+	if md == nil {
+		return false
+	}
+	m.notifyClosed(md)
+	return true
+}
+
+// remove forgets md, which must be open; m.mu must be held.
+func (m *DocManager) remove(md *managedDoc) {
+	md.closed.Store(true)
+	m.lru.Remove(md.elem)
+	delete(m.docs, md.uri)
+}
+
+func (m *DocManager) notifyClosed(md *managedDoc) {
+	doc, version := md.doc.Snapshot()
+	m.notify(DocChange{URI: md.uri, Version: version, Doc: doc, Closed: true})
+}
+
+// lookup returns the open document uri and marks it as the most recently
+// used, or returns nil.
+func (m *DocManager) lookup(uri string) *managedDoc {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	md := m.docs[uri]
+	if md != nil {
+		m.lru.MoveToFront(md.elem)
+	}
+	return md
+}
+
+// Get returns the open document uri and its version. The document must not
+// be modified.
+func (m *DocManager) Get(uri string) (doc interface{}, version int64, ok bool) {
+	md := m.lookup(uri)
+	if md == nil {
+		return nil, 0, false
+	}
+	doc, version = md.doc.Snapshot()
+	return doc, version, true
+}
+
+// Apply applies ops to the open document uri and returns its new version.
+// When ifVersion is non-zero the patch is only applied if the document is
+// at that version, and a *VersionError is returned otherwise. The
+// subscribers of the document are notified before Apply returns, in the
+// order of the versions, so they must not apply patches to the same
+// document themselves.
+func (m *DocManager) Apply(uri string, ops []Operation, ifVersion int64) (int64, error) {
+	md := m.lookup(uri)
+	if md == nil {
+		return 0, ErrDocumentNotOpen
+	}
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	if md.closed.Load() {
+		return 0, ErrDocumentNotOpen
+	}
+	version, err := md.doc.Apply(ops, ifVersion)
+	if err != nil {
+		return 0, err
+	}
+	doc, _ := md.doc.Snapshot()
+	m.notify(DocChange{URI: uri, Version: version, Patch: ops, Doc: doc})
+	return version, nil
+}
+
+// Subscribe calls fn with every change to the document uri, or to any
+// document when uri is empty, until the returned function is called. The
+// subscribers of a change are called in the order they subscribed, those of
+// the document before those of every document.
+func (m *DocManager) Subscribe(uri string, fn func(DocChange)) (cancel func()) {
+	s := &subscriber{fn: fn}
+	m.mu.Lock()
+	m.subs[uri] = append(m.subs[uri], s)
+	m.mu.Unlock()
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for i, x := range m.subs[uri] {
+			if x == s {
+				m.subs[uri] = append(m.subs[uri][:i:i], m.subs[uri][i+1:]...)
+				break
+			}
+		}
+		if len(m.subs[uri]) == 0 {
+			delete(m.subs, uri)
+		}
+	}
+}
+
+// notify calls the subscribers of the document of c, and those of every
+// document.
+func (m *DocManager) notify(c DocChange) {
+	m.mu.Lock()
+	subs := m.subs[""]
+	if c.URI != "" {
+		subs = slices.Concat(m.subs[c.URI], subs)
+	}
+	m.mu.Unlock()
+	for _, s := range subs {
+		s.fn(c)
+	}
+}
+
+// Len returns the number of open documents.
+func (m *DocManager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
@@ -0,0 +1,112 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestDocManager(t *testing.T) {
+	m := NewDocManager(0)
+	var changes []DocChange
+	cancel := m.Subscribe("file:///a.json", func(c DocChange) { changes = append(changes, c) })
+	var all int
+	m.Subscribe("", func(DocChange) { all++ })
+
+	m.Open("file:///a.json", decode(`{"n": 1}`), 1)
+	m.Open("file:///b.json", decode(`{}`), 1)
+	v, err := m.Apply("file:///a.json", parseStr(`[{"op": "replace", "path": "/n", "value": 2}]`), 1)
+	if err != nil || v != 2 {
+		t.Fatalf("unexpected version %d, %v", v, err)
+	}
+	if _, err := m.Apply("file:///a.json", parseStr(`[]`), 1); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("expected a version mismatch, got %v", err)
+	}
+	if _, err := m.Apply("file:///a.json", parseStr(`[{"op": "remove", "path": "/x"}]`), 0); err == nil {
+		t.Error("expected a failing patch to fail")
+	}
+	if _, err := m.Apply("file:///b.json", parseStr(`[{"op": "add", "path": "/x", "value": 1}]`), 0); err != nil {
+		t.Fatal(err)
+	}
+	if doc, v, ok := m.Get("file:///a.json"); !ok || v != 2 || !reflect.DeepEqual(doc, decode(`{"n": 2}`)) {
+		t.Errorf("unexpected document %v at version %d", doc, v)
+	}
+
+	m.Open("file:///a.json", decode(`{"n": 5}`), 7)
+	if !m.Close("file:///a.json") || m.Close("file:///a.json") {
+		t.Error("expected the document to be closed once")
+	}
+	if _, err := m.Apply("file:///a.json", parseStr(`[]`), 0); !errors.Is(err, ErrDocumentNotOpen) {
+		t.Errorf("expected ErrDocumentNotOpen, got %v", err)
+	}
+	cancel()
+	m.Open("file:///a.json", decode(`{}`), 1)
+	m.Apply("file:///a.json", parseStr(`[]`), 0)
+
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", changes)
+	}
+	if c := changes[0]; c.Version != 2 || len(c.Patch) != 1 || !reflect.DeepEqual(c.Doc, decode(`{"n": 2}`)) {
+		t.Errorf("unexpected change %+v", c)
+	}
+	if c := changes[1]; c.Version != 7 || c.Patch != nil || c.Closed {
+		t.Errorf("expected a change reopening the document, got %+v", c)
+	}
+	if c := changes[2]; c.Version != 7 || !c.Closed {
+		t.Errorf("expected a change closing the document, got %+v", c)
+	}
+	if all != 5 {
+		t.Errorf("expected 5 changes to any document, got %d", all)
+	}
+}
+
+func TestDocManagerEviction(t *testing.T) {
+	m := NewDocManager(2)
+	var closed []string
+	m.Subscribe("", func(c DocChange) {
+		if c.Closed {
+			closed = append(closed, c.URI)
+		}
+	})
+	m.Open("a", decode(`{}`), 1)
+	m.Open("b", decode(`{}`), 1)
+	m.Get("a")
+	m.Open("c", decode(`{}`), 1)
+	if _, _, ok := m.Get("b"); ok || m.Len() != 2 {
+		t.Errorf("expected the least recently used document to be evicted, have %d", m.Len())
+	}
+	if _, err := m.Apply("a", parseStr(`[]`), 0); err != nil {
+		t.Fatal(err)
+	}
+	m.Open("d", decode(`{}`), 1)
+	if !reflect.DeepEqual(closed, []string{"b", "c"}) {
+		t.Errorf("unexpected evictions %v", closed)
+	}
+}
+
+func TestDocManagerConcurrent(t *testing.T) {
+	m := NewDocManager(0)
+	m.Open("a", decode(`{"list": []}`), 1)
+	var versions []int64
+	m.Subscribe("a", func(c DocChange) { versions = append(versions, c.Version) })
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Apply("a", parseStr(`[{"op": "add", "path": "/list/-", "value": 1}]`), 0); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	for i, v := range versions {
+		if v != int64(i+2) {
+			t.Fatalf("expected changes in version order, got %v", versions)
+		}
+	}
+	if doc, _, _ := m.Get("a"); len(doc.(map[string]interface{})["list"].([]interface{})) != 20 {
+		t.Errorf("unexpected document %v", doc)
+	}
+}
@@ -0,0 +1,105 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrVersionMismatch matches errors for patches refused by Document.Apply
+// because the document is no longer at the version they were made against.
+var ErrVersionMismatch = errors.New("document version mismatch")
+
+// VersionError reports a Document.Apply precondition that failed.
+type VersionError struct {
+	Expected int64 // version the patch was made against
+	Actual   int64 // current version of the document
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("document is at version %d, not %d", e.Actual, e.Expected)
+}
+
+// Is makes VersionError match ErrVersionMismatch.
+func (e *VersionError) Is(target error) bool { return target == ErrVersionMismatch }
+
+// Code returns "version-mismatch".
+func (e *VersionError) Code() string { return "version-mismatch" }
+
+// Document holds a JSON document and a version incremented by every patch
+// applied to it, for optimistic concurrency control: a client reads the
+// document and its version, and its patch is only applied if nobody else
+// patched the document in the meantime. A Document is safe for concurrent
+// use.
+type Document struct {
+	mu      sync.RWMutex
+	doc     interface{}
+	version int64
+	opts    []Option
+}
+
+// NewDocument returns a Document holding doc at the given version, for
+// example as loaded from storage; new documents usually start at version 1.
+// opts are used for every patch applied to the document, except that
+// patches are never applied in place.
+func NewDocument(doc interface{}, version int64, opts ...Option) *Document {
+	opts = append(opts[:len(opts):len(opts)], func(o *Options) { o.InPlace = false })
+	return &Document{doc: doc, version: version, opts: opts}
+}
+
+// Apply applies ops to the document and returns its new version. When
+// ifVersion is non-zero the patch is only applied if the document is at that
+// version, and a *VersionError is returned otherwise. If the patch fails,
+// the document and its version are left unchanged.
+func (d *Document) Apply(ops []Operation, ifVersion int64) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if ifVersion != 0 && ifVersion != d.version {
+		return 0, &VersionError{Expected: ifVersion, Actual: d.version}
+	}
+	doc, err := Apply(d.doc, ops, d.opts...)
+	if err != nil {
+		return 0, err
+	}
+	d.doc = doc
+	d.version++
+	return d.version, nil
+}
+
+// Snapshot returns the document and its version. The document is never
+// modified by later patches, and must not be modified by the caller either.
+func (d *Document) Snapshot() (interface{}, int64) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.doc, d.version
+}
+
+// Version returns the current version of the document.
+func (d *Document) Version() int64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.version
+}
+
+// ETag returns the strong entity tag of a document version, for the ETag
+// header of HTTP responses.
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// ParseETag returns the version of an entity tag produced by ETag, as found
+// in an If-Match request header. Weak entity tags are rejected, since
+// If-Match uses the strong comparison.
+func ParseETag(etag string) (int64, error) {
+	etag = strings.TrimSpace(etag)
+	if len(etag) < 2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return 0, fmt.Errorf("invalid entity tag %q", etag)
+	}
+	v, err := strconv.ParseInt(etag[1:len(etag)-1], 10, 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid entity tag %q", etag)
+	}
+	return v, nil
+}
@@ -0,0 +1,87 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestDocument(t *testing.T) {
+	d := NewDocument(decode(`{"n": 0}`), 1, WithInPlace())
+	snapshot, version := d.Snapshot()
+
+	v, err := d.Apply(parseStr(`[{"op": "replace", "path": "/n", "value": 1}]`), version)
+	if err != nil || v != 2 {
+		t.Fatalf("expected version 2, got %d, %v", v, err)
+	}
+	if !reflect.DeepEqual(snapshot, decode(`{"n": 0}`)) {
+		t.Errorf("snapshot was modified: %v", snapshot)
+	}
+
+	// a patch made against the old version is refused
+	_, err = d.Apply(parseStr(`[{"op": "replace", "path": "/n", "value": 5}]`), version)
+	var ve *VersionError
+	if !errors.Is(err, ErrVersionMismatch) || !errors.As(err, &ve) || ve.Expected != 1 || ve.Actual != 2 {
+		t.Fatalf("expected a version mismatch, got %v", err)
+	}
+
+	// a failing patch changes nothing
+	if _, err := d.Apply(parseStr(`[{"op": "replace", "path": "/n", "value": 3}, {"op": "remove", "path": "/x"}]`), 0); err == nil {
+		t.Fatal("expected an error")
+	}
+	doc, version := d.Snapshot()
+	if version != 2 || !reflect.DeepEqual(doc, decode(`{"n": 1}`)) {
+		t.Errorf("failed patch changed the document: %v at version %d", doc, version)
+	}
+
+	if v, err := d.Apply(parseStr(`[{"op": "add", "path": "/m", "value": 1}]`), 0); err != nil || v != 3 {
+		t.Errorf("expected an unconditional patch to apply, got %d, %v", v, err)
+	}
+}
+
+func TestDocumentConcurrent(t *testing.T) {
+	d := NewDocument(decode(`{"list": []}`), 1)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	applied := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// read, modify, write with a precondition, retrying on conflict
+			for {
+				_, version := d.Snapshot()
+				_, err := d.Apply(parseStr(`[{"op": "add", "path": "/list/-", "value": 1}]`), version)
+				if errors.Is(err, ErrVersionMismatch) {
+					continue
+				}
+				if err == nil {
+					mu.Lock()
+					applied++
+					mu.Unlock()
+				}
+				return
+			}
+		}()
+	}
+	wg.Wait()
+	doc, version := d.Snapshot()
+	if n := len(doc.(map[string]interface{})["list"].([]interface{})); n != 50 || applied != 50 || version != 51 {
+		t.Errorf("expected 50 patches, got %d elements, %d applied, version %d", n, applied, version)
+	}
+}
+
+func TestETag(t *testing.T) {
+	if e := ETag(42); e != `"42"` {
+		t.Errorf("unexpected entity tag %s", e)
+	}
+	if v, err := ParseETag(` "42"`); err != nil || v != 42 {
+		t.Errorf("expected 42, got %d, %v", v, err)
+	}
+	for _, s := range []string{``, `42`, `W/"42"`, `"x"`, `"-1"`, `"`} {
+		if _, err := ParseETag(s); err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+}
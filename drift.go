@@ -0,0 +1,118 @@
+package patch
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Drift periodically compares a live document with the one it should be, and
+// reports or corrects the differences. It is a minimal reconciliation loop
+// for configuration endpoints and similar resources.
+//
+//	d := &patch.Drift{
+//		Fetch:    fetchConfig,
+//		Desired:  desired,
+//		Correct:  patchConfig, // leave nil to only report
+//		Report:   func(e patch.DriftEvent) { log.Print(e) },
+//		Interval: time.Minute,
+//	}
+//	err := d.Run(ctx)
+type Drift struct {
+	// Fetch returns the live document.
+	Fetch func(ctx context.Context) (interface{}, error)
+	// Desired is the document the live one should match. It must not be
+	// modified while the Drift is running.
+	Desired interface{}
+	// Correct, if set, is called with the patch turning the live document
+	// into Desired. When nil, drift is only reported.
+	Correct func(ctx context.Context, ops []Operation) error
+	// Report, if set, is called after every check that found drift or
+	// failed.
+	Report func(DriftEvent)
+
+	// Interval is the time between two checks. It defaults to one minute.
+	Interval time.Duration
+	// Jitter randomly lengthens or shortens each wait by up to this
+	// fraction of it, so that many agents started together do not fetch in
+	// lockstep. It is clamped to [0, 1].
+	Jitter float64
+	// MaxBackoff bounds the wait after consecutive failed checks, which
+	// doubles from Interval with each failure. It defaults to Interval, that
+	// is no backoff.
+	MaxBackoff time.Duration
+}
+
+// DriftEvent describes the outcome of one check.
+type DriftEvent struct {
+	Time time.Time
+	// Patch turns the live document into the desired one. It is empty
+	// when there was no drift, and nil when the check failed before the
+	// documents could be compared.
+	Patch []Operation
+	// Corrected is true when Patch was successfully passed to Correct.
+	Corrected bool
+	// Err is the error returned by Fetch, CreatePatch or Correct.
+	Err error
+}
+
+// Check fetches the live document once, compares it with the desired one and
+// corrects it if Correct is set.
+func (d *Drift) Check(ctx context.Context) DriftEvent {
+	e := DriftEvent{Time: time.Now()}
+	live, err := d.Fetch(ctx)
+	if err != nil {
+		e.Err = err
+		return e
+	}
+	if e.Patch, e.Err = CreatePatch(live, d.Desired); e.Err != nil {
+		return e
+	}
+	if len(e.Patch) > 0 && d.Correct != nil {
+		if e.Err = d.Correct(ctx, e.Patch); e.Err == nil {
+			e.Corrected = true
+		}
+	}
+	return e
+}
+
+// Run checks for drift immediately and then after every interval, until ctx
+// is done. It returns ctx.Err().
+func (d *Drift) Run(ctx context.Context) error {
+	interval := d.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	maxBackoff := max(d.MaxBackoff, interval)
+	wait := interval
+	for {
+		e := d.Check(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.Report != nil && (e.Err != nil || len(e.Patch) > 0) {
+			d.Report(e)
+		}
+		if e.Err != nil {
+			wait = min(wait*2, maxBackoff)
+		} else {
+			wait = interval
+		}
+		t := time.NewTimer(jitter(wait, d.Jitter))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// jitter returns d randomly adjusted by up to the fraction f of it.
+func jitter(d time.Duration, f float64) time.Duration {
+	f = min(max(f, 0), 1)
+	if f == 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + f*(2*rand.Float64()-1)))
+}
@@ -0,0 +1,90 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDriftCheck(t *testing.T) {
+	live := decode(`{"replicas": 2, "image": "app:1"}`)
+	var mu sync.Mutex
+	d := &Drift{
+		Fetch: func(context.Context) (interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return deepCopy(live), nil
+		},
+		Desired: decode(`{"replicas": 3, "image": "app:1"}`),
+	}
+
+	e := d.Check(context.Background())
+	if e.Err != nil || e.Corrected || len(e.Patch) != 1 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+
+	d.Correct = func(_ context.Context, ops []Operation) error {
+		mu.Lock()
+		defer mu.Unlock()
+		var err error
+		live, err = Apply(live, ops)
+		return err
+	}
+	if e = d.Check(context.Background()); e.Err != nil || !e.Corrected {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if !reflect.DeepEqual(live, d.Desired) {
+		t.Errorf("drift not corrected: %v", live)
+	}
+	if e = d.Check(context.Background()); e.Err != nil || len(e.Patch) != 0 || e.Patch == nil {
+		t.Errorf("expected an empty patch, got %+v", e)
+	}
+}
+
+func TestDriftRun(t *testing.T) {
+	fail := errors.New("unavailable")
+	var mu sync.Mutex
+	var events []DriftEvent
+	fetches := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Drift{
+		Fetch: func(context.Context) (interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			fetches++
+			switch {
+			case fetches == 4:
+				cancel()
+				fallthrough
+			case fetches <= 2:
+				return nil, fail
+			}
+			return decode(`{"a": 1}`), nil
+		},
+		Desired:    decode(`{"a": 2}`),
+		Report:     func(e DriftEvent) { events = append(events, e) },
+		Interval:   time.Millisecond,
+		MaxBackoff: 4 * time.Millisecond,
+		Jitter:     0.5,
+	}
+	if err := d.Run(ctx); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if len(events) != 3 || events[0].Err != fail || events[1].Err != fail || len(events[2].Patch) != 1 {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if d := jitter(time.Second, 0.25); d < 750*time.Millisecond || d > 1250*time.Millisecond {
+			t.Fatalf("jitter out of range: %v", d)
+		}
+	}
+	if d := jitter(time.Second, 0); d != time.Second {
+		t.Errorf("expected no jitter, got %v", d)
+	}
+}
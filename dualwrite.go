@@ -0,0 +1,156 @@
+package patch
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// DualWrite rewrites operations for a document being migrated from one
+// layout to another, which holds both the old and the new shape during the
+// migration window. m maps the locations of the old shape to those of the
+// new one, as for RewritePaths. Operations may be written against either
+// shape, and every write to a mapped location is carried over to its
+// counterpart in the other shape, so that producers and consumers can move
+// to the new shape independently:
+//
+//   - an add, replace or remove at or below a mapped location is followed
+//     by the same operation at the counterpart;
+//   - a move or copy onto a mapped location is followed by a copy of the
+//     result to the counterpart, and a move from one by a remove of the
+//     counterpart, unless it was the destination of the move;
+//   - an add or replace of a parent of mapped locations is followed by a
+//     copy to the counterparts of those the value holds, when it holds only
+//     one of the two shapes, and a remove of a parent by a remove of the
+//     counterparts outside of it;
+//   - test operations are left as they are.
+//
+// A move or copy onto or from a parent of a mapped location cannot be
+// carried over without knowing the document, and is refused with an error
+// matching ErrInvalidPatch, as is a mapping whose old and new locations
+// overlap or that maps two locations to the same one.
+func DualWrite(operations []Operation, m PrefixMapping) ([]Operation, error) {
+	type pair struct{ old, new pointer.Pointer }
+	var pairs []pair
+	inverse := make(PrefixMapping, len(m))
+	for _, from := range slices.Sorted(maps.Keys(m)) {
+		to := m[from]
+		if _, dup := inverse[to]; dup {
+			return nil, fmt.Errorf("dual write: %s is mapped to twice: %w", to, ErrInvalidPatch)
+		}
+		inverse[to] = from
+		var p pair
+		var err error
+		if p.old, err = pointer.Parse(from); err != nil {
+			return nil, fmt.Errorf("dual write: %w", err)
+		}
+		if p.new, err = pointer.Parse(to); err != nil {
+			return nil, fmt.Errorf("dual write: %w", err)
+		}
+		pairs = append(pairs, p)
+	}
+	for _, p := range pairs {
+		for _, q := range pairs {
+			if within(p.old, q.new) || within(q.new, p.old) {
+				return nil, fmt.Errorf("dual write: %s and %s overlap: %w", p.old, q.new, ErrInvalidPatch)
+			}
+		}
+	}
+	// counterpart returns the pointer matching ptr in the other shape
+	counterpart := func(ptr string) (string, bool) {
+		if to, ok := m.lookup(ptr); ok {
+			return to, true
+		}
+		return inverse.lookup(ptr)
+	}
+
+	var out []Operation
+	for i, op := range operations {
+		out = append(out, op)
+		path, err := pointer.Parse(op.Path)
+		if err != nil {
+			return nil, &InvalidPatchError{Index: i, Op: op.Op, Err: err}
+		}
+		// the mapped locations strictly below path, in either shape
+		var below []pair
+		for _, p := range pairs {
+			if len(p.old) > len(path) && within(p.old, path) || len(p.new) > len(path) && within(p.new, path) {
+				below = append(below, p)
+			}
+		}
+		refuse := func(ptr string) error {
+			return &InvalidPatchError{Index: i, Op: op.Op, Err: fmt.Errorf("cannot write both shapes through %s, a parent of migrated locations", ptr)}
+		}
+
+		switch op.Op {
+		case "add", "replace", "remove":
+			if to, ok := counterpart(op.Path); ok {
+				mirror := op
+				mirror.Path = to
+				out = append(out, mirror)
+				continue
+			}
+			if op.Op == "remove" {
+				for _, p := range below {
+					switch {
+					case within(p.old, path) && !within(p.new, path):
+						out = append(out, Operation{Op: "remove", Path: p.new.String()})
+					case within(p.new, path) && !within(p.old, path):
+						out = append(out, Operation{Op: "remove", Path: p.old.String()})
+					}
+				}
+				continue
+			}
+			if len(below) == 0 {
+				continue
+			}
+			var value interface{}
+			if err := unmarshalNumber(op.Value, &value); err != nil {
+				return nil, &InvalidPatchError{Index: i, Op: op.Op, Err: err}
+			}
+			holds := func(ptr pointer.Pointer) bool {
+				if !within(ptr, path) {
+					return false
+				}
+				_, err := ptr[len(path):].Get(value)
+				return err == nil
+			}
+			for _, p := range below {
+				hasOld, hasNew := holds(p.old), holds(p.new)
+				switch {
+				case hasOld && !hasNew:
+					out = append(out, Operation{Op: "copy", From: p.old.String(), Path: p.new.String()})
+				case hasNew && !hasOld:
+					out = append(out, Operation{Op: "copy", From: p.new.String(), Path: p.old.String()})
+				}
+			}
+		case "move", "copy":
+			if len(below) > 0 {
+				return nil, refuse(op.Path)
+			}
+			from, err := pointer.Parse(op.From)
+			if err != nil {
+				return nil, &InvalidPatchError{Index: i, Op: op.Op, Err: err}
+			}
+			for _, p := range pairs {
+				if len(p.old) > len(from) && within(p.old, from) || len(p.new) > len(from) && within(p.new, from) {
+					return nil, refuse(op.From)
+				}
+			}
+			if to, ok := counterpart(op.From); ok && op.Op == "move" && to != op.Path {
+				out = append(out, Operation{Op: "remove", Path: to})
+			}
+			if to, ok := counterpart(op.Path); ok {
+				out = append(out, Operation{Op: "copy", From: op.Path, Path: to})
+			}
+		}
+	}
+	return out, nil
+}
+
+// within reports whether ptr points at parent or inside it.
+func within(ptr, parent pointer.Pointer) bool {
+	return len(ptr) >= len(parent) && samePrefix(ptr, parent, len(parent))
+}
@@ -0,0 +1,66 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDualWrite(t *testing.T) {
+	m := PrefixMapping{"/spec/replicas": "/spec/scale/replicas", "/owner": "/meta/owner"}
+	doc := `{"spec": {"replicas": 1, "scale": {"replicas": 1}}, "owner": {"name": "a"}, "meta": {"owner": {"name": "a"}}}`
+	cases := []struct {
+		patch, expected string
+	}{
+		// writes against the old shape
+		{`[{"op": "replace", "path": "/spec/replicas", "value": 3}]`,
+			`{"spec": {"replicas": 3, "scale": {"replicas": 3}}, "owner": {"name": "a"}, "meta": {"owner": {"name": "a"}}}`},
+		{`[{"op": "add", "path": "/owner/team", "value": "x"}, {"op": "remove", "path": "/owner/name"}]`,
+			`{"spec": {"replicas": 1, "scale": {"replicas": 1}}, "owner": {"team": "x"}, "meta": {"owner": {"team": "x"}}}`},
+		// writes against the new shape
+		{`[{"op": "replace", "path": "/meta/owner", "value": {"name": "b"}}]`,
+			`{"spec": {"replicas": 1, "scale": {"replicas": 1}}, "owner": {"name": "b"}, "meta": {"owner": {"name": "b"}}}`},
+		{`[{"op": "add", "path": "/n", "value": 5}, {"op": "move", "from": "/n", "path": "/spec/scale/replicas"}]`,
+			`{"spec": {"replicas": 5, "scale": {"replicas": 5}}, "owner": {"name": "a"}, "meta": {"owner": {"name": "a"}}}`},
+		// a parent replaced with one shape only
+		{`[{"op": "replace", "path": "/spec", "value": {"replicas": 7, "scale": {}}}]`,
+			`{"spec": {"replicas": 7, "scale": {"replicas": 7}}, "owner": {"name": "a"}, "meta": {"owner": {"name": "a"}}}`},
+		// moving a value out of the migrated location removes both copies
+		{`[{"op": "move", "from": "/owner", "path": "/former"}]`,
+			`{"spec": {"replicas": 1, "scale": {"replicas": 1}}, "former": {"name": "a"}, "meta": {}}`},
+		// a producer migrating a value itself
+		{`[{"op": "move", "from": "/owner", "path": "/meta/owner"}]`, doc},
+		{`[{"op": "test", "path": "/owner/name", "value": "a"}, {"op": "remove", "path": "/meta"}]`,
+			`{"spec": {"replicas": 1, "scale": {"replicas": 1}}}`},
+	}
+	for _, c := range cases {
+		ops, err := DualWrite(parseStr(c.patch), m)
+		if err != nil {
+			t.Errorf("%s: %v", c.patch, err)
+			continue
+		}
+		out, err := Apply(decode(doc), ops)
+		if err != nil {
+			data, _ := json.Marshal(ops)
+			t.Errorf("%s: %s does not apply: %v", c.patch, data, err)
+			continue
+		}
+		if !jsonEqual(out, decode(c.expected)) {
+			t.Errorf("%s: expected %s, got %v", c.patch, c.expected, out)
+		}
+	}
+
+	for _, p := range []string{
+		`[{"op": "copy", "from": "/owner", "path": "/spec"}]`,
+		`[{"op": "move", "from": "/meta", "path": "/x"}]`,
+	} {
+		if _, err := DualWrite(parseStr(p), m); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%s: expected the patch to be refused, got %v", p, err)
+		}
+	}
+	for _, bad := range []PrefixMapping{{"/a": "/a/b"}, {"/a": "/c", "/b": "/c"}} {
+		if _, err := DualWrite(nil, bad); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%v: expected the mapping to be refused, got %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package patch
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// EnvMapping maps the JSON pointers of a configuration document to the
+// names of environment variables overriding them, for configuration
+// deployed both as JSON files and as environment variables.
+//
+// A pointer is named after its reference tokens, upper-cased with every
+// character other than an ASCII letter or digit replaced by an underscore,
+// joined with Separator and preceded by Prefix: with Prefix "APP_", the
+// variable for /db/max-conns is APP_DB_MAX_CONNS.
+type EnvMapping struct {
+	// Prefix is prepended to generated names.
+	Prefix string
+	// Separator joins the tokens of generated names; "_" when empty.
+	Separator string
+	// Names gives the names of pointers, instead of the generated ones.
+	Names map[string]string
+}
+
+// Name returns the name of the environment variable for the pointer path.
+func (m *EnvMapping) Name(path string) (string, error) {
+	if name, ok := m.Names[path]; ok {
+		return name, nil
+	}
+	p, err := pointer.Parse(path)
+	if err != nil {
+		return "", err
+	}
+	if len(p) == 0 {
+		return "", fmt.Errorf("the whole document has no environment variable")
+	}
+	sep := m.Separator
+	if sep == "" {
+		sep = "_"
+	}
+	tokens := make([]string, len(p))
+	for i, token := range p {
+		tokens[i] = strings.Map(func(r rune) rune {
+			switch {
+			case 'a' <= r && r <= 'z':
+				return r - 'a' + 'A'
+			case 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+				return r
+			}
+			return '_'
+		}, token)
+	}
+	return m.Prefix + strings.Join(tokens, sep), nil
+}
+
+// PatchToEnv returns the environment variable overrides, as "KEY=VALUE"
+// strings, equivalent to a patch of replace operations on scalar values.
+// String values are used as they are and other values in their JSON
+// encoding.
+func PatchToEnv(ops []Operation, m EnvMapping) ([]string, error) {
+	env := make([]string, 0, len(ops))
+	for i, op := range ops {
+		if op.Op != "replace" {
+			return nil, &InvalidPatchError{Index: i, Op: op.Op, Err: fmt.Errorf("only replace operations can be environment variables")}
+		}
+		var v interface{}
+		if err := unmarshalNumber(op.Value, &v); err != nil {
+			return nil, &InvalidPatchError{Index: i, Op: op.Op, Err: fmt.Errorf("invalid 'value' parameter: %v", err)}
+		}
+		name, err := m.Name(op.Path)
+		if err != nil {
+			return nil, &InvalidPatchError{Index: i, Op: op.Op, Err: err}
+		}
+		switch v := v.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, &InvalidPatchError{Index: i, Op: op.Op, Err: fmt.Errorf("%s: only scalar values can be environment variables", op.Path)}
+		case string:
+			env = append(env, name+"="+v)
+		default:
+			b, _ := marshal(v)
+			env = append(env, name+"="+string(b))
+		}
+	}
+	return env, nil
+}
+
+// EnvToPatch returns the patch of replace operations applying the
+// environment variable overrides in env, "KEY=VALUE" strings as returned by
+// os.Environ, to the scalar values of doc. Variables naming no scalar of doc
+// are ignored. A value replacing a string is used as it is, and other
+// values must be JSON scalars. The operations are sorted by path.
+func EnvToPatch(env []string, doc interface{}, m EnvMapping) ([]Operation, error) {
+	leaves := make(map[string][]string) // names to pointers
+	current := make(map[string]interface{})
+	var walk func(path pointer.Pointer, v interface{}) error
+	walk = func(path pointer.Pointer, v interface{}) error {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for _, k := range slices.Sorted(maps.Keys(v)) {
+				if err := walk(append(path[:len(path):len(path)], k), v[k]); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			for i, child := range v {
+				if err := walk(append(path[:len(path):len(path)], fmt.Sprint(i)), child); err != nil {
+					return err
+				}
+			}
+		default:
+			if len(path) == 0 {
+				return nil
+			}
+			ptr := path.String()
+			name, err := m.Name(ptr)
+			if err != nil {
+				return err
+			}
+			leaves[name] = append(leaves[name], ptr)
+			current[ptr] = v
+		}
+		return nil
+	}
+	if err := walk(nil, doc); err != nil {
+		return nil, err
+	}
+
+	ops := make([]Operation, 0)
+	for _, kv := range env {
+		name, value, ok := strings.Cut(kv, "=")
+		paths := leaves[name]
+		if !ok || len(paths) == 0 {
+			continue
+		}
+		if len(paths) > 1 {
+			sort.Strings(paths)
+			return nil, fmt.Errorf("%s: names both %s and %s", name, paths[0], paths[1])
+		}
+		path := paths[0]
+		var raw []byte
+		if _, isString := current[path].(string); isString {
+			raw, _ = marshal(value)
+		} else {
+			var v interface{}
+			if err := unmarshalNumber([]byte(value), &v); err != nil {
+				return nil, fmt.Errorf("%s: invalid value for %s: %v", name, path, err)
+			}
+			switch v.(type) {
+			case map[string]interface{}, []interface{}:
+				return nil, fmt.Errorf("%s: %s must be a scalar", name, path)
+			}
+			raw, _ = marshal(v)
+		}
+		ops = append(ops, Operation{Op: "replace", Path: path, Value: raw})
+	}
+	sort.SliceStable(ops, func(i, j int) bool { return ops[i].Path < ops[j].Path })
+	return ops, nil
+}
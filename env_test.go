@@ -0,0 +1,99 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEnvMappingName(t *testing.T) {
+	m := EnvMapping{Prefix: "APP_", Names: map[string]string{"/db/url": "DATABASE_URL"}}
+	for path, expected := range map[string]string{
+		"/db/max-conns":   "APP_DB_MAX_CONNS",
+		"/servers/0/a~1b": "APP_SERVERS_0_A_B",
+		"/db/url":         "DATABASE_URL",
+	} {
+		if name, err := m.Name(path); err != nil || name != expected {
+			t.Errorf("%s: expected %s, got %s %v", path, expected, name, err)
+		}
+	}
+	m = EnvMapping{Separator: "__"}
+	if name, _ := m.Name("/log_level/x"); name != "LOG_LEVEL__X" {
+		t.Errorf("expected LOG_LEVEL__X, got %s", name)
+	}
+	if _, err := m.Name(""); err == nil {
+		t.Error("expected an error for the whole document")
+	}
+}
+
+func TestPatchToEnv(t *testing.T) {
+	m := EnvMapping{Prefix: "APP_"}
+	env, err := PatchToEnv(parseStr(`[
+		{"op": "replace", "path": "/name", "value": "a b"},
+		{"op": "replace", "path": "/db/port", "value": 5432},
+		{"op": "replace", "path": "/debug", "value": true},
+		{"op": "replace", "path": "/tag", "value": null},
+		{"op": "replace", "path": "/big", "value": 12345678901234567890}
+	]`), m)
+	expected := []string{"APP_NAME=a b", "APP_DB_PORT=5432", "APP_DEBUG=true", "APP_TAG=null", "APP_BIG=12345678901234567890"}
+	if err != nil || !reflect.DeepEqual(env, expected) {
+		t.Errorf("expected %q, got %q %v", expected, env, err)
+	}
+
+	for _, p := range []string{
+		`[{"op": "add", "path": "/a", "value": 1}]`,
+		`[{"op": "replace", "path": "/a", "value": {"b": 1}}]`,
+		`[{"op": "replace", "path": "", "value": 1}]`,
+	} {
+		if _, err := PatchToEnv(parseStr(p), m); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%s: expected an invalid patch, got %v", p, err)
+		}
+	}
+}
+
+func TestEnvToPatch(t *testing.T) {
+	doc := decode(`{"name": "x", "db": {"port": 1, "hosts": ["a", "b"]}, "debug": false, "tag": null}`)
+	m := EnvMapping{Prefix: "APP_"}
+	ops, err := EnvToPatch([]string{
+		"HOME=/root",
+		"APP_NAME=5",
+		"APP_DEBUG=true",
+		"APP_DB_PORT=6543",
+		"APP_DB_HOSTS_1=c",
+		"APP_TAG=\"t\"",
+		"APP_UNKNOWN=1",
+	}, doc, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := parseStr(`[
+		{"op": "replace", "path": "/db/hosts/1", "value": "c"},
+		{"op": "replace", "path": "/db/port", "value": 6543},
+		{"op": "replace", "path": "/debug", "value": true},
+		{"op": "replace", "path": "/name", "value": "5"},
+		{"op": "replace", "path": "/tag", "value": "t"}
+	]`)
+	if !reflect.DeepEqual(ops, expected) {
+		t.Errorf("expected %v, got %v", expected, ops)
+	}
+
+	// the overrides round trip, except for a string replacing a null
+	env, err := PatchToEnv(ops[:4], m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := EnvToPatch(env, doc, m)
+	if err != nil || !reflect.DeepEqual(back, ops[:4]) {
+		t.Errorf("round trip gave %v %v", back, err)
+	}
+
+	if _, err := EnvToPatch([]string{"APP_DB_PORT=x"}, doc, m); err == nil {
+		t.Error("expected an invalid number to be rejected")
+	}
+	if _, err := EnvToPatch([]string{"APP_DB_PORT=[1]"}, doc, m); err == nil {
+		t.Error("expected an array to be rejected")
+	}
+	if _, err := EnvToPatch([]string{"APP_A_B=1"}, decode(`{"a_b": 1, "a": {"b": 2}}`), m); err == nil {
+		t.Error("expected an ambiguous name to be rejected")
+	}
+}
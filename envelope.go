@@ -0,0 +1,278 @@
+package patch
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrNotYetValid matches errors for envelopes applied before their
+	// NotBefore time.
+	ErrNotYetValid = errors.New("patch not yet valid")
+	// ErrExpired matches errors for envelopes applied at or after their
+	// ExpiresAt time.
+	ErrExpired = errors.New("patch expired")
+	// ErrInvalidSignature matches errors for envelopes whose signature is
+	// missing or does not verify.
+	ErrInvalidSignature = errors.New("invalid patch signature")
+)
+
+// Envelope wraps a patch with the period during which it may be applied,
+// so that a patch distributed ahead of time is not applied early and a
+// patch left in a queue for too long is not applied late, and with the
+// metadata and signature an audit log of patches needs:
+//
+//	{
+//	  "id": "4f1c2a9e0b7d8e6f5a4b3c2d1e0f9a8b",
+//	  "author": "deploy-bot",
+//	  "createdAt": "2024-05-31T17:12:00Z",
+//	  "notBefore": "2024-06-01T09:00:00Z",
+//	  "expiresAt": "2024-06-02T09:00:00Z",
+//	  "patch": [{"op": "replace", "path": "/banner", "value": "launch"}],
+//	  "signature": {"alg": "Ed25519", "kid": "deploy-2024", "value": "..."}
+//	}
+//
+// Every member other than patch is optional.
+type Envelope struct {
+	ID        string      `json:"id,omitempty"`
+	Author    string      `json:"author,omitempty"`
+	CreatedAt time.Time   `json:"createdAt,omitzero"`
+	NotBefore time.Time   `json:"notBefore,omitzero"`
+	ExpiresAt time.Time   `json:"expiresAt,omitzero"`
+	Patch     []Operation `json:"patch"`
+	// Signature, set by Sign, covers every other member.
+	Signature *Signature `json:"signature,omitempty"`
+}
+
+// Signature is the signature of an Envelope.
+type Signature struct {
+	Alg   string `json:"alg"` // "HS256" or "Ed25519"
+	KeyID string `json:"kid,omitempty"`
+	Value []byte `json:"value"` // base64 encoded in JSON
+}
+
+// SignatureError reports an envelope whose signature is missing or does
+// not verify.
+type SignatureError struct {
+	KeyID  string
+	Reason string
+}
+
+func (e *SignatureError) Error() string {
+	if e.KeyID == "" {
+		return "invalid patch signature: " + e.Reason
+	}
+	return fmt.Sprintf("invalid patch signature with key %q: %s", e.KeyID, e.Reason)
+}
+
+// Is makes SignatureError match ErrInvalidSignature.
+func (e *SignatureError) Is(target error) bool { return target == ErrInvalidSignature }
+
+// Code returns "invalid-signature".
+func (e *SignatureError) Code() string { return "invalid-signature" }
+
+// Signer signs the signing input of an Envelope.
+type Signer interface {
+	Sign(input []byte) (*Signature, error)
+}
+
+// Verifier checks the signature of an Envelope against its signing input,
+// returning a *SignatureError when it does not verify.
+type Verifier interface {
+	Verify(sig *Signature, input []byte) error
+}
+
+// HMACSigner signs envelopes with HMAC-SHA256 ("HS256").
+type HMACSigner struct {
+	KeyID string
+	Key   []byte
+}
+
+// Sign implements Signer.
+func (s HMACSigner) Sign(input []byte) (*Signature, error) {
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write(input)
+	return &Signature{Alg: "HS256", KeyID: s.KeyID, Value: mac.Sum(nil)}, nil
+}
+
+// Ed25519Signer signs envelopes with Ed25519 ("Ed25519").
+type Ed25519Signer struct {
+	KeyID string
+	Key   ed25519.PrivateKey
+}
+
+// Sign implements Signer.
+func (s Ed25519Signer) Sign(input []byte) (*Signature, error) {
+	return &Signature{Alg: "Ed25519", KeyID: s.KeyID, Value: ed25519.Sign(s.Key, input)}, nil
+}
+
+// HMACKeys verifies HS256 signatures with the keys of the map, by key ID.
+type HMACKeys map[string][]byte
+
+// Verify implements Verifier.
+func (k HMACKeys) Verify(sig *Signature, input []byte) error {
+	key, err := verificationKey(k, sig, "HS256")
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(input)
+	if !hmac.Equal(mac.Sum(nil), sig.Value) {
+		return &SignatureError{KeyID: sig.KeyID, Reason: "signature mismatch"}
+	}
+	return nil
+}
+
+// Ed25519Keys verifies Ed25519 signatures with the public keys of the map,
+// by key ID.
+type Ed25519Keys map[string]ed25519.PublicKey
+
+// Verify implements Verifier.
+func (k Ed25519Keys) Verify(sig *Signature, input []byte) error {
+	key, err := verificationKey(k, sig, "Ed25519")
+	if err != nil {
+		return err
+	}
+	if len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, input, sig.Value) {
+		return &SignatureError{KeyID: sig.KeyID, Reason: "signature mismatch"}
+	}
+	return nil
+}
+
+// verificationKey returns the key of keys sig was made with, when sig uses
+// the algorithm alg.
+func verificationKey[K interface{}](keys map[string]K, sig *Signature, alg string) (K, error) {
+	var zero K
+	if sig.Alg != alg {
+		return zero, &SignatureError{KeyID: sig.KeyID, Reason: fmt.Sprintf("unexpected algorithm %q", sig.Alg)}
+	}
+	key, ok := keys[sig.KeyID]
+	if !ok {
+		return zero, &SignatureError{KeyID: sig.KeyID, Reason: "unknown key"}
+	}
+	return key, nil
+}
+
+// NewEnvelope returns an Envelope holding patch, written by author now,
+// with a random ID.
+func NewEnvelope(author string, patch []Operation) *Envelope {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &Envelope{ID: hex.EncodeToString(id), Author: author, CreatedAt: time.Now().UTC(), Patch: patch}
+}
+
+// ValidityError reports an envelope applied outside of its validity period.
+type ValidityError struct {
+	NotBefore time.Time
+	ExpiresAt time.Time
+	Now       time.Time // the time the envelope was checked at
+}
+
+func (e *ValidityError) Error() string {
+	if e.expired() {
+		return fmt.Sprintf("patch expired at %s", e.ExpiresAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("patch not valid before %s", e.NotBefore.Format(time.RFC3339))
+}
+
+func (e *ValidityError) expired() bool {
+	return !e.ExpiresAt.IsZero() && !e.Now.Before(e.ExpiresAt)
+}
+
+// Is makes ValidityError match ErrExpired or ErrNotYetValid.
+func (e *ValidityError) Is(target error) bool {
+	if e.expired() {
+		return target == ErrExpired
+	}
+	return target == ErrNotYetValid
+}
+
+// Code returns "patch-expired" or "patch-not-yet-valid".
+func (e *ValidityError) Code() string {
+	if e.expired() {
+		return "patch-expired"
+	}
+	return "patch-not-yet-valid"
+}
+
+// ParseEnvelope decodes an Envelope.
+func ParseEnvelope(data []byte) (*Envelope, error) {
+	var e Envelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Check returns a ValidityError when now is before NotBefore, or at or
+// after ExpiresAt.
+func (e *Envelope) Check(now time.Time) error {
+	err := &ValidityError{NotBefore: e.NotBefore, ExpiresAt: e.ExpiresAt, Now: now}
+	if err.expired() || !e.NotBefore.IsZero() && now.Before(e.NotBefore) {
+		return err
+	}
+	return nil
+}
+
+// SigningInput returns the bytes a signature of e covers: e encoded as JSON
+// without its signature, as by encoding/json with the values of the
+// operations compacted. It only depends on the members of e, so an
+// envelope decoded from JSON has the signing input it was signed with.
+func (e *Envelope) SigningInput() ([]byte, error) {
+	unsigned := *e
+	unsigned.Signature = nil
+	return marshal(&unsigned)
+}
+
+// Sign signs e with s, replacing its signature.
+func (e *Envelope) Sign(s Signer) error {
+	input, err := e.SigningInput()
+	if err != nil {
+		return err
+	}
+	sig, err := s.Sign(input)
+	if err != nil {
+		return err
+	}
+	e.Signature = sig
+	return nil
+}
+
+// Verify checks the signature of e with v. An envelope without a signature
+// fails with a *SignatureError.
+func (e *Envelope) Verify(v Verifier) error {
+	if e.Signature == nil {
+		return &SignatureError{Reason: "missing signature"}
+	}
+	input, err := e.SigningInput()
+	if err != nil {
+		return err
+	}
+	return v.Verify(e.Signature, input)
+}
+
+// ApplyVerified verifies the signature of e with v before applying it with
+// Apply.
+func (e *Envelope) ApplyVerified(doc interface{}, v Verifier, opts ...Option) (interface{}, error) {
+	if err := e.Verify(v); err != nil {
+		return nil, err
+	}
+	return e.Apply(doc, opts...)
+}
+
+// Apply checks the envelope against the clock of the options, and applies
+// its patch to doc like Apply when it is valid. It does not check the
+// signature; see ApplyVerified.
+func (e *Envelope) Apply(doc interface{}, opts ...Option) (interface{}, error) {
+	if err := e.Check(newOptions(opts).now()); err != nil {
+		return nil, err
+	}
+	return Apply(doc, e.Patch, opts...)
+}
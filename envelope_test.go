@@ -0,0 +1,108 @@
+package patch
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEnvelope(t *testing.T) {
+	e, err := ParseEnvelope([]byte(`{
+		"notBefore": "2024-06-01T09:00:00Z",
+		"expiresAt": "2024-06-02T09:00:00Z",
+		"patch": [{"op": "replace", "path": "/banner", "value": "launch"}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := decode(`{"banner": "soon"}`)
+	at := func(s string) Option {
+		now, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return WithClock(func() time.Time { return now })
+	}
+
+	result, err := e.Apply(doc, at("2024-06-01T09:00:00Z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := decode(`{"banner": "launch"}`); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+
+	_, err = e.Apply(doc, at("2024-06-01T08:59:59Z"))
+	var verr *ValidityError
+	if !errors.Is(err, ErrNotYetValid) || errors.Is(err, ErrExpired) || !errors.As(err, &verr) || verr.Code() != "patch-not-yet-valid" {
+		t.Errorf("expected ErrNotYetValid, got %v", err)
+	}
+	_, err = e.Apply(doc, at("2024-06-02T09:00:00Z"))
+	if !errors.Is(err, ErrExpired) || errors.Is(err, ErrNotYetValid) {
+		t.Errorf("expected ErrExpired, got %v", err)
+	}
+
+	// times are optional
+	open := &Envelope{Patch: e.Patch}
+	if err := open.Check(time.Time{}); err != nil {
+		t.Error(err)
+	}
+	if _, err := open.Apply(doc); err != nil {
+		t.Error(err)
+	}
+	if b := string(mustMarshal(t, open)); b != `{"patch":[{"op":"replace","path":"/banner","value":"launch"}]}` {
+		t.Errorf("unexpected encoding %s", b)
+	}
+}
+
+func TestSignedEnvelope(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers := []struct {
+		signer   Signer
+		verifier Verifier
+	}{
+		{HMACSigner{KeyID: "k1", Key: []byte("secret")}, HMACKeys{"k1": []byte("secret")}},
+		{Ed25519Signer{KeyID: "k2", Key: priv}, Ed25519Keys{"k2": pub}},
+	}
+	for _, s := range signers {
+		e := NewEnvelope("alice", parseStr(`[{"op": "add", "path": "/a", "value": {"b":  1}}]`))
+		if len(e.ID) != 32 || e.CreatedAt.IsZero() {
+			t.Errorf("expected an ID and a creation time, got %+v", e)
+		}
+		if err := e.Sign(s.signer); err != nil {
+			t.Fatal(err)
+		}
+
+		// the signature survives a round trip through JSON
+		decoded, err := ParseEnvelope(mustMarshal(t, e))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := decoded.ApplyVerified(decode(`{}`), s.verifier)
+		if err != nil || !jsonEqual(got, decode(`{"a": {"b": 1}}`)) {
+			t.Errorf("%s: unexpected result %v, %v", e.Signature.Alg, got, err)
+		}
+
+		decoded.Author = "mallory"
+		var serr *SignatureError
+		if err := decoded.Verify(s.verifier); !errors.Is(err, ErrInvalidSignature) || !errors.As(err, &serr) || serr.Code() != "invalid-signature" {
+			t.Errorf("%s: expected a tampered envelope to be refused, got %v", e.Signature.Alg, err)
+		}
+	}
+
+	e := NewEnvelope("alice", nil)
+	if _, err := e.ApplyVerified(decode(`{}`), HMACKeys{}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected an unsigned envelope to be refused, got %v", err)
+	}
+	e.Sign(HMACSigner{KeyID: "k1", Key: []byte("secret")})
+	for _, v := range []Verifier{HMACKeys{"other": []byte("secret")}, Ed25519Keys{"k1": pub}, HMACKeys{"k1": []byte("wrong")}} {
+		if err := e.Verify(v); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("expected the signature not to verify, got %v", err)
+		}
+	}
+}
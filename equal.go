@@ -0,0 +1,228 @@
+package patch
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// jsonEqual reports whether a and b are the same JSON value. Numbers are
+// compared by value whatever their Go type, so json.Number("1.0"),
+// json.Number("1") and float64(1) are all equal.
+func jsonEqual(a, b interface{}) bool {
+	if ao, ok := a.(*SortedObject); ok {
+		return sortedEqual(ao, b)
+	}
+	if bo, ok := b.(*SortedObject); ok {
+		return sortedEqual(bo, a)
+	}
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			w, ok := bv[k]
+			if !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case string, bool, nil:
+		return a == b
+	case float64:
+		if bv, ok := b.(float64); ok {
+			return av == bv && !math.IsInf(av, 0)
+		}
+	}
+	an, _ := a.(json.Number)
+	bn, _ := b.(json.Number)
+	if an != "" && an == bn {
+		return true
+	}
+	if an != "" && bn != "" && !mayBeEqual(an, bn) {
+		return false
+	}
+	x, ok := toRat(a)
+	if !ok {
+		return false
+	}
+	y, ok := toRat(b)
+	return ok && x.Cmp(y) == 0
+}
+
+// mayBeEqual is a quick check of whether two numbers can be equal: parsing
+// preserves order, so numbers that parse to different float64 values
+// differ.
+func mayBeEqual(a, b json.Number) bool {
+	x, err := strconv.ParseFloat(string(a), 64)
+	if err != nil || math.IsInf(x, 0) {
+		return true
+	}
+	y, err := strconv.ParseFloat(string(b), 64)
+	return err != nil || math.IsInf(y, 0) || x == y
+}
+
+// maxExponentDigits bounds the exponents of numbers compared exactly.
+const maxExponentDigits = 4
+
+// toRat returns the exact value of a number as decoded by encoding/json or
+// stored by callers.
+func toRat(v interface{}) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		// an exponent this large would take arbitrary time and memory to
+		// expand, and is beyond anything a JSON producer means exactly
+		if i := strings.IndexAny(string(n), "eE"); i >= 0 && len(n)-i > maxExponentDigits+2 {
+			return nil, false
+		}
+		return new(big.Rat).SetString(string(n))
+	case float64:
+		if math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(n), true
+	case float32:
+		return toRat(float64(n))
+	case int:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int64:
+		return new(big.Rat).SetInt64(n), true
+	case int32:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(n)), true
+	case uint:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(uint64(n))), true
+	}
+	return nil, false
+}
+
+// sortedEqual is jsonEqual for a SortedObject and any value.
+func sortedEqual(a *SortedObject, b interface{}) bool {
+	var get func(string) (interface{}, bool)
+	switch bv := b.(type) {
+	case map[string]interface{}:
+		if len(bv) != a.Len() {
+			return false
+		}
+		get = func(k string) (interface{}, bool) { v, ok := bv[k]; return v, ok }
+	case *SortedObject:
+		if bv.Len() != a.Len() {
+			return false
+		}
+		get = bv.Get
+	default:
+		return false
+	}
+	for k, v := range a.All() {
+		w, ok := get(k)
+		if !ok || !jsonEqual(v, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// Equaler decides whether the value of a test operation, expected, matches
+// the value it tests, actual. Both are decoded JSON values, and must not be
+// modified.
+type Equaler interface {
+	Equal(expected, actual interface{}) bool
+}
+
+// EqualFunc is a function implementing Equaler.
+type EqualFunc func(expected, actual interface{}) bool
+
+// Equal implements Equaler.
+func (f EqualFunc) Equal(expected, actual interface{}) bool { return f(expected, actual) }
+
+// WithEqualer compares values for test operations with e. See
+// Options.Equaler.
+func WithEqualer(e Equaler) Option {
+	return func(o *Options) { o.Equaler = e }
+}
+
+// testEqual reports whether the value of a test operation matches actual,
+// under Options.Equaler.
+func (a *applier) testEqual(expected, actual interface{}) bool {
+	if a.opts.Equaler != nil {
+		return a.opts.Equaler.Equal(expected, actual)
+	}
+	return jsonEqual(expected, actual)
+}
+
+// Equality is an Equaler relaxing the JSON equality test operations use by
+// default, in which numbers are equal when they have the same value, so
+// that 1 and 1.0 are, and other values when they are identical.
+type Equality struct {
+	// Epsilon is the largest difference between two numbers that are
+	// considered equal.
+	Epsilon float64 `json:"epsilon,omitempty"`
+	// IgnoreCase compares strings without regard to case, under Unicode
+	// case folding.
+	IgnoreCase bool `json:"ignoreCase,omitempty"`
+}
+
+// Equal implements Equaler, comparing objects member by member and arrays
+// element by element.
+func (e Equality) Equal(expected, actual interface{}) bool {
+	if o, ok := expected.(*SortedObject); ok {
+		expected = o.Map()
+	}
+	if o, ok := actual.(*SortedObject); ok {
+		actual = o.Map()
+	}
+	switch x := expected.(type) {
+	case map[string]interface{}:
+		y, ok := actual.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !e.Equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := actual.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !e.Equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case string:
+		if y, ok := actual.(string); ok && e.IgnoreCase {
+			return strings.EqualFold(x, y)
+		}
+	}
+	if e.Epsilon > 0 {
+		if x, ok := toRat(expected); ok {
+			if y, ok := toRat(actual); ok {
+				d, _ := new(big.Rat).Sub(x, y).Float64()
+				return math.Abs(d) <= e.Epsilon
+			}
+		}
+	}
+	return jsonEqual(expected, actual)
+}
@@ -0,0 +1,93 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestJSONEqual(t *testing.T) {
+	for _, tc := range []struct {
+		a, b  interface{}
+		equal bool
+	}{
+		{json.Number("1"), json.Number("1.0"), true},
+		{json.Number("1"), float64(1), true},
+		{json.Number("100"), json.Number("1e2"), true},
+		{json.Number("0.1"), float64(0.1), false},
+		{json.Number("9007199254740993"), json.Number("9007199254740992"), false},
+		{json.Number("9007199254740993"), int64(9007199254740993), true},
+		{json.Number("1e100000"), json.Number("1e100000"), true},
+		{json.Number("1e100000"), json.Number("10e99999"), false},
+		{json.Number("1"), "1", false},
+		{float64(2), int(2), true},
+		{float64(2), float64(2), true},
+		{math.Inf(1), math.Inf(1), false},
+		{json.Number("1.5"), json.Number("1.25"), false},
+		{json.Number("1e-400"), json.Number("2e-400"), false},
+		{nil, nil, true},
+		{nil, false, false},
+		{map[string]interface{}{"a": json.Number("1.50")}, map[string]interface{}{"a": 1.5}, true},
+		{map[string]interface{}{"a": 1.0}, map[string]interface{}{"b": 1.0}, false},
+		{[]interface{}{json.Number("1")}, []interface{}{1.0, 2.0}, false},
+		{[]interface{}{"x"}, map[string]interface{}{}, false},
+	} {
+		if got := jsonEqual(tc.a, tc.b); got != tc.equal {
+			t.Errorf("jsonEqual(%#v, %#v) = %v", tc.a, tc.b, got)
+		}
+	}
+}
+
+func TestUseNumber(t *testing.T) {
+	var doc interface{}
+	if err := unmarshalNumber([]byte(`{"id": 9007199254740993, "price": 1.10}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	ops := parseStr(`[
+		{"op": "test", "path": "/price", "value": 1.1},
+		{"op": "test", "path": "/id", "value": 9007199254740993},
+		{"op": "add", "path": "/next", "value": 9007199254740995}
+	]`)
+	result, err := Apply(doc, ops, WithUseNumber())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := marshal(result)
+	if string(out) != `{"id":9007199254740993,"next":9007199254740995,"price":1.10}` {
+		t.Errorf("numbers not preserved: %s", out)
+	}
+
+	_, err = Apply(doc, parseStr(`[{"op": "test", "path": "/id", "value": 9007199254740992}]`), WithUseNumber())
+	if !errors.Is(err, ErrTestFailed) {
+		t.Errorf("expected neighbouring integers to differ, got %v", err)
+	}
+}
+
+func TestEqualer(t *testing.T) {
+	doc := decode(`{"price": 9.99, "name": "Widget", "tags": ["A", "b"], "n": 1}`)
+	cases := []struct {
+		test  string
+		eq    Equaler
+		match bool
+	}{
+		{`{"op": "test", "path": "/n", "value": 1.0}`, nil, true},
+		{`{"op": "test", "path": "/price", "value": 9.990001}`, nil, false},
+		{`{"op": "test", "path": "/price", "value": 9.990001}`, Equality{Epsilon: 1e-5}, true},
+		{`{"op": "test", "path": "/price", "value": 9.98}`, Equality{Epsilon: 1e-5}, false},
+		{`{"op": "test", "path": "/name", "value": "WIDGET"}`, Equality{IgnoreCase: true}, true},
+		{`{"op": "test", "path": "", "value": {"price": 10, "name": "widget", "tags": ["a", "B"], "n": 1}}`, Equality{Epsilon: 0.02, IgnoreCase: true}, true},
+		{`{"op": "test", "path": "/tags", "value": ["a"]}`, Equality{IgnoreCase: true}, false},
+		{`{"op": "test", "path": "/name", "value": "anything"}`, EqualFunc(func(expected, actual interface{}) bool { return true }), true},
+	}
+	for _, c := range cases {
+		var opts []Option
+		if c.eq != nil {
+			opts = append(opts, WithEqualer(c.eq))
+		}
+		_, err := Apply(doc, parseStr("["+c.test+"]"), opts...)
+		if c.match && err != nil || !c.match && !errors.Is(err, ErrTestFailed) {
+			t.Errorf("%s with %#v: unexpected result %v", c.test, c.eq, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+var (
+	// ErrInvalidPatch matches errors for operations that are malformed,
+	// regardless of the document they are applied to.
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrNotFound matches errors for pointers that do not resolve to a
+	// value in the document.
+	ErrNotFound = pointer.ErrNotFound
+	// ErrTestFailed matches errors for test operations whose value did not
+	// match the document.
+	ErrTestFailed = errors.New("test failed")
+)
+
+// InvalidPatchError reports a malformed operation: an unknown operator, a
+// missing member or a malformed pointer.
+type InvalidPatchError struct {
+	Index int    // index of the operation within the patch
+	Op    string // operator name
+	Err   error
+}
+
+func (e *InvalidPatchError) Error() string {
+	return fmt.Sprintf("operation %d (%s): %v", e.Index, e.Op, e.Err)
+}
+
+func (e *InvalidPatchError) Unwrap() error { return e.Err }
+
+// Is makes InvalidPatchError match ErrInvalidPatch.
+func (e *InvalidPatchError) Is(target error) bool { return target == ErrInvalidPatch }
+
+// Code returns "invalid-patch".
+func (e *InvalidPatchError) Code() string { return "invalid-patch" }
+
+// PathError reports an operation that could not be carried out on the
+// document, for example because its pointer does not resolve or indexes
+// into a scalar.
+type PathError struct {
+	Index int
+	Op    string
+	Path  string
+	Err   error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("operation %d (%s %s): %v", e.Index, e.Op, e.Path, e.Err)
+}
+
+func (e *PathError) Unwrap() error { return e.Err }
+
+// Code returns "path-not-found" when the error matches ErrNotFound, and
+// "path-invalid" otherwise.
+func (e *PathError) Code() string {
+	if errors.Is(e.Err, ErrNotFound) {
+		return "path-not-found"
+	}
+	return "path-invalid"
+}
+
+// TestFailedError reports a test operation whose value did not match.
+type TestFailedError struct {
+	Index    int
+	Path     string
+	Expected interface{}
+	Actual   interface{}
+}
+
+func (e *TestFailedError) Error() string {
+	return fmt.Sprintf("operation %d: test failed: %s expected to be %s, found %s", e.Index, e.Path, jsonText(e.Expected), jsonText(e.Actual))
+}
+
+// jsonText formats v as JSON, so that values of different types, such as
+// the string "1" and the number 1, read differently in error messages.
+func jsonText(v interface{}) string {
+	b, err := marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+// Is makes TestFailedError match ErrTestFailed.
+func (e *TestFailedError) Is(target error) bool { return target == ErrTestFailed }
+
+// Code returns "test-failed".
+func (e *TestFailedError) Code() string { return "test-failed" }
+
+// opError attaches the index and operation to an error returned while
+// applying the operation.
+func opError(i int, op *Operation, err error) error {
+	var inv *InvalidPatchError
+	var test *TestFailedError
+	var path *PathError
+	switch {
+	case errors.As(err, &inv):
+		inv.Index, inv.Op = i, op.Op
+		return inv
+	case errors.As(err, &test):
+		test.Index = i
+		return test
+	case errors.As(err, &path):
+		return path
+	}
+	return &PathError{Index: i, Op: op.Op, Path: op.Path, Err: err}
+}
@@ -0,0 +1,83 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTypedErrors(t *testing.T) {
+	doc := map[string]interface{}{"a": 1.0, "list": []interface{}{}}
+	cases := []struct {
+		patch string
+		index int
+		code  string
+		is    error
+	}{
+		{`[{"op": "test", "path": "/a", "value": 1}, {"op": "test", "path": "/a", "value": 2}]`, 1, "test-failed", ErrTestFailed},
+		{`[{"op": "bogus", "path": "/a"}]`, 0, "invalid-patch", ErrInvalidPatch},
+		{`[{"op": "add", "path": "/b"}]`, 0, "invalid-patch", ErrInvalidPatch},
+		{`[{"op": "move", "path": "/b"}]`, 0, "invalid-patch", ErrInvalidPatch},
+		{`[{"op": "add", "path": "/b", "value": 1}, {"op": "remove", "path": "/missing"}]`, 1, "path-not-found", ErrNotFound},
+		{`[{"op": "replace", "path": "/missing", "value": 1}]`, 0, "path-not-found", ErrNotFound},
+		{`[{"op": "add", "path": "/x/y", "value": 1}]`, 0, "path-not-found", ErrNotFound},
+		{`[{"op": "test", "path": "/missing", "value": null}]`, 0, "path-not-found", ErrNotFound},
+		{`[{"op": "add", "path": "/a/b", "value": 1}]`, 0, "path-invalid", nil},
+	}
+	for _, c := range cases {
+		// operations lacking a required member fail to parse
+		ops, err := Parse([]byte(c.patch))
+		if err == nil {
+			_, err = Apply(doc, ops)
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", c.patch)
+			continue
+		}
+		coded, ok := err.(interface {
+			Code() string
+			Error() string
+		})
+		if !ok || coded.Code() != c.code {
+			t.Errorf("%s: expected code %s, got %v", c.patch, c.code, err)
+		}
+		if c.is != nil && !errors.Is(err, c.is) {
+			t.Errorf("%s: expected %v to match %v", c.patch, err, c.is)
+		}
+		var index int
+		var inv *InvalidPatchError
+		var path *PathError
+		var test *TestFailedError
+		switch {
+		case errors.As(err, &inv):
+			index = inv.Index
+		case errors.As(err, &path):
+			index = path.Index
+		case errors.As(err, &test):
+			index = test.Index
+		default:
+			t.Errorf("%s: unexpected error type %T", c.patch, err)
+		}
+		if index != c.index {
+			t.Errorf("%s: expected index %d, got %d", c.patch, c.index, index)
+		}
+	}
+}
+
+func TestTestFailedErrorValues(t *testing.T) {
+	_, err := Apply(map[string]interface{}{"a": "x"}, parseStr(`[{"op": "test", "path": "/a", "value": "y"}]`))
+	var test *TestFailedError
+	if !errors.As(err, &test) {
+		t.Fatalf("expected a TestFailedError, got %v", err)
+	}
+	if test.Path != "/a" || test.Expected != "y" || test.Actual != "x" {
+		t.Errorf("unexpected error contents %+v", test)
+	}
+}
+
+func TestTestFailedErrorMessage(t *testing.T) {
+	_, err := Apply(map[string]interface{}{"a": 1.0}, parseStr(`[{"op": "test", "path": "/a", "value": "1"}]`))
+	expected := `operation 0: test failed: /a expected to be "1", found 1`
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
@@ -0,0 +1,153 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// FromFieldMask converts an update described by a google.protobuf.FieldMask
+// to operations. mask holds the paths of the FieldMask, dot-separated field
+// names, and newValues the message holding the new values, encoded as JSON
+// and decoded into an interface{}. Each masked field set in newValues
+// becomes a replace, and each one missing from it a remove, since a field
+// in the mask but not in the message is cleared. The path "*" replaces the
+// whole document.
+//
+// Field names are used as member names verbatim, so messages should be
+// encoded with the names of their fields, as protojson does with
+// UseProtoNames, when the mask uses them. The operations expect the masked
+// fields to be set in the document, like replace and remove do.
+func FromFieldMask(mask []string, newValues interface{}) ([]Operation, error) {
+	ops := make([]Operation, 0, len(mask))
+	for _, p := range mask {
+		if p == "*" {
+			raw, err := marshal(newValues)
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, Operation{Op: "replace", Path: "", Value: raw})
+			continue
+		}
+		fields := strings.Split(p, ".")
+		for _, f := range fields {
+			if f == "" {
+				return nil, fmt.Errorf("field mask path %q has an empty field name", p)
+			}
+		}
+		path := pointer.New(fields...)
+		v, err := path.Get(newValues)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			ops = append(ops, Operation{Op: "remove", Path: path.String()})
+		case err != nil:
+			return nil, fmt.Errorf("field mask path %q: %w", p, err)
+		default:
+			raw, err := marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, Operation{Op: "replace", Path: path.String(), Value: raw})
+		}
+	}
+	return ops, nil
+}
+
+// ToFieldMask converts operations to the paths of a google.protobuf.FieldMask
+// and the message, as a JSON object, holding the new values of the masked
+// fields, so that FromFieldMask(mask, values) describes the same update. A
+// field mask can only set and clear fields, so:
+//
+//   - test, move and copy operations cannot be converted;
+//   - paths must address object members whose names have no dots: tokens
+//     that may be array indexes, including "-", are refused, since a field
+//     mask replaces repeated fields whole;
+//   - a path may not be inside or above the path of another operation,
+//     except for the whole document, which becomes the path "*".
+//
+// Masked fields are listed in the order of their first operation. When an
+// operation cannot be converted, ToFieldMask returns an error matching
+// ErrInvalidPatch.
+func ToFieldMask(operations []Operation) ([]string, interface{}, error) {
+	var mask []string
+	var values interface{} = map[string]interface{}{}
+	for i, op := range operations {
+		var err error
+		if mask, values, err = toFieldMask(mask, values, op); err != nil {
+			return nil, nil, &InvalidPatchError{Index: i, Op: op.Op, Err: err}
+		}
+	}
+	return mask, values, nil
+}
+
+// toFieldMask adds op to mask and values and returns them.
+func toFieldMask(mask []string, values interface{}, op Operation) ([]string, interface{}, error) {
+	var value interface{}
+	switch op.Op {
+	case "add", "replace":
+		if op.Value == nil {
+			return nil, nil, fmt.Errorf("missing 'value' parameter")
+		}
+		if err := unmarshalNumber(op.Value, &value); err != nil {
+			return nil, nil, fmt.Errorf("invalid 'value' parameter: %v", err)
+		}
+	case "remove":
+	default:
+		return nil, nil, fmt.Errorf("%s operations cannot be expressed in a field mask", op.Op)
+	}
+	path, err := parsePath(op.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(path) == 0 {
+		if op.Op == "remove" {
+			return nil, nil, fmt.Errorf("the whole document cannot be removed")
+		}
+		if len(mask) > 0 && mask[0] != "*" {
+			return nil, nil, fmt.Errorf("the whole document cannot be replaced after its fields")
+		}
+		return []string{"*"}, value, nil
+	}
+	if len(mask) > 0 && mask[0] == "*" {
+		return nil, nil, fmt.Errorf("%s is inside the document replaced by an earlier operation", op.Path)
+	}
+	for _, token := range path {
+		if isIndex(token) {
+			return nil, nil, fmt.Errorf("array element %q cannot be addressed in a field mask", token)
+		}
+		if token == "" || strings.Contains(token, ".") {
+			return nil, nil, fmt.Errorf("member %q cannot be named in a field mask", token)
+		}
+	}
+	field := strings.Join(path, ".")
+	known := false
+	for _, m := range mask {
+		if m == field {
+			known = true
+		} else if strings.HasPrefix(m, field+".") || strings.HasPrefix(field, m+".") {
+			return nil, nil, fmt.Errorf("field %s overlaps field %s of an earlier operation", field, m)
+		}
+	}
+	if !known {
+		mask = append(mask, field)
+	}
+
+	// the objects of values are only ever created here
+	parent := values.(map[string]interface{})
+	for _, token := range path[:len(path)-1] {
+		child, ok := parent[token].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			parent[token] = child
+		}
+		parent = child
+	}
+	if op.Op == "remove" {
+		delete(parent, path[len(path)-1])
+	} else {
+		parent[path[len(path)-1]] = value
+	}
+	return mask, values, nil
+}
@@ -0,0 +1,72 @@
+package patch
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestFromFieldMask(t *testing.T) {
+	values := decode(`{"display_name": "New", "config": {"limits": {"cpu": 2}}}`)
+	ops, err := FromFieldMask([]string{"display_name", "config.limits", "config.labels"}, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Apply(decode(`{"display_name": "Old", "config": {"limits": {"cpu": 1, "memory": 4}, "labels": {"a": "b"}}, "id": 7}`), ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !jsonEqual(got, decode(`{"display_name": "New", "config": {"limits": {"cpu": 2}}, "id": 7}`)) {
+		t.Errorf("unexpected document %v", got)
+	}
+
+	ops, err = FromFieldMask([]string{"*"}, values)
+	if err != nil || len(ops) != 1 || ops[0].Path != "" {
+		t.Errorf("expected the whole document to be replaced, got %v, %v", ops, err)
+	}
+	if _, err := FromFieldMask([]string{"config..limits"}, values); err == nil {
+		t.Error("expected an empty field name to be refused")
+	}
+	if _, err := FromFieldMask([]string{"display_name.x"}, values); err == nil {
+		t.Error("expected a field inside a scalar to be refused")
+	}
+}
+
+func TestToFieldMask(t *testing.T) {
+	mask, values, err := ToFieldMask(parseStr(`[
+		{"op": "replace", "path": "/display_name", "value": "New"},
+		{"op": "add", "path": "/config/limits", "value": {"cpu": 2}},
+		{"op": "remove", "path": "/config/labels"},
+		{"op": "replace", "path": "/display_name", "value": "Newer"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(mask, []string{"display_name", "config.limits", "config.labels"}) {
+		t.Errorf("unexpected mask %v", mask)
+	}
+	if !jsonEqual(values, decode(`{"display_name": "Newer", "config": {"limits": {"cpu": 2}}}`)) {
+		t.Errorf("unexpected values %v", values)
+	}
+	ops, err := FromFieldMask(mask, values)
+	if err != nil || len(ops) != 3 || ops[2].Op != "remove" {
+		t.Errorf("expected the mask to convert back, got %v, %v", ops, err)
+	}
+
+	for _, p := range []string{
+		`[{"op": "move", "from": "/a", "path": "/b"}]`,
+		`[{"op": "replace", "path": "/items/0", "value": 1}]`,
+		`[{"op": "replace", "path": "/a.b", "value": 1}]`,
+		`[{"op": "replace", "path": "/a", "value": {}}, {"op": "replace", "path": "/a/b", "value": 1}]`,
+		`[{"op": "replace", "path": "", "value": {}}, {"op": "replace", "path": "/a", "value": 1}]`,
+		`[{"op": "remove", "path": ""}]`,
+	} {
+		if _, _, err := ToFieldMask(parseStr(p)); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%s: expected an invalid patch, got %v", p, err)
+		}
+	}
+	mask, values, err = ToFieldMask(parseStr(`[{"op": "replace", "path": "", "value": {"a": 1}}]`))
+	if err != nil || !slices.Equal(mask, []string{"*"}) || !jsonEqual(values, decode(`{"a": 1}`)) {
+		t.Errorf("expected the whole document to be masked, got %v, %v, %v", mask, values, err)
+	}
+}
@@ -0,0 +1,76 @@
+package patch
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// format converts between an encoding of documents and the values
+// operations work on: map[string]interface{}, []interface{}, string, bool,
+// nil and json.Number, as decoded by ApplyBytes. Binary formats decode
+// floating point numbers that are not finite to float64, which JSON cannot
+// represent.
+type format interface {
+	decode(data []byte) (interface{}, error)
+	encode(v interface{}) ([]byte, error)
+}
+
+// applyFormat decodes doc with f, applies operations to it and encodes the
+// result with f. Values of operations are decoded with json.Number, like the
+// numbers of the document.
+func applyFormat(f format, doc []byte, operations []Operation, opts []Option) ([]byte, error) {
+	o, err := f.decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	// o is private to this call and needs no copy
+	a := &applier{opts: newOptions(opts), useNumber: true}
+	result, err := a.apply(o, operations)
+	if err != nil {
+		return nil, err
+	}
+	return f.encode(result)
+}
+
+// jsonFormat is JSON, as encoded and decoded by the codec set with SetCodec.
+type jsonFormat struct{}
+
+func (jsonFormat) decode(data []byte) (interface{}, error) {
+	var v interface{}
+	err := currentCodec().UnmarshalNumber(data, &v)
+	return v, err
+}
+
+func (jsonFormat) encode(v interface{}) ([]byte, error) { return currentCodec().Marshal(v) }
+
+// floatNumber returns f as a json.Number that does not read as an integer,
+// so that encoding it again gives a floating point number, or f itself when
+// it is not finite.
+func floatNumber(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return f
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return json.Number(s)
+}
+
+// normalize returns v with the types a binary format encodes: values of other types,
+// such as the structs an operator may produce, go through encoding/json.
+func normalize(v interface{}) (interface{}, error) {
+	switch v.(type) {
+	case nil, bool, string, json.Number, float64, map[string]interface{}, []interface{}:
+		return v, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = unmarshalNumber(b, &out)
+	return out, err
+}
@@ -0,0 +1,215 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Group is the outcome of a group of operations applied by ApplyGroups.
+type Group struct {
+	// Operations are the indexes of the operations of the group in the
+	// patch, in the order they are applied.
+	Operations []int `json:"operations"`
+	// Applied reports whether every operation of the group was applied;
+	// otherwise none of them was.
+	Applied bool `json:"applied"`
+	// Err is the error of the operation that failed the group.
+	Err error `json:"-"`
+}
+
+// GroupError reports a group of operations left out by ApplyGroups. The
+// index of the operation it wraps is that in the patch.
+type GroupError struct {
+	Group int // index of the group
+	Err   error
+}
+
+func (e *GroupError) Error() string {
+	return fmt.Sprintf("group %d: %v", e.Group, e.Err)
+}
+
+func (e *GroupError) Unwrap() error { return e.Err }
+
+// ApplyGroups applies operations to a copy of o (or to o itself when
+// Options.InPlace is set) in groups, each listing the indexes of its
+// operations in the patch; every operation must be in exactly one group.
+// The groups are applied in order, each atomically: a group with a failing
+// operation leaves the document as it was before the group, and the next
+// groups are applied regardless. This sits between Apply, where a failing
+// operation fails the patch, and ContinueOnError, which is ignored here and
+// skips single operations.
+// With OnErrorHints, an operation skipped or replaced by its "default"
+// does not fail its group, while one whose hint is "abort" fails the patch.
+//
+// The document is returned along with the outcome of each group and the
+// errors of the groups left out, joined with errors.Join, each a
+// *GroupError. Errors that are not about a single group, such as a patch
+// exceeding Options.Limits or a malformed list of groups, fail the patch as
+// they would with Apply, and the document and outcomes are then nil.
+func ApplyGroups(o interface{}, operations []Operation, groups [][]int, opts ...Option) (interface{}, []Group, error) {
+	seen := make([]bool, len(operations))
+	for g, group := range groups {
+		for _, i := range group {
+			if i < 0 || i >= len(operations) {
+				return nil, nil, fmt.Errorf("group %d: no operation %d: %w", g, i, ErrInvalidPatch)
+			}
+			if seen[i] {
+				return nil, nil, fmt.Errorf("group %d: operation %d is in another group: %w", g, i, ErrInvalidPatch)
+			}
+			seen[i] = true
+		}
+	}
+	if i := slices.Index(seen, false); i >= 0 {
+		return nil, nil, fmt.Errorf("operation %d is in no group: %w", i, ErrInvalidPatch)
+	}
+
+	options := newOptions(opts)
+	if !options.InPlace {
+		o = deepCopy(o)
+	}
+	a := &applier{opts: options, groups: groups, outcomes: make([]Group, len(groups))}
+	result, err := a.apply(o, operations)
+	if ge := (*GroupError)(nil); err != nil && !errors.As(err, &ge) {
+		return nil, nil, err
+	}
+	return result, a.outcomes, err
+}
+
+// ApplyIsolated applies operations with ApplyGroups, in the groups returned
+// by Partition, so that a failing operation only leaves out the operations
+// touching the same part of the document.
+func ApplyIsolated(o interface{}, operations []Operation, opts ...Option) (interface{}, []Group, error) {
+	return ApplyGroups(o, operations, Partition(operations), opts...)
+}
+
+// Partition splits operations into groups whose operations touch disjoint
+// parts of the document, so that applying or leaving out one group has no
+// effect on the others. Two operations are in the same group when the
+// location one of them reads or writes, through its path or from, is
+// inside, above or at that of the other. Since adding or removing an array
+// element moves the elements after it, locations are cut before their first
+// token that may be an array index, including "-" and "*"; a path that is
+// not a JSON pointer, such as a JSONPath expression, stands for the whole
+// document.
+//
+// The groups are ordered by their first operation, and list their
+// operations in order.
+func Partition(operations []Operation) [][]int {
+	scopes := make([][][]string, len(operations))
+	for i, op := range operations {
+		scopes[i] = append(scopes[i], scopeOf(op.Path))
+		if op.Op == "move" || op.Op == "copy" {
+			scopes[i] = append(scopes[i], scopeOf(op.From))
+		}
+	}
+	// union-find over the operations
+	parent := make([]int, len(operations))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range operations {
+		for j := 0; j < i; j++ {
+			if find(i) != find(j) && scopesOverlap(scopes[i], scopes[j]) {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+	var groups [][]int
+	index := make(map[int]int)
+	for i := range operations {
+		root := find(i)
+		g, ok := index[root]
+		if !ok {
+			g = len(groups)
+			index[root] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
+
+// scopeOf returns the tokens of path up to the first one that may be an
+// array index, or none when path is not a JSON pointer.
+func scopeOf(path string) []string {
+	tokens, err := parsePath(path)
+	if err != nil {
+		return nil
+	}
+	for i, t := range tokens {
+		if t == "*" || isIndex(t) {
+			return tokens[:i]
+		}
+	}
+	return tokens
+}
+
+// scopesOverlap reports whether a location of a is inside, above or at one
+// of b.
+func scopesOverlap(a, b [][]string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if overlaps(x, y, false) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyGroups applies the groups of operations of a, each atomically, to o.
+// Like applyEach, it copies the objects and arrays along the paths of the
+// operations before modifying them, so that a failed group can be dropped.
+func (a *applier) applyGroups(o interface{}, operations []Operation) (interface{}, error) {
+	var errs []error
+	for g, group := range a.groups {
+		changes := 0
+		if a.report != nil {
+			changes = len(a.report.Changes)
+		}
+		next, err := o, error(nil)
+		for _, i := range group {
+			if err := a.interrupted(i); err != nil {
+				return nil, err
+			}
+			var ins *instruction
+			if ins, err = a.instruction(i, operations[i]); err != nil {
+				break
+			}
+			prev, opChanges := next, 0
+			if a.report != nil {
+				opChanges = len(a.report.Changes)
+			}
+			next = copyPath(next, ins.path)
+			if ins.op.Op == "move" {
+				next = copyPath(next, ins.from)
+			}
+			if next, err = a.exec(next, i, ins); err != nil && a.opts.OnErrorHints {
+				if next, err = a.recoverOp(prev, i, operations[i], err, opChanges); err != nil && operations[i].OnError == "abort" {
+					return nil, err
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		a.outcomes[g] = Group{Operations: group, Applied: err == nil, Err: err}
+		if err != nil {
+			if a.report != nil {
+				a.report.Changes = a.report.Changes[:changes]
+			}
+			errs = append(errs, &GroupError{Group: g, Err: err})
+			continue
+		}
+		o = next
+	}
+	return o, errors.Join(errs...)
+}
@@ -0,0 +1,73 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPartition(t *testing.T) {
+	ops := parseStr(`[
+		{"op": "replace", "path": "/users/alice/name", "value": "A"},
+		{"op": "add", "path": "/settings/theme", "value": "dark"},
+		{"op": "test", "path": "/users/alice", "value": {}},
+		{"op": "remove", "path": "/items/0"},
+		{"op": "replace", "path": "/items/3/name", "value": "x"},
+		{"op": "move", "from": "/settings", "path": "/archive/settings"},
+		{"op": "add", "path": "/users/bob", "value": {}}
+	]`)
+	expected := [][]int{{0, 2}, {1, 5}, {3, 4}, {6}}
+	if got := Partition(ops); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	if got := Partition(parseStr(`[{"op": "add", "path": "/a", "value": 1}, {"op": "test", "path": "", "value": {}}]`)); len(got) != 1 {
+		t.Errorf("expected the root to overlap everything, got %v", got)
+	}
+}
+
+func TestApplyIsolated(t *testing.T) {
+	doc := decode(`{"users": {"alice": {"name": "a"}}, "items": [1, 2], "settings": {}}`)
+	ops := parseStr(`[
+		{"op": "replace", "path": "/users/alice/name", "value": "A"},
+		{"op": "add", "path": "/settings/theme", "value": "dark"},
+		{"op": "remove", "path": "/items/0"},
+		{"op": "test", "path": "/users/alice/name", "value": "nope"},
+		{"op": "remove", "path": "/items/5"}
+	]`)
+	got, groups, err := ApplyIsolated(doc, ops)
+	if !jsonEqual(got, decode(`{"users": {"alice": {"name": "a"}}, "items": [1, 2], "settings": {"theme": "dark"}}`)) {
+		t.Errorf("unexpected document %v", got)
+	}
+	var ge *GroupError
+	if !errors.Is(err, ErrTestFailed) || !errors.Is(err, ErrNotFound) || !errors.As(err, &ge) || ge.Group != 0 {
+		t.Errorf("expected the first and last groups to fail, got %v", err)
+	}
+	applied := []bool{false, true, false}
+	if len(groups) != 3 {
+		t.Fatalf("unexpected groups %+v", groups)
+	}
+	for i, g := range groups {
+		if g.Applied != applied[i] || (g.Err == nil) != applied[i] {
+			t.Errorf("group %d: unexpected outcome %+v", i, g)
+		}
+	}
+	if !jsonEqual(doc, decode(`{"users": {"alice": {"name": "a"}}, "items": [1, 2], "settings": {}}`)) {
+		t.Error("expected the document to be left unchanged")
+	}
+}
+
+func TestApplyGroups(t *testing.T) {
+	ops := parseStr(`[{"op": "add", "path": "/a", "value": 1}, {"op": "add", "path": "/b", "value": 2}]`)
+	for _, groups := range [][][]int{{{0}}, {{0, 1}, {1}}, {{0, 2}, {1}}} {
+		if _, _, err := ApplyGroups(decode(`{}`), ops, groups); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%v: expected the groups to be refused, got %v", groups, err)
+		}
+	}
+	got, _, err := ApplyGroups(decode(`{}`), ops, [][]int{{1, 0}})
+	if err != nil || !jsonEqual(got, decode(`{"a": 1, "b": 2}`)) {
+		t.Errorf("unexpected result %v, %v", got, err)
+	}
+	if _, _, err := ApplyGroups(decode(`{}`), ops, [][]int{{0}, {1}}, WithLimits(Limits{MaxOperations: 1})); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected the limits to fail the patch, got %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOpHooks(t *testing.T) {
+	doc := decode(`{"a": 1, "list": [1], "metadata": {"owner": "x"}}`)
+	var log []string
+	before := func(op Operation, path string) error {
+		if strings.HasPrefix(path, "/metadata") && op.Op != "test" {
+			return errors.New("read-only")
+		}
+		log = append(log, "before "+op.Op+" "+path)
+		return nil
+	}
+	after := func(op Operation, oldValue, newValue interface{}) {
+		log = append(log, fmt.Sprintf("after %s %v -> %v", op.Op, oldValue, newValue))
+	}
+	result, err := Apply(doc, parseStr(`[
+		{"op": "replace", "path": "/a", "value": 2},
+		{"op": "add", "path": "/list/-", "value": 3},
+		{"op": "add", "path": "/list/0", "value": 0},
+		{"op": "test", "path": "/metadata/owner", "value": "x"},
+		{"op": "move", "from": "/a", "path": "/b"},
+		{"op": "remove", "path": "/list/1"}
+	]`), WithBeforeOp(before), WithAfterOp(after))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := decode(`{"b": 2, "list": [0, 3], "metadata": {"owner": "x"}}`); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	expected := []string{
+		"before replace /a", "after replace 1 -> 2",
+		"before add /list/1", "after add <nil> -> 3",
+		"before add /list/0", "after add <nil> -> 0",
+		"before test /metadata/owner",
+		"before move /b", "after move <nil> -> 2",
+		"before remove /list/1", "after remove 1 -> <nil>",
+	}
+	if !reflect.DeepEqual(log, expected) {
+		t.Errorf("expected %q, got %q", expected, log)
+	}
+
+	_, err = Apply(doc, parseStr(`[{"op": "remove", "path": "/metadata/owner"}]`), WithBeforeOp(before))
+	var pathErr *PathError
+	if !errors.As(err, &pathErr) || pathErr.Index != 0 || pathErr.Err.Error() != "read-only" {
+		t.Errorf("expected the operation to be vetoed, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package patch
+
+import (
+	"fmt"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// OpIdempotency is the verdict of Idempotency on one operation of a patch.
+type OpIdempotency struct {
+	// Idempotent is true when applying the operation again, as part of the
+	// same patch, neither fails nor changes the document.
+	Idempotent bool
+	// Reason explains why the operation is not idempotent.
+	Reason string
+}
+
+// IsIdempotent reports whether applying operations to a document they were
+// already applied to always succeeds and leaves it unchanged, so that a
+// consumer receiving the patch more than once may apply it again without
+// guarding it with test operations. See Idempotency for the rules.
+func IsIdempotent(operations []Operation) bool {
+	for _, v := range Idempotency(operations) {
+		if !v.Idempotent {
+			return false
+		}
+	}
+	return true
+}
+
+// Idempotency annotates each of operations with whether it keeps the patch
+// idempotent. The analysis only looks at the patch, so it is conservative:
+//
+//   - replace, and add to an object member, overwrite a value and are
+//     idempotent, unless a later operation overwrites one of their parents,
+//     which the operation needs to exist when applied again.
+//   - add with a last token that may be an array index or "-" may insert
+//     into an array, and is never idempotent.
+//   - test, and the source of copy, read a value, and are idempotent unless
+//     a later operation modifies that value or its parents. A copy into or
+//     over its own source is never idempotent.
+//   - remove fails once its value is gone, and move removes its source, so
+//     neither is idempotent; neither are custom operators.
+func Idempotency(operations []Operation) []OpIdempotency {
+	// write is a location an operation modifies; shifts is true when it
+	// may insert into or remove from an array.
+	type write struct {
+		path   []string
+		shifts bool
+	}
+	verdicts := make([]OpIdempotency, len(operations))
+	writes := make([][]write, len(operations))
+	reads := make([][]string, len(operations))
+	for i, op := range operations {
+		v := &verdicts[i]
+		path, err := parsePath(op.Path)
+		if err != nil {
+			v.Reason = err.Error()
+			continue
+		}
+		var from []string
+		if op.Op == "move" || op.Op == "copy" {
+			if from, err = parsePath(op.From); err != nil {
+				v.Reason = err.Error()
+				continue
+			}
+		}
+		inserts := len(path) > 0 && isIndex(path[len(path)-1])
+		switch op.Op {
+		case "replace":
+			writes[i] = []write{{path, false}}
+			v.Idempotent = true
+		case "test":
+			reads[i] = path
+			v.Idempotent = true
+		case "add", "copy":
+			writes[i] = []write{{path, inserts}}
+			reads[i] = from
+			switch {
+			case inserts:
+				v.Reason = "may insert into an array"
+			case from != nil && len(from) != len(path) && overlaps(from, path, false):
+				// copying into its own source nests the value again,
+				// and copying over it loses the source
+				v.Reason = "copies into or over its own source"
+			default:
+				v.Idempotent = true
+			}
+		case "remove":
+			writes[i] = []write{{path, inserts}}
+			v.Reason = "fails once the value is removed"
+		case "move":
+			writes[i] = []write{{from, len(from) > 0 && isIndex(from[len(from)-1])}, {path, inserts}}
+			v.Reason = "removes its source"
+		default:
+			v.Reason = fmt.Sprintf("%q is not a standard operator", op.Op)
+		}
+	}
+
+	for i := range operations {
+		v := &verdicts[i]
+		if !v.Idempotent {
+			continue
+		}
+	later:
+		for j := i + 1; j < len(operations); j++ {
+			for _, w := range writes[j] {
+				for _, own := range writes[i] {
+					if len(w.path) < len(own.path) && overlaps(w.path, own.path, w.shifts) {
+						*v = OpIdempotency{Reason: fmt.Sprintf("operation %d modifies a parent of %s", j, pointer.Pointer(own.path))}
+						break later
+					}
+				}
+				if reads[i] != nil && overlaps(w.path, reads[i], w.shifts) {
+					*v = OpIdempotency{Reason: fmt.Sprintf("operation %d modifies %s", j, pointer.Pointer(reads[i]))}
+					break later
+				}
+			}
+		}
+	}
+	return verdicts
+}
@@ -0,0 +1,72 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsIdempotent(t *testing.T) {
+	for _, tc := range []struct {
+		patch      string
+		idempotent bool
+	}{
+		{`[]`, true},
+		{`[{"op": "replace", "path": "/a", "value": 1}, {"op": "add", "path": "/b", "value": {}}, {"op": "add", "path": "/b/c", "value": 2}]`, true},
+		{`[{"op": "replace", "path": "", "value": {"a": [1]}}, {"op": "replace", "path": "/a/0", "value": 2}]`, true},
+		{`[{"op": "test", "path": "/a", "value": 1}, {"op": "replace", "path": "/b", "value": 2}]`, true},
+		{`[{"op": "replace", "path": "/a", "value": 2}, {"op": "test", "path": "/a", "value": 2}]`, true},
+		{`[{"op": "copy", "from": "/a", "path": "/b"}]`, true},
+		{`[{"op": "test", "path": "/a", "value": 1}, {"op": "replace", "path": "/a", "value": 2}]`, false},
+		{`[{"op": "test", "path": "/a/b", "value": 1}, {"op": "replace", "path": "/a", "value": {}}]`, false},
+		{`[{"op": "copy", "from": "/a", "path": "/b"}, {"op": "replace", "path": "/a", "value": 2}]`, false},
+		{`[{"op": "replace", "path": "/a/b", "value": 1}, {"op": "replace", "path": "/a", "value": {}}]`, false},
+		{`[{"op": "add", "path": "/list/-", "value": 1}]`, false},
+		{`[{"op": "add", "path": "/list/0", "value": 1}]`, false},
+		{`[{"op": "remove", "path": "/a"}]`, false},
+		{`[{"op": "move", "from": "/a", "path": "/b"}]`, false},
+		{`[{"op": "copy", "from": "/a", "path": "/a/b"}]`, false},
+		{`[{"op": "copy", "from": "/a/b", "path": "/a"}]`, false},
+		{`[{"op": "move", "from": "/a/b", "path": "/a"}]`, false},
+		{`[{"op": "copy", "from": "/a", "path": "/a"}]`, true},
+		{`[{"op": "frobnicate", "path": "/a"}]`, false},
+		{`[{"op": "replace", "path": "a", "value": 1}]`, false},
+	} {
+		if got := IsIdempotent(parseStr(tc.patch)); got != tc.idempotent {
+			t.Errorf("%s: expected %v, got %v", tc.patch, tc.idempotent, got)
+		}
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	ops := parseStr(`[
+		{"op": "test", "path": "/list/2", "value": "x"},
+		{"op": "replace", "path": "/name", "value": "x"},
+		{"op": "remove", "path": "/list/0"}
+	]`)
+	expected := []OpIdempotency{
+		{Reason: "operation 2 modifies /list/2"},
+		{Idempotent: true},
+		{Reason: "fails once the value is removed"},
+	}
+	if got := Idempotency(ops); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	// applying an idempotent patch again changes nothing
+	ops = parseStr(`[
+		{"op": "add", "path": "/a", "value": {"b": 1}},
+		{"op": "replace", "path": "/a/b", "value": 2},
+		{"op": "copy", "from": "/c", "path": "/a/d"}
+	]`)
+	if !IsIdempotent(ops) {
+		t.Fatal("expected the patch to be idempotent")
+	}
+	once, err := Apply(decode(`{"c": [1]}`), ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	twice, err := Apply(once, ops)
+	if err != nil || !reflect.DeepEqual(once, twice) {
+		t.Errorf("expected %v, got %v (%v)", once, twice, err)
+	}
+}
@@ -0,0 +1,213 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"iter"
+	"slices"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// maxImpactValues is the number of distinct values a Distribution counts
+// individually.
+const maxImpactValues = 20
+
+// ImpactReport is the outcome of a patch over a corpus of documents, as
+// computed by Impact.
+type ImpactReport struct {
+	Documents int `json:"documents"`
+	Applied   int `json:"applied"`
+	Failed    int `json:"failed"`
+	// Failures groups the failed documents by the code of their error and
+	// the operation that failed, in the order the groups were first seen.
+	Failures []ImpactFailure `json:"failures,omitempty"`
+	// Changes describes every pointer whose value the patch changed in at
+	// least one document, in the order it was first changed.
+	Changes []PointerImpact `json:"changes,omitempty"`
+}
+
+// ImpactFailure is a group of documents the patch failed on the same way.
+type ImpactFailure struct {
+	Code  string `json:"code"`  // the Code of the error, or "error"
+	Index int    `json:"index"` // of the failing operation, -1 when not about one
+	Count int    `json:"count"`
+	// First is the position in the corpus of the first document of the
+	// group, and Example its error message.
+	First   int    `json:"first"`
+	Example string `json:"example"`
+}
+
+// PointerImpact describes the changes to the value at a pointer across the
+// corpus. A document counts once per pointer, comparing the value before
+// and after the whole patch, so a pointer changed and then restored by the
+// patch is not counted.
+type PointerImpact struct {
+	Path     string       `json:"path"`
+	Added    int          `json:"added"`
+	Removed  int          `json:"removed"`
+	Replaced int          `json:"replaced"`
+	Before   Distribution `json:"before"` // of the values replaced or removed
+	After    Distribution `json:"after"`  // of the values added or replacing others
+}
+
+// Distribution counts values by their JSON encoding. The first
+// maxImpactValues distinct values are counted individually, the most
+// frequent first, and the others together in Other.
+type Distribution struct {
+	Values []ValueCount `json:"values,omitempty"`
+	Other  int          `json:"other,omitempty"`
+}
+
+// ValueCount is a value of a Distribution and the number of times it was
+// found.
+type ValueCount struct {
+	Value json.RawMessage `json:"value"`
+	Count int             `json:"count"`
+}
+
+// Impact applies operations, with opts, to a copy of every document of docs
+// without keeping the results, and reports how many documents the patch
+// applies to, why it fails on the others, and how the values it changes
+// are distributed, so that a bulk patch can be assessed before being rolled
+// out. With ContinueOnError, documents with skipped operations count as
+// failed, and the changes of the other operations are counted.
+func Impact(operations []Operation, docs iter.Seq[interface{}], opts ...Option) (*ImpactReport, error) {
+	options := newOptions(opts)
+	options.InPlace = false
+	r := &ImpactReport{}
+	failures := make(map[ImpactFailure]int) // groups, with Count 0, to their index
+	changes := make(map[string]int)
+	for doc := range docs {
+		n := r.Documents
+		r.Documents++
+		result, report, err := applyWithReport(doc, operations, options)
+		if err != nil {
+			var inv *InvalidPatchError
+			if errors.As(err, &inv) && !options.ContinueOnError {
+				// a malformed patch fails on every document
+				return nil, err
+			}
+			r.Failed++
+			key := ImpactFailure{Code: "error", Index: failedOperation(err)}
+			var coded interface{ Code() string }
+			if errors.As(err, &coded) {
+				key.Code = coded.Code()
+			}
+			i, ok := failures[key]
+			if !ok {
+				i = len(r.Failures)
+				failures[key] = i
+				f := key
+				f.First, f.Example = n, err.Error()
+				r.Failures = append(r.Failures, f)
+			}
+			r.Failures[i].Count++
+			if result == nil {
+				continue
+			}
+		} else {
+			r.Applied++
+		}
+		for _, path := range report.Touched() {
+			ptr, err := pointer.Parse(path)
+			if err != nil {
+				continue
+			}
+			before, errBefore := ptr.Get(doc)
+			after, errAfter := ptr.Get(result)
+			if errBefore != nil && errAfter != nil {
+				continue
+			}
+			var b, a []byte
+			if errBefore == nil {
+				if b, err = marshal(before); err != nil {
+					return nil, err
+				}
+			}
+			if errAfter == nil {
+				if a, err = marshal(after); err != nil {
+					return nil, err
+				}
+			}
+			if errBefore == nil && errAfter == nil && bytes.Equal(b, a) {
+				continue
+			}
+			i, ok := changes[path]
+			if !ok {
+				i = len(r.Changes)
+				changes[path] = i
+				r.Changes = append(r.Changes, PointerImpact{Path: path})
+			}
+			c := &r.Changes[i]
+			switch {
+			case errBefore != nil:
+				c.Added++
+			case errAfter != nil:
+				c.Removed++
+			default:
+				c.Replaced++
+			}
+			if b != nil {
+				c.Before.add(b)
+			}
+			if a != nil {
+				c.After.add(a)
+			}
+		}
+	}
+	for i := range r.Changes {
+		r.Changes[i].Before.sort()
+		r.Changes[i].After.sort()
+	}
+	return r, nil
+}
+
+// add counts the value encoded as v.
+func (d *Distribution) add(v []byte) {
+	for i := range d.Values {
+		if bytes.Equal(d.Values[i].Value, v) {
+			d.Values[i].Count++
+			return
+		}
+	}
+	if len(d.Values) == maxImpactValues {
+		d.Other++
+		return
+	}
+	d.Values = append(d.Values, ValueCount{Value: v, Count: 1})
+}
+
+// sort orders the values of d by decreasing count, and then by encoding.
+func (d *Distribution) sort() {
+	slices.SortStableFunc(d.Values, func(a, b ValueCount) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return bytes.Compare(a.Value, b.Value)
+	})
+}
+
+// failedOperation returns the index of the operation an error of Apply is
+// about, or -1.
+func failedOperation(err error) int {
+	var inv *InvalidPatchError
+	var path *PathError
+	var test *TestFailedError
+	var forbidden *ForbiddenError
+	var limit *LimitExceededError
+	switch {
+	case errors.As(err, &inv):
+		return inv.Index
+	case errors.As(err, &path):
+		return path.Index
+	case errors.As(err, &test):
+		return test.Index
+	case errors.As(err, &forbidden):
+		return forbidden.Index
+	case errors.As(err, &limit):
+		return limit.Index
+	}
+	return -1
+}
@@ -0,0 +1,78 @@
+package patch
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestImpact(t *testing.T) {
+	docs := []interface{}{
+		decode(`{"version": 1, "tier": "free", "legacy": true}`),
+		decode(`{"version": 1, "tier": "pro", "legacy": true}`),
+		decode(`{"version": 1, "tier": "free"}`),
+		decode(`{"version": 2, "tier": "free", "legacy": false}`),
+		decode(`{"version": 1, "tier": "gold", "legacy": false}`),
+	}
+	ops := parseStr(`[
+		{"op": "test", "path": "/version", "value": 1},
+		{"op": "replace", "path": "/tier", "value": "free"},
+		{"op": "remove", "path": "/legacy"},
+		{"op": "add", "path": "/migrated", "value": true}
+	]`)
+	r, err := Impact(ops, slices.Values(docs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Documents != 5 || r.Applied != 3 || r.Failed != 2 {
+		t.Errorf("unexpected counts %+v", r)
+	}
+	expectedFailures := []ImpactFailure{
+		{Code: "path-not-found", Index: 2, Count: 1, First: 2},
+		{Code: "test-failed", Index: 0, Count: 1, First: 3},
+	}
+	for i := range r.Failures {
+		r.Failures[i].Example = ""
+	}
+	if !slices.Equal(r.Failures, expectedFailures) {
+		t.Errorf("expected failures %+v, got %+v", expectedFailures, r.Failures)
+	}
+
+	paths := []string{}
+	for _, c := range r.Changes {
+		paths = append(paths, c.Path)
+	}
+	if !slices.Equal(paths, []string{"/legacy", "/migrated", "/tier"}) {
+		t.Fatalf("unexpected changed pointers %v", paths)
+	}
+	legacy, migrated, tier := r.Changes[0], r.Changes[1], r.Changes[2]
+	if legacy.Removed != 3 || len(legacy.Before.Values) != 2 || string(legacy.Before.Values[0].Value) != "true" || legacy.Before.Values[0].Count != 2 {
+		t.Errorf("unexpected impact on /legacy %+v", legacy)
+	}
+	if migrated.Added != 3 || migrated.After.Values[0].Count != 3 {
+		t.Errorf("unexpected impact on /migrated %+v", migrated)
+	}
+	// the document already on the free tier is left out
+	if tier.Replaced != 2 || len(tier.Before.Values) != 2 || string(tier.Before.Values[0].Value) != `"gold"` || tier.After.Values[0].Count != 2 {
+		t.Errorf("unexpected impact on /tier %+v", tier)
+	}
+	if !jsonEqual(docs[0], decode(`{"version": 1, "tier": "free", "legacy": true}`)) {
+		t.Error("expected the documents to be left unchanged")
+	}
+
+	if _, err := Impact([]Operation{{Op: "bogus"}}, slices.Values(docs)); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("expected a malformed patch to be reported, got %v", err)
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	var d Distribution
+	for i := 0; i < maxImpactValues+5; i++ {
+		d.add(mustMarshal(t, i))
+	}
+	d.add(mustMarshal(t, 3))
+	d.sort()
+	if len(d.Values) != maxImpactValues || d.Other != 5 || string(d.Values[0].Value) != "3" || d.Values[0].Count != 2 {
+		t.Errorf("unexpected distribution %+v", d)
+	}
+}
@@ -0,0 +1,167 @@
+package patch
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// elementIndex returns the index of the element of s named by token. When
+// add is true the token may also be "-" or len(s), which name the position
+// after the last element, as the path of an add operation may. Tokens that
+// are valid indexes but name no element give an error matching ErrNotFound.
+func elementIndex(s []interface{}, token string, add bool) (int, error) {
+	if token == "-" {
+		if add {
+			return len(s), nil
+		}
+		return -1, fmt.Errorf("element -: %w", ErrNotFound)
+	}
+	i, err := pointer.ParseIndex(token, math.MaxInt32, false)
+	if err != nil {
+		return -1, err
+	}
+	if i > len(s) || i == len(s) && !add {
+		return -1, fmt.Errorf("element %s: %w", token, ErrNotFound)
+	}
+	return i, nil
+}
+
+// resolveNegative returns ins with the negative array indexes of its path,
+// counted from the end of their array, replaced by the index they name in
+// root, in its path and in the path of its operation. Tokens it cannot
+// resolve are left for makeCommand to report.
+func (a *applier) resolveNegative(root interface{}, ins *instruction) (*instruction, error) {
+	var path []string
+	current := root
+	for i, token := range ins.path {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			current = v[token]
+			continue
+		case *SortedObject:
+			current, _ = v.Get(token)
+			continue
+		case []interface{}:
+			if len(token) > 1 && token[0] == '-' {
+				n, err := pointer.ParseIndex(token[1:], math.MaxInt32, false)
+				if err != nil {
+					return nil, fmt.Errorf("invalid array index %q", token)
+				}
+				if n == 0 || n > len(v) {
+					return nil, fmt.Errorf("element %s: %w", token, ErrNotFound)
+				}
+				if path == nil {
+					path = slices.Clone(ins.path)
+				}
+				token = strconv.Itoa(len(v) - n)
+				path[i] = token
+			}
+			j, err := elementIndex(v, token, false)
+			if err == nil {
+				current = v[j]
+				continue
+			}
+		}
+		break
+	}
+	if path == nil {
+		return ins, nil
+	}
+	resolved := *ins
+	resolved.path = path
+	resolved.op.Path = pointer.Pointer(path).String()
+	return &resolved, nil
+}
+
+// IndexResolver resolves the tokens addressing array elements that are not
+// array indexes, such as "first", "last" or "id:abc", letting applications
+// address elements their own way. It is called with the array and the
+// token for every such token of the path and from of an operation, except
+// "-" and, with Options.NegativeIndices, negative indexes. It returns the
+// index of the element the token names, which may be len(array) for the
+// last token of the path of an add, or -1 to leave the token to be
+// reported as an invalid index. An error it returns fails the operation;
+// wrap ErrNotFound for tokens that name no element.
+type IndexResolver func(array []interface{}, token string) (int, error)
+
+// WithIndexResolver resolves array tokens with fn. See
+// Options.IndexResolver.
+func WithIndexResolver(fn IndexResolver) Option {
+	return func(o *Options) { o.IndexResolver = fn }
+}
+
+// resolveIndices returns ins with the tokens of its path and from that
+// Options.IndexResolver resolves replaced by the indexes they name in root,
+// in its pointers and in those of its operation.
+func (a *applier) resolveIndices(root interface{}, ins *instruction) (*instruction, error) {
+	path, err := a.resolveTokens(root, ins.path, ins.op.Op == "add")
+	if err != nil {
+		return nil, err
+	}
+	from := ins.from
+	if ins.ref == nil && (ins.op.Op == "move" || ins.op.Op == "copy") {
+		if from, err = a.resolveTokens(root, ins.from, false); err != nil {
+			return nil, err
+		}
+	}
+	if path == nil && from == nil {
+		return ins, nil
+	}
+	resolved := *ins
+	if path != nil {
+		resolved.path = path
+		resolved.op.Path = pointer.Pointer(path).String()
+	}
+	if from != nil {
+		resolved.from = from
+		resolved.op.From = pointer.Pointer(from).String()
+	}
+	return &resolved, nil
+}
+
+// resolveTokens returns a copy of path with the tokens Options.IndexResolver
+// resolves in root replaced, or nil when it resolves none. When add is true
+// the last token may resolve to the position after the last element.
+func (a *applier) resolveTokens(root interface{}, path []string, add bool) ([]string, error) {
+	var out []string
+	current := root
+	for i, token := range path {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			current = v[token]
+			continue
+		case *SortedObject:
+			current, _ = v.Get(token)
+			continue
+		case []interface{}:
+			if _, err := pointer.ParseIndex(token, math.MaxInt32, true); err != nil &&
+				!(a.opts.NegativeIndices && len(token) > 1 && token[0] == '-') {
+				j, err := a.opts.IndexResolver(v, token)
+				if err != nil {
+					return nil, fmt.Errorf("element %s: %w", token, err)
+				}
+				if j >= 0 {
+					if j > len(v) || j == len(v) && !(add && i == len(path)-1) {
+						return nil, fmt.Errorf("element %s: index %d out of range", token, j)
+					}
+					if out == nil {
+						out = slices.Clone(path)
+					}
+					token = strconv.Itoa(j)
+					out[i] = token
+				}
+			}
+			j, err := elementIndex(v, token, false)
+			if err == nil {
+				current = v[j]
+				continue
+			}
+		}
+		break
+	}
+	return out, nil
+}
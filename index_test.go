@@ -0,0 +1,121 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArrayIndexes(t *testing.T) {
+	for _, tc := range []struct {
+		patch    string
+		notFound bool
+	}{
+		{`[{"op": "replace", "path": "/a/3", "value": 0}]`, true},
+		{`[{"op": "replace", "path": "/a/-", "value": 0}]`, true},
+		{`[{"op": "remove", "path": "/a/3"}]`, true},
+		{`[{"op": "remove", "path": "/a/-"}]`, true},
+		{`[{"op": "test", "path": "/a/-", "value": 0}]`, true},
+		{`[{"op": "add", "path": "/a/4", "value": 0}]`, true},
+		{`[{"op": "add", "path": "/a/-/x", "value": 0}]`, true},
+		{`[{"op": "replace", "path": "/a/-1", "value": 0}]`, false},
+		{`[{"op": "remove", "path": "/a/01"}]`, false},
+		{`[{"op": "remove", "path": "/a/99999999999999999999"}]`, false},
+		{`[{"op": "add", "path": "/a/+1", "value": 0}]`, false},
+	} {
+		_, err := Apply(decode(`{"a": [1, 2, 3]}`), parseStr(tc.patch))
+		if err == nil {
+			t.Errorf("%s: expected an error", tc.patch)
+		} else if errors.Is(err, ErrNotFound) != tc.notFound {
+			t.Errorf("%s: unexpected error %v", tc.patch, err)
+		}
+	}
+}
+
+func TestNegativeIndices(t *testing.T) {
+	doc := decode(`{"a": [1, 2, [3, 4]], "m": {"-1": 5}}`)
+	ops := parseStr(`[
+		{"op": "test", "path": "/a/-1/-2", "value": 3},
+		{"op": "replace", "path": "/a/-1/-1", "value": 6},
+		{"op": "remove", "path": "/a/-3"},
+		{"op": "test", "path": "/m/-1", "value": 5},
+		{"op": "add", "path": "/a/-", "value": 7}
+	]`)
+	result, report, err := ApplyWithReport(doc, ops, WithNegativeIndices())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := decode(`{"a": [2, [3, 6], 7], "m": {"-1": 5}}`); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	if paths := report.Touched(); !reflect.DeepEqual(paths, []string{"/a/2/1", "/a/0", "/a/-"}) {
+		t.Errorf("expected the report to carry actual indexes, got %v", paths)
+	}
+
+	if _, err := Apply(doc, ops); err == nil {
+		t.Error("expected negative indexes to be refused by default")
+	}
+	for _, p := range []string{"/a/-4", "/a/-0", "/a/-x"} {
+		_, err := Apply(doc, parseStr(`[{"op": "remove", "path": "`+p+`"}]`), WithNegativeIndices())
+		if err == nil {
+			t.Errorf("%s: expected an error", p)
+		}
+	}
+	if _, err := Apply(doc, parseStr(`[{"op": "add", "path": "/a/-1", "value": 0}]`), WithNegativeIndices()); err == nil {
+		t.Error("expected add to refuse negative indexes")
+	}
+}
+
+func TestIndexResolver(t *testing.T) {
+	resolver := func(array []interface{}, token string) (int, error) {
+		switch {
+		case token == "first":
+			return 0, nil
+		case token == "last":
+			return len(array) - 1, nil
+		case strings.HasPrefix(token, "id:"):
+			for i, e := range array {
+				if m, ok := e.(map[string]interface{}); ok && m["id"] == token[3:] {
+					return i, nil
+				}
+			}
+			return -1, ErrNotFound
+		}
+		return -1, nil
+	}
+	doc := `{"users": [{"id": "a", "tags": ["x", "y"]}, {"id": "b", "tags": []}]}`
+	cases := []struct {
+		patch    string
+		expected string // "" when the patch fails
+	}{
+		{`[{"op": "replace", "path": "/users/id:b/tags", "value": ["z"]}]`, `{"users": [{"id": "a", "tags": ["x", "y"]}, {"id": "b", "tags": ["z"]}]}`},
+		{`[{"op": "remove", "path": "/users/first/tags/last"}]`, `{"users": [{"id": "a", "tags": ["x"]}, {"id": "b", "tags": []}]}`},
+		{`[{"op": "move", "from": "/users/id:a", "path": "/users/-"}]`, `{"users": [{"id": "b", "tags": []}, {"id": "a", "tags": ["x", "y"]}]}`},
+		{`[{"op": "test", "path": "/users/last/id", "value": "b"}]`, doc},
+		{`[{"op": "remove", "path": "/users/id:c"}]`, ""},
+		{`[{"op": "remove", "path": "/users/second"}]`, ""},
+		{`[{"op": "remove", "path": "/users/id:b/tags/last"}]`, ""},
+	}
+	for _, c := range cases {
+		got, err := Apply(decode(doc), parseStr(c.patch), WithIndexResolver(resolver))
+		if c.expected == "" {
+			if err == nil {
+				t.Errorf("%s: expected an error", c.patch)
+			}
+			continue
+		}
+		if err != nil || !jsonEqual(got, decode(c.expected)) {
+			t.Errorf("%s: expected %s, got %v, %v", c.patch, c.expected, got, err)
+		}
+	}
+
+	_, report, err := ApplyWithReport(decode(doc), parseStr(`[{"op": "remove", "path": "/users/id:b"}]`), WithIndexResolver(resolver))
+	if err != nil || report.Changes[0].Path != "/users/1" {
+		t.Errorf("expected the report to carry the resolved index, got %+v, %v", report, err)
+	}
+	_, err = Apply(decode(doc), parseStr(`[{"op": "remove", "path": "/users/id:c"}]`), WithIndexResolver(resolver))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the resolver's error, got %v", err)
+	}
+}
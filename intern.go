@@ -0,0 +1,192 @@
+package patch
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"hash/maphash"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"unsafe"
+)
+
+// WithInterning makes the patched document share a single instance of
+// repeated values. See Options.Intern.
+func WithInterning() Option {
+	return func(o *Options) { o.Intern = true }
+}
+
+// Intern returns doc with every repeated string, object and array replaced
+// by a single instance of it, so that a document holding the same value in
+// thousands of places stores it once. Objects and arrays holding a replaced
+// value are copied rather than modified: doc itself is unchanged, and the
+// result shares the parts of it without repeated values.
+//
+// The values of the result may be reachable from several locations, so it
+// must not be modified in place, as ApplyUnsafe and Options.InPlace do:
+// changing one of them would change all of them. Apply copies the document
+// first and may be used on it.
+func Intern(doc interface{}) interface{} {
+	in := &interner{
+		seed:    maphash.MakeSeed(),
+		strings: make(map[string]interface{}),
+		nodes:   make(map[uint64][]interface{}),
+	}
+	v, _ := in.intern(doc)
+	return v
+}
+
+// interner hash-conses a document: the children of a value are interned
+// first, so that two values are equal when their children are identical.
+type interner struct {
+	seed    maphash.Seed
+	strings map[string]interface{}
+	nodes   map[uint64][]interface{} // objects and arrays, by hash
+}
+
+// intern returns the canonical instance of v and its hash.
+func (in *interner) intern(v interface{}) (interface{}, uint64) {
+	var h maphash.Hash
+	h.SetSeed(in.seed)
+	switch v := v.(type) {
+	case nil:
+		h.WriteByte('n')
+	case bool:
+		h.WriteByte('b')
+		if v {
+			h.WriteByte(1)
+		}
+	case float64:
+		h.WriteByte('f')
+		h.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)))
+	case json.Number:
+		h.WriteByte('N')
+		h.WriteString(string(v))
+	case string:
+		h.WriteByte('s')
+		h.WriteString(v)
+		s, ok := in.strings[v]
+		if !ok {
+			s = v
+			in.strings[v] = s
+		}
+		return s, h.Sum64()
+	case map[string]interface{}:
+		out, copied := v, false
+		var sum uint64
+		for k, child := range v {
+			c, ch := in.intern(child)
+			if replaced(child, c) {
+				if !copied {
+					out, copied = maps.Clone(v), true
+				}
+				out[k] = c
+			}
+			sum += in.member(k, ch)
+		}
+		h.WriteByte('o')
+		h.Write(binary.LittleEndian.AppendUint64(nil, sum))
+		return in.canonical(out, h.Sum64())
+	case *SortedObject:
+		out := v
+		var sum uint64
+		for i, k := range v.keys {
+			c, ch := in.intern(v.values[i])
+			if replaced(v.values[i], c) {
+				if out == v {
+					out = v.clone()
+				}
+				out.values[i] = c
+			}
+			sum += in.member(k, ch)
+		}
+		h.WriteByte('O')
+		h.Write(binary.LittleEndian.AppendUint64(nil, sum))
+		return in.canonical(out, h.Sum64())
+	case []interface{}:
+		out, copied := v, false
+		h.WriteByte('a')
+		for i, child := range v {
+			c, ch := in.intern(child)
+			if replaced(child, c) {
+				if !copied {
+					out, copied = slices.Clone(v), true
+				}
+				out[i] = c
+			}
+			h.Write(binary.LittleEndian.AppendUint64(nil, ch))
+		}
+		return in.canonical(out, h.Sum64())
+	}
+	return v, h.Sum64()
+}
+
+// member hashes an object member, so that the hash of an object, the sum of
+// those of its members, does not depend on their order.
+func (in *interner) member(key string, value uint64) uint64 {
+	var h maphash.Hash
+	h.SetSeed(in.seed)
+	h.WriteString(key)
+	h.Write(binary.LittleEndian.AppendUint64(nil, value))
+	return h.Sum64()
+}
+
+// canonical returns the interned object or array equal to v, or v itself
+// when there is none yet.
+func (in *interner) canonical(v interface{}, h uint64) (interface{}, uint64) {
+	for _, c := range in.nodes[h] {
+		if sameChildren(v, c) {
+			return c, h
+		}
+	}
+	in.nodes[h] = append(in.nodes[h], v)
+	return v, h
+}
+
+// sameChildren reports whether a and b are objects or arrays of the same
+// type with identical children.
+func sameChildren(a, b interface{}) bool {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, x := range av {
+			if y, ok := bv[k]; !ok || !identical(x, y) {
+				return false
+			}
+		}
+		return true
+	case *SortedObject:
+		bv, ok := b.(*SortedObject)
+		return ok && slices.Equal(av.keys, bv.keys) && slices.EqualFunc(av.values, bv.values, identical)
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		return ok && slices.EqualFunc(av, bv, identical)
+	}
+	return false
+}
+
+// identical reports whether a and b are the same object or array, or equal
+// scalars.
+func identical(a, b interface{}) bool {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		return ok && reflect.ValueOf(av).UnsafePointer() == reflect.ValueOf(bv).UnsafePointer()
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		return ok && len(av) == len(bv) && (len(av) == 0 || &av[0] == &bv[0])
+	}
+	return a == b
+}
+
+// replaced reports whether intern returned another instance than v.
+func replaced(v, interned interface{}) bool {
+	if s, ok := v.(string); ok {
+		return unsafe.StringData(s) != unsafe.StringData(interned.(string))
+	}
+	return !identical(v, interned)
+}
@@ -0,0 +1,53 @@
+package patch
+
+import (
+	"testing"
+)
+
+func TestIntern(t *testing.T) {
+	original := decode(`[{"config": {"enabled": true, "tags": ["x", "y"]}}, {"config": {"tags": ["x", "y"], "enabled": true}}, [1, "x"]]`)
+	doc := Intern(original)
+	if !jsonEqual(doc, original) {
+		t.Fatalf("expected an equal document, got %v", doc)
+	}
+	s, o := doc.([]interface{}), original.([]interface{})
+	a := s[0].(map[string]interface{})["config"]
+	b := s[1].(map[string]interface{})["config"]
+	if !identical(a, b) {
+		t.Error("expected the configs to be shared")
+	}
+	if identical(o[1], s[1]) || identical(o, s) {
+		t.Error("expected the objects holding a replaced value to be copied")
+	}
+	if !identical(o[0], s[0]) || !identical(o[2], s[2]) {
+		t.Error("expected the values without repetitions to be kept")
+	}
+	if identical(a, Intern(decode(`{"enabled": false, "tags": ["x", "y"]}`))) {
+		t.Error("expected different values to stay apart")
+	}
+}
+
+func TestApplyInterning(t *testing.T) {
+	var ops []Operation
+	for _, k := range []string{"a", "b", "c"} {
+		ops = append(ops, parseStr(`[{"op": "add", "path": "/`+k+`", "value": {"limits": {"cpu": 2}}}]`)...)
+	}
+	doc, err := Apply(decode(`{}`), ops, WithInterning())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := doc.(map[string]interface{})
+	if !identical(m["a"], m["c"]) {
+		t.Error("expected the added values to be shared")
+	}
+
+	// Apply copies the document, so a shared value is only changed where
+	// the patch changes it
+	doc, err = Apply(doc, parseStr(`[{"op": "replace", "path": "/a/limits/cpu", "value": 4}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !jsonEqual(doc, decode(`{"a": {"limits": {"cpu": 4}}, "b": {"limits": {"cpu": 2}}, "c": {"limits": {"cpu": 2}}}`)) {
+		t.Errorf("unexpected document %v", doc)
+	}
+}
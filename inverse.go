@@ -0,0 +1,110 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// ApplyWithInverse applies operations to a copy of o and also returns the
+// inverse patch: applying it to the result produces a document equal to o.
+// This is enough to implement undo without keeping snapshots of the whole
+// document.
+func ApplyWithInverse(o interface{}, operations []Operation) (interface{}, []Operation, error) {
+	a := &applier{opts: &Options{}, undo: &undoLog{}}
+	result, err := a.apply(deepCopy(o), operations)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, a.undo.ops(), nil
+}
+
+// undoLog collects the inverse of each applied operation.
+type undoLog struct {
+	groups [][]Operation
+}
+
+// ops returns the inverse of the whole patch: the inverse of each operation,
+// last operation first.
+func (u *undoLog) ops() []Operation {
+	out := make([]Operation, 0, len(u.groups))
+	for i := len(u.groups) - 1; i >= 0; i-- {
+		out = append(out, u.groups[i]...)
+	}
+	return out
+}
+
+// invert returns the operations reverting ins, computed from the state of
+// the document before ins is applied.
+func (a *applier) invert(root interface{}, ins *instruction, c *command) ([]Operation, error) {
+	path := ins.op.Path
+	switch ins.op.Op {
+	case "test":
+		return nil, nil
+	case "remove":
+		prior, _ := c.prior(false)
+		return undoOp("add", path, prior)
+	case "replace":
+		prior, _ := c.prior(false)
+		return undoOp("replace", path, prior)
+	case "add", "copy":
+		if prior, ok := c.prior(true); ok {
+			return undoOp("replace", path, prior)
+		}
+		return undoOp("remove", concreteIndex(c), nil)
+	case "move":
+		prior, exists := c.prior(true)
+		if exists && len(c.path) < len(c.from) && slices.Equal(c.path, c.from[:len(c.path)]) {
+			// the value replaced its parent, which restoring brings it
+			// back with
+			return undoOp("replace", path, prior)
+		}
+		from, err := a.makeCommand(root, &instruction{path: c.from})
+		if err != nil {
+			return nil, err
+		}
+		moved, _ := from.prior(false)
+		// the destination member is restored if it was overwritten, and
+		// removed otherwise, at the index the value was inserted at
+		var undo []Operation
+		if exists {
+			undo, err = undoOp("replace", path, prior)
+		} else {
+			undo, err = undoOp("remove", concreteIndex(c), nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+		readd, err := undoOp("add", ins.op.From, moved)
+		if err != nil {
+			return nil, err
+		}
+		return append(undo, readd...), nil
+	}
+	return nil, fmt.Errorf("custom operator %s cannot be inverted", ins.op.Op)
+}
+
+// concreteIndex returns the command's path with a trailing "-" replaced by
+// the index the inserted element will have.
+func concreteIndex(c *command) string {
+	p := pointer.Pointer(c.path)
+	if s, ok := c.parent.([]interface{}); ok && c.key == "-" {
+		p = append(append(pointer.Pointer{}, c.path[:c.pathLen-1]...), strconv.Itoa(len(s)))
+	}
+	return p.String()
+}
+
+func undoOp(op, path string, value interface{}) ([]Operation, error) {
+	o := Operation{Op: op, Path: path}
+	if op != "remove" {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		o.Value = raw
+	}
+	return []Operation{o}, nil
+}
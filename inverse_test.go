@@ -0,0 +1,60 @@
+package patch
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+func TestApplyWithInverse(t *testing.T) {
+	cases := []struct{ doc, patch string }{
+		{`{"a": 1}`, `[{"op": "add", "path": "/b", "value": 2}]`},
+		{`{"a": 1}`, `[{"op": "add", "path": "/a", "value": 2}]`},
+		{`{"a": [1, 2]}`, `[{"op": "add", "path": "/a/1", "value": 3}, {"op": "add", "path": "/a/-", "value": 4}]`},
+		{`{"a": {"b": [1]}}`, `[{"op": "remove", "path": "/a/b/0"}, {"op": "remove", "path": "/a"}]`},
+		{`{"a": [1, 2]}`, `[{"op": "replace", "path": "/a/0", "value": {"x": 1}}, {"op": "replace", "path": "", "value": [1]}]`},
+		{`{"a": [1, 2, 3, 4]}`, `[{"op": "move", "from": "/a/0", "path": "/a/3"}, {"op": "move", "from": "/a/1", "path": "/a/-"}]`},
+		{`{"a": [1, 2], "b": []}`, `[{"op": "move", "from": "/a/0", "path": "/b/-"}, {"op": "move", "from": "/b", "path": "/c"}]`},
+		{`{"a": {"b": 1}, "c": 2}`, `[{"op": "move", "from": "/a/b", "path": "/c"}]`},
+		{`{"a": {"b": {"x": 1}}}`, `[{"op": "move", "from": "/a/b", "path": "/a"}]`},
+		{`{"a": [1], "b": 2}`, `[{"op": "copy", "from": "/a", "path": "/a/-"}, {"op": "copy", "from": "/a", "path": "/b"}]`},
+		{`{"a": 1}`, `[{"op": "test", "path": "/a", "value": 1}]`},
+		{`[true, 2, {"a": "d", "c": false}]`, `[{"op": "move", "from": "/2/c", "path": "/0"}]`},
+		{`{"a": [[1], 2]}`, `[{"op": "move", "from": "/a/0", "path": "/a"}]`},
+		{`{"a": [1, 2], "b": {"c": 3}}`, `[{"op": "move", "from": "/b/c", "path": "/a/-"}, {"op": "move", "from": "/a/0", "path": "/a/-"}]`},
+	}
+	for _, c := range cases {
+		doc := decode(c.doc)
+		result, inverse, err := ApplyWithInverse(doc, parseStr(c.patch))
+		if err != nil {
+			t.Errorf("%s: %v", c.patch, err)
+			continue
+		}
+		restored, err := Apply(result, inverse)
+		if err != nil {
+			t.Errorf("%s: applying inverse %s: %v", c.patch, inverse, err)
+			continue
+		}
+		if !reflect.DeepEqual(restored, doc) {
+			t.Errorf("%s: inverse %s restored %v, expected %v", c.patch, inverse, restored, doc)
+		}
+	}
+}
+
+// TestApplyWithInverseRandom checks that the inverses of random patches
+// restore the original document.
+func TestApplyWithInverseRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	doc := decode(`{"a": [true, 2, {"a": "d", "c": false}], "b": {"c": [2, 3]}, "list": [0, [1], {"x": 2}]}`)
+	for n := 0; n < 10000; n++ {
+		ops := randomPatch(r, doc, 1+r.IntN(6))
+		result, inverse, err := ApplyWithInverse(doc, ops)
+		if err != nil {
+			t.Fatalf("%v\ndoes not apply: %v", fmtOps(ops), err)
+		}
+		restored, err := Apply(result, inverse)
+		if err != nil || !reflect.DeepEqual(restored, doc) {
+			t.Fatalf("%v\ninverse %v\nrestored %v, %v", fmtOps(ops), fmtOps(inverse), restored, err)
+		}
+	}
+}
@@ -0,0 +1,608 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// With Options.JSONPath, the path of an operation may be a JSONPath
+// expression, starting with "$", which selects the locations the operation
+// applies to. The supported subset is:
+//
+//	$.name  $['name']  $["name"]   a member
+//	$[2]  $[-1]                    an element, counting from the end when negative
+//	$.*  $[*]                      every member or element
+//	$[0,2]  $['a','b']             several of them
+//	$..name  $..*  $..[0]          recursive descent
+//	$[?(@.id == 42 && @.tags)]     the members or elements matching a filter
+//
+// Filters compare the value at a relative path starting with "@" with a
+// literal (a number, a string in single or double quotes, true, false or
+// null) or another relative path, with ==, !=, <, <=, > and >=, or test
+// that a relative path exists. Comparisons can be combined with &&, || and
+// !, and grouped with parentheses. Ordering comparisons only hold between
+// two numbers or two strings.
+
+// jsonPath is a parsed JSONPath expression.
+type jsonPath []pathSegment
+
+type pathSegment struct {
+	// descend is set for "..", which applies the selectors to the value
+	// and all of its descendants
+	descend   bool
+	selectors []pathSelector
+}
+
+type pathSelector struct {
+	name     string
+	index    int
+	isIndex  bool
+	wildcard bool
+	filter   filterExpr
+}
+
+// isJSONPath reports whether path is a JSONPath expression rather than a
+// JSON pointer, which never starts with "$".
+func isJSONPath(path string) bool {
+	return strings.HasPrefix(path, "$")
+}
+
+// parseJSONPath parses a JSONPath expression.
+func parseJSONPath(s string) (jsonPath, error) {
+	p := &pathParser{s: s}
+	q, err := p.path()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath %q: %w", s, err)
+	}
+	return q, nil
+}
+
+type pathParser struct {
+	s   string
+	pos int
+}
+
+func (p *pathParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *pathParser) skipSpace() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *pathParser) consume(prefix string) bool {
+	if strings.HasPrefix(p.s[p.pos:], prefix) {
+		p.pos += len(prefix)
+		return true
+	}
+	return false
+}
+
+func (p *pathParser) path() (jsonPath, error) {
+	if !p.consume("$") {
+		return nil, p.errorf("must start with $")
+	}
+	var q jsonPath
+	for p.pos < len(p.s) {
+		var seg pathSegment
+		switch {
+		case p.consume(".."):
+			seg.descend = true
+			if p.pos < len(p.s) && p.s[p.pos] == '[' {
+				sels, err := p.bracket()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = sels
+				break
+			}
+			sel, err := p.dotted()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = []pathSelector{sel}
+		case p.consume("."):
+			sel, err := p.dotted()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = []pathSelector{sel}
+		case p.s[p.pos] == '[':
+			sels, err := p.bracket()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = sels
+		default:
+			return nil, p.errorf("unexpected %q", p.s[p.pos:])
+		}
+		q = append(q, seg)
+	}
+	return q, nil
+}
+
+// dotted parses the member name or "*" following a dot.
+func (p *pathParser) dotted() (pathSelector, error) {
+	if p.consume("*") {
+		return pathSelector{wildcard: true}, nil
+	}
+	name := p.name()
+	if name == "" {
+		return pathSelector{}, p.errorf("expected a member name")
+	}
+	return pathSelector{name: name}, nil
+}
+
+// name parses a member name in dot notation.
+func (p *pathParser) name() string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c >= utf8.RuneSelf || c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.s[start:p.pos]
+}
+
+// bracket parses a bracketed list of selectors.
+func (p *pathParser) bracket() ([]pathSelector, error) {
+	p.pos++ // '['
+	var sels []pathSelector
+	for {
+		p.skipSpace()
+		sel, err := p.selector()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+		p.skipSpace()
+		if p.consume("]") {
+			return sels, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected , or ]")
+		}
+	}
+}
+
+func (p *pathParser) selector() (pathSelector, error) {
+	switch {
+	case p.consume("*"):
+		return pathSelector{wildcard: true}, nil
+	case p.consume("?"):
+		p.skipSpace()
+		f, err := p.or()
+		if err != nil {
+			return pathSelector{}, err
+		}
+		return pathSelector{filter: f}, nil
+	case p.pos < len(p.s) && (p.s[p.pos] == '\'' || p.s[p.pos] == '"'):
+		s, err := p.quoted()
+		return pathSelector{name: s}, err
+	}
+	i, err := p.integer()
+	if err != nil {
+		return pathSelector{}, err
+	}
+	return pathSelector{index: i, isIndex: true}, nil
+}
+
+func (p *pathParser) integer() (int, error) {
+	start := p.pos
+	p.consume("-")
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	i, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		return 0, p.errorf("expected an index")
+	}
+	return i, nil
+}
+
+// quoted parses a string in single or double quotes, with JSON escapes.
+func (p *pathParser) quoted() (string, error) {
+	q := p.s[p.pos]
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := p.pos + 1; i < len(p.s); i++ {
+		switch c := p.s[i]; {
+		case c == '\\' && i+1 < len(p.s):
+			i++
+			if p.s[i] == '\'' {
+				b.WriteByte('\'')
+			} else {
+				b.WriteByte('\\')
+				b.WriteByte(p.s[i])
+			}
+		case c == q:
+			b.WriteByte('"')
+			p.pos = i + 1
+			var s string
+			if err := json.Unmarshal([]byte(b.String()), &s); err != nil {
+				return "", p.errorf("invalid string: %v", err)
+			}
+			return s, nil
+		case c == '"':
+			b.WriteString(`\"`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// filterExpr is a parsed filter, evaluated with @ bound to a member or
+// element.
+type filterExpr func(v interface{}) bool
+
+func (p *pathParser) or() (filterExpr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace(); p.consume("||"); p.skipSpace() {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(v interface{}) bool { return l(v) || right(v) }
+	}
+	return left, nil
+}
+
+func (p *pathParser) and() (filterExpr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace(); p.consume("&&"); p.skipSpace() {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(v interface{}) bool { return l(v) && right(v) }
+	}
+	return left, nil
+}
+
+func (p *pathParser) unary() (filterExpr, error) {
+	p.skipSpace()
+	if p.consume("!") {
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(v interface{}) bool { return !f(v) }, nil
+	}
+	if p.consume("(") {
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, p.errorf("expected )")
+		}
+		return f, nil
+	}
+	return p.comparison()
+}
+
+// operand is one side of a comparison: the value at a path relative to @,
+// or a literal.
+type operand func(v interface{}) (interface{}, bool)
+
+func (p *pathParser) comparison() (filterExpr, error) {
+	left, rel, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	var op string
+	for _, o := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		if !rel {
+			return nil, p.errorf("expected a comparison")
+		}
+		return func(v interface{}) bool {
+			_, ok := left(v)
+			return ok
+		}, nil
+	}
+	p.skipSpace()
+	right, _, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return func(v interface{}) bool {
+		x, okx := left(v)
+		y, oky := right(v)
+		return compareValues(op, x, okx, y, oky)
+	}, nil
+}
+
+// operand parses a relative path or a literal, and reports which it was.
+func (p *pathParser) operand() (operand, bool, error) {
+	if p.consume("@") {
+		var tokens []pathSelector
+		for {
+			if p.consume(".") {
+				name := p.name()
+				if name == "" {
+					return nil, false, p.errorf("expected a member name")
+				}
+				tokens = append(tokens, pathSelector{name: name})
+			} else if p.pos < len(p.s) && p.s[p.pos] == '[' {
+				sels, err := p.bracket()
+				if err != nil {
+					return nil, false, err
+				}
+				if len(sels) != 1 || sels[0].wildcard || sels[0].filter != nil {
+					return nil, false, p.errorf("filters may only use plain members and indexes")
+				}
+				tokens = append(tokens, sels[0])
+			} else {
+				break
+			}
+		}
+		return func(v interface{}) (interface{}, bool) {
+			for _, t := range tokens {
+				var ok bool
+				if v, ok = t.child(v); !ok {
+					return nil, false
+				}
+			}
+			return v, true
+		}, true, nil
+	}
+	lit, err := p.literal()
+	if err != nil {
+		return nil, false, err
+	}
+	return func(interface{}) (interface{}, bool) { return lit, true }, false, nil
+}
+
+func (p *pathParser) literal() (interface{}, error) {
+	if p.pos < len(p.s) && (p.s[p.pos] == '\'' || p.s[p.pos] == '"') {
+		return p.quoted()
+	}
+	for _, kw := range []string{"true", "false", "null"} {
+		if p.consume(kw) {
+			var v interface{}
+			err := json.Unmarshal([]byte(kw), &v)
+			return v, err
+		}
+	}
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte("+-.0123456789eE", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	var n json.Number
+	if err := json.Unmarshal([]byte(p.s[start:p.pos]), &n); err != nil || start == p.pos {
+		p.pos = start
+		return nil, p.errorf("expected a value")
+	}
+	return n, nil
+}
+
+// compareValues evaluates a filter comparison. Missing values only equal
+// each other.
+func compareValues(op string, x interface{}, okx bool, y interface{}, oky bool) bool {
+	switch op {
+	case "==":
+		return okx == oky && (!okx || jsonEqual(x, y))
+	case "!=":
+		return !compareValues("==", x, okx, y, oky)
+	}
+	if !okx || !oky {
+		return false
+	}
+	var c int
+	if xs, ok := x.(string); ok {
+		ys, ok := y.(string)
+		if !ok {
+			return false
+		}
+		c = strings.Compare(xs, ys)
+	} else {
+		xr, ok := toRat(x)
+		if !ok {
+			return false
+		}
+		yr, ok := toRat(y)
+		if !ok {
+			return false
+		}
+		c = xr.Cmp(yr)
+	}
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+// child returns the value a name or index selector picks in v.
+func (s pathSelector) child(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if s.isIndex {
+			return nil, false
+		}
+		c, ok := v[s.name]
+		return c, ok
+	case []interface{}:
+		if !s.isIndex {
+			return nil, false
+		}
+		i := s.index
+		if i < 0 {
+			i += len(v)
+		}
+		if i < 0 || i >= len(v) {
+			return nil, false
+		}
+		return v[i], true
+	}
+	return nil, false
+}
+
+// pathNode is a location selected by a JSONPath expression.
+type pathNode struct {
+	path  []string
+	value interface{}
+	// missing is set for a member named by the last segment that does not
+	// exist, which add operations create
+	missing bool
+}
+
+// eval returns the pointers to the locations q selects in root, in the
+// order operations should be applied to them: descendants before their
+// ancestors, members in sorted order and array elements last first, so
+// that removing or inserting elements does not shift the elements left to
+// visit.
+func (q jsonPath) eval(root interface{}) [][]string {
+	nodes := []pathNode{{value: root}}
+	for n, seg := range q {
+		last := n == len(q)-1
+		var next []pathNode
+		for _, node := range nodes {
+			if node.missing {
+				continue
+			}
+			bases := []pathNode{node}
+			if seg.descend {
+				bases = descendants(node, bases)
+			}
+			for _, base := range bases {
+				for _, sel := range seg.selectors {
+					next = sel.apply(base, next, last && !seg.descend)
+				}
+			}
+		}
+		nodes = next
+	}
+
+	seen := make(map[string]bool)
+	var out [][]string
+	for _, node := range nodes {
+		key := pointer.Pointer(node.path).String()
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, node.path)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return applyBefore(out[i], out[j]) })
+	return out
+}
+
+// descendants appends the members and elements under node to out,
+// depth first.
+func descendants(node pathNode, out []pathNode) []pathNode {
+	for _, c := range children(node) {
+		out = append(out, c)
+		out = descendants(c, out)
+	}
+	return out
+}
+
+// children returns the members of an object, in sorted order, or the
+// elements of an array.
+func children(node pathNode) []pathNode {
+	var out []pathNode
+	switch v := node.value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			out = append(out, pathNode{path: appendToken(node.path, k), value: v[k]})
+		}
+	case []interface{}:
+		for i, e := range v {
+			out = append(out, pathNode{path: appendToken(node.path, strconv.Itoa(i)), value: e})
+		}
+	}
+	return out
+}
+
+func appendToken(path []string, token string) []string {
+	return append(slices.Clip(path), token)
+}
+
+// apply appends the nodes sel selects under node to out. When last is set,
+// a member that does not exist is selected too, as the location an add
+// operation creates.
+func (sel pathSelector) apply(node pathNode, out []pathNode, last bool) []pathNode {
+	switch {
+	case sel.wildcard:
+		return append(out, children(node)...)
+	case sel.filter != nil:
+		for _, c := range children(node) {
+			if sel.filter(c.value) {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	c, ok := sel.child(node.value)
+	if ok {
+		token := sel.name
+		if sel.isIndex {
+			i := sel.index
+			if i < 0 {
+				i += len(node.value.([]interface{}))
+			}
+			token = strconv.Itoa(i)
+		}
+		return append(out, pathNode{path: appendToken(node.path, token), value: c})
+	}
+	if _, isObject := node.value.(map[string]interface{}); isObject && last && !sel.isIndex {
+		return append(out, pathNode{path: appendToken(node.path, sel.name), missing: true})
+	}
+	return out
+}
+
+// applyBefore reports whether an operation should be applied at a before
+// b: descendants come before their ancestors, and elements of the same
+// array in decreasing order.
+func applyBefore(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		x, errx := strconv.Atoi(a[i])
+		y, erry := strconv.Atoi(b[i])
+		if errx == nil && erry == nil {
+			return x > y
+		}
+		return a[i] < b[i]
+	}
+	return len(a) > len(b)
+}
@@ -0,0 +1,88 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+func TestJSONPathEval(t *testing.T) {
+	doc := decode(`{
+		"items": [
+			{"id": 41, "name": "a", "tags": ["x"]},
+			{"id": 42, "name": "b", "price": 5},
+			{"id": 43, "name": "c", "price": 12.5}
+		],
+		"meta": {"name": "m", "it's": 1}
+	}`)
+	for _, tc := range []struct {
+		expr     string
+		expected []string
+	}{
+		{`$`, []string{""}},
+		{`$.items[?(@.id==42)].name`, []string{"/items/1/name"}},
+		{`$.items[?(@.price < 10 || @.tags)].id`, []string{"/items/1/id", "/items/0/id"}},
+		{`$.items[?(!@.price)]`, []string{"/items/0"}},
+		{`$.items[?(@.tags[0] == 'x' && @.name != "b")]`, []string{"/items/0"}},
+		{`$.items[?(@.price >= 5)]`, []string{"/items/2", "/items/1"}},
+		{`$.items[?(@.name > 'a')].name`, []string{"/items/2/name", "/items/1/name"}},
+		{`$.items[*].price`, []string{"/items/2/price", "/items/1/price", "/items/0/price"}},
+		{`$.items[0,2]`, []string{"/items/2", "/items/0"}},
+		{`$.items[-1].id`, []string{"/items/2/id"}},
+		{`$.items[5]`, nil},
+		{`$['meta']["it's"]`, []string{"/meta/it's"}},
+		{`$.meta['it\'s']`, []string{"/meta/it's"}},
+		{`$.meta.*`, []string{"/meta/it's", "/meta/name"}},
+		{`$..name`, []string{"/items/2/name", "/items/1/name", "/items/0/name", "/meta/name"}},
+		{`$..[0]`, []string{"/items/0/tags/0", "/items/0"}},
+		{`$.missing.name`, nil},
+	} {
+		q, err := parseJSONPath(tc.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tc.expr, err)
+			continue
+		}
+		var got []string
+		for _, p := range q.eval(doc) {
+			got = append(got, pointer.Pointer(p).String())
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.expr, tc.expected, got)
+		}
+	}
+
+	for _, expr := range []string{"items", "$.", "$[", "$['a'", "$[?(@.a ==)]", "$[?(@.a]", "$.a b", "$[?(1)]"} {
+		if _, err := parseJSONPath(expr); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+}
+
+func TestJSONPathOperations(t *testing.T) {
+	doc := decode(`{"items": [{"id": 1, "tmp": true}, {"id": 2}, {"id": 3, "tmp": false}]}`)
+	ops := parseStr(`[
+		{"op": "replace", "path": "$.items[?(@.id == 2)].id", "value": 20},
+		{"op": "add", "path": "$.items[*].seen", "value": true},
+		{"op": "remove", "path": "$.items[?(@.tmp == true)]"},
+		{"op": "remove", "path": "$..tmp"}
+	]`)
+	result, report, err := ApplyWithReport(doc, ops, WithJSONPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := decode(`{"items": [{"id": 20, "seen": true}, {"id": 3, "seen": true}]}`); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	expected := []string{"/items/1/id", "/items/2/seen", "/items/1/seen", "/items/0/seen", "/items/0", "/items/1/tmp"}
+	if touched := report.Touched(); !reflect.DeepEqual(touched, expected) {
+		t.Errorf("expected %v, got %v", expected, touched)
+	}
+
+	if _, err := Apply(doc, ops); err == nil {
+		t.Error("expected JSONPath to be refused by default")
+	}
+	if _, err := Apply(doc, parseStr(`[{"op": "remove", "path": "$.items["}]`), WithJSONPath()); err == nil {
+		t.Error("expected an invalid expression to be an error")
+	}
+}
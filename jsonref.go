@@ -0,0 +1,97 @@
+package patch
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// followRefs returns path with every object it goes through that is a
+// local JSON reference, such as {"$ref": "#/components/schemas/Pet"},
+// replaced by the location the reference points to. A reference is only
+// followed when the path continues into the object with a token that is
+// not one of its members, so that the reference object itself, and its
+// "$ref" member, can still be addressed. Only references to the same
+// document, with a fragment that is a JSON pointer, are followed.
+func followRefs(root interface{}, path []string) ([]string, error) {
+	var out []string
+	current := root
+	// seen holds the references followed since the last token, to detect
+	// references that lead back to themselves
+	var seen map[string]bool
+	for i := 0; i < len(path); {
+		if m, ok := current.(map[string]interface{}); ok {
+			ref, isRef := m["$ref"].(string)
+			if _, member := m[path[i]]; isRef && !member && strings.HasPrefix(ref, "#") {
+				if seen[ref] {
+					return nil, fmt.Errorf("reference %q refers to itself", ref)
+				}
+				if seen == nil {
+					seen = make(map[string]bool)
+				}
+				seen[ref] = true
+				target, err := parseFragment(ref)
+				if err != nil {
+					return nil, err
+				}
+				if current, err = target.Get(root); err != nil {
+					return nil, fmt.Errorf("reference %q: %w", ref, err)
+				}
+				out = slices.Clone(target)
+				continue
+			}
+		}
+		out = append(out, path[i])
+		seen = nil
+		switch v := current.(type) {
+		case map[string]interface{}:
+			current = v[path[i]]
+		case []interface{}:
+			j, err := elementIndex(v, path[i], false)
+			if err != nil {
+				return append(out, path[i+1:]...), nil
+			}
+			current = v[j]
+		default:
+			return append(out, path[i+1:]...), nil
+		}
+		i++
+	}
+	return out, nil
+}
+
+// parseFragment parses the JSON pointer in the URI fragment of a local
+// reference.
+func parseFragment(ref string) (pointer.Pointer, error) {
+	s, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference %q: %w", ref, err)
+	}
+	p, err := pointer.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference %q: %w", ref, err)
+	}
+	return p, nil
+}
+
+// resolveRefs returns ins with its path and from following the references
+// of root. See Options.FollowRefs.
+func (a *applier) resolveRefs(root interface{}, ins *instruction) (*instruction, error) {
+	path, err := followRefs(root, ins.path)
+	if err != nil {
+		return nil, err
+	}
+	resolved := *ins
+	resolved.path = path
+	resolved.op.Path = pointer.Pointer(path).String()
+	if ins.from != nil && ins.ref == nil {
+		if resolved.from, err = followRefs(root, ins.from); err != nil {
+			return nil, err
+		}
+		resolved.op.From = pointer.Pointer(resolved.from).String()
+	}
+	return &resolved, nil
+}
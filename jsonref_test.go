@@ -0,0 +1,56 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFollowRefs(t *testing.T) {
+	doc := decode(`{
+		"paths": {"/pets": {"get": {"schema": {"$ref": "#/components/schemas/Pets"}}}},
+		"components": {"schemas": {
+			"Pets": {"type": "array", "items": {"$ref": "#/components/schemas/Pet"}},
+			"Pet": {"properties": {"name": {"type": "string"}}},
+			"a~b/c": {"$ref": "#/components/schemas/a~0b~1c"},
+			"Alias": {"$ref": "#/components/schemas/Pet%20"},
+			"Pet ": {"$ref": "#/components/schemas/Pet"}
+		}}
+	}`)
+	ops := parseStr(`[
+		{"op": "add", "path": "/paths/~1pets/get/schema/items/properties/id", "value": {"type": "integer"}},
+		{"op": "test", "path": "/components/schemas/Alias/properties/name/type", "value": "string"},
+		{"op": "copy", "from": "/components/schemas/Alias/properties/name", "path": "/paths/~1pets/get/schema/title"},
+		{"op": "replace", "path": "/paths/~1pets/get/schema/$ref", "value": "#/components/schemas/Pet"}
+	]`)
+	result, report, err := ApplyWithReport(doc, ops, WithFollowRefs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemas := result.(map[string]interface{})["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	if expected := decode(`{"properties": {"name": {"type": "string"}, "id": {"type": "integer"}}}`); !reflect.DeepEqual(schemas["Pet"], expected) {
+		t.Errorf("expected %v, got %v", expected, schemas["Pet"])
+	}
+	if expected := decode(`{"type": "array", "items": {"$ref": "#/components/schemas/Pet"}, "title": {"type": "string"}}`); !reflect.DeepEqual(schemas["Pets"], expected) {
+		t.Errorf("expected %v, got %v", expected, schemas["Pets"])
+	}
+	expected := []string{"/components/schemas/Pet/properties/id", "/components/schemas/Pets/title", "/paths/~1pets/get/schema/$ref"}
+	if touched := report.Touched(); !reflect.DeepEqual(touched, expected) {
+		t.Errorf("expected %v, got %v", expected, touched)
+	}
+
+	if _, err := Apply(doc, parseStr(`[{"op": "test", "path": "/components/schemas/a~0b~1c/x", "value": 1}]`), WithFollowRefs()); err == nil {
+		t.Error("expected a reference to itself to be an error")
+	}
+	if _, err := Apply(doc, ops[:1]); err == nil {
+		t.Error("expected references not to be followed by default")
+	}
+
+	// Check does not modify the referenced location
+	before := deepCopy(doc)
+	if err := Check(doc, ops[:1], WithFollowRefs()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc, before) {
+		t.Error("Check modified the document")
+	}
+}
@@ -0,0 +1,162 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// StructDocument returns the JSON representation of the struct v, or of
+// the value v points to, as PatchStruct patches it: the JSON text held by
+// string fields tagged patch:"jsonstring" is decoded in place, so that
+// the document embedded in
+//
+//	type Event struct {
+//		Type    string `json:"type"`
+//		Payload string `json:"payload" patch:"jsonstring"`
+//	}
+//
+// can be read and patched at "/payload/user/id" rather than as an opaque
+// string. The tag also applies to the elements of slices, arrays and maps
+// of strings, and to pointers to strings. Strings that are not JSON text,
+// such as empty ones, are left as they are. Numbers are decoded as
+// json.Number.
+func StructDocument(v interface{}) (interface{}, error) {
+	doc, _, err := structDocument(v)
+	return doc, err
+}
+
+// embeddedJSON is the JSON text of a jsonstring field and the document it
+// was decoded to, or the text itself when it is not JSON.
+type embeddedJSON struct {
+	text string
+	doc  interface{}
+}
+
+// structDocument is StructDocument also returning the embedded documents
+// it decoded, by pointer.
+func structDocument(v interface{}) (interface{}, map[string]embeddedJSON, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	var doc interface{}
+	if err := unmarshalNumber(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	embedded := make(map[string]embeddedJSON)
+	if v == nil {
+		return doc, embedded, nil
+	}
+	doc = jsonStrings(reflect.TypeOf(v), doc, "", false, func(path string, v interface{}) interface{} {
+		text, ok := v.(string)
+		if !ok {
+			return v
+		}
+		var inner interface{}
+		if err := unmarshalNumber([]byte(text), &inner); err != nil {
+			embedded[path] = embeddedJSON{text: text, doc: text}
+			return v
+		}
+		// the patch modifies the document in place
+		embedded[path] = embeddedJSON{text: text, doc: deepCopy(inner)}
+		return inner
+	})
+	return doc, embedded, nil
+}
+
+// encodeJSONStrings encodes the values of the jsonstring fields of doc, the
+// patched JSON representation of a value of type t, back into JSON text.
+// A value equal to the one decoded at the same location keeps its original
+// text, and a null that was not decoded from text stays null.
+func encodeJSONStrings(t reflect.Type, doc interface{}, embedded map[string]embeddedJSON) (interface{}, error) {
+	var err error
+	doc = jsonStrings(t, doc, "", false, func(path string, v interface{}) interface{} {
+		e, decoded := embedded[path]
+		switch {
+		case decoded && jsonEqual(v, e.doc):
+			return e.text
+		case !decoded && v == nil:
+			return v
+		}
+		text, merr := marshal(v)
+		if merr != nil && err == nil {
+			err = fmt.Errorf("field %s: %w", path, merr)
+		}
+		return string(text)
+	})
+	return doc, err
+}
+
+// jsonStrings calls visit with the pointer and the value of every location
+// of doc, the JSON representation of a value of type t, that holds a
+// string field tagged patch:"jsonstring" or an element of one, and stores
+// the value visit returns there instead. tagged is set when doc is such a
+// field. Types with their own MarshalJSON are left alone, as their
+// representation does not follow their fields.
+func jsonStrings(t reflect.Type, doc interface{}, path string, tagged bool, visit func(path string, v interface{}) interface{}) interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if tagged && t.Kind() == reflect.String {
+		return visit(path, doc)
+	}
+	marshaler := reflect.TypeFor[json.Marshaler]()
+	if t.Implements(marshaler) || reflect.PointerTo(t).Implements(marshaler) {
+		return doc
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := doc.(map[string]interface{})
+		if !ok || tagged {
+			return doc
+		}
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() && !f.Anonymous {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" && f.Anonymous {
+				// the fields of embedded structs are promoted
+				ft := f.Type
+				for ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					jsonStrings(ft, m, path, false, visit)
+					continue
+				}
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if v, ok := m[name]; ok {
+				m[name] = jsonStrings(f.Type, v, path+"/"+pointer.Escape(name), f.Tag.Get("patch") == "jsonstring", visit)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if s, ok := doc.([]interface{}); ok {
+			for i := range s {
+				s[i] = jsonStrings(t.Elem(), s[i], path+"/"+strconv.Itoa(i), tagged, visit)
+			}
+		}
+	case reflect.Map:
+		if m, ok := doc.(map[string]interface{}); ok {
+			for k, v := range m {
+				m[k] = jsonStrings(t.Elem(), v, path+"/"+pointer.Escape(k), tagged, visit)
+			}
+		}
+	}
+	return doc
+}
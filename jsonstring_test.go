@@ -0,0 +1,104 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+type testEvent struct {
+	Type    string            `json:"type"`
+	Payload string            `json:"payload" patch:"jsonstring"`
+	Context *string           `json:"context,omitempty" patch:"jsonstring"`
+	Extra   map[string]string `json:"extra,omitempty" patch:"jsonstring"`
+	testMeta
+}
+
+type testMeta struct {
+	Trace []string `json:"trace,omitempty" patch:"jsonstring"`
+}
+
+func TestPatchStructJSONStrings(t *testing.T) {
+	ctx := `{"ip": "10.0.0.1"}`
+	e := testEvent{
+		Type:    "signup",
+		Payload: `{"user": {"id": 12345678901234567890, "name": "Ann"}, "tags": []}`,
+		Context: &ctx,
+		Extra:   map[string]string{"a": `[1, 2]`, "b": `not json`},
+		testMeta: testMeta{
+			Trace: []string{`{"span": 1}`, ``},
+		},
+	}
+	doc, err := StructDocument(&e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := pointer.New("payload", "user", "id").Get(doc); err != nil || id != json.Number("12345678901234567890") {
+		t.Errorf("expected the payload to be decoded, got %v %v", id, err)
+	}
+
+	err = PatchStruct(&e, parseStr(`[
+		{"op": "test", "path": "/payload/user/name", "value": "Ann"},
+		{"op": "add", "path": "/payload/tags/-", "value": "<new>"},
+		{"op": "replace", "path": "/extra/a/0", "value": 3},
+		{"op": "replace", "path": "/trace/0/span", "value": 2}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := testEvent{
+		Type:    "signup",
+		Payload: `{"tags":["<new>"],"user":{"id":12345678901234567890,"name":"Ann"}}`,
+		Context: &ctx,
+		Extra:   map[string]string{"a": `[3,2]`, "b": `not json`},
+		testMeta: testMeta{
+			Trace: []string{`{"span":2}`, ``},
+		},
+	}
+	if !reflect.DeepEqual(e, expected) {
+		t.Errorf("expected %+v, got %+v", expected, e)
+	}
+	if *e.Context != `{"ip": "10.0.0.1"}` {
+		t.Errorf("expected the unchanged context to keep its text, got %s", *e.Context)
+	}
+
+	// fields can be replaced and removed as a whole
+	err = PatchStruct(&e, parseStr(`[
+		{"op": "replace", "path": "/payload", "value": {"v": 1}},
+		{"op": "remove", "path": "/context"},
+		{"op": "replace", "path": "/extra/b", "value": "text"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Payload != `{"v":1}` || e.Context != nil || e.Extra["b"] != `"text"` {
+		t.Errorf("unexpected result %+v", e)
+	}
+
+	// a failing test inside the payload leaves the struct alone
+	before := e
+	if err := PatchStruct(&e, parseStr(`[{"op": "test", "path": "/payload/v", "value": 2}]`)); !errors.Is(err, ErrTestFailed) {
+		t.Errorf("expected ErrTestFailed, got %v", err)
+	}
+	if !reflect.DeepEqual(e, before) {
+		t.Errorf("failed patch modified the struct: %+v", e)
+	}
+}
+
+func TestApplyTypedJSONStrings(t *testing.T) {
+	events := []testEvent{{Type: "a", Payload: `{"n": 1}`}, {Type: "b", Payload: `{"n": 2}`}}
+	patched, err := ApplyTyped(events, parseStr(`[
+		{"op": "move", "from": "/1", "path": "/0"},
+		{"op": "replace", "path": "/1/payload/n", "value": 3}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []testEvent{{Type: "b", Payload: `{"n":2}`}, {Type: "a", Payload: `{"n":3}`}}
+	if !reflect.DeepEqual(patched, expected) {
+		t.Errorf("expected %+v, got %+v", expected, patched)
+	}
+}
@@ -0,0 +1,146 @@
+package patch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// jwsAlgs maps the algorithms of Signature to their names in JWS headers,
+// where they differ.
+var jwsAlgs = map[string]string{"Ed25519": "EdDSA"}
+
+// jwsEncoding is the unpadded base64url encoding of JWS segments, rejecting
+// the non-canonical encodings another implementation could sign differently.
+var jwsEncoding = base64.RawURLEncoding.Strict()
+
+// ApplyJWS verifies the JWS token, in compact serialization, with v, applies
+// operations to its JSON payload as Apply does with opts, and returns the
+// token for the patched payload signed with s. When the payload is a set
+// of JWT claims with exp or nbf, the token is checked against the clock of
+// the options as an Envelope is, failing with a *ValidityError.
+//
+// The signature is verified over the segments of token as they are, never
+// re-encoded. The patched payload is encoded compactly, with the numbers as
+// they were written and without escaping HTML characters. The header is
+// kept byte for byte when s signs with the algorithm and key ID it names,
+// and otherwise has only its alg and kid parameters replaced. A token that
+// is malformed, does not verify or has critical header parameters fails
+// with a *SignatureError.
+func ApplyJWS(token string, operations []Operation, v Verifier, s Signer, opts ...Option) (string, error) {
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return "", &SignatureError{Reason: "malformed token: expected 3 segments"}
+	}
+	data, err := jwsEncoding.DecodeString(segments[0])
+	if err != nil {
+		return "", &SignatureError{Reason: "malformed header: " + err.Error()}
+	}
+	var header map[string]json.RawMessage
+	var alg, kid string
+	if err := json.Unmarshal(data, &header); err != nil {
+		return "", &SignatureError{Reason: "malformed header: " + err.Error()}
+	}
+	if err := json.Unmarshal(header["alg"], &alg); err != nil {
+		return "", &SignatureError{Reason: "malformed header: missing alg"}
+	}
+	if raw, ok := header["kid"]; ok {
+		if err := json.Unmarshal(raw, &kid); err != nil {
+			return "", &SignatureError{Reason: "malformed header: " + err.Error()}
+		}
+	}
+	if _, ok := header["crit"]; ok {
+		// extensions such as an unencoded payload change what the signature
+		// covers, and must be understood to be honoured
+		return "", &SignatureError{KeyID: kid, Reason: "unsupported critical header parameters"}
+	}
+	sig := &Signature{Alg: alg, KeyID: kid}
+	for name, jws := range jwsAlgs {
+		if jws == alg {
+			sig.Alg = name
+		}
+	}
+	if sig.Value, err = jwsEncoding.DecodeString(segments[2]); err != nil {
+		return "", &SignatureError{KeyID: kid, Reason: "malformed signature: " + err.Error()}
+	}
+	if err := v.Verify(sig, []byte(segments[0]+"."+segments[1])); err != nil {
+		return "", err
+	}
+
+	if data, err = jwsEncoding.DecodeString(segments[1]); err != nil {
+		return "", &SignatureError{KeyID: kid, Reason: "malformed payload: " + err.Error()}
+	}
+	var payload interface{}
+	if err := unmarshalNumber(data, &payload); err != nil {
+		return "", fmt.Errorf("jws payload: %w", err)
+	}
+	if claims, ok := payload.(map[string]interface{}); ok {
+		var e Envelope
+		if e.NotBefore, err = numericDate(claims, "nbf"); err != nil {
+			return "", err
+		}
+		if e.ExpiresAt, err = numericDate(claims, "exp"); err != nil {
+			return "", err
+		}
+		if err := e.Check(newOptions(opts).now()); err != nil {
+			return "", err
+		}
+	}
+	result, err := Apply(payload, operations, opts...)
+	if err != nil {
+		return "", err
+	}
+	if data, err = marshal(result); err != nil {
+		return "", err
+	}
+
+	encoded, body := segments[0], jwsEncoding.EncodeToString(data)
+	// the algorithm and key ID of s are only known once it signed, with
+	// the header to be replaced when they are not those it names
+	for range 2 {
+		sig, err := s.Sign([]byte(encoded + "." + body))
+		if err != nil {
+			return "", err
+		}
+		signedAlg := sig.Alg
+		if jws, ok := jwsAlgs[signedAlg]; ok {
+			signedAlg = jws
+		}
+		if signedAlg == alg && sig.KeyID == kid {
+			return encoded + "." + body + "." + jwsEncoding.EncodeToString(sig.Value), nil
+		}
+		alg, kid = signedAlg, sig.KeyID
+		header["alg"], _ = marshal(alg)
+		delete(header, "kid")
+		if kid != "" {
+			header["kid"], _ = marshal(kid)
+		}
+		if data, err = marshal(header); err != nil {
+			return "", err
+		}
+		encoded = jwsEncoding.EncodeToString(data)
+	}
+	return "", fmt.Errorf("jws: signer changed its algorithm or key between signatures")
+}
+
+// numericDate returns the time of the JWT claim name of claims, as seconds
+// since the epoch, or the zero time when it is missing.
+func numericDate(claims map[string]interface{}, name string) (time.Time, error) {
+	v, ok := claims[name]
+	if !ok {
+		return time.Time{}, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, fmt.Errorf("jws payload: claim %q is not a number", name)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("jws payload: claim %q: %w", name, err)
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)), nil
+}
@@ -0,0 +1,120 @@
+package patch
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signJWS returns a compact JWS of header and payload signed with s.
+func signJWS(t *testing.T, header, payload string, s Signer) string {
+	t.Helper()
+	input := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(payload))
+	sig, err := s.Sign([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig.Value)
+}
+
+// jwsSegment returns the decoded segment i of token.
+func jwsSegment(t *testing.T, token string, i int) string {
+	t.Helper()
+	data, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[i])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestApplyJWS(t *testing.T) {
+	upstream := HMACSigner{KeyID: "upstream", Key: []byte("upstream secret")}
+	keys := HMACKeys{"upstream": upstream.Key}
+	header := `{"typ":"JWT", "alg":"HS256", "kid":"upstream"}`
+	token := signJWS(t, header, `{"sub":"42","n":12345678901234567890,"scope":["read"]}`, upstream)
+	ops := parseStr(`[{"op": "add", "path": "/scope/-", "value": "<write>"}]`)
+
+	// re-signed with the same key, the header is kept as it was
+	result, err := ApplyJWS(token, ops, keys, upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := jwsSegment(t, result, 0); got != header {
+		t.Errorf("expected the header to be kept, got %s", got)
+	}
+	if got, expected := jwsSegment(t, result, 1), `{"n":12345678901234567890,"scope":["read","<write>"],"sub":"42"}`; got != expected {
+		t.Errorf("expected payload %s, got %s", expected, got)
+	}
+	if _, err := ApplyJWS(result, nil, keys, upstream); err != nil {
+		t.Errorf("patched token does not verify: %v", err)
+	}
+
+	// re-signed with another key, alg and kid are replaced
+	own := Ed25519Signer{KeyID: "own", Key: ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))}
+	result, err = ApplyJWS(token, ops, keys, own)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := jwsSegment(t, result, 0), `{"alg":"EdDSA","kid":"own","typ":"JWT"}`; got != expected {
+		t.Errorf("expected header %s, got %s", expected, got)
+	}
+	ownKeys := Ed25519Keys{"own": own.Key.Public().(ed25519.PublicKey)}
+	if _, err := ApplyJWS(result, nil, ownKeys, own); err != nil {
+		t.Errorf("re-signed token does not verify: %v", err)
+	}
+	if _, err := ApplyJWS(result, nil, keys, upstream); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected the upstream key not to verify the token, got %v", err)
+	}
+
+	// a failing patch fails
+	if _, err := ApplyJWS(token, parseStr(`[{"op": "remove", "path": "/missing"}]`), keys, upstream); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestApplyJWSInvalid(t *testing.T) {
+	s := HMACSigner{KeyID: "k", Key: []byte("secret")}
+	keys := HMACKeys{"k": s.Key}
+	token := signJWS(t, `{"alg":"HS256","kid":"k"}`, `{"a":1}`, s)
+	segments := strings.Split(token, ".")
+	other := HMACSigner{KeyID: "k", Key: []byte("other")}
+
+	for name, token := range map[string]string{
+		"segments":  segments[0] + "." + segments[1],
+		"signature": signJWS(t, `{"alg":"HS256","kid":"k"}`, `{"a":1}`, other),
+		"payload":   segments[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"a":2}`)) + "." + segments[2],
+		"padding":   token + "=",
+		"none":      signJWS(t, `{"alg":"none","kid":"k"}`, `{"a":1}`, s),
+		"crit":      signJWS(t, `{"alg":"HS256","kid":"k","b64":false,"crit":["b64"]}`, `{"a":1}`, s),
+		"header":    base64.RawURLEncoding.EncodeToString([]byte(`[]`)) + "." + segments[1] + "." + segments[2],
+	} {
+		_, err := ApplyJWS(token, nil, keys, s)
+		var serr *SignatureError
+		if !errors.As(err, &serr) || serr.Code() != "invalid-signature" {
+			t.Errorf("%s: expected a *SignatureError, got %v", name, err)
+		}
+	}
+
+	claims := signJWS(t, `{"alg":"HS256","kid":"k"}`, `{"nbf":1717232400,"exp":1717318800.5}`, s)
+	at := func(unix int64) Option {
+		return WithClock(func() time.Time { return time.Unix(unix, 0) })
+	}
+	if _, err := ApplyJWS(claims, nil, keys, s, at(1717232400)); err != nil {
+		t.Error(err)
+	}
+	if _, err := ApplyJWS(claims, nil, keys, s, at(1717232399)); !errors.Is(err, ErrNotYetValid) {
+		t.Errorf("expected ErrNotYetValid, got %v", err)
+	}
+	if _, err := ApplyJWS(claims, nil, keys, s, at(1717318800)); err != nil {
+		t.Errorf("expected the fraction of exp to be kept, got %v", err)
+	}
+	if _, err := ApplyJWS(claims, nil, keys, s, at(1717318801)); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected ErrExpired, got %v", err)
+	}
+	if _, err := ApplyJWS(signJWS(t, `{"alg":"HS256","kid":"k"}`, `{"exp":"tomorrow"}`, s), nil, keys, s); err == nil {
+		t.Error("expected an error for a malformed exp")
+	}
+}
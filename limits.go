@@ -0,0 +1,119 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrLimitExceeded matches errors for patches refused by Options.Limits.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Limits bound the resources a patch from an untrusted client may use. Zero
+// fields impose no limit.
+type Limits struct {
+	// MaxOperations is the largest number of operations in a patch.
+	MaxOperations int `json:"maxOperations,omitempty"`
+	// MaxPointerDepth is the largest number of tokens in the path or from
+	// of an operation.
+	MaxPointerDepth int `json:"maxPointerDepth,omitempty"`
+	// MaxValueBytes is the largest size of the JSON text of the value of an
+	// operation.
+	MaxValueBytes int `json:"maxValueBytes,omitempty"`
+	// MaxDocumentBytes is the largest size of the patched document encoded
+	// as JSON. Checking it encodes the result once more.
+	MaxDocumentBytes int `json:"maxDocumentBytes,omitempty"`
+}
+
+// LimitExceededError reports a patch, or the document it produced, that
+// exceeds one of its Limits.
+type LimitExceededError struct {
+	Index  int    // of the offending operation, -1 when not about one
+	Limit  string // "operations", "pointer-depth", "value-size" or "document-size"
+	Max    int
+	Actual int // the size found, or Max+1 when Parse stopped counting
+}
+
+func (e *LimitExceededError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("%s limit exceeded: %d > %d", e.Limit, e.Actual, e.Max)
+	}
+	return fmt.Sprintf("operation %d: %s limit exceeded: %d > %d", e.Index, e.Limit, e.Actual, e.Max)
+}
+
+// Is makes LimitExceededError match ErrLimitExceeded.
+func (e *LimitExceededError) Is(target error) bool { return target == ErrLimitExceeded }
+
+// Code returns "limit-exceeded".
+func (e *LimitExceededError) Code() string { return "limit-exceeded" }
+
+// WithLimits refuses patches exceeding l. See Options.Limits.
+func WithLimits(l Limits) Option {
+	return func(o *Options) { o.Limits = l }
+}
+
+// ParseWithLimits is like Parse, but refuses patches exceeding l with a
+// *LimitExceededError. The operations are counted before they are decoded,
+// so a patch with too many of them is refused early. MaxDocumentBytes is
+// not checked, since there is no document yet.
+func ParseWithLimits(patch []byte, l Limits) ([]Operation, error) {
+	if l.MaxOperations > 0 {
+		d := json.NewDecoder(bytes.NewReader(patch))
+		if tok, err := d.Token(); err == nil && tok == json.Delim('[') {
+			for n := 0; d.More(); n++ {
+				if n == l.MaxOperations {
+					return nil, &LimitExceededError{Index: -1, Limit: "operations", Max: l.MaxOperations, Actual: n + 1}
+				}
+				var raw json.RawMessage
+				if d.Decode(&raw) != nil {
+					break // reported by Parse
+				}
+			}
+		}
+	}
+	ops, err := Parse(patch)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.checkPatch(ops); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// checkPatch checks the operations of a patch against the limits.
+func (l *Limits) checkPatch(operations []Operation) error {
+	if l.MaxOperations > 0 && len(operations) > l.MaxOperations {
+		return &LimitExceededError{Index: -1, Limit: "operations", Max: l.MaxOperations, Actual: len(operations)}
+	}
+	for i, op := range operations {
+		if l.MaxPointerDepth > 0 {
+			// every token of a pointer starts with a slash
+			depth := max(strings.Count(op.Path, "/"), strings.Count(op.From, "/"))
+			if depth > l.MaxPointerDepth {
+				return &LimitExceededError{Index: i, Limit: "pointer-depth", Max: l.MaxPointerDepth, Actual: depth}
+			}
+		}
+		if l.MaxValueBytes > 0 && len(op.Value) > l.MaxValueBytes {
+			return &LimitExceededError{Index: i, Limit: "value-size", Max: l.MaxValueBytes, Actual: len(op.Value)}
+		}
+	}
+	return nil
+}
+
+// checkDocument checks the size of a patched document against the limits.
+func (l *Limits) checkDocument(doc interface{}) error {
+	if l.MaxDocumentBytes <= 0 {
+		return nil
+	}
+	data, err := marshal(doc)
+	if err != nil {
+		return err
+	}
+	if len(data) > l.MaxDocumentBytes {
+		return &LimitExceededError{Index: -1, Limit: "document-size", Max: l.MaxDocumentBytes, Actual: len(data)}
+	}
+	return nil
+}
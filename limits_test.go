@@ -0,0 +1,62 @@
+package patch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	limits := Limits{MaxOperations: 2, MaxPointerDepth: 3, MaxValueBytes: 10, MaxDocumentBytes: 30}
+	cases := []struct {
+		patch string
+		limit string // "" when the patch is within the limits
+		index int
+	}{
+		{`[{"op": "add", "path": "/a/b/c", "value": "0123456"}]`, "", 0},
+		{`[{"op": "add", "path": "/x", "value": 1}, {"op": "add", "path": "/y", "value": 1}, {"op": "add", "path": "/z", "value": 1}]`, "operations", -1},
+		{`[{"op": "test", "path": "/a", "value": 1}, {"op": "add", "path": "/a/b/c/d", "value": 1}]`, "pointer-depth", 1},
+		{`[{"op": "copy", "from": "/a/b/c/d", "path": "/x"}]`, "pointer-depth", 0},
+		{`[{"op": "add", "path": "/x", "value": "0123456789"}]`, "value-size", 0},
+		{`[{"op": "add", "path": "/x", "value": "0123456"}, {"op": "add", "path": "/y", "value": "0123456"}]`, "document-size", -1},
+	}
+	for _, c := range cases {
+		_, err := Apply(decode(`{"a": {"b": {}}}`), parseStr(c.patch), WithLimits(limits))
+		if c.limit == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", c.patch, err)
+			}
+			continue
+		}
+		var le *LimitExceededError
+		if !errors.Is(err, ErrLimitExceeded) || !errors.As(err, &le) || le.Limit != c.limit || le.Index != c.index {
+			t.Errorf("%s: expected the %s limit to be exceeded, got %v", c.patch, c.limit, err)
+		}
+	}
+
+	// the document size is checked once the failed operations are skipped
+	_, err := Apply(decode(`{}`), parseStr(`[{"op": "remove", "path": "/x"}, {"op": "add", "path": "/y", "value": "0123456"}]`),
+		WithLimits(Limits{MaxDocumentBytes: 10}), WithContinueOnError())
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected the document size limit to be exceeded, got %v", err)
+	}
+}
+
+func TestParseWithLimits(t *testing.T) {
+	huge := "[" + strings.Repeat(`{"op": "test", "path": "", "value": 1},`, 100) + "garbage"
+	_, err := ParseWithLimits([]byte(huge), Limits{MaxOperations: 10})
+	var le *LimitExceededError
+	if !errors.As(err, &le) || le.Limit != "operations" || le.Actual != 11 || le.Code() != "limit-exceeded" {
+		t.Errorf("expected the operations to be counted before decoding, got %v", err)
+	}
+	if _, err := ParseWithLimits([]byte(`[{"op": "add", "path": "/x", "value": [1, 2, 3]}]`), Limits{MaxValueBytes: 5}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected the value size limit to be exceeded, got %v", err)
+	}
+	ops, err := ParseWithLimits([]byte(`[{"op": "add", "path": "/x", "value": 1}]`), Limits{MaxOperations: 1, MaxValueBytes: 5})
+	if err != nil || len(ops) != 1 {
+		t.Errorf("unexpected result %v, %v", ops, err)
+	}
+	if _, err := ParseWithLimits([]byte(`[{"op": "add"}]`), Limits{MaxOperations: 1}); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("expected an invalid patch, got %v", err)
+	}
+}
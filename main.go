@@ -1,11 +1,15 @@
 package patch
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
-	"strconv"
+	"slices"
 	"strings"
+
+	"github.com/grncdr/json-patch/pointer"
 )
 
 // Operation is the external representation of a change to be applied
@@ -14,6 +18,10 @@ type Operation struct {
 	Path  string          `json:"path"`
 	Value json.RawMessage `json:"value"`
 	From  string          `json:"from,omitempty"`
+	// OnError and Default are the hint of the operation for when it fails
+	// on a document, honoured with Options.OnErrorHints.
+	OnError string          `json:"onError,omitempty"`
+	Default json.RawMessage `json:"default,omitempty"`
 }
 
 // commands are the internal representation of an operation to be applied
@@ -23,12 +31,16 @@ type command struct {
 	pathLen int
 	current interface{}
 	parent  interface{}
-	parents []interface{}
-	key     string
-	value   interface{}
+	// up is where parent is stored in its own parent, when it has one
+	up    slot
+	key   string
+	value interface{}
+	from  []string
+	ref   *int
+	name  bool
 }
 
-type operator func(interface{}, *Operation, *command) (interface{}, error)
+type operator func(*applier, interface{}, *Operation, *command) (interface{}, error)
 
 var impls = map[string]operator{
 	"add":     applyAdd,
@@ -39,31 +51,167 @@ var impls = map[string]operator{
 	"copy":    applyCopy,
 }
 
+// Parse decodes a patch. An operation lacking a member its operator
+// requires gives an InvalidPatchError; see Operation.UnmarshalJSON.
 func Parse(patch []byte) ([]Operation, error) {
 	result := make([]Operation, 0)
-	if err := json.Unmarshal(patch, &result); err != nil {
+	if err := currentCodec().Unmarshal(patch, &result); err != nil {
+		var inv *InvalidPatchError
+		if errors.As(err, &inv) {
+			inv.Index = invalidIndex(patch)
+		}
 		return nil, err
 	}
 	return result, nil
 }
 
-func Apply(o interface{}, operations []Operation) (interface{}, error) {
-	return ApplyUnsafe(deepCopy(o), operations)
+// invalidIndex returns the index of the first operation of patch that
+// does not decode, which Operation.UnmarshalJSON cannot know.
+func invalidIndex(patch []byte) int {
+	var raw []json.RawMessage
+	json.Unmarshal(patch, &raw)
+	for i, r := range raw {
+		var op Operation
+		if op.UnmarshalJSON(r) != nil {
+			return i
+		}
+	}
+	return 0
+}
+
+// Apply applies operations to a deep copy of o and returns the result. o is
+// never modified, unless WithInPlace is given.
+func Apply(o interface{}, operations []Operation, opts ...Option) (interface{}, error) {
+	options := newOptions(opts)
+	if !options.InPlace {
+		o = deepCopy(o)
+	}
+	a := &applier{opts: options}
+	return a.apply(o, operations)
+}
+
+// ApplyUnsafe applies operations directly to o, skipping the deep copy made
+// by Apply. Always use the returned document: o itself is stale whenever the
+// patch replaces the whole document or resizes a top-level array, whose
+// elements are then shifted in place.
+//
+// If an operation fails, the operations before it remain applied to o and
+// the failing one may be half done (a move may have removed its source
+// without adding it at the destination), so o should be discarded.
+func ApplyUnsafe(o interface{}, operations []Operation, opts ...Option) (interface{}, error) {
+	a := &applier{opts: newOptions(opts)}
+	return a.apply(o, operations)
+}
+
+// ApplyWithReport applies operations to a copy of o like Apply (or to o
+// itself, like ApplyUnsafe, with WithInPlace), and also returns a Report
+// listing every pointer the patch modified.
+func ApplyWithReport(o interface{}, operations []Operation, opts ...Option) (interface{}, *Report, error) {
+	return applyWithReport(o, operations, newOptions(opts))
+}
+
+// applyWithReport is ApplyWithReport with already built options.
+func applyWithReport(o interface{}, operations []Operation, opts *Options) (interface{}, *Report, error) {
+	a := &applier{opts: opts, report: &Report{}}
+	if !opts.InPlace {
+		o = deepCopy(o)
+	}
+	result, err := a.apply(o, operations)
+	if err != nil && !opts.ContinueOnError {
+		return nil, nil, err
+	}
+	return result, a.report, err
+}
+
+// applier holds the per-call state of a patch application.
+type applier struct {
+	opts      *Options
+	report    *Report
+	undo      *undoLog
+	useNumber bool
+	defaults  []defaulter
+	// referenced marks the operations whose output later operations refer
+	// to, and outputs holds a copy of each such output
+	referenced map[int]bool
+	outputs    map[int]interface{}
+	// dry is set by Check and CheckAll, which only report errors and do
+	// not charge the quota
+	dry bool
+	// shared is set when the document must not be modified because others
+	// hold it, by Check, CheckAll and SyncDocument: each operation then
+	// copies the objects and arrays it writes to
+	shared bool
+	// checked is set when the document is known to pass the UTF-8 check
+	checked bool
+	// ctx is checked for cancellation between operations by ApplyContext
+	ctx context.Context
+	// groups are the indexes of the operations of each group applied
+	// atomically by ApplyGroups, and outcomes their outcomes
+	groups   [][]int
+	outcomes []Group
+	// scope holds the tokens of Options.Scope once parsed
+	scope []string
+	// compiled holds the instructions of the patch when compiled ahead of
+	// time by ApplyAll, nil for the operations that failed to compile
+	compiled []*instruction
 }
 
-func ApplyUnsafe(o interface{}, operations []Operation) (interface{}, error) {
-	for _, op := range operations {
-		impl := impls[op.Op]
-		if impl == nil {
-			return nil, fmt.Errorf("%s is not valid operator", op.Op)
+func (a *applier) apply(o interface{}, operations []Operation) (interface{}, error) {
+	if err := a.opts.Limits.checkPatch(operations); err != nil {
+		return nil, err
+	}
+	o, err := a.run(o, operations)
+	if err == nil || a.opts.ContinueOnError || a.groups != nil {
+		if a.opts.Intern && !a.dry {
+			o = Intern(o)
+		}
+		if err := a.opts.Limits.checkDocument(o); err != nil {
+			return nil, err
 		}
+		if a.opts.Schema != nil {
+			if err := a.opts.Schema.Validate(o); err != nil {
+				return nil, blame(err, operations, a.scope)
+			}
+		}
+	}
+	return o, err
+}
 
-		c, err := makeCommand(o, &op)
+// run applies operations to o once the patch is within the limits.
+func (a *applier) run(o interface{}, operations []Operation) (interface{}, error) {
+	if !a.dry {
+		if err := a.charge(operations); err != nil {
+			return nil, err
+		}
+	}
+	if a.opts.UTF8 != UTF8PassThrough && !a.checked {
+		if a.shared {
+			// checkDocument rewrites the strings it visits
+			o = deepCopy(o)
+		}
+		var err error
+		if o, err = a.opts.UTF8.checkDocument(o, ""); err != nil {
+			return nil, err
+		}
+	}
+	if a.opts.OpRefs {
+		a.referenced = referencedOps(operations)
+	}
+	if a.groups != nil {
+		return a.applyGroups(o, operations)
+	}
+	if a.shared || a.opts.ContinueOnError || a.opts.OnErrorHints {
+		return a.applyEach(o, operations)
+	}
+	for i, op := range operations {
+		if err := a.interrupted(i); err != nil {
+			return nil, err
+		}
+		ins, err := a.instruction(i, op)
 		if err != nil {
 			return nil, err
 		}
-
-		o, err = impl(o, &op, c)
+		o, err = a.exec(o, i, ins)
 		if err != nil {
 			return nil, err
 		}
@@ -72,15 +220,250 @@ func ApplyUnsafe(o interface{}, operations []Operation) (interface{}, error) {
 	return o, nil
 }
 
-func makeCommand(root interface{}, op *Operation) (*command, error) {
-	value, err := getOperatorValue(op)
+// instruction is an operation with its operator, pointers and value resolved
+// ahead of time, so that it can be executed against any number of documents.
+type instruction struct {
+	op   Operation
+	impl operator
+	path []string
+	from []string
+	// ref is the index of the earlier operation whose output from is
+	// relative to, when from is an "@N" reference
+	ref *int
+	// rel is the path of a test operation given as a relative pointer,
+	// resolved against Options.Anchor when the instruction is executed,
+	// and name is set once the resolved path is that of a "#" pointer
+	rel  *pointer.Relative
+	name bool
+	// query is the path of the operation when it is a JSONPath expression,
+	// evaluated when the instruction is executed
+	query jsonPath
+	value interface{}
+	// shared is set when the instruction is reused across applications,
+	// in which case its value must be copied before being inserted.
+	shared bool
+}
+
+// compile resolves the i-th operation of a patch into an instruction. All
+// errors are InvalidPatchErrors.
+func (a *applier) compile(i int, op Operation) (*instruction, error) {
+	ins, err := a.compileOp(i, op)
+	if err == nil && a.opts.OnErrorHints {
+		err = checkHint(op)
+	}
+	if err != nil {
+		return nil, &InvalidPatchError{Index: i, Op: op.Op, Err: err}
+	}
+	return ins, nil
+}
+
+// instruction returns the instruction of the i-th operation of a patch,
+// compiling it unless ApplyAll already did.
+func (a *applier) instruction(i int, op Operation) (*instruction, error) {
+	if i < len(a.compiled) && a.compiled[i] != nil {
+		return a.compiled[i], nil
+	}
+	return a.compile(i, op)
+}
+
+func (a *applier) compileOp(i int, op Operation) (*instruction, error) {
+	impl, err := a.operator(op.Op)
+	if err != nil {
+		return nil, err
+	}
+	if impl == nil {
+		// an unknown operator to skip, whatever its other members
+		return &instruction{op: op}, nil
+	}
+
+	if a.opts.UTF8 != UTF8PassThrough {
+		if err := a.opts.UTF8.checkOperation(&op); err != nil {
+			return nil, err
+		}
+	}
+
+	value, err := a.getOperatorValue(&op)
 	if err != nil {
 		return nil, err
 	}
-	path, err := parsePath(op.Path)
+	if op.Op == "test" && a.opts.Anchor != "" && isRelative(op.Path) {
+		rel, err := pointer.ParseRelative(op.Path)
+		if err != nil {
+			return nil, err
+		}
+		return &instruction{op: op, impl: impl, rel: &rel, value: value}, nil
+	}
+	ins := &instruction{op: op, impl: impl, value: value}
+	if a.opts.JSONPath && isJSONPath(op.Path) {
+		if ins.query, err = parseJSONPath(op.Path); err != nil {
+			return nil, err
+		}
+	} else if ins.path, err = parsePath(op.Path); err != nil {
+		return nil, err
+	} else if ins.path, err = a.scoped(ins.path); err != nil {
+		return nil, err
+	}
+	path := ins.path
+	if op.Op == "move" || op.Op == "copy" {
+		if op.From == "" {
+			return nil, fmt.Errorf("missing parameter 'from'")
+		}
+		if op.Op == "copy" && a.opts.OpRefs && strings.HasPrefix(op.From, "@") {
+			ref, from, err := parseRef(op.From, i)
+			if err != nil {
+				return nil, err
+			}
+			ins.ref, ins.from = &ref, from
+		} else if ins.from, err = parsePath(op.From); err != nil {
+			return nil, err
+		} else if ins.from, err = a.scoped(ins.from); err != nil {
+			return nil, err
+		}
+		if op.Op == "move" && len(ins.from) < len(path) && slices.Equal(ins.from, path[:len(ins.from)]) {
+			return nil, fmt.Errorf("cannot move %s into its own child %s", op.From, op.Path)
+		}
+	}
+	return ins, nil
+}
+
+// exec applies the i-th instruction of a patch to o. Errors carry the index
+// of the instruction.
+func (a *applier) exec(o interface{}, i int, ins *instruction) (interface{}, error) {
+	if ins.impl == nil {
+		if a.opts.OnUnknownOp != nil {
+			a.opts.OnUnknownOp(i, ins.op)
+		}
+		return o, nil
+	}
+	if ins.rel != nil {
+		resolved, err := a.resolveRelative(o, ins)
+		if err != nil {
+			return nil, opError(i, &ins.op, err)
+		}
+		ins = resolved
+	}
+	if ins.query != nil {
+		paths, err := a.evalScoped(o, ins.query)
+		if err != nil {
+			return nil, opError(i, &ins.op, err)
+		}
+		return a.execEach(o, i, ins, paths)
+	}
+	if a.opts.Wildcards && hasWildcard(ins.path) {
+		return a.execWildcard(o, i, ins)
+	}
+	return a.execPath(o, i, ins)
+}
+
+// execPath applies the i-th instruction of a patch to o once its path is
+// known.
+func (a *applier) execPath(o interface{}, i int, ins *instruction) (interface{}, error) {
+	if a.opts.FollowRefs {
+		resolved, err := a.resolveRefs(o, ins)
+		if err != nil {
+			return nil, opError(i, &ins.op, err)
+		}
+		o, ins = a.isolate(o, resolved), resolved
+	}
+	if a.opts.IndexResolver != nil {
+		resolved, err := a.resolveIndices(o, ins)
+		if err != nil {
+			return nil, opError(i, &ins.op, err)
+		}
+		o, ins = a.isolate(o, resolved), resolved
+	}
+	if err := a.checkScope(i, ins); err != nil {
+		return nil, err
+	}
+	if a.opts.NegativeIndices && (ins.op.Op == "remove" || ins.op.Op == "replace" || ins.op.Op == "test") {
+		resolved, err := a.resolveNegative(o, ins)
+		if err != nil {
+			return nil, opError(i, &ins.op, err)
+		}
+		o, ins = a.isolate(o, resolved), resolved
+	}
+	if ins.op.Op == "move" && a.opts.MoveIndex != MoveAfterRemove {
+		resolved, err := a.resolveMove(o, ins)
+		if err != nil {
+			return nil, opError(i, &ins.op, err)
+		}
+		ins = resolved
+	}
+	created := -1
+	if ins.op.Op == "add" && a.opts.CreateMissingParents {
+		created = createParents(o, ins.path)
+	}
+	target := o
+	if ins.op.Op == "move" {
+		target = a.moveTarget(o, ins)
+	}
+	c, err := a.makeCommand(target, ins)
 	if err != nil {
+		return nil, opError(i, &ins.op, err)
+	}
+	if err := a.authorize(i, &ins.op, c); err != nil {
 		return nil, err
 	}
+	if a.opts.BeforeOp != nil {
+		if err := a.opts.BeforeOp(ins.op, targetPath(ins, c)); err != nil {
+			return nil, opError(i, &ins.op, err)
+		}
+	}
+
+	if ins.op.Op == "add" && len(a.opts.Defaulters) > 0 {
+		if err := a.applyDefaults(c); err != nil {
+			return nil, opError(i, &ins.op, err)
+		}
+	}
+
+	if ins.op.Op == "replace" && (a.opts.Coerce || len(a.opts.CoerceTypes) > 0) {
+		if err := a.coerce(c); err != nil {
+			return nil, opError(i, &ins.op, err)
+		}
+	}
+
+	if a.report != nil {
+		a.record(o, i, &ins.op, c)
+	}
+
+	var undo []Operation
+	if a.undo != nil && created >= 0 {
+		// removing the outermost created parent removes the rest with it
+		undo, err = undoOp("remove", pointer.Pointer(ins.path[:created+1]).String(), nil)
+	} else if a.undo != nil {
+		if undo, err = a.invert(o, ins, c); err != nil {
+			return nil, opError(i, &ins.op, err)
+		}
+	}
+
+	var old interface{}
+	if a.opts.AfterOp != nil {
+		old, _ = c.prior(ins.op.Op != "remove" && ins.op.Op != "replace")
+	}
+
+	o, err = ins.impl(a, o, &ins.op, c)
+	if err != nil {
+		return nil, opError(i, &ins.op, err)
+	}
+	if a.undo != nil {
+		a.undo.groups = append(a.undo.groups, undo)
+	}
+	if a.referenced[i] {
+		a.capture(o, i, ins, c)
+	}
+	if a.opts.AfterOp != nil && ins.op.Op != "test" {
+		v, _ := output(o, ins, c)
+		a.opts.AfterOp(ins.op, old, v)
+	}
+	return o, nil
+}
+
+func (a *applier) makeCommand(root interface{}, ins *instruction) (*command, error) {
+	value := ins.value
+	if ins.shared {
+		value = deepCopy(value)
+	}
+	path := ins.path
 	pathLen := len(path)
 	if pathLen == 0 {
 		return &command{
@@ -88,9 +471,11 @@ func makeCommand(root interface{}, op *Operation) (*command, error) {
 			pathLen: pathLen,
 			key:     "",
 			value:   value,
+			from:    ins.from,
+			ref:     ins.ref,
+			name:    ins.name,
 			current: root,
 			parent:  nil,
-			parents: nil,
 		}, nil
 	}
 	key := path[pathLen-1]
@@ -99,41 +484,52 @@ func makeCommand(root interface{}, op *Operation) (*command, error) {
 	if err != nil {
 		return nil, err
 	}
+	var up slot
+	if pathLen > 1 {
+		up = slotOf(elements[pathLen-2], path[pathLen-2])
+	}
 	return &command{
 		path:    path,
 		pathLen: pathLen,
 		key:     key,
 		value:   value,
+		from:    ins.from,
+		ref:     ins.ref,
+		name:    ins.name,
 		current: elements[pathLen],
 		parent:  elements[pathLen-1],
-		parents: elements[:pathLen-1],
+		up:      up,
 	}, nil
 }
 
-func getOperatorValue(op *Operation) (interface{}, error) {
+func (a *applier) getOperatorValue(op *Operation) (interface{}, error) {
 	if op.Value == nil {
 		if op.Op == "add" || op.Op == "replace" || op.Op == "test" {
 			return nil, fmt.Errorf("missing 'value' parameter")
 		}
+		return nil, nil
 	}
 	var result interface{}
-	json.Unmarshal(op.Value, &result)
+	if err := a.unmarshal(op.Value, &result); err != nil {
+		return nil, fmt.Errorf("invalid 'value' parameter: %v", err)
+	}
 	return result, nil
 }
 
-func parsePath(s string) ([]string, error) {
-	parts := strings.Split(s, "/")[1:]
-	if len(parts) == 0 {
-		return parts, nil
+// unmarshal decodes JSON text with the codec of the options, using
+// json.Number for numbers when the applier preserves number precision.
+func (a *applier) unmarshal(data []byte, v interface{}) error {
+	if a.useNumber || a.opts.UseNumber {
+		return a.opts.codec().UnmarshalNumber(data, v)
 	}
-	out := make([]string, len(parts))
-	for i, part := range parts {
-		out[i] = strings.Replace(strings.Replace(part, "~1", "/", -1), "~0", "~", -1)
-	}
-	return out, nil
+	return a.opts.codec().Unmarshal(data, v)
+}
+
+func parsePath(s string) ([]string, error) {
+	return pointer.Parse(s)
 }
 
-func applyAdd(root interface{}, op *Operation, c *command) (interface{}, error) {
+func applyAdd(a *applier, root interface{}, op *Operation, c *command) (interface{}, error) {
 	if len(c.path) == 0 {
 		return c.value, nil
 	}
@@ -142,61 +538,69 @@ func applyAdd(root interface{}, op *Operation, c *command) (interface{}, error)
 		m := c.parent.(map[string]interface{})
 		m[c.key] = c.value
 		return root, nil
+	case *SortedObject:
+		c.parent.(*SortedObject).Set(c.key, c.value)
+		return root, nil
 	case []interface{}:
 		s := c.parent.([]interface{})
-		i, err := parseIndex(c.key, len(s), true)
+		i, err := elementIndex(s, c.key, true)
 		if err != nil {
 			return nil, err
 		}
-
-		s = append(s, nil)
-		copy(s[i+1:], s[i:])
-		s[i] = c.value
-
-		if root, err := swapParentSlice(root, s, c); err != nil {
-			return nil, fmt.Errorf("Failed to swap in new slice")
-		} else {
-			return root, nil
-		}
+		return c.setParent(root, slices.Insert(s, i, c.value)), nil
 	}
 
 	return nil, fmt.Errorf("Cannot set key %s in a %T", c.key, c.parent)
 }
 
-func applyRemove(root interface{}, op *Operation, c *command) (interface{}, error) {
+func applyRemove(a *applier, root interface{}, op *Operation, c *command) (interface{}, error) {
 	switch c.parent.(type) {
 	case map[string]interface{}:
 		m := c.parent.(map[string]interface{})
+		if _, ok := m[c.key]; !ok {
+			return nil, fmt.Errorf("member %q: %w", c.key, ErrNotFound)
+		}
 		delete(m, c.key)
 		return root, nil
+	case *SortedObject:
+		if !c.parent.(*SortedObject).Delete(c.key) {
+			return nil, fmt.Errorf("member %q: %w", c.key, ErrNotFound)
+		}
+		return root, nil
 	case []interface{}:
 		s := c.parent.([]interface{})
-		i, err := strconv.Atoi(c.key)
-		if err != nil || i > len(s) {
-			return nil, fmt.Errorf("Invalid array index %s", c.key)
+		i, err := elementIndex(s, c.key, false)
+		if err != nil {
+			return nil, err
 		}
-		s2 := make([]interface{}, len(s)-1)
-		copy(s2, s[0:i])
-		copy(s2[i:], s[i+1:])
-
-		return swapParentSlice(root, s2, c)
+		return c.setParent(root, slices.Delete(s, i, i+1)), nil
 	}
 
 	return nil, fmt.Errorf("Cannot remove from a %T", c.parent)
 }
 
-func applyReplace(root interface{}, op *Operation, c *command) (interface{}, error) {
+func applyReplace(a *applier, root interface{}, op *Operation, c *command) (interface{}, error) {
 	if len(c.path) == 0 {
 		return c.value, nil
 	}
 	switch c.parent.(type) {
 	case map[string]interface{}:
 		m := c.parent.(map[string]interface{})
+		if _, ok := m[c.key]; !ok {
+			return nil, fmt.Errorf("member %q: %w", c.key, ErrNotFound)
+		}
 		m[c.key] = c.value
 		return root, nil
+	case *SortedObject:
+		o := c.parent.(*SortedObject)
+		if _, ok := o.Get(c.key); !ok {
+			return nil, fmt.Errorf("member %q: %w", c.key, ErrNotFound)
+		}
+		o.Set(c.key, c.value)
+		return root, nil
 	case []interface{}:
 		s := c.parent.([]interface{})
-		i, err := parseIndex(c.key, len(s), false)
+		i, err := elementIndex(s, c.key, false)
 		if err != nil {
 			return nil, err
 		}
@@ -206,124 +610,137 @@ func applyReplace(root interface{}, op *Operation, c *command) (interface{}, err
 	return nil, fmt.Errorf("Cannot replace %s in a %T", c.key, c.parent)
 }
 
-func applyMove(root interface{}, op *Operation, c *command) (interface{}, error) {
-	if op.From == "" {
-		return nil, fmt.Errorf("missing parameter 'from'")
+func applyMove(a *applier, root interface{}, op *Operation, c *command) (interface{}, error) {
+	rmOp := &instruction{
+		op:   Operation{Op: "remove", Path: op.From},
+		path: c.from,
 	}
-	rmOp := Operation{
-		Op:   "remove",
-		Path: op.From,
-	}
-	rmContext, err := makeCommand(root, &rmOp)
+	rmContext, err := a.makeCommand(root, rmOp)
 	if err != nil {
 		return nil, err
 	}
-	root, err = applyRemove(root, &rmOp, rmContext)
+	root, err = applyRemove(a, root, &rmOp.op, rmContext)
 	if err != nil {
 		return nil, err
 	}
+	if a.copies() {
+		// removing the value may have shifted the destination into
+		// containers that were not copied beforehand
+		root = copyPath(root, c.path)
+	}
+	// the removed value is no longer in the document and can be reused
+	return a.addValue(root, op, c, rmContext.current)
+}
 
-	stringVal, err := json.Marshal(rmContext.current)
+// moveTarget returns the document the path of the move ins is resolved
+// against: root with the moved value removed, as RFC 6902 defines move,
+// when it is an array element and the path goes through its array, and
+// root otherwise. root itself is not modified.
+func (a *applier) moveTarget(root interface{}, ins *instruction) interface{} {
+	n := len(ins.from)
+	if n == 0 || len(ins.path) < n || !slices.Equal(ins.from[:n-1], ins.path[:n-1]) {
+		return root
+	}
+	view := copyPath(root, ins.from)
+	rmOp := &instruction{op: Operation{Op: "remove", Path: ins.op.From}, path: ins.from}
+	c, err := a.makeCommand(view, rmOp)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to marshal %v to JSON (should never happen)", rmContext.current)
+		// applyMove will report the same failure
+		return root
 	}
-
-	addOp := Operation{
-		Op:    "add",
-		Path:  op.Path,
-		Value: json.RawMessage(stringVal),
+	if _, ok := c.parent.([]interface{}); !ok {
+		return root
 	}
-	addContext, err := makeCommand(root, &addOp)
-	if err != nil {
-		return nil, err
+	if view, err = applyRemove(a, view, &rmOp.op, c); err != nil {
+		return root
 	}
-	return applyAdd(root, &addOp, addContext)
+	return view
 }
 
-// this is just applyMove without actually executing the move, so also way too
-// slow.
-func applyCopy(root interface{}, op *Operation, c *command) (interface{}, error) {
-	if op.From == "" {
-		return nil, fmt.Errorf("missing parameter 'from'")
-	}
-	rmOp := Operation{
-		Op:   "remove",
-		Path: op.From,
+func applyCopy(a *applier, root interface{}, op *Operation, c *command) (interface{}, error) {
+	var src interface{}
+	var err error
+	if c.ref != nil {
+		src, err = a.output(*c.ref, c.from)
+	} else {
+		src, err = pointer.Pointer(c.from).Get(root)
 	}
-	rmContext, err := makeCommand(root, &rmOp)
 	if err != nil {
 		return nil, err
 	}
+	return a.addValue(root, op, c, deepCopy(src))
+}
 
-	stringVal, err := json.Marshal(rmContext.current)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to marshal %v to JSON (should never happen)", rmContext.current)
-	}
-
-	addOp := Operation{
-		Op:    "add",
-		Path:  op.Path,
-		Value: json.RawMessage(stringVal),
+// addValue adds value at the path of the move or copy command c.
+func (a *applier) addValue(root interface{}, op *Operation, c *command, value interface{}) (interface{}, error) {
+	addOp := &instruction{
+		op:    Operation{Op: "add", Path: op.Path},
+		path:  c.path,
+		value: value,
 	}
-	addContext, err := makeCommand(root, &addOp)
+	addContext, err := a.makeCommand(root, addOp)
 	if err != nil {
 		return nil, err
 	}
-	return applyAdd(root, &addOp, addContext)
+	return applyAdd(a, root, &addOp.op, addContext)
 }
 
-func applyTest(root interface{}, op *Operation, c *command) (interface{}, error) {
-	if reflect.DeepEqual(c.current, c.value) {
+func applyTest(a *applier, root interface{}, op *Operation, c *command) (interface{}, error) {
+	current, ok := c.prior(false)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if c.name {
+		current = c.key
+		if s, ok := c.parent.([]interface{}); ok {
+			current, _ = elementIndex(s, c.key, false)
+		}
+	}
+	if a.testEqual(c.value, current) {
 		return root, nil
 	}
-	return nil, fmt.Errorf("%s expected to be %v, found %v", c.path, c.value, c.current)
+	return nil, &TestFailedError{Path: op.Path, Expected: c.value, Actual: current}
 }
 
-func parseIndex(s string, max int, allowDash bool) (int, error) {
-	if allowDash && s == "-" {
-		//fmt.Printf("Parsed \"-\" array index...\n")
-		return max, nil
-	}
-	i, err := strconv.Atoi(s)
-	if err != nil {
-		return -1, err
-	}
-	if i > max || i < 0 {
-		return -1, fmt.Errorf("Array index %d out of bounds", i)
-	}
-	//fmt.Printf("Parsed array index %d...\n", i)
-	return i, nil
+// slot is the place of a container in its parent object or array.
+type slot struct {
+	object map[string]interface{}
+	sorted *SortedObject
+	key    string
+	array  []interface{}
+	index  int
 }
 
-func swapParentSlice(root interface{}, newParent []interface{}, c *command) (interface{}, error) {
-	if c.pathLen > 1 {
-		gp := c.parents[c.pathLen-2]
-		k := c.path[c.pathLen-2]
-		// fmt.Printf("Setting %k in %v to %v\n", k, gp, newParent)
-		switch gp.(type) {
-		case map[string]interface{}:
-			m := gp.(map[string]interface{})
-			m[k] = newParent
-			return root, nil
-		case []interface{}:
-			s := gp.([]interface{})
-			i, err := parseIndex(k, len(s), false)
-			if err != nil {
-				return nil, err
-			}
-			s[i] = newParent
-			return root, nil
-		}
+func slotOf(parent interface{}, key string) slot {
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		return slot{object: p, key: key}
+	case *SortedObject:
+		return slot{sorted: p, key: key}
+	case []interface{}:
+		// walkPath descended into this element, so the index is valid
+		i, _ := elementIndex(p, key, false)
+		return slot{array: p, index: i}
+	}
+	return slot{}
+}
 
-		// why this should never happen:
-		//  - `gp` is by definition a value that we indexed into earlier to get the
-		//    descending slice element we now want to replace
-		return nil, fmt.Errorf("Cannot index a %T (this should never happen)", c)
-	} else if c.pathLen == 1 {
-		// the slice to be replaced _is_ the root
-		return newParent, nil
+// setParent stores s, the array holding the command's target after an
+// element was inserted or removed, in place of that array, and returns the
+// root of the document. Inserting and removing elements work on the array
+// itself, so only its length changes unless it had to grow.
+func (c *command) setParent(root interface{}, s []interface{}) interface{} {
+	switch {
+	case c.pathLen == 1:
+		return s
+	case c.up.object != nil:
+		c.up.object[c.up.key] = s
+	case c.up.sorted != nil:
+		c.up.sorted.Set(c.up.key, s)
+	default:
+		c.up.array[c.up.index] = s
 	}
-	return nil, fmt.Errorf("zero-length path invalid")
+	return root
 }
 
 func walkPath(root interface{}, path []string) ([]interface{}, error) {
@@ -333,20 +750,33 @@ func walkPath(root interface{}, path []string) ([]interface{}, error) {
 	for i, key := range path {
 		switch current.(type) {
 		case map[string]interface{}:
-			elements[i+1] = current.(map[string]interface{})[key]
+			v, ok := current.(map[string]interface{})[key]
+			if !ok && i < len(path)-1 {
+				return nil, fmt.Errorf("member %q: %w", key, ErrNotFound)
+			}
+			elements[i+1] = v
+			current = elements[i+1]
+		case *SortedObject:
+			v, ok := current.(*SortedObject).Get(key)
+			if !ok && i < len(path)-1 {
+				return nil, fmt.Errorf("member %q: %w", key, ErrNotFound)
+			}
+			elements[i+1] = v
 			current = elements[i+1]
 		case []interface{}:
 			s := current.([]interface{})
-			if j, err := parseIndex(key, len(s), true); err != nil {
+			// the last token may name the position after the last element,
+			// which only add accepts
+			j, err := elementIndex(s, key, i == len(path)-1)
+			if err != nil {
 				return nil, err
+			}
+			if j < len(s) {
+				elements[i+1] = s[j]
 			} else {
-				if j < len(s) {
-					elements[i+1] = s[j]
-				} else {
-					elements[i+1] = nil
-				}
-				current = elements[i+1]
+				elements[i+1] = nil
 			}
+			current = elements[i+1]
 		default:
 			return nil, fmt.Errorf("Cannot index a %T", current)
 		}
@@ -354,24 +784,161 @@ func walkPath(root interface{}, path []string) ([]interface{}, error) {
 	return elements, nil
 }
 
+// createParents adds an empty object for every member of path, except the
+// last, that is missing from an object in root. Array elements are never
+// created, and walking stops at the first array index that does not resolve
+// so that the error is reported as usual. It returns the position in path
+// of the first created member, or -1.
+func createParents(root interface{}, path []string) int {
+	created := -1
+	current := root
+	for i := 0; i < len(path)-1; i++ {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[path[i]]
+			if !ok {
+				next = map[string]interface{}{}
+				v[path[i]] = next
+				if created < 0 {
+					created = i
+				}
+			}
+			current = next
+		case *SortedObject:
+			next, ok := v.Get(path[i])
+			if !ok {
+				next = map[string]interface{}{}
+				v.Set(path[i], next)
+				if created < 0 {
+					created = i
+				}
+			}
+			current = next
+		case []interface{}:
+			j, err := elementIndex(v, path[i], false)
+			if err != nil {
+				return created
+			}
+			current = v[j]
+		default:
+			return created
+		}
+	}
+	return created
+}
+
+// Copier is implemented by values of types other than those produced by
+// encoding/json, such as values put in a document programmatically, that
+// hold references to mutable data. Apply and the other functions copying a
+// document call DeepCopy instead of sharing the value between the copies.
+type Copier interface {
+	DeepCopy() interface{}
+}
+
 /**
  * Cheapish deep-copy, this does not copy strings because strings inside an
- * interface{} are treated as immutable anyways
+ * interface{} are treated as immutable anyways. The same goes for
+ * json.Number, time.Time and the other values of non-reference types, while
+ * slices and maps of other types are copied with reflection, and pointers and
+ * structs are shared unless they implement Copier.
  */
 func deepCopy(root interface{}) interface{} {
 	switch src := root.(type) {
+	case nil, string, bool, float64, json.Number:
+		return root
 	case map[string]interface{}:
 		out := make(map[string]interface{}, len(src))
 		for k, v := range src {
 			out[k] = deepCopy(v)
 		}
 		return out
+	case *SortedObject:
+		out := src.clone()
+		for i, v := range out.values {
+			out.values[i] = deepCopy(v)
+		}
+		return out
 	case []interface{}:
 		out := make([]interface{}, len(src))
 		for k, v := range src {
 			out[k] = deepCopy(v)
 		}
 		return out
+	case json.RawMessage:
+		return slices.Clone(src)
+	case Copier:
+		if isNil(reflect.ValueOf(src)) {
+			// most implementations would dereference the nil pointer
+			return root
+		}
+		return src.DeepCopy()
+	}
+	return copyReflect(root)
+}
+
+// copyReflect copies the slices and maps of types deepCopy does not know,
+// along with their elements.
+func copyReflect(root interface{}) interface{} {
+	v := reflect.ValueOf(root)
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return root
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		if basicKind(v.Type().Elem().Kind()) {
+			// elements of basic kinds need no copying of their own
+			reflect.Copy(out, v)
+			return out.Interface()
+		}
+		for i := range v.Len() {
+			out.Index(i).Set(copyElem(v.Index(i)))
+		}
+		return out.Interface()
+	case reflect.Map:
+		if v.IsNil() {
+			return root
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for it := v.MapRange(); it.Next(); {
+			out.SetMapIndex(it.Key(), copyElem(it.Value()))
+		}
+		return out.Interface()
 	}
 	return root
 }
+
+// basicKind reports whether values of kind k hold no references, and need
+// no copying of their own.
+func basicKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return true
+	}
+	return false
+}
+
+// isNil reports whether v is a nil pointer, map, slice, function, channel
+// or interface.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// copyElem copies an element of a slice or map, keeping it as it is when
+// its copy cannot be stored in its place.
+func copyElem(v reflect.Value) reflect.Value {
+	if basicKind(v.Kind()) || !v.CanInterface() || v.Kind() == reflect.Interface && v.IsNil() {
+		return v
+	}
+	c := reflect.ValueOf(deepCopy(v.Interface()))
+	if !c.IsValid() || !c.Type().AssignableTo(v.Type()) {
+		return v
+	}
+	return c
+}
@@ -2,10 +2,12 @@ package patch
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"reflect"
 	"testing"
+	"time"
 )
 
 type Spec struct {
@@ -113,6 +115,80 @@ func TestRemove(t *testing.T) {
 	})
 }
 
+func TestMoveCopyValues(t *testing.T) {
+	// values are moved and copied as they are, without going through JSON
+	type custom struct{ N int }
+	doc := map[string]interface{}{"a": custom{1}, "n": json.Number("1.50"), "list": []interface{}{int64(2)}}
+	result, err := Apply(doc, parseStr(`[
+		{"op": "move", "from": "/a", "path": "/b"},
+		{"op": "copy", "from": "/n", "path": "/m"},
+		{"op": "copy", "from": "/list", "path": "/list2"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"b": custom{1}, "n": json.Number("1.50"), "m": json.Number("1.50"),
+		"list": []interface{}{int64(2)}, "list2": []interface{}{int64(2)},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	// copies are not shared with their source
+	m := result.(map[string]interface{})
+	m["list2"].([]interface{})[0] = "changed"
+	if m["list"].([]interface{})[0] != int64(2) {
+		t.Error("the copy shares its source")
+	}
+}
+
+func TestCopyMissingFrom(t *testing.T) {
+	for _, from := range []string{"/missing", "/a/5", "/a/-", "/a/0/x"} {
+		_, err := Apply(decode(`{"a": [1]}`), parseStr(`[{"op": "copy", "from": "`+from+`", "path": "/b"}]`))
+		if err == nil {
+			t.Errorf("%s: expected an error", from)
+		}
+	}
+	_, err := Apply(decode(`{}`), parseStr(`[{"op": "copy", "from": "/missing", "path": "/b"}]`))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestArraySplice(t *testing.T) {
+	doc := decode(`[[1, 2, 3], {"a": [[4, 5]]}]`)
+	inner := doc.([]interface{})[0].([]interface{})
+	result, err := ApplyUnsafe(doc, parseStr(`[
+		{"op": "remove", "path": "/0/0"},
+		{"op": "add", "path": "/0/1", "value": 6},
+		{"op": "remove", "path": "/1/a/0/1"},
+		{"op": "add", "path": "/1/a/0/-", "value": 7},
+		{"op": "add", "path": "/1/a/0", "value": []},
+		{"op": "remove", "path": "/1/a/1/0"},
+		{"op": "add", "path": "/0", "value": 0}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := decode(`[0, [2, 6, 3], {"a": [[], [7]]}]`); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	// elements are inserted and removed in the array itself
+	if got := result.([]interface{})[1].([]interface{}); &got[0] != &inner[0] {
+		t.Error("the array was reallocated")
+	}
+	for _, p := range []string{
+		`[{"op": "remove", "path": "/0/3"}]`,
+		`[{"op": "remove", "path": "/0/-"}]`,
+		`[{"op": "remove", "path": "/0/01"}]`,
+		`[{"op": "add", "path": "/0/4", "value": 1}]`,
+	} {
+		if _, err := ApplyUnsafe(decode(`[[1, 2, 3]]`), parseStr(p)); err == nil {
+			t.Errorf("%s: expected an error", p)
+		}
+	}
+}
+
 func TestBasicSpec(t *testing.T) {
 	doSpecFile(t, "testdata/spec_tests.json")
 }
@@ -120,3 +196,87 @@ func TestBasicSpec(t *testing.T) {
 func TestEvenMore(t *testing.T) {
 	doSpecFile(t, "testdata/tests.json")
 }
+
+// stamp is a value put in a document programmatically, holding a reference
+// that deepCopy cannot copy without implementing Copier.
+type stamp struct{ at *time.Time }
+
+func (s stamp) DeepCopy() interface{} {
+	at := *s.at
+	return stamp{&at}
+}
+
+// counter is a Copier implemented on a pointer, which may be nil.
+type counter struct{ n int }
+
+func (c *counter) DeepCopy() interface{} {
+	return &counter{c.n}
+}
+
+func TestDeepCopy(t *testing.T) {
+	now := time.Now()
+	raw := json.RawMessage(`{"a":1}`)
+	tags := []string{"x", "y"}
+	counts := map[string][]int{"a": {1, 2}}
+	nested := []map[string]interface{}{{"a": []interface{}{1.0}}}
+	at := now
+	stamps := []stamp{{&at}}
+	counters := []*counter{{1}, nil}
+	doc := map[string]interface{}{
+		"raw":    raw,
+		"number": json.Number("1.50"),
+		"time":   now,
+		"tags":   tags,
+		"counts": counts,
+		"nested": nested,
+		"stamp":  stamp{&at},
+		"stamps": stamps,
+		// typed nil Copiers are kept as they are
+		"counter":  (*counter)(nil),
+		"counters": counters,
+	}
+	c := deepCopy(doc).(map[string]interface{})
+	if !reflect.DeepEqual(c, doc) {
+		t.Fatalf("copy differs: %v", c)
+	}
+	if c["number"] != json.Number("1.50") || c["time"] != now {
+		t.Errorf("expected immutable values to be kept: %v %v", c["number"], c["time"])
+	}
+
+	raw[0] = '['
+	tags[0] = "z"
+	counts["a"][0] = 3
+	nested[0]["a"].([]interface{})[0] = 2.0
+	*doc["stamp"].(stamp).at = now.Add(time.Hour)
+	if string(c["raw"].(json.RawMessage)) != `{"a":1}` {
+		t.Errorf("raw message is shared: %s", c["raw"])
+	}
+	if c["tags"].([]string)[0] != "x" {
+		t.Errorf("slice is shared: %v", c["tags"])
+	}
+	if c["counts"].(map[string][]int)["a"][0] != 1 {
+		t.Errorf("map is shared: %v", c["counts"])
+	}
+	if c["nested"].([]map[string]interface{})[0]["a"].([]interface{})[0] != 1.0 {
+		t.Errorf("nested value is shared: %v", c["nested"])
+	}
+	if !c["stamp"].(stamp).at.Equal(now) {
+		t.Errorf("Copier was not used: %v", c["stamp"].(stamp).at)
+	}
+	if !c["stamps"].([]stamp)[0].at.Equal(now) {
+		t.Errorf("Copier was not used for elements: %v", c["stamps"].([]stamp)[0].at)
+	}
+	counters[0].n = 2
+	if got := c["counters"].([]*counter); got[0].n != 1 || got[1] != nil {
+		t.Errorf("unexpected copy of pointers: %v", got)
+	}
+
+	result, err := Apply(doc, parseStr(`[{"op": "copy", "from": "/tags", "path": "/copied"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags[1] = "w"
+	if got := result.(map[string]interface{})["copied"].([]string); got[1] != "y" {
+		t.Errorf("copied value is shared with the original: %v", got)
+	}
+}
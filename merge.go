@@ -0,0 +1,194 @@
+package patch
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// MergePatch applies a JSON Merge Patch (RFC 7386) to a copy of doc and
+// returns the result. Members of patch that are null remove the member from
+// the document, objects are merged recursively, and any other value replaces
+// the target outright.
+func MergePatch(doc, patch interface{}) interface{} {
+	return mergeInto(deepCopy(doc), patch)
+}
+
+func mergeInto(doc, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return deepCopy(patch)
+	}
+	d, ok := doc.(map[string]interface{})
+	if !ok {
+		d = make(map[string]interface{}, len(p))
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(d, k)
+			continue
+		}
+		d[k] = mergeInto(d[k], v)
+	}
+	return d
+}
+
+// ToMergePatch converts operations to a JSON Merge Patch with the same
+// effect. Merge patches can only set and remove object members, so:
+//
+//   - test, move and copy operations cannot be converted;
+//   - paths must address object members: tokens that may be array indexes,
+//     including "-", are refused, since a merge patch replaces arrays whole;
+//   - values cannot be null, which a merge patch would read as a removal,
+//     nor objects holding null members;
+//   - objects can only be added, as members the document does not have
+//     yet: a merge patch would merge them into an existing object instead
+//     of replacing it, so replacing with an object, and adding one where
+//     an earlier operation already set, removed or modified the member,
+//     are refused;
+//   - a member is created along with its missing parents, where the
+//     operations would fail, and removing a missing member does nothing.
+//
+// When an operation cannot be converted, ToMergePatch returns an error
+// matching ErrInvalidPatch.
+func ToMergePatch(operations []Operation) ([]byte, error) {
+	var root interface{} = map[string]interface{}{}
+	for i, op := range operations {
+		var err error
+		if root, err = toMerge(root, op); err != nil {
+			return nil, &InvalidPatchError{Index: i, Op: op.Op, Err: err}
+		}
+	}
+	return marshal(root)
+}
+
+// toMerge adds op to the merge patch root and returns the updated patch.
+func toMerge(root interface{}, op Operation) (interface{}, error) {
+	var value interface{}
+	switch op.Op {
+	case "add", "replace":
+		if op.Value == nil {
+			return nil, fmt.Errorf("missing 'value' parameter")
+		}
+		if err := unmarshalNumber(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("invalid 'value' parameter: %v", err)
+		}
+		switch value.(type) {
+		case map[string]interface{}:
+			if op.Op == "replace" {
+				return nil, fmt.Errorf("an object value would be merged into the value it replaces")
+			}
+			if hasNullMember(value) {
+				return nil, fmt.Errorf("an object with null members cannot be expressed in a merge patch")
+			}
+		case nil:
+			return nil, fmt.Errorf("a null value cannot be expressed in a merge patch")
+		}
+	case "remove":
+	default:
+		return nil, fmt.Errorf("%s operations cannot be expressed in a merge patch", op.Op)
+	}
+	path, err := parsePath(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(path) == 0 {
+		if op.Op == "remove" {
+			return nil, fmt.Errorf("the whole document cannot be removed")
+		}
+		if _, ok := value.(map[string]interface{}); ok {
+			return nil, fmt.Errorf("an object value would be merged into the document")
+		}
+		return value, nil
+	}
+	for _, token := range path {
+		if isIndex(token) {
+			return nil, fmt.Errorf("array element %q cannot be addressed in a merge patch", token)
+		}
+	}
+	// objects on the path were either created here or added as values of
+	// members the document does not have, and can be written into; any
+	// other value was set by an earlier operation
+	parent, ok := root.(map[string]interface{})
+	for _, token := range path[:len(path)-1] {
+		if !ok {
+			break
+		}
+		child, exists := parent[token]
+		if !exists {
+			child = map[string]interface{}{}
+			parent[token] = child
+		}
+		parent, ok = child.(map[string]interface{})
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s is inside a value set by an earlier operation", op.Path)
+	}
+	key := path[len(path)-1]
+	if _, isObject := value.(map[string]interface{}); isObject {
+		if _, exists := parent[key]; exists {
+			return nil, fmt.Errorf("an object value would be merged into %s, which an earlier operation changed", op.Path)
+		}
+	}
+	parent[key] = value
+	return root, nil
+}
+
+// hasNullMember reports whether the object v, or an object member of it,
+// has a null member. Arrays are kept whole by merge patches, so the objects
+// they hold may have null members.
+func hasNullMember(v interface{}) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, e := range v {
+			if e == nil || hasNullMember(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FromMergePatch converts a JSON Merge Patch to operations: an add for each
+// member set and a remove for each member set to null, in key order. The
+// operations assume the objects the merge patch recurses into exist in the
+// document, and that the members it removes do, so they fail where the merge
+// patch would create a parent or do nothing.
+func FromMergePatch(patch []byte) ([]Operation, error) {
+	var p interface{}
+	if err := unmarshalNumber(patch, &p); err != nil {
+		return nil, err
+	}
+	ops := make([]Operation, 0)
+	if err := fromMerge(&ops, nil, p); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+func fromMerge(ops *[]Operation, path pointer.Pointer, patch interface{}) error {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		raw, err := marshal(patch)
+		if err != nil {
+			return err
+		}
+		*ops = append(*ops, Operation{Op: "add", Path: path.String(), Value: raw})
+		return nil
+	}
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		member := append(path[:len(path):len(path)], k)
+		if p[k] == nil {
+			*ops = append(*ops, Operation{Op: "remove", Path: member.String()})
+		} else if err := fromMerge(ops, member, p[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// examples from RFC 7386, appendix A
+func TestMergePatch(t *testing.T) {
+	cases := []struct{ doc, patch, expected string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, c := range cases {
+		doc := decode(c.doc)
+		got := MergePatch(doc, decode(c.patch))
+		if !reflect.DeepEqual(got, decode(c.expected)) {
+			t.Errorf("%s + %s: expected %s, got %v", c.doc, c.patch, c.expected, got)
+		}
+		if !reflect.DeepEqual(doc, decode(c.doc)) {
+			t.Errorf("%s + %s: document was modified", c.doc, c.patch)
+		}
+	}
+}
+
+func TestToMergePatch(t *testing.T) {
+	cases := []struct{ patch, expected string }{
+		{`[]`, `{}`},
+		{`[{"op": "add", "path": "/a", "value": 1}, {"op": "remove", "path": "/b"}]`, `{"a":1,"b":null}`},
+		{`[{"op": "replace", "path": "/a/b", "value": [1, null]}, {"op": "add", "path": "/a/c", "value": "x"}]`, `{"a":{"b":[1,null],"c":"x"}}`},
+		{`[{"op": "add", "path": "/a", "value": 1}, {"op": "remove", "path": "/a"}]`, `{"a":null}`},
+		{`[{"op": "add", "path": "/a/b", "value": 1}, {"op": "add", "path": "/a", "value": 2}]`, `{"a":2}`},
+		{`[{"op": "add", "path": "/n", "value": 12345678901234567890}]`, `{"n":12345678901234567890}`},
+		{`[{"op": "add", "path": "/a", "value": 1}, {"op": "replace", "path": "", "value": [2]}]`, `[2]`},
+		{`[{"op": "add", "path": "/a", "value": {"b": 1, "c": [{"d": null}]}}]`, `{"a":{"b":1,"c":[{"d":null}]}}`},
+		{`[{"op": "add", "path": "/a/b", "value": {"d": 1}}, {"op": "add", "path": "/a/b/c", "value": 1}, {"op": "remove", "path": "/a/b/d"}]`, `{"a":{"b":{"c":1,"d":null}}}`},
+	}
+	for _, c := range cases {
+		got, err := ToMergePatch(parseStr(c.patch))
+		if err != nil {
+			t.Errorf("%s: %v", c.patch, err)
+		} else if string(got) != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.patch, c.expected, got)
+		}
+	}
+
+	invalid := []string{
+		`[{"op": "test", "path": "/a", "value": 1}]`,
+		`[{"op": "move", "from": "/a", "path": "/b"}]`,
+		`[{"op": "copy", "from": "/a", "path": "/b"}]`,
+		`[{"op": "add", "path": "/a/0", "value": 1}]`,
+		`[{"op": "add", "path": "/a/-", "value": 1}]`,
+		`[{"op": "replace", "path": "/a", "value": {"b": 1}}]`,
+		`[{"op": "add", "path": "/a", "value": {"b": null}}]`,
+		`[{"op": "add", "path": "", "value": {"b": 1}}]`,
+		`[{"op": "remove", "path": "/a"}, {"op": "add", "path": "/a", "value": {"b": 1}}]`,
+		`[{"op": "add", "path": "/a/x", "value": 1}, {"op": "add", "path": "/a", "value": {"b": 1}}]`,
+		`[{"op": "replace", "path": "/a", "value": null}]`,
+		`[{"op": "add", "path": "/a", "value": 1}, {"op": "add", "path": "/a/b", "value": 1}]`,
+		`[{"op": "remove", "path": "/a"}, {"op": "add", "path": "/a/b", "value": 1}]`,
+		`[{"op": "remove", "path": ""}]`,
+	}
+	for _, p := range invalid {
+		if _, err := ToMergePatch(parseStr(p)); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%s: expected an invalid patch error, got %v", p, err)
+		}
+	}
+}
+
+func TestFromMergePatch(t *testing.T) {
+	cases := []struct{ patch, expected string }{
+		{`{}`, `[]`},
+		{`{"b": 1, "a": null}`, `[{"op":"remove","path":"/a"},{"op":"add","path":"/b","value":1}]`},
+		{`{"a": {"b": "x", "c": null}, "d": [null]}`, `[{"op":"add","path":"/a/b","value":"x"},{"op":"remove","path":"/a/c"},{"op":"add","path":"/d","value":[null]}]`},
+		{`{"a/b": {"~": 1}}`, `[{"op":"add","path":"/a~1b/~0","value":1}]`},
+		{`["x"]`, `[{"op":"add","path":"","value":["x"]}]`},
+	}
+	for _, c := range cases {
+		ops, err := FromMergePatch([]byte(c.patch))
+		if err != nil {
+			t.Errorf("%s: %v", c.patch, err)
+			continue
+		}
+		if !reflect.DeepEqual(ops, parseStr(c.expected)) {
+			t.Errorf("%s: expected %s, got %v", c.patch, c.expected, ops)
+		}
+	}
+
+	// the conversions agree with MergePatch where their assumptions hold
+	doc := decode(`{"a": {"b": 1, "c": 2}, "d": "x"}`)
+	patch := `{"a": {"b": null, "e": [1]}, "d": 3, "f": true}`
+	ops, err := FromMergePatch([]byte(patch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := Apply(doc, ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := MergePatch(doc, decode(patch))
+	if !reflect.DeepEqual(applied, merged) {
+		t.Errorf("expected %v, got %v", merged, applied)
+	}
+	back, err := ToMergePatch(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(MergePatch(doc, decode(string(back))), merged) {
+		t.Errorf("round trip through %s changed the result", back)
+	}
+}
@@ -0,0 +1,87 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// ErrAmbiguousMove matches errors for moves rejected by MoveRejectAmbiguous.
+var ErrAmbiguousMove = errors.New("ambiguous move")
+
+// MoveIndexMode selects how the destination index of a move within a single
+// array is interpreted.
+//
+// RFC 6902 defines move as a remove followed by an add, so the destination
+// index refers to the array after the element has been taken out: moving
+// /list/0 to /list/2 in [a, b, c, d] gives [b, c, a, d]. Some producers
+// instead compute the destination against the array before the move, meaning
+// "insert before the element currently at index 2", which gives [b, a, c, d].
+// The two readings only differ when the element moves towards the end of the
+// array, and applying a patch with the wrong one silently puts the element
+// one position too far. Inverse patches always use the RFC 6902 reading,
+// whatever the mode used to apply the patch.
+type MoveIndexMode int
+
+const (
+	// MoveAfterRemove interprets the destination index against the array
+	// with the moved element removed, as RFC 6902 specifies.
+	MoveAfterRemove MoveIndexMode = iota
+	// MoveBeforeRemove interprets the destination index against the array
+	// as it was before the move. The index may then be equal to the length
+	// of the array to move the element to the end.
+	MoveBeforeRemove
+	// MoveRejectAmbiguous fails moves whose result depends on the
+	// interpretation, that is moves towards a higher index of the same
+	// array, with an error matching ErrAmbiguousMove. Moves to "-" and
+	// towards a lower index are carried out.
+	MoveRejectAmbiguous
+)
+
+// resolveMove returns ins with its destination adjusted to the RFC 6902
+// reading of the index, according to the applier's MoveIndexMode.
+func (a *applier) resolveMove(root interface{}, ins *instruction) (*instruction, error) {
+	from, to, ok := sameArrayMove(root, ins.from, ins.path)
+	if !ok || from >= to {
+		return ins, nil
+	}
+	switch a.opts.MoveIndex {
+	case MoveBeforeRemove:
+		adjusted := *ins
+		adjusted.path = append(pointer.Pointer{}, ins.path...)
+		adjusted.path[len(adjusted.path)-1] = strconv.Itoa(to - 1)
+		return &adjusted, nil
+	case MoveRejectAmbiguous:
+		return nil, fmt.Errorf("moving element %d to %d of the same array: %w", from, to, ErrAmbiguousMove)
+	}
+	return ins, nil
+}
+
+// sameArrayMove reports whether from and to address numbered elements of the
+// same array in root, and returns their indexes.
+func sameArrayMove(root interface{}, from, to []string) (int, int, bool) {
+	n := len(to)
+	if n == 0 || len(from) != n || !slices.Equal(from[:n-1], to[:n-1]) {
+		return 0, 0, false
+	}
+	parent, err := pointer.Pointer(to[:n-1]).Get(root)
+	if err != nil {
+		return 0, 0, false
+	}
+	s, ok := parent.([]interface{})
+	if !ok {
+		return 0, 0, false
+	}
+	i, err := pointer.ParseIndex(from[n-1], len(s)-1, false)
+	if err != nil {
+		return 0, 0, false
+	}
+	j, err := pointer.ParseIndex(to[n-1], len(s), false)
+	if err != nil {
+		return 0, 0, false
+	}
+	return i, j, true
+}
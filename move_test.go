@@ -0,0 +1,89 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMoveIndex(t *testing.T) {
+	doc := `{"list": ["a", "b", "c", "d"], "obj": {"0": "x"}}`
+	for _, tc := range []struct {
+		mode     MoveIndexMode
+		from, to string
+		expected string // the resulting list, or "" for an error
+	}{
+		{MoveAfterRemove, "/list/0", "/list/2", `["b", "c", "a", "d"]`},
+		{MoveBeforeRemove, "/list/0", "/list/2", `["b", "a", "c", "d"]`},
+		{MoveRejectAmbiguous, "/list/0", "/list/2", ""},
+
+		{MoveAfterRemove, "/list/0", "/list/4", ""},
+		{MoveBeforeRemove, "/list/0", "/list/4", `["b", "c", "d", "a"]`},
+
+		{MoveAfterRemove, "/list/3", "/list/1", `["a", "d", "b", "c"]`},
+		{MoveBeforeRemove, "/list/3", "/list/1", `["a", "d", "b", "c"]`},
+		{MoveRejectAmbiguous, "/list/3", "/list/1", `["a", "d", "b", "c"]`},
+
+		{MoveBeforeRemove, "/list/1", "/list/-", `["a", "c", "d", "b"]`},
+		{MoveRejectAmbiguous, "/list/1", "/list/-", `["a", "c", "d", "b"]`},
+		{MoveBeforeRemove, "/list/1", "/list/1", `["a", "b", "c", "d"]`},
+	} {
+		ops := []Operation{{Op: "move", From: tc.from, Path: tc.to}}
+		result, _, err := ApplyWithReport(decode(doc), ops, WithMoveIndex(tc.mode))
+		if tc.expected == "" {
+			if err == nil {
+				t.Errorf("mode %d, %s to %s: expected an error", tc.mode, tc.from, tc.to)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("mode %d, %s to %s: %v", tc.mode, tc.from, tc.to, err)
+			continue
+		}
+		list := result.(map[string]interface{})["list"]
+		if expected := decode(tc.expected); !reflect.DeepEqual(list, expected) {
+			t.Errorf("mode %d, %s to %s: expected %v, got %v", tc.mode, tc.from, tc.to, expected, list)
+		}
+	}
+
+	_, _, err := ApplyWithReport(decode(doc), []Operation{{Op: "move", From: "/list/0", Path: "/list/3"}}, WithMoveIndex(MoveRejectAmbiguous))
+	if !errors.Is(err, ErrAmbiguousMove) {
+		t.Errorf("expected ErrAmbiguousMove, got %v", err)
+	}
+
+	// object members that look like indexes are not adjusted
+	result, _, err := ApplyWithReport(decode(doc), []Operation{{Op: "move", From: "/obj/0", Path: "/obj/1"}}, WithMoveIndex(MoveBeforeRemove))
+	if err != nil || !reflect.DeepEqual(result.(map[string]interface{})["obj"], decode(`{"1": "x"}`)) {
+		t.Errorf("object move: %v %v", result, err)
+	}
+}
+
+func TestMovePathAfterRemove(t *testing.T) {
+	// the path is resolved once the moved element is removed, where /2
+	// is the object rather than the string
+	doc := decode(`[{"i": 0}, {"i": 1}, "new", {"i": 2}]`)
+	ops := parseStr(`[{"op": "move", "from": "/0", "path": "/2/x"}]`)
+	expected := decode(`[{"i": 1}, "new", {"i": 2, "x": {"i": 0}}]`)
+	for _, opts := range [][]Option{nil, {WithContinueOnError()}} {
+		result, err := Apply(doc, ops, opts...)
+		if err != nil || !reflect.DeepEqual(result, expected) {
+			t.Errorf("expected %v, got %v, %v", expected, result, err)
+		}
+	}
+	if !reflect.DeepEqual(doc, decode(`[{"i": 0}, {"i": 1}, "new", {"i": 2}]`)) {
+		t.Errorf("the document was modified: %v", doc)
+	}
+}
+
+func TestMoveIndexInverse(t *testing.T) {
+	doc := decode(`{"list": ["a", "b", "c", "d"]}`)
+	a := &applier{opts: &Options{MoveIndex: MoveBeforeRemove}, undo: &undoLog{}}
+	result, err := a.apply(deepCopy(doc), []Operation{{Op: "move", From: "/list/0", Path: "/list/4"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// inverse patches always follow RFC 6902
+	if restored, err := Apply(result, a.undo.ops()); err != nil || !reflect.DeepEqual(restored, doc) {
+		t.Errorf("expected %v, got %v %v", doc, restored, err)
+	}
+}
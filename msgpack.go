@@ -0,0 +1,350 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ApplyMsgpack applies operations to a MessagePack encoded document and
+// returns the MessagePack encoded result. The document must hold the JSON
+// data model: maps with string keys, arrays, strings, numbers, booleans and
+// nil; binary and extension values are rejected. Integers keep their exact
+// value and floating point numbers stay floating point, but integers beyond
+// the 64 bit range a patch may add cannot be encoded.
+//
+// The result uses the shortest encoding of every value, with floats in the
+// shortest of 32 or 64 bits that keeps their value, and map keys sorted.
+func ApplyMsgpack(doc []byte, operations []Operation, opts ...Option) ([]byte, error) {
+	return applyFormat(msgpackFormat{}, doc, operations, opts)
+}
+
+type msgpackFormat struct{}
+
+func (msgpackFormat) decode(data []byte) (interface{}, error) {
+	d := &msgpackDecoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: %w at offset %d", err, d.pos)
+	}
+	if d.pos != len(data) {
+		return nil, fmt.Errorf("msgpack: extra data at offset %d", d.pos)
+	}
+	return v, nil
+}
+
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+// uint reads a big endian unsigned integer of n bytes.
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	if len(d.data)-d.pos < n {
+		return 0, errTruncated
+	}
+	var u uint64
+	for _, c := range d.data[d.pos : d.pos+n] {
+		u = u<<8 | uint64(c)
+	}
+	d.pos += n
+	return u, nil
+}
+
+// length reads a length of n bytes, and checks that that many items of at
+// least one byte each can follow.
+func (d *msgpackDecoder) length(n int) (int, error) {
+	l, err := d.uint(n)
+	if err != nil {
+		return 0, err
+	}
+	return d.fits(l)
+}
+
+func (d *msgpackDecoder) fits(l uint64) (int, error) {
+	if l > uint64(len(d.data)-d.pos) {
+		return 0, errTruncated
+	}
+	return int(l), nil
+}
+
+func (d *msgpackDecoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New("exceeded max depth")
+	}
+	if d.pos >= len(d.data) {
+		return nil, errTruncated
+	}
+	start := d.pos
+	b := d.data[d.pos]
+	d.pos++
+	switch {
+	case b <= 0x7f:
+		return json.Number(strconv.Itoa(int(b))), nil
+	case b >= 0xe0:
+		return json.Number(strconv.Itoa(int(int8(b)))), nil
+	case b&0xe0 == 0xa0:
+		return d.str(int(b & 0x1f))
+	case b&0xf0 == 0x90:
+		return d.array(int(b&0x0f), depth)
+	case b&0xf0 == 0x80:
+		return d.object(int(b&0x0f), depth)
+	}
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (b - 0xcc))
+		return json.Number(strconv.FormatUint(u, 10)), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (b - 0xd0)
+		u, err := d.uint(n)
+		// sign extend
+		i := int64(u<<(64-8*n)) >> (64 - 8*n)
+		return json.Number(strconv.FormatInt(i, 10)), err
+	case 0xca:
+		u, err := d.uint(4)
+		return floatNumber(float64(math.Float32frombits(uint32(u)))), err
+	case 0xcb:
+		u, err := d.uint(8)
+		return floatNumber(math.Float64frombits(u)), err
+	case 0xd9, 0xda, 0xdb:
+		l, err := d.length(1 << (b - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(l)
+	case 0xdc, 0xdd:
+		l, err := d.length(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(l, depth)
+	case 0xde, 0xdf:
+		l, err := d.length(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.object(l, depth)
+	case 0xc4, 0xc5, 0xc6:
+		d.pos = start
+		return nil, errors.New("binary values have no JSON equivalent")
+	}
+	d.pos = start
+	if b == 0xc1 {
+		return nil, errors.New("invalid byte 0xc1")
+	}
+	return nil, errors.New("extension values have no JSON equivalent")
+}
+
+func (d *msgpackDecoder) str(n int) (string, error) {
+	l, err := d.fits(uint64(n))
+	if err != nil {
+		return "", err
+	}
+	b := d.data[d.pos : d.pos+l]
+	if !utf8.Valid(b) {
+		return "", errors.New("invalid UTF-8 in string")
+	}
+	d.pos += l
+	return string(b), nil
+}
+
+func (d *msgpackDecoder) array(n, depth int) (interface{}, error) {
+	if _, err := d.fits(uint64(n)); err != nil {
+		return nil, err
+	}
+	s := make([]interface{}, n)
+	for i := range s {
+		var err error
+		if s[i], err = d.value(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (d *msgpackDecoder) object(n, depth int) (interface{}, error) {
+	if _, err := d.fits(uint64(n)); err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		keyAt := d.pos
+		k, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			d.pos = keyAt
+			return nil, errors.New("map keys must be strings")
+		}
+		if m[key], err = d.value(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (msgpackFormat) encode(v interface{}) ([]byte, error) {
+	var e msgpackEncoder
+	if err := e.value(v); err != nil {
+		return nil, fmt.Errorf("msgpack: %w", err)
+	}
+	return e.Bytes(), nil
+}
+
+type msgpackEncoder struct{ bytes.Buffer }
+
+// head writes the marker of a string, array or map of length n: fix is the
+// marker of the fixed length form, whose length fits in bits bits, and wide
+// the markers of the forms with lengths of 1, 2 and 4 bytes.
+func (e *msgpackEncoder) head(n int, fix byte, bits int, wide [3]byte) error {
+	switch {
+	case n < 1<<bits:
+		e.WriteByte(fix | byte(n))
+	case n <= math.MaxUint8 && wide[0] != 0:
+		e.Write([]byte{wide[0], byte(n)})
+	case n <= math.MaxUint16:
+		e.WriteByte(wide[1])
+		e.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case uint64(n) <= math.MaxUint32:
+		e.WriteByte(wide[2])
+		e.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		return fmt.Errorf("length %d too large", n)
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) value(v interface{}) error {
+	v, err := normalize(v)
+	if err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case nil:
+		e.WriteByte(0xc0)
+	case bool:
+		if v {
+			e.WriteByte(0xc3)
+		} else {
+			e.WriteByte(0xc2)
+		}
+	case string:
+		return e.str(v)
+	case float64:
+		e.float(v)
+	case json.Number:
+		return e.number(v)
+	case []interface{}:
+		if err := e.head(len(v), 0x90, 4, [3]byte{0, 0xdc, 0xdd}); err != nil {
+			return err
+		}
+		for _, x := range v {
+			if err := e.value(x); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if err := e.head(len(v), 0x80, 4, [3]byte{0, 0xde, 0xdf}); err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := e.str(k); err != nil {
+				return err
+			}
+			if err := e.value(v[k]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) str(s string) error {
+	if err := e.head(len(s), 0xa0, 5, [3]byte{0xd9, 0xda, 0xdb}); err != nil {
+		return err
+	}
+	e.WriteString(s)
+	return nil
+}
+
+func (e *msgpackEncoder) number(n json.Number) error {
+	s := n.String()
+	if strings.ContainsAny(s, ".eE") {
+		f, err := n.Float64()
+		if err != nil {
+			return err
+		}
+		e.float(f)
+		return nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		e.int(i)
+		return nil
+	}
+	u, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("integer %s out of range", s)
+	}
+	e.WriteByte(0xcf)
+	e.Write(binary.BigEndian.AppendUint64(nil, u))
+	return nil
+}
+
+func (e *msgpackEncoder) int(i int64) {
+	switch {
+	case i >= 0 && i <= math.MaxInt8, i < 0 && i >= -32:
+		e.WriteByte(byte(i))
+	case i > 0 && i <= math.MaxUint8:
+		e.Write([]byte{0xcc, byte(i)})
+	case i > 0 && i <= math.MaxUint16:
+		e.WriteByte(0xcd)
+		e.Write(binary.BigEndian.AppendUint16(nil, uint16(i)))
+	case i > 0 && i <= math.MaxUint32:
+		e.WriteByte(0xce)
+		e.Write(binary.BigEndian.AppendUint32(nil, uint32(i)))
+	case i > 0:
+		e.WriteByte(0xcf)
+		e.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+	case i >= math.MinInt8:
+		e.Write([]byte{0xd0, byte(i)})
+	case i >= math.MinInt16:
+		e.WriteByte(0xd1)
+		e.Write(binary.BigEndian.AppendUint16(nil, uint16(i)))
+	case i >= math.MinInt32:
+		e.WriteByte(0xd2)
+		e.Write(binary.BigEndian.AppendUint32(nil, uint32(i)))
+	default:
+		e.WriteByte(0xd3)
+		e.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+	}
+}
+
+func (e *msgpackEncoder) float(f float64) {
+	if f32 := float32(f); float64(f32) == f {
+		e.WriteByte(0xca)
+		e.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(f32)))
+		return
+	}
+	e.WriteByte(0xcb)
+	e.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+}
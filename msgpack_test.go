@@ -0,0 +1,99 @@
+package patch
+
+import "testing"
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	cases := []struct{ json, msgpack string }{
+		{`0`, "00"},
+		{`127`, "7f"},
+		{`128`, "cc 80"},
+		{`65536`, "ce 00010000"},
+		{`18446744073709551615`, "cf ffffffffffffffff"},
+		{`-1`, "ff"},
+		{`-32`, "e0"},
+		{`-33`, "d0 df"},
+		{`-40000`, "d2 ffff63c0"},
+		{`1.5`, "ca 3fc00000"},
+		{`1.1`, "cb 3ff199999999999a"},
+		{`null`, "c0"},
+		{`[true, false]`, "92 c3 c2"},
+		{`""`, "a0"},
+		{`{"b": 1, "a": [2]}`, "82 a1 61 91 02 a1 62 01"},
+	}
+	for _, c := range cases {
+		var v interface{}
+		if err := unmarshalNumber([]byte(c.json), &v); err != nil {
+			t.Fatal(err)
+		}
+		b, err := msgpackFormat{}.encode(v)
+		if err != nil {
+			t.Errorf("%s: %v", c.json, err)
+			continue
+		}
+		if expected := unhex(t, c.msgpack); string(b) != string(expected) {
+			t.Errorf("%s: expected %x, got %x", c.json, expected, b)
+		}
+		back, err := msgpackFormat{}.decode(b)
+		if err != nil {
+			t.Errorf("%s: %v", c.json, err)
+			continue
+		}
+		if !jsonEqual(back, v) {
+			t.Errorf("%s: decoded %#v", c.json, back)
+		}
+	}
+
+	// wider forms than necessary decode too
+	for hexData, expected := range map[string]string{
+		"d9 01 61":            `"a"`,
+		"dc 0001 01":          `[1]`,
+		"de 0001 a1 61 c0":    `{"a":null}`,
+		"d3 ffffffffffffffff": `-1`,
+		"cb 3ff0000000000000": `1.0`,
+	} {
+		v, err := msgpackFormat{}.decode(unhex(t, hexData))
+		if err != nil {
+			t.Errorf("%s: %v", hexData, err)
+			continue
+		}
+		if b, _ := marshal(v); string(b) != expected {
+			t.Errorf("%s: expected %s, got %s", hexData, expected, b)
+		}
+	}
+
+	for _, bad := range []string{"", "c1", "c4 01 00", "d4 01 00", "a2 61", "81 01 02", "dd ffffffff", "00 00"} {
+		if _, err := (msgpackFormat{}).decode(unhex(t, bad)); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+	if _, err := (msgpackFormat{}).encode(mustDecode(t, `18446744073709551616`)); err == nil {
+		t.Error("expected an integer beyond 64 bits to be rejected")
+	}
+}
+
+func mustDecode(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := unmarshalNumber([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestApplyMsgpack(t *testing.T) {
+	// {"a": 1, "b": [1.5]}
+	doc := unhex(t, "82 a1 61 01 a1 62 91 ca 3fc00000")
+	result, err := ApplyMsgpack(doc, parseStr(`[
+		{"op": "move", "from": "/b/0", "path": "/c"},
+		{"op": "replace", "path": "/a", "value": -200}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := unhex(t, "83 a1 61 d1 ff38 a1 62 90 a1 63 ca 3fc00000"); string(result) != string(expected) {
+		t.Errorf("expected %x, got %x", expected, result)
+	}
+	if _, err := ApplyMsgpack(doc, parseStr(`[{"op": "remove", "path": "/z"}]`)); err == nil {
+		t.Error("expected the patch to fail")
+	}
+}
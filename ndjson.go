@@ -0,0 +1,72 @@
+package patch
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// LineError reports a record of a JSON Lines stream that could not be
+// decoded or patched.
+type LineError struct {
+	Line int // 1-based line number
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error { return e.Err }
+
+// ApplyNDJSON patches a stream of JSON Lines (newline delimited JSON)
+// records. It reads records from r one at a time, calls selector with each
+// one to get the operations to apply to it, and writes the patched record to
+// w on a line of its own. Records for which selector returns no operations
+// are copied unchanged, and blank lines are dropped.
+//
+// Numbers are decoded as json.Number, so they are written out exactly as
+// they were read. opts are passed to Apply. ApplyNDJSON stops at the first
+// record that fails, returning a *LineError.
+func ApplyNDJSON(r io.Reader, w io.Writer, selector func(doc interface{}) []Operation, opts ...Option) error {
+	opts = append([]Option{WithUseNumber()}, opts...)
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if err := patchLine(bw, bytes.TrimSpace(line), selector, opts); err != nil {
+				return &LineError{Line: n, Err: err}
+			}
+		}
+		if err == io.EOF {
+			return bw.Flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func patchLine(w *bufio.Writer, line []byte, selector func(doc interface{}) []Operation, opts []Option) error {
+	if len(line) == 0 {
+		return nil
+	}
+	var doc interface{}
+	if err := unmarshalNumber(line, &doc); err != nil {
+		return err
+	}
+	if ops := selector(doc); len(ops) > 0 {
+		// the record is not used elsewhere, so it can be patched in place
+		result, err := ApplyUnsafe(doc, ops, opts...)
+		if err != nil {
+			return err
+		}
+		if line, err = marshal(result); err != nil {
+			return err
+		}
+	}
+	w.Write(line)
+	return w.WriteByte('\n')
+}
@@ -0,0 +1,54 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestApplyNDJSON(t *testing.T) {
+	in := `{"id": 1, "status": "new", "amount": 12345678901234567890}
+{"id": 2, "status": "done"}
+
+{"id":3,"status":"new"}`
+	setDone := parseStr(`[{"op": "replace", "path": "/status", "value": "done"}, {"op": "add", "path": "/tag", "value": "<x>"}]`)
+	var out bytes.Buffer
+	err := ApplyNDJSON(strings.NewReader(in), &out, func(doc interface{}) []Operation {
+		if doc.(map[string]interface{})["status"] == "new" {
+			return setDone
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"amount":12345678901234567890,"id":1,"status":"done","tag":"<x>"}
+{"id": 2, "status": "done"}
+{"id":3,"status":"done","tag":"<x>"}
+`
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+
+	in = "{\"id\": 1}\r\n{\"id\": 2}\n"
+	out.Reset()
+	remove := parseStr(`[{"op": "remove", "path": "/missing"}]`)
+	err = ApplyNDJSON(strings.NewReader(in), &out, func(doc interface{}) []Operation {
+		if doc.(map[string]interface{})["id"] == json.Number("2") {
+			return remove
+		}
+		return nil
+	})
+	var le *LineError
+	if !errors.As(err, &le) || le.Line != 2 || !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an error on line 2, got %v", err)
+	}
+
+	out.Reset()
+	err = ApplyNDJSON(strings.NewReader("{}\n{\n"), &out, func(interface{}) []Operation { return nil })
+	if !errors.As(err, &le) || le.Line != 2 {
+		t.Errorf("expected a syntax error on line 2, got %v", err)
+	}
+}
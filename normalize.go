@@ -0,0 +1,224 @@
+package patch
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// Normalize returns a canonical form of operations with the same effect on
+// every document, so that patches meaning the same thing encode to the same
+// bytes and can be hashed for deduplication or as idempotency keys:
+//
+//   - pointers are re-encoded, escaping "~" and "/" as RFC 6901 requires
+//     and nothing else;
+//   - values are re-encoded as canonical JSON: without insignificant
+//     whitespace, with object members sorted by name, strings escaped as by
+//     encoding/json without HTML escaping, and numbers in their shortest
+//     exact decimal form, so that 1.0, 1e0 and 1 are all 1;
+//   - members an operator does not use, such as the value of a remove or
+//     the from of an add, are dropped;
+//   - a replace writing the value an earlier operation added, replaced or
+//     tested at the same object member, with no operation changing it in
+//     between, is dropped;
+//   - operations that commute are sorted by their encoding. Operations
+//     commute when neither writes a part of the document the other reads
+//     or writes; operations within the same array never do, since adding or
+//     removing an element moves the others, and custom operators commute
+//     with nothing.
+//
+// Reordering may change which operation a failing patch reports. Pointers
+// are interpreted without the document, so a number or "-" token is taken
+// to address an array element. Malformed patches are returned unchanged.
+func Normalize(operations []Operation) []Operation {
+	type normalized struct {
+		op            Operation
+		key           string
+		writes, reads [][]string
+		barrier       bool
+	}
+	var ops []*normalized
+	known := make(map[string]string) // canonical values at object members
+	for _, op := range operations {
+		n := &normalized{op: Operation{Op: op.Op}}
+		path, err := parsePath(op.Path)
+		if err != nil {
+			return operations
+		}
+		n.op.Path = pointer.Pointer(path).String()
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return operations
+			}
+			if n.op.Value, err = canonicalJSON(op.Value); err != nil {
+				return operations
+			}
+		case "remove":
+		case "move", "copy":
+			from, err := parsePath(op.From)
+			if err != nil || op.From == "" {
+				return operations
+			}
+			n.op.From = pointer.Pointer(from).String()
+		default:
+			n.op, n.barrier = op, true
+			if op.Value != nil {
+				if v, err := canonicalJSON(op.Value); err == nil {
+					n.op.Value = v
+				}
+			}
+		}
+
+		scope := scopeOf(n.op.Path)
+		member := len(scope) == len(path)
+		if n.op.Op == "replace" && member && known[n.op.Path] == string(n.op.Value) {
+			continue
+		}
+		switch n.op.Op {
+		case "add", "replace", "remove":
+			n.writes = [][]string{scope}
+		case "move":
+			n.writes = [][]string{scope, scopeOf(n.op.From)}
+		case "copy":
+			n.writes, n.reads = [][]string{scope}, [][]string{scopeOf(n.op.From)}
+		case "test":
+			n.reads = [][]string{scope}
+		}
+		for p := range known {
+			if n.barrier || scopesOverlap(n.writes, [][]string{scopeOf(p)}) {
+				delete(known, p)
+			}
+		}
+		if member && (n.op.Op == "add" || n.op.Op == "replace" || n.op.Op == "test") {
+			known[n.op.Path] = string(n.op.Value)
+		}
+		key, err := marshal(n.op)
+		if err != nil {
+			return operations
+		}
+		n.key = string(key)
+		ops = append(ops, n)
+	}
+
+	// order the operations by their dependencies, taking the smallest
+	// operation among those whose dependencies are satisfied
+	conflict := func(a, b *normalized) bool {
+		return a.barrier || b.barrier ||
+			scopesOverlap(a.writes, append(b.writes, b.reads...)) ||
+			scopesOverlap(b.writes, append(a.writes, a.reads...))
+	}
+	pending := make([]int, len(ops)) // number of unplaced dependencies
+	for j := range ops {
+		for i := 0; i < j; i++ {
+			if conflict(ops[i], ops[j]) {
+				pending[j]++
+			}
+		}
+	}
+	out := make([]Operation, 0, len(ops))
+	placed := make([]bool, len(ops))
+	for range ops {
+		next := -1
+		for j, n := range ops {
+			if !placed[j] && pending[j] == 0 && (next < 0 || n.key < ops[next].key) {
+				next = j
+			}
+		}
+		placed[next] = true
+		out = append(out, ops[next].op)
+		for j := next + 1; j < len(ops); j++ {
+			if !placed[j] && conflict(ops[next], ops[j]) {
+				pending[j]--
+			}
+		}
+	}
+	return out
+}
+
+// canonicalJSON re-encodes the JSON text data in the canonical form
+// described by Normalize.
+func canonicalJSON(data []byte) (json.RawMessage, error) {
+	var v interface{}
+	if err := unmarshalNumber(data, &v); err != nil {
+		return nil, err
+	}
+	return marshal(canonicalNumbers(v))
+}
+
+// canonicalNumbers replaces the numbers of v, decoded with UseNumber, with
+// their canonical form, in place.
+func canonicalNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, x := range v {
+			v[k] = canonicalNumbers(x)
+		}
+	case []interface{}:
+		for i, x := range v {
+			v[i] = canonicalNumbers(x)
+		}
+	case json.Number:
+		return canonicalNumber(v)
+	}
+	return v
+}
+
+// canonicalNumber returns the shortest exact decimal form of n: without
+// an exponent for magnitudes from 1e-6 to below 1e21, and otherwise with
+// one digit before the decimal point, as ECMAScript formats numbers.
+// Numbers with absurd exponents are returned unchanged.
+func canonicalNumber(n json.Number) json.Number {
+	s := string(n)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	mantissa, exp := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil || e > 1e6 || e < -1e6 {
+			return n
+		}
+		mantissa, exp = s[:i], e
+	}
+	digits := mantissa
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		digits = mantissa[:i] + mantissa[i+1:]
+		exp -= len(mantissa) - i - 1
+	}
+	digits = strings.TrimLeft(digits, "0")
+	if digits == "" {
+		return "0"
+	}
+	trimmed := strings.TrimRight(digits, "0")
+	exp += len(digits) - len(trimmed)
+	digits = trimmed
+
+	// the value is digits × 10^exp, with the decimal point after point
+	// digits
+	point := len(digits) + exp
+	var out string
+	switch {
+	case exp >= 0 && point <= 21:
+		out = digits + strings.Repeat("0", exp)
+	case exp < 0 && point > 0:
+		out = digits[:point] + "." + digits[point:]
+	case exp < 0 && point > -6:
+		out = "0." + strings.Repeat("0", -point) + digits
+	default:
+		out = digits[:1]
+		if len(digits) > 1 {
+			out += "." + digits[1:]
+		}
+		if point > 0 {
+			out += "e+" + strconv.Itoa(point-1)
+		} else {
+			out += "e" + strconv.Itoa(point-1)
+		}
+	}
+	if neg {
+		out = "-" + out
+	}
+	return json.Number(out)
+}
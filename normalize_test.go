@@ -0,0 +1,73 @@
+package patch
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		patch, expected string
+	}{
+		{
+			`[{"op": "add", "path": "/a", "value": {"z": 1.0, "a": [1e2, -0.0, 12.50]}, "from": "/x"}]`,
+			`[{"op":"add","path":"/a","value":{"a":[100,0,12.5],"z":1}}]`,
+		},
+		{
+			// commuting operations are sorted, others keep their order
+			`[{"op": "replace", "path": "/b", "value": 1}, {"op": "remove", "path": "/a"}, {"op": "test", "path": "/b", "value": 1}, {"op": "add", "path": "/c", "value": 1}]`,
+			`[{"op":"add","path":"/c","value":1},{"op":"remove","path":"/a"},{"op":"replace","path":"/b","value":1},{"op":"test","path":"/b","value":1}]`,
+		},
+		{
+			`[{"op": "add", "path": "/list/0", "value": 1}, {"op": "add", "path": "/list/-", "value": 0}]`,
+			`[{"op":"add","path":"/list/0","value":1},{"op":"add","path":"/list/-","value":0}]`,
+		},
+		{
+			// no-op replaces are dropped
+			`[{"op": "test", "path": "/a", "value": {"x": 1}}, {"op": "replace", "path": "/a", "value": {"x": 1.0}}, {"op": "add", "path": "/b", "value": 2}, {"op": "replace", "path": "/b", "value": 2}]`,
+			`[{"op":"add","path":"/b","value":2},{"op":"test","path":"/a","value":{"x":1}}]`,
+		},
+		{
+			`[{"op": "replace", "path": "/a", "value": 1}, {"op": "remove", "path": "/a"}, {"op": "add", "path": "/a", "value": 1}, {"op": "replace", "path": "/a", "value": 1}]`,
+			`[{"op":"replace","path":"/a","value":1},{"op":"remove","path":"/a"},{"op":"add","path":"/a","value":1}]`,
+		},
+		{
+			`[{"op": "move", "from": "/a/b", "path": "/c"}, {"op": "copy", "from": "/d", "path": "/a"}]`,
+			`[{"op":"move","path":"/c","from":"/a/b"},{"op":"copy","path":"/a","from":"/d"}]`,
+		},
+	}
+	for _, c := range cases {
+		out, err := json.Marshal(Normalize(parseStr(c.patch)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != c.expected {
+			t.Errorf("%s:\nexpected %s\ngot      %s", c.patch, c.expected, out)
+		}
+	}
+
+	// equivalent patches normalize to the same bytes
+	a, _ := json.Marshal(Normalize(parseStr(`[{"op": "add", "path": "/x", "value": 1}, {"op": "add", "path": "/y", "value": {"b": 2, "a": 1}}]`)))
+	b, _ := json.Marshal(Normalize(parseStr(`[{"op": "add", "path": "/y", "value": {"a": 1.00, "b": 2}}, {"op": "add", "path": "/x", "value": 10e-1}]`)))
+	if string(a) != string(b) {
+		t.Errorf("expected the same normal form, got %s and %s", a, b)
+	}
+
+	malformed := parseStr(`[{"op": "add", "path": "x", "value": 1}]`)
+	if out := Normalize(malformed); len(out) != 1 || out[0].Path != "x" {
+		t.Errorf("expected a malformed patch to be returned unchanged, got %v", out)
+	}
+}
+
+func TestCanonicalNumber(t *testing.T) {
+	for in, expected := range map[string]string{
+		"0": "0", "-0": "0", "0.000": "0", "1.0": "1", "100": "100", "1e2": "100", "1.5E+3": "1500",
+		"-12.50": "-12.5", "0.001": "0.001", "1e-6": "0.000001", "1e-7": "1e-7", "123e-10": "1.23e-8",
+		"1e20": "100000000000000000000", "1e21": "1e+21", "12345678901234567890123": "1.2345678901234567890123e+22",
+		"1e99999999": "1e99999999",
+	} {
+		if got := canonicalNumber(json.Number(in)); string(got) != expected {
+			t.Errorf("%s: expected %s, got %s", in, expected, got)
+		}
+	}
+}
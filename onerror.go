@@ -0,0 +1,57 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// WithOnErrorHints honours the "onError" hints of operations. See
+// Options.OnErrorHints.
+func WithOnErrorHints() Option {
+	return func(o *Options) { o.OnErrorHints = true }
+}
+
+// checkHint checks the "onError" hint of op and its "default" member.
+func checkHint(op Operation) error {
+	switch op.OnError {
+	case "", "skip", "abort":
+	case "default":
+		if op.Default == nil {
+			return fmt.Errorf("missing 'default' parameter")
+		}
+		if !json.Valid(op.Default) {
+			return fmt.Errorf("invalid 'default' parameter")
+		}
+	default:
+		return fmt.Errorf("unknown onError hint %q", op.OnError)
+	}
+	return nil
+}
+
+// recoverOp handles the failure err of the i-th operation of a patch, op,
+// on o as its hint says, and returns the document to continue with, or err
+// when the failure stands. changes is the number of changes reported
+// before the operation.
+func (a *applier) recoverOp(o interface{}, i int, op Operation, err error, changes int) (interface{}, error) {
+	if op.OnError != "skip" && op.OnError != "default" {
+		return nil, err
+	}
+	if a.report != nil {
+		a.report.Changes = a.report.Changes[:changes]
+	}
+	if op.OnError == "skip" {
+		return o, nil
+	}
+	ins, cerr := a.compile(i, Operation{Op: "add", Path: op.Path, Value: op.Default})
+	if cerr != nil {
+		return nil, err
+	}
+	next, aerr := a.exec(copyPath(o, ins.path), i, ins)
+	if aerr != nil {
+		if a.report != nil {
+			a.report.Changes = a.report.Changes[:changes]
+		}
+		return nil, err
+	}
+	return next, nil
+}
@@ -0,0 +1,120 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestOnErrorHints(t *testing.T) {
+	ops, err := Parse([]byte(`[
+		{"op": "replace", "path": "/tags/0", "value": "new", "onError": "skip"},
+		{"op": "replace", "path": "/count", "value": 1, "onError": "default", "default": 0},
+		{"op": "add", "path": "/seen", "value": true}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ doc, expected string }{
+		{`{"tags": ["old"], "count": 5}`, `{"tags": ["new"], "count": 1, "seen": true}`},
+		{`{"count": 5}`, `{"count": 1, "seen": true}`},
+		{`{"tags": [], "name": "x"}`, `{"tags": [], "name": "x", "count": 0, "seen": true}`},
+	} {
+		result, report, err := ApplyWithReport(decode(tc.doc), ops, WithOnErrorHints())
+		if err != nil {
+			t.Errorf("%s: %v", tc.doc, err)
+			continue
+		}
+		if expected := decode(tc.expected); !reflect.DeepEqual(result, expected) {
+			t.Errorf("%s: expected %v, got %v", tc.doc, expected, result)
+		}
+		if touched := report.Touched(); slices.Contains(touched, "/tags/0") != (tc.doc == `{"tags": ["old"], "count": 5}`) {
+			t.Errorf("%s: unexpected changes %v", tc.doc, touched)
+		}
+	}
+
+	// without the option, hints are ignored
+	if _, err := Apply(decode(`{"count": 5}`), ops); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// a default that cannot be added fails as the operation would have
+	failing := []Operation{{Op: "replace", Path: "/a/b", Value: []byte(`1`), OnError: "default", Default: []byte(`0`)}}
+	if _, err := Apply(decode(`{}`), failing, WithOnErrorHints()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// abort fails the patch even with ContinueOnError
+	abort := parseStr(`[
+		{"op": "remove", "path": "/a", "onError": "abort"},
+		{"op": "add", "path": "/b", "value": 1}
+	]`)
+	if result, err := Apply(decode(`{}`), abort, WithOnErrorHints(), WithContinueOnError()); result != nil || !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the patch to be aborted, got %v %v", result, err)
+	}
+	result, err := Apply(decode(`{}`), abort[1:], WithOnErrorHints(), WithContinueOnError())
+	if expected := decode(`{"b": 1}`); err != nil || !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v %v", expected, result, err)
+	}
+
+	// a skipped operation has no effect, even half done
+	move := parseStr(`[{"op": "move", "from": "/a", "path": "/missing/b", "onError": "skip"}]`)
+	doc := decode(`{"a": 1}`)
+	if result, err := ApplyUnsafe(doc, move, WithOnErrorHints()); err != nil || !reflect.DeepEqual(result, decode(`{"a": 1}`)) {
+		t.Errorf("expected the move to be skipped, got %v %v", result, err)
+	}
+}
+
+func TestOnErrorHintsInvalid(t *testing.T) {
+	for _, op := range []Operation{
+		{Op: "remove", Path: "/a", OnError: "retry"},
+		{Op: "remove", Path: "/a", OnError: "default"},
+		{Op: "remove", Path: "/a", OnError: "default", Default: []byte(`{`)},
+	} {
+		_, err := Apply(decode(`{"a": 1}`), []Operation{op}, WithOnErrorHints())
+		if !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%+v: expected ErrInvalidPatch, got %v", op, err)
+		}
+	}
+}
+
+func TestOnErrorHintsGroups(t *testing.T) {
+	ops := parseStr(`[
+		{"op": "remove", "path": "/legacy", "onError": "skip"},
+		{"op": "add", "path": "/v", "value": 2}
+	]`)
+	result, groups, err := ApplyGroups(decode(`{"v": 1}`), ops, [][]int{{0, 1}}, WithOnErrorHints())
+	if err != nil || !groups[0].Applied {
+		t.Fatalf("expected the group to apply, got %v %v", groups, err)
+	}
+	if expected := decode(`{"v": 2}`); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestOperationHintsJSON(t *testing.T) {
+	data := `[{"op":"replace","path":"/a","value":1,"onError":"default","default":0}]`
+	ops, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ops[0].OnError != "default" || string(ops[0].Default) != "0" {
+		t.Errorf("unexpected hint %+v", ops[0])
+	}
+	if b := string(mustMarshal(t, ops)); b != data {
+		t.Errorf("expected %s, got %s", data, b)
+	}
+	if strict, err := ParseStrict([]byte(data)); err != nil || !reflect.DeepEqual(strict, ops) {
+		t.Errorf("expected ParseStrict to accept hints, got %v, %v", strict, err)
+	}
+	if _, err := ParseStrict([]byte(`[{"op":"remove","path":"/a","onError":null}]`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("expected ParseStrict to refuse a null hint, got %v", err)
+	}
+	if _, err := ParseStrict([]byte(`[{"op":"remove","path":"/a","onError":"retry"}]`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("expected ParseStrict to refuse an unknown hint, got %v", err)
+	}
+	if c := ComplianceReport(WithOnErrorHints()); !c.Enabled("on-error-hints") {
+		t.Errorf("expected the on-error-hints extension, got %v", c.Extensions)
+	}
+}
@@ -0,0 +1,103 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// operationJSON is the encoding of an Operation: "value" is omitted when
+// the operation has none, while an explicit null is kept.
+type operationJSON struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+	From  string          `json:"from,omitempty"`
+	// hints are omitted unless given
+	OnError string          `json:"onError,omitempty"`
+	Default json.RawMessage `json:"default,omitempty"`
+}
+
+// MarshalJSON encodes the operation, omitting "value" when Value is nil,
+// as for remove, move and copy, and "from" when From is empty.
+func (op Operation) MarshalJSON() ([]byte, error) {
+	return marshal(operationJSON(op))
+}
+
+// UnmarshalJSON decodes an operation, keeping the difference between a
+// missing "value", which leaves Value nil, and an explicit null. The
+// members required by standard operators must be present: "value" for add,
+// replace and test, and "from" for move and copy; their absence is an
+// InvalidPatchError. Unknown members are ignored; ParseStrict rejects them.
+func (op *Operation) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	var decoded Operation
+	if err := decodeMembers(members, &decoded, false); err != nil {
+		return &InvalidPatchError{Op: decoded.Op, Err: err}
+	}
+	*op = decoded
+	return nil
+}
+
+// requiredMembers lists the members each standard operator needs besides
+// "op" and "path".
+var requiredMembers = map[string][]string{
+	"add":     {"value"},
+	"replace": {"value"},
+	"test":    {"value"},
+	"move":    {"from"},
+	"copy":    {"from"},
+}
+
+// decodeMembers fills op from the members of its JSON object, which must
+// include "op" and those required by the operator. When strict is set,
+// "path" is required too, string members may not be null, members other
+// than those of an Operation are refused, and so are invalid hints.
+func decodeMembers(members map[string]json.RawMessage, op *Operation, strict bool) error {
+	strings := []struct {
+		name string
+		dest *string
+	}{
+		{"op", &op.Op},
+		{"path", &op.Path},
+		{"from", &op.From},
+		{"onError", &op.OnError},
+	}
+	for _, m := range strings {
+		raw, ok := members[m.name]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(raw, m.dest); err != nil || strict && string(raw) == "null" {
+			return fmt.Errorf("member %q must be a string", m.name)
+		}
+	}
+	op.Value = members["value"]
+	op.Default = members["default"]
+
+	required := []string{"op"}
+	if strict {
+		required = append(required, "path")
+	}
+	for _, name := range append(required, requiredMembers[op.Op]...) {
+		if _, ok := members[name]; !ok {
+			return fmt.Errorf("missing %q member", name)
+		}
+	}
+	if strict {
+		for name := range members {
+			switch name {
+			case "op", "path", "value", "from", "onError", "default":
+			default:
+				return fmt.Errorf("unknown member %q", name)
+			}
+		}
+		return checkHint(*op)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOperationJSON(t *testing.T) {
+	ops := parseStr(`[
+		{"op": "remove", "path": "/a"},
+		{"op": "add", "path": "/b", "value": null},
+		{"op": "move", "from": "/c", "path": "/d"},
+		{"op": "replace", "path": "/e", "value": "<x>"}
+	]`)
+	if ops[0].Value != nil || string(ops[1].Value) != "null" {
+		t.Errorf("expected a missing value to be nil and null to be kept, got %q and %q", ops[0].Value, ops[1].Value)
+	}
+	expected := `[{"op":"remove","path":"/a"},{"op":"add","path":"/b","value":null},{"op":"move","path":"/d","from":"/c"},{"op":"replace","path":"/e","value":"<x>"}]`
+	if b := string(mustMarshal(t, ops)); b != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+	if again := parseStr(expected); !reflect.DeepEqual(again, ops) {
+		t.Errorf("expected %v, got %v", ops, again)
+	}
+
+	// adding null works, where a missing value is refused
+	result, err := Apply(decode(`{}`), ops[1:2])
+	if err != nil || !reflect.DeepEqual(result, decode(`{"b": null}`)) {
+		t.Errorf("unexpected result %v %v", result, err)
+	}
+}
+
+func TestOperationRequiredMembers(t *testing.T) {
+	for _, tc := range []struct {
+		patch string
+		index int
+	}{
+		{`[{"op": "add", "path": "/a"}]`, 0},
+		{`[{"op": "remove", "path": "/a"}, {"op": "copy", "path": "/b"}]`, 1},
+		{`[{"op": "test", "path": "/a", "value": 1}, {"op": "move", "path": "/b", "value": 1}]`, 1},
+		{`[{"path": "/a"}]`, 0},
+	} {
+		_, err := Parse([]byte(tc.patch))
+		var inv *InvalidPatchError
+		if !errors.As(err, &inv) || inv.Index != tc.index {
+			t.Errorf("%s: expected an InvalidPatchError for operation %d, got %v", tc.patch, tc.index, err)
+		}
+	}
+	for _, p := range []string{
+		`[{"op": "remove", "path": "/a", "extra": 1}]`,
+		`[{"op": "frobnicate", "path": "/a", "level": 11}]`,
+		`[{"op": "remove"}]`,
+	} {
+		if _, err := Parse([]byte(p)); err != nil {
+			t.Errorf("%s: %v", p, err)
+		}
+	}
+	if _, err := ParseStrict([]byte(`[{"op": "remove", "path": "/a", "extra": 1}]`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("expected ParseStrict to reject unknown members, got %v", err)
+	}
+}
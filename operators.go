@@ -0,0 +1,116 @@
+package patch
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// Target is the location an operation's path resolves to in a document.
+type Target struct {
+	Pointer pointer.Pointer
+	// Value is the value currently at the location, if Exists.
+	Value  interface{}
+	Exists bool
+}
+
+// OperatorFunc implements a custom operator. It is called with the document
+// being patched, the operation, the operation's path resolved against the
+// document and the operation's decoded value (nil if it has none), and
+// returns the updated document. It may modify doc in place, for example with
+// target.Pointer.Set.
+type OperatorFunc func(doc interface{}, op Operation, target Target, value interface{}) (interface{}, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]OperatorFunc)
+)
+
+// RegisterOperator makes a custom operator available to every patch. It
+// panics if name is one of the standard operators or is already registered.
+// Operators that should only be available to some patches can be passed in
+// Options.Operators instead.
+func RegisterOperator(name string, fn OperatorFunc) {
+	if fn == nil {
+		panic("patch: RegisterOperator called with a nil OperatorFunc")
+	}
+	if _, ok := impls[name]; ok {
+		panic("patch: cannot redefine standard operator " + name)
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic("patch: operator " + name + " registered twice")
+	}
+	registry[name] = fn
+}
+
+// UnknownOpMode selects what happens to operations whose operator is
+// neither a standard one, one from Options.Operators nor a registered one.
+// Producers can then roll out new extension operators before every consumer
+// knows them.
+type UnknownOpMode int
+
+const (
+	// UnknownOpReject fails the patch with an error matching
+	// ErrInvalidPatch.
+	UnknownOpReject UnknownOpMode = iota
+	// UnknownOpSkip leaves the document unchanged and calls
+	// Options.OnUnknownOp, if set, to report the skipped operation.
+	UnknownOpSkip
+	// UnknownOpDispatch applies the operation with
+	// Options.UnknownOperator, or rejects it when that is nil.
+	UnknownOpDispatch
+)
+
+// WithSkipUnknownOps skips operations with an unknown operator, calling
+// warn (which may be nil) for each of them. See UnknownOpSkip.
+func WithSkipUnknownOps(warn func(index int, op Operation)) Option {
+	return func(o *Options) { o.UnknownOps, o.OnUnknownOp = UnknownOpSkip, warn }
+}
+
+// WithUnknownOperator applies operations with an unknown operator with fn.
+// See UnknownOpDispatch.
+func WithUnknownOperator(fn OperatorFunc) Option {
+	return func(o *Options) { o.UnknownOps, o.UnknownOperator = UnknownOpDispatch, fn }
+}
+
+// operator returns the implementation of the named operator: a standard
+// one, one from the options, or a registered one, in that order. For an
+// unknown operator it returns the handler selected by Options.UnknownOps, or
+// nil if the operation is to be skipped.
+func (a *applier) operator(name string) (operator, error) {
+	if impl := impls[name]; impl != nil {
+		return impl, nil
+	}
+	if fn := a.opts.Operators[name]; fn != nil {
+		return custom(fn), nil
+	}
+	registryMu.RLock()
+	fn := registry[name]
+	registryMu.RUnlock()
+	if fn != nil {
+		return custom(fn), nil
+	}
+	switch a.opts.UnknownOps {
+	case UnknownOpSkip:
+		return nil, nil
+	case UnknownOpDispatch:
+		if fn := a.opts.UnknownOperator; fn != nil {
+			return custom(fn), nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not valid operator", name)
+}
+
+func custom(fn OperatorFunc) operator {
+	return func(a *applier, root interface{}, op *Operation, c *command) (interface{}, error) {
+		return fn(root, *op, c.target(), c.value)
+	}
+}
+
+func (c *command) target() Target {
+	v, ok := c.prior(false)
+	return Target{Pointer: pointer.Pointer(c.path), Value: v, Exists: ok}
+}
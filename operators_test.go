@@ -0,0 +1,115 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func init() {
+	RegisterOperator("test-inc", func(doc interface{}, op Operation, target Target, value interface{}) (interface{}, error) {
+		n, ok := target.Value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot increment a %T", target.Value)
+		}
+		by, _ := value.(float64)
+		return target.Pointer.Set(doc, n+by)
+	})
+}
+
+func TestRegisteredOperator(t *testing.T) {
+	result, err := Apply(map[string]interface{}{"n": 1.0}, parseStr(`[{"op": "test-inc", "path": "/n", "value": 2}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"n": 3.0}) {
+		t.Errorf("unexpected result %v", result)
+	}
+	_, err = Apply(map[string]interface{}{"n": "x"}, parseStr(`[{"op": "test-inc", "path": "/n", "value": 2}]`))
+	var pathErr *PathError
+	if !errors.As(err, &pathErr) || pathErr.Index != 0 {
+		t.Errorf("expected a PathError, got %v", err)
+	}
+}
+
+func TestOptionsOperators(t *testing.T) {
+	appendStr := func(doc interface{}, op Operation, target Target, value interface{}) (interface{}, error) {
+		return target.Pointer.Set(doc, target.Value.(string)+value.(string))
+	}
+	ops := parseStr(`[{"op": "str-append", "path": "/s", "value": "def"}]`)
+	opts := WithOperator("str-append", appendStr)
+	result, _, err := ApplyWithReport(map[string]interface{}{"s": "abc"}, ops, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"s": "abcdef"}) {
+		t.Errorf("unexpected result %v", result)
+	}
+	if _, err := Apply(map[string]interface{}{"s": "abc"}, ops); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("expected the operator to be unknown without options, got %v", err)
+	}
+}
+
+func TestRegisterOperatorPanics(t *testing.T) {
+	for _, name := range []string{"add", "test-inc"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected registering %s to panic", name)
+				}
+			}()
+			RegisterOperator(name, func(doc interface{}, op Operation, target Target, value interface{}) (interface{}, error) {
+				return doc, nil
+			})
+		}()
+	}
+}
+
+func TestUnknownOps(t *testing.T) {
+	doc := decode(`{"a": 1}`)
+	ops := parseStr(`[
+		{"op": "add", "path": "/b", "value": 2},
+		{"op": "frobnicate", "path": "/does/not/exist", "level": 11},
+		{"op": "remove", "path": "/a"}
+	]`)
+
+	if _, err := Apply(doc, ops); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("expected unknown operators to be rejected by default, got %v", err)
+	}
+
+	var skipped []int
+	result, err := Apply(doc, ops, WithSkipUnknownOps(func(i int, op Operation) {
+		skipped = append(skipped, i)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, decode(`{"b": 2}`)) || !reflect.DeepEqual(skipped, []int{1}) {
+		t.Errorf("expected operation 1 to be skipped, got %v and %v", result, skipped)
+	}
+	if _, err := Apply(doc, ops, WithOptions(Options{UnknownOps: UnknownOpSkip})); err != nil {
+		t.Errorf("skipping without a callback: %v", err)
+	}
+
+	var dispatched []string
+	wildcard := func(doc interface{}, op Operation, target Target, value interface{}) (interface{}, error) {
+		dispatched = append(dispatched, op.Op+" "+target.Pointer.String())
+		return doc, nil
+	}
+	ops[1].Path = "/a"
+	if _, err := Apply(doc, ops, WithUnknownOperator(wildcard)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dispatched, []string{"frobnicate /a"}) {
+		t.Errorf("expected the unknown operation to be dispatched, got %v", dispatched)
+	}
+	if _, err := Apply(doc, ops, WithOptions(Options{UnknownOps: UnknownOpDispatch})); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("expected a rejection without a wildcard operator, got %v", err)
+	}
+
+	// registered operators are not unknown
+	if _, err := Apply(doc, parseStr(`[{"op": "test-inc", "path": "/a"}]`), WithUnknownOperator(wildcard)); err != nil || len(dispatched) != 1 {
+		t.Errorf("expected the registered operator to be used, got %v", err)
+	}
+}
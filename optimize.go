@@ -0,0 +1,290 @@
+package patch
+
+import (
+	"encoding/json"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// Optimize returns a patch with the same effect as operations on every
+// document operations apply to, with redundant operations squashed:
+//
+//   - a value that is later replaced or removed as a whole is not written
+//     first, so "add /a" followed by "replace /a" becomes a single add, and
+//     changes inside a member that is later replaced are dropped;
+//   - changes made inside a value added or replaced earlier are folded into
+//     that value, so "add /a {}" followed by "add /a/b 1" becomes
+//     "add /a {"b": 1}";
+//   - an array element inserted and later removed is never inserted, with
+//     the indexes of the operations in between adjusted accordingly, unless
+//     they use the element or the adjusted patch would be invalid;
+//   - removing a member and adding it back becomes a replace.
+//
+// Operations are only squashed across operations that do not read, write
+// or shift the values involved, so test operations act as barriers, and
+// custom operators are left alone. The patch may fail differently on
+// documents it does not apply to. Pointers are interpreted without the
+// document, so a number or "-" token is taken to address an array element.
+// Malformed patches are returned unchanged.
+func Optimize(operations []Operation) []Operation {
+	ops := make([]*rebaseOp, len(operations))
+	for i, op := range operations {
+		r, err := newRebaseOp(i, op)
+		if err != nil {
+			return operations
+		}
+		ops[i] = r
+	}
+	for changed := true; changed; {
+		changed = false
+		for j := range ops {
+			if !ops[j].dropped && squash(ops, j) {
+				changed = true
+			}
+		}
+	}
+	out := make([]Operation, 0, len(ops))
+	for _, r := range ops {
+		if r.dropped {
+			continue
+		}
+		r.op.Path = r.path.String()
+		if r.from != nil {
+			r.op.From = r.from.String()
+		}
+		out = append(out, r.op)
+	}
+	return out
+}
+
+// squash looks for an earlier operation that ops[j] makes redundant, and
+// merges the two.
+func squash(ops []*rebaseOp, j int) bool {
+	b := ops[j]
+	switch b.op.Op {
+	case "add", "replace", "remove":
+	default:
+		return false
+	}
+	cur := b.path
+	for k := j - 1; k >= 0; k-- {
+		a := ops[k]
+		if a.dropped {
+			continue
+		}
+		if _, ok := impls[a.op.Op]; !ok {
+			return false
+		}
+		prims := a.prims()
+		for pi := len(prims) - 1; pi >= 0; pi-- {
+			q, rel, ok := unshift(cur, prims[pi])
+			if !ok {
+				return false
+			}
+			if rel != relNone {
+				if prims[pi].kind == primRead || a.op.Op == "move" || a.op.Op == "copy" {
+					return false
+				}
+				return merge(ops, k, j, prims[pi], rel, q)
+			}
+			cur = q
+		}
+	}
+	return false
+}
+
+// unshift returns q, a pointer valid after p takes effect, adjusted to be
+// valid before it, and how it relates to p's target. It returns false when
+// the relation cannot be known without the document.
+func unshift(q pointer.Pointer, p prim) (pointer.Pointer, relation, bool) {
+	n := len(p.ptr)
+	if n > 0 && len(q) >= n && (p.kind == primInsert || p.kind == primDelete) &&
+		isIndex(p.ptr[n-1]) && samePrefix(q, p.ptr, n-1) {
+		m, mok := arrayIndex(p.ptr[n-1])
+		i, iok := arrayIndex(q[n-1])
+		switch {
+		case !iok:
+			// q appends, whatever p did
+			return q, relNone, true
+		case !mok:
+			// p appended an element q may or may not address
+			return q, relNone, false
+		case p.kind == primInsert && i > m:
+			return withToken(q, n-1, i-1), relNone, true
+		case p.kind == primInsert && i == m:
+			if len(q) == n {
+				return q, relSame, true
+			}
+			return q, relInside, true
+		case p.kind == primDelete && i >= m:
+			return withToken(q, n-1, i+1), relNone, true
+		}
+		return q, relNone, true
+	}
+	m := min(len(q), n)
+	if !samePrefix(q, p.ptr, m) {
+		return q, relNone, true
+	}
+	switch {
+	case len(q) == n:
+		return q, relSame, true
+	case len(q) > n:
+		return q, relInside, true
+	}
+	return q, relContains, true
+}
+
+// merge squashes ops[k] and ops[j], given that the target of ops[j], which
+// is cur in the document ops[k] applies to, relates to p, the effect of
+// ops[k], by rel.
+func merge(ops []*rebaseOp, k, j int, p prim, rel relation, cur pointer.Pointer) bool {
+	a, b := ops[k], ops[j]
+	bSets := b.op.Op == "replace" || b.op.Op == "add" && b.addPrim().kind == primSet
+	switch rel {
+	case relContains:
+		// b overwrites or removes the value a modified
+		if bSets || b.op.Op == "remove" {
+			a.dropped = true
+			return true
+		}
+	case relInside:
+		// b modifies the value a wrote, which no operation in between
+		// may read or change for b to be moved before them
+		if (a.op.Op == "add" || a.op.Op == "replace") && untouched(ops, k, j, p.ptr) {
+			return fold(a, b, cur[len(p.ptr):])
+		}
+	case relSame:
+		switch {
+		case p.kind == primInsert && b.op.Op == "remove":
+			if !uninsert(ops, k, j) {
+				return false
+			}
+			a.dropped, b.dropped = true, true
+			return true
+		case (p.kind == primInsert || p.kind == primSet) && b.op.Op == "replace":
+			a.op.Value = b.op.Value
+			b.dropped = true
+			return true
+		case p.kind == primSet && bSets:
+			a.dropped = true
+			return true
+		case a.op.Op == "replace" && b.op.Op == "remove":
+			a.dropped = true
+			return true
+		case p.kind == primDelete && b.op.Op == "add" && (b.addPrim().kind == primSet || j == k+1):
+			// removing a value and adding one at the same place
+			// replaces it
+			a.op = Operation{Op: "replace", Path: a.op.Path, Value: b.op.Value}
+			b.dropped = true
+			return true
+		}
+	}
+	return false
+}
+
+// untouched reports whether none of the operations between ops[k] and
+// ops[j] reads, writes or shifts the value at target. Array indexes are
+// taken to address any element, as they may be shifted in between.
+func untouched(ops []*rebaseOp, k, j int, target pointer.Pointer) bool {
+	for _, r := range ops[k+1 : j] {
+		if r.dropped {
+			continue
+		}
+		for _, p := range r.prims() {
+			if mayOverlap(p.ptr, target) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// mayOverlap reports whether p and q may address the same value or one a
+// value inside the other, whatever elements their array indexes address.
+func mayOverlap(p, q pointer.Pointer) bool {
+	for i := 0; i < min(len(p), len(q)); i++ {
+		if p[i] != q[i] && !(isIndex(p[i]) && isIndex(q[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// fold applies b to the value written by a, at the pointer rel relative to
+// it, and drops b.
+func fold(a, b *rebaseOp, rel pointer.Pointer) bool {
+	var value interface{}
+	if err := unmarshalNumber(a.op.Value, &value); err != nil {
+		return false
+	}
+	op := b.op
+	op.Path = rel.String()
+	value, err := Apply(value, []Operation{op}, WithUseNumber())
+	if err != nil {
+		return false
+	}
+	raw, err := marshal(value)
+	if err != nil {
+		return false
+	}
+	a.op.Value = json.RawMessage(raw)
+	b.dropped = true
+	return true
+}
+
+// uninsert adjusts the operations between ops[k] and ops[j] for the array
+// element inserted by ops[k] and removed by ops[j] no longer existing. It
+// changes nothing and returns false when one of them uses the element, or
+// would become a move into its own child.
+func uninsert(ops []*rebaseOp, k, j int) bool {
+	// elem follows the element as the operations in between shift it
+	elem := ops[k].path
+	adjust := func(q pointer.Pointer, p prim) (pointer.Pointer, bool) {
+		n := len(elem)
+		if len(q) < n || !samePrefix(q, elem, n-1) {
+			return q, true
+		}
+		pos, _ := arrayIndex(elem[n-1])
+		i, ok := arrayIndex(q[n-1])
+		switch {
+		case !ok || i < pos:
+			return q, true
+		case i > pos:
+			return withToken(q, n-1, i-1), true
+		}
+		// inserting before the element does not use it
+		return q, p.kind == primInsert && len(q) == n
+	}
+	paths := make([]pointer.Pointer, j-k-1)
+	froms := make([]pointer.Pointer, j-k-1)
+	for t := k + 1; t < j; t++ {
+		r := ops[t]
+		if r.dropped {
+			continue
+		}
+		path, from := r.path, r.from
+		// each pointer is adjusted in the state it applies to
+		for x, p := range r.prims() {
+			var ok bool
+			if x == 0 && r.from != nil {
+				from, ok = adjust(from, p)
+			} else {
+				path, ok = adjust(path, p)
+			}
+			if !ok {
+				return false
+			}
+			elem, _ = shift(elem, p)
+		}
+		if r.op.Op == "move" && len(from) < len(path) && samePrefix(path, from, len(from)) {
+			return false
+		}
+		paths[t-k-1], froms[t-k-1] = path, from
+	}
+	for t := k + 1; t < j; t++ {
+		if r := ops[t]; !r.dropped {
+			r.path, r.from = paths[t-k-1], froms[t-k-1]
+		}
+	}
+	return true
+}
@@ -0,0 +1,215 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+func TestOptimize(t *testing.T) {
+	for _, tc := range []struct {
+		patch, expected string
+	}{
+		{
+			`[{"op": "add", "path": "/a", "value": 1}, {"op": "replace", "path": "/a", "value": 2}]`,
+			`[{"op": "add", "path": "/a", "value": 2}]`,
+		},
+		{
+			`[{"op": "replace", "path": "/a", "value": 1}, {"op": "remove", "path": "/a"}]`,
+			`[{"op": "remove", "path": "/a"}]`,
+		},
+		{
+			`[{"op": "add", "path": "/a", "value": {}}, {"op": "add", "path": "/a/b", "value": [1]}, {"op": "add", "path": "/a/b/0", "value": 0}]`,
+			`[{"op": "add", "path": "/a", "value": {"b": [0, 1]}}]`,
+		},
+		{
+			`[{"op": "add", "path": "/a/x", "value": 1}, {"op": "add", "path": "/b", "value": 2}, {"op": "replace", "path": "/a", "value": {}}]`,
+			`[{"op": "add", "path": "/b", "value": 2}, {"op": "replace", "path": "/a", "value": {}}]`,
+		},
+		{
+			`[{"op": "add", "path": "/list/1", "value": "x"}, {"op": "add", "path": "/list/0", "value": "y"}, {"op": "replace", "path": "/list/3", "value": "z"}, {"op": "remove", "path": "/list/2"}]`,
+			`[{"op": "add", "path": "/list/0", "value": "y"}, {"op": "replace", "path": "/list/2", "value": "z"}]`,
+		},
+		{
+			`[{"op": "remove", "path": "/a"}, {"op": "add", "path": "/a", "value": 3}]`,
+			`[{"op": "replace", "path": "/a", "value": 3}]`,
+		},
+		{
+			`[{"op": "add", "path": "/a", "value": 1}, {"op": "test", "path": "/a", "value": 1}, {"op": "replace", "path": "/a", "value": 2}]`,
+			`[{"op": "add", "path": "/a", "value": 1}, {"op": "test", "path": "/a", "value": 1}, {"op": "replace", "path": "/a", "value": 2}]`,
+		},
+		{
+			`[{"op": "add", "path": "/a", "value": 1}, {"op": "remove", "path": "/a"}]`,
+			`[{"op": "add", "path": "/a", "value": 1}, {"op": "remove", "path": "/a"}]`,
+		},
+		{
+			`[{"op": "add", "path": "/x", "value": ["p", "q"]}, {"op": "test", "path": "/x/1", "value": "q"}, {"op": "remove", "path": "/x/0"}]`,
+			`[{"op": "add", "path": "/x", "value": ["p", "q"]}, {"op": "test", "path": "/x/1", "value": "q"}, {"op": "remove", "path": "/x/0"}]`,
+		},
+		{
+			`[{"op": "add", "path": "/x", "value": ["p", "q"]}, {"op": "copy", "from": "/x/1", "path": "/y"}, {"op": "add", "path": "/x/0", "value": "z"}]`,
+			`[{"op": "add", "path": "/x", "value": ["p", "q"]}, {"op": "copy", "from": "/x/1", "path": "/y"}, {"op": "add", "path": "/x/0", "value": "z"}]`,
+		},
+		{
+			// without the inserted element, the move would go into its
+			// own child
+			`[{"op": "add", "path": "/1", "value": ["p", "q"]}, {"op": "move", "from": "/0", "path": "/1/1"}, {"op": "test", "path": "/1/1/1", "value": 2}, {"op": "test", "path": "/2", "value": {"a": [4]}}, {"op": "add", "path": "/1/1/1", "value": {"x": 1}}, {"op": "remove", "path": "/0"}]`,
+			`[{"op": "add", "path": "/1", "value": ["p", "q"]}, {"op": "move", "from": "/0", "path": "/1/1"}, {"op": "test", "path": "/1/1/1", "value": 2}, {"op": "test", "path": "/2", "value": {"a": [4]}}, {"op": "add", "path": "/1/1/1", "value": {"x": 1}}, {"op": "remove", "path": "/0"}]`,
+		},
+		{
+			`[{"op": "add", "path": "/list/-", "value": 1}, {"op": "remove", "path": "/list/3"}]`,
+			`[{"op": "add", "path": "/list/-", "value": 1}, {"op": "remove", "path": "/list/3"}]`,
+		},
+	} {
+		got, _ := json.Marshal(Optimize(parseStr(tc.patch)))
+		expected, _ := json.Marshal(parseStr(tc.expected))
+		if string(got) != string(expected) {
+			t.Errorf("%s:\nexpected %s\ngot      %s", tc.patch, expected, got)
+		}
+	}
+}
+
+// TestOptimizeRandom checks that optimized random patches have the same
+// effect as the original ones.
+func TestOptimizeRandom(t *testing.T) {
+	doc := decode(`{"a": 1, "b": {"c": [2, 3]}, "list": [0, [1], {"x": 2}]}`)
+	nested := []interface{}{
+		decode(`[[1, 2], [3], {"a": [4]}]`),
+		decode(`[[], [[1]], [2, [3]]]`),
+		decode(`[[1], [2], [3]]`),
+	}
+	for seed := uint64(1); seed <= 3; seed++ {
+		r := rand.New(rand.NewPCG(seed, seed+1))
+		for n := 0; n < 10000; n++ {
+			checkOptimize(t, doc, randomPatch(r, doc, 2+r.IntN(6)))
+		}
+		// long patches rearranging nested arrays, where dropping an
+		// inserted element shifts the pointers of many operations
+		for n := 0; n < 2000; n++ {
+			doc := nested[n%len(nested)]
+			checkOptimize(t, doc, randomPatch(r, doc, 10, "add", "move", "remove"))
+		}
+	}
+}
+
+func checkOptimize(t *testing.T, doc interface{}, ops []Operation) {
+	t.Helper()
+	expected, err := applyRecover(doc, ops)
+	if err != nil {
+		t.Fatalf("%v\ndoes not apply: %v", fmtOps(ops), err)
+	}
+	optimized := Optimize(ops)
+	got, err := applyRecover(doc, optimized)
+	if err != nil || !reflect.DeepEqual(got, expected) {
+		t.Fatalf("on %v\n%v\noptimized to %v\nexpected %v, got %v (%v)", doc, fmtOps(ops), fmtOps(optimized), expected, got, err)
+	}
+}
+
+// randomPatch returns a random patch of n operations that applies to doc,
+// made of operations of the given kinds, or of any kind.
+func randomPatch(r *rand.Rand, doc interface{}, n int, kinds ...string) []Operation {
+	values := []string{`1`, `"p"`, `{}`, `{"x": 1}`, `[5]`, `["p", "q"]`, `[{"x": 1}, [2]]`}
+	if len(kinds) == 0 {
+		kinds = []string{"add", "replace", "remove", "test", "move", "copy"}
+	}
+	var ops []Operation
+	for len(ops) < n {
+		existing, targets := randomPointers(doc, ""), randomTargets(doc, "")
+		op := Operation{Op: kinds[r.IntN(len(kinds))]}
+		if len(existing) == 0 {
+			op.Op = "add"
+		}
+		switch op.Op {
+		case "add", "move", "copy":
+			op.Path = targets[r.IntN(len(targets))]
+		default:
+			op.Path = existing[r.IntN(len(existing))]
+		}
+		switch op.Op {
+		case "add", "replace":
+			op.Value = json.RawMessage(values[r.IntN(len(values))])
+		case "test":
+			p, _ := pointer.Parse(op.Path)
+			v, _ := p.Get(doc)
+			op.Value, _ = json.Marshal(v)
+		case "move", "copy":
+			op.From = existing[r.IntN(len(existing))]
+		}
+		next, err := applyRecover(doc, []Operation{op})
+		if err != nil {
+			continue
+		}
+		doc = next
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// randomPointers returns the pointers of the values in doc, under prefix.
+func randomPointers(doc interface{}, prefix string) []string {
+	var out []string
+	if prefix != "" {
+		out = append(out, prefix)
+	}
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for k, x := range v {
+			out = append(out, randomPointers(x, prefix+"/"+k)...)
+		}
+	case []interface{}:
+		for i, x := range v {
+			out = append(out, randomPointers(x, fmt.Sprintf("%s/%d", prefix, i))...)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// randomTargets returns the pointers values can be added at in doc, under
+// prefix.
+func randomTargets(doc interface{}, prefix string) []string {
+	var out []string
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for _, k := range []string{"a", "b", "x"} {
+			out = append(out, prefix+"/"+k)
+		}
+		for k, x := range v {
+			out = append(out, randomTargets(x, prefix+"/"+k)...)
+		}
+	case []interface{}:
+		out = append(out, prefix+"/-")
+		for i := 0; i <= len(v); i++ {
+			out = append(out, fmt.Sprintf("%s/%d", prefix, i))
+		}
+		for i, x := range v {
+			out = append(out, randomTargets(x, fmt.Sprintf("%s/%d", prefix, i))...)
+		}
+	}
+	slices.Sort(out)
+	out = slices.Compact(out)
+	return out
+}
+
+// applyRecover is Apply, reporting panics as errors.
+func applyRecover(doc interface{}, ops []Operation) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return Apply(doc, ops)
+}
+
+func fmtOps(ops []Operation) string {
+	s := ""
+	for _, op := range ops {
+		s += fmt.Sprintf("\n  %s %s %s %s", op.Op, op.From, op.Path, op.Value)
+	}
+	return s
+}
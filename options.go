@@ -0,0 +1,391 @@
+package patch
+
+import (
+	"context"
+	"time"
+)
+
+// Options controls optional behaviour when applying a patch. The zero value
+// applies operations exactly as described by RFC 6902.
+type Options struct {
+	// InPlace applies the patch directly to the given document instead of a
+	// copy. See ApplyUnsafe for what this means when the patch fails.
+	InPlace bool `json:"inPlace,omitempty"`
+
+	// CaptureBefore records the value previously found at every pointer
+	// modified by the patch in the Report returned by ApplyWithReport.
+	CaptureBefore bool `json:"captureBefore,omitempty"`
+
+	// BeforeLimit is the largest serialized size, in bytes, of a value that
+	// is captured in full. Larger values are recorded as a truncated prefix
+	// and a digest instead. Zero means no limit.
+	BeforeLimit int `json:"beforeLimit,omitempty"`
+
+	// UTF8 selects how invalid UTF-8 in the document and the operations is
+	// handled. The default passes strings through unchanged.
+	UTF8 UTF8Mode `json:"utf8,omitempty"`
+
+	// Operators adds custom operators for this application only, on top of
+	// the standard ones and those added with RegisterOperator.
+	Operators map[string]OperatorFunc `json:"-"`
+
+	// UnknownOps selects what happens to operations whose operator is not
+	// known. The default rejects them.
+	UnknownOps UnknownOpMode `json:"unknownOps,omitempty"`
+	// UnknownOperator applies operations with an unknown operator when
+	// UnknownOps is UnknownOpDispatch.
+	UnknownOperator OperatorFunc `json:"-"`
+	// OnUnknownOp is called with each operation skipped when UnknownOps is
+	// UnknownOpSkip, along with its index.
+	OnUnknownOp func(index int, op Operation) `json:"-"`
+
+	// Defaulters are called when an add operation creates an object or an
+	// array, to fill in default members. The keys are pointer prefixes
+	// whose tokens may be "*" to match any token; every defaulter whose
+	// prefix matches the created value's location is called, shortest
+	// prefix first.
+	Defaulters map[string]DefaulterFunc `json:"-"`
+
+	// OpRefs lets copy operations take their value from the output of an
+	// earlier operation of the same patch, with a "from" of the form
+	// "@N/pointer". This is an extension to RFC 6902.
+	OpRefs bool `json:"opRefs,omitempty"`
+
+	// CreateMissingParents makes add operations create the missing objects
+	// along their path, like mkdir -p: adding /a/b/c to {} produces
+	// {"a": {"b": {"c": ...}}}. Parents are always created as objects, so a
+	// token that looks like an array index names an object member when its
+	// parent is missing. Array elements are never created: a token
+	// addressing an existing array must name an existing element, and "-"
+	// is only accepted as the last token. Parents that exist but are not
+	// containers, including null, are an error as usual.
+	CreateMissingParents bool `json:"createMissingParents,omitempty"`
+
+	// MoveIndex selects how the destination index of a move within a
+	// single array is interpreted. The default follows RFC 6902.
+	MoveIndex MoveIndexMode `json:"moveIndex,omitempty"`
+
+	// NegativeIndices lets the paths of remove, replace and test operations
+	// count array elements from the end, so that "-1" names the last element
+	// and "-2" the one before it. Negative tokens are resolved against the
+	// document before the operation is applied, so reports and inverse
+	// patches carry the actual index. This is an extension to RFC 6902.
+	NegativeIndices bool `json:"negativeIndices,omitempty"`
+
+	// Equaler, when set, decides whether the value of a test operation
+	// matches the document instead of JSON equality, under which numbers
+	// are compared by value. See Equality for common relaxations.
+	Equaler Equaler `json:"-"`
+
+	// IndexResolver, when set, resolves the tokens addressing array
+	// elements that are not array indexes, such as "last" or "id:abc",
+	// before the operation is applied, so reports and inverse patches
+	// carry the actual index. This is an extension to RFC 6902.
+	IndexResolver IndexResolver `json:"-"`
+
+	// Wildcards lets the path of an operation contain "*" tokens, each
+	// matching every member of an object or every element of an array, as
+	// in "/users/*/password". The operation is then applied once for every
+	// matching path, found in the document as it is before the operation,
+	// and fails as a whole if any of them fails; reports list every path.
+	// Members are visited in sorted order and array elements last first.
+	// A path matching nothing is not an error. A member named "*" cannot be
+	// addressed while Wildcards is set, and "from" may not contain "*".
+	// This is an extension to RFC 6902.
+	Wildcards bool `json:"wildcards,omitempty"`
+
+	// FollowRefs lets paths go through local JSON references, as found in
+	// JSON Schema and OpenAPI documents: when a path continues into an
+	// object such as {"$ref": "#/components/schemas/Pet"} with a token
+	// that is not one of its members, it continues from the location the
+	// reference points to instead. The operation then applies, and is
+	// reported, at that location. The object itself and its "$ref" member
+	// can still be addressed. This is an extension to RFC 6902.
+	FollowRefs bool `json:"followRefs,omitempty"`
+
+	// JSONPath lets the path of an operation be a JSONPath expression
+	// starting with "$", such as "$.items[?(@.id == 42)].name", instead of
+	// a JSON pointer. The operation is then applied once for every location
+	// the expression selects in the document as it is before the operation,
+	// like a path with Wildcards; a member named by the last segment is
+	// selected even when missing, so that add can create it. Supported are
+	// members in dot and bracket notation, indexes (counting from the end
+	// when negative), "*", unions such as [0,2], recursive descent with ".."
+	// and filters such as [?(@.price < 10 && @.tags)]. This is an extension
+	// to RFC 6902.
+	JSONPath bool `json:"jsonPath,omitempty"`
+
+	// UseNumber decodes the values of operations with json.Number instead
+	// of float64, so that large integers and precise decimals are stored
+	// exactly. Documents should then be decoded the same way, as done by
+	// ApplyBytes or with json.Decoder.UseNumber.
+	UseNumber bool `json:"useNumber,omitempty"`
+
+	// ContinueOnError skips the operations that fail instead of stopping at
+	// the first one. The patched document is then returned along with the
+	// errors of every skipped operation, joined with errors.Join; each of
+	// them carries the index of its operation. A skipped operation has no
+	// effect on the document, at the cost of copying the objects and arrays
+	// along the path of every operation.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+
+	// OnErrorHints honours the "onError" member of operations, which says
+	// what to do when the operation fails on a document, so that a patch
+	// can tolerate documents of different shapes: "skip" skips it without
+	// an error, "default" adds the "default" member of the operation at its
+	// path instead, and "abort" fails the whole patch, even with
+	// ContinueOnError. The hints do not apply to malformed operations, and
+	// an operation whose "default" cannot be added fails as it would have
+	// without it. Like ContinueOnError, the hints cost a copy of the
+	// objects and arrays along the path of every operation. This is an
+	// extension to RFC 6902.
+	OnErrorHints bool `json:"onErrorHints,omitempty"`
+
+	// Coerce converts the string values of replace operations to the type
+	// of the number or boolean they replace, for patches built from HTML
+	// forms where every value arrives as a string: "3" replacing a number
+	// becomes 3 and "true" replacing a boolean becomes true. A string that
+	// does not convert fails the operation. CoerceTypes gives the type to
+	// coerce to at given pointers instead, whose tokens may be "*" to match
+	// any token; it applies even when Coerce is not set.
+	Coerce      bool                  `json:"coerce,omitempty"`
+	CoerceTypes map[string]CoerceType `json:"coerceTypes,omitempty"`
+
+	// Codec decodes the values of operations, instead of the codec set
+	// with SetCodec.
+	Codec Codec `json:"-"`
+
+	// Anchor is the location, as a JSON pointer, from which test
+	// operations may give their path as a Relative JSON Pointer, such as
+	// "0/sibling" or "1#". Relative paths are only accepted when Anchor is
+	// set. This is an extension to RFC 6902.
+	Anchor string `json:"anchor,omitempty"`
+
+	// StructValidator, when set, validates structs patched by PatchStruct.
+	StructValidator StructValidator `json:"-"`
+
+	// Schema, when set, is the JSON Schema the patched document must
+	// conform to. A patch producing a document that does not fails with a
+	// *SchemaError instead of returning it, once its operations are
+	// applied; with InPlace, the document is then left patched. The whole
+	// document is validated, regardless of Scope.
+	Schema *Schema `json:"schema,omitempty"`
+
+	// Quota, when set, is charged with the usage of the patch on behalf of
+	// Caller before it is applied. A patch the caller has no budget for
+	// fails with an error matching ErrQuotaExceeded and is not applied.
+	Quota  QuotaStore `json:"-"`
+	Caller string     `json:"caller,omitempty"`
+
+	// Authorize, when set, is called with Context before each operation is
+	// applied, with the location the operation's path resolves to. An
+	// operation it refuses fails the patch with an error matching
+	// ErrForbidden. For move and copy, the location is the destination;
+	// the source is the operation's From. Context defaults to
+	// context.Background().
+	Authorize AuthorizeFunc   `json:"-"`
+	Context   context.Context `json:"-"`
+
+	// Policy, when set, refuses the operations its rules do not allow at
+	// the locations they protect, failing the patch with an error matching
+	// ErrForbidden that wraps a PolicyError. It is checked before
+	// Authorize.
+	Policy *Policy `json:"policy,omitempty"`
+
+	// BeforeOp, when set, is called before each operation is applied, with
+	// the pointer the operation writes to once its path is resolved: for an
+	// element appended with "-", the index it will have. An error it returns
+	// vetoes the operation and fails the patch with a PathError wrapping it.
+	BeforeOp func(op Operation, path string) error `json:"-"`
+	// AfterOp, when set, is called after each operation other than test has
+	// been applied, with the value previously at the location it wrote to,
+	// nil when there was none, and the value now there, nil after a remove.
+	// Both values belong to the documents and must not be modified.
+	//
+	// Both are called in the order the operations are applied, and an
+	// operation with several targets, through Wildcards or JSONPath, is
+	// called once per target in the order its targets are visited: object
+	// members in sorted order, and array elements last first. The calls,
+	// like the Changes of a Report, are the same on every run.
+	AfterOp func(op Operation, oldValue, newValue interface{}) `json:"-"`
+
+	// Limits bound the size of patches and of the documents they produce.
+	// A patch exceeding them fails with a *LimitExceededError before any
+	// of its operations is applied, or, for MaxDocumentBytes, instead of
+	// returning the document.
+	Limits Limits `json:"limits,omitzero"`
+
+	// Scope, a JSON pointer, confines the patch to the value it refers to,
+	// such as the document of one tenant in a document shared by several:
+	// the paths and froms of the operations are relative to it, so that
+	// "/name" with the scope "/tenants/acme" is /tenants/acme/name, and an
+	// operation that would reach outside it, through a relative test path,
+	// a local JSON reference or a removal of the scope itself, fails with
+	// an error matching ErrForbidden that wraps a ScopeError. JSONPath
+	// expressions are evaluated against the value at the scope, while
+	// Anchor and JSON references stay pointers into the whole document, as
+	// are the locations seen by Policy, Authorize and the hooks.
+	Scope string `json:"scope,omitempty"`
+
+	// Intern replaces the repeated strings, objects and arrays of the
+	// patched document with a single shared instance of each, as done by
+	// Intern, once the patch is applied. The document must then not be
+	// modified in place.
+	Intern bool `json:"intern,omitempty"`
+
+	// Clock returns the current time, against which time-limited patches
+	// such as Envelopes are checked. It defaults to time.Now.
+	Clock func() time.Time `json:"-"`
+}
+
+// Option configures a single call to Apply or ApplyUnsafe.
+type Option func(*Options)
+
+// newOptions returns the Options built by applying opts in order.
+func newOptions(opts []Option) *Options {
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// now returns the current time of the options' Clock.
+func (o *Options) now() time.Time {
+	if o.Clock != nil {
+		return o.Clock()
+	}
+	return time.Now()
+}
+
+// WithOptions copies every field of o. Options given after it override the
+// fields they set.
+func WithOptions(o Options) Option {
+	return func(dst *Options) { *dst = o }
+}
+
+// WithAnchor lets test operations use relative pointers, evaluated from
+// anchor. See Options.Anchor.
+func WithAnchor(anchor string) Option {
+	return func(o *Options) { o.Anchor = anchor }
+}
+
+// WithUseNumber decodes operation values with json.Number. See
+// Options.UseNumber.
+func WithUseNumber() Option {
+	return func(o *Options) { o.UseNumber = true }
+}
+
+// WithContinueOnError skips failing operations. See
+// Options.ContinueOnError.
+func WithContinueOnError() Option {
+	return func(o *Options) { o.ContinueOnError = true }
+}
+
+// WithInPlace applies the patch directly to the given document. See
+// Options.InPlace.
+func WithInPlace() Option {
+	return func(o *Options) { o.InPlace = true }
+}
+
+// WithUTF8 selects how invalid UTF-8 is handled. See Options.UTF8.
+func WithUTF8(mode UTF8Mode) Option {
+	return func(o *Options) { o.UTF8 = mode }
+}
+
+// WithOperator adds a custom operator for this call. See Options.Operators.
+func WithOperator(name string, fn OperatorFunc) Option {
+	return func(o *Options) {
+		o.Operators = withEntry(o.Operators, name, fn)
+	}
+}
+
+// WithDefaulter adds a defaulter for objects and arrays created under
+// pattern. See Options.Defaulters.
+func WithDefaulter(pattern string, fn DefaulterFunc) Option {
+	return func(o *Options) {
+		o.Defaulters = withEntry(o.Defaulters, pattern, fn)
+	}
+}
+
+// WithOpRefs lets copy operations refer to the output of earlier
+// operations. See Options.OpRefs.
+func WithOpRefs() Option {
+	return func(o *Options) { o.OpRefs = true }
+}
+
+// WithCreateMissingParents makes add operations create missing parent
+// objects. See Options.CreateMissingParents.
+func WithCreateMissingParents() Option {
+	return func(o *Options) { o.CreateMissingParents = true }
+}
+
+// WithMoveIndex selects how move destinations within an array are read. See
+// Options.MoveIndex.
+func WithMoveIndex(mode MoveIndexMode) Option {
+	return func(o *Options) { o.MoveIndex = mode }
+}
+
+// WithNegativeIndices lets remove, replace and test count array elements
+// from the end. See Options.NegativeIndices.
+func WithNegativeIndices() Option {
+	return func(o *Options) { o.NegativeIndices = true }
+}
+
+// WithWildcards lets operation paths contain "*" tokens. See
+// Options.Wildcards.
+func WithWildcards() Option {
+	return func(o *Options) { o.Wildcards = true }
+}
+
+// WithFollowRefs lets paths go through local JSON references. See
+// Options.FollowRefs.
+func WithFollowRefs() Option {
+	return func(o *Options) { o.FollowRefs = true }
+}
+
+// WithJSONPath lets operation paths be JSONPath expressions. See
+// Options.JSONPath.
+func WithJSONPath() Option {
+	return func(o *Options) { o.JSONPath = true }
+}
+
+// WithBeforeOp calls fn before each operation, which it may veto. See
+// Options.BeforeOp.
+func WithBeforeOp(fn func(op Operation, path string) error) Option {
+	return func(o *Options) { o.BeforeOp = fn }
+}
+
+// WithAfterOp calls fn after each operation modifying the document. See
+// Options.AfterOp.
+func WithAfterOp(fn func(op Operation, oldValue, newValue interface{})) Option {
+	return func(o *Options) { o.AfterOp = fn }
+}
+
+// WithClock sets the clock time-limited patches are checked against. See
+// Options.Clock.
+func WithClock(clock func() time.Time) Option {
+	return func(o *Options) { o.Clock = clock }
+}
+
+// WithCoerce converts the values of replace operations to the type they
+// replace, or to the types given for some pointers. See Options.Coerce.
+func WithCoerce(types map[string]CoerceType) Option {
+	return func(o *Options) { o.Coerce, o.CoerceTypes = true, types }
+}
+
+// WithCodec sets the Codec of the patch. See Options.Codec.
+func WithCodec(c Codec) Option {
+	return func(o *Options) { o.Codec = c }
+}
+
+// withEntry returns a copy of m with key set to v, so that maps shared
+// through WithOptions are never modified.
+func withEntry[V interface{}](m map[string]V, key string, v V) map[string]V {
+	out := make(map[string]V, len(m)+1)
+	for k, x := range m {
+		out[k] = x
+	}
+	out[key] = v
+	return out
+}
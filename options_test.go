@@ -0,0 +1,56 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyOptions(t *testing.T) {
+	doc := decode(`{"list": ["a", "b", "c"]}`)
+	ops := parseStr(`[
+		{"op": "add", "path": "/x/y", "value": 1},
+		{"op": "move", "from": "/list/0", "path": "/list/3"},
+		{"op": "double", "path": "/x/y"}
+	]`)
+	double := func(doc interface{}, op Operation, target Target, value interface{}) (interface{}, error) {
+		return target.Pointer.Set(doc, target.Value.(float64)*2)
+	}
+	result, err := Apply(doc, ops,
+		WithCreateMissingParents(),
+		WithMoveIndex(MoveBeforeRemove),
+		WithOperator("double", double),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := decode(`{"list": ["b", "c", "a"], "x": {"y": 2}}`)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	if !reflect.DeepEqual(doc, decode(`{"list": ["a", "b", "c"]}`)) {
+		t.Errorf("document modified without WithInPlace: %v", doc)
+	}
+
+	if _, err := Apply(doc, ops); err == nil {
+		t.Error("expected options not to carry over between calls")
+	}
+
+	doc = decode(`{"a": {}}`)
+	if _, err := Apply(doc, parseStr(`[{"op": "add", "path": "/a/b", "value": 1}]`), WithInPlace()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc, decode(`{"a": {"b": 1}}`)) {
+		t.Errorf("expected the document to be modified in place, got %v", doc)
+	}
+}
+
+func TestWithOptions(t *testing.T) {
+	base := Options{Operators: map[string]OperatorFunc{"a": nil}, OpRefs: true}
+	o := newOptions([]Option{WithOptions(base), WithOperator("b", nil), WithInPlace()})
+	if !o.OpRefs || !o.InPlace || len(o.Operators) != 2 {
+		t.Errorf("unexpected options %+v", o)
+	}
+	if len(base.Operators) != 1 {
+		t.Error("WithOperator modified the map passed to WithOptions")
+	}
+}
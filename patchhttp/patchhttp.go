@@ -0,0 +1,180 @@
+// Package patchhttp serves HTTP PATCH requests (RFC 5789) carrying JSON
+// Patch (application/json-patch+json) or JSON Merge Patch
+// (application/merge-patch+json) documents.
+package patchhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	patch "github.com/grncdr/json-patch"
+)
+
+// Media types of the supported patch formats.
+const (
+	JSONPatch  = "application/json-patch+json"
+	MergePatch = "application/merge-patch+json"
+)
+
+// DefaultMaxBodyBytes is the largest request body accepted when
+// Handler.MaxBodyBytes is zero.
+const DefaultMaxBodyBytes = 1 << 20
+
+// StatusClientClosedRequest is the non-standard status, used by nginx,
+// responded with when the client canceled its request before it completed.
+const StatusClientClosedRequest = 499
+
+// ErrNotFound can be returned by Handler.Get to respond with 404.
+var ErrNotFound = &StatusError{Code: http.StatusNotFound, Err: errors.New("resource not found")}
+
+// StatusError is an error that responds with a specific HTTP status when
+// returned by Handler.Get or Handler.Put.
+type StatusError struct {
+	Code int
+	Err  error
+}
+
+func (e *StatusError) Error() string { return e.Err.Error() }
+func (e *StatusError) Unwrap() error { return e.Err }
+
+// Handler applies the patch in the body of PATCH requests to the resource
+// returned by Get and stores the result with Put. It responds with the
+// patched document, or with an error status following RFC 5789: 415 for an
+// unsupported content type, 400 for a malformed patch, 409 when a test
+// operation fails, 422 when the patch cannot be applied to the resource and
+// 403 when Authorize refuses one of its operations. JSON patches are
+// abandoned when the request's context is done, with 503 when its deadline
+// passed.
+type Handler struct {
+	// Get returns the current document for the request.
+	Get func(r *http.Request) (interface{}, error)
+	// Put stores the patched document. A Put checking the request's
+	// If-Match header, for example with patch.ParseETag and a
+	// patch.Document, can return an error matching
+	// patch.ErrVersionMismatch to respond with 412.
+	Put func(r *http.Request, doc interface{}) error
+	// MaxBodyBytes limits the size of patches, DefaultMaxBodyBytes if 0.
+	MaxBodyBytes int64
+	// Authorize, when set, is called with the request's context before
+	// each operation of a JSON Patch is applied. Merge patches are not
+	// authorized operation by operation, and are refused with 415 when
+	// Authorize is set.
+	Authorize patch.AuthorizeFunc
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Authorize != nil {
+		w.Header().Set("Accept-Patch", JSONPatch)
+	} else {
+		w.Header().Set("Accept-Patch", JSONPatch+", "+MergePatch)
+	}
+	if r.Method != http.MethodPatch {
+		w.Header().Set("Allow", http.MethodPatch)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != JSONPatch && (mediaType != MergePatch || h.Authorize != nil) {
+		writeError(w, http.StatusUnsupportedMediaType, errors.New("unsupported patch format"))
+		return
+	}
+
+	limit := h.MaxBodyBytes
+	if limit == 0 {
+		limit = DefaultMaxBodyBytes
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, err)
+		} else {
+			writeError(w, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	var apply func(doc interface{}) (interface{}, error)
+	if mediaType == JSONPatch {
+		ops, err := patch.ParseStrict(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		var opts []patch.Option
+		if h.Authorize != nil {
+			opts = append(opts, patch.WithAuthorize(r.Context(), h.Authorize))
+		}
+		apply = func(doc interface{}) (interface{}, error) { return patch.ApplyContext(r.Context(), doc, ops, opts...) }
+	} else {
+		var p interface{}
+		if err := json.Unmarshal(body, &p); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		apply = func(doc interface{}) (interface{}, error) { return patch.MergePatch(doc, p), nil }
+	}
+
+	doc, err := h.Get(r)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	result, err := apply(doc)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	if err := h.Put(r, result); err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// statusOf maps an error from Get, Put or applying the patch to a status.
+func statusOf(err error) int {
+	var se *StatusError
+	switch {
+	case errors.As(err, &se):
+		return se.Code
+	case errors.Is(err, patch.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, patch.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, patch.ErrTestFailed):
+		return http.StatusConflict
+	case errors.Is(err, patch.ErrInvalidPatch):
+		return http.StatusBadRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	}
+	var pe *patch.PathError
+	if errors.As(err, &pe) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	body := errorBody{Error: err.Error()}
+	var coded interface{ Code() string }
+	if errors.As(err, &coded) {
+		body.Code = coded.Code()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,152 @@
+package patchhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	patch "github.com/grncdr/json-patch"
+)
+
+func newHandler(doc map[string]interface{}) (*Handler, *interface{}) {
+	var stored interface{}
+	return &Handler{
+		Get: func(r *http.Request) (interface{}, error) {
+			if r.URL.Path == "/missing" {
+				return nil, ErrNotFound
+			}
+			if r.URL.Path == "/broken" {
+				return nil, errors.New("database down")
+			}
+			return doc, nil
+		},
+		Put: func(r *http.Request, d interface{}) error {
+			stored = d
+			return nil
+		},
+		MaxBodyBytes: 1024,
+	}, &stored
+}
+
+func TestHandler(t *testing.T) {
+	cases := []struct {
+		method, path, contentType, body string
+		status                          int
+		response                        string
+	}{
+		{"PATCH", "/doc", JSONPatch, `[{"op": "add", "path": "/b", "value": 2}]`, 200, `{"a":1,"b":2}`},
+		{"PATCH", "/doc", MergePatch + "; charset=utf-8", `{"a": null, "c": 3}`, 200, `{"c":3}`},
+		{"PATCH", "/doc", JSONPatch, `[{"op": "test", "path": "/a", "value": 2}]`, 409, `"code":"test-failed"`},
+		{"PATCH", "/doc", JSONPatch, `[{"op": "remove", "path": "/zzz"}]`, 422, `"code":"path-not-found"`},
+		{"PATCH", "/doc", JSONPatch, `[{"op": "add", "path": "/b"}]`, 400, `"code":"invalid-patch"`},
+		{"PATCH", "/doc", JSONPatch, `not json`, 400, `"error"`},
+		{"PATCH", "/doc", MergePatch, `{`, 400, `"error"`},
+		{"PATCH", "/doc", "application/json", `[]`, 415, `unsupported`},
+		{"PATCH", "/doc", JSONPatch, `[` + strings.Repeat(" ", 2000) + `]`, 413, `"error"`},
+		{"PATCH", "/missing", JSONPatch, `[]`, 404, `not found`},
+		{"PATCH", "/broken", JSONPatch, `[]`, 500, `database down`},
+		{"PUT", "/doc", JSONPatch, `[]`, 405, `not allowed`},
+	}
+	for _, c := range cases {
+		h, _ := newHandler(map[string]interface{}{"a": 1.0})
+		req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		req.Header.Set("Content-Type", c.contentType)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.status {
+			t.Errorf("%s %s: expected status %d, got %d: %s", c.contentType, c.body, c.status, rec.Code, rec.Body)
+		}
+		if !strings.Contains(rec.Body.String(), c.response) {
+			t.Errorf("%s %s: expected response to contain %s, got %s", c.contentType, c.body, c.response, rec.Body)
+		}
+		if rec.Header().Get("Accept-Patch") == "" {
+			t.Error("expected an Accept-Patch header")
+		}
+	}
+}
+
+func TestHandlerStoresResult(t *testing.T) {
+	h, stored := newHandler(map[string]interface{}{"a": 1.0})
+	req := httptest.NewRequest("PATCH", "/doc", strings.NewReader(`[{"op": "remove", "path": "/a"}]`))
+	req.Header.Set("Content-Type", JSONPatch)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if m, ok := (*stored).(map[string]interface{}); !ok || len(m) != 0 {
+		t.Errorf("expected the patched document to be stored, got %v", *stored)
+	}
+}
+
+type userKey struct{}
+
+func TestHandlerAuthorize(t *testing.T) {
+	h, _ := newHandler(map[string]interface{}{"a": 1.0})
+	h.Authorize = func(ctx context.Context, op patch.Operation, target patch.Target) error {
+		if ctx.Value(userKey{}) != "admin" && target.Pointer.String() == "/a" {
+			return errors.New("only admins may change a")
+		}
+		return nil
+	}
+	cases := []struct {
+		contentType, body, user string
+		status                  int
+	}{
+		{JSONPatch, `[{"op": "add", "path": "/b", "value": 2}]`, "", 200},
+		{JSONPatch, `[{"op": "remove", "path": "/a"}]`, "", 403},
+		{JSONPatch, `[{"op": "remove", "path": "/a"}]`, "admin", 200},
+		{MergePatch, `{"a": null}`, "admin", 415},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("PATCH", "/doc", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", c.contentType)
+		req = req.WithContext(context.WithValue(req.Context(), userKey{}, c.user))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.status {
+			t.Errorf("%s as %q: expected status %d, got %d: %s", c.body, c.user, c.status, rec.Code, rec.Body)
+		}
+		if rec.Header().Get("Accept-Patch") != JSONPatch {
+			t.Errorf("expected only JSON Patch to be accepted, got %s", rec.Header().Get("Accept-Patch"))
+		}
+	}
+}
+
+func TestHandlerDeadline(t *testing.T) {
+	h, _ := newHandler(map[string]interface{}{"a": 1.0})
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	req := httptest.NewRequest("PATCH", "/doc", strings.NewReader(`[{"op": "add", "path": "/b", "value": 2}]`)).WithContext(ctx)
+	req.Header.Set("Content-Type", JSONPatch)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestHandlerCanceled(t *testing.T) {
+	h, _ := newHandler(map[string]interface{}{"a": 1.0})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("PATCH", "/doc", strings.NewReader(`[{"op": "add", "path": "/b", "value": 2}]`)).WithContext(ctx)
+	req.Header.Set("Content-Type", JSONPatch)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != StatusClientClosedRequest {
+		t.Errorf("expected status 499, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestStatusOfContextErrors(t *testing.T) {
+	for err, expected := range map[error]int{
+		context.Canceled: StatusClientClosedRequest,
+		fmt.Errorf("loading: %w", context.Canceled): StatusClientClosedRequest,
+		context.DeadlineExceeded:                    http.StatusServiceUnavailable,
+	} {
+		if got := statusOf(err); got != expected {
+			t.Errorf("%v: expected status %d, got %d", err, expected, got)
+		}
+	}
+}
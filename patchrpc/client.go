@@ -0,0 +1,90 @@
+package patchrpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	patch "github.com/grncdr/json-patch"
+	"github.com/grncdr/json-patch/store"
+)
+
+// Client calls the methods of a Server over a byte stream. Calls are made
+// one at a time: each waits for its response before the next request is
+// written.
+type Client struct {
+	mu   sync.Mutex
+	enc  *json.Encoder
+	dec  *json.Decoder
+	next int64
+}
+
+// NewClient returns a Client writing requests to w and reading responses
+// from r.
+func NewClient(r io.Reader, w io.Writer) *Client {
+	return &Client{enc: json.NewEncoder(w), dec: json.NewDecoder(r)}
+}
+
+// Call calls method with params and decodes its result into result, unless
+// result is nil. A failed call returns an *Error.
+func (c *Client) Call(method string, params, result interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.next++
+	id := json.RawMessage(strconv.FormatInt(c.next, 10))
+	if err := c.enc.Encode(Request{JSONRPC: Version, ID: id, Method: method, Params: raw}); err != nil {
+		return err
+	}
+	var resp Response
+	if err := c.dec.Decode(&resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if string(resp.ID) != string(id) {
+		return fmt.Errorf("response id %s does not match request id %s", resp.ID, id)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+// Get returns the value at ptr in the document stored under key, all of
+// it when ptr is empty.
+func (c *Client) Get(key, ptr string) (*GetResult, error) {
+	var res GetResult
+	if err := c.Call(MethodGet, GetParams{Key: key, Pointer: ptr}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Apply applies ops to the document stored under key, only if it is at
+// ifVersion when that is non-zero, and returns the new version.
+func (c *Client) Apply(key string, ops []patch.Operation, ifVersion int64) (store.Record, error) {
+	raw, err := json.Marshal(ops)
+	if err != nil {
+		return store.Record{}, err
+	}
+	var rec store.Record
+	err = c.Call(MethodApply, ApplyParams{Key: key, Patch: raw, IfVersion: ifVersion}, &rec)
+	return rec, err
+}
+
+// Diff returns the patch turning the document stored under key into
+// modified, with the version of the stored document.
+func (c *Client) Diff(key string, modified []byte) (*DiffResult, error) {
+	var res DiffResult
+	if err := c.Call(MethodDiff, DiffParams{Key: key, Modified: modified}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
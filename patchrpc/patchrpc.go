@@ -0,0 +1,173 @@
+// Package patchrpc defines JSON-RPC 2.0 methods for reading, diffing and
+// patching the documents of a store.Store, with a Server answering them
+// and a Client calling them over any byte stream, such as the standard
+// input and output of an editor plugin.
+//
+// The methods are:
+//
+//   - document.get, with GetParams, returns a GetResult;
+//   - document.apply, with ApplyParams, returns the new store.Record;
+//   - document.diff, with DiffParams, returns a DiffResult.
+package patchrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	patch "github.com/grncdr/json-patch"
+	"github.com/grncdr/json-patch/store"
+)
+
+// Version is the JSON-RPC version of requests and responses.
+const Version = "2.0"
+
+// Names of the methods.
+const (
+	MethodGet   = "document.get"
+	MethodApply = "document.apply"
+	MethodDiff  = "document.diff"
+)
+
+// Error codes: those defined by JSON-RPC 2.0, and those of the errors of
+// the methods.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+
+	// CodeNotFound is returned when no document is stored under the key.
+	CodeNotFound = -32001
+	// CodeConflict is returned when the document is not at the version
+	// the request expects.
+	CodeConflict = -32002
+	// CodeInvalidPatch is returned for a malformed patch.
+	CodeInvalidPatch = -32003
+	// CodeApplyFailed is returned when a patch cannot be applied to the
+	// document, for example because a test operation fails; Data.Code
+	// tells why.
+	CodeApplyFailed = -32004
+)
+
+// Request is a JSON-RPC request, or a notification when ID is nil.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC response, carrying either Result or Error.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is the error of a JSON-RPC response. Clients return it for the
+// calls that fail, and it matches store.ErrNotFound, store.ErrConflict,
+// patch.ErrInvalidPatch and patch.ErrTestFailed with errors.Is according
+// to its codes.
+type Error struct {
+	Code    int        `json:"code"`
+	Message string     `json:"message"`
+	Data    *ErrorData `json:"data,omitempty"`
+}
+
+// ErrorData details an Error caused by a patch.
+type ErrorData struct {
+	// Code is the Code() of the patch error, such as "test-failed".
+	Code string `json:"code,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case store.ErrNotFound:
+		return e.Code == CodeNotFound
+	case store.ErrConflict:
+		return e.Code == CodeConflict
+	case patch.ErrInvalidPatch:
+		return e.Code == CodeInvalidPatch
+	case patch.ErrTestFailed:
+		return e.Data != nil && e.Data.Code == "test-failed"
+	}
+	return false
+}
+
+// GetParams are the parameters of document.get.
+type GetParams struct {
+	Key string `json:"key"`
+	// Pointer selects the part of the document to return, all of it when
+	// empty.
+	Pointer string `json:"pointer,omitempty"`
+}
+
+// GetResult is the result of document.get.
+type GetResult struct {
+	Key     string          `json:"key"`
+	Version int64           `json:"version"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// ApplyParams are the parameters of document.apply.
+type ApplyParams struct {
+	Key string `json:"key"`
+	// Patch is the JSON Patch to apply, which must be valid for
+	// patch.ParseStrict.
+	Patch json.RawMessage `json:"patch"`
+	// IfVersion, when non-zero, only applies the patch to that version of
+	// the document, as in store.Store.Patch.
+	IfVersion int64 `json:"ifVersion,omitempty"`
+}
+
+// DiffParams are the parameters of document.diff, which returns the patch
+// turning Original into Modified. When Key is set, the document stored
+// under it is diffed instead of Original, so that the patch can then be
+// applied with the version in DiffResult.
+type DiffParams struct {
+	Key      string          `json:"key,omitempty"`
+	Original json.RawMessage `json:"original,omitempty"`
+	Modified json.RawMessage `json:"modified"`
+}
+
+// DiffResult is the result of document.diff.
+type DiffResult struct {
+	// Version is the version of the stored document that was diffed, 0
+	// when DiffParams.Key was empty.
+	Version int64             `json:"version,omitempty"`
+	Patch   []patch.Operation `json:"patch"`
+}
+
+// errorOf converts an error of a method to the Error of its response.
+func errorOf(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	e = &Error{Message: err.Error()}
+	var coded interface{ Code() string }
+	if errors.As(err, &coded) {
+		e.Data = &ErrorData{Code: coded.Code()}
+	}
+	var pe *patch.PathError
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		e.Code = CodeNotFound
+	case errors.Is(err, store.ErrConflict):
+		e.Code = CodeConflict
+	case errors.Is(err, patch.ErrInvalidPatch):
+		e.Code = CodeInvalidPatch
+	case errors.Is(err, patch.ErrTestFailed), errors.Is(err, patch.ErrForbidden), errors.As(err, &pe):
+		e.Code = CodeApplyFailed
+	default:
+		e.Code = CodeInternalError
+	}
+	return e
+}
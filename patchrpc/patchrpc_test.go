@@ -0,0 +1,117 @@
+package patchrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	patch "github.com/grncdr/json-patch"
+	"github.com/grncdr/json-patch/store"
+)
+
+func newServer(t *testing.T) *Server {
+	s := store.New(store.NewMemory())
+	if _, err := s.Create(context.Background(), "doc", []byte(`{"a": 1, "big": 12345678901234567890}`)); err != nil {
+		t.Fatal(err)
+	}
+	return &Server{Store: s}
+}
+
+func TestHandle(t *testing.T) {
+	cases := []struct{ request, response string }{
+		{`{"jsonrpc": "2.0", "id": 1, "method": "document.get", "params": {"key": "doc", "pointer": "/big"}}`,
+			`{"jsonrpc":"2.0","id":1,"result":{"key":"doc","version":1,"value":12345678901234567890}}`},
+		{`{"jsonrpc": "2.0", "id": "x", "method": "document.apply", "params": {"key": "doc", "patch": [{"op": "add", "path": "/b", "value": 2}]}}`,
+			`"result":{"key":"doc","version":2,"doc":{"a":1,"b":2,"big":12345678901234567890}`},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "document.diff", "params": {"original": {"a": 1}, "modified": {"a": 2}}}`,
+			`"result":{"patch":[{"op":"replace","path":"/a","value":2}]}`},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "document.get", "params": {"key": "missing"}}`, `"code":-32001`},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "document.get", "params": {"key": "doc", "pointer": "/zzz"}}`, `"data":{"code":"path-not-found"}`},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "document.apply", "params": {"key": "doc", "patch": [{"op": "test", "path": "/a", "value": 2}]}}`,
+			`{"code":-32004,"message":"operation 0: test failed: /a expected to be 2, found 1","data":{"code":"test-failed"}}`},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "document.apply", "params": {"key": "doc", "patch": [{"op": "add", "path": "/b"}]}}`, `"code":-32003`},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "document.apply", "params": {"key": "doc", "patch": [], "ifVersion": 7}}`, `"code":-32002`},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "document.apply", "params": {"key": "doc"}}`, `"code":-32602`},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "document.diff", "params": {"key": "doc", "original": {}, "modified": {}}}`, `"code":-32602`},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "document.get", "params": ["doc"]}`, `"code":-32602`},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "document.delete"}`, `"code":-32601`},
+		{`{"id": 1, "method": "document.get"}`, `{"jsonrpc":"2.0","id":1,"error":{"code":-32600`},
+		{`{"jsonrpc": "2.0", "id": 1`, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700`},
+		{`[]`, `"code":-32600`},
+		{`[{"jsonrpc": "2.0", "id": 1, "method": "document.get", "params": {"key": "doc", "pointer": "/a"}}, 5]`,
+			`[{"jsonrpc":"2.0","id":1,"result":{"key":"doc","version":1,"value":1}},{"jsonrpc":"2.0","id":null,"error":{"code":-32600`},
+	}
+	for _, c := range cases {
+		resp := string(newServer(t).Handle(context.Background(), []byte(c.request)))
+		if !strings.Contains(resp, c.response) {
+			t.Errorf("%s: expected response to contain %s, got %s", c.request, c.response, resp)
+		}
+	}
+}
+
+func TestHandleNotifications(t *testing.T) {
+	s := newServer(t)
+	ctx := context.Background()
+	if resp := s.Handle(ctx, []byte(`{"jsonrpc": "2.0", "method": "document.apply", "params": {"key": "doc", "patch": [{"op": "remove", "path": "/a"}]}}`)); resp != nil {
+		t.Errorf("expected no response to a notification, got %s", resp)
+	}
+	if resp := s.Handle(ctx, []byte(`[{"jsonrpc": "2.0", "method": "document.get", "params": {"key": "doc"}}]`)); resp != nil {
+		t.Errorf("expected no response to a batch of notifications, got %s", resp)
+	}
+	if rec, _ := s.Store.Get(ctx, "doc"); rec.Version != 2 {
+		t.Errorf("expected the notification to be applied, got version %d", rec.Version)
+	}
+}
+
+func TestClient(t *testing.T) {
+	s := newServer(t)
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- s.Serve(context.Background(), reqR, respW)
+		respW.Close()
+	}()
+	c := NewClient(respR, reqW)
+
+	diff, err := c.Diff("doc", []byte(`{"a": 2, "big": 12345678901234567890}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Version != 1 || len(diff.Patch) != 1 || diff.Patch[0].Op != "replace" {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+	rec, err := c.Apply("doc", diff.Patch, diff.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Version != 2 || string(rec.Doc) != `{"a":2,"big":12345678901234567890}` {
+		t.Errorf("unexpected record %d %s", rec.Version, rec.Doc)
+	}
+	got, err := c.Get("doc", "/a")
+	if err != nil || got.Version != 2 || string(got.Value) != "2" {
+		t.Errorf("unexpected get %+v %v", got, err)
+	}
+
+	if _, err := c.Apply("doc", diff.Patch, diff.Version); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("expected a conflict, got %v", err)
+	}
+	test := []patch.Operation{{Op: "test", Path: "/a", Value: []byte(`3`)}}
+	if _, err := c.Apply("doc", test, 0); !errors.Is(err, patch.ErrTestFailed) {
+		t.Errorf("expected a failed test, got %v", err)
+	}
+	if _, err := c.Get("missing", ""); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+	var rpcErr *Error
+	if err := c.Call("document.delete", struct{}{}, nil); !errors.As(err, &rpcErr) || rpcErr.Code != CodeMethodNotFound {
+		t.Errorf("expected method not found, got %v", err)
+	}
+
+	reqW.Close()
+	if err := <-done; err != nil {
+		t.Errorf("expected Serve to return nil at the end of the stream, got %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+package patchrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	patch "github.com/grncdr/json-patch"
+	"github.com/grncdr/json-patch/pointer"
+	"github.com/grncdr/json-patch/store"
+)
+
+// Server answers the methods of the package for the documents of Store.
+type Server struct {
+	Store *store.Store
+}
+
+// Serve reads requests, or batches of requests, from r and writes their
+// responses to w, one per line, until r is exhausted or ctx is done. A
+// stream that is not valid JSON cannot be resynchronized: Serve responds
+// with a parse error and returns it.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	d := json.NewDecoder(r)
+	for ctx.Err() == nil {
+		var msg json.RawMessage
+		err := d.Decode(&msg)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			resp, _ := json.Marshal(errorResponse(nil, &Error{Code: CodeParseError, Message: err.Error()}))
+			w.Write(append(resp, '\n'))
+			return err
+		}
+		if resp := s.Handle(ctx, msg); resp != nil {
+			if _, err := w.Write(append(resp, '\n')); err != nil {
+				return err
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// Handle answers a request or a batch of requests encoded in msg and
+// returns the encoded response, or nil when msg only holds notifications.
+func (s *Server) Handle(ctx context.Context, msg []byte) []byte {
+	msg = bytes.TrimSpace(msg)
+	var resp interface{}
+	if len(msg) > 0 && msg[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(msg, &batch); err != nil {
+			resp = errorResponse(nil, &Error{Code: CodeParseError, Message: err.Error()})
+		} else if len(batch) == 0 {
+			resp = errorResponse(nil, &Error{Code: CodeInvalidRequest, Message: "empty batch"})
+		} else {
+			var responses []*Response
+			for _, m := range batch {
+				if r := s.handle(ctx, m); r != nil {
+					responses = append(responses, r)
+				}
+			}
+			if len(responses) == 0 {
+				return nil
+			}
+			resp = responses
+		}
+	} else if r := s.handle(ctx, msg); r != nil {
+		resp = r
+	} else {
+		return nil
+	}
+	out, err := json.Marshal(resp)
+	if err != nil {
+		out, _ = json.Marshal(errorResponse(nil, &Error{Code: CodeInternalError, Message: err.Error()}))
+	}
+	return out
+}
+
+// handle answers a single request, returning nil for a notification.
+func (s *Server) handle(ctx context.Context, msg json.RawMessage) *Response {
+	var req Request
+	if err := json.Unmarshal(msg, &req); err != nil {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			return errorResponse(nil, &Error{Code: CodeParseError, Message: err.Error()})
+		}
+		return errorResponse(nil, &Error{Code: CodeInvalidRequest, Message: err.Error()})
+	}
+	if req.JSONRPC != Version || req.Method == "" {
+		return errorResponse(req.ID, &Error{Code: CodeInvalidRequest, Message: "invalid request"})
+	}
+	result, err := s.call(ctx, req.Method, req.Params)
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		return errorResponse(req.ID, errorOf(err))
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, errorOf(err))
+	}
+	return &Response{JSONRPC: Version, ID: req.ID, Result: raw}
+}
+
+func errorResponse(id json.RawMessage, e *Error) *Response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &Response{JSONRPC: Version, ID: id, Error: e}
+}
+
+// call runs method with the encoded params.
+func (s *Server) call(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case MethodGet:
+		var p GetParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.get(ctx, p)
+	case MethodApply:
+		var p ApplyParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Key == "" || p.Patch == nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: "key and patch are required"}
+		}
+		ops, err := patch.ParseStrict(p.Patch)
+		if err != nil {
+			return nil, err
+		}
+		return s.Store.Patch(ctx, p.Key, ops, p.IfVersion)
+	case MethodDiff:
+		var p DiffParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.diff(ctx, p)
+	}
+	return nil, &Error{Code: CodeMethodNotFound, Message: "method not found: " + method}
+}
+
+// decodeParams decodes the params of a request, which must be an object.
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 || params[0] != '{' {
+		return &Error{Code: CodeInvalidParams, Message: "params must be an object"}
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) get(ctx context.Context, p GetParams) (*GetResult, error) {
+	if p.Key == "" {
+		return nil, &Error{Code: CodeInvalidParams, Message: "key is required"}
+	}
+	ptr, err := pointer.Parse(p.Pointer)
+	if err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	rec, err := s.Store.Get(ctx, p.Key)
+	if err != nil {
+		return nil, err
+	}
+	res := &GetResult{Key: rec.Key, Version: rec.Version, Value: rec.Doc}
+	if len(ptr) > 0 {
+		d := json.NewDecoder(bytes.NewReader(rec.Doc))
+		d.UseNumber()
+		var doc interface{}
+		if err := d.Decode(&doc); err != nil {
+			return nil, err
+		}
+		v, err := ptr.Get(doc)
+		if err != nil {
+			pe := &patch.PathError{Path: p.Pointer, Err: err}
+			return nil, &Error{Code: CodeInvalidParams, Message: err.Error(), Data: &ErrorData{Code: pe.Code()}}
+		}
+		if res.Value, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *Server) diff(ctx context.Context, p DiffParams) (*DiffResult, error) {
+	if p.Modified == nil || (p.Key == "") == (p.Original == nil) {
+		return nil, &Error{Code: CodeInvalidParams, Message: "modified and exactly one of key and original are required"}
+	}
+	res := &DiffResult{}
+	original := p.Original
+	if p.Key != "" {
+		rec, err := s.Store.Get(ctx, p.Key)
+		if err != nil {
+			return nil, err
+		}
+		original, res.Version = rec.Doc, rec.Version
+	}
+	raw, err := patch.CreatePatchBytes(original, p.Modified)
+	if err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	if res.Patch, err = patch.Parse(raw); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,251 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+var (
+	// ErrStreamGap matches errors for events of a PatchStream that are
+	// missing, either skipped by a StreamClient or no longer kept in the
+	// stream's history for a subscriber resuming after them.
+	ErrStreamGap = errors.New("gap in patch stream")
+	// ErrSlowSubscriber is the error of a Subscription dropped by its
+	// PatchStream because its buffer was full.
+	ErrSlowSubscriber = errors.New("subscriber too slow")
+)
+
+// StreamEvent is a patch applied to the document of a PatchStream. Events
+// are numbered from 1 in the order the patches were applied.
+type StreamEvent struct {
+	Seq   uint64      `json:"seq"`
+	Patch []Operation `json:"patch"`
+}
+
+// StreamGapError reports a missing event of a PatchStream.
+type StreamGapError struct {
+	Expected uint64 // the sequence number of the missing event
+	Got      uint64 // the next one available
+}
+
+func (e *StreamGapError) Error() string {
+	return fmt.Sprintf("patch stream: expected event %d, got %d", e.Expected, e.Got)
+}
+
+// Is makes StreamGapError match ErrStreamGap.
+func (e *StreamGapError) Is(target error) bool { return target == ErrStreamGap }
+
+// Code returns "stream-gap".
+func (e *StreamGapError) Code() string { return "stream-gap" }
+
+// PatchStream is a document on the server side of a live synchronization,
+// over WebSockets or server-sent events for example. Every patch applied to
+// it is numbered and delivered to its subscribers, which StreamClients turn
+// back into copies of the document. The most recent events are kept in a
+// history, so that a subscriber that lost its connection can resume where
+// it left off. A PatchStream is safe for concurrent use.
+type PatchStream struct {
+	mu      sync.Mutex // orders patches and their delivery
+	doc     *SyncDocument
+	seq     uint64
+	history []StreamEvent // at most keep events, oldest first
+	keep    int
+	subs    []*Subscription // in the order they subscribed
+}
+
+// Subscription delivers the events of a PatchStream on C, in order. C is
+// closed when the subscription is cancelled or dropped by the stream.
+type Subscription struct {
+	C      <-chan StreamEvent
+	ch     chan StreamEvent
+	stream *PatchStream
+	err    error // guarded by stream.mu
+}
+
+// NewPatchStream returns a PatchStream holding doc, which must not be
+// modified afterwards, and keeping the last history events for subscribers
+// to resume from. opts are used for every patch, as by NewSyncDocument, and
+// must be given to the StreamClients as well: with options such as
+// ContinueOnError, the events are only meaningful when applied the same
+// way.
+func NewPatchStream(doc interface{}, history int, opts ...Option) (*PatchStream, error) {
+	d, err := NewSyncDocument(doc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &PatchStream{doc: d, keep: history}, nil
+}
+
+// Apply applies ops to the document, as SyncDocument.Apply does, and
+// delivers them to the subscribers unless the document was left unchanged
+// by an error. It returns the sequence number of the event, or 0. ops are
+// shared with the subscribers and must not be modified afterwards.
+//
+// Events are delivered to the subscribers in the order they subscribed.
+// Delivery does not block: a subscriber whose buffer is full is dropped,
+// and must subscribe again from the last event it received.
+func (s *PatchStream) Apply(ops []Operation) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.doc.Apply(ops)
+	if err != nil && !newOptions(s.doc.opts).ContinueOnError {
+		return 0, err
+	}
+	s.seq++
+	e := StreamEvent{Seq: s.seq, Patch: ops}
+	if s.keep > 0 {
+		if len(s.history) == s.keep {
+			s.history = s.history[1:]
+		}
+		s.history = append(s.history, e)
+	}
+	for _, sub := range slices.Clone(s.subs) {
+		select {
+		case sub.ch <- e:
+		default:
+			sub.close(ErrSlowSubscriber)
+		}
+	}
+	return e.Seq, err
+}
+
+// Snapshot returns the current document and the sequence number of the last
+// event applied to it. The document is shared, as by SyncDocument.Snapshot,
+// and must not be modified.
+func (s *PatchStream) Snapshot() (interface{}, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doc.Snapshot(), s.seq
+}
+
+// Subscribe returns a Subscription delivering the events after the one
+// numbered after, starting with those in the history, with room for buffer
+// more events. It fails with a *StreamGapError when the history no longer
+// holds the events following after; the subscriber must then start over
+// from a Snapshot, or with Join.
+func (s *PatchStream) Subscribe(after uint64, buffer int) (*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subscribe(after, buffer)
+}
+
+// Join returns a StreamClient holding the current document and a
+// Subscription delivering the events that follow it. opts are those of the
+// StreamClient.
+func (s *PatchStream) Join(buffer int, opts ...Option) (*StreamClient, *Subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, _ := s.subscribe(s.seq, buffer)
+	return NewStreamClient(s.doc.Snapshot(), s.seq, opts...), sub
+}
+
+func (s *PatchStream) subscribe(after uint64, buffer int) (*Subscription, error) {
+	if after > s.seq {
+		return nil, fmt.Errorf("patch stream: no event %d yet", after)
+	}
+	var missed []StreamEvent
+	if after < s.seq {
+		first := s.seq - uint64(len(s.history)) + 1
+		if after+1 < first {
+			return nil, &StreamGapError{Expected: after + 1, Got: first}
+		}
+		missed = s.history[after+1-first:]
+	}
+	ch := make(chan StreamEvent, len(missed)+buffer)
+	for _, e := range missed {
+		ch <- e
+	}
+	sub := &Subscription{C: ch, ch: ch, stream: s}
+	s.subs = append(s.subs, sub)
+	return sub, nil
+}
+
+// Cancel ends the subscription and closes C.
+func (sub *Subscription) Cancel() {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	sub.close(nil)
+}
+
+// Err returns ErrSlowSubscriber once the subscription was dropped by its
+// stream, and nil otherwise.
+func (sub *Subscription) Err() error {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	return sub.err
+}
+
+// close ends the subscription with err. The stream must be locked.
+func (sub *Subscription) close(err error) {
+	i := slices.Index(sub.stream.subs, sub)
+	if i < 0 {
+		return
+	}
+	sub.stream.subs = slices.Delete(sub.stream.subs, i, i+1)
+	sub.err = err
+	close(sub.ch)
+}
+
+// StreamClient keeps a copy of the document of a PatchStream by applying
+// its events in order. Events it already applied are ignored, so a client
+// can resubscribe from an earlier event than its own, and a missing event
+// is reported rather than skipped. A StreamClient is not safe for
+// concurrent use.
+type StreamClient struct {
+	doc  interface{}
+	seq  uint64
+	opts []Option
+}
+
+// NewStreamClient returns a StreamClient holding doc, the document of a
+// PatchStream after the event numbered seq. doc is not modified: patches
+// copy the objects and arrays they write to, as with SyncDocument.
+func NewStreamClient(doc interface{}, seq uint64, opts ...Option) *StreamClient {
+	return &StreamClient{doc: doc, seq: seq, opts: opts}
+}
+
+// Doc returns the client's document. It must not be modified.
+func (c *StreamClient) Doc() interface{} { return c.doc }
+
+// Seq returns the sequence number of the last event applied.
+func (c *StreamClient) Seq() uint64 { return c.seq }
+
+// Apply applies e to the document. It does nothing for an event the client
+// already applied, and fails with a *StreamGapError for one that does not
+// follow the last event applied.
+func (c *StreamClient) Apply(e StreamEvent) error {
+	if e.Seq <= c.seq {
+		return nil
+	}
+	if e.Seq != c.seq+1 {
+		return &StreamGapError{Expected: c.seq + 1, Got: e.Seq}
+	}
+	a := &applier{opts: newOptions(c.opts), shared: true}
+	doc, err := a.apply(c.doc, e.Patch)
+	if err != nil && !a.opts.ContinueOnError {
+		return fmt.Errorf("patch stream event %d: %w", e.Seq, err)
+	}
+	c.doc, c.seq = doc, e.Seq
+	return nil
+}
+
+// Consume applies the events of sub until its channel is closed, returning
+// the subscription's error, or until ctx is done or an event fails to apply.
+func (c *StreamClient) Consume(ctx context.Context, sub *Subscription) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-sub.C:
+			if !ok {
+				return sub.Err()
+			}
+			if err := c.Apply(e); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPatchStream(t *testing.T) {
+	s, err := NewPatchStream(decode(`{"n": 0}`), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, sub := s.Join(10)
+	for i, p := range []string{
+		`[{"op": "replace", "path": "/n", "value": 1}]`,
+		`[{"op": "add", "path": "/a", "value": []}]`,
+		`[{"op": "add", "path": "/a/-", "value": "x"}]`,
+	} {
+		if seq, err := s.Apply(parseStr(p)); err != nil || seq != uint64(i+1) {
+			t.Fatalf("unexpected result %d, %v", seq, err)
+		}
+	}
+	if seq, err := s.Apply(parseStr(`[{"op": "remove", "path": "/missing"}]`)); err == nil || seq != 0 {
+		t.Errorf("expected a failed patch not to be delivered, got %d, %v", seq, err)
+	}
+	sub.Cancel()
+	if err := client.Consume(context.Background(), sub); err != nil {
+		t.Fatal(err)
+	}
+	doc, seq := s.Snapshot()
+	if client.Seq() != 3 || seq != 3 || !jsonEqual(client.Doc(), doc) {
+		t.Errorf("expected the client to catch up, got %v at %d", client.Doc(), client.Seq())
+	}
+
+	// resuming from the history
+	late := NewStreamClient(decode(`{"n": 1}`), 1)
+	resumed, err := s.Subscribe(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed.Cancel()
+	if err := late.Consume(context.Background(), resumed); err != nil || !jsonEqual(late.Doc(), doc) {
+		t.Errorf("expected the client to resume, got %v, %v", late.Doc(), err)
+	}
+	if _, err := s.Subscribe(0, 0); !errors.Is(err, ErrStreamGap) {
+		t.Errorf("expected the history to be too short, got %v", err)
+	}
+	if _, err := s.Subscribe(4, 0); err == nil {
+		t.Error("expected a future event to be refused")
+	}
+}
+
+func TestPatchStreamSlowSubscriber(t *testing.T) {
+	s, _ := NewPatchStream(decode(`{}`), 0)
+	client, sub := s.Join(1)
+	s.Apply(parseStr(`[{"op": "add", "path": "/a", "value": 1}]`))
+	s.Apply(parseStr(`[{"op": "add", "path": "/b", "value": 1}]`))
+	if err := client.Consume(context.Background(), sub); !errors.Is(err, ErrSlowSubscriber) {
+		t.Errorf("expected the subscriber to be dropped, got %v", err)
+	}
+	if client.Seq() != 1 {
+		t.Errorf("expected the buffered event to be applied, got %d", client.Seq())
+	}
+	sub.Cancel()
+}
+
+func TestStreamClient(t *testing.T) {
+	c := NewStreamClient(decode(`{}`), 0)
+	e := StreamEvent{Seq: 1, Patch: parseStr(`[{"op": "add", "path": "/a", "value": 1}]`)}
+	if err := c.Apply(e); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Apply(e); err != nil {
+		t.Errorf("expected a duplicate to be ignored, got %v", err)
+	}
+	var gap *StreamGapError
+	if err := c.Apply(StreamEvent{Seq: 3}); !errors.As(err, &gap) || gap.Expected != 2 || gap.Got != 3 {
+		t.Errorf("expected a gap, got %v", err)
+	}
+	if err := c.Apply(StreamEvent{Seq: 2, Patch: parseStr(`[{"op": "remove", "path": "/b"}]`)}); !errors.Is(err, ErrNotFound) || c.Seq() != 1 {
+		t.Errorf("expected a failing event not to be applied, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s, _ := NewPatchStream(decode(`{}`), 0)
+	_, sub := s.Join(0)
+	if err := c.Consume(ctx, sub); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context to end the consumption, got %v", err)
+	}
+}
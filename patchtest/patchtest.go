@@ -0,0 +1,288 @@
+// Package patchtest runs declarative JSON patch tests stored as data.
+//
+// A spec file is a JSON array of specs, in the same shape as the
+// json-patch-tests suite (https://github.com/json-patch/json-patch-tests)
+// with a few additions:
+//
+//	{
+//	  "comment":      "human readable description",
+//	  "doc":          {...},            // or "docFile": "doc.json"
+//	  "patch":        [...],            // or "patchFile": "patch.json"
+//	  "expected":     {...},            // or "expectedFile": "out.json"
+//	  "error":        "any message",    // the patch is expected to fail
+//	  "errorCode":    "test-failed",    // ... with an error carrying this code
+//	  "options":      {"captureBefore": true},
+//	  "disabled":     false
+//	}
+//
+// File references are resolved relative to the directory of the spec file.
+// The checks accept extra Options, such as custom operators, which cannot
+// be given as data, so that implementations of extensions can run the
+// official suite and their own spec files against the library. Results are
+// reported through a testing.T, or in the Test Anything Protocol by RunTAP
+// for other harnesses. Golden compares results with files kept in testdata.
+//
+// Replay checks that a patch consumer recovers from damaged, reordered and
+// duplicated deliveries of a patch log.
+package patchtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	patch "github.com/grncdr/json-patch"
+)
+
+// Spec is a single test case.
+type Spec struct {
+	Comment      string            `json:"comment,omitempty"`
+	Doc          interface{}       `json:"doc,omitempty"`
+	DocFile      string            `json:"docFile,omitempty"`
+	Patch        []patch.Operation `json:"patch,omitempty"`
+	PatchFile    string            `json:"patchFile,omitempty"`
+	Expected     interface{}       `json:"expected,omitempty"`
+	ExpectedFile string            `json:"expectedFile,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	ErrorCode    string            `json:"errorCode,omitempty"`
+	Options      *patch.Options    `json:"options,omitempty"`
+	Disabled     bool              `json:"disabled,omitempty"`
+}
+
+// coder is implemented by errors that carry a machine readable code.
+type coder interface {
+	Code() string
+}
+
+// LoadFile reads a spec file and resolves the file references of every spec
+// in it.
+func LoadFile(filename string) ([]Spec, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var specs []Spec
+	if err := json.Unmarshal(b, &specs); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	dir := filepath.Dir(filename)
+	for i := range specs {
+		if err := specs[i].resolve(dir); err != nil {
+			return nil, fmt.Errorf("%s: spec %d: %v", filename, i, err)
+		}
+	}
+	return specs, nil
+}
+
+func (s *Spec) resolve(dir string) error {
+	refs := []struct {
+		file string
+		dest interface{}
+	}{
+		{s.DocFile, &s.Doc},
+		{s.PatchFile, &s.Patch},
+		{s.ExpectedFile, &s.Expected},
+	}
+	for _, ref := range refs {
+		if ref.file == "" {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, ref.file))
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(b, ref.dest); err != nil {
+			return fmt.Errorf("%s: %v", ref.file, err)
+		}
+	}
+	return nil
+}
+
+// Check applies the spec's patch and returns an error describing how the
+// outcome differed from the expectation, or nil if the spec passed. opts
+// are applied on top of the spec's Options.
+func Check(spec Spec, opts ...patch.Option) error {
+	doc := spec.Doc
+	if doc == nil {
+		doc = make(map[string]interface{})
+	}
+
+	var options patch.Options
+	if spec.Options != nil {
+		options = *spec.Options
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	result, _, err := patch.ApplyWithReport(doc, spec.Patch, patch.WithOptions(options))
+
+	expectError := spec.Error != "" || spec.ErrorCode != ""
+	switch {
+	case err == nil && expectError:
+		return fmt.Errorf("expected error %s%s", spec.Error, spec.ErrorCode)
+	case err != nil && !expectError:
+		return fmt.Errorf("unexpected error %v", err)
+	case err != nil:
+		if spec.ErrorCode == "" {
+			return nil
+		}
+		var c coder
+		if !errors.As(err, &c) {
+			return fmt.Errorf("expected error with code %s, got %v", spec.ErrorCode, err)
+		}
+		if c.Code() != spec.ErrorCode {
+			return fmt.Errorf("expected error with code %s, got %s (%v)", spec.ErrorCode, c.Code(), err)
+		}
+		return nil
+	case spec.Expected != nil && !reflect.DeepEqual(result, spec.Expected):
+		return fmt.Errorf("expected %v to equal %v", result, spec.Expected)
+	}
+	return nil
+}
+
+// Run checks every enabled spec as a subtest of t, with opts as for
+// Check.
+func Run(t *testing.T, specs []Spec, opts ...patch.Option) {
+	t.Helper()
+	for i, spec := range specs {
+		spec := spec
+		t.Run(fmt.Sprintf("%d %s", i, spec.Comment), func(t *testing.T) {
+			if spec.Disabled {
+				t.Skip("disabled")
+			}
+			if err := Check(spec, opts...); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// RunFile loads a spec file and runs it with Run.
+func RunFile(t *testing.T, filename string, opts ...patch.Option) {
+	t.Helper()
+	specs, err := LoadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Run(t, specs, opts...)
+}
+
+// RunDir runs every spec file in dir, the ".json" files holding an array
+// of specs, each as a subtest of t named after the file. It runs a
+// checkout of the json-patch-tests suite as it is, and skips the other
+// files, such as the documents and patches referenced by the specs.
+func RunDir(t *testing.T, dir string, opts ...patch.Option) {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran := false
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isSpecFile(b) {
+			continue
+		}
+		ran = true
+		t.Run(filepath.Base(f), func(t *testing.T) {
+			RunFile(t, f, opts...)
+		})
+	}
+	if !ran {
+		t.Fatalf("no spec files in %s", dir)
+	}
+}
+
+// isSpecFile reports whether data is an array of specs rather than, for
+// example, a patch, whose operations have an "op" member.
+func isSpecFile(data []byte) bool {
+	var specs []map[string]json.RawMessage
+	if json.Unmarshal(data, &specs) != nil {
+		return false
+	}
+	for _, s := range specs {
+		if _, ok := s["op"]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// RunTAP checks every spec like Run, but writes the results to w in the
+// Test Anything Protocol, version 13, for harnesses outside of go test. A
+// failing spec is followed by a YAML block with its error, and disabled
+// specs are reported as skipped. It returns the number of failed specs.
+func RunTAP(w io.Writer, specs []Spec, opts ...patch.Option) (int, error) {
+	failed := 0
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "TAP version 13\n1..%d\n", len(specs))
+	for i, spec := range specs {
+		desc := strings.ReplaceAll(spec.Comment, "#", "\\#")
+		if desc != "" {
+			desc = " - " + strings.Join(strings.Fields(desc), " ")
+		}
+		if spec.Disabled {
+			fmt.Fprintf(bw, "ok %d%s # SKIP disabled\n", i+1, desc)
+			continue
+		}
+		err := Check(spec, opts...)
+		if err == nil {
+			fmt.Fprintf(bw, "ok %d%s\n", i+1, desc)
+			continue
+		}
+		failed++
+		msg, _ := json.Marshal(err.Error())
+		fmt.Fprintf(bw, "not ok %d%s\n  ---\n  message: %s\n  ...\n", i+1, desc, msg)
+	}
+	return failed, bw.Flush()
+}
+
+// UpdateGolden makes Golden write the files it compares with instead of
+// comparing them. It is set when the PATCHTEST_UPDATE environment variable
+// is, so that the files of a package can be regenerated with
+//
+//	PATCHTEST_UPDATE=1 go test
+var UpdateGolden = os.Getenv("PATCHTEST_UPDATE") != ""
+
+// Golden fails t unless got, encoded as indented JSON, matches the golden
+// file filename. Values are compared as JSON, so that files edited by hand
+// need not be formatted the same way.
+func Golden(t *testing.T, filename string, got interface{}) {
+	t.Helper()
+	data, err := json.MarshalIndent(got, "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if UpdateGolden {
+		if err := os.MkdirAll(filepath.Dir(filename), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, append(data, '\n'), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("%v (set PATCHTEST_UPDATE=1 to create it)", err)
+	}
+	var expected, actual interface{}
+	if err := json.Unmarshal(b, &expected); err != nil {
+		t.Fatalf("%s: %v", filename, err)
+	}
+	json.Unmarshal(data, &actual)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("%s: expected\n%s\ngot\n%s", filename, bytes.TrimSpace(b), data)
+	}
+}
@@ -0,0 +1,67 @@
+package patchtest
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	patch "github.com/grncdr/json-patch"
+)
+
+func TestRunFile(t *testing.T) {
+	RunFile(t, "testdata/specs.json")
+}
+
+func TestCheckReportsMismatches(t *testing.T) {
+	ops := []patch.Operation{{Op: "add", Path: "/a", Value: []byte(`1`)}}
+	err := Check(Spec{Patch: ops, Expected: map[string]interface{}{"a": 2.0}})
+	if err == nil || !strings.Contains(err.Error(), "expected") {
+		t.Errorf("expected a mismatch, got %v", err)
+	}
+	err = Check(Spec{Patch: ops, Error: "should fail"})
+	if err == nil {
+		t.Error("expected a missing error to be reported")
+	}
+	err = Check(Spec{Patch: []patch.Operation{{Op: "bogus"}}, ErrorCode: "coded"})
+	if err == nil || !strings.Contains(err.Error(), "code") {
+		t.Errorf("expected an uncoded error to be reported, got %v", err)
+	}
+}
+
+func TestRunDir(t *testing.T) {
+	RunDir(t, "testdata")
+	if isSpecFile([]byte(`[{"op": "remove", "path": "/a"}]`)) || isSpecFile([]byte(`{}`)) || !isSpecFile([]byte(`[{"patch": []}]`)) {
+		t.Error("unexpected spec file detection")
+	}
+}
+
+func TestRunTAP(t *testing.T) {
+	specs := []Spec{
+		{Comment: "passes", Patch: []patch.Operation{{Op: "add", Path: "/a", Value: []byte(`1`)}}},
+		{Comment: "fails # badly", Patch: []patch.Operation{{Op: "remove", Path: "/a"}}},
+		{Comment: "custom", Patch: []patch.Operation{{Op: "touch", Path: "/a"}}},
+		{Comment: "off", Disabled: true},
+	}
+	touch := patch.WithOperator("touch", func(doc interface{}, op patch.Operation, _ patch.Target, _ interface{}) (interface{}, error) {
+		return doc, nil
+	})
+	var buf strings.Builder
+	failed, err := RunTAP(&buf, specs, touch)
+	expected := "TAP version 13\n1..4\nok 1 - passes\nnot ok 2 - fails \\# badly\n  ---\n  message: \"unexpected error operation 0 (remove /a): member \\\"a\\\": value not found\"\n  ...\nok 3 - custom\nok 4 - off # SKIP disabled\n"
+	if err != nil || failed != 1 || buf.String() != expected {
+		t.Errorf("expected 1 failure and\n%s\ngot %d, %v and\n%s", expected, failed, err, buf.String())
+	}
+}
+
+func TestGolden(t *testing.T) {
+	dir := t.TempDir()
+	file := dir + "/out.json"
+	UpdateGolden = true
+	Golden(t, file, map[string]interface{}{"b": []int{1, 2}, "a": "x"})
+	UpdateGolden = false
+	if err := os.WriteFile(file+".edited", []byte(`{"a":"x","b":[1,2]}`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	Golden(t, file, map[string]interface{}{"a": "x", "b": []float64{1, 2}})
+	Golden(t, file+".edited", map[string]interface{}{"a": "x", "b": []int{1, 2}})
+}
@@ -0,0 +1,153 @@
+package patchtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	patch "github.com/grncdr/json-patch"
+)
+
+// Faults are the failures Replay injects while delivering a patch log, each
+// given as the probability, between 0 and 1, of it happening to a delivery.
+type Faults struct {
+	// Seed seeds the random choices, so that a failing replay can be
+	// reproduced.
+	Seed int64 `json:"seed,omitempty"`
+	// Fail inserts an operation that always fails at a random position of
+	// the patch, which a consumer must reject as a whole.
+	Fail float64 `json:"fail,omitempty"`
+	// Truncate cuts the encoded patch short, as a broken connection would.
+	Truncate float64 `json:"truncate,omitempty"`
+	// Reorder delivers the patch after the next one.
+	Reorder float64 `json:"reorder,omitempty"`
+	// Duplicate delivers the patch twice.
+	Duplicate float64 `json:"duplicate,omitempty"`
+}
+
+// Consumer is the patch-consuming pipeline under test.
+type Consumer interface {
+	// Deliver hands the consumer the encoded patch with sequence number
+	// seq in the log, which starts at 1. The patch may be damaged, out of
+	// order or already delivered; an error reports it was not applied.
+	Deliver(seq int, patch []byte) error
+	// Doc returns the consumer's current document.
+	Doc() interface{}
+}
+
+// Delivery is a patch handed to the consumer by Replay.
+type Delivery struct {
+	Seq   int
+	Patch []byte
+	// Faults lists the faults injected into this delivery: "fail",
+	// "truncate", "reorder" and "duplicate", or "resend" for the clean
+	// copies delivered once the faulty stream is over.
+	Faults []string
+	// Err is the error returned by Consumer.Deliver.
+	Err error
+}
+
+// ReplayResult describes a replay.
+type ReplayResult struct {
+	Deliveries []Delivery
+	// Expected is the document the log produces when applied cleanly, and
+	// Got the consumer's document at the end of the replay.
+	Expected, Got interface{}
+}
+
+// Replay delivers log, a sequence of patches applied to doc, to c with the
+// faults f injected, and then delivers every patch again in order and
+// undamaged, as a producer retransmitting what it cannot confirm was
+// received would. A consumer that recovers from the faults, rejecting the
+// damaged patches and ignoring those it already applied, ends up with the
+// document the log produces; Replay returns an error otherwise.
+func Replay(doc interface{}, log [][]patch.Operation, c Consumer, f Faults) (*ReplayResult, error) {
+	res := &ReplayResult{Expected: doc}
+	clean := make([][]byte, len(log))
+	for i, ops := range log {
+		var err error
+		if res.Expected, err = patch.Apply(res.Expected, ops); err != nil {
+			return nil, fmt.Errorf("patch %d of the log: %w", i+1, err)
+		}
+		if clean[i], err = json.Marshal(ops); err != nil {
+			return nil, err
+		}
+	}
+
+	r := rand.New(rand.NewSource(f.Seed))
+	var schedule []Delivery
+	for i, ops := range log {
+		d := Delivery{Seq: i + 1, Patch: clean[i]}
+		if r.Float64() < f.Fail {
+			failing := patch.Operation{Op: "test", Path: "", Value: json.RawMessage(`"patchtest: injected failure"`)}
+			at := r.Intn(len(ops) + 1)
+			damaged := append(append(append([]patch.Operation{}, ops[:at]...), failing), ops[at:]...)
+			d.Patch, _ = json.Marshal(damaged)
+			d.Faults = append(d.Faults, "fail")
+		}
+		if r.Float64() < f.Truncate {
+			d.Patch = d.Patch[:r.Intn(len(d.Patch))]
+			d.Faults = append(d.Faults, "truncate")
+		}
+		schedule = append(schedule, d)
+		if r.Float64() < f.Duplicate {
+			dup := d
+			dup.Faults = append(d.Faults[:len(d.Faults):len(d.Faults)], "duplicate")
+			schedule = append(schedule, dup)
+		}
+	}
+	for i := 0; i+1 < len(schedule); i++ {
+		if r.Float64() < f.Reorder {
+			schedule[i].Faults = append(schedule[i].Faults, "reorder")
+			schedule[i], schedule[i+1] = schedule[i+1], schedule[i]
+			i++
+		}
+	}
+	for i := range clean {
+		schedule = append(schedule, Delivery{Seq: i + 1, Patch: clean[i], Faults: []string{"resend"}})
+	}
+
+	for _, d := range schedule {
+		d.Err = c.Deliver(d.Seq, d.Patch)
+		res.Deliveries = append(res.Deliveries, d)
+	}
+	res.Got = c.Doc()
+
+	expected, err := json.Marshal(res.Expected)
+	if err != nil {
+		return nil, err
+	}
+	got, err := json.Marshal(res.Got)
+	if err != nil {
+		return res, fmt.Errorf("consumer document: %w", err)
+	}
+	if !bytes.Equal(got, expected) {
+		return res, fmt.Errorf("consumer diverged: expected %s, got %s", expected, got)
+	}
+	return res, nil
+}
+
+// RunReplay replays log to a new consumer in each of runs subtests of t,
+// with f.Seed incremented for every run, and fails those whose consumer
+// does not recover. The deliveries of a failed run are logged.
+func RunReplay(t *testing.T, doc interface{}, log [][]patch.Operation, newConsumer func() Consumer, f Faults, runs int) {
+	t.Helper()
+	for i := 0; i < runs; i++ {
+		faults := f
+		faults.Seed += int64(i)
+		t.Run(fmt.Sprintf("seed %d", faults.Seed), func(t *testing.T) {
+			res, err := Replay(doc, log, newConsumer(), faults)
+			if err == nil {
+				return
+			}
+			if res != nil {
+				for _, d := range res.Deliveries {
+					t.Logf("patch %d %v: %s: %v", d.Seq, d.Faults, d.Patch, d.Err)
+				}
+			}
+			t.Error(err)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package patchtest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	patch "github.com/grncdr/json-patch"
+)
+
+// sequenced applies patches atomically, in order and once each.
+type sequenced struct {
+	doc     interface{}
+	applied int
+}
+
+func (c *sequenced) Deliver(seq int, data []byte) error {
+	if seq != c.applied+1 {
+		return errors.New("out of sequence")
+	}
+	ops, err := patch.Parse(data)
+	if err != nil {
+		return err
+	}
+	doc, err := patch.Apply(c.doc, ops)
+	if err != nil {
+		return err
+	}
+	c.doc, c.applied = doc, seq
+	return nil
+}
+
+func (c *sequenced) Doc() interface{} { return c.doc }
+
+// naive applies whatever it receives, in place.
+type naive struct{ doc interface{} }
+
+func (c *naive) Deliver(seq int, data []byte) error {
+	ops, err := patch.Parse(data)
+	if err != nil {
+		return err
+	}
+	c.doc, err = patch.ApplyUnsafe(c.doc, ops)
+	return err
+}
+
+func (c *naive) Doc() interface{} { return c.doc }
+
+func replayLog() [][]patch.Operation {
+	var log [][]patch.Operation
+	for _, p := range []string{
+		`[{"op": "add", "path": "/items", "value": []}]`,
+		`[{"op": "add", "path": "/items/-", "value": 1}, {"op": "add", "path": "/n", "value": 1}]`,
+		`[{"op": "add", "path": "/items/-", "value": 2}, {"op": "replace", "path": "/n", "value": 2}]`,
+		`[{"op": "remove", "path": "/items/0"}]`,
+		`[{"op": "add", "path": "/items/-", "value": 3}, {"op": "move", "from": "/n", "path": "/m"}]`,
+	} {
+		ops, err := patch.Parse([]byte(p))
+		if err != nil {
+			panic(err)
+		}
+		log = append(log, ops)
+	}
+	return log
+}
+
+func TestReplay(t *testing.T) {
+	faults := Faults{Fail: 0.3, Truncate: 0.3, Reorder: 0.3, Duplicate: 0.3}
+	RunReplay(t, map[string]interface{}{}, replayLog(), func() Consumer {
+		return &sequenced{doc: map[string]interface{}{}}
+	}, faults, 20)
+
+	res, err := Replay(map[string]interface{}{}, replayLog(), &sequenced{doc: map[string]interface{}{}}, Faults{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Deliveries) != 10 || res.Deliveries[5].Err == nil || !strings.Contains(string(res.Deliveries[5].Patch), "items") {
+		t.Errorf("expected a clean stream followed by rejected resends, got %+v", res.Deliveries)
+	}
+}
+
+func TestReplayDetectsDivergence(t *testing.T) {
+	diverged := 0
+	for seed := int64(0); seed < 10; seed++ {
+		faults := Faults{Seed: seed, Fail: 0.5, Duplicate: 0.5}
+		res, err := Replay(map[string]interface{}{}, replayLog(), &naive{doc: map[string]interface{}{}}, faults)
+		if err != nil {
+			if res == nil || !strings.Contains(err.Error(), "diverged") {
+				t.Fatalf("unexpected error %v", err)
+			}
+			diverged++
+		}
+	}
+	if diverged == 0 {
+		t.Error("expected a naive consumer to diverge")
+	}
+
+	bad := [][]patch.Operation{{{Op: "remove", Path: "/missing"}}}
+	if _, err := Replay(map[string]interface{}{}, bad, &naive{}, Faults{}); err == nil || !strings.Contains(err.Error(), "patch 1 of the log") {
+		t.Errorf("expected a log that does not apply to be reported, got %v", err)
+	}
+}
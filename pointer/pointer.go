@@ -0,0 +1,260 @@
+// Package pointer implements JSON Pointers as described by RFC 6901.
+package pointer
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// Token is a single reference token of a JSON pointer, in its escaped form.
+type Token string
+
+// String returns the unescaped token. It only allocates when the token
+// contains an escape sequence.
+func (t Token) String() string {
+	s := string(t)
+	if strings.IndexByte(s, '~') < 0 {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '~' && i+1 < len(s) {
+			switch s[i+1] {
+			case '0':
+				b.WriteByte('~')
+				i++
+				continue
+			case '1':
+				b.WriteByte('/')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Tokens iterates over the escaped reference tokens of ptr. The tokens are
+// substrings of ptr, so iterating does not allocate. Anything before the
+// first '/' is ignored, and the empty pointer yields no tokens.
+func Tokens(ptr string) iter.Seq[Token] {
+	return func(yield func(Token) bool) {
+		i := strings.IndexByte(ptr, '/')
+		if i < 0 {
+			return
+		}
+		rest := ptr[i+1:]
+		for {
+			j := strings.IndexByte(rest, '/')
+			if j < 0 {
+				yield(Token(rest))
+				return
+			}
+			if !yield(Token(rest[:j])) {
+				return
+			}
+			rest = rest[j+1:]
+		}
+	}
+}
+
+// ErrNotFound is returned (wrapped) when a pointer does not resolve to a
+// value in a document.
+var ErrNotFound = errors.New("value not found")
+
+// Object is implemented by representations of JSON objects other than
+// map[string]interface{}, so that Get can look up their members.
+type Object interface {
+	Get(name string) (interface{}, bool)
+}
+
+// Pointer is a parsed JSON pointer: the sequence of its unescaped reference
+// tokens. The empty Pointer refers to the whole document.
+type Pointer []string
+
+// Parse parses the string representation of a JSON pointer.
+func Parse(s string) (Pointer, error) {
+	if s != "" && s[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must be empty or start with '/'", s)
+	}
+	p := make(Pointer, 0, strings.Count(s, "/"))
+	for t := range Tokens(s) {
+		p = append(p, t.String())
+	}
+	return p, nil
+}
+
+// New returns the pointer made of the given unescaped tokens.
+func New(tokens ...string) Pointer {
+	return Pointer(tokens)
+}
+
+// Escape encodes s as a reference token, replacing '~' with "~0" and '/'
+// with "~1".
+func Escape(s string) string {
+	if !strings.ContainsAny(s, "~/") {
+		return s
+	}
+	return strings.Replace(strings.Replace(s, "~", "~0", -1), "/", "~1", -1)
+}
+
+// String returns the pointer in its escaped string representation.
+func (p Pointer) String() string {
+	var b strings.Builder
+	for _, t := range p {
+		b.WriteByte('/')
+		b.WriteString(Escape(t))
+	}
+	return b.String()
+}
+
+// Get returns the value p refers to in doc.
+func (p Pointer) Get(doc interface{}) (interface{}, error) {
+	current := doc
+	for i, key := range p {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			v, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("%s: %w", p[:i+1], ErrNotFound)
+			}
+			current = v
+		case Object:
+			v, ok := node.Get(key)
+			if !ok {
+				return nil, fmt.Errorf("%s: %w", p[:i+1], ErrNotFound)
+			}
+			current = v
+		case []interface{}:
+			j, err := ParseIndex(key, len(node)-1, false)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", p[:i+1], err)
+			}
+			current = node[j]
+		default:
+			return nil, fmt.Errorf("%s: cannot index a %T", p[:i+1], current)
+		}
+	}
+	return current, nil
+}
+
+// Set stores v at the location p refers to and returns the updated
+// document, which differs from doc when p is empty or an array at the top
+// level grows. Object members are created or replaced; array elements are
+// replaced, except that the index "-" or one past the last element appends.
+// Every container on the way to the final token must already exist.
+func (p Pointer) Set(doc, v interface{}) (interface{}, error) {
+	if len(p) == 0 {
+		return v, nil
+	}
+	return p.update(doc, 0, func(parent interface{}, key string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[key] = v
+			return node, nil
+		case []interface{}:
+			i, err := ParseIndex(key, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			if i == len(node) {
+				return append(node, v), nil
+			}
+			node[i] = v
+			return node, nil
+		}
+		return nil, fmt.Errorf("cannot set %q in a %T", key, parent)
+	})
+}
+
+// Delete removes the value p refers to and returns the updated document.
+// Deleting the whole document returns nil.
+func (p Pointer) Delete(doc interface{}) (interface{}, error) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+	return p.update(doc, 0, func(parent interface{}, key string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := node[key]; !ok {
+				return nil, ErrNotFound
+			}
+			delete(node, key)
+			return node, nil
+		case []interface{}:
+			i, err := ParseIndex(key, len(node)-1, false)
+			if err != nil {
+				return nil, err
+			}
+			return append(node[:i], node[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("cannot delete %q from a %T", key, parent)
+	})
+}
+
+// update descends to the parent of the final token and replaces every
+// container on the way with the result of fn, so that slices may grow or
+// shrink.
+func (p Pointer) update(node interface{}, depth int, fn func(interface{}, string) (interface{}, error)) (interface{}, error) {
+	key := p[depth]
+	if depth == len(p)-1 {
+		out, err := fn(node, key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		return out, nil
+	}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[key]
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", p[:depth+1], ErrNotFound)
+		}
+		child, err := p.update(child, depth+1, fn)
+		if err != nil {
+			return nil, err
+		}
+		n[key] = child
+		return n, nil
+	case []interface{}:
+		i, err := ParseIndex(key, len(n)-1, false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p[:depth+1], err)
+		}
+		child, err := p.update(n[i], depth+1, fn)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = child
+		return n, nil
+	}
+	return nil, fmt.Errorf("%s: cannot index a %T", p[:depth+1], node)
+}
+
+// ParseIndex parses an array index token, which must be a non-negative
+// decimal integer without leading zeros no greater than max. When allowDash
+// is true the token "-" is accepted and refers to max.
+func ParseIndex(s string, max int, allowDash bool) (int, error) {
+	if allowDash && s == "-" {
+		return max, nil
+	}
+	if s == "" || len(s) > 1 && s[0] == '0' {
+		return -1, fmt.Errorf("invalid array index %q", s)
+	}
+	i := 0
+	for j := 0; j < len(s); j++ {
+		c := s[j]
+		if c < '0' || c > '9' {
+			return -1, fmt.Errorf("invalid array index %q", s)
+		}
+		i = i*10 + int(c-'0')
+		if i > max {
+			return -1, fmt.Errorf("array index %s out of bounds", s)
+		}
+	}
+	return i, nil
+}
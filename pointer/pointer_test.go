@@ -0,0 +1,154 @@
+package pointer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func collect(ptr string) []string {
+	out := []string{}
+	for t := range Tokens(ptr) {
+		out = append(out, t.String())
+	}
+	return out
+}
+
+func TestTokens(t *testing.T) {
+	cases := map[string][]string{
+		"":           {},
+		"/":          {""},
+		"/a/b":       {"a", "b"},
+		"/a~1b/c~0d": {"a/b", "c~d"},
+		"/~01":       {"~1"},
+		"/a//b/":     {"a", "", "b", ""},
+	}
+	for ptr, expected := range cases {
+		if got := collect(ptr); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%q: expected %q, got %q", ptr, expected, got)
+		}
+	}
+}
+
+func TestTokensDoNotAllocate(t *testing.T) {
+	n := 0
+	allocs := testing.AllocsPerRun(100, func() {
+		for tok := range Tokens("/foo/bar/0/baz") {
+			n += len(tok.String())
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
+
+func doc() interface{} {
+	return map[string]interface{}{
+		"a/b": map[string]interface{}{"c~d": "x"},
+		"arr": []interface{}{1.0, 2.0},
+	}
+}
+
+func TestParseAndString(t *testing.T) {
+	for _, s := range []string{"", "/", "/a~1b/c~0d", "/arr/0"} {
+		p, err := Parse(s)
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+			continue
+		}
+		if p.String() != s {
+			t.Errorf("expected %q to round trip, got %q", s, p.String())
+		}
+	}
+	if _, err := Parse("a/b"); err == nil {
+		t.Error("expected a pointer without a leading slash to be rejected")
+	}
+	if got := New("a/b", "c~d").String(); got != "/a~1b/c~0d" {
+		t.Errorf("unexpected escaping %q", got)
+	}
+}
+
+func TestGet(t *testing.T) {
+	p, _ := Parse("/a~1b/c~0d")
+	if v, err := p.Get(doc()); err != nil || v != "x" {
+		t.Errorf("expected x, got %v, %v", v, err)
+	}
+	for _, s := range []string{"/missing", "/arr/2", "/arr/01", "/arr/-", "/arr/0/x"} {
+		p, _ := Parse(s)
+		if _, err := p.Get(doc()); err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+	p, _ = Parse("/missing")
+	if _, err := p.Get(doc()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSetAndDelete(t *testing.T) {
+	d := doc()
+	var err error
+	for _, step := range []struct {
+		ptr string
+		v   interface{}
+	}{
+		{"/arr/-", 3.0},
+		{"/arr/0", 0.0},
+		{"/new", true},
+	} {
+		p, _ := Parse(step.ptr)
+		if d, err = p.Set(d, step.v); err != nil {
+			t.Fatalf("%s: %v", step.ptr, err)
+		}
+	}
+	p, _ := Parse("/a~1b")
+	if d, err = p.Delete(d); err != nil {
+		t.Fatal(err)
+	}
+	p, _ = Parse("/arr/1")
+	if d, err = p.Delete(d); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"arr": []interface{}{0.0, 3.0},
+		"new": true,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Errorf("expected %v, got %v", expected, d)
+	}
+	p, _ = Parse("/missing/x")
+	if _, err := p.Set(d, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := p.Delete(d); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestParseIndex(t *testing.T) {
+	cases := []struct {
+		s     string
+		max   int
+		dash  bool
+		index int
+		ok    bool
+	}{
+		{"0", 0, false, 0, true},
+		{"10", 10, false, 10, true},
+		{"11", 10, false, 0, false},
+		{"-", 3, true, 3, true},
+		{"-", 3, false, 0, false},
+		{"01", 3, false, 0, false},
+		{"+1", 3, false, 0, false},
+		{"-1", 3, false, 0, false},
+		{"1e0", 3, false, 0, false},
+		{"", 3, false, 0, false},
+		{"99999999999999999999999", 3, false, 0, false},
+	}
+	for _, c := range cases {
+		i, err := ParseIndex(c.s, c.max, c.dash)
+		if (err == nil) != c.ok || c.ok && i != c.index {
+			t.Errorf("ParseIndex(%q, %d, %v) = %d, %v", c.s, c.max, c.dash, i, err)
+		}
+	}
+}
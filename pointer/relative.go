@@ -0,0 +1,155 @@
+package pointer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Relative is a Relative JSON Pointer (draft-bhutton-relative-json-pointer):
+// a location given from another one by going up a number of levels,
+// optionally moving to another element of the same array, and then either
+// following a JSON pointer or, with "#", taking the member name or array
+// index reached. "0/sibling" is a member of the current location, "1/x" a
+// member of its parent and "0+1" the next element of the same array.
+type Relative struct {
+	// Up is the number of levels to go up from the current location.
+	Up int
+	// Shift is added to the array index of the location reached by going
+	// up, which must then be an array element.
+	Shift int
+	// Pointer is followed from the location reached, unless Name is set.
+	Pointer Pointer
+	// Name makes the pointer refer to the member name or array index of
+	// the location reached instead of its value.
+	Name bool
+}
+
+// ParseRelative parses the string representation of a Relative JSON
+// Pointer.
+func ParseRelative(s string) (Relative, error) {
+	invalid := fmt.Errorf("invalid relative JSON pointer %q", s)
+	up, rest, ok := leadingInt(s)
+	if !ok {
+		return Relative{}, invalid
+	}
+	r := Relative{Up: up}
+	if rest != "" && (rest[0] == '+' || rest[0] == '-') {
+		shift, after, ok := leadingInt(rest[1:])
+		if !ok {
+			return Relative{}, invalid
+		}
+		if rest[0] == '-' {
+			shift = -shift
+		}
+		r.Shift, rest = shift, after
+	}
+	if rest == "#" {
+		r.Name = true
+		return r, nil
+	}
+	if rest != "" && rest[0] != '/' {
+		return Relative{}, invalid
+	}
+	p, err := Parse(rest)
+	if err != nil {
+		return Relative{}, invalid
+	}
+	r.Pointer = p
+	return r, nil
+}
+
+// leadingInt parses the non-negative integer at the start of s, which may
+// not have leading zeros, and returns it with the rest of s.
+func leadingInt(s string) (int, string, bool) {
+	n := 0
+	for n < len(s) && '0' <= s[n] && s[n] <= '9' {
+		n++
+	}
+	if n == 0 || n > 1 && s[0] == '0' {
+		return 0, s, false
+	}
+	i, err := strconv.Atoi(s[:n])
+	if err != nil {
+		return 0, s, false
+	}
+	return i, s[n:], true
+}
+
+// String returns the relative pointer in its string representation.
+func (r Relative) String() string {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(r.Up))
+	if r.Shift > 0 {
+		b.WriteByte('+')
+	}
+	if r.Shift != 0 {
+		b.WriteString(strconv.Itoa(r.Shift))
+	}
+	if r.Name {
+		b.WriteByte('#')
+	} else {
+		b.WriteString(r.Pointer.String())
+	}
+	return b.String()
+}
+
+// Resolve returns the absolute pointer to the location r refers to from the
+// location base in doc. When r.Name is set, that is the location whose name
+// r refers to.
+func (r Relative) Resolve(doc interface{}, base Pointer) (Pointer, error) {
+	if r.Up > len(base) {
+		return nil, fmt.Errorf("%s: cannot go up %d levels from %s", r, r.Up, base)
+	}
+	loc := append(Pointer{}, base[:len(base)-r.Up]...)
+	if r.Shift != 0 {
+		n := len(loc)
+		if n == 0 {
+			return nil, fmt.Errorf("%s: the whole document is not an array element", r)
+		}
+		parent, err := loc[:n-1].Get(doc)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := parent.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: %s is not an array element", r, loc)
+		}
+		i, err := ParseIndex(loc[n-1], len(s)-1, false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", loc, err)
+		}
+		if i += r.Shift; i < 0 || i >= len(s) {
+			return nil, fmt.Errorf("%s: array index %d out of bounds: %w", r, i, ErrNotFound)
+		}
+		loc[n-1] = strconv.Itoa(i)
+	}
+	if r.Name {
+		if len(loc) == 0 {
+			return nil, fmt.Errorf("%s: the whole document has no name", r)
+		}
+		return loc, nil
+	}
+	return append(loc, r.Pointer...), nil
+}
+
+// Get returns the value r refers to from the location base in doc. When
+// r.Name is set, that is a member name as a string or an array index as an
+// int.
+func (r Relative) Get(doc interface{}, base Pointer) (interface{}, error) {
+	loc, err := r.Resolve(doc, base)
+	if err != nil {
+		return nil, err
+	}
+	if !r.Name {
+		return loc.Get(doc)
+	}
+	if _, err := loc.Get(doc); err != nil {
+		return nil, err
+	}
+	parent, _ := loc[:len(loc)-1].Get(doc)
+	if s, ok := parent.([]interface{}); ok {
+		return ParseIndex(loc[len(loc)-1], len(s)-1, false)
+	}
+	return loc[len(loc)-1], nil
+}
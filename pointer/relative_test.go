@@ -0,0 +1,84 @@
+package pointer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// examples from the Relative JSON Pointer draft, section 5.1
+func TestRelativeGet(t *testing.T) {
+	doc := map[string]interface{}{
+		"foo":    []interface{}{"bar", "baz"},
+		"highly": map[string]interface{}{"nested": map[string]interface{}{"objects": true}},
+	}
+	cases := []struct {
+		base, rel string
+		expected  interface{}
+	}{
+		{"/foo/1", "0", "baz"},
+		{"/foo/1", "1/0", "bar"},
+		{"/foo/1", "0-1", "bar"},
+		{"/foo/1", "2/highly/nested/objects", true},
+		{"/foo/1", "0#", 1},
+		{"/foo/1", "0-1#", 0},
+		{"/foo/1", "1#", "foo"},
+		{"/highly/nested", "0/objects", true},
+		{"/highly/nested", "1/nested/objects", true},
+		{"/highly/nested", "2/foo/0", "bar"},
+		{"/highly/nested", "0#", "nested"},
+		{"/highly/nested", "1#", "highly"},
+	}
+	for _, c := range cases {
+		base, _ := Parse(c.base)
+		r, err := ParseRelative(c.rel)
+		if err != nil {
+			t.Errorf("%s: %v", c.rel, err)
+			continue
+		}
+		got, err := r.Get(doc, base)
+		if err != nil {
+			t.Errorf("%s from %s: %v", c.rel, c.base, err)
+		} else if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%s from %s: expected %v, got %v", c.rel, c.base, c.expected, got)
+		}
+	}
+
+	errs := []struct{ base, rel string }{
+		{"/foo/1", "3"},
+		{"/foo/1", "0+1"},
+		{"/foo/1", "2#"},
+		{"/highly/nested", "0+1"},
+		{"/foo/1", "0/missing"},
+	}
+	for _, c := range errs {
+		base, _ := Parse(c.base)
+		r, _ := ParseRelative(c.rel)
+		if _, err := r.Get(doc, base); err == nil {
+			t.Errorf("%s from %s: expected an error", c.rel, c.base)
+		}
+	}
+	base, _ := Parse("/foo/1")
+	r, _ := ParseRelative("0+1")
+	if _, err := r.Get(doc, base); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestParseRelative(t *testing.T) {
+	for _, s := range []string{"0", "1#", "0-1", "2+3/a~1b/c~0d", "10/0", "0/"} {
+		r, err := ParseRelative(s)
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+			continue
+		}
+		if r.String() != s {
+			t.Errorf("expected %q to round trip, got %q", s, r.String())
+		}
+	}
+	for _, s := range []string{"", "/a", "01", "a", "0#/a", "0+", "0+01", "0a", "-1", "0##"} {
+		if _, err := ParseRelative(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package patch
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// Policy restricts which operators a patch may apply to parts of the
+// document, for patches from clients that must not touch some members,
+// such as an "/id" or an append-only "/audit" log. Every rule constrains
+// the locations its pattern matches, and an operation must be allowed by
+// every rule it affects; locations no rule matches are unrestricted.
+//
+// A Policy is plain data and can be loaded from JSON along with the other
+// Options.
+type Policy struct {
+	Rules []PolicyRule `json:"rules"`
+}
+
+// PolicyRule lists the operators allowed at the locations Pattern matches.
+//
+// Pattern is a JSON pointer whose tokens may be "*" to match any token. It
+// matches its locations and everything below them, so "/audit/*" covers
+// every element of "/audit" and their contents; a last token "**" is
+// accepted for "*". A malformed pattern refuses every operation.
+//
+// An operation on a matched location, or below one, needs its operator in
+// Allow; for move, so does its "from". An operation above a matched
+// location replaces or removes it along with its parent, and needs
+// "replace" in Allow, or "remove" for remove and the "from" of move. Test
+// operations, which do not modify the document, are always allowed, as
+// are the "from" of copy operations.
+type PolicyRule struct {
+	Pattern string   `json:"pattern"`
+	Allow   []string `json:"allow,omitempty"`
+}
+
+// PolicyError is the error wrapped by the ForbiddenError of an operation
+// refused by Options.Policy.
+type PolicyError struct {
+	Pattern string // of the rule refusing the operation
+	Op      string // the operator the rule does not allow
+	Path    string // the location the operator applies to
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("policy rule %s does not allow %s at %s", e.Pattern, e.Op, e.Path)
+}
+
+// WithPolicy refuses the operations p does not allow. See Options.Policy.
+func WithPolicy(p *Policy) Option {
+	return func(o *Options) { o.Policy = p }
+}
+
+// check returns a PolicyError when a rule of p does not allow op to write
+// at path, or to remove from when it is a move.
+func (p *Policy) check(op string, path, from []string) error {
+	if op == "test" {
+		return nil
+	}
+	for _, r := range p.Rules {
+		pattern, err := pointer.Parse(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("policy rule %s: %w", r.Pattern, err)
+		}
+		if n := len(pattern); n > 0 && pattern[n-1] == "**" {
+			pattern[n-1] = "*"
+		}
+		whole := "replace"
+		if op == "remove" {
+			whole = "remove"
+		}
+		if err := r.allows(pattern, op, path, whole); err != nil {
+			return err
+		}
+		if op == "move" {
+			if err := r.allows(pattern, op, from, "remove"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// allows checks op writing at path against the rule, whose pattern is
+// parsed. Above the locations the pattern matches, op counts as whole.
+func (r *PolicyRule) allows(pattern pointer.Pointer, op string, path pointer.Pointer, whole string) error {
+	needed := op
+	if !matchPrefix(pattern, path) {
+		if !coversPattern(path, pattern) {
+			return nil
+		}
+		needed = whole
+	}
+	if slices.Contains(r.Allow, needed) {
+		return nil
+	}
+	return &PolicyError{Pattern: r.Pattern, Op: needed, Path: path.String()}
+}
+
+// coversPattern reports whether path is above some location pattern
+// matches: path is shorter, and each of its tokens is matched by the
+// pattern token at the same position.
+func coversPattern(path, pattern pointer.Pointer) bool {
+	if len(path) >= len(pattern) {
+		return false
+	}
+	for i, t := range path {
+		if pattern[i] != "*" && pattern[i] != t {
+			return false
+		}
+	}
+	return true
+}
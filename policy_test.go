@@ -0,0 +1,69 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	policy := &Policy{Rules: []PolicyRule{
+		{Pattern: "/id"},
+		{Pattern: "/audit/**", Allow: []string{"add"}},
+		{Pattern: "/users/*/role", Allow: []string{"replace"}},
+	}}
+	doc := `{"id": 1, "name": "a", "audit": [{"at": 1}], "users": [{"role": "x", "name": "u"}]}`
+	cases := []struct {
+		patch string
+		rule  string // of the rule refusing the patch, "" when allowed
+		op    string
+	}{
+		{`[{"op": "replace", "path": "/name", "value": "b"}]`, "", ""},
+		{`[{"op": "test", "path": "/id", "value": 1}]`, "", ""},
+		{`[{"op": "copy", "from": "/id", "path": "/copy"}]`, "", ""},
+		{`[{"op": "add", "path": "/audit/-", "value": {"at": 2}}]`, "", ""},
+		{`[{"op": "replace", "path": "/users/0/role", "value": "y"}]`, "", ""},
+		{`[{"op": "remove", "path": "/users/0/name"}]`, "", ""},
+		{`[{"op": "replace", "path": "/id", "value": 2}]`, "/id", "replace"},
+		{`[{"op": "remove", "path": "/id"}]`, "/id", "remove"},
+		{`[{"op": "move", "from": "/id", "path": "/old"}]`, "/id", "move"},
+		{`[{"op": "move", "from": "/audit", "path": "/old"}]`, "/audit/**", "remove"},
+		{`[{"op": "move", "from": "/name", "path": "/id"}]`, "/id", "move"},
+		{`[{"op": "replace", "path": "", "value": {}}]`, "/id", "replace"},
+		{`[{"op": "replace", "path": "/audit/0/at", "value": 0}]`, "/audit/**", "replace"},
+		{`[{"op": "remove", "path": "/audit/0"}]`, "/audit/**", "remove"},
+		{`[{"op": "add", "path": "/audit", "value": []}]`, "/audit/**", "replace"},
+		{`[{"op": "remove", "path": "/audit"}]`, "/audit/**", "remove"},
+		{`[{"op": "remove", "path": "/users/0/role"}]`, "/users/*/role", "remove"},
+		{`[{"op": "remove", "path": "/users/0"}]`, "/users/*/role", "remove"},
+	}
+	for _, c := range cases {
+		_, err := Apply(decode(doc), parseStr(c.patch), WithPolicy(policy))
+		if c.rule == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", c.patch, err)
+			}
+			continue
+		}
+		var pe *PolicyError
+		if !errors.Is(err, ErrForbidden) || !errors.As(err, &pe) || pe.Pattern != c.rule || pe.Op != c.op {
+			t.Errorf("%s: expected rule %s to refuse %s, got %v", c.patch, c.rule, c.op, err)
+		}
+	}
+
+	_, err := Apply(decode(doc), parseStr(`[{"op": "add", "path": "/x", "value": 1}]`), WithPolicy(&Policy{Rules: []PolicyRule{{Pattern: "x"}}}))
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("expected a malformed pattern to refuse every operation, got %v", err)
+	}
+}
+
+func TestPolicyJSON(t *testing.T) {
+	var o Options
+	if err := json.Unmarshal([]byte(`{"policy": {"rules": [{"pattern": "/id"}]}}`), &o); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Apply(decode(`{"id": 1}`), parseStr(`[{"op": "remove", "path": "/id"}]`), WithOptions(o))
+	if err == nil || err.Error() != "operation 0 (remove /id): forbidden: policy rule /id does not allow remove at /id" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
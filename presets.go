@@ -0,0 +1,71 @@
+package patch
+
+// Presets bundle the options suited to a kind of integration. A preset sets
+// the fields it documents and leaves the others, such as Authorize or
+// Quota, alone; options given after it override its choices:
+//
+//	result, err := patch.Apply(doc, ops, patch.Hardened(), patch.WithAuthorize(ctx, rbac))
+
+// Strict applies patches exactly as RFC 6902 specifies, and refuses input
+// the RFCs leave undefined instead of guessing:
+//
+//   - strings that are not valid UTF-8, including unpaired surrogates, are
+//     rejected (UTF8Reject);
+//   - numbers are decoded as json.Number, so tests compare them exactly and
+//     large integers are stored without rounding (UseNumber);
+//   - moves within an array use the RFC 6902 index (MoveAfterRemove);
+//   - operations with an unknown operator are rejected (UnknownOpReject);
+//   - the extensions OpRefs, CreateMissingParents, ContinueOnError,
+//     OnErrorHints and relative test paths (Anchor) are turned off.
+func Strict() Option {
+	return func(o *Options) {
+		o.UTF8 = UTF8Reject
+		o.UseNumber = true
+		o.MoveIndex = MoveAfterRemove
+		o.UnknownOps = UnknownOpReject
+		o.OpRefs = false
+		o.CreateMissingParents = false
+		o.ContinueOnError = false
+		o.OnErrorHints = false
+		o.Anchor = ""
+	}
+}
+
+// Hardened is Strict for patches from untrusted clients. On top of Strict:
+//
+//   - the patch is applied to a copy, so a failing patch never leaves a
+//     half patched document behind (InPlace is turned off);
+//   - moves towards a higher index of the same array, whose result depends
+//     on how the producer computed the index, are rejected
+//     (MoveRejectAmbiguous);
+//   - patches are limited to 1000 operations, pointers to 32 tokens and
+//     values to 1 MiB (Limits). The size of the document is not limited,
+//     as it depends on the integration.
+func Hardened() Option {
+	strict := Strict()
+	return func(o *Options) {
+		strict(o)
+		o.InPlace = false
+		o.MoveIndex = MoveRejectAmbiguous
+		o.Limits = Limits{MaxOperations: 1000, MaxPointerDepth: 32, MaxValueBytes: 1 << 20}
+	}
+}
+
+// Lenient applies as much of a patch as makes sense, for best effort
+// synchronization with producers that are known to be sloppy:
+//
+//   - invalid UTF-8 is replaced with U+FFFD (UTF8Replace);
+//   - numbers are decoded as json.Number (UseNumber);
+//   - add operations create missing parent objects (CreateMissingParents);
+//   - operations with an unknown operator are skipped (UnknownOpSkip);
+//   - failing operations are skipped and reported together
+//     (ContinueOnError).
+func Lenient() Option {
+	return func(o *Options) {
+		o.UTF8 = UTF8Replace
+		o.UseNumber = true
+		o.CreateMissingParents = true
+		o.UnknownOps = UnknownOpSkip
+		o.ContinueOnError = true
+	}
+}
@@ -0,0 +1,56 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	move := parseStr(`[{"op": "move", "from": "/list/0", "path": "/list/2"}]`)
+	doc := decode(`{"list": ["a", "b", "c"]}`)
+	if _, err := Apply(doc, move, Strict()); err != nil {
+		t.Errorf("strict: %v", err)
+	}
+	if _, err := Apply(doc, move, Hardened()); !errors.Is(err, ErrAmbiguousMove) {
+		t.Errorf("hardened: expected an ambiguous move, got %v", err)
+	}
+
+	invalid := []Operation{{Op: "add", Path: "/s", Value: []byte(`"\ud800"`)}}
+	if _, err := Apply(doc, invalid, Strict()); err == nil {
+		t.Error("strict: expected invalid UTF-8 to be rejected")
+	}
+	if _, err := Apply(doc, invalid, Lenient()); err != nil {
+		t.Errorf("lenient: %v", err)
+	}
+
+	sloppy := parseStr(`[
+		{"op": "add", "path": "/a/b", "value": 1},
+		{"op": "upsert", "path": "/c", "value": 1},
+		{"op": "remove", "path": "/missing"},
+		{"op": "add", "path": "/n", "value": 12345678901234567890}
+	]`)
+	result, err := Apply(decode(`{}`), sloppy, Lenient())
+	if err == nil {
+		t.Error("lenient: expected the failed operation to be reported")
+	}
+	if data, _ := marshal(result); string(data) != `{"a":{"b":1},"n":12345678901234567890}` {
+		t.Errorf("lenient: unexpected result %s", data)
+	}
+	if _, err := Apply(decode(`{}`), sloppy, Strict()); err == nil {
+		t.Error("strict: expected the patch to fail")
+	}
+
+	// options after a preset override it, and those before it are kept
+	// unless the preset sets them
+	o := newOptions([]Option{WithOpRefs(), WithInPlace(), Strict(), WithCreateMissingParents()})
+	if o.OpRefs || !o.InPlace || !o.CreateMissingParents || o.UTF8 != UTF8Reject {
+		t.Errorf("unexpected options %+v", o)
+	}
+	o = newOptions([]Option{WithInPlace(), Hardened()})
+	expected := &Options{UTF8: UTF8Reject, UseNumber: true, MoveIndex: MoveRejectAmbiguous,
+		Limits: Limits{MaxOperations: 1000, MaxPointerDepth: 32, MaxValueBytes: 1 << 20}}
+	if !reflect.DeepEqual(o, expected) {
+		t.Errorf("expected %+v, got %+v", expected, o)
+	}
+}
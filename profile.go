@@ -0,0 +1,71 @@
+package patch
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// PointerStat describes the subtree of a document rooted at Pointer.
+type PointerStat struct {
+	Pointer string
+	// Size is the length in bytes of the subtree encoded as compact JSON.
+	Size int64
+	// Nodes is the number of values in the subtree, including its root.
+	Nodes int
+}
+
+// Profile returns a PointerStat for every object and array of doc, largest
+// first, to find the parts of a document that make it expensive to copy and
+// patch. Ties are ordered by node count and then by pointer. Scalars are
+// only accounted for in the containers holding them, and those that cannot
+// be encoded as JSON count for no bytes.
+func Profile(doc interface{}) []PointerStat {
+	p := &profiler{}
+	p.walk(doc, nil)
+	sort.Slice(p.stats, func(i, j int) bool {
+		a, b := p.stats[i], p.stats[j]
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		if a.Nodes != b.Nodes {
+			return a.Nodes > b.Nodes
+		}
+		return a.Pointer < b.Pointer
+	})
+	return p.stats
+}
+
+type profiler struct {
+	stats []PointerStat
+}
+
+// walk returns the size and node count of v, found at path, and records
+// them if v is a container.
+func (p *profiler) walk(v interface{}, path pointer.Pointer) (int64, int) {
+	var size int64
+	nodes := 1
+	switch v := v.(type) {
+	case map[string]interface{}:
+		size = 2 + int64(max(len(v)-1, 0)) // braces and commas
+		for k, child := range v {
+			key, _ := marshal(k)
+			s, n := p.walk(child, append(path[:len(path):len(path)], k))
+			size += int64(len(key)) + 1 + s
+			nodes += n
+		}
+	case []interface{}:
+		size = 2 + int64(max(len(v)-1, 0)) // brackets and commas
+		for i, child := range v {
+			s, n := p.walk(child, append(path[:len(path):len(path)], strconv.Itoa(i)))
+			size += s
+			nodes += n
+		}
+	default:
+		b, _ := marshal(v)
+		return int64(len(b)), 1
+	}
+	p.stats = append(p.stats, PointerStat{Pointer: path.String(), Size: size, Nodes: nodes})
+	return size, nodes
+}
@@ -0,0 +1,42 @@
+package patch
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+func TestProfile(t *testing.T) {
+	doc := decode(`{"meta": {"name": "x<y"}, "items": [{"id": 1, "tags": ["a", "b"]}, {"id": 2, "tags": []}], "n": null}`)
+	stats := Profile(doc)
+	expected := []PointerStat{
+		{"", 0, 13},
+		{"/items", 0, 9},
+		{"/items/0", 0, 5},
+		{"/items/1", 0, 3},
+		{"/meta", 0, 2},
+		{"/items/0/tags", 0, 3},
+		{"/items/1/tags", 0, 1},
+	}
+	for i := range expected {
+		p, _ := pointer.Parse(expected[i].Pointer)
+		v, _ := p.Get(doc)
+		b, _ := marshal(v)
+		expected[i].Size = int64(len(b))
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected %v, got %v", expected, stats)
+	}
+
+	if stats := Profile("scalar"); len(stats) != 0 {
+		t.Errorf("expected no containers, got %v", stats)
+	}
+	// values that cannot be encoded count for nothing
+	stats = Profile([]interface{}{math.NaN(), json.Number("1")})
+	if !reflect.DeepEqual(stats, []PointerStat{{"", 4, 3}}) {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
@@ -0,0 +1,151 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// ErrStaleProjection matches errors for projections whose stored view does
+// not match the view computed from the patched document.
+var ErrStaleProjection = errors.New("stale projection")
+
+// Projection describes a view derived from a document, such as a
+// denormalized copy kept in another table or index.
+type Projection struct {
+	Name string
+	// Reads lists pointers to the parts of the document the view is derived
+	// from. A projection with no Reads depends on the whole document.
+	Reads []string
+	// Compute derives the view from a document.
+	Compute func(doc interface{}) (interface{}, error)
+}
+
+// ProjectionStatus is the outcome of checking one projection.
+type ProjectionStatus struct {
+	Name string
+	// Affected is true when the patch modifies a part of the document the
+	// projection reads.
+	Affected bool
+	// Stale is true when the stored view differs from the computed one.
+	Stale bool
+	// Want is the view computed from the patched document. It is only set
+	// for affected projections.
+	Want interface{}
+}
+
+// AffectedProjections returns the names of the projections that read a part
+// of the document modified by operations. Operations that insert into or
+// remove from an array affect every later element of that array, since
+// their indexes shift.
+func AffectedProjections(operations []Operation, projections []Projection) ([]string, error) {
+	var names []string
+	for _, p := range projections {
+		affected, err := affects(operations, p.Reads)
+		if err != nil {
+			return nil, fmt.Errorf("projection %q: %w", p.Name, err)
+		}
+		if affected {
+			names = append(names, p.Name)
+		}
+	}
+	return names, nil
+}
+
+// CheckProjections verifies that the views stored for the projections, keyed
+// by name, were recomputed after operations were applied. patched is the
+// document with operations already applied. Only the projections affected by
+// operations are computed; the returned error matches ErrStaleProjection for
+// each one whose stored view is missing or different.
+func CheckProjections(patched interface{}, operations []Operation, projections []Projection, views map[string]interface{}) ([]ProjectionStatus, error) {
+	statuses := make([]ProjectionStatus, len(projections))
+	var errs []error
+	for i, p := range projections {
+		s := &statuses[i]
+		s.Name = p.Name
+		affected, err := affects(operations, p.Reads)
+		if err != nil {
+			return nil, fmt.Errorf("projection %q: %w", p.Name, err)
+		}
+		if !affected {
+			continue
+		}
+		s.Affected = true
+		if s.Want, err = p.Compute(patched); err != nil {
+			return nil, fmt.Errorf("projection %q: %w", p.Name, err)
+		}
+		view, ok := views[p.Name]
+		if !ok || !reflect.DeepEqual(view, s.Want) {
+			s.Stale = true
+			errs = append(errs, fmt.Errorf("projection %q: %w", p.Name, ErrStaleProjection))
+		}
+	}
+	return statuses, errors.Join(errs...)
+}
+
+// affects reports whether any of the operations modifies a part of the
+// document under one of the reads pointers.
+func affects(operations []Operation, reads []string) (bool, error) {
+	var paths [][]string
+	for _, r := range reads {
+		p, err := pointer.Parse(r)
+		if err != nil {
+			return false, err
+		}
+		paths = append(paths, p)
+	}
+	for _, op := range operations {
+		if op.Op == "test" {
+			continue
+		}
+		if len(paths) == 0 {
+			return true, nil
+		}
+		targets := []string{op.Path}
+		if op.Op == "move" {
+			targets = append(targets, op.From)
+		}
+		for _, t := range targets {
+			target, err := pointer.Parse(t)
+			if err != nil {
+				return false, err
+			}
+			shifts := op.Op != "replace" && len(target) > 0 && isIndex(target[len(target)-1])
+			for _, r := range paths {
+				if overlaps(target, r, shifts) {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// overlaps reports whether modifying the value at target can change the
+// value at read. When shifts is true, target inserts into or removes from
+// an array, moving the elements after it.
+func overlaps(target, read []string, shifts bool) bool {
+	n := min(len(target), len(read))
+	for i := 0; i < n; i++ {
+		if target[i] == read[i] {
+			continue
+		}
+		if !shifts || i != len(target)-1 || !isIndex(read[i]) {
+			return false
+		}
+		// elements before the insertion or removal point keep their index
+		t, _ := pointer.ParseIndex(target[i], math.MaxInt32, true)
+		r, _ := pointer.ParseIndex(read[i], math.MaxInt32, true)
+		return r >= t
+	}
+	return true
+}
+
+// isIndex reports whether token could address an array element.
+func isIndex(token string) bool {
+	_, err := pointer.ParseIndex(token, math.MaxInt32, true)
+	return err == nil
+}
@@ -0,0 +1,69 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAffectedProjections(t *testing.T) {
+	projections := []Projection{
+		{Name: "all"},
+		{Name: "name", Reads: []string{"/user/name"}},
+		{Name: "user", Reads: []string{"/user"}},
+		{Name: "third-tag", Reads: []string{"/tags/2"}},
+		{Name: "first-tag", Reads: []string{"/tags/0"}},
+	}
+	for _, tc := range []struct {
+		patch    string
+		expected []string
+	}{
+		{`[{"op": "test", "path": "/user/name", "value": "x"}]`, nil},
+		{`[{"op": "replace", "path": "/user/name", "value": "x"}]`, []string{"all", "name", "user"}},
+		{`[{"op": "add", "path": "/user/email", "value": "x"}]`, []string{"all", "user"}},
+		{`[{"op": "replace", "path": "", "value": {}}]`, []string{"all", "name", "user", "third-tag", "first-tag"}},
+		{`[{"op": "replace", "path": "/tags/1", "value": "x"}]`, []string{"all"}},
+		{`[{"op": "remove", "path": "/tags/1"}]`, []string{"all", "third-tag"}},
+		{`[{"op": "move", "from": "/user/name", "path": "/owner"}]`, []string{"all", "name", "user"}},
+	} {
+		names, err := AffectedProjections(parseStr(tc.patch), projections)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(names, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.patch, tc.expected, names)
+		}
+	}
+}
+
+func TestCheckProjections(t *testing.T) {
+	count := func(doc interface{}) (interface{}, error) {
+		return float64(len(doc.(map[string]interface{})["tags"].([]interface{}))), nil
+	}
+	projections := []Projection{
+		{Name: "count", Reads: []string{"/tags"}, Compute: count},
+		{Name: "name", Reads: []string{"/name"}, Compute: func(doc interface{}) (interface{}, error) {
+			t.Error("unaffected projection computed")
+			return nil, nil
+		}},
+	}
+	ops := parseStr(`[{"op": "add", "path": "/tags/-", "value": "c"}]`)
+	patched, err := Apply(decode(`{"name": "x", "tags": ["a", "b"]}`), ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := CheckProjections(patched, ops, projections, map[string]interface{}{"count": float64(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ProjectionStatus{{Name: "count", Affected: true, Want: float64(3)}, {Name: "name"}}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected %v, got %v", expected, statuses)
+	}
+
+	statuses, err = CheckProjections(patched, ops, projections, map[string]interface{}{"count": float64(2)})
+	if !errors.Is(err, ErrStaleProjection) || !statuses[0].Stale {
+		t.Errorf("expected a stale projection, got %v %v", statuses, err)
+	}
+}
@@ -0,0 +1,138 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrQuotaExceeded matches errors for patches refused because their caller
+// ran out of budget.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Usage is an amount of patching work: a number of operations and the
+// number of bytes of their paths, from pointers and values.
+type Usage struct {
+	Ops   int
+	Bytes int64
+}
+
+func (u Usage) add(v Usage) Usage {
+	return Usage{Ops: u.Ops + v.Ops, Bytes: u.Bytes + v.Bytes}
+}
+
+// exceeds reports whether u is over limit. Zero fields of limit are
+// unlimited.
+func (u Usage) exceeds(limit Usage) bool {
+	return limit.Ops > 0 && u.Ops > limit.Ops || limit.Bytes > 0 && u.Bytes > limit.Bytes
+}
+
+// patchUsage returns the usage charged for operations.
+func patchUsage(operations []Operation) Usage {
+	u := Usage{Ops: len(operations)}
+	for _, op := range operations {
+		u.Bytes += int64(len(op.Path) + len(op.From) + len(op.Value))
+	}
+	return u
+}
+
+// QuotaStore accounts for the work done on behalf of each caller. It is
+// consulted before a patch is applied, so a refused patch has no effect on
+// the document. Implementations backed by shared storage let several
+// servers enforce the same budgets.
+type QuotaStore interface {
+	// Charge adds u to the consumption of the caller identified by key. If
+	// that would take the caller over budget, Charge records nothing and
+	// returns an error matching ErrQuotaExceeded, such as a *QuotaError.
+	// Charge must be safe for concurrent use.
+	Charge(key string, u Usage) error
+}
+
+// QuotaError reports a patch refused by a QuotaStore.
+type QuotaError struct {
+	Key   string
+	Used  Usage // consumption before the patch
+	Need  Usage // usage of the refused patch
+	Limit Usage
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota exceeded for %q: %d ops and %d bytes used, %d ops and %d bytes requested, limit %d ops and %d bytes",
+		e.Key, e.Used.Ops, e.Used.Bytes, e.Need.Ops, e.Need.Bytes, e.Limit.Ops, e.Limit.Bytes)
+}
+
+// Is makes QuotaError match ErrQuotaExceeded.
+func (e *QuotaError) Is(target error) bool { return target == ErrQuotaExceeded }
+
+// Code returns "quota-exceeded".
+func (e *QuotaError) Code() string { return "quota-exceeded" }
+
+// WithQuota charges the patch to caller in store before applying it. See
+// Options.Quota.
+func WithQuota(store QuotaStore, caller string) Option {
+	return func(o *Options) { o.Quota, o.Caller = store, caller }
+}
+
+// charge charges operations to the caller, if a quota store is set.
+func (a *applier) charge(operations []Operation) error {
+	if a.opts.Quota == nil {
+		return nil
+	}
+	return a.opts.Quota.Charge(a.opts.Caller, patchUsage(operations))
+}
+
+// MemoryQuota is a QuotaStore keeping consumption in memory. Budgets are not
+// reset automatically; call Reset at the end of each accounting period.
+type MemoryQuota struct {
+	mu     sync.Mutex
+	limit  Usage
+	limits map[string]Usage
+	used   map[string]Usage
+}
+
+// NewMemoryQuota returns a MemoryQuota giving every caller the budget limit.
+// Zero fields of limit are unlimited.
+func NewMemoryQuota(limit Usage) *MemoryQuota {
+	return &MemoryQuota{
+		limit:  limit,
+		limits: make(map[string]Usage),
+		used:   make(map[string]Usage),
+	}
+}
+
+// SetLimit overrides the budget of the caller identified by key.
+func (q *MemoryQuota) SetLimit(key string, limit Usage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.limits[key] = limit
+}
+
+// Charge implements QuotaStore.
+func (q *MemoryQuota) Charge(key string, u Usage) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	limit, ok := q.limits[key]
+	if !ok {
+		limit = q.limit
+	}
+	used := q.used[key]
+	if used.add(u).exceeds(limit) {
+		return &QuotaError{Key: key, Used: used, Need: u, Limit: limit}
+	}
+	q.used[key] = used.add(u)
+	return nil
+}
+
+// Used returns the consumption of the caller identified by key.
+func (q *MemoryQuota) Used(key string) Usage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.used[key]
+}
+
+// Reset clears the consumption of every caller.
+func (q *MemoryQuota) Reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	clear(q.used)
+}
@@ -0,0 +1,70 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestQuota(t *testing.T) {
+	q := NewMemoryQuota(Usage{Ops: 3})
+	q.SetLimit("big", Usage{Ops: 100, Bytes: 1 << 20})
+	ops := parseStr(`[{"op": "add", "path": "/a", "value": 1}, {"op": "remove", "path": "/a"}]`)
+
+	if _, err := Apply(decode(`{}`), ops, WithQuota(q, "small")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Apply(decode(`{}`), ops, WithQuota(q, "small"))
+	var qe *QuotaError
+	if !errors.Is(err, ErrQuotaExceeded) || !errors.As(err, &qe) || qe.Key != "small" {
+		t.Fatalf("expected a quota error, got %v", err)
+	}
+	if used := q.Used("small"); !reflect.DeepEqual(used, Usage{Ops: 2, Bytes: 5}) {
+		t.Errorf("refused patch was charged: %+v", used)
+	}
+
+	// a refused patch leaves the document alone, even in place
+	doc := decode(`{}`)
+	if _, err := Apply(doc, ops[:1], WithQuota(q, "small"), WithInPlace()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Apply(doc, parseStr(`[{"op": "add", "path": "/b", "value": 1}]`), WithQuota(q, "small"), WithInPlace()); err == nil {
+		t.Fatal("expected a quota error")
+	}
+	if !reflect.DeepEqual(doc, decode(`{"a": 1}`)) {
+		t.Errorf("refused patch modified the document: %v", doc)
+	}
+
+	if _, err := Apply(decode(`{}`), ops, WithQuota(q, "big")); err != nil {
+		t.Errorf("caller with its own limit: %v", err)
+	}
+
+	q.Reset()
+	if _, err := Apply(decode(`{}`), ops, WithQuota(q, "small")); err != nil {
+		t.Errorf("after reset: %v", err)
+	}
+}
+
+func TestQuotaConcurrent(t *testing.T) {
+	q := NewMemoryQuota(Usage{Ops: 50})
+	ops := parseStr(`[{"op": "add", "path": "/a", "value": 1}]`)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	applied := 0
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Apply(decode(`{}`), ops, WithQuota(q, "k")); err == nil {
+				mu.Lock()
+				applied++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if applied != 50 {
+		t.Errorf("expected 50 patches within budget, got %d", applied)
+	}
+}
@@ -0,0 +1,439 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// ErrConflict matches errors for patches that cannot be rebased onto each
+// other.
+var ErrConflict = errors.New("conflicting patches")
+
+// Conflict reports an operation of a patch that interferes with an operation
+// of a concurrent patch made against the same document.
+type Conflict struct {
+	A, B   int    // indexes of the operations in the two patches
+	Path   string // the pointer of B's operation that is interfered with
+	Reason string
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("operation %d of A and operation %d of B at %s: %s", c.A, c.B, c.Path, c.Reason)
+}
+
+// ConflictError is returned by Rebase when the patches conflict.
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	parts := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		parts[i] = c.String()
+	}
+	return "conflicting patches: " + strings.Join(parts, "; ")
+}
+
+// Is makes ConflictError match ErrConflict.
+func (e *ConflictError) Is(target error) bool { return target == ErrConflict }
+
+// Code returns "conflict".
+func (e *ConflictError) Code() string { return "conflict" }
+
+// Rebase rewrites b, a patch made against the same document as a, so that
+// it can be applied after a. Pointers of b are adjusted for the array
+// elements a inserts and removes and for the values a moves, and operations
+// of b that a already carried out, such as removing the same member, are
+// dropped. If the patches conflict, Rebase returns a *ConflictError listing
+// every conflict.
+//
+// Two operations conflict when one of them removes, replaces, tests or
+// copies a value the other one modifies, when they write different values
+// at the same place, insert elements at the same place or move the same
+// value to different places, or when rebasing one of them would make it
+// move a value into itself. Rebasing works on the patches alone: a token
+// that is a number or "-" is taken to address an array element, and the
+// last such token of an add or remove is taken to insert into or remove
+// from an array. A value a moves to the end of an array with "-" cannot be
+// followed there, so the operations of b inside it conflict.
+func Rebase(a, b []Operation) ([]Operation, error) {
+	rebased, conflicts, err := rebase(a, b)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		return nil, &ConflictError{Conflicts: conflicts}
+	}
+	return rebased, nil
+}
+
+// Conflicts returns the conflicts between two patches made against the same
+// document, as described for Rebase. Operations with malformed pointers are
+// ignored.
+func Conflicts(a, b []Operation) []Conflict {
+	_, conflicts, _ := rebase(a, b)
+	return conflicts
+}
+
+type primKind int
+
+const (
+	primRead   primKind = iota // the value is read, by test, copy or move
+	primSet                    // the value is created or overwritten
+	primInsert                 // an array element is inserted
+	primDelete                 // the value is removed
+)
+
+// prim is one elementary effect of an operation.
+type prim struct {
+	kind primKind
+	ptr  pointer.Pointer
+}
+
+// rebaseOp is an operation being rebased, with its pointers parsed.
+type rebaseOp struct {
+	op         Operation
+	index      int
+	path, from pointer.Pointer
+	// dropped is set when the other patch already does what the
+	// operation does
+	dropped bool
+}
+
+func newRebaseOp(i int, op Operation) (*rebaseOp, error) {
+	r := &rebaseOp{op: op, index: i}
+	var err error
+	if r.path, err = pointer.Parse(op.Path); err != nil {
+		return nil, fmt.Errorf("operation %d: %w", i, err)
+	}
+	if op.Op == "move" || op.Op == "copy" {
+		if r.from, err = pointer.Parse(op.From); err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return r, nil
+}
+
+// prims returns the effects of the operation, in order.
+func (r *rebaseOp) prims() []prim {
+	switch r.op.Op {
+	case "test":
+		return []prim{{primRead, r.path}}
+	case "remove":
+		return []prim{{primDelete, r.path}}
+	case "replace":
+		return []prim{{primSet, r.path}}
+	case "add":
+		return []prim{r.addPrim()}
+	case "copy":
+		return []prim{{primRead, r.from}, r.addPrim()}
+	case "move":
+		return []prim{{primDelete, r.from}, r.addPrim()}
+	}
+	return []prim{{primSet, r.path}}
+}
+
+func (r *rebaseOp) addPrim() prim {
+	if n := len(r.path); n > 0 && isIndex(r.path[n-1]) {
+		return prim{primInsert, r.path}
+	}
+	return prim{primSet, r.path}
+}
+
+// relation describes where a pointer lies relative to the target of a prim.
+type relation int
+
+const (
+	relNone     relation = iota // unrelated
+	relSame                     // the same value
+	relInside                   // inside the prim's target
+	relContains                 // containing the prim's target
+)
+
+// shift returns q, a pointer valid before p takes effect, adjusted to be
+// valid after it, and how it relates to p's target.
+func shift(q pointer.Pointer, p prim) (pointer.Pointer, relation) {
+	n := len(p.ptr)
+	if n > 0 && len(q) >= n && (p.kind == primInsert || p.kind == primDelete) &&
+		isIndex(p.ptr[n-1]) && samePrefix(q, p.ptr, n-1) {
+		k, kok := arrayIndex(p.ptr[n-1])
+		i, iok := arrayIndex(q[n-1])
+		if p.kind == primInsert {
+			if kok && iok && i >= k {
+				return withToken(q, n-1, i+1), relNone
+			}
+			return q, relNone
+		}
+		switch {
+		case !kok || !iok || i < k:
+			return q, relNone
+		case i > k:
+			return withToken(q, n-1, i-1), relNone
+		}
+	}
+	m := min(len(q), n)
+	if !samePrefix(q, p.ptr, m) {
+		return q, relNone
+	}
+	switch {
+	case len(q) == n:
+		return q, relSame
+	case len(q) > n:
+		return q, relInside
+	}
+	return q, relContains
+}
+
+func samePrefix(a, b pointer.Pointer, n int) bool {
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex returns the index named by token, which is false for "-".
+func arrayIndex(token string) (int, bool) {
+	if token == "-" {
+		return 0, false
+	}
+	i, err := strconv.Atoi(token)
+	return i, err == nil
+}
+
+func withToken(p pointer.Pointer, i, index int) pointer.Pointer {
+	out := append(pointer.Pointer{}, p...)
+	out[i] = strconv.Itoa(index)
+	return out
+}
+
+func rebase(a, b []Operation) ([]Operation, []Conflict, error) {
+	as := make([]*rebaseOp, len(a))
+	for i, op := range a {
+		r, err := newRebaseOp(i, op)
+		if err != nil {
+			return nil, nil, fmt.Errorf("patch A: %w", err)
+		}
+		as[i] = r
+	}
+	var conflicts []Conflict
+	var out []Operation
+	for j, op := range b {
+		rb, err := newRebaseOp(j, op)
+		if err != nil {
+			return nil, nil, fmt.Errorf("patch B: %w", err)
+		}
+		// each operation of a is made against the document rb was
+		// moved to when it was reached
+		var passed []*rebaseOp
+		var seen []rebaseOp
+		for _, ra := range as {
+			if ra.dropped {
+				continue
+			}
+			passed, seen = append(passed, ra), append(seen, *rb)
+			c, ok := rb.over(ra)
+			if !ok && rb.movesIntoItself() {
+				c, ok = Conflict{A: ra.index, B: rb.index, Path: rb.op.Path, Reason: "A makes B move the value into itself"}, true
+			}
+			if ok {
+				conflicts = append(conflicts, c)
+			}
+			if rb.dropped {
+				// the later operations of b are made against a
+				// document already including ra
+				ra.dropped = true
+				passed = passed[:len(passed)-1]
+				break
+			}
+		}
+		// the later operations of b are made against a document that
+		// includes rb, so a must now be expressed against it too
+		for k, ra := range passed {
+			r := &seen[k]
+			if ra.op.Op == "move" {
+				ra.path, ra.from = r.followMove(ra.path, ra.from)
+				continue
+			}
+			ra.path = r.follow(ra.path, ra.op.Op == "add" || ra.op.Op == "copy")
+			if ra.from != nil {
+				ra.from = r.follow(ra.from, false)
+			}
+		}
+		if rb.dropped {
+			continue
+		}
+		rb.op.Path = rb.path.String()
+		if rb.from != nil {
+			rb.op.From = rb.from.String()
+		}
+		out = append(out, rb.op)
+	}
+	return out, conflicts, nil
+}
+
+// follow returns q, a pointer valid before the operation, adjusted to be
+// valid after it. Values inside the source of a move follow it to its
+// destination. When q is the destination of an add, move or copy, the
+// place it names stays where it is even if the move takes its value away.
+func (r *rebaseOp) follow(q pointer.Pointer, dest bool) pointer.Pointer {
+	if r.op.Op == "move" && followsMove(q, r.from, dest) {
+		return append(append(pointer.Pointer{}, r.path...), q[len(r.from):]...)
+	}
+	for _, p := range r.prims() {
+		q, _ = shift(q, p)
+	}
+	return q
+}
+
+// followsMove reports whether q, a destination if dest is set, addresses
+// the value moved from from or a value inside it.
+func followsMove(q, from pointer.Pointer, dest bool) bool {
+	if dest && len(q) == len(from) {
+		return false
+	}
+	return len(q) >= len(from) && samePrefix(q, from, len(from))
+}
+
+// followMove is follow for the path and source of a move. The path is
+// valid once the source is removed, so it is adjusted in the document
+// still holding the source, then for the source being removed from where
+// it was followed to.
+func (r *rebaseOp) followMove(path, from pointer.Pointer) (pointer.Pointer, pointer.Pointer) {
+	q, rel, ok := unshift(path, prim{primDelete, from})
+	if !ok || rel != relNone {
+		return r.follow(path, true), r.follow(from, false)
+	}
+	from = r.follow(from, false)
+	q, _ = shift(r.follow(q, true), prim{primDelete, from})
+	return q, from
+}
+
+// over moves b past the effects of a, marking it dropped when a already did
+// what b does, and returns the conflict between them if there is one.
+func (b *rebaseOp) over(a *rebaseOp) (Conflict, bool) {
+	conflict := func(reason string) (Conflict, bool) {
+		return Conflict{A: a.index, B: b.index, Path: b.op.Path, Reason: reason}, true
+	}
+	// the path of a move is valid once its source is removed, so it is
+	// moved past a in the document still holding the source, and adjusted
+	// for the source being removed afterwards
+	var movePath pointer.Pointer
+	if b.op.Op == "move" {
+		if q, rel, ok := unshift(b.path, prim{primDelete, b.from}); ok && rel == relNone {
+			movePath = q
+		}
+	}
+	bprims := b.prims()
+	for k, bp := range bprims {
+		q := &b.path
+		if k == 0 && b.from != nil {
+			q = &b.from
+		} else if movePath != nil {
+			q = &movePath
+		}
+		dest := k == len(bprims)-1 && (b.op.Op == "add" || b.op.Op == "move" || b.op.Op == "copy")
+		if a.op.Op == "move" && followsMove(*q, a.from, dest) {
+			if bp.kind == primDelete && b.op.Op == "move" && len(*q) == len(a.from) {
+				// both move the same value
+				if b.path.String() == a.path.String() {
+					b.dropped = true
+					return Conflict{}, false
+				}
+				return conflict("the value was moved elsewhere by A")
+			}
+			// b follows the value a moved, which cannot be found when
+			// a appended it to an array
+			if n := len(a.path); n > 0 && a.path[n-1] == "-" {
+				return conflict("the value was appended to an array by A")
+			}
+			*q = append(append(pointer.Pointer{}, a.path...), (*q)[len(a.from):]...)
+			continue
+		}
+		for _, p := range a.prims() {
+			if p.kind == primRead {
+				if bp.kind != primRead && !b.keepsReadValue(*q, bp, p) {
+					return conflict("B modifies a value read by A")
+				}
+				continue
+			}
+			if bp.kind == primInsert && p.kind == primInsert && len(*q) == len(p.ptr) &&
+				samePrefix(*q, p.ptr, len(p.ptr)-1) && (*q)[len(*q)-1] == p.ptr[len(p.ptr)-1] {
+				// the order of the two new elements depends on which
+				// patch is applied first
+				return conflict("A inserts an element at the same place")
+			}
+			var rel relation
+			*q, rel = shift(*q, p)
+			switch rel {
+			case relInside:
+				if p.kind != primInsert {
+					return conflict("the value was removed or replaced by A")
+				}
+			case relSame:
+				switch {
+				case p.kind == primDelete && bp.kind == primDelete && b.op.Op == "remove":
+					b.dropped = true
+					return Conflict{}, false
+				case p.kind == primSet && bp.kind == primSet && b.op.Op == a.op.Op &&
+					b.op.Op != "move" && b.op.Op != "copy" && sameValue(a.op.Value, b.op.Value):
+					b.dropped = true
+					return Conflict{}, false
+				case bp.kind == primInsert:
+					// b inserts before the element a removed or set
+				case p.kind == primDelete:
+					return conflict("the value was removed by A")
+				default:
+					return conflict("the value was modified by A")
+				}
+			case relContains:
+				switch {
+				case bp.kind == primRead && b.op.Op == "test":
+					return conflict("A modified part of the tested value")
+				case bp.kind == primRead:
+					return conflict("A modified part of the copied value")
+				case bp.kind == primSet || bp.kind == primDelete && b.op.Op != "move":
+					return conflict("B overwrites a value modified by A")
+				}
+			}
+		}
+	}
+	if movePath != nil {
+		b.path, _ = shift(movePath, prim{primDelete, b.from})
+	}
+	return Conflict{}, false
+}
+
+// keepsReadValue reports whether bp, the effect of b at q, leaves the value
+// read by p unchanged, or moves it to where a can follow it.
+func (b *rebaseOp) keepsReadValue(q pointer.Pointer, bp prim, p prim) bool {
+	_, rel := shift(q, p)
+	switch rel {
+	case relNone:
+		return true
+	case relSame, relContains:
+		// an insert goes before the value, and a moved value is
+		// followed by a
+		return bp.kind == primInsert || bp.kind == primDelete && b.op.Op == "move"
+	}
+	return false
+}
+
+// movesIntoItself reports whether b is a move into its own child, which
+// cannot be applied.
+func (b *rebaseOp) movesIntoItself() bool {
+	return b.op.Op == "move" && len(b.from) < len(b.path) && samePrefix(b.path, b.from, len(b.from))
+}
+
+func sameValue(x, y []byte) bool {
+	var a, b interface{}
+	if unmarshalNumber(x, &a) != nil || unmarshalNumber(y, &b) != nil {
+		return false
+	}
+	return jsonEqual(a, b)
+}
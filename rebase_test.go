@@ -0,0 +1,235 @@
+package patch
+
+import (
+	"errors"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestRebase(t *testing.T) {
+	for _, tc := range []struct {
+		comment, doc, a, b string
+	}{
+		{
+			"unrelated members",
+			`{"x": 1, "y": 2}`,
+			`[{"op": "replace", "path": "/x", "value": 10}]`,
+			`[{"op": "remove", "path": "/y"}]`,
+		},
+		{
+			"insert before an element b modifies",
+			`{"list": ["a", "b", "c"]}`,
+			`[{"op": "add", "path": "/list/0", "value": "z"}]`,
+			`[{"op": "replace", "path": "/list/1", "value": "B"}, {"op": "remove", "path": "/list/2"}]`,
+		},
+		{
+			"remove before an element b modifies",
+			`{"list": [{"n": 1}, {"n": 2}, {"n": 3}]}`,
+			`[{"op": "remove", "path": "/list/0"}]`,
+			`[{"op": "replace", "path": "/list/2/n", "value": 30}, {"op": "add", "path": "/list/-", "value": {"n": 4}}]`,
+		},
+		{
+			"b inserts before an element a modifies later",
+			`{"list": ["a", "b"]}`,
+			`[{"op": "replace", "path": "/list/1", "value": "B"}]`,
+			`[{"op": "add", "path": "/list/0", "value": "z"}, {"op": "replace", "path": "/list/1", "value": "a2"}]`,
+		},
+		{
+			"both remove the same member",
+			`{"x": 1, "y": 2}`,
+			`[{"op": "remove", "path": "/x"}]`,
+			`[{"op": "remove", "path": "/x"}, {"op": "add", "path": "/z", "value": 3}]`,
+		},
+		{
+			"both set the same value",
+			`{"x": 1}`,
+			`[{"op": "replace", "path": "/x", "value": 2}]`,
+			`[{"op": "replace", "path": "/x", "value": 2.0}]`,
+		},
+		{
+			"b moves an object a modifies",
+			`{"obj": {"a": 0}}`,
+			`[{"op": "add", "path": "/obj/b", "value": 1}]`,
+			`[{"op": "move", "from": "/obj", "path": "/other"}]`,
+		},
+		{
+			"move within an array",
+			`{"list": ["a", "b", "c", "d"]}`,
+			`[{"op": "remove", "path": "/list/1"}]`,
+			`[{"op": "move", "from": "/list/3", "path": "/list/0"}]`,
+		},
+		{
+			"move into an element shifted by its own source",
+			`{"b": [{"i": 0}, {"i": 1}, {"i": 2}]}`,
+			`[{"op": "add", "path": "/b/2", "value": "new"}]`,
+			`[{"op": "move", "from": "/b/0", "path": "/b/1/x"}]`,
+		},
+		{
+			"insert where b moves a value from",
+			`{"m": [5, 6]}`,
+			`[{"op": "move", "from": "/m/0", "path": "/a"}]`,
+			`[{"op": "add", "path": "/m/0", "value": 7}]`,
+		},
+		{
+			"insert around an element a moves into",
+			`{"b": [{"i": 0}, {"i": 1}, {"i": 2}]}`,
+			`[{"op": "move", "from": "/b/0", "path": "/b/1/x"}]`,
+			`[{"op": "add", "path": "/b/2", "value": "new"}, {"op": "add", "path": "/b/3/y", "value": 1}]`,
+		},
+	} {
+		a, b := parseStr(tc.a), parseStr(tc.b)
+		rebased, err := Rebase(a, b)
+		if err != nil {
+			t.Errorf("%s: %v", tc.comment, err)
+			continue
+		}
+		// applying a then the rebased b must match applying b then
+		// the rebased a
+		ab, err := Apply(decode(tc.doc), append(append([]Operation{}, a...), rebased...))
+		if err != nil {
+			t.Errorf("%s: applying a then b': %v", tc.comment, err)
+			continue
+		}
+		reverse, err := Rebase(b, a)
+		if err != nil {
+			t.Errorf("%s: rebasing a over b: %v", tc.comment, err)
+			continue
+		}
+		ba, err := Apply(decode(tc.doc), append(append([]Operation{}, b...), reverse...))
+		if err != nil {
+			t.Errorf("%s: applying b then a': %v", tc.comment, err)
+			continue
+		}
+		if !reflect.DeepEqual(ab, ba) {
+			t.Errorf("%s: a then b' gives %v, b then a' gives %v", tc.comment, ab, ba)
+		}
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		a, b   string
+		expect []Conflict
+	}{
+		{
+			`[{"op": "replace", "path": "/x", "value": 1}]`,
+			`[{"op": "replace", "path": "/x", "value": 2}]`,
+			[]Conflict{{A: 0, B: 0, Path: "/x", Reason: "the value was modified by A"}},
+		},
+		{
+			`[{"op": "remove", "path": "/obj"}]`,
+			`[{"op": "add", "path": "/y", "value": 1}, {"op": "replace", "path": "/obj/a", "value": 2}]`,
+			[]Conflict{{A: 0, B: 1, Path: "/obj/a", Reason: "the value was removed or replaced by A"}},
+		},
+		{
+			`[{"op": "remove", "path": "/list/1"}]`,
+			`[{"op": "replace", "path": "/list/1", "value": 2}]`,
+			[]Conflict{{A: 0, B: 0, Path: "/list/1", Reason: "the value was removed by A"}},
+		},
+		{
+			`[{"op": "replace", "path": "/obj/a", "value": 1}]`,
+			`[{"op": "test", "path": "/obj", "value": {"a": 0}}]`,
+			[]Conflict{{A: 0, B: 0, Path: "/obj", Reason: "A modified part of the tested value"}},
+		},
+		{
+			`[{"op": "add", "path": "/obj/a", "value": 1}]`,
+			`[{"op": "replace", "path": "/obj", "value": {}}]`,
+			[]Conflict{{A: 0, B: 0, Path: "/obj", Reason: "B overwrites a value modified by A"}},
+		},
+		{
+			`[{"op": "add", "path": "/obj/a", "value": 1}]`,
+			`[{"op": "move", "from": "/obj", "path": "/other"}]`,
+			nil,
+		},
+		{
+			`[{"op": "move", "from": "/x", "path": "/p"}]`,
+			`[{"op": "move", "from": "/x", "path": "/q"}]`,
+			[]Conflict{{A: 0, B: 0, Path: "/q", Reason: "the value was moved elsewhere by A"}},
+		},
+		{
+			`[{"op": "move", "from": "/l", "path": "/p/z"}]`,
+			`[{"op": "move", "from": "/p", "path": "/l/0"}]`,
+			[]Conflict{{A: 0, B: 0, Path: "/l/0", Reason: "A makes B move the value into itself"}},
+		},
+		{
+			`[{"op": "add", "path": "/x/a", "value": 1}]`,
+			`[{"op": "copy", "from": "/x", "path": "/y"}]`,
+			[]Conflict{{A: 0, B: 0, Path: "/y", Reason: "A modified part of the copied value"}},
+		},
+		{
+			`[{"op": "copy", "from": "/x", "path": "/y"}]`,
+			`[{"op": "add", "path": "/x/a", "value": 1}]`,
+			[]Conflict{{A: 0, B: 0, Path: "/x/a", Reason: "B modifies a value read by A"}},
+		},
+		{
+			`[{"op": "add", "path": "/list/1", "value": 1}]`,
+			`[{"op": "add", "path": "/list/1", "value": 2}]`,
+			[]Conflict{{A: 0, B: 0, Path: "/list/1", Reason: "A inserts an element at the same place"}},
+		},
+		{
+			`[{"op": "add", "path": "/list/-", "value": 1}]`,
+			`[{"op": "add", "path": "/list/-", "value": 2}]`,
+			[]Conflict{{A: 0, B: 0, Path: "/list/-", Reason: "A inserts an element at the same place"}},
+		},
+		{
+			`[{"op": "move", "from": "/a", "path": "/list/-"}]`,
+			`[{"op": "add", "path": "/a/c", "value": 1}]`,
+			[]Conflict{{A: 0, B: 0, Path: "/a/c", Reason: "the value was appended to an array by A"}},
+		},
+	} {
+		got := Conflicts(parseStr(tc.a), parseStr(tc.b))
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("%s / %s: expected %v, got %v", tc.a, tc.b, tc.expect, got)
+		}
+	}
+
+	// an insert stays where it is when the value it goes before is moved
+	rebased, err := Rebase(parseStr(`[{"op": "move", "from": "/m/0", "path": "/a"}]`), parseStr(`[{"op": "add", "path": "/m/0", "value": 7}]`))
+	if expected := parseStr(`[{"op": "add", "path": "/m/0", "value": 7}]`); err != nil || !reflect.DeepEqual(rebased, expected) {
+		t.Errorf("expected %v, got %v %v", expected, rebased, err)
+	}
+
+	_, err = Rebase(parseStr(`[{"op": "remove", "path": "/x"}]`), parseStr(`[{"op": "replace", "path": "/x", "value": 1}]`))
+	var ce *ConflictError
+	if !errors.Is(err, ErrConflict) || !errors.As(err, &ce) || len(ce.Conflicts) != 1 {
+		t.Errorf("expected a conflict error, got %v", err)
+	}
+}
+
+// TestRebaseRandom checks that two random patches made against the same
+// document either conflict or converge once each is rebased onto the other.
+func TestRebaseRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	docs := []interface{}{
+		decode(`{"a": 1, "b": {"c": [2, 3]}, "list": [0, [1], {"x": 2}]}`),
+		decode(`{"m": [[1, 2], [3], {"a": [4]}], "x": {"a": {"b": 1}}}`),
+	}
+	converged := 0
+	for n := 0; n < 20000; n++ {
+		doc := docs[n%len(docs)]
+		a, b := randomPatch(r, doc, 1+r.IntN(3)), randomPatch(r, doc, 1+r.IntN(3))
+		bOverA, err := Rebase(a, b)
+		if errors.Is(err, ErrConflict) {
+			continue
+		}
+		aOverB, err2 := Rebase(b, a)
+		if errors.Is(err2, ErrConflict) {
+			continue
+		}
+		if err != nil || err2 != nil {
+			t.Fatalf("%v\n%v\ncannot be rebased: %v %v", fmtOps(a), fmtOps(b), err, err2)
+		}
+		ab, err := applyRecover(doc, append(slices.Clone(a), bOverA...))
+		ba, err2 := applyRecover(doc, append(slices.Clone(b), aOverB...))
+		if err != nil || err2 != nil || !reflect.DeepEqual(ab, ba) {
+			t.Fatalf("on %v\na: %v\nb: %v\nb': %v\na': %v\na then b' gives %v (%v)\nb then a' gives %v (%v)",
+				doc, fmtOps(a), fmtOps(b), fmtOps(bOverA), fmtOps(aOverB), ab, err, ba, err2)
+		}
+		converged++
+	}
+	if converged < 5000 {
+		t.Errorf("only %d of the patches could be rebased", converged)
+	}
+}
@@ -0,0 +1,96 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// With Options.OpRefs, the "from" of a copy operation may be written
+// "@N/pointer" to refer to the value produced by the N-th operation of the
+// same patch (counting from zero) rather than to the document. The value an
+// operation produces is the one found at its path right after it was
+// applied; remove operations produce nothing. For example
+//
+//	[
+//	  {"op": "add", "path": "/items/-", "value": {"tags": ["new"]}},
+//	  {"op": "copy", "from": "@0/tags", "path": "/defaults/tags"}
+//	]
+//
+// copies the tags of the appended item without knowing its index.
+
+// parseRef parses an "@N/pointer" reference made by the i-th operation.
+func parseRef(s string, i int) (int, []string, error) {
+	rest := s[1:]
+	j := strings.IndexByte(rest, '/')
+	if j < 0 {
+		j = len(rest)
+	}
+	n, err := pointer.ParseIndex(rest[:j], i-1, false)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid reference %q: must name an earlier operation", s)
+	}
+	p, err := parsePath(rest[j:])
+	return n, p, err
+}
+
+// referencedOps returns the indexes of the operations whose output is
+// referred to by a later operation.
+func referencedOps(operations []Operation) map[int]bool {
+	refs := make(map[int]bool)
+	for i, op := range operations {
+		if op.Op != "copy" || !strings.HasPrefix(op.From, "@") {
+			continue
+		}
+		if n, _, err := parseRef(op.From, i); err == nil {
+			refs[n] = true
+		}
+	}
+	return refs
+}
+
+// capture keeps a copy of the value produced by the i-th operation.
+func (a *applier) capture(root interface{}, i int, ins *instruction, c *command) {
+	v, ok := output(root, ins, c)
+	if !ok {
+		return
+	}
+	if a.outputs == nil {
+		a.outputs = make(map[int]interface{})
+	}
+	a.outputs[i] = deepCopy(v)
+}
+
+// targetPath returns the pointer to the location the command c of ins
+// writes to, with a trailing "-" replaced by the index of the element it
+// appends.
+func targetPath(ins *instruction, c *command) string {
+	switch ins.op.Op {
+	case "add", "copy", "move":
+		return concreteIndex(c)
+	}
+	return pointer.Pointer(c.path).String()
+}
+
+// output returns the value the command c of ins produced in root.
+func output(root interface{}, ins *instruction, c *command) (interface{}, bool) {
+	if ins.op.Op == "remove" {
+		return nil, false
+	}
+	p, err := pointer.Parse(targetPath(ins, c))
+	if err != nil {
+		return nil, false
+	}
+	v, err := p.Get(root)
+	return v, err == nil
+}
+
+// output resolves from within the value produced by the n-th operation.
+func (a *applier) output(n int, from []string) (interface{}, error) {
+	v, ok := a.outputs[n]
+	if !ok {
+		return nil, fmt.Errorf("operation %d produced no value", n)
+	}
+	return pointer.Pointer(from).Get(v)
+}
@@ -0,0 +1,53 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOpRefs(t *testing.T) {
+	opts := WithOpRefs()
+	doc := decode(`{"items": [{"id": 1}], "defaults": {}}`)
+	ops := parseStr(`[
+		{"op": "add", "path": "/items/-", "value": {"id": 2, "tags": ["new"]}},
+		{"op": "replace", "path": "/items/1/id", "value": 3},
+		{"op": "copy", "from": "@0/tags", "path": "/defaults/tags"},
+		{"op": "copy", "from": "@1", "path": "/defaults/id"},
+		{"op": "copy", "from": "@0", "path": "/snapshot"}
+	]`)
+	result, _, err := ApplyWithReport(doc, ops, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := decode(`{
+		"items": [{"id": 1}, {"id": 3, "tags": ["new"]}],
+		"defaults": {"tags": ["new"], "id": 3},
+		"snapshot": {"id": 2, "tags": ["new"]}
+	}`)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestOpRefsErrors(t *testing.T) {
+	opts := WithOpRefs()
+	doc := decode(`{"a": 1}`)
+	for _, s := range []string{
+		`[{"op": "copy", "from": "@0", "path": "/b"}]`,
+		`[{"op": "add", "path": "/b", "value": 1}, {"op": "copy", "from": "@1", "path": "/c"}]`,
+		`[{"op": "add", "path": "/b", "value": 1}, {"op": "copy", "from": "@x", "path": "/c"}]`,
+		`[{"op": "add", "path": "/b", "value": 1}, {"op": "move", "from": "@0", "path": "/c"}]`,
+	} {
+		if _, _, err := ApplyWithReport(doc, parseStr(s), opts); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%s: expected an invalid patch error, got %v", s, err)
+		}
+	}
+	_, _, err := ApplyWithReport(doc, parseStr(`[{"op": "remove", "path": "/a"}, {"op": "copy", "from": "@0", "path": "/c"}]`), opts)
+	if err == nil {
+		t.Error("expected referring to the output of a remove to fail")
+	}
+	if _, err := Apply(doc, parseStr(`[{"op": "add", "path": "/b", "value": 1}, {"op": "copy", "from": "@0", "path": "/c"}]`)); err == nil {
+		t.Error("expected references to be rejected without OpRefs")
+	}
+}
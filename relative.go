@@ -0,0 +1,23 @@
+package patch
+
+// isRelative reports whether path is written as a relative JSON pointer,
+// which starts with a digit where an absolute one starts with '/'.
+func isRelative(path string) bool {
+	return path != "" && '0' <= path[0] && path[0] <= '9'
+}
+
+// resolveRelative returns ins with its relative path resolved against the
+// anchor in root.
+func (a *applier) resolveRelative(root interface{}, ins *instruction) (*instruction, error) {
+	anchor, err := parsePath(a.opts.Anchor)
+	if err != nil {
+		return nil, err
+	}
+	path, err := ins.rel.Resolve(root, anchor)
+	if err != nil {
+		return nil, err
+	}
+	resolved := *ins
+	resolved.rel, resolved.path, resolved.name = nil, path, ins.rel.Name
+	return &resolved, nil
+}
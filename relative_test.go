@@ -0,0 +1,40 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRelativeTest(t *testing.T) {
+	doc := decode(`{"items": [{"id": "a", "qty": 1}, {"id": "b", "qty": 2}]}`)
+	cases := []struct {
+		anchor, patch string
+		err           error
+	}{
+		{"/items/1/qty", `[{"op": "test", "path": "1/id", "value": "b"}]`, nil},
+		{"/items/1/qty", `[{"op": "test", "path": "0", "value": 2}]`, nil},
+		{"/items/1", `[{"op": "test", "path": "0-1/id", "value": "a"}]`, nil},
+		{"/items/1", `[{"op": "test", "path": "0#", "value": 1}]`, nil},
+		{"/items/1/qty", `[{"op": "test", "path": "0#", "value": "qty"}]`, nil},
+		{"/items/1", `[{"op": "test", "path": "0#", "value": 0}]`, ErrTestFailed},
+		{"/items/1", `[{"op": "test", "path": "0/name", "value": "b"}]`, ErrNotFound},
+		{"/items/1", `[{"op": "test", "path": "0+1", "value": "b"}]`, ErrNotFound},
+		{"/items/1", `[{"op": "test", "path": "5/id", "value": "b"}]`, errors.New("")},
+		{"/items/1", `[{"op": "test", "path": "0#x", "value": "b"}]`, ErrInvalidPatch},
+		// relative paths are only for test operations
+		{"/items/1", `[{"op": "replace", "path": "0/id", "value": "b"}]`, ErrInvalidPatch},
+		{"", `[{"op": "test", "path": "0/id", "value": "b"}]`, ErrInvalidPatch},
+	}
+	for _, c := range cases {
+		_, err := Apply(doc, parseStr(c.patch), WithAnchor(c.anchor))
+		switch {
+		case c.err == nil && err != nil:
+			t.Errorf("%s from %s: %v", c.patch, c.anchor, err)
+		case c.err != nil && err == nil:
+			t.Errorf("%s from %s: expected an error", c.patch, c.anchor)
+		case c.err != nil && c.err.Error() != "" && !errors.Is(err, c.err):
+			t.Errorf("%s from %s: expected %v, got %v", c.patch, c.anchor, c.err, err)
+		}
+	}
+
+}
@@ -0,0 +1,205 @@
+package patch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// ErrNothingToUndo is returned by Repl.Undo when no operation is left to
+// revert.
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// Repl is an interactive patch authoring session: operations are applied to
+// a document one at a time, can be undone, and accumulate into a patch that
+// can be exported once the document looks right.
+type Repl struct {
+	doc  interface{}
+	ops  []Operation
+	undo [][]Operation
+}
+
+// NewRepl starts a session on a copy of doc.
+func NewRepl(doc interface{}) *Repl {
+	return &Repl{doc: deepCopy(doc)}
+}
+
+// Doc returns the current document. It must not be modified.
+func (r *Repl) Doc() interface{} { return r.doc }
+
+// Patch returns the operations applied so far, excluding undone ones.
+func (r *Repl) Patch() []Operation { return slices.Clone(r.ops) }
+
+// Load replaces the document and discards the accumulated patch.
+func (r *Repl) Load(doc interface{}) {
+	r.doc, r.ops, r.undo = deepCopy(doc), nil, nil
+}
+
+// Apply applies op to the document and appends it to the patch. The document
+// is left unchanged when op fails.
+func (r *Repl) Apply(op Operation) error {
+	doc, inverse, err := ApplyWithInverse(r.doc, []Operation{op})
+	if err != nil {
+		return err
+	}
+	r.doc = doc
+	r.ops = append(r.ops, op)
+	r.undo = append(r.undo, inverse)
+	return nil
+}
+
+// Undo reverts the last applied operation and removes it from the patch.
+func (r *Repl) Undo() error {
+	if len(r.undo) == 0 {
+		return ErrNothingToUndo
+	}
+	n := len(r.undo) - 1
+	doc, err := Apply(r.doc, r.undo[n])
+	if err != nil {
+		return err
+	}
+	r.doc, r.ops, r.undo = doc, r.ops[:n], r.undo[:n]
+	return nil
+}
+
+// Get returns the value at ptr in the current document.
+func (r *Repl) Get(ptr string) (interface{}, error) {
+	p, err := pointer.Parse(ptr)
+	if err != nil {
+		return nil, err
+	}
+	return p.Get(r.doc)
+}
+
+const replHelp = `commands:
+  get [POINTER]           print the value at POINTER, or the whole document
+  add POINTER VALUE       apply an add operation; VALUE is JSON
+  remove POINTER          apply a remove operation
+  replace POINTER VALUE   apply a replace operation
+  move FROM POINTER       apply a move operation
+  copy FROM POINTER       apply a copy operation
+  test POINTER VALUE      apply a test operation
+  {"op": ...}             apply an operation written as JSON
+  undo                    revert the last operation
+  patch                   print the operations applied so far
+  load FILE               load a new document and start a new patch
+  help                    print this message
+  quit                    end the session
+`
+
+// errQuit is returned by Exec for the quit command.
+var errQuit = errors.New("quit")
+
+// Exec runs one command line, as accepted by Run, and returns its output.
+// Pointers given as arguments cannot contain whitespace; operations on such
+// pointers can be written as JSON instead.
+func (r *Repl) Exec(line string) (string, error) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "{") {
+		var op Operation
+		if err := json.Unmarshal([]byte(line), &op); err != nil {
+			return "", err
+		}
+		return "", r.Apply(op)
+	}
+	cmd, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+	switch cmd {
+	case "":
+		return "", nil
+	case "get":
+		v, err := r.Get(rest)
+		if err != nil {
+			return "", err
+		}
+		return indent(v)
+	case "add", "replace", "test":
+		ptr, value, _ := strings.Cut(rest, " ")
+		value = strings.TrimSpace(value)
+		if ptr == "" || value == "" {
+			return "", fmt.Errorf("usage: %s POINTER VALUE", cmd)
+		}
+		if !json.Valid([]byte(value)) {
+			return "", fmt.Errorf("invalid JSON value %s", value)
+		}
+		return "", r.Apply(Operation{Op: cmd, Path: ptr, Value: json.RawMessage(value)})
+	case "remove":
+		if rest == "" {
+			return "", errors.New("usage: remove POINTER")
+		}
+		return "", r.Apply(Operation{Op: cmd, Path: rest})
+	case "move", "copy":
+		from, ptr, _ := strings.Cut(rest, " ")
+		ptr = strings.TrimSpace(ptr)
+		if from == "" || ptr == "" {
+			return "", fmt.Errorf("usage: %s FROM POINTER", cmd)
+		}
+		return "", r.Apply(Operation{Op: cmd, From: from, Path: ptr})
+	case "undo":
+		return "", r.Undo()
+	case "patch":
+		return indent(r.ops)
+	case "load":
+		data, err := os.ReadFile(rest)
+		if err != nil {
+			return "", err
+		}
+		var doc interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return "", err
+		}
+		r.Load(doc)
+		return "", nil
+	case "help":
+		return replHelp, nil
+	case "quit", "exit":
+		return "", errQuit
+	}
+	return "", fmt.Errorf("unknown command %q (try help)", cmd)
+}
+
+// Run reads commands from in until it is exhausted or the quit command is
+// given, writing a prompt, the output of each command and any errors to
+// out.
+func (r *Repl) Run(in io.Reader, out io.Writer) error {
+	s := bufio.NewScanner(in)
+	s.Buffer(nil, 1<<24)
+	for {
+		fmt.Fprint(out, "> ")
+		if !s.Scan() {
+			fmt.Fprintln(out)
+			return s.Err()
+		}
+		result, err := r.Exec(s.Text())
+		if err == errQuit {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+			continue
+		}
+		if result != "" {
+			fmt.Fprint(out, strings.TrimSuffix(result, "\n")+"\n")
+		}
+	}
+}
+
+func indent(v interface{}) (string, error) {
+	data, err := marshal(v)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,71 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRepl(t *testing.T) {
+	r := NewRepl(decode(`{"a": 1, "list": [1, 2]}`))
+	for _, line := range []string{
+		`add /b {"c": true}`,
+		`remove /list/0`,
+		`{"op": "move", "from": "/a", "path": "/d"}`,
+		`copy /b /e`,
+		`undo`,
+		`test /d 1`,
+	} {
+		if _, err := r.Exec(line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+	}
+	expected := decode(`{"b": {"c": true}, "d": 1, "list": [2]}`)
+	if !reflect.DeepEqual(r.Doc(), expected) {
+		t.Errorf("expected %v, got %v", expected, r.Doc())
+	}
+	if n := len(r.Patch()); n != 4 {
+		t.Errorf("expected 4 operations, got %d", n)
+	}
+	out, err := r.Exec("get /b")
+	if err != nil || out != "{\n  \"c\": true\n}" {
+		t.Errorf("get: %q %v", out, err)
+	}
+
+	// the patch replays to the same document
+	replayed, err := Apply(decode(`{"a": 1, "list": [1, 2]}`), r.Patch())
+	if err != nil || !reflect.DeepEqual(replayed, expected) {
+		t.Errorf("replay: %v %v", replayed, err)
+	}
+
+	for _, line := range []string{`test /d 2`, `add /x`, `add /x {`, `get /missing`, `frob`} {
+		if _, err := r.Exec(line); err == nil {
+			t.Errorf("%s: expected an error", line)
+		}
+	}
+	if !reflect.DeepEqual(r.Doc(), expected) {
+		t.Errorf("failed commands changed the document: %v", r.Doc())
+	}
+}
+
+func TestReplUndo(t *testing.T) {
+	r := NewRepl(nil)
+	if err := r.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("expected ErrNothingToUndo, got %v", err)
+	}
+}
+
+func TestReplRun(t *testing.T) {
+	var out strings.Builder
+	r := NewRepl(decode(`{}`))
+	if err := r.Run(strings.NewReader("add /a 1\nbogus\nquit\nadd /b 2\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `error: unknown command "bogus"`) {
+		t.Errorf("expected an error in the output, got %q", out.String())
+	}
+	if !reflect.DeepEqual(r.Doc(), decode(`{"a": 1}`)) {
+		t.Errorf("expected the session to stop at quit, got %v", r.Doc())
+	}
+}
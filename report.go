@@ -0,0 +1,185 @@
+package patch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"unicode/utf8"
+)
+
+// Report describes the changes made while applying a patch.
+type Report struct {
+	// Changes are in the order they were made: by operation, in the order
+	// the operations were applied, and then, for an operation with several
+	// targets, in the order of its targets, as described for
+	// Options.AfterOp. The source of a move precedes its destination.
+	Changes []Change
+}
+
+// Touched returns the pointers modified by the patch, in the order they
+// were first modified, leaving out changes that were no-ops.
+func (r *Report) Touched() []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, ch := range r.Changes {
+		if ch.NoOp || seen[ch.Path] {
+			continue
+		}
+		seen[ch.Path] = true
+		out = append(out, ch.Path)
+	}
+	return out
+}
+
+// ChangeKind classifies a Change.
+type ChangeKind string
+
+const (
+	// ChangeAdded is a value added where there was none.
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved is a value removed, including the source of a move.
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeReplaced is a value overwritten, by a replace or by an add, copy
+	// or move onto an existing object member.
+	ChangeReplaced ChangeKind = "replaced"
+	// ChangeMoved is the destination of a move where there was no value.
+	ChangeMoved ChangeKind = "moved"
+)
+
+// Change records a single pointer modified by an operation. A move produces
+// two changes: one for "from", of kind ChangeRemoved, and one for "path",
+// whose From is set.
+type Change struct {
+	Index  int        // index of the operation within the patch
+	Op     string     // operator name
+	Kind   ChangeKind // empty for custom operators
+	Path   string     // the pointer that was modified
+	From   string     // for the destination of a move, where the value came from
+	Before *Image     // the prior value, if captured and one existed
+	// NoOp is true when the operation left the value at Path as it was:
+	// a replace, add or copy writing an identical value, or a move onto
+	// itself.
+	NoOp bool
+}
+
+// Image is a copy of a value as it was before being modified. Values whose
+// serialized size exceeds Options.BeforeLimit are not kept; only a prefix of
+// their serialization, of at most BeforeLimit bytes and ending on a rune
+// boundary, and its digest are recorded.
+type Image struct {
+	Value     interface{}
+	Size      int
+	Truncated bool
+	Prefix    string
+	Digest    string
+}
+
+func (a *applier) record(root interface{}, i int, op *Operation, c *command) {
+	switch op.Op {
+	case "test":
+		return
+	case "move":
+		from, err := a.makeCommand(root, &instruction{path: c.from})
+		if err != nil {
+			// applyMove will report the same failure
+			return
+		}
+		ch := a.change(i, op, op.From, from, false)
+		ch.Kind = ChangeRemoved
+		ch.NoOp = op.From == op.Path
+	}
+	ch := a.change(i, op, op.Path, c, op.Op != "remove" && op.Op != "replace")
+	prior, exists := c.prior(op.Op != "remove" && op.Op != "replace")
+	switch op.Op {
+	case "remove":
+		ch.Kind = ChangeRemoved
+	case "add", "replace", "copy", "move":
+		ch.Kind = ChangeAdded
+		if exists {
+			ch.Kind = ChangeReplaced
+		} else if op.Op == "move" {
+			ch.Kind = ChangeMoved
+		}
+		if op.Op == "move" || op.Op == "copy" {
+			ch.From = op.From
+		}
+		ch.NoOp = exists && a.writes(root, op, c, prior)
+	}
+}
+
+// writes reports whether the value written by the add, replace, copy or move
+// command c is equal to prior.
+func (a *applier) writes(root interface{}, op *Operation, c *command, prior interface{}) bool {
+	switch op.Op {
+	case "move":
+		return op.From == op.Path
+	case "copy":
+		if c.ref != nil {
+			return false
+		}
+		from, err := a.makeCommand(root, &instruction{path: c.from})
+		if err != nil {
+			return false
+		}
+		v, ok := from.prior(false)
+		return ok && jsonEqual(v, prior)
+	}
+	return jsonEqual(c.value, prior)
+}
+
+func (a *applier) change(i int, op *Operation, path string, c *command, inserting bool) *Change {
+	ch := Change{Index: i, Op: op.Op, Path: path}
+	if a.opts.CaptureBefore {
+		if v, ok := c.prior(inserting); ok {
+			ch.Before = a.opts.image(v)
+		}
+	}
+	a.report.Changes = append(a.report.Changes, ch)
+	return &a.report.Changes[len(a.report.Changes)-1]
+}
+
+// prior returns the value currently at the command's target, if there is
+// one. Inserting into an array never displaces an existing value.
+func (c *command) prior(inserting bool) (interface{}, bool) {
+	if c.pathLen == 0 {
+		return c.current, true
+	}
+	switch p := c.parent.(type) {
+	case map[string]interface{}:
+		v, ok := p[c.key]
+		return v, ok
+	case *SortedObject:
+		return p.Get(c.key)
+	case []interface{}:
+		if inserting {
+			return nil, false
+		}
+		i, err := elementIndex(p, c.key, false)
+		if err != nil {
+			return nil, false
+		}
+		return p[i], true
+	}
+	return nil, false
+}
+
+func (o *Options) image(v interface{}) *Image {
+	b, err := o.codec().Marshal(v)
+	if err != nil {
+		return &Image{Value: deepCopy(v)}
+	}
+	img := &Image{Size: len(b)}
+	if o.BeforeLimit > 0 && len(b) > o.BeforeLimit {
+		sum := sha256.Sum256(b)
+		img.Truncated = true
+		// cut on a rune boundary so that the prefix stays valid UTF-8
+		n := o.BeforeLimit
+		for n > 0 && !utf8.RuneStart(b[n]) {
+			n--
+		}
+		img.Prefix = string(b[:n])
+		img.Digest = "sha256:" + hex.EncodeToString(sum[:])
+		return img
+	}
+	img.Value = deepCopy(v)
+	return img
+}
@@ -0,0 +1,161 @@
+package patch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReportBeforeImages(t *testing.T) {
+	doc := map[string]interface{}{
+		"name": "old",
+		"list": []interface{}{"a", "b"},
+		"big":  strings.Repeat("x", 100),
+	}
+	ops := parseStr(`[
+		{"op": "replace", "path": "/name", "value": "new"},
+		{"op": "add", "path": "/list/1", "value": "c"},
+		{"op": "remove", "path": "/big"},
+		{"op": "move", "from": "/list/0", "path": "/first"},
+		{"op": "test", "path": "/name", "value": "new"}
+	]`)
+	_, report, err := ApplyWithReport(doc, ops, WithOptions(Options{CaptureBefore: true, BeforeLimit: 20}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changes) != 5 {
+		t.Fatalf("expected 5 changes, got %d", len(report.Changes))
+	}
+	if img := report.Changes[0].Before; img == nil || img.Value != "old" {
+		t.Errorf("expected prior value of /name to be captured, got %+v", img)
+	}
+	if img := report.Changes[1].Before; img != nil {
+		t.Errorf("expected no prior value for an array insert, got %+v", img)
+	}
+	img := report.Changes[2].Before
+	if img == nil || !img.Truncated || img.Value != nil || len(img.Prefix) != 20 || img.Size != 102 {
+		t.Errorf("expected /big to be truncated, got %+v", img)
+	}
+	if !strings.HasPrefix(img.Digest, "sha256:") {
+		t.Errorf("expected a sha256 digest, got %q", img.Digest)
+	}
+	if ch := report.Changes[3]; ch.Path != "/list/0" || ch.Before.Value != "a" {
+		t.Errorf("expected move source to be recorded, got %+v", ch)
+	}
+	if ch := report.Changes[4]; ch.Path != "/first" || ch.Before != nil {
+		t.Errorf("expected move destination to be recorded, got %+v", ch)
+	}
+	if doc["name"] != "old" {
+		t.Errorf("original document was modified")
+	}
+}
+
+func TestReportPrefixRuneBoundary(t *testing.T) {
+	doc := map[string]interface{}{"s": "ééééé"}
+	ops := parseStr(`[{"op": "remove", "path": "/s"}]`)
+	_, report, err := ApplyWithReport(doc, ops, WithOptions(Options{CaptureBefore: true, BeforeLimit: 4}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img := report.Changes[0].Before; img == nil || !img.Truncated || img.Prefix != `"é` {
+		t.Errorf("expected the prefix to end before the split rune, got %+v", img)
+	}
+}
+
+func TestReportWithoutCapture(t *testing.T) {
+	_, report, err := ApplyWithReport(
+		map[string]interface{}{"a": 1.0},
+		parseStr(`[{"op": "remove", "path": "/a"}]`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Change{{Index: 0, Op: "remove", Kind: ChangeRemoved, Path: "/a"}}
+	if !reflect.DeepEqual(report.Changes, expected) {
+		t.Errorf("expected %v, got %v", expected, report.Changes)
+	}
+}
+
+func TestApplyInPlace(t *testing.T) {
+	doc := map[string]interface{}{"a": 1.0, "list": []interface{}{1.0}}
+	ops := parseStr(`[{"op": "replace", "path": "/a", "value": 2}, {"op": "add", "path": "/list/-", "value": 2}]`)
+	result, _, err := ApplyWithReport(doc, ops, WithInPlace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["a"] != 2.0 {
+		t.Error("expected the document to be modified in place")
+	}
+	expected := map[string]interface{}{"a": 2.0, "list": []interface{}{1.0, 2.0}}
+	if !reflect.DeepEqual(result, expected) || !reflect.DeepEqual(doc, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestReportKinds(t *testing.T) {
+	doc := decode(`{"a": 1, "b": {"c": [1, 2]}, "list": ["x", "y"]}`)
+	ops := parseStr(`[
+		{"op": "replace", "path": "/a", "value": 1.0},
+		{"op": "replace", "path": "/a", "value": 2},
+		{"op": "add", "path": "/n", "value": null},
+		{"op": "add", "path": "/n", "value": null},
+		{"op": "add", "path": "/list/0", "value": "w"},
+		{"op": "copy", "from": "/b", "path": "/d"},
+		{"op": "copy", "from": "/b", "path": "/d"},
+		{"op": "move", "from": "/d", "path": "/e"},
+		{"op": "move", "from": "/e", "path": "/e"},
+		{"op": "remove", "path": "/n"},
+		{"op": "test", "path": "/a", "value": 2}
+	]`)
+	_, report, err := ApplyWithReport(doc, ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Change{
+		{Index: 0, Op: "replace", Kind: ChangeReplaced, Path: "/a", NoOp: true},
+		{Index: 1, Op: "replace", Kind: ChangeReplaced, Path: "/a"},
+		{Index: 2, Op: "add", Kind: ChangeAdded, Path: "/n"},
+		{Index: 3, Op: "add", Kind: ChangeReplaced, Path: "/n", NoOp: true},
+		{Index: 4, Op: "add", Kind: ChangeAdded, Path: "/list/0"},
+		{Index: 5, Op: "copy", Kind: ChangeAdded, Path: "/d", From: "/b"},
+		{Index: 6, Op: "copy", Kind: ChangeReplaced, Path: "/d", From: "/b", NoOp: true},
+		{Index: 7, Op: "move", Kind: ChangeRemoved, Path: "/d"},
+		{Index: 7, Op: "move", Kind: ChangeMoved, Path: "/e", From: "/d"},
+		{Index: 8, Op: "move", Kind: ChangeRemoved, Path: "/e", NoOp: true},
+		{Index: 8, Op: "move", Kind: ChangeReplaced, Path: "/e", From: "/e", NoOp: true},
+		{Index: 9, Op: "remove", Kind: ChangeRemoved, Path: "/n"},
+	}
+	if !reflect.DeepEqual(report.Changes, expected) {
+		t.Errorf("expected\n%+v\ngot\n%+v", expected, report.Changes)
+	}
+	touched := []string{"/a", "/n", "/list/0", "/d", "/e"}
+	if got := report.Touched(); !reflect.DeepEqual(got, touched) {
+		t.Errorf("expected touched pointers %v, got %v", touched, got)
+	}
+}
+
+func TestReportOrder(t *testing.T) {
+	ops := parseStr(`[
+		{"op": "replace", "path": "/m/*", "value": 0},
+		{"op": "remove", "path": "/list/*"}
+	]`)
+	var expected []string
+	for _, k := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		expected = append(expected, "/m/"+k)
+	}
+	expected = append(expected, "/list/2", "/list/1", "/list/0")
+	for run := 0; run < 20; run++ {
+		doc := decode(`{"m": {"h": 1, "g": 1, "f": 1, "e": 1, "d": 1, "c": 1, "b": 1, "a": 1}, "list": [1, 2, 3]}`)
+		var calls []string
+		opts := WithOptions(Options{Wildcards: true, AfterOp: func(op Operation, _, _ interface{}) {
+			calls = append(calls, op.Path)
+		}})
+		_, report, err := ApplyWithReport(doc, ops, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(calls, expected) || !reflect.DeepEqual(report.Touched(), expected) {
+			t.Fatalf("expected %v, got calls %v and changes %v", expected, calls, report.Touched())
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package patch
+
+import "strings"
+
+// RewritePaths returns a copy of operations with the path, and the from
+// pointer of operations that have one, passed through mapping. It is meant
+// for rebasing patches written against one document layout onto another.
+func RewritePaths(operations []Operation, mapping func(ptr string) string) []Operation {
+	out := make([]Operation, len(operations))
+	for i, op := range operations {
+		op.Path = mapping(op.Path)
+		if op.From != "" {
+			op.From = mapping(op.From)
+		}
+		out[i] = op
+	}
+	return out
+}
+
+// PrefixMapping declares how pointers are relocated: each key is a pointer
+// prefix, and pointers starting with it have it replaced by the value.
+// Prefixes match whole reference tokens, so "/a" matches "/a" and "/a/b" but
+// not "/ab", and the longest matching prefix wins. Pointers matching no
+// prefix are left unchanged.
+//
+//	ops = RewritePaths(ops, PrefixMapping{"/spec/replicas": "/spec/scale/replicas"}.Map)
+type PrefixMapping map[string]string
+
+// Map relocates ptr according to the mapping.
+func (m PrefixMapping) Map(ptr string) string {
+	if mapped, ok := m.lookup(ptr); ok {
+		return mapped
+	}
+	return ptr
+}
+
+// lookup relocates ptr according to the mapping, and reports whether a
+// prefix matched it.
+func (m PrefixMapping) lookup(ptr string) (string, bool) {
+	best, found := "", false
+	for prefix := range m {
+		if len(prefix) < len(best) || found && len(prefix) == len(best) {
+			continue
+		}
+		if prefix == "" || ptr == prefix || strings.HasPrefix(ptr, prefix+"/") {
+			best, found = prefix, true
+		}
+	}
+	if !found {
+		return ptr, false
+	}
+	return m[best] + ptr[len(best):], true
+}
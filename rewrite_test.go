@@ -0,0 +1,50 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPrefixMapping(t *testing.T) {
+	m := PrefixMapping{
+		"/a":        "/x",
+		"/a/b":      "/y",
+		"/settings": "/config/settings",
+	}
+	cases := map[string]string{
+		"/a":              "/x",
+		"/a/c":            "/x/c",
+		"/a/b":            "/y",
+		"/a/b/c":          "/y/c",
+		"/ab":             "/ab",
+		"/settings/theme": "/config/settings/theme",
+		"/other":          "/other",
+		"":                "",
+	}
+	for in, expected := range cases {
+		if got := m.Map(in); got != expected {
+			t.Errorf("%q: expected %q, got %q", in, expected, got)
+		}
+	}
+	if got := (PrefixMapping{"": "/root"}).Map("/a"); got != "/root/a" {
+		t.Errorf("expected the empty prefix to match everything, got %q", got)
+	}
+}
+
+func TestRewritePaths(t *testing.T) {
+	ops := parseStr(`[
+		{"op": "replace", "path": "/old/name", "value": "x"},
+		{"op": "move", "from": "/old/a", "path": "/other"}
+	]`)
+	out := RewritePaths(ops, PrefixMapping{"/old": "/new"}.Map)
+	expected := parseStr(`[
+		{"op": "replace", "path": "/new/name", "value": "x"},
+		{"op": "move", "from": "/new/a", "path": "/other"}
+	]`)
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("expected %v, got %v", expected, out)
+	}
+	if ops[0].Path != "/old/name" {
+		t.Error("RewritePaths modified its input")
+	}
+}
@@ -0,0 +1,546 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// ErrSchemaInvalid matches errors for patched documents that do not conform
+// to Options.Schema.
+var ErrSchemaInvalid = errors.New("patched document does not match its schema")
+
+// maxRefDepth bounds the number of nested $refs followed while validating,
+// so that a schema referring to itself without descending into the document
+// is reported instead of recursing forever.
+const maxRefDepth = 1000
+
+// Schema is a JSON Schema the patched documents must conform to. It
+// implements the validation keywords of JSON Schema 2020-12 and of the
+// drafts before it:
+//
+//   - type, enum and const;
+//   - multipleOf, maximum, exclusiveMaximum, minimum and exclusiveMinimum,
+//     compared exactly, the exclusive ones as numbers or, as in draft 4, as
+//     booleans;
+//   - maxLength and minLength, in code points, and pattern, a regular
+//     expression in the syntax of the regexp package;
+//   - prefixItems, items, as a schema or, as before 2020-12, an array of
+//     schemas followed by additionalItems, contains, maxItems, minItems and
+//     uniqueItems;
+//   - properties, patternProperties, additionalProperties, propertyNames,
+//     required, dependentRequired, maxProperties and minProperties;
+//   - allOf, anyOf, oneOf, not, and if, then and else;
+//   - $ref, to a location within the schema such as "#/$defs/address".
+//
+// Other keywords, such as format, title or default, are ignored, as are
+// references to other documents, which ParseSchema refuses. A Schema is
+// encoded to and decoded from the JSON it was parsed from, so it can be
+// loaded along with the other Options.
+type Schema struct {
+	root    interface{}
+	data    json.RawMessage
+	regexps map[string]*regexp.Regexp
+}
+
+// SchemaError lists the constraints of Options.Schema a patched document
+// violates, in the same order on every run. The Ops of a Violation are the
+// operations that modified the value at its Path, one of its parents or
+// one of its children.
+type SchemaError struct {
+	Violations []Violation
+}
+
+func (e *SchemaError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.Path + ": " + v.Message
+		if len(v.Ops) > 0 {
+			parts[i] += fmt.Sprintf(" (operations %v)", v.Ops)
+		}
+	}
+	return "document does not match its schema: " + strings.Join(parts, "; ")
+}
+
+// Is makes SchemaError match ErrSchemaInvalid.
+func (e *SchemaError) Is(target error) bool { return target == ErrSchemaInvalid }
+
+// Code returns "schema-invalid".
+func (e *SchemaError) Code() string { return "schema-invalid" }
+
+// WithSchema validates patched documents against s. See Options.Schema.
+func WithSchema(s *Schema) Option {
+	return func(o *Options) { o.Schema = s }
+}
+
+// ParseSchema parses a JSON Schema, checking that its subschemas are
+// objects or booleans, that its patterns compile and that its references
+// resolve.
+func ParseSchema(data []byte) (*Schema, error) {
+	s := &Schema{regexps: make(map[string]*regexp.Regexp)}
+	if err := unmarshalNumber(data, &s.root); err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	s.data = slices.Clone(data)
+	if err := s.compile(s.root, nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// MarshalJSON returns the JSON s was parsed from.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	return s.data, nil
+}
+
+// UnmarshalJSON parses a JSON Schema into s, as ParseSchema does.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	parsed, err := ParseSchema(data)
+	if err != nil {
+		return err
+	}
+	*s = *parsed
+	return nil
+}
+
+// Validate checks doc against s and returns a *SchemaError listing the
+// constraints it violates, or nil.
+func (s *Schema) Validate(doc interface{}) error {
+	var out []Violation
+	s.validate(s.root, doc, nil, 0, &out)
+	if len(out) > 0 {
+		return &SchemaError{Violations: out}
+	}
+	return nil
+}
+
+// schemaKeywords lists the keywords whose value is a subschema, an array of
+// subschemas or an object of subschemas.
+var (
+	schemaKeywords      = []string{"additionalProperties", "additionalItems", "items", "contains", "propertyNames", "not", "if", "then", "else"}
+	schemaArrayKeywords = []string{"allOf", "anyOf", "oneOf", "prefixItems", "items"}
+	schemaMapKeywords   = []string{"properties", "patternProperties", "$defs", "definitions", "dependentSchemas"}
+)
+
+// compile checks the subschema schema, found at path in the schema, and
+// those it contains.
+func (s *Schema) compile(schema interface{}, path pointer.Pointer) error {
+	if _, ok := schema.(bool); ok {
+		return nil
+	}
+	m, ok := schema.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("schema %s: a schema must be an object or a boolean, not %s", path, schemaType(schema))
+	}
+	sub := func(token ...string) pointer.Pointer {
+		return append(path[:len(path):len(path)], token...)
+	}
+	if p, ok := m["pattern"].(string); ok {
+		if err := s.regexp(p, sub("pattern")); err != nil {
+			return err
+		}
+	}
+	if pp, ok := m["patternProperties"].(map[string]interface{}); ok {
+		for p := range pp {
+			if err := s.regexp(p, sub("patternProperties", p)); err != nil {
+				return err
+			}
+		}
+	}
+	if ref, ok := m["$ref"].(string); ok {
+		if _, err := s.resolve(ref); err != nil {
+			return fmt.Errorf("schema %s: %w", sub("$ref"), err)
+		}
+	}
+	for _, k := range schemaKeywords {
+		if v, ok := m[k]; ok {
+			if _, isArray := v.([]interface{}); isArray && k == "items" {
+				continue
+			}
+			if err := s.compile(v, sub(k)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, k := range schemaArrayKeywords {
+		if v, ok := m[k].([]interface{}); ok {
+			for i, x := range v {
+				if err := s.compile(x, sub(k, fmt.Sprint(i))); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, k := range schemaMapKeywords {
+		if v, ok := m[k].(map[string]interface{}); ok {
+			for _, name := range slices.Sorted(maps.Keys(v)) {
+				if err := s.compile(v[name], sub(k, name)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// regexp compiles the pattern p, found at path in the schema.
+func (s *Schema) regexp(p string, path pointer.Pointer) error {
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return fmt.Errorf("schema %s: %w", path, err)
+	}
+	s.regexps[p] = re
+	return nil
+}
+
+// resolve returns the subschema ref refers to.
+func (s *Schema) resolve(ref string) (interface{}, error) {
+	fragment, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("cannot resolve %s: only references within the schema are supported", ref)
+	}
+	fragment, err := url.PathUnescape(fragment)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve %s: %w", ref, err)
+	}
+	ptr, err := pointer.Parse(fragment)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve %s: %w", ref, err)
+	}
+	v, err := ptr.Get(s.root)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve %s: %w", ref, err)
+	}
+	return v, nil
+}
+
+// valid reports whether v, at path in the document, conforms to schema.
+func (s *Schema) valid(schema, v interface{}, path pointer.Pointer, refs int) bool {
+	var out []Violation
+	s.validate(schema, v, path, refs, &out)
+	return len(out) == 0
+}
+
+// validate appends the violations of schema by v, found at path in the
+// document, to out. refs is the number of $refs followed to get to schema.
+func (s *Schema) validate(schema, v interface{}, path pointer.Pointer, refs int, out *[]Violation) {
+	fail := func(format string, args ...interface{}) {
+		*out = append(*out, Violation{Path: path.String(), Message: fmt.Sprintf(format, args...)})
+	}
+	switch schema {
+	case true:
+		return
+	case false:
+		fail("no value is allowed here")
+		return
+	}
+	m, _ := schema.(map[string]interface{})
+	if ref, ok := m["$ref"].(string); ok {
+		target, err := s.resolve(ref)
+		switch {
+		case err != nil:
+			fail("%v", err)
+		case refs == maxRefDepth:
+			fail("more than %d nested references to follow", maxRefDepth)
+		default:
+			s.validate(target, v, path, refs+1, out)
+		}
+	}
+
+	typ := schemaType(v)
+	if t, ok := m["type"]; ok {
+		types, _ := t.([]interface{})
+		if name, ok := t.(string); ok {
+			types = []interface{}{name}
+		}
+		if !slices.ContainsFunc(types, func(t interface{}) bool { return hasType(v, typ, t) }) {
+			fail("expected %s, found %s", joinTypes(types), typ)
+		}
+	}
+	if enum, ok := m["enum"].([]interface{}); ok {
+		if !slices.ContainsFunc(enum, func(x interface{}) bool { return jsonEqual(x, v) }) {
+			fail("must be one of %s", encodeSchema(enum))
+		}
+	}
+	if c, ok := m["const"]; ok && !jsonEqual(c, v) {
+		fail("must be %s", encodeSchema(c))
+	}
+
+	switch typ {
+	case "number":
+		s.validateNumber(m, v, fail)
+	case "string":
+		str := v.(string)
+		n := utf8.RuneCountInString(str)
+		if max, ok := schemaInt(m["maxLength"]); ok && n > max {
+			fail("must be at most %d characters long", max)
+		}
+		if min, ok := schemaInt(m["minLength"]); ok && n < min {
+			fail("must be at least %d characters long", min)
+		}
+		if p, ok := m["pattern"].(string); ok && !s.regexps[p].MatchString(str) {
+			fail("must match %s", p)
+		}
+	case "array":
+		s.validateArray(m, v.([]interface{}), path, refs, out, fail)
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			obj = v.(*SortedObject).Map()
+		}
+		s.validateObject(m, obj, path, refs, out, fail)
+	}
+
+	if allOf, ok := m["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			s.validate(sub, v, path, refs, out)
+		}
+	}
+	if anyOf, ok := m["anyOf"].([]interface{}); ok {
+		if !slices.ContainsFunc(anyOf, func(sub interface{}) bool { return s.valid(sub, v, path, refs) }) {
+			fail("must match at least one schema of anyOf")
+		}
+	}
+	if oneOf, ok := m["oneOf"].([]interface{}); ok {
+		matches := 0
+		for _, sub := range oneOf {
+			if s.valid(sub, v, path, refs) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			fail("must match exactly one schema of oneOf, matches %d", matches)
+		}
+	}
+	if not, ok := m["not"]; ok && s.valid(not, v, path, refs) {
+		fail("must not match the schema of not")
+	}
+	if cond, ok := m["if"]; ok {
+		if s.valid(cond, v, path, refs) {
+			if then, ok := m["then"]; ok {
+				s.validate(then, v, path, refs, out)
+			}
+		} else if els, ok := m["else"]; ok {
+			s.validate(els, v, path, refs, out)
+		}
+	}
+}
+
+// validateNumber checks the numeric keywords of schema against v.
+func (s *Schema) validateNumber(schema map[string]interface{}, v interface{}, fail func(string, ...interface{})) {
+	n, _ := toRat(v)
+	bound := func(k string) (*big.Rat, bool) {
+		r, ok := toRat(schema[k])
+		return r, ok && schema[k] != nil
+	}
+	if d, ok := bound("multipleOf"); ok && d.Sign() > 0 && !new(big.Rat).Quo(n, d).IsInt() {
+		fail("must be a multiple of %s", d.RatString())
+	}
+	// before draft 6, exclusiveMaximum and exclusiveMinimum are booleans
+	// making maximum and minimum exclusive
+	if max, ok := bound("maximum"); ok {
+		if c := n.Cmp(max); c > 0 {
+			fail("must be at most %s", max.RatString())
+		} else if c == 0 && schema["exclusiveMaximum"] == true {
+			fail("must be less than %s", max.RatString())
+		}
+	}
+	if max, ok := bound("exclusiveMaximum"); ok && n.Cmp(max) >= 0 {
+		fail("must be less than %s", max.RatString())
+	}
+	if min, ok := bound("minimum"); ok {
+		if c := n.Cmp(min); c < 0 {
+			fail("must be at least %s", min.RatString())
+		} else if c == 0 && schema["exclusiveMinimum"] == true {
+			fail("must be greater than %s", min.RatString())
+		}
+	}
+	if min, ok := bound("exclusiveMinimum"); ok && n.Cmp(min) <= 0 {
+		fail("must be greater than %s", min.RatString())
+	}
+}
+
+// validateArray checks the array keywords of schema against a.
+func (s *Schema) validateArray(schema map[string]interface{}, a []interface{}, path pointer.Pointer, refs int, out *[]Violation, fail func(string, ...interface{})) {
+	elem := func(i int) pointer.Pointer {
+		return append(path[:len(path):len(path)], fmt.Sprint(i))
+	}
+	if max, ok := schemaInt(schema["maxItems"]); ok && len(a) > max {
+		fail("must have at most %d elements", max)
+	}
+	if min, ok := schemaInt(schema["minItems"]); ok && len(a) < min {
+		fail("must have at least %d elements", min)
+	}
+	if schema["uniqueItems"] == true {
+	unique:
+		for i := range a {
+			for j := i + 1; j < len(a); j++ {
+				if jsonEqual(a[i], a[j]) {
+					fail("elements %d and %d are equal", i, j)
+					break unique
+				}
+			}
+		}
+	}
+	prefix, _ := schema["prefixItems"].([]interface{})
+	rest, hasRest := schema["items"]
+	if tuple, ok := rest.([]interface{}); ok {
+		prefix = tuple
+		rest, hasRest = schema["additionalItems"]
+	}
+	for i, x := range a {
+		switch {
+		case i < len(prefix):
+			s.validate(prefix[i], x, elem(i), refs, out)
+		case hasRest:
+			s.validate(rest, x, elem(i), refs, out)
+		}
+	}
+	if contains, ok := schema["contains"]; ok {
+		if !slices.ContainsFunc(a, func(x interface{}) bool { return s.valid(contains, x, path, refs) }) {
+			fail("must contain an element matching the schema of contains")
+		}
+	}
+}
+
+// validateObject checks the object keywords of schema against obj.
+func (s *Schema) validateObject(schema, obj map[string]interface{}, path pointer.Pointer, refs int, out *[]Violation, fail func(string, ...interface{})) {
+	if max, ok := schemaInt(schema["maxProperties"]); ok && len(obj) > max {
+		fail("must have at most %d members", max)
+	}
+	if min, ok := schemaInt(schema["minProperties"]); ok && len(obj) < min {
+		fail("must have at least %d members", min)
+	}
+	// missing members are reported at their own path, so that they are
+	// blamed on the operations removing them
+	missing := func(name, message string) {
+		member := append(path[:len(path):len(path)], name)
+		*out = append(*out, Violation{Path: member.String(), Message: message})
+	}
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, ok := obj[name]; !ok {
+					missing(name, "required member is missing")
+				}
+			}
+		}
+	}
+	if deps, ok := schema["dependentRequired"].(map[string]interface{}); ok {
+		for _, name := range slices.Sorted(maps.Keys(deps)) {
+			if _, ok := obj[name]; !ok {
+				continue
+			}
+			required, _ := deps[name].([]interface{})
+			for _, r := range required {
+				if r, ok := r.(string); ok {
+					if _, ok := obj[r]; !ok {
+						missing(r, fmt.Sprintf("member is missing, required along with %q", name))
+					}
+				}
+			}
+		}
+	}
+	if deps, ok := schema["dependentSchemas"].(map[string]interface{}); ok {
+		for _, name := range slices.Sorted(maps.Keys(deps)) {
+			if _, ok := obj[name]; ok {
+				s.validate(deps[name], obj, path, refs, out)
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	patterns, _ := schema["patternProperties"].(map[string]interface{})
+	additional, hasAdditional := schema["additionalProperties"]
+	names, hasNames := schema["propertyNames"]
+	for _, name := range slices.Sorted(maps.Keys(obj)) {
+		member := append(path[:len(path):len(path)], name)
+		if hasNames && !s.valid(names, name, member, refs) {
+			fail("member name %q does not match the schema of propertyNames", name)
+		}
+		matched := false
+		if sub, ok := properties[name]; ok {
+			s.validate(sub, obj[name], member, refs, out)
+			matched = true
+		}
+		for _, p := range slices.Sorted(maps.Keys(patterns)) {
+			if s.regexps[p].MatchString(name) {
+				s.validate(patterns[p], obj[name], member, refs, out)
+				matched = true
+			}
+		}
+		if !matched && hasAdditional {
+			if additional == false {
+				*out = append(*out, Violation{Path: member.String(), Message: "member is not allowed"})
+				continue
+			}
+			s.validate(additional, obj[name], member, refs, out)
+		}
+	}
+}
+
+// schemaType returns the JSON Schema type of v: "null", "boolean",
+// "number", "string", "array" or "object".
+func schemaType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}, *SortedObject:
+		return "object"
+	}
+	if _, ok := toRat(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// hasType reports whether v, of type typ, is of the type t named by a
+// schema, where integers are the numbers without a fractional part.
+func hasType(v interface{}, typ string, t interface{}) bool {
+	if t == "integer" && typ == "number" {
+		n, _ := toRat(v)
+		return n.IsInt()
+	}
+	return t == typ
+}
+
+// joinTypes formats the types of a type keyword.
+func joinTypes(types []interface{}) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = fmt.Sprint(t)
+	}
+	return strings.Join(names, " or ")
+}
+
+// schemaInt returns the non-negative integer value of a keyword.
+func schemaInt(v interface{}) (int, bool) {
+	r, ok := toRat(v)
+	if !ok || v == nil || !r.IsInt() || !r.Num().IsInt64() {
+		return 0, false
+	}
+	return int(r.Num().Int64()), true
+}
+
+// encodeSchema encodes a value of the schema for a message.
+func encodeSchema(v interface{}) string {
+	b, err := marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,136 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["name", "replicas"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "pattern": "^[a-z]+$"},
+		"replicas": {"type": "integer", "minimum": 0, "maximum": 10},
+		"ports": {"type": "array", "items": {"$ref": "#/$defs/port"}, "uniqueItems": true},
+		"mode": {"enum": ["fast", "safe"]}
+	},
+	"additionalProperties": false,
+	"$defs": {"port": {"type": "integer", "exclusiveMinimum": 0, "exclusiveMaximum": 65536}}
+}`
+
+func TestSchema(t *testing.T) {
+	s, err := ParseSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		doc        string
+		violations []string
+	}{
+		{`{"name": "web", "replicas": 2, "ports": [80, 443], "mode": "safe"}`, nil},
+		{`{"name": "web", "replicas": 2.0}`, nil},
+		{`{"name": "Web", "replicas": 2.5}`, []string{"/name: must match ^[a-z]+$", "/replicas: expected integer, found number"}},
+		{`{"replicas": 11, "extra": true}`, []string{"/name: required member is missing", "/extra: member is not allowed", "/replicas: must be at most 10"}},
+		{`{"name": "web", "replicas": 1, "ports": [80, 80, 0], "mode": "slow"}`, []string{
+			`/mode: must be one of ["fast","safe"]`, "/ports: elements 0 and 1 are equal", "/ports/2: must be greater than 0"}},
+		{`[]`, []string{": expected object, found array"}},
+	}
+	for _, c := range cases {
+		err := s.Validate(decode(c.doc))
+		var got []string
+		var se *SchemaError
+		if errors.As(err, &se) {
+			for _, v := range se.Violations {
+				got = append(got, v.Path+": "+v.Message)
+			}
+		} else if err != nil {
+			t.Fatalf("%s: unexpected error %v", c.doc, err)
+		}
+		if !reflect.DeepEqual(got, c.violations) {
+			t.Errorf("%s: expected %q, got %q", c.doc, c.violations, got)
+		}
+	}
+
+	for _, bad := range []string{
+		`{"pattern": "("}`,
+		`{"$ref": "other.json#/a"}`,
+		`{"$ref": "#/$defs/missing"}`,
+		`{"properties": {"a": 1}}`,
+		`{"type": `,
+	} {
+		if _, err := ParseSchema([]byte(bad)); err == nil {
+			t.Errorf("%s: expected the schema to be refused", bad)
+		}
+	}
+}
+
+func TestSchemaCombinators(t *testing.T) {
+	s, err := ParseSchema([]byte(`{
+		"oneOf": [{"type": "string"}, {"type": "number", "multipleOf": 0.5}],
+		"not": {"const": 3},
+		"if": {"type": "number"}, "then": {"minimum": 0}, "else": {"maxLength": 3}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for doc, valid := range map[string]bool{
+		`"abc"`: true, `"abcd"`: false, `1.5`: true, `1.25`: false, `3`: false, `-1`: false, `null`: false,
+	} {
+		if err := s.Validate(decode(doc)); (err == nil) != valid {
+			t.Errorf("%s: expected valid %v, got %v", doc, valid, err)
+		}
+	}
+
+	// a recursive schema, and one referring to itself forever
+	tree, _ := ParseSchema([]byte(`{"type": "object", "properties": {"children": {"type": "array", "items": {"$ref": "#"}}}}`))
+	if err := tree.Validate(decode(`{"children": [{"children": []}, {"children": [1]}]}`)); err == nil || !strings.Contains(err.Error(), "/children/1/children/0") {
+		t.Errorf("expected the nested child to be reported, got %v", err)
+	}
+	loop, _ := ParseSchema([]byte(`{"$defs": {"a": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`))
+	if err := loop.Validate(decode(`{}`)); err == nil || !strings.Contains(err.Error(), "nested references") {
+		t.Errorf("expected the reference loop to be reported, got %v", err)
+	}
+}
+
+func TestApplyWithSchema(t *testing.T) {
+	s, err := ParseSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := decode(`{"name": "web", "replicas": 2}`)
+	out, err := Apply(doc, parseStr(`[{"op": "replace", "path": "/replicas", "value": 3}]`), WithSchema(s))
+	if err != nil || !jsonEqual(out, decode(`{"name": "web", "replicas": 3}`)) {
+		t.Fatalf("unexpected result %v, %v", out, err)
+	}
+
+	out, err = Apply(doc, parseStr(`[
+		{"op": "add", "path": "/mode", "value": "fast"},
+		{"op": "remove", "path": "/name"},
+		{"op": "replace", "path": "/replicas", "value": -1}
+	]`), WithSchema(s))
+	var se *SchemaError
+	if out != nil || !errors.Is(err, ErrSchemaInvalid) || !errors.As(err, &se) || se.Code() != "schema-invalid" {
+		t.Fatalf("expected the patch to be refused, got %v, %v", out, err)
+	}
+	if len(se.Violations) != 2 || !reflect.DeepEqual(se.Violations[0].Ops, []int{1}) || !reflect.DeepEqual(se.Violations[1].Ops, []int{2}) {
+		t.Errorf("expected the violations to be blamed on operations 1 and 2, got %+v", se.Violations)
+	}
+	if !jsonEqual(doc, decode(`{"name": "web", "replicas": 2}`)) {
+		t.Errorf("expected the document to be left unchanged, got %v", doc)
+	}
+
+	// a Schema is loaded along with the other options
+	var opts Options
+	if err := json.Unmarshal([]byte(`{"schema": `+testSchema+`}`), &opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Apply(doc, parseStr(`[{"op": "add", "path": "/x", "value": 1}]`), WithOptions(opts)); !errors.Is(err, ErrSchemaInvalid) {
+		t.Errorf("expected the schema to be enforced, got %v", err)
+	}
+	if _, err := json.Marshal(opts); err != nil {
+		t.Error(err)
+	}
+}
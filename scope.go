@@ -0,0 +1,89 @@
+package patch
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// ScopeError is the error wrapped by the ForbiddenError of an operation
+// reaching outside Options.Scope, or removing the scope itself.
+type ScopeError struct {
+	Scope string
+	Path  string // the location the operation reached, in the whole document
+}
+
+func (e *ScopeError) Error() string {
+	if e.Path == e.Scope {
+		return fmt.Sprintf("the scope %s cannot be removed", e.Scope)
+	}
+	return fmt.Sprintf("%s is outside the scope %s", e.Path, e.Scope)
+}
+
+// WithScope confines the patch to the value at scope. See Options.Scope.
+func WithScope(scope string) Option {
+	return func(o *Options) { o.Scope = scope }
+}
+
+// scoped returns path prefixed with the scope of the patch.
+func (a *applier) scoped(path []string) ([]string, error) {
+	if a.opts.Scope == "" {
+		return path, nil
+	}
+	if a.scope == nil {
+		scope, err := pointer.Parse(a.opts.Scope)
+		if err != nil {
+			return nil, fmt.Errorf("scope: %w", err)
+		}
+		if slices.Contains(scope, "*") {
+			return nil, fmt.Errorf("scope %s contains a wildcard", a.opts.Scope)
+		}
+		a.scope = scope
+	}
+	return append(slices.Clip(a.scope), path...), nil
+}
+
+// checkScope returns a ForbiddenError when the i-th instruction, once its
+// pointers are resolved, reaches outside the scope of the patch or removes
+// the scope itself.
+func (a *applier) checkScope(i int, ins *instruction) error {
+	if a.opts.Scope == "" {
+		return nil
+	}
+	scope, err := a.scoped(nil)
+	if err != nil {
+		return opError(i, &ins.op, err)
+	}
+	check := func(p []string, removed bool) error {
+		if len(p) < len(scope) || !slices.Equal(p[:len(scope)], scope) || (removed && len(p) == len(scope)) {
+			return &ForbiddenError{Index: i, Op: ins.op.Op, Path: ins.op.Path,
+				Err: &ScopeError{Scope: a.opts.Scope, Path: pointer.Pointer(p).String()}}
+		}
+		return nil
+	}
+	if err := check(ins.path, ins.op.Op == "remove"); err != nil {
+		return err
+	}
+	if ins.op.Op == "move" || (ins.op.Op == "copy" && ins.ref == nil) {
+		return check(ins.from, ins.op.Op == "move")
+	}
+	return nil
+}
+
+// evalScoped returns the paths matching q within the scope of the patch.
+func (a *applier) evalScoped(o interface{}, q jsonPath) ([][]string, error) {
+	scope, err := a.scoped(nil)
+	if err != nil || len(scope) == 0 {
+		return q.eval(o), err
+	}
+	v, err := pointer.Pointer(scope).Get(o)
+	if err != nil {
+		return nil, nil // nothing matches in a missing scope
+	}
+	paths := q.eval(v)
+	for i, p := range paths {
+		paths[i] = append(slices.Clip(scope), p...)
+	}
+	return paths, nil
+}
@@ -0,0 +1,47 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestScope(t *testing.T) {
+	doc := `{"tenants": {"acme": {"name": "Acme", "users": [{"name": "a"}]}, "other": {"secret": 1}}}`
+	cases := []struct {
+		patch    string
+		opts     []Option
+		expected string // "" when the patch is forbidden
+	}{
+		{
+			`[{"op": "replace", "path": "/name", "value": "ACME"}, {"op": "move", "from": "/users/0", "path": "/owner"}, {"op": "test", "path": "/users", "value": []}]`,
+			nil,
+			`{"name": "ACME", "users": [], "owner": {"name": "a"}}`,
+		},
+		{`[{"op": "replace", "path": "", "value": {}}]`, nil, `{}`},
+		{`[{"op": "replace", "path": "$.users[*].name", "value": "b"}]`, []Option{WithJSONPath()}, `{"name": "Acme", "users": [{"name": "b"}]}`},
+		{`[{"op": "remove", "path": ""}]`, nil, ""},
+		{`[{"op": "test", "path": "3/other", "value": {"secret": 1}}]`, []Option{WithAnchor("/tenants/acme/users")}, ""},
+		{`[{"op": "add", "path": "/link", "value": {"$ref": "#/tenants/other"}}, {"op": "replace", "path": "/link/secret", "value": 2}]`, []Option{WithFollowRefs()}, ""},
+	}
+	for _, c := range cases {
+		got, err := Apply(decode(doc), parseStr(c.patch), append(c.opts, WithScope("/tenants/acme"))...)
+		if c.expected == "" {
+			var se *ScopeError
+			if !errors.Is(err, ErrForbidden) || !errors.As(err, &se) || se.Scope != "/tenants/acme" {
+				t.Errorf("%s: expected the patch to be forbidden, got %v", c.patch, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", c.patch, err)
+			continue
+		}
+		tenants := got.(map[string]interface{})["tenants"].(map[string]interface{})
+		if !jsonEqual(tenants["acme"], decode(c.expected)) || !jsonEqual(tenants["other"], decode(`{"secret": 1}`)) {
+			t.Errorf("%s: unexpected document %v", c.patch, got)
+		}
+	}
+	if _, err := Apply(decode(doc), parseStr(`[{"op": "add", "path": "/x", "value": 1}]`), WithScope("/tenants/*"), WithWildcards()); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("expected a wildcard scope to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package patch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ScriptEngine is an embedded scripting engine, such as Starlark or an
+// expression language, that implements custom operators and selectors with
+// scripts supplied at run time instead of Go code. ScriptOperator and
+// ScriptSelector adapt compiled scripts to the package; TemplateEngine is a
+// reference implementation on top of text/template.
+//
+// Engines must run scripts in a sandbox:
+//   - a script sees only its input, never the document itself: inputs are
+//     deep copies, and a script may not keep references to them;
+//   - a script has no access to the file system, the network, the
+//     environment or the clock, unless the engine documents otherwise;
+//   - Run returns promptly, with an error, once its context is done, and
+//     bounds the memory a script may use;
+//   - results are made of the JSON data model only: maps with string keys,
+//     slices, strings, numbers, booleans and nil.
+//
+// The package enforces what it can: results are checked against
+// ScriptLimits and copied through JSON, so a result holding other types or
+// sharing memory with the engine never reaches the document.
+type ScriptEngine interface {
+	// Compile compiles the script src; name identifies it in errors.
+	Compile(name, src string) (Script, error)
+}
+
+// Script is a compiled script. It must be safe for concurrent use.
+type Script interface {
+	// Run evaluates the script with input, made of the JSON data model,
+	// and returns its result.
+	Run(ctx context.Context, input interface{}) (interface{}, error)
+}
+
+// ScriptLimits bounds the execution of a script by ScriptOperator and
+// ScriptSelector. Zero values mean no limit.
+type ScriptLimits struct {
+	// Timeout is the deadline of the context a script is run with.
+	Timeout time.Duration
+	// MaxResultSize is the largest size, in bytes of JSON, of a result.
+	MaxResultSize int
+}
+
+// ErrScriptLimit matches errors for scripts that exceeded their
+// ScriptLimits.
+var ErrScriptLimit = errors.New("script limit exceeded")
+
+// run runs s with a deep copy of input within the limits, and returns a
+// copy of its result decoded with json.Number.
+func (l ScriptLimits) run(s Script, input interface{}) (interface{}, error) {
+	ctx := context.Background()
+	if l.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+		defer cancel()
+	}
+	result, err := s.Run(ctx, deepCopy(input))
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("script ran longer than %v: %w", l.Timeout, ErrScriptLimit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("invalid script result: %v", err)
+	}
+	if l.MaxResultSize > 0 && len(b) > l.MaxResultSize {
+		return nil, fmt.Errorf("script result of %d bytes exceeds %d: %w", len(b), l.MaxResultSize, ErrScriptLimit)
+	}
+	var v interface{}
+	err = unmarshalNumber(b, &v)
+	return v, err
+}
+
+// ScriptOperator returns a custom operator implemented by s, for
+// RegisterOperator or Options.Operators. The script is run with an object
+// holding the operation's "op" and "path", its "value" if it has one, and
+// the value currently at the path as "current" if there is one. The result
+// of the script is stored at the path: an object member is added or
+// replaced, and an array element replaced, or appended with "-".
+func ScriptOperator(s Script, limits ScriptLimits) OperatorFunc {
+	return func(doc interface{}, op Operation, target Target, value interface{}) (interface{}, error) {
+		input := map[string]interface{}{"op": op.Op, "path": op.Path}
+		if op.Value != nil {
+			input["value"] = value
+		}
+		if target.Exists {
+			input["current"] = target.Value
+		}
+		result, err := limits.run(s, input)
+		if err != nil {
+			return nil, err
+		}
+		return target.Pointer.Set(doc, result)
+	}
+}
+
+// ScriptSelector returns a function choosing the operations to apply to a
+// document, such as a record of ApplyNDJSON, with s. The script is run with
+// the document and returns the operations as an array of objects, or null
+// for none.
+func ScriptSelector(s Script, limits ScriptLimits) func(doc interface{}) ([]Operation, error) {
+	return func(doc interface{}) ([]Operation, error) {
+		result, err := limits.run(s, doc)
+		if err != nil {
+			return nil, err
+		}
+		b, _ := json.Marshal(result)
+		var ops []Operation
+		if err := json.Unmarshal(b, &ops); err != nil {
+			return nil, fmt.Errorf("script result is not a patch: %v", err)
+		}
+		return ops, nil
+	}
+}
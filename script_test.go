@@ -0,0 +1,87 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// scriptFunc is a Script implemented by a Go function.
+type scriptFunc func(ctx context.Context, input interface{}) (interface{}, error)
+
+func (f scriptFunc) Run(ctx context.Context, input interface{}) (interface{}, error) {
+	return f(ctx, input)
+}
+
+func TestScriptOperator(t *testing.T) {
+	var seen interface{}
+	double := scriptFunc(func(_ context.Context, input interface{}) (interface{}, error) {
+		seen = input
+		in := input.(map[string]interface{})
+		// scripts only ever modify their copy of the input
+		in["current"].([]interface{})[0] = "changed"
+		return []interface{}{in["current"], in["value"]}, nil
+	})
+	doc := decode(`{"a": ["x"]}`)
+	result, err := Apply(doc, parseStr(`[{"op": "pair", "path": "/a", "value": 1}]`),
+		WithOptions(Options{Operators: map[string]OperatorFunc{"pair": ScriptOperator(double, ScriptLimits{})}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !jsonEqual(result, decode(`{"a": [["changed"], 1]}`)) {
+		t.Errorf("unexpected result %v", result)
+	}
+	if !jsonEqual(doc, decode(`{"a": ["x"]}`)) {
+		t.Errorf("the script modified the document: %v", doc)
+	}
+	expected := map[string]interface{}{"op": "pair", "path": "/a", "value": 1.0, "current": []interface{}{"changed"}}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Errorf("expected input %v, got %v", expected, seen)
+	}
+}
+
+func TestScriptLimits(t *testing.T) {
+	slow := scriptFunc(func(ctx context.Context, _ interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	big := scriptFunc(func(context.Context, interface{}) (interface{}, error) {
+		return "0123456789", nil
+	})
+	invalid := scriptFunc(func(context.Context, interface{}) (interface{}, error) {
+		return func() {}, nil
+	})
+	limits := ScriptLimits{Timeout: time.Millisecond, MaxResultSize: 5}
+	for name, s := range map[string]Script{"slow": slow, "big": big} {
+		_, err := ScriptOperator(s, limits)(decode(`{}`), Operation{Op: "x", Path: "/a"}, Target{Pointer: []string{"a"}}, nil)
+		if !errors.Is(err, ErrScriptLimit) {
+			t.Errorf("%s: expected a limit error, got %v", name, err)
+		}
+	}
+	if _, err := ScriptOperator(invalid, limits)(decode(`{}`), Operation{Op: "x", Path: "/a"}, Target{Pointer: []string{"a"}}, nil); err == nil {
+		t.Error("expected a result outside the JSON data model to be rejected")
+	}
+}
+
+func TestScriptSelector(t *testing.T) {
+	s := scriptFunc(func(_ context.Context, doc interface{}) (interface{}, error) {
+		if doc.(map[string]interface{})["skip"] == true {
+			return nil, nil
+		}
+		return []interface{}{map[string]interface{}{"op": "add", "path": "/seen", "value": true}}, nil
+	})
+	sel := ScriptSelector(s, ScriptLimits{})
+	ops, err := sel(decode(`{}`))
+	if err != nil || len(ops) != 1 || ops[0].Op != "add" || string(ops[0].Value) != "true" {
+		t.Errorf("unexpected operations %v %v", ops, err)
+	}
+	if ops, err := sel(decode(`{"skip": true}`)); err != nil || len(ops) != 0 {
+		t.Errorf("expected no operations, got %v %v", ops, err)
+	}
+	notPatch := scriptFunc(func(context.Context, interface{}) (interface{}, error) { return "x", nil })
+	if _, err := ScriptSelector(notPatch, ScriptLimits{})(nil); err == nil {
+		t.Error("expected a result that is not a patch to be rejected")
+	}
+}
@@ -0,0 +1,108 @@
+package patch
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// ApplyFunc applies a patch to a document, the way Apply does.
+type ApplyFunc func(doc interface{}, operations []Operation) (interface{}, error)
+
+// Divergence describes a patch for which the alternate implementation of a
+// Shadow disagreed with this package: one failed and the other did not, or
+// both succeeded with different documents. Errors are not compared, only
+// whether there was one.
+type Divergence struct {
+	Doc   interface{} // the document the patch was applied to
+	Patch []Operation
+
+	Result interface{} // result of this package
+	Err    error
+
+	ShadowResult interface{} // result of the alternate implementation
+	ShadowErr    error
+}
+
+func (d Divergence) String() string {
+	return fmt.Sprintf("patch %v: result %v (error %v), shadow result %v (error %v)",
+		d.Patch, d.Result, d.Err, d.ShadowResult, d.ShadowErr)
+}
+
+// Shadow applies patches with this package and, in the background, with an
+// alternate implementation, reporting every patch on which they disagree.
+// It is meant as a safety net while migrating from one implementation to
+// the other: callers always get the result of this package, and the
+// alternate implementation cannot slow them down or affect their documents.
+//
+// A Shadow must not be copied after first use.
+type Shadow struct {
+	// Alternate is the implementation compared against. It receives copies
+	// of the document and the patch; a panic in it counts as an error.
+	Alternate ApplyFunc
+	// OnDivergence is called, from a background goroutine, for every
+	// divergence. It may be called concurrently.
+	OnDivergence func(Divergence)
+	// MaxPending bounds the number of comparisons running at once.
+	// Patches arriving while the limit is reached are not compared and
+	// are counted by Dropped. Zero means no limit.
+	MaxPending int
+
+	pending sync.WaitGroup
+	running atomic.Int64
+	dropped atomic.Int64
+}
+
+// Apply applies operations to doc like Apply, and schedules the comparison
+// with the alternate implementation.
+func (s *Shadow) Apply(doc interface{}, operations []Operation, opts ...Option) (interface{}, error) {
+	if n := s.running.Add(1); s.MaxPending > 0 && n > int64(s.MaxPending) {
+		s.running.Add(-1)
+		s.dropped.Add(1)
+		return Apply(doc, operations, opts...)
+	}
+
+	d := Divergence{Doc: deepCopy(doc), Patch: slices.Clone(operations)}
+	d.Result, d.Err = Apply(doc, operations, opts...)
+	result := d.Result
+	d.Result = deepCopy(d.Result)
+
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		defer s.running.Add(-1)
+		d.ShadowResult, d.ShadowErr = s.alternate(deepCopy(d.Doc), slices.Clone(d.Patch))
+		if diverges(d) && s.OnDivergence != nil {
+			s.OnDivergence(d)
+		}
+	}()
+	return result, d.Err
+}
+
+func (s *Shadow) alternate(doc interface{}, operations []Operation) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("alternate implementation panicked: %v", r)
+		}
+	}()
+	return s.Alternate(doc, operations)
+}
+
+func diverges(d Divergence) bool {
+	if (d.Err == nil) != (d.ShadowErr == nil) {
+		return true
+	}
+	return d.Err == nil && !jsonEqual(d.Result, d.ShadowResult)
+}
+
+// Wait blocks until every scheduled comparison has completed.
+func (s *Shadow) Wait() {
+	s.pending.Wait()
+}
+
+// Dropped returns the number of patches that were not compared because
+// MaxPending comparisons were already running.
+func (s *Shadow) Dropped() int64 {
+	return s.dropped.Load()
+}
@@ -0,0 +1,85 @@
+package patch
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestShadow(t *testing.T) {
+	var mu sync.Mutex
+	var divergences []Divergence
+	s := &Shadow{
+		// an alternate implementation that ignores remove operations and
+		// panics on copy
+		Alternate: func(doc interface{}, ops []Operation) (interface{}, error) {
+			var kept []Operation
+			for _, op := range ops {
+				switch op.Op {
+				case "remove":
+					continue
+				case "copy":
+					panic("copy not supported")
+				}
+				kept = append(kept, op)
+			}
+			return ApplyUnsafe(doc, kept)
+		},
+		OnDivergence: func(d Divergence) {
+			mu.Lock()
+			defer mu.Unlock()
+			divergences = append(divergences, d)
+		},
+	}
+
+	doc := decode(`{"a": 1, "b": 2}`)
+	for _, p := range []string{
+		`[{"op": "add", "path": "/c", "value": 3}]`,
+		`[{"op": "remove", "path": "/a"}]`,
+		`[{"op": "copy", "from": "/a", "path": "/d"}]`,
+		`[{"op": "remove", "path": "/x"}]`,
+		`[{"op": "test", "path": "/a", "value": 2}]`,
+	} {
+		s.Apply(doc, parseStr(p))
+	}
+	s.Wait()
+
+	if len(divergences) != 3 {
+		t.Fatalf("expected 3 divergences, got %v", divergences)
+	}
+	byOp := make(map[string]Divergence)
+	for _, d := range divergences {
+		byOp[d.Patch[0].Op+" "+d.Patch[0].Path] = d
+	}
+	if d, ok := byOp["remove /a"]; !ok || d.Err != nil || d.ShadowErr != nil {
+		t.Errorf("expected remove /a to diverge, got %v", d)
+	}
+	if d, ok := byOp["copy /d"]; !ok || d.ShadowErr == nil {
+		t.Errorf("expected the panic to be reported, got %v", d)
+	}
+	if d, ok := byOp["remove /x"]; !ok || !errors.Is(d.Err, ErrNotFound) || d.ShadowErr != nil {
+		t.Errorf("expected remove /x to diverge, got %v", d)
+	}
+}
+
+func TestShadowMaxPending(t *testing.T) {
+	release := make(chan struct{})
+	s := &Shadow{
+		Alternate: func(doc interface{}, ops []Operation) (interface{}, error) {
+			<-release
+			return Apply(doc, ops)
+		},
+		MaxPending: 2,
+	}
+	ops := parseStr(`[{"op": "add", "path": "/a", "value": 1}]`)
+	for i := 0; i < 5; i++ {
+		if _, err := s.Apply(decode(`{}`), ops); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(release)
+	s.Wait()
+	if n := s.Dropped(); n != 3 {
+		t.Errorf("expected 3 dropped comparisons, got %d", n)
+	}
+}
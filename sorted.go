@@ -0,0 +1,203 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SortedObject is a JSON object stored as a slice of member names, in
+// sorted order, and a slice of their values, instead of a map. Members are
+// looked up by binary search. For objects with many members it takes a
+// fraction of the memory of a map[string]interface{}, and copying it, as
+// done by Apply and by ContinueOnError, copies two slices instead of
+// rehashing every member; adding and removing a member moves the members
+// after it.
+//
+// The operations of a patch, Apply and its variants, CreatePatch, wildcards
+// and the UTF8 modes accept SortedObjects wherever they accept objects;
+// objects created by the patch are maps. Use Pack to convert the wide
+// objects of a document and Unpack to convert them back. Pointers to
+// SortedObjects are encoded to and decoded from JSON objects.
+type SortedObject struct {
+	keys   []string
+	values []interface{}
+}
+
+// NewSortedObject returns a SortedObject holding the members of m.
+func NewSortedObject(m map[string]interface{}) *SortedObject {
+	o := &SortedObject{keys: make([]string, 0, len(m)), values: make([]interface{}, len(m))}
+	for k := range m {
+		o.keys = append(o.keys, k)
+	}
+	sort.Strings(o.keys)
+	for i, k := range o.keys {
+		o.values[i] = m[k]
+	}
+	return o
+}
+
+// Len returns the number of members of o.
+func (o *SortedObject) Len() int {
+	return len(o.keys)
+}
+
+// Get returns the value of the member name.
+func (o *SortedObject) Get(name string) (interface{}, bool) {
+	i, ok := slices.BinarySearch(o.keys, name)
+	if !ok {
+		return nil, false
+	}
+	return o.values[i], true
+}
+
+// Set adds or replaces the member name.
+func (o *SortedObject) Set(name string, v interface{}) {
+	i, ok := slices.BinarySearch(o.keys, name)
+	if ok {
+		o.values[i] = v
+		return
+	}
+	o.keys = slices.Insert(o.keys, i, name)
+	o.values = slices.Insert(o.values, i, v)
+}
+
+// Delete removes the member name, and reports whether it was there.
+func (o *SortedObject) Delete(name string) bool {
+	i, ok := slices.BinarySearch(o.keys, name)
+	if !ok {
+		return false
+	}
+	o.keys = slices.Delete(o.keys, i, i+1)
+	o.values = slices.Delete(o.values, i, i+1)
+	return true
+}
+
+// All iterates over the members of o in sorted order. o must not be
+// modified during the iteration.
+func (o *SortedObject) All() iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		for i, k := range o.keys {
+			if !yield(k, o.values[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Map returns the members of o as a map, sharing their values.
+func (o *SortedObject) Map() map[string]interface{} {
+	m := make(map[string]interface{}, len(o.keys))
+	for i, k := range o.keys {
+		m[k] = o.values[i]
+	}
+	return m
+}
+
+// clone returns a copy of o sharing its values.
+func (o *SortedObject) clone() *SortedObject {
+	return &SortedObject{keys: slices.Clone(o.keys), values: slices.Clone(o.values)}
+}
+
+// MarshalJSON encodes o as a JSON object with its members in sorted order,
+// as encoding/json does for maps.
+func (o *SortedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		v, err := marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes a JSON object into o, without going through a map.
+// Its values are decoded like json.Unmarshal into an interface{}; when a
+// member appears more than once, the last one is kept.
+func (o *SortedObject) UnmarshalJSON(data []byte) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := d.Token(); err != nil || tok != json.Delim('{') {
+		return fmt.Errorf("cannot decode %s into a SortedObject", data)
+	}
+	type member struct {
+		key   string
+		value interface{}
+	}
+	var members []member
+	for d.More() {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		var m member
+		m.key = tok.(string)
+		if err := d.Decode(&m.value); err != nil {
+			return err
+		}
+		members = append(members, m)
+	}
+	// a stable sort keeps duplicates in their order, so the last one wins
+	slices.SortStableFunc(members, func(a, b member) int {
+		return strings.Compare(a.key, b.key)
+	})
+	o.keys, o.values = o.keys[:0], o.values[:0]
+	for i, m := range members {
+		if i+1 < len(members) && members[i+1].key == m.key {
+			continue
+		}
+		o.keys = append(o.keys, m.key)
+		o.values = append(o.values, m.value)
+	}
+	return nil
+}
+
+// Pack returns a copy of doc in which every object with at least minKeys
+// members is a *SortedObject, and the others are maps.
+func Pack(doc interface{}, minKeys int) interface{} {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		if len(v) < minKeys {
+			out := make(map[string]interface{}, len(v))
+			for k, x := range v {
+				out[k] = Pack(x, minKeys)
+			}
+			return out
+		}
+		o := NewSortedObject(v)
+		for i, x := range o.values {
+			o.values[i] = Pack(x, minKeys)
+		}
+		return o
+	case *SortedObject:
+		return Pack(v.Map(), minKeys)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, x := range v {
+			out[i] = Pack(x, minKeys)
+		}
+		return out
+	}
+	return doc
+}
+
+// Unpack returns a copy of doc in which every *SortedObject is a map.
+func Unpack(doc interface{}) interface{} {
+	return Pack(doc, int(^uint(0)>>1))
+}
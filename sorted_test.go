@@ -0,0 +1,84 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSortedObject(t *testing.T) {
+	var o SortedObject
+	if err := json.Unmarshal([]byte(`{"b": 1, "a": {"x": [true]}, "b": 2, "c": null}`), &o); err != nil {
+		t.Fatal(err)
+	}
+	if o.Len() != 3 {
+		t.Errorf("expected the duplicate member to be dropped, got %d members", o.Len())
+	}
+	if v, ok := o.Get("b"); !ok || v != 2.0 {
+		t.Errorf("expected the last duplicate to win, got %v", v)
+	}
+	o.Set("aa", "x")
+	if !o.Delete("c") || o.Delete("c") {
+		t.Error("expected c to be deleted once")
+	}
+	out, err := json.Marshal(&o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `{"a":{"x":[true]},"aa":"x","b":2}` {
+		t.Errorf("unexpected encoding %s", out)
+	}
+	if err := json.Unmarshal([]byte(`[1]`), &o); err == nil {
+		t.Error("expected an array to be refused")
+	}
+}
+
+func TestApplySorted(t *testing.T) {
+	doc := `{"catalog": {"a": 1, "b": {"c": [1, 2]}, "d": "x"}, "small": {"k": 1}}`
+	patches := []string{
+		`[{"op": "add", "path": "/catalog/e", "value": 2}]`,
+		`[{"op": "add", "path": "/catalog/b/c/-", "value": 3}]`,
+		`[{"op": "remove", "path": "/catalog/a"}]`,
+		`[{"op": "replace", "path": "/catalog/d", "value": "y"}]`,
+		`[{"op": "move", "from": "/catalog/a", "path": "/small/a"}]`,
+		`[{"op": "copy", "from": "/catalog/b", "path": "/catalog/f"}]`,
+		`[{"op": "test", "path": "/catalog", "value": {"a": 1, "b": {"c": [1, 2]}, "d": "x"}}]`,
+		`[{"op": "remove", "path": "/catalog/missing"}]`,
+		`[{"op": "replace", "path": "/catalog/missing", "value": 1}]`,
+		`[{"op": "replace", "path": "/catalog/*", "value": 0}]`,
+	}
+	for _, p := range patches {
+		expected, expectedErr := Apply(decode(doc), parseStr(p), WithWildcards())
+		packed := Pack(decode(doc), 3)
+		if _, ok := packed.(map[string]interface{})["catalog"].(*SortedObject); !ok {
+			t.Fatal("expected the catalog to be packed")
+		}
+		got, err := Apply(packed, parseStr(p), WithWildcards())
+		if (err == nil) != (expectedErr == nil) || (err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrTestFailed)) {
+			t.Errorf("%s: expected error %v, got %v", p, expectedErr, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !jsonEqual(got, expected) {
+			t.Errorf("%s: expected %v, got %v", p, expected, Unpack(got))
+		}
+		if !jsonEqual(Unpack(packed), decode(doc)) {
+			t.Errorf("%s: the packed document was modified", p)
+		}
+	}
+}
+
+func TestCreatePatchSorted(t *testing.T) {
+	original := Pack(decode(`{"a": 1, "b": 2, "c": 3}`), 2)
+	modified := decode(`{"a": 1, "b": 3, "d": 4}`)
+	ops, err := CreatePatch(original, modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Apply(original, ops)
+	if err != nil || !jsonEqual(got, modified) {
+		t.Errorf("expected %v, got %v, %v", modified, got, err)
+	}
+}
@@ -0,0 +1,135 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrUnknownCheckpoint is returned by Stage.Rollback for a checkpoint that
+// does not exist.
+var ErrUnknownCheckpoint = errors.New("unknown checkpoint")
+
+// Stage is a staging area where patches are composed before being
+// committed as one: patches are staged one at a time, and can be inspected,
+// reordered and dropped, with named checkpoints to roll back to. The staged
+// patches always apply, in order, to the document the Stage started from.
+// A Stage is not safe for concurrent use.
+type Stage struct {
+	base        interface{}
+	opts        []Option
+	patches     [][]Operation
+	doc         interface{}
+	checkpoints []checkpoint
+}
+
+type checkpoint struct {
+	name    string
+	patches [][]Operation
+	doc     interface{}
+}
+
+// NewStage starts staging patches for a copy of doc. opts are used every
+// time the staged patches are applied, except that they are never applied
+// in place.
+func NewStage(doc interface{}, opts ...Option) *Stage {
+	opts = append(opts[:len(opts):len(opts)], func(o *Options) { o.InPlace = false })
+	doc = deepCopy(doc)
+	return &Stage{base: doc, opts: opts, doc: doc}
+}
+
+// Doc returns the document with every staged patch applied. It must not be
+// modified.
+func (s *Stage) Doc() interface{} { return s.doc }
+
+// Patches returns the staged patches, in the order they apply.
+func (s *Stage) Patches() [][]Operation { return slices.Clone(s.patches) }
+
+// Add stages ops after the patches already staged. The stage is left
+// unchanged when ops do not apply.
+func (s *Stage) Add(ops []Operation) error {
+	doc, err := Apply(s.doc, ops, s.opts...)
+	if err != nil {
+		return fmt.Errorf("staged patch %d: %w", len(s.patches), err)
+	}
+	s.patches = append(s.patches, slices.Clone(ops))
+	s.doc = doc
+	return nil
+}
+
+// Move moves the staged patch at index from so that it is at index to,
+// shifting the patches in between. The stage is left unchanged when the
+// patches no longer apply in their new order.
+func (s *Stage) Move(from, to int) error {
+	if from < 0 || from >= len(s.patches) || to < 0 || to >= len(s.patches) {
+		return fmt.Errorf("cannot move staged patch %d to %d: only %d are staged", from, to, len(s.patches))
+	}
+	patches := slices.Clone(s.patches)
+	p := patches[from]
+	patches = slices.Insert(slices.Delete(patches, from, from+1), to, p)
+	return s.restage(patches)
+}
+
+// Drop removes the staged patch at index i. The stage is left unchanged
+// when the patches after it no longer apply without it.
+func (s *Stage) Drop(i int) error {
+	if i < 0 || i >= len(s.patches) {
+		return fmt.Errorf("cannot drop staged patch %d: only %d are staged", i, len(s.patches))
+	}
+	return s.restage(slices.Delete(slices.Clone(s.patches), i, i+1))
+}
+
+// restage replaces the staged patches, once they are known to apply.
+func (s *Stage) restage(patches [][]Operation) error {
+	doc := s.base
+	for i, ops := range patches {
+		var err error
+		if doc, err = Apply(doc, ops, s.opts...); err != nil {
+			return fmt.Errorf("staged patch %d: %w", i, err)
+		}
+	}
+	s.patches, s.doc = patches, doc
+	return nil
+}
+
+// Checkpoint records the staged patches under name, replacing any earlier
+// checkpoint of the same name, so that Rollback can return to them.
+func (s *Stage) Checkpoint(name string) {
+	s.checkpoints = slices.DeleteFunc(s.checkpoints, func(c checkpoint) bool { return c.name == name })
+	s.checkpoints = append(s.checkpoints, checkpoint{name, slices.Clone(s.patches), s.doc})
+}
+
+// Checkpoints returns the names of the checkpoints, oldest first.
+func (s *Stage) Checkpoints() []string {
+	names := make([]string, len(s.checkpoints))
+	for i, c := range s.checkpoints {
+		names[i] = c.name
+	}
+	return names
+}
+
+// Rollback restores the patches staged when the named checkpoint was
+// recorded. The checkpoints recorded after it are discarded; it is kept.
+func (s *Stage) Rollback(name string) error {
+	i := slices.IndexFunc(s.checkpoints, func(c checkpoint) bool { return c.name == name })
+	if i < 0 {
+		return fmt.Errorf("%q: %w", name, ErrUnknownCheckpoint)
+	}
+	c := s.checkpoints[i]
+	s.patches, s.doc = slices.Clone(c.patches), c.doc
+	s.checkpoints = s.checkpoints[:i+1]
+	return nil
+}
+
+// Commit returns the staged patches combined into one patch, and the
+// document it produces. The stage then starts over from that document,
+// with no patch staged and no checkpoint.
+func (s *Stage) Commit() ([]Operation, interface{}) {
+	var combined []Operation
+	for _, ops := range s.patches {
+		combined = append(combined, ops...)
+	}
+	doc := s.doc
+	s.base, s.patches, s.checkpoints = doc, nil, nil
+	return combined, doc
+}
@@ -0,0 +1,78 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStage(t *testing.T) {
+	doc := decode(`{"a": 1}`)
+	s := NewStage(doc)
+	for _, p := range []string{
+		`[{"op": "add", "path": "/b", "value": {}}]`,
+		`[{"op": "add", "path": "/b/c", "value": 2}]`,
+	} {
+		if err := s.Add(parseStr(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Checkpoint("b")
+	if err := s.Add(parseStr(`[{"op": "remove", "path": "/missing"}]`)); err == nil {
+		t.Error("expected a patch that does not apply to be refused")
+	}
+	if err := s.Add(parseStr(`[{"op": "replace", "path": "/a", "value": 3}]`)); err != nil {
+		t.Fatal(err)
+	}
+	s.Checkpoint("a")
+	if expected := decode(`{"a": 3, "b": {"c": 2}}`); !reflect.DeepEqual(s.Doc(), expected) {
+		t.Errorf("expected %v, got %v", expected, s.Doc())
+	}
+
+	// patches can be reordered and dropped as long as they still apply
+	if err := s.Move(2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Move(2, 0); err == nil {
+		t.Error("expected adding /b/c before /b to be refused")
+	}
+	if err := s.Drop(1); err == nil {
+		t.Error("expected dropping /b to be refused")
+	}
+	if err := s.Drop(2); err != nil {
+		t.Fatal(err)
+	}
+	if expected := decode(`{"a": 3, "b": {}}`); !reflect.DeepEqual(s.Doc(), expected) {
+		t.Errorf("expected %v, got %v", expected, s.Doc())
+	}
+	if len(s.Patches()) != 2 || s.Patches()[0][0].Path != "/a" {
+		t.Errorf("unexpected patches %v", s.Patches())
+	}
+
+	if err := s.Rollback("b"); err != nil {
+		t.Fatal(err)
+	}
+	if expected := decode(`{"a": 1, "b": {"c": 2}}`); !reflect.DeepEqual(s.Doc(), expected) {
+		t.Errorf("expected %v, got %v", expected, s.Doc())
+	}
+	if names := s.Checkpoints(); !reflect.DeepEqual(names, []string{"b"}) {
+		t.Errorf("expected later checkpoints to be discarded, got %v", names)
+	}
+	if err := s.Rollback("a"); !errors.Is(err, ErrUnknownCheckpoint) {
+		t.Errorf("expected ErrUnknownCheckpoint, got %v", err)
+	}
+
+	ops, result := s.Commit()
+	if len(ops) != 2 || !reflect.DeepEqual(result, s.Doc()) {
+		t.Errorf("unexpected commit %v %v", ops, result)
+	}
+	if replayed, err := Apply(doc, ops); err != nil || !reflect.DeepEqual(replayed, result) {
+		t.Errorf("the combined patch does not produce the result: %v %v", replayed, err)
+	}
+	if len(s.Patches()) != 0 || len(s.Checkpoints()) != 0 {
+		t.Error("expected the stage to start over")
+	}
+	if expected := decode(`{"a": 1}`); !reflect.DeepEqual(doc, expected) {
+		t.Errorf("the original document was modified: %v", doc)
+	}
+}
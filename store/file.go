@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// File is a Driver that keeps each document in its own JSON file within a
+// directory. Writes are atomic, but version checks are only reliable when a
+// single File instance writes to the directory.
+type File struct {
+	dir string
+	// PollInterval is how often Watch checks for new versions.
+	PollInterval time.Duration
+
+	mu sync.Mutex
+}
+
+// NewFile returns a driver storing documents in dir, which is created if
+// needed.
+func NewFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &File{dir: dir, PollInterval: time.Second}, nil
+}
+
+func (f *File) path(key string) string {
+	return filepath.Join(f.dir, url.PathEscape(key)+".json")
+}
+
+// Load implements Driver.
+func (f *File) Load(ctx context.Context, key string) (Record, error) {
+	b, err := os.ReadFile(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return Record{}, ErrNotFound
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	var rec Record
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return Record{}, err
+	}
+	return rec, nil
+}
+
+// Save implements Driver.
+func (f *File) Save(ctx context.Context, rec Record) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cur, err := f.Load(ctx, rec.Key)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	if cur.Version != rec.Version-1 {
+		return ErrConflict
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(rec.Key))
+}
+
+// Watch implements Driver by polling the document's file every
+// PollInterval. Versions written between two polls are skipped.
+func (f *File) Watch(ctx context.Context, key string) (<-chan Record, error) {
+	last, err := f.Load(ctx, key)
+	if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+	out := make(chan Record)
+	go func() {
+		defer close(out)
+		t := time.NewTicker(f.PollInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			rec, err := f.Load(ctx, key)
+			if err != nil || rec.Version <= last.Version {
+				continue
+			}
+			last = rec
+			select {
+			case out <- rec:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
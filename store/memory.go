@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is a Driver that keeps every version of its documents in memory.
+// It implements History.
+type Memory struct {
+	mu       sync.Mutex
+	records  map[string][]Record
+	watchers map[string][]*watcher
+}
+
+// NewMemory returns an empty in-memory driver.
+func NewMemory() *Memory {
+	return &Memory{
+		records:  make(map[string][]Record),
+		watchers: make(map[string][]*watcher),
+	}
+}
+
+// Load implements Driver.
+func (m *Memory) Load(ctx context.Context, key string) (Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	recs := m.records[key]
+	if len(recs) == 0 {
+		return Record{}, ErrNotFound
+	}
+	return recs[len(recs)-1], nil
+}
+
+// Save implements Driver.
+func (m *Memory) Save(ctx context.Context, rec Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if int64(len(m.records[rec.Key])) != rec.Version-1 {
+		return ErrConflict
+	}
+	m.records[rec.Key] = append(m.records[rec.Key], rec)
+	for _, w := range m.watchers[rec.Key] {
+		w.push(rec)
+	}
+	return nil
+}
+
+// Since implements History.
+func (m *Memory) Since(ctx context.Context, key string, version int64) ([]Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	recs := m.records[key]
+	if version < 0 || version > int64(len(recs)) {
+		return nil, nil
+	}
+	return append([]Record(nil), recs[version:]...), nil
+}
+
+// Watch implements Driver. Every saved version is delivered.
+func (m *Memory) Watch(ctx context.Context, key string) (<-chan Record, error) {
+	w := newWatcher()
+	m.mu.Lock()
+	m.watchers[key] = append(m.watchers[key], w)
+	m.mu.Unlock()
+
+	go func() {
+		w.run(ctx)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		ws := m.watchers[key]
+		for i := range ws {
+			if ws[i] == w {
+				m.watchers[key] = append(ws[:i], ws[i+1:]...)
+				break
+			}
+		}
+	}()
+	return w.out, nil
+}
+
+// watcher queues records for a subscriber so that a slow reader never
+// blocks writers.
+type watcher struct {
+	mu      sync.Mutex
+	pending []Record
+	signal  chan struct{}
+	out     chan Record
+}
+
+func newWatcher() *watcher {
+	return &watcher{signal: make(chan struct{}, 1), out: make(chan Record)}
+}
+
+func (w *watcher) push(rec Record) {
+	w.mu.Lock()
+	w.pending = append(w.pending, rec)
+	w.mu.Unlock()
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers queued records until ctx is done, then closes out.
+func (w *watcher) run(ctx context.Context) {
+	defer close(w.out)
+	for {
+		w.mu.Lock()
+		batch := w.pending
+		w.pending = nil
+		w.mu.Unlock()
+		for _, rec := range batch {
+			select {
+			case w.out <- rec:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-w.signal:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Package store keeps JSON documents that are modified with JSON patches,
+// using optimistic concurrency control on top of a pluggable Driver.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	patch "github.com/grncdr/json-patch"
+)
+
+var (
+	// ErrNotFound is returned when no document is stored under a key.
+	ErrNotFound = errors.New("document not found")
+	// ErrConflict is returned when a document was not at the expected
+	// version.
+	ErrConflict = errors.New("document version conflict")
+)
+
+// Record is a version of a stored document.
+type Record struct {
+	Key     string          `json:"key"`
+	Version int64           `json:"version"`
+	Doc     json.RawMessage `json:"doc"`
+	// Patch is the patch that produced this version from the previous one,
+	// or nil for the first version.
+	Patch json.RawMessage `json:"patch,omitempty"`
+}
+
+// Driver persists the latest version of each document. Implementations must
+// be safe for concurrent use.
+type Driver interface {
+	// Load returns the latest record stored under key, or ErrNotFound.
+	Load(ctx context.Context, key string) (Record, error)
+	// Save stores rec under rec.Key if the version currently stored is
+	// rec.Version-1 (with version 0 meaning no document is stored), and
+	// returns ErrConflict otherwise.
+	Save(ctx context.Context, rec Record) error
+	// Watch delivers records saved under key after Watch was called, until
+	// ctx is done, at which point the channel is closed. Drivers that poll
+	// may skip intermediate versions.
+	Watch(ctx context.Context, key string) (<-chan Record, error)
+}
+
+// maxRetries bounds how often an unconditional Patch reloads the document
+// after losing a race with a concurrent writer.
+const maxRetries = 5
+
+// Store applies patches to documents kept by a Driver.
+type Store struct {
+	driver Driver
+}
+
+// New returns a Store backed by d.
+func New(d Driver) *Store {
+	return &Store{driver: d}
+}
+
+// Get returns the latest version of the document stored under key.
+func (s *Store) Get(ctx context.Context, key string) (Record, error) {
+	return s.driver.Load(ctx, key)
+}
+
+// Create stores the first version of a document, failing with ErrConflict
+// if one already exists.
+func (s *Store) Create(ctx context.Context, key string, doc []byte) (Record, error) {
+	if !json.Valid(doc) {
+		return Record{}, errors.New("invalid JSON document")
+	}
+	rec := Record{Key: key, Version: 1, Doc: doc}
+	if err := s.driver.Save(ctx, rec); err != nil {
+		return Record{}, err
+	}
+	return rec, nil
+}
+
+// Patch applies ops to the document stored under key and saves the result
+// as a new version. When ifVersion is non-zero the patch is only applied if
+// the document is at that version, and ErrConflict is returned otherwise.
+// When ifVersion is zero the patch is applied to whatever version is
+// current, retrying a few times if concurrent writers interfere.
+func (s *Store) Patch(ctx context.Context, key string, ops []patch.Operation, ifVersion int64) (Record, error) {
+	raw, err := json.Marshal(ops)
+	if err != nil {
+		return Record{}, err
+	}
+	for attempt := 0; ; attempt++ {
+		cur, err := s.driver.Load(ctx, key)
+		if err != nil {
+			return Record{}, err
+		}
+		if ifVersion != 0 && cur.Version != ifVersion {
+			return Record{}, ErrConflict
+		}
+		doc, err := patch.ApplyBytes(cur.Doc, raw)
+		if err != nil {
+			return Record{}, err
+		}
+		rec := Record{Key: key, Version: cur.Version + 1, Doc: doc, Patch: raw}
+		err = s.driver.Save(ctx, rec)
+		if err == nil {
+			return rec, nil
+		}
+		if !errors.Is(err, ErrConflict) || ifVersion != 0 || attempt == maxRetries {
+			return Record{}, err
+		}
+	}
+}
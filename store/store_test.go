@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	patch "github.com/grncdr/json-patch"
+)
+
+func ops(s string) []patch.Operation {
+	o, err := patch.Parse([]byte(s))
+	if err != nil {
+		panic(err)
+	}
+	return o
+}
+
+func testDriver(t *testing.T, d Driver) {
+	ctx := context.Background()
+	s := New(d)
+	if _, err := s.Get(ctx, "doc"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.Create(ctx, "doc", []byte(`{"id": 12345678901234567890}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(ctx, "doc", []byte(`{}`)); err != ErrConflict {
+		t.Fatalf("expected creating twice to conflict, got %v", err)
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	updates, err := d.Watch(watchCtx, "doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := s.Patch(ctx, "doc", ops(`[{"op": "add", "path": "/n", "value": 1}]`), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Version != 2 || string(rec.Doc) != `{"id":12345678901234567890,"n":1}` {
+		t.Errorf("unexpected record %d %s", rec.Version, rec.Doc)
+	}
+	if _, err := s.Patch(ctx, "doc", ops(`[]`), 1); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected a stale version to conflict, got %v", err)
+	}
+	if rec, err = s.Patch(ctx, "doc", ops(`[{"op": "remove", "path": "/n"}]`), 0); err != nil || rec.Version != 3 {
+		t.Errorf("expected an unconditional patch to succeed, got %v %v", rec.Version, err)
+	}
+
+	select {
+	case rec := <-updates:
+		if rec.Version < 2 {
+			t.Errorf("unexpected version %d from watch", rec.Version)
+		}
+	case <-time.After(time.Second):
+		t.Error("timed out waiting for watch")
+	}
+	cancel()
+	for range updates {
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testDriver(t, NewMemory())
+}
+
+func TestFile(t *testing.T) {
+	f, err := NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.PollInterval = 10 * time.Millisecond
+	testDriver(t, f)
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	patch "github.com/grncdr/json-patch"
+)
+
+// ErrHistoryUnavailable is returned by Watch when asked to start from a
+// version the driver no longer has the patches for.
+var ErrHistoryUnavailable = errors.New("document history unavailable")
+
+// History is implemented by drivers that keep every version of a document.
+type History interface {
+	// Since returns the records stored under key with a version greater
+	// than version, oldest first.
+	Since(ctx context.Context, key string, version int64) ([]Record, error)
+}
+
+// Watch streams every version of the document stored under key that is
+// newer than fromVersion, oldest first, until ctx is done. Each record's
+// Patch turns the previously delivered version into this one. When the
+// driver skips versions that its History cannot provide, the patch is
+// computed by diffing the two documents instead, or replaces the whole
+// document if no version was delivered yet, and the version number jumps.
+func (s *Store) Watch(ctx context.Context, key string, fromVersion int64) (<-chan Record, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	updates, err := s.driver.Watch(ctx, key)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	hist, _ := s.driver.(History)
+
+	var backlog []Record
+	var lastDoc []byte
+	cur, err := s.driver.Load(ctx, key)
+	switch {
+	case err == ErrNotFound:
+	case err != nil:
+		cancel()
+		return nil, err
+	case cur.Version == fromVersion:
+		lastDoc = cur.Doc
+	case cur.Version == fromVersion+1:
+		backlog = []Record{cur}
+	case cur.Version > fromVersion && hist != nil:
+		if backlog, err = hist.Since(ctx, key, fromVersion); err != nil {
+			cancel()
+			return nil, err
+		}
+	case cur.Version > fromVersion:
+		cancel()
+		return nil, ErrHistoryUnavailable
+	}
+
+	out := make(chan Record)
+	go func() {
+		defer cancel()
+		defer close(out)
+		last := fromVersion
+		send := func(rec Record) bool {
+			select {
+			case out <- rec:
+				last, lastDoc = rec.Version, rec.Doc
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for _, rec := range backlog {
+			if !send(rec) {
+				return
+			}
+		}
+		for rec := range updates {
+			if rec.Version <= last {
+				continue
+			}
+			if rec.Version > last+1 && hist != nil {
+				missed, err := hist.Since(ctx, key, last)
+				if err == nil {
+					for _, m := range missed {
+						if m.Version < rec.Version && !send(m) {
+							return
+						}
+					}
+				}
+			}
+			if rec.Version > last+1 {
+				rec.Patch = gapPatch(lastDoc, rec.Doc)
+			}
+			if !send(rec) {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// gapPatch returns a patch turning prev, the last delivered document, into
+// doc. Without prev, or when the documents cannot be diffed, the patch
+// replaces the whole document.
+func gapPatch(prev, doc []byte) json.RawMessage {
+	if prev != nil {
+		if p, err := patch.CreatePatchBytes(prev, doc); err == nil {
+			return p
+		}
+	}
+	p, _ := json.Marshal([]patch.Operation{{Op: "replace", Path: "", Value: doc}})
+	return p
+}
+
+// Wait long-polls for versions of the document stored under key newer than
+// after. It blocks until at least one is available or ctx is done, and
+// returns those that are ready.
+func (s *Store) Wait(ctx context.Context, key string, after int64) ([]Record, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ch, err := s.Watch(ctx, key, after)
+	if err != nil {
+		return nil, err
+	}
+	rec, ok := <-ch
+	if !ok {
+		return nil, ctx.Err()
+	}
+	recs := []Record{rec}
+	for {
+		select {
+		case rec, ok := <-ch:
+			if !ok {
+				return recs, nil
+			}
+			recs = append(recs, rec)
+		default:
+			return recs, nil
+		}
+	}
+}
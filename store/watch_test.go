@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	patch "github.com/grncdr/json-patch"
+)
+
+func receive(t *testing.T, ch <-chan Record) Record {
+	t.Helper()
+	select {
+	case rec := <-ch:
+		return rec
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a record")
+	}
+	return Record{}
+}
+
+func replay(t *testing.T, doc []byte, recs ...Record) string {
+	t.Helper()
+	for _, rec := range recs {
+		var err error
+		if doc, err = patch.ApplyBytes(doc, rec.Patch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return string(doc)
+}
+
+func TestWatchFromHistory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := New(NewMemory())
+	s.Create(ctx, "doc", []byte(`{"n": 0}`))
+	s.Patch(ctx, "doc", ops(`[{"op": "replace", "path": "/n", "value": 1}]`), 0)
+	s.Patch(ctx, "doc", ops(`[{"op": "replace", "path": "/n", "value": 2}]`), 0)
+
+	ch, err := s.Watch(ctx, "doc", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, r3 := receive(t, ch), receive(t, ch)
+	s.Patch(ctx, "doc", ops(`[{"op": "add", "path": "/m", "value": true}]`), 0)
+	r4 := receive(t, ch)
+	if r2.Version != 2 || r3.Version != 3 || r4.Version != 4 {
+		t.Fatalf("unexpected versions %d %d %d", r2.Version, r3.Version, r4.Version)
+	}
+	if got := replay(t, []byte(`{"n": 0}`), r2, r3, r4); got != `{"m":true,"n":2}` {
+		t.Errorf("unexpected replayed document %s", got)
+	}
+}
+
+func TestWatchDiffsSkippedVersions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f, err := NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.PollInterval = 50 * time.Millisecond
+	s := New(f)
+	s.Create(ctx, "doc", []byte(`{"n": 0}`))
+
+	if _, err := s.Watch(ctx, "doc", 0); err != nil {
+		t.Errorf("expected watching from the version before the current one to work, got %v", err)
+	}
+	s.Patch(ctx, "doc", ops(`[{"op": "replace", "path": "/n", "value": 1}]`), 0)
+	if _, err := s.Watch(ctx, "doc", 0); err != ErrHistoryUnavailable {
+		t.Errorf("expected ErrHistoryUnavailable, got %v", err)
+	}
+
+	ch, err := s.Watch(ctx, "doc", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Patch(ctx, "doc", ops(`[{"op": "replace", "path": "/n", "value": 2}]`), 0)
+	s.Patch(ctx, "doc", ops(`[{"op": "add", "path": "/m", "value": 3}]`), 0)
+	// depending on timing the poller sees version 3 or skips straight to 4
+	var recs []Record
+	for len(recs) == 0 || recs[len(recs)-1].Version < 4 {
+		recs = append(recs, receive(t, ch))
+	}
+	if got := replay(t, []byte(`{"n": 1}`), recs...); got != `{"m":3,"n":2}` {
+		t.Errorf("unexpected replayed document %s", got)
+	}
+}
+
+// lossy is a Memory whose watchers miss odd versions and whose history
+// cannot be read.
+type lossy struct{ *Memory }
+
+func (l lossy) Watch(ctx context.Context, key string) (<-chan Record, error) {
+	ch, err := l.Memory.Watch(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan Record)
+	go func() {
+		defer close(out)
+		for rec := range ch {
+			if rec.Version%2 == 0 {
+				out <- rec
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (l lossy) Since(ctx context.Context, key string, version int64) ([]Record, error) {
+	return nil, errors.New("history unavailable")
+}
+
+func TestWatchReplacesUnknownDocument(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := New(lossy{NewMemory()})
+	ch, err := s.Watch(ctx, "doc", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Create(ctx, "doc", []byte(`{"n": 0}`))
+	s.Patch(ctx, "doc", ops(`[{"op": "replace", "path": "/n", "value": 1}]`), 0)
+	s.Patch(ctx, "doc", ops(`[{"op": "replace", "path": "/n", "value": 2}]`), 0)
+	s.Patch(ctx, "doc", ops(`[{"op": "add", "path": "/m", "value": 3}]`), 0)
+	r2, r4 := receive(t, ch), receive(t, ch)
+	if r2.Version != 2 || r4.Version != 4 {
+		t.Fatalf("unexpected versions %d %d", r2.Version, r4.Version)
+	}
+	// no version was delivered before 2, so its patch must not depend on one
+	if got := replay(t, []byte(`null`), r2); got != `{"n":1}` {
+		t.Errorf("unexpected document after version 2: %s", got)
+	}
+	if got := replay(t, []byte(`null`), r2, r4); got != `{"m":3,"n":2}` {
+		t.Errorf("unexpected replayed document %s", got)
+	}
+}
+
+func TestWait(t *testing.T) {
+	ctx := context.Background()
+	s := New(NewMemory())
+	s.Create(ctx, "doc", []byte(`{}`))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.Patch(ctx, "doc", ops(`[{"op": "add", "path": "/a", "value": 1}]`), 0)
+	}()
+	recs, err := s.Wait(ctx, "doc", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p []patch.Operation
+	json.Unmarshal(recs[0].Patch, &p)
+	if recs[0].Version != 2 || len(p) != 1 {
+		t.Errorf("unexpected records %+v", recs)
+	}
+
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.Wait(short, "doc", 2); err != context.DeadlineExceeded {
+		t.Errorf("expected a deadline error, got %v", err)
+	}
+}
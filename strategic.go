@@ -0,0 +1,183 @@
+package patch
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// MergeSchema maps the pointers of arrays of objects to their merge key, the
+// member identifying their elements in a strategic merge patch, such as
+// "/spec/containers" to "name". Tokens of the pointers may be "*" to match
+// any token, for example the index of an element of another merged array:
+// "/spec/containers/*/ports" to "containerPort".
+type MergeSchema map[string]string
+
+// StrategicMerge applies a strategic merge patch, as used by Kubernetes, to
+// a copy of doc and returns the result. It is a JSON Merge Patch, applied as
+// by MergePatch, except for the arrays schema gives a merge key for: each
+// element of such an array in the patch is merged into the element of the
+// document with the same value of the merge key, or appended when there is
+// none, and the other elements of the document are kept. Other arrays are
+// replaced.
+//
+// The "$patch" member of an object in the patch is a directive:
+//
+//   - "replace" replaces the target with the object instead of merging it;
+//   - "delete" removes the target, which for an element of a merged array
+//     is the element with the same merge key;
+//   - "merge" is the default.
+//
+// An element {"$patch": "replace"} in a merged array replaces the array
+// with the other elements. A patch with an unknown directive, or with an
+// element of a merged array that is not an object or lacks the merge key,
+// fails with an error matching ErrInvalidPatch.
+func StrategicMerge(doc, patch interface{}, schema MergeSchema) (interface{}, error) {
+	return schema.merge(deepCopy(doc), patch, nil)
+}
+
+// directive returns the "$patch" directive of a patch value, "merge" when
+// it has none.
+func directive(patch interface{}, path pointer.Pointer) (string, error) {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return "merge", nil
+	}
+	d, ok := p["$patch"]
+	if !ok {
+		return "merge", nil
+	}
+	switch d {
+	case "merge", "replace", "delete":
+		return d.(string), nil
+	}
+	return "", fmt.Errorf("%s: unknown directive %v: %w", path, d, ErrInvalidPatch)
+}
+
+// merge merges patch into doc, found at path, and returns the result.
+func (s MergeSchema) merge(doc, patch interface{}, path pointer.Pointer) (interface{}, error) {
+	switch p := patch.(type) {
+	case map[string]interface{}:
+		d, err := directive(p, path)
+		if err != nil {
+			return nil, err
+		}
+		target, ok := doc.(map[string]interface{})
+		if !ok || d == "replace" {
+			target = make(map[string]interface{}, len(p))
+		}
+		// in sorted order, so that an invalid patch is always reported the
+		// same way
+		for _, k := range slices.Sorted(maps.Keys(p)) {
+			v := p[k]
+			if k == "$patch" {
+				continue
+			}
+			member := append(path[:len(path):len(path)], k)
+			if d == "replace" {
+				target[k] = deepCopy(v)
+				continue
+			}
+			vd, err := directive(v, member)
+			if err != nil {
+				return nil, err
+			}
+			if v == nil || vd == "delete" {
+				delete(target, k)
+				continue
+			}
+			if target[k], err = s.merge(target[k], v, member); err != nil {
+				return nil, err
+			}
+		}
+		return target, nil
+	case []interface{}:
+		if key, ok := s.mergeKey(path); ok {
+			return s.mergeArray(doc, p, key, path)
+		}
+	}
+	return deepCopy(patch), nil
+}
+
+// mergeArray merges the elements of patch into those of doc, found at path,
+// with the same value of key.
+func (s MergeSchema) mergeArray(doc interface{}, patch []interface{}, key string, path pointer.Pointer) (interface{}, error) {
+	target, _ := doc.([]interface{})
+	for _, e := range patch {
+		if p, ok := e.(map[string]interface{}); ok && len(p) == 1 && p["$patch"] == "replace" {
+			target = nil
+		}
+	}
+	for i, e := range patch {
+		p, ok := e.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: element %d of a merged array is not an object: %w", path, i, ErrInvalidPatch)
+		}
+		if len(p) == 1 && p["$patch"] == "replace" {
+			continue
+		}
+		id, ok := p[key]
+		if !ok {
+			return nil, fmt.Errorf("%s: element %d has no merge key %q: %w", path, i, key, ErrInvalidPatch)
+		}
+		j := slices.IndexFunc(target, func(v interface{}) bool {
+			m, ok := v.(map[string]interface{})
+			return ok && jsonEqual(m[key], id)
+		})
+		d, err := directive(p, path)
+		if err != nil {
+			return nil, err
+		}
+		if d == "delete" {
+			if j >= 0 {
+				target = slices.Delete(target, j, j+1)
+			}
+			continue
+		}
+		if j < 0 {
+			target = append(target, nil)
+			j = len(target) - 1
+		}
+		element := append(path[:len(path):len(path)], strconv.Itoa(j))
+		if target[j], err = s.merge(target[j], p, element); err != nil {
+			return nil, err
+		}
+	}
+	if target == nil {
+		target = []interface{}{}
+	}
+	return target, nil
+}
+
+// mergeKey returns the merge key of the array at path. The most specific
+// pattern, with the fewest "*", wins.
+func (s MergeSchema) mergeKey(path pointer.Pointer) (string, bool) {
+	if key, ok := s[path.String()]; ok {
+		return key, true
+	}
+	patterns := make([]string, 0, len(s))
+	for p := range s {
+		if strings.Contains(p, "*") {
+			patterns = append(patterns, p)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		wi, wj := strings.Count(patterns[i], "*"), strings.Count(patterns[j], "*")
+		if wi != wj {
+			return wi < wj
+		}
+		return patterns[i] < patterns[j]
+	})
+	for _, p := range patterns {
+		pattern, err := pointer.Parse(p)
+		if err == nil && len(pattern) == len(path) && matchPrefix(pattern, path) {
+			return s[p], true
+		}
+	}
+	return "", false
+}
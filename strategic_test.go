@@ -0,0 +1,87 @@
+package patch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStrategicMerge(t *testing.T) {
+	schema := MergeSchema{
+		"/spec/containers":         "name",
+		"/spec/containers/*/ports": "containerPort",
+	}
+	doc := decode(`{"spec": {"replicas": 1, "containers": [
+		{"name": "app", "image": "app:1", "ports": [{"containerPort": 80, "protocol": "TCP"}], "args": ["-v"]},
+		{"name": "sidecar", "image": "proxy:1"}
+	]}}`)
+	cases := []struct {
+		patch    string
+		expected string
+	}{
+		{
+			`{"spec": {"containers": [{"name": "app", "image": "app:2", "ports": [{"containerPort": 443}], "args": ["-q"]}]}}`,
+			`{"spec": {"replicas": 1, "containers": [
+				{"name": "app", "image": "app:2", "ports": [{"containerPort": 80, "protocol": "TCP"}, {"containerPort": 443}], "args": ["-q"]},
+				{"name": "sidecar", "image": "proxy:1"}
+			]}}`,
+		},
+		{
+			`{"spec": {"replicas": null, "containers": [{"name": "log", "image": "log:1"}, {"name": "sidecar", "$patch": "delete"}]}}`,
+			`{"spec": {"containers": [
+				{"name": "app", "image": "app:1", "ports": [{"containerPort": 80, "protocol": "TCP"}], "args": ["-v"]},
+				{"name": "log", "image": "log:1"}
+			]}}`,
+		},
+		{
+			`{"spec": {"containers": [{"$patch": "replace"}, {"name": "only"}]}}`,
+			`{"spec": {"replicas": 1, "containers": [{"name": "only"}]}}`,
+		},
+		{
+			`{"spec": {"containers": [{"name": "app", "$patch": "replace", "image": "app:3"}]}}`,
+			`{"spec": {"replicas": 1, "containers": [{"name": "app", "image": "app:3"}, {"name": "sidecar", "image": "proxy:1"}]}}`,
+		},
+		{
+			`{"spec": {"containers": [{"name": "app", "ports": [{"containerPort": 80, "$patch": "delete"}]}]}}`,
+			`{"spec": {"replicas": 1, "containers": [
+				{"name": "app", "image": "app:1", "ports": [], "args": ["-v"]},
+				{"name": "sidecar", "image": "proxy:1"}
+			]}}`,
+		},
+		{
+			`{"spec": {"$patch": "delete"}}`,
+			`{}`,
+		},
+	}
+	for _, c := range cases {
+		got, err := StrategicMerge(doc, decode(c.patch), schema)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", c.patch, err)
+			continue
+		}
+		if !jsonEqual(got, decode(c.expected)) {
+			t.Errorf("%s: expected %s, got %v", c.patch, c.expected, got)
+		}
+	}
+	if len(doc.(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})) != 2 {
+		t.Error("expected the document to be left unchanged")
+	}
+
+	for _, p := range []string{
+		`{"spec": {"containers": [{"image": "x"}]}}`,
+		`{"spec": {"containers": ["app"]}}`,
+		`{"spec": {"$patch": "retain"}}`,
+	} {
+		if _, err := StrategicMerge(doc, decode(p), schema); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%s: expected an invalid patch, got %v", p, err)
+		}
+	}
+
+	// the first invalid member in sorted order is reported
+	for i := 0; i < 10; i++ {
+		_, err := StrategicMerge(doc, decode(`{"z": {"$patch": "x"}, "b": {"$patch": "x"}, "m": {"$patch": "x"}}`), schema)
+		if err == nil || !strings.HasPrefix(err.Error(), "/b:") {
+			t.Fatalf("expected /b to be reported, got %v", err)
+		}
+	}
+}
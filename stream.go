@@ -0,0 +1,461 @@
+package patch
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// ApplyStream applies operations to the JSON document read from r and
+// writes the result to w, without ever holding the whole document in
+// memory: the document is read token by token, and only the values that are
+// tested or written are decoded. It suits documents too large to decode,
+// patched at a few locations.
+//
+// Only add, replace, remove and test operations are supported, and their
+// paths may not overlap: no path may be a prefix of another, except that
+// several operations may append to the same array with "-". Operations
+// are applied in order as usual, but since the document is read only once,
+// an operation may not address an element of an array at or after the
+// index where an earlier operation inserted or removed an element.
+//
+// The result is written as compact JSON, with numbers exactly as they were
+// read. If an operation fails, the output written so far is incomplete and
+// should be discarded.
+func ApplyStream(r io.Reader, w io.Writer, operations []Operation) error {
+	root, err := buildStreamTree(operations)
+	if err != nil {
+		return err
+	}
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	s := &streamer{d: d, w: bufio.NewWriter(w)}
+	if root.op != nil {
+		err = s.apply(root.op)
+	} else {
+		err = s.value(root)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return fmt.Errorf("invalid character after top-level value")
+	}
+	return s.w.Flush()
+}
+
+// streamOp is an operation to apply while streaming.
+type streamOp struct {
+	index int
+	op    Operation
+	value interface{}
+}
+
+// streamNode is a node of the tree of operation paths: op is the operation
+// whose path ends here, appends the add operations to "-" of the array
+// here, and last the highest operation index in the subtree.
+type streamNode struct {
+	children map[string]*streamNode
+	op       *streamOp
+	appends  []*streamOp
+	last     int
+}
+
+func buildStreamTree(operations []Operation) (*streamNode, error) {
+	root := &streamNode{}
+	for i, op := range operations {
+		if err := root.insert(i, op); err != nil {
+			return nil, &InvalidPatchError{Index: i, Op: op.Op, Err: err}
+		}
+	}
+	return root, nil
+}
+
+func (n *streamNode) insert(i int, op Operation) error {
+	sop := &streamOp{index: i, op: op}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return fmt.Errorf("missing 'value' parameter")
+		}
+		if err := unmarshalNumber(op.Value, &sop.value); err != nil {
+			return fmt.Errorf("invalid 'value' parameter: %v", err)
+		}
+	case "remove":
+	default:
+		return fmt.Errorf("%s operations cannot be streamed", op.Op)
+	}
+	path, err := parsePath(op.Path)
+	if err != nil {
+		return err
+	}
+	overlap := fmt.Errorf("%s overlaps the path of an earlier operation", op.Path)
+	for j, token := range path {
+		if n.op != nil {
+			return overlap
+		}
+		n.last = i
+		if token == "-" {
+			if j != len(path)-1 || op.Op != "add" {
+				return fmt.Errorf("%s: \"-\" can only end the path of an add", op.Path)
+			}
+			n.appends = append(n.appends, sop)
+			return nil
+		}
+		if n.children == nil {
+			n.children = make(map[string]*streamNode)
+		}
+		child := n.children[token]
+		if child == nil {
+			child = &streamNode{}
+			n.children[token] = child
+		}
+		n = child
+	}
+	if n.op != nil || len(n.children) > 0 || len(n.appends) > 0 {
+		return overlap
+	}
+	if op.Op == "remove" && len(path) == 0 {
+		return fmt.Errorf("the whole document cannot be removed")
+	}
+	n.op, n.last = sop, i
+	return nil
+}
+
+// firstOp returns the earliest operation in the subtree of n.
+func (n *streamNode) firstOp() *streamOp {
+	return n.firstAfter(-1)
+}
+
+// firstAfter returns the earliest operation in the subtree of n that comes
+// after the operation with the given index, or nil.
+func (n *streamNode) firstAfter(index int) *streamOp {
+	if n.last <= index {
+		return nil
+	}
+	var first *streamOp
+	consider := func(op *streamOp) {
+		if op != nil && op.index > index && (first == nil || op.index < first.index) {
+			first = op
+		}
+	}
+	consider(n.op)
+	for _, a := range n.appends {
+		consider(a)
+	}
+	for _, child := range n.children {
+		consider(child.firstAfter(index))
+	}
+	return first
+}
+
+type streamer struct {
+	d *json.Decoder
+	w *bufio.Writer
+}
+
+// value copies the next value of the input to the output, applying the
+// operations of the subtree n.
+func (s *streamer) value(n *streamNode) error {
+	if n == nil {
+		return s.copy()
+	}
+	tok, err := s.d.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		return s.object(n)
+	case json.Delim('['):
+		return s.array(n)
+	}
+	op := n.firstOp()
+	return opError(op.index, &op.op, fmt.Errorf("cannot index a %s", jsonType(tok)))
+}
+
+func (s *streamer) object(n *streamNode) error {
+	s.w.WriteByte('{')
+	seen := make(map[string]bool)
+	first := true
+	member := func(key string) {
+		if !first {
+			s.w.WriteByte(',')
+		}
+		first = false
+		s.write(key)
+		s.w.WriteByte(':')
+	}
+	for s.d.More() {
+		tok, err := s.d.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+		seen[key] = true
+		child := n.children[key]
+		switch {
+		case child == nil:
+			member(key)
+			err = s.copy()
+		case child.op == nil:
+			member(key)
+			err = s.value(child)
+		case child.op.op.Op == "remove":
+			err = s.skip()
+		default:
+			member(key)
+			err = s.apply(child.op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := s.d.Token(); err != nil {
+		return err
+	}
+	// members added by the patch, and operations that found nothing
+	keys := make([]string, 0, len(n.children))
+	for key := range n.children {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return n.children[keys[i]].last < n.children[keys[j]].last })
+	for _, key := range keys {
+		child := n.children[key]
+		if child.op == nil || child.op.op.Op != "add" {
+			op := child.firstOp()
+			return opError(op.index, &op.op, fmt.Errorf("member %q: %w", key, ErrNotFound))
+		}
+		member(key)
+		s.write(child.op.value)
+	}
+	if len(n.appends) > 0 {
+		op := n.appends[0]
+		return opError(op.index, &op.op, fmt.Errorf("cannot append to an object"))
+	}
+	s.w.WriteByte('}')
+	return nil
+}
+
+func (s *streamer) array(n *streamNode) error {
+	s.w.WriteByte('[')
+	first := true
+	element := func() {
+		if !first {
+			s.w.WriteByte(',')
+		}
+		first = false
+	}
+	count := 0
+	for ; s.d.More(); count++ {
+		key := strconv.Itoa(count)
+		child := n.children[key]
+		var err error
+		switch {
+		case child == nil:
+			element()
+			err = s.copy()
+		case child.op == nil:
+			element()
+			err = s.value(child)
+		default:
+			switch child.op.op.Op {
+			case "add":
+				if err = n.checkShift(count, child.op); err != nil {
+					return err
+				}
+				element()
+				s.write(child.op.value)
+				element()
+				err = s.copy()
+			case "remove":
+				if err = n.checkShift(count, child.op); err != nil {
+					return err
+				}
+				err = s.skip()
+			default:
+				element()
+				err = s.apply(child.op)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := s.d.Token(); err != nil {
+		return err
+	}
+
+	// elements added at the end, in the order of the patch: appends go
+	// last, and an index is relative to the end of the input
+	type insert struct {
+		op *streamOp
+		at int
+	}
+	var tail []insert
+	for _, op := range n.appends {
+		tail = append(tail, insert{op, -1})
+	}
+	for key, child := range n.children {
+		i, err := pointer.ParseIndex(key, math.MaxInt, false)
+		if err == nil && i < count {
+			continue
+		}
+		if err != nil || child.op == nil || child.op.op.Op != "add" {
+			op := child.firstOp()
+			if err == nil {
+				err = fmt.Errorf("array index %s out of bounds: %w", key, ErrNotFound)
+			}
+			return opError(op.index, &op.op, err)
+		}
+		tail = append(tail, insert{child.op, i - count})
+	}
+	sort.Slice(tail, func(i, j int) bool { return tail[i].op.index < tail[j].op.index })
+	var added []interface{}
+	for _, t := range tail {
+		at := t.at
+		if at < 0 {
+			at = len(added)
+		} else if at > len(added) {
+			return opError(t.op.index, &t.op.op, fmt.Errorf("array index %d out of bounds: %w", count+at, ErrNotFound))
+		}
+		added = slices.Insert(added, at, t.op.value)
+	}
+	for _, v := range added {
+		element()
+		s.write(v)
+	}
+	s.w.WriteByte(']')
+	return nil
+}
+
+// checkShift fails if op, which inserts or removes element i of the array
+// n, is followed by an operation addressing element i or a later one, whose
+// index would then refer to another element than in the input.
+func (n *streamNode) checkShift(i int, op *streamOp) error {
+	var later *streamOp
+	for key, child := range n.children {
+		j, err := strconv.Atoi(key)
+		if err != nil || j < i {
+			continue
+		}
+		if c := child.firstAfter(op.index); c != nil && (later == nil || c.index < later.index) {
+			later = c
+		}
+	}
+	if later != nil {
+		return opError(later.index, &later.op, fmt.Errorf("cannot be streamed after operation %d changed the length of the array", op.index))
+	}
+	return nil
+}
+
+// apply applies op, which addresses the next value of the input, and writes
+// the result. Removes are handled by the containers.
+func (s *streamer) apply(op *streamOp) error {
+	switch op.op.Op {
+	case "test":
+		var current interface{}
+		if err := s.d.Decode(&current); err != nil {
+			return err
+		}
+		if !jsonEqual(current, op.value) {
+			return &TestFailedError{Index: op.index, Path: op.op.Path, Expected: op.value, Actual: current}
+		}
+		s.write(current)
+		return nil
+	}
+	if err := s.skip(); err != nil {
+		return err
+	}
+	s.write(op.value)
+	return nil
+}
+
+// copy copies the next value of the input to the output.
+func (s *streamer) copy() error {
+	tok, err := s.d.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		s.w.WriteByte('{')
+		for first := true; s.d.More(); first = false {
+			key, err := s.d.Token()
+			if err != nil {
+				return err
+			}
+			if !first {
+				s.w.WriteByte(',')
+			}
+			s.write(key)
+			s.w.WriteByte(':')
+			if err := s.copy(); err != nil {
+				return err
+			}
+		}
+		s.w.WriteByte('}')
+	case json.Delim('['):
+		s.w.WriteByte('[')
+		for first := true; s.d.More(); first = false {
+			if !first {
+				s.w.WriteByte(',')
+			}
+			if err := s.copy(); err != nil {
+				return err
+			}
+		}
+		s.w.WriteByte(']')
+	default:
+		s.write(tok)
+		return nil
+	}
+	_, err = s.d.Token()
+	return err
+}
+
+// skip reads the next value of the input without writing it.
+func (s *streamer) skip() error {
+	depth := 0
+	for {
+		tok, err := s.d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func (s *streamer) write(v interface{}) {
+	b, _ := marshal(v)
+	s.w.Write(b)
+}
+
+func jsonType(tok json.Token) string {
+	switch tok.(type) {
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
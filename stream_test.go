@@ -0,0 +1,92 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestApplyStream(t *testing.T) {
+	doc := `{"big": [1, 2, {"x": "y"}], "n": 12345678901234567890, "obj": {"a": 1, "b": 2}, "list": ["a", "b", "c"]}`
+	cases := []struct{ patch, expected string }{
+		{`[]`, `{"big":[1,2,{"x":"y"}],"n":12345678901234567890,"obj":{"a":1,"b":2},"list":["a","b","c"]}`},
+		{`[
+			{"op": "test", "path": "/n", "value": 12345678901234567890},
+			{"op": "replace", "path": "/obj/a", "value": {"deep": [true]}},
+			{"op": "remove", "path": "/obj/b"},
+			{"op": "add", "path": "/obj/c", "value": "<new>"},
+			{"op": "remove", "path": "/big"}
+		]`, `{"n":12345678901234567890,"obj":{"a":{"deep":[true]},"c":"<new>"},"list":["a","b","c"]}`},
+		{`[
+			{"op": "replace", "path": "/list/2", "value": "C"},
+			{"op": "add", "path": "/list/1", "value": "x"},
+			{"op": "remove", "path": "/list/0"}
+		]`, `{"big":[1,2,{"x":"y"}],"n":12345678901234567890,"obj":{"a":1,"b":2},"list":["x","b","C"]}`},
+		{`[
+			{"op": "add", "path": "/list/-", "value": 1},
+			{"op": "add", "path": "/list/3", "value": 0},
+			{"op": "add", "path": "/list/-", "value": 2},
+			{"op": "add", "path": "/big/2/z", "value": null}
+		]`, `{"big":[1,2,{"x":"y","z":null}],"n":12345678901234567890,"obj":{"a":1,"b":2},"list":["a","b","c",0,1,2]}`},
+		{`[{"op": "replace", "path": "", "value": [1]}]`, `[1]`},
+	}
+	for _, c := range cases {
+		ops := parseStr(c.patch)
+		var out bytes.Buffer
+		if err := ApplyStream(strings.NewReader(doc), &out, ops); err != nil {
+			t.Errorf("%s: %v", c.patch, err)
+			continue
+		}
+		if out.String() != c.expected {
+			t.Errorf("%s:\nexpected %s\ngot      %s", c.patch, c.expected, out.String())
+		}
+		// the result is the same as with Apply
+		applied, err := ApplyBytes([]byte(doc), []byte(c.patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !jsonEqual(decodeNumber(t, applied), decodeNumber(t, out.Bytes())) {
+			t.Errorf("%s: Apply gives %s", c.patch, applied)
+		}
+	}
+
+	errs := []struct {
+		patch string
+		err   error
+	}{
+		{`[{"op": "test", "path": "/obj/a", "value": 2}]`, ErrTestFailed},
+		{`[{"op": "remove", "path": "/obj/z"}]`, ErrNotFound},
+		{`[{"op": "replace", "path": "/list/3", "value": 1}]`, ErrNotFound},
+		{`[{"op": "add", "path": "/list/4", "value": 1}]`, ErrNotFound},
+		{`[{"op": "add", "path": "/n/x", "value": 1}]`, nil},
+		{`[{"op": "add", "path": "/obj/-", "value": 1}]`, nil},
+		{`[{"op": "move", "from": "/obj/a", "path": "/x"}]`, ErrInvalidPatch},
+		{`[{"op": "remove", "path": "/obj"}, {"op": "add", "path": "/obj/a", "value": 1}]`, ErrInvalidPatch},
+		{`[{"op": "add", "path": "/obj/a", "value": 1}, {"op": "remove", "path": "/obj"}]`, ErrInvalidPatch},
+		{`[{"op": "remove", "path": "/list/0"}, {"op": "replace", "path": "/list/1", "value": 1}]`, nil},
+		{`[{"op": "add", "path": "/list/1", "value": 0}, {"op": "add", "path": "/list/3", "value": 1}]`, nil},
+		{`[{"op": "remove", "path": "/list/-"}]`, ErrInvalidPatch},
+	}
+	for _, c := range errs {
+		err := ApplyStream(strings.NewReader(doc), &bytes.Buffer{}, parseStr(c.patch))
+		if err == nil || c.err != nil && !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %v, got %v", c.patch, c.err, err)
+		}
+	}
+
+	for _, in := range []string{``, `{"a": 1} {}`, `{"a": }`} {
+		if err := ApplyStream(strings.NewReader(in), &bytes.Buffer{}, nil); err == nil {
+			t.Errorf("%q: expected a syntax error", in)
+		}
+	}
+}
+
+func decodeNumber(t *testing.T, b []byte) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := unmarshalNumber(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
@@ -0,0 +1,345 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// ErrStructInvalid matches errors for patched structs that failed
+// validation.
+var ErrStructInvalid = errors.New("patched struct is invalid")
+
+// PatchStruct applies operations to the JSON representation of the struct
+// pointed to by v and decodes the result back into it. v is only updated if
+// the patch applies, the result decodes and, when a StructValidator is set
+// with WithStructValidator, the patched struct is valid. The JSON text of
+// string fields tagged patch:"jsonstring" is patched as a document of its
+// own, as described for StructDocument, and encoded back compactly unless
+// the patch left it unchanged.
+func PatchStruct(v interface{}, operations []Operation, opts ...Option) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("PatchStruct needs a non-nil pointer, not %T", v)
+	}
+	doc, embedded, err := structDocument(v)
+	if err != nil {
+		return err
+	}
+	options := newOptions(opts)
+	options.UseNumber = true
+	a := &applier{opts: options}
+	result, err := a.apply(doc, operations)
+	if err != nil {
+		return err
+	}
+	if result, err = encodeJSONStrings(rv.Type(), result, embedded); err != nil {
+		return err
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	patched := reflect.New(rv.Elem().Type())
+	if err := json.Unmarshal(data, patched.Interface()); err != nil {
+		return err
+	}
+	if options.StructValidator != nil {
+		if err := options.StructValidator.ValidateStruct(patched.Interface()); err != nil {
+			return blame(err, operations, nil)
+		}
+	}
+	rv.Elem().Set(patched.Elem())
+	return nil
+}
+
+// ApplyTyped applies operations to doc and returns the result as a T, for
+// callers with typed models. Documents made of the values produced by
+// encoding/json, whose T is an interface, map[string]interface{} or
+// []interface{}, are patched like with Apply, and the patch must not turn
+// them into another type. Other types, such as structs or pointers to them,
+// are patched like with PatchStruct, through their JSON representation.
+// doc itself is never modified.
+func ApplyTyped[T any](doc T, operations []Operation, opts ...Option) (T, error) {
+	var zero T
+	switch t := reflect.TypeFor[T](); {
+	case t.Kind() == reflect.Interface,
+		t == reflect.TypeFor[map[string]interface{}](),
+		t == reflect.TypeFor[[]interface{}]():
+		result, err := Apply(doc, operations, opts...)
+		if err != nil {
+			return zero, err
+		}
+		v, ok := result.(T)
+		if !ok && result != nil {
+			return zero, fmt.Errorf("patched document is a %T, not a %s", result, t)
+		}
+		return v, nil
+	}
+	out := doc
+	if err := PatchStruct(&out, operations, opts...); err != nil {
+		return zero, err
+	}
+	return out, nil
+}
+
+// StructValidator checks the constraints of a struct, given a pointer to it.
+// To point at the offending fields, and so at the operations that modified
+// them, it returns a *StructError; any other error is attributed to the
+// whole struct.
+type StructValidator interface {
+	ValidateStruct(v interface{}) error
+}
+
+// WithStructValidator validates structs after PatchStruct has patched them.
+// See TagValidator for a validator driven by struct tags.
+func WithStructValidator(sv StructValidator) Option {
+	return func(o *Options) { o.StructValidator = sv }
+}
+
+// StructError lists the constraints a struct violates.
+type StructError struct {
+	Violations []Violation
+}
+
+// Violation is a constraint violated by a field.
+type Violation struct {
+	// Path points at the field in the JSON representation of the struct.
+	Path    string
+	Message string
+	// Ops lists the indexes of the operations that modified the field,
+	// one of its parents or one of its children. It is filled in by
+	// PatchStruct.
+	Ops []int
+}
+
+func (e *StructError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.Path + ": " + v.Message
+		if len(v.Ops) > 0 {
+			parts[i] += fmt.Sprintf(" (operations %v)", v.Ops)
+		}
+	}
+	return "invalid struct: " + strings.Join(parts, "; ")
+}
+
+// Is makes StructError match ErrStructInvalid.
+func (e *StructError) Is(target error) bool { return target == ErrStructInvalid }
+
+// Code returns "struct-invalid".
+func (e *StructError) Code() string { return "struct-invalid" }
+
+// blame attributes each violation of a validation error, a *SchemaError or
+// a *StructError, to the operations that caused it. The paths of the
+// operations are relative to scope.
+func blame(err error, operations []Operation, scope []string) error {
+	var violations []Violation
+	var schema *SchemaError
+	var se *StructError
+	switch {
+	case errors.As(err, &schema):
+		violations = schema.Violations
+	case errors.As(err, &se):
+		violations = se.Violations
+	default:
+		se = &StructError{Violations: []Violation{{Message: err.Error()}}}
+		violations = se.Violations
+	}
+	prefix := pointer.Pointer(scope).String()
+	for i := range violations {
+		v := &violations[i]
+		field, perr := pointer.Parse(v.Path)
+		if perr != nil {
+			continue
+		}
+		v.Ops = nil
+		for j, op := range operations {
+			if op.Op == "test" {
+				continue
+			}
+			if touches(prefix+op.Path, field) || op.Op == "move" && touches(prefix+op.From, field) {
+				v.Ops = append(v.Ops, j)
+			}
+		}
+	}
+	if schema != nil {
+		return schema
+	}
+	return se
+}
+
+// touches reports whether the value at ptr contains or is inside field.
+func touches(ptr string, field pointer.Pointer) bool {
+	p, err := pointer.Parse(ptr)
+	if err != nil {
+		return false
+	}
+	return samePrefix(p, field, min(len(p), len(field)))
+}
+
+// TagValidator is a StructValidator checking the constraints declared by
+// the validate tags of struct fields, and calling the Validate method of
+// values implementing it. It understands a subset of the rules of the
+// popular validator packages, separated by commas:
+//
+//	required    the field is not its zero value (nor a nil pointer)
+//	omitempty   skip the remaining rules when the field is its zero value
+//	min=N       numbers are at least N; strings, slices and maps have at
+//	            least N characters or elements
+//	max=N       the same, with at most N
+//	oneof=a b   the field, formatted with fmt, is one of the listed words
+//
+// Fields are named after their json tags in violation paths.
+type TagValidator struct{}
+
+// ValidateStruct implements StructValidator.
+func (TagValidator) ValidateStruct(v interface{}) error {
+	var vs []Violation
+	if err := checkValue(reflect.ValueOf(v), "", &vs); err != nil {
+		return err
+	}
+	if len(vs) > 0 {
+		return &StructError{Violations: vs}
+	}
+	return nil
+}
+
+func checkValue(v reflect.Value, path string, out *[]Violation) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.CanAddr() {
+		if val, ok := v.Addr().Interface().(interface{ Validate() error }); ok {
+			if err := val.Validate(); err != nil {
+				*out = append(*out, Violation{Path: path, Message: err.Error()})
+			}
+		}
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			p := path
+			if name != "" || !f.Anonymous {
+				if name == "" {
+					name = f.Name
+				}
+				p = path + "/" + pointer.Escape(name)
+			}
+			if tag := f.Tag.Get("validate"); tag != "" {
+				if err := checkRules(v.Field(i), tag, p, out); err != nil {
+					return fmt.Errorf("field %s: %w", f.Name, err)
+				}
+			}
+			if err := checkValue(v.Field(i), p, out); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := checkValue(v.Index(i), path+"/"+strconv.Itoa(i), out); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		for _, k := range v.MapKeys() {
+			if err := checkValue(v.MapIndex(k), path+"/"+pointer.Escape(k.String()), out); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkRules(v reflect.Value, tag, path string, out *[]Violation) error {
+	violate := func(format string, args ...interface{}) {
+		*out = append(*out, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		if name != "required" && name != "omitempty" && !reflect.Indirect(v).IsValid() {
+			// the remaining rules do not apply to nil pointers
+			return nil
+		}
+		switch name {
+		case "required":
+			if v.IsZero() {
+				violate("is required")
+				return nil
+			}
+		case "omitempty":
+			if v.IsZero() {
+				return nil
+			}
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return fmt.Errorf("invalid rule %q", rule)
+			}
+			size, what, ok := measure(v)
+			if !ok {
+				return fmt.Errorf("rule %q does not apply to %s", rule, v.Kind())
+			}
+			if name == "min" && size < limit {
+				violate("must be at least %s%s", arg, what)
+				return nil
+			}
+			if name == "max" && size > limit {
+				violate("must be at most %s%s", arg, what)
+				return nil
+			}
+		case "oneof":
+			s := fmt.Sprint(reflect.Indirect(v).Interface())
+			found := false
+			for _, w := range strings.Fields(arg) {
+				found = found || w == s
+			}
+			if !found {
+				violate("must be one of %s", arg)
+				return nil
+			}
+		default:
+			return fmt.Errorf("unknown rule %q", rule)
+		}
+	}
+	return nil
+}
+
+// measure returns the number a min or max rule compares with its limit.
+func measure(v reflect.Value) (float64, string, bool) {
+	v = reflect.Indirect(v)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " elements", true
+	}
+	return 0, "", false
+}
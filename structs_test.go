@@ -0,0 +1,127 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testService struct {
+	Name     string            `json:"name" validate:"required,max=10"`
+	Replicas int               `json:"replicas" validate:"min=1,max=5"`
+	Tier     string            `json:"tier,omitempty" validate:"omitempty,oneof=free pro"`
+	Ports    []testPort        `json:"ports" validate:"max=2"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Owner    *testOwner        `json:"owner,omitempty"`
+	internal int
+}
+
+type testPort struct {
+	Number int `json:"number" validate:"min=1,max=65535"`
+}
+
+type testOwner struct {
+	Email string `json:"email"`
+}
+
+func (o testOwner) Validate() error {
+	if o.Email == "" {
+		return errors.New("owner needs an email")
+	}
+	return nil
+}
+
+func TestPatchStruct(t *testing.T) {
+	s := testService{Name: "api", Replicas: 2, Ports: []testPort{{80}}, internal: 7}
+	err := PatchStruct(&s, parseStr(`[
+		{"op": "replace", "path": "/replicas", "value": 3},
+		{"op": "add", "path": "/ports/-", "value": {"number": 443}},
+		{"op": "add", "path": "/labels", "value": {"env": "prod"}}
+	]`), WithStructValidator(TagValidator{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := testService{Name: "api", Replicas: 3, Ports: []testPort{{80}, {443}}, Labels: map[string]string{"env": "prod"}}
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("expected %+v, got %+v", expected, s)
+	}
+
+	before := s
+	err = PatchStruct(&s, parseStr(`[
+		{"op": "replace", "path": "/name", "value": ""},
+		{"op": "replace", "path": "/replicas", "value": 9},
+		{"op": "replace", "path": "/ports/1/number", "value": 0},
+		{"op": "test", "path": "/replicas", "value": 9},
+		{"op": "add", "path": "/tier", "value": "gold"},
+		{"op": "add", "path": "/owner", "value": {}}
+	]`), WithStructValidator(TagValidator{}))
+	var se *StructError
+	if !errors.Is(err, ErrStructInvalid) || !errors.As(err, &se) {
+		t.Fatalf("expected a struct error, got %v", err)
+	}
+	violations := []Violation{
+		{Path: "/name", Message: "is required", Ops: []int{0}},
+		{Path: "/replicas", Message: "must be at most 5", Ops: []int{1}},
+		{Path: "/tier", Message: "must be one of free pro", Ops: []int{4}},
+		{Path: "/ports/1/number", Message: "must be at least 1", Ops: []int{2}},
+		{Path: "/owner", Message: "owner needs an email", Ops: []int{5}},
+	}
+	if !reflect.DeepEqual(se.Violations, violations) {
+		t.Errorf("expected\n%+v\ngot\n%+v", violations, se.Violations)
+	}
+	if !reflect.DeepEqual(s, before) {
+		t.Errorf("invalid patch modified the struct: %+v", s)
+	}
+
+	// without a validator, only the patch and the decoding can fail
+	if err := PatchStruct(&s, parseStr(`[{"op": "replace", "path": "/replicas", "value": 9}]`)); err != nil || s.Replicas != 9 {
+		t.Errorf("expected the patch to apply, got %v", err)
+	}
+	if err := PatchStruct(&s, parseStr(`[{"op": "replace", "path": "/replicas", "value": "x"}]`)); err == nil {
+		t.Error("expected a decoding error")
+	}
+	if err := PatchStruct(s, nil); err == nil {
+		t.Error("expected an error for a non-pointer")
+	}
+}
+
+func TestApplyTyped(t *testing.T) {
+	svc := testService{Name: "api", Replicas: 1, Owner: &testOwner{Email: "a@example.com"}}
+	patched, err := ApplyTyped(svc, parseStr(`[
+		{"op": "replace", "path": "/replicas", "value": 3},
+		{"op": "add", "path": "/ports", "value": [{"number": 80}]},
+		{"op": "replace", "path": "/owner/email", "value": "b@example.com"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.Replicas != 3 || len(patched.Ports) != 1 || patched.Owner.Email != "b@example.com" {
+		t.Errorf("unexpected result %+v", patched)
+	}
+	if svc.Replicas != 1 || svc.Owner.Email != "a@example.com" {
+		t.Errorf("the document was modified: %+v", svc)
+	}
+
+	ptr, err := ApplyTyped(&svc, parseStr(`[{"op": "replace", "path": "/name", "value": "web"}]`))
+	if err != nil || ptr == &svc || ptr.Name != "web" || svc.Name != "api" {
+		t.Errorf("unexpected result %+v, %v", ptr, err)
+	}
+	if _, err := ApplyTyped(svc, parseStr(`[{"op": "replace", "path": "/replicas", "value": "x"}]`)); err == nil {
+		t.Error("expected a value of the wrong type to fail")
+	}
+	if _, err := ApplyTyped(svc, parseStr(`[{"op": "replace", "path": "/replicas", "value": 9}]`), WithStructValidator(TagValidator{})); !errors.Is(err, ErrStructInvalid) {
+		t.Errorf("expected the struct to be validated, got %v", err)
+	}
+
+	m, err := ApplyTyped(map[string]interface{}{"a": 1.0}, parseStr(`[{"op": "add", "path": "/b", "value": 2}]`))
+	if err != nil || !reflect.DeepEqual(m, map[string]interface{}{"a": 1.0, "b": 2.0}) {
+		t.Errorf("unexpected result %v, %v", m, err)
+	}
+	if _, err := ApplyTyped(map[string]interface{}{}, parseStr(`[{"op": "replace", "path": "", "value": [1]}]`)); err == nil {
+		t.Error("expected turning an object into an array to fail")
+	}
+	v, err := ApplyTyped(decode(`[1]`), parseStr(`[{"op": "replace", "path": "", "value": "s"}]`))
+	if err != nil || v != "s" {
+		t.Errorf("unexpected result %v, %v", v, err)
+	}
+}
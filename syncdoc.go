@@ -0,0 +1,76 @@
+package patch
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// SyncDocument is a JSON document that any number of goroutines can patch
+// and read concurrently. Patches are applied one at a time and readers see
+// the document either before or after a patch, never in between.
+//
+// Patches do not copy the whole document: each operation copies the objects
+// and arrays along the path it writes to and shares everything else with the
+// previous version, so the cost of a patch depends on the depth and width of
+// the containers it touches rather than on the size of the document. Reads
+// take no lock.
+type SyncDocument struct {
+	mu   sync.Mutex // serializes patches
+	doc  atomic.Pointer[interface{}]
+	opts []Option
+}
+
+// NewSyncDocument returns a SyncDocument holding doc, which must not be
+// modified afterwards. opts are used for every patch applied to the
+// document; InPlace is ignored. With a UTF8 mode other than the default,
+// doc is checked once here.
+func NewSyncDocument(doc interface{}, opts ...Option) (*SyncDocument, error) {
+	if mode := newOptions(opts).UTF8; mode != UTF8PassThrough {
+		var err error
+		if doc, err = mode.checkDocument(doc, ""); err != nil {
+			return nil, err
+		}
+	}
+	d := &SyncDocument{opts: opts}
+	d.doc.Store(&doc)
+	return d, nil
+}
+
+// Apply applies ops to the document. If the patch fails, the document is
+// left unchanged, unless the options include ContinueOnError, in which case
+// the operations that succeeded are kept.
+func (d *SyncDocument) Apply(ops []Operation) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// the operations' values were checked, so the result stays valid
+	a := &applier{opts: newOptions(d.opts), shared: true, checked: true}
+	doc, err := a.apply(*d.doc.Load(), ops)
+	if err != nil && !a.opts.ContinueOnError {
+		return err
+	}
+	d.doc.Store(&doc)
+	return err
+}
+
+// Snapshot returns the current version of the document. It is shared with
+// other readers and with later versions, so it must not be modified; use
+// Get for a private copy of part of it.
+func (d *SyncDocument) Snapshot() interface{} {
+	return *d.doc.Load()
+}
+
+// Get returns a deep copy of the value ptr refers to in the current version
+// of the document.
+func (d *SyncDocument) Get(ptr string) (interface{}, error) {
+	p, err := pointer.Parse(ptr)
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.Get(d.Snapshot())
+	if err != nil {
+		return nil, err
+	}
+	return deepCopy(v), nil
+}
@@ -0,0 +1,116 @@
+package patch
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestSyncDocument(t *testing.T) {
+	d, err := NewSyncDocument(decode(`{"a": {"list": [1, 2]}, "b": {"x": 1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := d.Snapshot()
+	if err := d.Apply(parseStr(`[
+		{"op": "add", "path": "/a/list/-", "value": 3},
+		{"op": "move", "from": "/b/x", "path": "/a/x"}
+	]`)); err != nil {
+		t.Fatal(err)
+	}
+	after := d.Snapshot()
+	if !reflect.DeepEqual(before, decode(`{"a": {"list": [1, 2]}, "b": {"x": 1}}`)) {
+		t.Errorf("earlier snapshot was modified: %v", before)
+	}
+	if !reflect.DeepEqual(after, decode(`{"a": {"list": [1, 2, 3], "x": 1}, "b": {}}`)) {
+		t.Errorf("unexpected document %v", after)
+	}
+
+	// a failing patch leaves the document alone
+	if err := d.Apply(parseStr(`[{"op": "remove", "path": "/a/list/0"}, {"op": "remove", "path": "/zzz"}]`)); err == nil {
+		t.Fatal("expected an error")
+	}
+	if !reflect.DeepEqual(d.Snapshot(), after) {
+		t.Errorf("failed patch modified the document: %v", d.Snapshot())
+	}
+
+	v, err := d.Get("/a/list")
+	if err != nil || !reflect.DeepEqual(v, decode(`[1, 2, 3]`)) {
+		t.Fatalf("expected [1, 2, 3], got %v, %v", v, err)
+	}
+	v.([]interface{})[0] = "changed"
+	if !reflect.DeepEqual(d.Snapshot(), after) {
+		t.Error("modifying the result of Get modified the document")
+	}
+	if _, err := d.Get("/missing"); err == nil {
+		t.Error("expected an error")
+	}
+
+	if _, err := NewSyncDocument(map[string]interface{}{"s": "\xff"}, WithUTF8(UTF8Reject)); err == nil {
+		t.Error("expected invalid UTF-8 to be rejected")
+	}
+}
+
+func TestSyncDocumentArrayMoves(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithContinueOnError()}, {WithOnErrorHints()}} {
+		d, err := NewSyncDocument(decode(`["d", {}, {}, [[0]], [[1]]]`), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := d.Snapshot()
+		if err := d.Apply(parseStr(`[
+			{"op": "move", "from": "/0", "path": "/1/a"},
+			{"op": "move", "from": "/0", "path": "/2/0/-"}
+		]`)); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(before, decode(`["d", {}, {}, [[0]], [[1]]]`)) {
+			t.Errorf("earlier snapshot was modified: %v", before)
+		}
+		if after := d.Snapshot(); !reflect.DeepEqual(after, decode(`[{"a": "d"}, [[0]], [[1, {}]]]`)) {
+			t.Errorf("unexpected document %v", after)
+		}
+	}
+}
+
+func TestSyncDocumentConcurrent(t *testing.T) {
+	d, err := NewSyncDocument(decode(`{"a": 0, "b": 0, "log": []}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				ops := parseStr(fmt.Sprintf(`[
+					{"op": "replace", "path": "/a", "value": %d},
+					{"op": "add", "path": "/log/-", "value": %d},
+					{"op": "replace", "path": "/b", "value": %d}
+				]`, i, i, i))
+				if err := d.Apply(ops); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				m := d.Snapshot().(map[string]interface{})
+				if m["a"] != m["b"] {
+					t.Errorf("read a half applied patch: %v", m)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(d.Snapshot().(map[string]interface{})["log"].([]interface{})); n != 200 {
+		t.Errorf("expected 200 log entries, got %d", n)
+	}
+}
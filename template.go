@@ -0,0 +1,91 @@
+package patch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+)
+
+// TemplateEngine is a ScriptEngine whose scripts are text/template
+// templates producing JSON text: a template is executed with the input as
+// its data, and its output is decoded, with json.Number for numbers, to give
+// the result. The function json encodes a value as JSON:
+//
+//	{{json (printf "%s-%s" .current .value)}}
+//
+// Templates only reach their input and the functions of the engine, and
+// cannot loop other than over their input, so they terminate. Their output
+// is limited, and execution stops at the next write once the context is
+// done.
+type TemplateEngine struct {
+	// Funcs adds functions to templates. They are part of the sandbox and
+	// must follow the contract of ScriptEngine.
+	Funcs template.FuncMap
+	// MaxOutput is the largest output of a template, in bytes; 1 MiB when
+	// zero.
+	MaxOutput int
+}
+
+// Compile parses the template src.
+func (e *TemplateEngine) Compile(name, src string) (Script, error) {
+	t := template.New(name).Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := marshal(v)
+			return string(b), err
+		},
+	})
+	if e.Funcs != nil {
+		t = t.Funcs(e.Funcs)
+	}
+	t, err := t.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	max := e.MaxOutput
+	if max <= 0 {
+		max = 1 << 20
+	}
+	return &templateScript{t: t, max: max}, nil
+}
+
+type templateScript struct {
+	t   *template.Template
+	max int
+}
+
+func (s *templateScript) Run(ctx context.Context, input interface{}) (interface{}, error) {
+	w := &limitedWriter{ctx: ctx, max: s.max}
+	if err := s.t.Execute(w, input); err != nil {
+		return nil, err
+	}
+	out := bytes.TrimSpace(w.buf.Bytes())
+	if len(out) == 0 {
+		return nil, fmt.Errorf("template %s produced no output", s.t.Name())
+	}
+	var v interface{}
+	if err := unmarshalNumber(out, &v); err != nil {
+		return nil, fmt.Errorf("template %s produced invalid JSON: %v", s.t.Name(), err)
+	}
+	return v, nil
+}
+
+// limitedWriter is a buffer failing writes beyond max bytes or once ctx is
+// done, which stops the execution of a template.
+type limitedWriter struct {
+	ctx context.Context
+	buf bytes.Buffer
+	max int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if w.buf.Len()+len(p) > w.max {
+		return 0, errOutputLimit
+	}
+	return w.buf.Write(p)
+}
+
+var errOutputLimit = fmt.Errorf("template output exceeds its limit: %w", ErrScriptLimit)
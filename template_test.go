@@ -0,0 +1,64 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTemplateEngine(t *testing.T) {
+	e := &TemplateEngine{Funcs: map[string]interface{}{"upper": strings.ToUpper}}
+	s, err := e.Compile("join", `{{json (printf "%s-%s" (upper .current) .value)}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := parseStr(`[{"op": "join", "path": "/s", "value": "b"}]`)
+	result, err := Apply(decode(`{"s": "a"}`), ops,
+		WithOptions(Options{Operators: map[string]OperatorFunc{"join": ScriptOperator(s, ScriptLimits{})}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !jsonEqual(result, decode(`{"s": "A-b"}`)) {
+		t.Errorf("unexpected result %v", result)
+	}
+
+	sel, err := e.Compile("select", `{{if eq .status "active"}}[{"op": "add", "path": "/seen", "value": {{json .id}}}]{{else}}null{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selected, err := ScriptSelector(sel, ScriptLimits{})(decode(`{"status": "active", "id": 12}`))
+	if err != nil || len(selected) != 1 || string(selected[0].Value) != "12" {
+		t.Errorf("unexpected selection %v %v", selected, err)
+	}
+
+	if _, err := e.Compile("bad", `{{`); err == nil {
+		t.Error("expected a parse error")
+	}
+	for src, input := range map[string]interface{}{
+		``:       nil,
+		`{`:      nil,
+		`{{.x}}`: "not a map",
+	} {
+		s, err := e.Compile("t", src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Run(context.Background(), input); err == nil {
+			t.Errorf("%q: expected an error", src)
+		}
+	}
+}
+
+func TestTemplateEngineLimits(t *testing.T) {
+	e := &TemplateEngine{MaxOutput: 10}
+	s, _ := e.Compile("long", `"{{range .}}{{.}}{{end}}"`)
+	if _, err := s.Run(context.Background(), []interface{}{"0123456789", "0123456789"}); !errors.Is(err, ErrScriptLimit) {
+		t.Errorf("expected the output limit to be exceeded, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Run(ctx, []interface{}{"a"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected execution to stop, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTxnDone is returned when a transaction is used after Commit or
+// Rollback.
+var ErrTxnDone = errors.New("transaction has already been committed or rolled back")
+
+// TxnFunc is a side effect run when a transaction commits. It receives the
+// patched document and every operation staged in the transaction.
+type TxnFunc func(doc interface{}, ops []Operation) error
+
+type txnEffect struct {
+	do, undo TxnFunc
+}
+
+// Txn stages patches against a document together with external side effects
+// (cache or index updates, for example) that must take effect with them or
+// not at all. The original document is never modified. A Txn is not safe for
+// concurrent use.
+type Txn struct {
+	doc     interface{}
+	ops     []Operation
+	effects []txnEffect
+	done    bool
+}
+
+// Begin starts a transaction on doc.
+func Begin(doc interface{}) *Txn {
+	return &Txn{doc: doc}
+}
+
+// Stage applies ops to the transaction's working document. If the patch
+// fails, the working document is left as it was and the error is returned;
+// the transaction may still be committed without it.
+func (t *Txn) Stage(ops []Operation) error {
+	if t.done {
+		return ErrTxnDone
+	}
+	doc, err := Apply(t.doc, ops)
+	if err != nil {
+		return err
+	}
+	t.doc = doc
+	t.ops = append(t.ops, ops...)
+	return nil
+}
+
+// Document returns the working document with every staged patch applied.
+func (t *Txn) Document() interface{} {
+	return t.doc
+}
+
+// OnCommit registers a side effect run by Commit. When a later side effect
+// fails, undo (which may be nil) is called to compensate for do.
+func (t *Txn) OnCommit(do, undo TxnFunc) error {
+	if t.done {
+		return ErrTxnDone
+	}
+	t.effects = append(t.effects, txnEffect{do, undo})
+	return nil
+}
+
+// Commit runs the registered side effects in order and returns the patched
+// document. If a side effect fails, the ones that already succeeded are
+// compensated in reverse order and the error is returned along with any
+// errors from the compensations.
+func (t *Txn) Commit() (interface{}, error) {
+	if t.done {
+		return nil, ErrTxnDone
+	}
+	t.done = true
+	for i, e := range t.effects {
+		err := e.do(t.doc, t.ops)
+		if err == nil {
+			continue
+		}
+		errs := []error{fmt.Errorf("side effect %d failed: %w", i, err)}
+		for j := i - 1; j >= 0; j-- {
+			undo := t.effects[j].undo
+			if undo == nil {
+				continue
+			}
+			if err := undo(t.doc, t.ops); err != nil {
+				errs = append(errs, fmt.Errorf("compensating side effect %d failed: %w", j, err))
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+	return t.doc, nil
+}
+
+// Rollback abandons the transaction. No side effects are run.
+func (t *Txn) Rollback() {
+	t.done = true
+	t.doc = nil
+	t.ops = nil
+	t.effects = nil
+}
@@ -0,0 +1,74 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTxnCommit(t *testing.T) {
+	doc := map[string]interface{}{"n": 1.0}
+	txn := Begin(doc)
+	if err := txn.Stage(parseStr(`[{"op": "replace", "path": "/n", "value": 2}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn.Stage(parseStr(`[{"op": "test", "path": "/n", "value": 1}]`)); err == nil {
+		t.Fatal("expected a failing stage to return an error")
+	}
+	if err := txn.Stage(parseStr(`[{"op": "add", "path": "/m", "value": 3}]`)); err != nil {
+		t.Fatal(err)
+	}
+	var seen []Operation
+	txn.OnCommit(func(doc interface{}, ops []Operation) error {
+		seen = ops
+		return nil
+	}, nil)
+
+	result, err := txn.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"n": 2.0, "m": 3.0}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	if len(seen) != 2 {
+		t.Errorf("expected side effect to see 2 operations, got %v", seen)
+	}
+	if doc["n"] != 1.0 {
+		t.Error("original document was modified")
+	}
+	if _, err := txn.Commit(); err != ErrTxnDone {
+		t.Errorf("expected ErrTxnDone, got %v", err)
+	}
+}
+
+func TestTxnCompensation(t *testing.T) {
+	txn := Begin(map[string]interface{}{})
+	txn.Stage(parseStr(`[{"op": "add", "path": "/a", "value": 1}]`))
+
+	var log []string
+	step := func(name string, fail bool) (TxnFunc, TxnFunc) {
+		return func(interface{}, []Operation) error {
+				if fail {
+					return errors.New(name + " failed")
+				}
+				log = append(log, "do "+name)
+				return nil
+			}, func(interface{}, []Operation) error {
+				log = append(log, "undo "+name)
+				return nil
+			}
+	}
+	txn.OnCommit(step("cache", false))
+	txn.OnCommit(step("index", false))
+	txn.OnCommit(step("search", true))
+
+	if _, err := txn.Commit(); err == nil {
+		t.Fatal("expected commit to fail")
+	}
+	expected := []string{"do cache", "do index", "undo index", "undo cache"}
+	if !reflect.DeepEqual(log, expected) {
+		t.Errorf("expected %v, got %v", expected, log)
+	}
+}
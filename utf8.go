@@ -0,0 +1,165 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// UTF8Mode selects how strings that are not valid UTF-8 are handled. This
+// includes JSON escapes of unpaired UTF-16 surrogates such as "\ud800",
+// which encoding/json silently decodes to U+FFFD.
+type UTF8Mode int
+
+const (
+	// UTF8PassThrough leaves strings untouched.
+	UTF8PassThrough UTF8Mode = iota
+	// UTF8Reject fails the patch when the document or an operation
+	// contains an invalid string.
+	UTF8Reject
+	// UTF8Replace replaces invalid sequences with U+FFFD.
+	UTF8Replace
+)
+
+const replacementChar = "\uFFFD"
+
+// checkOperation validates (or, in replace mode, repairs) the strings of op.
+func (m UTF8Mode) checkOperation(op *Operation) error {
+	switch m {
+	case UTF8Reject:
+		if !utf8.ValidString(op.Path) {
+			return fmt.Errorf("invalid UTF-8 in path %q", op.Path)
+		}
+		if !utf8.ValidString(op.From) {
+			return fmt.Errorf("invalid UTF-8 in from %q", op.From)
+		}
+		if !utf8.Valid(op.Value) {
+			return fmt.Errorf("invalid UTF-8 in value of %s %s", op.Op, op.Path)
+		}
+		if hasLoneSurrogate(op.Value) {
+			return fmt.Errorf("unpaired UTF-16 surrogate in value of %s %s", op.Op, op.Path)
+		}
+	case UTF8Replace:
+		// encoding/json already substitutes invalid sequences in values
+		op.Path = strings.ToValidUTF8(op.Path, replacementChar)
+		op.From = strings.ToValidUTF8(op.From, replacementChar)
+	}
+	return nil
+}
+
+// checkDocument validates (or, in replace mode, repairs) every key and
+// string value of doc.
+func (m UTF8Mode) checkDocument(doc interface{}, path string) (interface{}, error) {
+	switch v := doc.(type) {
+	case string:
+		if utf8.ValidString(v) {
+			return v, nil
+		}
+		if m == UTF8Reject {
+			return nil, fmt.Errorf("invalid UTF-8 in string at %q", path)
+		}
+		return strings.ToValidUTF8(v, replacementChar), nil
+	case map[string]interface{}:
+		for k, child := range v {
+			p := path + "/" + pointer.Escape(k)
+			if !utf8.ValidString(k) {
+				if m == UTF8Reject {
+					return nil, fmt.Errorf("invalid UTF-8 in key at %q", p)
+				}
+				delete(v, k)
+				k = strings.ToValidUTF8(k, replacementChar)
+			}
+			child, err := m.checkDocument(child, p)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = child
+		}
+	case *SortedObject:
+		var repaired []string
+		for i, k := range v.keys {
+			p := path + "/" + pointer.Escape(k)
+			if !utf8.ValidString(k) {
+				if m == UTF8Reject {
+					return nil, fmt.Errorf("invalid UTF-8 in key at %q", p)
+				}
+				repaired = append(repaired, k)
+			}
+			child, err := m.checkDocument(v.values[i], p)
+			if err != nil {
+				return nil, err
+			}
+			v.values[i] = child
+		}
+		for _, k := range repaired {
+			child, _ := v.Get(k)
+			v.Delete(k)
+			v.Set(strings.ToValidUTF8(k, replacementChar), child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			child, err := m.checkDocument(child, fmt.Sprintf("%s/%d", path, i))
+			if err != nil {
+				return nil, err
+			}
+			v[i] = child
+		}
+	}
+	return doc, nil
+}
+
+// hasLoneSurrogate reports whether the JSON text b contains a \u escape of
+// a UTF-16 surrogate that is not part of a valid pair.
+func hasLoneSurrogate(b []byte) bool {
+	for i := 0; i < len(b); i++ {
+		if b[i] != '\\' {
+			continue
+		}
+		i++
+		if i >= len(b) || b[i] != 'u' {
+			continue
+		}
+		r, ok := hexRune(b[i+1:])
+		if !ok {
+			continue
+		}
+		i += 4
+		switch {
+		case r >= 0xDC00 && r <= 0xDFFF:
+			return true
+		case r >= 0xD800 && r <= 0xDBFF:
+			if i+2 >= len(b) || b[i+1] != '\\' || b[i+2] != 'u' {
+				return true
+			}
+			lo, ok := hexRune(b[i+3:])
+			if !ok || lo < 0xDC00 || lo > 0xDFFF {
+				return true
+			}
+			i += 6
+		}
+	}
+	return false
+}
+
+func hexRune(b []byte) (rune, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range b[:4] {
+		switch {
+		case '0' <= c && c <= '9':
+			c -= '0'
+		case 'a' <= c && c <= 'f':
+			c = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, true
+}
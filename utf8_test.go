@@ -0,0 +1,55 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHasLoneSurrogate(t *testing.T) {
+	cases := map[string]bool{
+		`"plain"`:                    false,
+		`"\ud83d\ude00"`:             false,
+		`"\ud83d"`:                   true,
+		`"\ude00"`:                   true,
+		`"\ud83d\u0041"`:             true,
+		`"\\ud83d"`:                  false,
+		`{"a": "x\ud800y"}`:          true,
+		`["\u00e9", "\uDBFF\uDFFF"]`: false,
+	}
+	for s, expected := range cases {
+		if got := hasLoneSurrogate([]byte(s)); got != expected {
+			t.Errorf("%s: expected %v, got %v", s, expected, got)
+		}
+	}
+}
+
+func TestUTF8Reject(t *testing.T) {
+	opts := WithUTF8(UTF8Reject)
+	_, _, err := ApplyWithReport(map[string]interface{}{}, parseStr(`[{"op": "add", "path": "/a", "value": "\ud800"}]`), opts)
+	if err == nil {
+		t.Error("expected a lone surrogate in a value to be rejected")
+	}
+	_, _, err = ApplyWithReport(map[string]interface{}{"a": "bad\xff"}, nil, opts)
+	if err == nil {
+		t.Error("expected invalid UTF-8 in the document to be rejected")
+	}
+	_, _, err = ApplyWithReport(map[string]interface{}{}, []Operation{{Op: "add", Path: "/\xff", Value: []byte(`1`)}}, opts)
+	if err == nil {
+		t.Error("expected invalid UTF-8 in a path to be rejected")
+	}
+}
+
+func TestUTF8Replace(t *testing.T) {
+	doc := map[string]interface{}{"k\xff": []interface{}{"v\xfe"}}
+	result, _, err := ApplyWithReport(doc, parseStr(`[{"op": "add", "path": "/b", "value": "\ud800"}]`), WithUTF8(UTF8Replace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"k\uFFFD": []interface{}{"v\uFFFD"},
+		"b":       "\uFFFD",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
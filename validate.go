@@ -0,0 +1,51 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Validate checks operations without applying them: every operator must be
+// known, the members it requires present, its pointers well formed and its
+// value valid JSON. The returned error joins an InvalidPatchError for every
+// invalid operation.
+func Validate(operations []Operation) error {
+	a := &applier{opts: &Options{}}
+	var errs []error
+	for i, op := range operations {
+		if _, err := a.compile(i, op); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ParseStrict is like Parse, but also rejects operations that lack "path",
+// which Parse treats as the root when missing, that have members other
+// than "op", "path", "value", "from" and the "onError" and "default" hints,
+// or whose "op", "path", "from" or "onError" is not a string, and then
+// Validates the result.
+func ParseStrict(patch []byte) ([]Operation, error) {
+	var raw []map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &raw); err != nil {
+		return nil, err
+	}
+	ops := make([]Operation, len(raw))
+	var errs []error
+	a := &applier{opts: &Options{}}
+	for i, members := range raw {
+		err := decodeMembers(members, &ops[i], true)
+		if err != nil {
+			err = &InvalidPatchError{Index: i, Op: ops[i].Op, Err: err}
+		} else {
+			_, err = a.compile(i, ops[i])
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return ops, nil
+}
@@ -0,0 +1,66 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if err := Validate(parseStr(`[
+		{"op": "add", "path": "/a", "value": 1},
+		{"op": "move", "from": "/a", "path": "/a"},
+		{"op": "move", "from": "/a/b", "path": "/a"}
+	]`)); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	err := Validate([]Operation{
+		{Op: "add", Path: "/a", Value: []byte(`{bad json`)},
+		{Op: "frob", Path: "/a"},
+		{Op: "remove", Path: "a"},
+		{Op: "move", From: "/a", Path: "/a/b"},
+		{Op: "test", Path: "/a"},
+		{Op: "remove", Path: "/a"},
+	})
+	if !errors.Is(err, ErrInvalidPatch) {
+		t.Fatalf("expected ErrInvalidPatch, got %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if !strings.Contains(err.Error(), fmt.Sprintf("operation %d", i)) {
+			t.Errorf("expected operation %d to be reported in %v", i, err)
+		}
+	}
+	if strings.Contains(err.Error(), "operation 5") {
+		t.Errorf("did not expect a valid operation to be reported in %v", err)
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	if _, err := ParseStrict([]byte(`[{"op": "add", "path": "", "value": null}, {"op": "remove", "path": "/a"}]`)); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	for _, s := range []string{
+		`[{"op": "remove"}]`,
+		`[{"path": "/a"}]`,
+		`[{"op": "add", "path": "/a"}]`,
+		`[{"op": "move", "path": "/a"}]`,
+		`[{"op": "add", "path": 1, "value": 1}]`,
+		`[{"op": "add", "path": null, "value": 1}]`,
+		`[{"op": "bogus", "path": "/a"}]`,
+		`[null]`,
+		`{}`,
+	} {
+		if _, err := ParseStrict([]byte(s)); err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+}
+
+func TestInvalidValueIsReported(t *testing.T) {
+	_, err := Apply(map[string]interface{}{}, []Operation{{Op: "add", Path: "/a", Value: []byte(`[1,`)}})
+	if !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("expected an invalid value to be reported, got %v", err)
+	}
+}
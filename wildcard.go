@@ -0,0 +1,93 @@
+package patch
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/grncdr/json-patch/pointer"
+)
+
+// hasWildcard reports whether path contains a "*" token.
+func hasWildcard(path []string) bool {
+	return slices.Contains(path, "*")
+}
+
+// execWildcard applies the i-th instruction, whose path contains "*"
+// tokens, once for every path it matches in o. See Options.Wildcards.
+func (a *applier) execWildcard(o interface{}, i int, ins *instruction) (interface{}, error) {
+	if hasWildcard(ins.from) {
+		return nil, opError(i, &ins.op, fmt.Errorf("wildcards are not allowed in 'from'"))
+	}
+	paths, err := expandWildcards(o, ins.path, 0)
+	if err != nil {
+		return nil, opError(i, &ins.op, err)
+	}
+	return a.execEach(o, i, ins, paths)
+}
+
+// execEach applies the i-th instruction once for each of paths, in order,
+// in place of its own path. Every path after the first gets its own copy of
+// the value, so that the targets do not alias each other.
+func (a *applier) execEach(o interface{}, i int, ins *instruction, paths [][]string) (interface{}, error) {
+	var err error
+	for j, path := range paths {
+		if err := a.interrupted(i); err != nil {
+			return nil, err
+		}
+		concrete := *ins
+		concrete.path = path
+		concrete.op.Path = pointer.Pointer(path).String()
+		concrete.shared = ins.shared || j > 0
+		o = a.isolate(o, &concrete)
+		if o, err = a.execPath(o, i, &concrete); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// expandWildcards returns the paths matching path in root, where a "*"
+// token from position start on matches every member of an object, in
+// sorted order, and every element of an array, last first, so that
+// operations removing or inserting elements do not shift the elements left
+// to visit.
+func expandWildcards(root interface{}, path []string, start int) ([][]string, error) {
+	n := slices.Index(path[start:], "*")
+	if n < 0 {
+		return [][]string{path}, nil
+	}
+	n += start
+	parent, err := pointer.Pointer(path[:n]).Get(root)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []string
+	switch v := parent.(type) {
+	case map[string]interface{}:
+		for k := range v {
+			tokens = append(tokens, k)
+		}
+		sort.Strings(tokens)
+	case *SortedObject:
+		tokens = slices.Clone(v.keys)
+	case []interface{}:
+		for j := len(v) - 1; j >= 0; j-- {
+			tokens = append(tokens, strconv.Itoa(j))
+		}
+	default:
+		return nil, fmt.Errorf("%s: cannot expand * in a %T", pointer.Pointer(path[:n]), parent)
+	}
+	var out [][]string
+	for _, t := range tokens {
+		p := slices.Clone(path)
+		p[n] = t
+		rest, err := expandWildcards(root, p, n+1)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rest...)
+	}
+	return out, nil
+}
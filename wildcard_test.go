@@ -0,0 +1,107 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWildcards(t *testing.T) {
+	doc := decode(`{"users": [{"name": "a", "password": "x"}, {"name": "b", "password": "y"}], "m": {"k": [1, 2], "j": [3]}}`)
+	for _, tc := range []struct {
+		patch, expected string
+		touched         []string
+	}{
+		{
+			`[{"op": "remove", "path": "/users/*/password"}]`,
+			`{"users": [{"name": "a"}, {"name": "b"}], "m": {"k": [1, 2], "j": [3]}}`,
+			[]string{"/users/1/password", "/users/0/password"},
+		},
+		{
+			`[{"op": "remove", "path": "/m/*/*"}]`,
+			`{"users": [{"name": "a", "password": "x"}, {"name": "b", "password": "y"}], "m": {"k": [], "j": []}}`,
+			[]string{"/m/j/0", "/m/k/1", "/m/k/0"},
+		},
+		{
+			`[{"op": "add", "path": "/users/*/admin", "value": false}, {"op": "test", "path": "/users/*/admin", "value": false}]`,
+			`{"users": [{"name": "a", "password": "x", "admin": false}, {"name": "b", "password": "y", "admin": false}], "m": {"k": [1, 2], "j": [3]}}`,
+			[]string{"/users/1/admin", "/users/0/admin"},
+		},
+		{
+			`[{"op": "copy", "from": "/users/0/name", "path": "/m/*"}]`,
+			`{"users": [{"name": "a", "password": "x"}, {"name": "b", "password": "y"}], "m": {"k": "a", "j": "a"}}`,
+			[]string{"/m/j", "/m/k"},
+		},
+		{
+			`[{"op": "remove", "path": "/m/j/*"}, {"op": "remove", "path": "/m/j/*"}]`,
+			`{"users": [{"name": "a", "password": "x"}, {"name": "b", "password": "y"}], "m": {"k": [1, 2], "j": []}}`,
+			[]string{"/m/j/0"},
+		},
+	} {
+		result, report, err := ApplyWithReport(doc, parseStr(tc.patch), WithWildcards())
+		if err != nil {
+			t.Errorf("%s: %v", tc.patch, err)
+			continue
+		}
+		if expected := decode(tc.expected); !reflect.DeepEqual(result, expected) {
+			t.Errorf("%s: expected %v, got %v", tc.patch, expected, result)
+		}
+		if touched := report.Touched(); !reflect.DeepEqual(touched, tc.touched) {
+			t.Errorf("%s: expected %v to be touched, got %v", tc.patch, tc.touched, touched)
+		}
+	}
+}
+
+func TestExpandedTargetsDoNotAlias(t *testing.T) {
+	expected := decode(`{"users": [{"n": 1, "s": {"a": 9}}, {"n": 2, "s": {"a": 1}}]}`)
+	for _, tc := range []struct {
+		path string
+		opt  Option
+	}{
+		{"/users/*/s", WithWildcards()},
+		{"$.users[*].s", WithJSONPath()},
+	} {
+		add := []Operation{{Op: "add", Path: tc.path, Value: []byte(`{"a": 1}`)}}
+		replace := parseStr(`[{"op": "replace", "path": "/users/0/s/a", "value": 9}]`)
+
+		result, err := Apply(decode(`{"users": [{"n": 1}, {"n": 2}]}`), append(add, replace...), tc.opt)
+		if err != nil || !reflect.DeepEqual(result, expected) {
+			t.Errorf("%s in one patch: expected %v, got %v %v", tc.path, expected, result, err)
+		}
+
+		doc, err := ApplyUnsafe(decode(`{"users": [{"n": 1}, {"n": 2}]}`), add, tc.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result, err := ApplyUnsafe(doc, replace); err != nil || !reflect.DeepEqual(result, expected) {
+			t.Errorf("%s in two patches: expected %v, got %v %v", tc.path, expected, result, err)
+		}
+	}
+}
+
+func TestWildcardErrors(t *testing.T) {
+	doc := decode(`{"users": [{"name": "a"}, {"name": "b", "password": "y"}], "n": 1}`)
+	ops := parseStr(`[{"op": "remove", "path": "/users/*/password"}]`)
+	if _, err := Apply(doc, ops, WithWildcards()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the missing password to fail the operation, got %v", err)
+	}
+	// a failing operation does not modify the shared document
+	if err := Check(doc, ops, WithWildcards()); err == nil {
+		t.Error("expected Check to fail")
+	}
+	if expected := decode(`{"users": [{"name": "a"}, {"name": "b", "password": "y"}], "n": 1}`); !reflect.DeepEqual(doc, expected) {
+		t.Errorf("the document was modified: %v", doc)
+	}
+	for _, p := range []string{
+		`[{"op": "remove", "path": "/n/*"}]`,
+		`[{"op": "remove", "path": "/users/*/missing/*"}]`,
+		`[{"op": "copy", "from": "/users/*", "path": "/x"}]`,
+	} {
+		if _, err := Apply(doc, parseStr(p), WithWildcards()); err == nil {
+			t.Errorf("%s: expected an error", p)
+		}
+	}
+	if _, err := Apply(decode(`{"*": 1}`), parseStr(`[{"op": "remove", "path": "/*"}, {"op": "add", "path": "/*", "value": 2}]`)); err != nil {
+		t.Errorf("expected * to name a member by default, got %v", err)
+	}
+}